@@ -7,8 +7,10 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net/url"
 
 	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/httpfetch"
 )
 
 // Structure for our options and state.
@@ -16,6 +18,12 @@ type addCmd struct {
 
 	// Configuration file, used for testing
 	config *configfile.ConfigFile
+
+	// Should we add feeds even if they duplicate existing ones?
+	force bool
+
+	// Should we skip fetching feeds to look for duplicates?
+	offline bool
 }
 
 // Arguments handles argument-flags we might have.
@@ -24,6 +32,9 @@ type addCmd struct {
 // which allows testing.
 func (a *addCmd) Arguments(flags *flag.FlagSet) {
 	a.config = configfile.New()
+
+	flags.BoolVar(&a.force, "force", false, "Add feeds even if they duplicate existing feeds.")
+	flags.BoolVar(&a.offline, "offline", false, "Don't fetch feeds to find duplicates, only compare their URLs.")
 }
 
 // Info is part of the subcommand-API
@@ -32,6 +43,15 @@ func (a *addCmd) Info() (string, string) {
 
 Add one or more specified URLs to the configuration file.
 
+Adding a feed which is already present would result in each of its items
+being emailed twice, so feeds which duplicate an existing feed are refused.
+As well as the URL itself, each feed is fetched to find the URL it redirects
+to, and the URL it claims for itself, which are compared to the existing
+feeds too.  Trivial variations, such as a trailing slash, are ignored.
+
+You may use '-offline' to skip fetching the feeds, or '-force' to add them
+regardless.
+
 To see details of the configuration file, including the location,
 please run:
 
@@ -49,17 +69,30 @@ func (a *addCmd) Execute(args []string) int {
 	// Upgrade our configuration-file if necessary
 	a.config.Upgrade()
 
-	_, err := a.config.Parse()
+	entries, err := a.config.Parse()
 	if err != nil {
 		fmt.Printf("Error parsing file: %s\n", err.Error())
 		return 1
 	}
 
+	// Did we refuse to add any feeds?
+	refused := false
+
 	// For each argument add it to the list
 	for _, entry := range args {
 
+		// Refuse duplicates, unless forced.
+		if !a.force {
+			if dup := a.duplicate(entries, entry); dup != "" {
+				fmt.Printf("Refusing to add %s, which duplicates the existing feed %s\n", entry, dup)
+				refused = true
+				continue
+			}
+		}
+
 		// Add the entry
 		a.config.Add(entry)
+		entries = append(entries, configfile.Feed{URL: entry})
 	}
 
 	// Save the list.
@@ -69,6 +102,62 @@ func (a *addCmd) Execute(args []string) int {
 		return 1
 	}
 
+	if refused {
+		return 1
+	}
+
 	// All done, with no errors.
 	return 0
 }
+
+// urlKey returns the given URL in a canonical form, so that trivial
+// variations of the same URL compare as equal.
+func urlKey(uri string) string {
+
+	u, err := url.Parse(uri)
+	if err != nil || u.Host == "" {
+		return uri
+	}
+	return normalizeURL(u)
+}
+
+// duplicate returns the URL of the existing feed which the given URL
+// duplicates, or the empty string if there is none.
+//
+// Unless we're offline the feed is fetched, so that the URL it redirects
+// to, and the URL it claims for itself, may also be compared.
+func (a *addCmd) duplicate(entries []configfile.Feed, uri string) string {
+
+	existing := make(map[string]string)
+	for _, entry := range entries {
+		existing[urlKey(entry.URL)] = entry.URL
+	}
+
+	if dup, ok := existing[urlKey(uri)]; ok {
+		return dup
+	}
+
+	var candidates []string
+	if !a.offline {
+
+		// Only try once, we're just looking.
+		helper := httpfetch.New(configfile.Feed{URL: uri, Options: []configfile.Option{
+			{Name: "retry", Value: "1"},
+			{Name: "delay", Value: "0"},
+		}})
+		feed, err := helper.Fetch()
+		if helper.FinalURL() != "" {
+			candidates = append(candidates, helper.FinalURL())
+		}
+		if err == nil && feed.FeedLink != "" {
+			candidates = append(candidates, feed.FeedLink)
+		}
+	}
+
+	for _, candidate := range candidates {
+		if dup, ok := existing[urlKey(candidate)]; ok {
+			return dup
+		}
+	}
+	return ""
+}
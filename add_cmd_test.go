@@ -1,7 +1,10 @@
 package main
 
 import (
-	"io/ioutil"
+	"flag"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
 	"os"
 	"testing"
 
@@ -19,7 +22,7 @@ https://example.net/
  - foo: bar
 `
 	data := []byte(content)
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("Error creating temporary file")
 	}
@@ -32,7 +35,8 @@ https://example.net/
 	}
 
 	add := addCmd{}
-	add.Arguments(nil)
+	add.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+	add.offline = true
 	config := configfile.NewWithPath(tmpfile.Name())
 	add.config = config
 
@@ -59,3 +63,54 @@ https://example.net/
 
 	os.Remove(tmpfile.Name())
 }
+
+func TestAddDuplicate(t *testing.T) {
+
+	// A feed which redirects, and one which claims another URL.
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/old":
+			http.Redirect(w, r, ts.URL+"/feed", http.StatusMovedPermanently)
+		case "/mirror":
+			fmt.Fprintf(w, `<?xml version="1.0"?>
+<feed xmlns="http://www.w3.org/2005/Atom"><title>T</title>
+<link rel="self" href="%s/feed/"/></feed>`, ts.URL)
+		default:
+			fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title></channel></rss>`)
+		}
+	}))
+	defer ts.Close()
+
+	tmpfile, err := os.CreateTemp("", "example")
+	if err != nil {
+		t.Fatalf("Error creating temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Write([]byte(ts.URL + "/feed\n"))
+	tmpfile.Close()
+
+	for _, uri := range []string{ts.URL + "/feed/", ts.URL + "/old", ts.URL + "/mirror"} {
+		add := addCmd{config: configfile.NewWithPath(tmpfile.Name())}
+		if add.Execute([]string{uri}) != 1 {
+			t.Errorf("expected %s to be refused", uri)
+		}
+	}
+
+	// Offline only the URL is compared.
+	add := addCmd{config: configfile.NewWithPath(tmpfile.Name()), offline: true}
+	if add.Execute([]string{ts.URL + "/old"}) != 0 {
+		t.Errorf("expected offline add to succeed")
+	}
+
+	// Forced additions are allowed.
+	add = addCmd{config: configfile.NewWithPath(tmpfile.Name()), force: true}
+	if add.Execute([]string{ts.URL + "/mirror"}) != 0 {
+		t.Errorf("expected forced add to succeed")
+	}
+
+	entries, _ := configfile.NewWithPath(tmpfile.Name()).Parse()
+	if len(entries) != 3 {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+}
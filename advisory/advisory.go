@@ -0,0 +1,259 @@
+// Package advisory looks up the security advisories which feed items
+// mention, so that emails may link to them along with their severity.
+//
+// CVE identifiers are looked up via the API of the National Vulnerability
+// Database, and the results are cached upon the local filesystem, to avoid
+// fetching them every time they're mentioned.
+package advisory
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// maxSize is the largest response we'll read from the API.
+const maxSize = 1024 * 1024
+
+// pattern matches a CVE identifier.
+var pattern = regexp.MustCompile(`\bCVE-[0-9]{4}-[0-9]{4,}\b`)
+
+// IDs returns the distinct CVE identifiers within the given text, in the
+// order in which they first appear.
+func IDs(text string) []string {
+
+	var ids []string
+	seen := make(map[string]bool)
+
+	for _, id := range pattern.FindAllString(text, -1) {
+		if !seen[id] {
+			seen[id] = true
+			ids = append(ids, id)
+		}
+	}
+	return ids
+}
+
+// Advisory holds the details of a single advisory.
+type Advisory struct {
+
+	// ID holds the identifier of the advisory, such as "CVE-2021-44228".
+	ID string `json:"id"`
+
+	// Score holds the CVSS base score, from 0 to 10, and Severity its
+	// rating, such as "CRITICAL".
+	//
+	// Both are empty if the advisory hasn't been scored yet.
+	Score    float64 `json:"score"`
+	Severity string  `json:"severity"`
+
+	// Version holds the version of CVSS which produced the score.
+	Version string `json:"version"`
+}
+
+// URL returns the address of the advisory within the NVD.
+func (a *Advisory) URL() string {
+	return "https://nvd.nist.gov/vuln/detail/" + a.ID
+}
+
+// Scored returns true if the advisory has been given a score.
+func (a *Advisory) Scored() bool {
+	return a.Version != ""
+}
+
+// Client is our state-storing structure
+type Client struct {
+
+	// api is the endpoint of the NVD API.
+	api string
+
+	// key is the API key sent to the NVD, if any, which raises the
+	// rate at which requests may be made.
+	key string
+
+	// dir is the directory in which we cache advisories.
+	dir string
+
+	// ttl is the length of time for which cached advisories are used,
+	// which is shorter for those which haven't been scored yet.
+	ttl        time.Duration
+	ttlPending time.Duration
+
+	// userAgent is the User-Agent header to send when fetching.
+	userAgent string
+}
+
+// New creates a new object, which caches advisories beneath our state
+// directory.
+//
+// The NVD API key, if any, is read from $NVD_API_KEY.
+func New() *Client {
+	return &Client{
+		api:        "https://services.nvd.nist.gov/rest/json/cves/2.0",
+		key:        os.Getenv("NVD_API_KEY"),
+		dir:        filepath.Join(configfile.New().StateDirectory(), "advisories"),
+		ttl:        7 * 24 * time.Hour,
+		ttlPending: 24 * time.Hour,
+		userAgent:  "rss2email (https://github.com/skx/rss2email)",
+	}
+}
+
+// SetUserAgent updates the User-Agent header we send when fetching.
+func (c *Client) SetUserAgent(agent string) {
+	c.userAgent = agent
+}
+
+// Lookup returns the advisory with the given CVE identifier.
+func (c *Client) Lookup(id string) (*Advisory, error) {
+
+	if !pattern.MatchString(id) {
+		return nil, fmt.Errorf("'%s' is not a CVE identifier", id)
+	}
+
+	path := filepath.Join(c.dir, id+".json")
+
+	adv, err := c.cached(path)
+	if err == nil {
+		return adv, nil
+	}
+
+	// Not present, or expired, so fetch it.
+	adv, err = c.fetch(id)
+	if err != nil {
+		return nil, err
+	}
+
+	// Failing to cache isn't fatal.
+	data, err := json.Marshal(adv)
+	if err == nil {
+		os.MkdirAll(c.dir, os.ModePerm)
+		_ = os.WriteFile(path, data, 0644)
+	}
+	return adv, nil
+}
+
+// cached returns the cached advisory, if it exists and has not expired.
+func (c *Client) cached(path string) (*Advisory, error) {
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	adv := &Advisory{}
+	err = json.Unmarshal(data, adv)
+	if err != nil {
+		return nil, err
+	}
+
+	ttl := c.ttl
+	if !adv.Scored() {
+		ttl = c.ttlPending
+	}
+	if time.Since(fi.ModTime()) > ttl {
+		return nil, fmt.Errorf("%s has expired", path)
+	}
+	return adv, nil
+}
+
+// metric is a CVSS metric, as returned by the NVD API.
+type metric struct {
+	Type     string `json:"type"`
+	Severity string `json:"baseSeverity"`
+	Data     struct {
+		Version  string  `json:"version"`
+		Score    float64 `json:"baseScore"`
+		Severity string  `json:"baseSeverity"`
+	} `json:"cvssData"`
+}
+
+// response is the part of the response of the NVD API which we use.
+type response struct {
+	Vulnerabilities []struct {
+		CVE struct {
+			ID      string `json:"id"`
+			Metrics struct {
+				V40 []metric `json:"cvssMetricV40"`
+				V31 []metric `json:"cvssMetricV31"`
+				V30 []metric `json:"cvssMetricV30"`
+				V2  []metric `json:"cvssMetricV2"`
+			} `json:"metrics"`
+		} `json:"cve"`
+	} `json:"vulnerabilities"`
+}
+
+// fetch retrieves the given advisory from the NVD.
+func (c *Client) fetch(id string) (*Advisory, error) {
+
+	client := &http.Client{Timeout: 20 * time.Second}
+	req, err := http.NewRequest("GET", c.api+"?cveId="+url.QueryEscape(id), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", c.userAgent)
+	if c.key != "" {
+		req.Header.Set("apiKey", c.key)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %d", id, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize))
+	if err != nil {
+		return nil, err
+	}
+
+	var r response
+	err = json.Unmarshal(data, &r)
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse the details of %s: %s", id, err)
+	}
+	if len(r.Vulnerabilities) == 0 {
+		return nil, fmt.Errorf("%s was not found", id)
+	}
+
+	// Prefer the most recent version of CVSS, and within that the
+	// primary score, rather than those of other sources.
+	adv := &Advisory{ID: id}
+	m := r.Vulnerabilities[0].CVE.Metrics
+	for _, metrics := range [][]metric{m.V40, m.V31, m.V30, m.V2} {
+		if len(metrics) == 0 {
+			continue
+		}
+		best := metrics[0]
+		for _, x := range metrics {
+			if x.Type == "Primary" {
+				best = x
+				break
+			}
+		}
+		adv.Score = best.Data.Score
+		adv.Version = best.Data.Version
+		adv.Severity = strings.ToUpper(best.Data.Severity)
+		if adv.Severity == "" {
+			adv.Severity = strings.ToUpper(best.Severity)
+		}
+		break
+	}
+	return adv, nil
+}
@@ -0,0 +1,133 @@
+package advisory
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// A response from the NVD API, trimmed to the fields we use.
+var log4shell = `{"vulnerabilities":[{"cve":{"id":"CVE-2021-44228","metrics":{
+  "cvssMetricV31":[
+    {"source":"other@example.com","type":"Secondary","cvssData":{"version":"3.1","baseScore":9.0,"baseSeverity":"CRITICAL"}},
+    {"source":"nvd@nist.gov","type":"Primary","cvssData":{"version":"3.1","baseScore":10.0,"baseSeverity":"CRITICAL"}}],
+  "cvssMetricV2":[
+    {"source":"nvd@nist.gov","type":"Primary","cvssData":{"version":"2.0","baseScore":9.3},"baseSeverity":"HIGH"}]}}}]}`
+
+// TestIDs ensures we find CVE identifiers.
+func TestIDs(t *testing.T) {
+
+	ids := IDs("Fixes CVE-2021-44228, and CVE-2021-45046 (see CVE-2021-44228), but not CVE-21-1 or XCVE-2021-1234")
+	if strings.Join(ids, ",") != "CVE-2021-44228,CVE-2021-45046" {
+		t.Fatalf("unexpected identifiers: %v", ids)
+	}
+
+	if len(IDs("nothing to see here")) != 0 {
+		t.Fatalf("unexpected identifiers")
+	}
+}
+
+// TestLookup fetches an advisory, and ensures it is cached.
+func TestLookup(t *testing.T) {
+
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch r.URL.Query().Get("cveId") {
+		case "CVE-2021-44228":
+			w.Write([]byte(log4shell))
+		case "CVE-2024-0001":
+			w.Write([]byte(`{"vulnerabilities":[{"cve":{"id":"CVE-2024-0001","metrics":{}}}]}`))
+		default:
+			w.Write([]byte(`{"vulnerabilities":[]}`))
+		}
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.api = ts.URL
+	c.dir = t.TempDir()
+
+	// Fetch twice, the second should be cached.
+	for i := 0; i < 2; i++ {
+		adv, err := c.Lookup("CVE-2021-44228")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if adv.Score != 10.0 || adv.Severity != "CRITICAL" || adv.Version != "3.1" {
+			t.Fatalf("unexpected advisory: %v", adv)
+		}
+		if adv.URL() != "https://nvd.nist.gov/vuln/detail/CVE-2021-44228" {
+			t.Fatalf("unexpected URL: %s", adv.URL())
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected one fetch, got %d", count)
+	}
+
+	// An advisory which hasn't been scored yet.
+	adv, err := c.Lookup("CVE-2024-0001")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if adv.Scored() {
+		t.Fatalf("unexpected score: %v", adv)
+	}
+
+	// Advisories which don't exist aren't cached.
+	for i := 0; i < 2; i++ {
+		_, err = c.Lookup("CVE-2024-9999")
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+	if count != 4 {
+		t.Fatalf("expected four fetches, got %d", count)
+	}
+
+	// And nothing is fetched for bogus identifiers.
+	_, err = c.Lookup("CVE-bogus")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if count != 4 {
+		t.Fatalf("expected four fetches, got %d", count)
+	}
+}
+
+// TestPending ensures advisories which haven't been scored are refetched
+// sooner than those which have.
+func TestPending(t *testing.T) {
+
+	scored := false
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if scored {
+			w.Write([]byte(log4shell))
+			return
+		}
+		w.Write([]byte(`{"vulnerabilities":[{"cve":{"id":"CVE-2021-44228","metrics":{}}}]}`))
+	}))
+	defer ts.Close()
+
+	c := New()
+	c.api = ts.URL
+	c.dir = t.TempDir()
+	c.ttlPending = 0
+
+	adv, err := c.Lookup("CVE-2021-44228")
+	if err != nil || adv.Scored() {
+		t.Fatalf("unexpected result: %v %v", adv, err)
+	}
+
+	scored = true
+	adv, err = c.Lookup("CVE-2021-44228")
+	if err != nil || !adv.Scored() {
+		t.Fatalf("unexpected result: %v %v", adv, err)
+	}
+	if count != 2 {
+		t.Fatalf("expected two fetches, got %d", count)
+	}
+}
@@ -0,0 +1,226 @@
+// Package aggregate writes the items we've processed into a single Atom
+// feed, so that other tools may consume the results of our filtering as
+// a feed once again.
+//
+// The feed holds the newest items we've received, from every feed, and is
+// itself the only state we keep: it is read when opened, new items are
+// added to the top, and the oldest are dropped once the limit is reached.
+package aggregate
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/xml"
+	"errors"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/river"
+)
+
+// DefaultLimit is the default number of items the feed holds.
+const DefaultLimit = 100
+
+// atomLink is a link, within our feed.
+type atomLink struct {
+	Href string `xml:"href,attr"`
+	Rel  string `xml:"rel,attr,omitempty"`
+}
+
+// atomPerson is the author of an entry.
+type atomPerson struct {
+	Name string `xml:"name"`
+}
+
+// atomContent is the content of an entry.
+type atomContent struct {
+	Type string `xml:"type,attr"`
+	Body string `xml:",chardata"`
+}
+
+// atomSource describes the feed an entry came from.
+type atomSource struct {
+	ID    string    `xml:"id"`
+	Title string    `xml:"title,omitempty"`
+	Link  *atomLink `xml:"link,omitempty"`
+}
+
+// atomEntry is a single entry within our feed.
+type atomEntry struct {
+	ID        string       `xml:"id"`
+	Title     string       `xml:"title"`
+	Link      *atomLink    `xml:"link,omitempty"`
+	Published string       `xml:"published,omitempty"`
+	Updated   string       `xml:"updated"`
+	Author    *atomPerson  `xml:"author,omitempty"`
+	Source    atomSource   `xml:"source"`
+	Content   *atomContent `xml:"content,omitempty"`
+}
+
+// atomFeed is the document we write.
+type atomFeed struct {
+	XMLName   xml.Name    `xml:"http://www.w3.org/2005/Atom feed"`
+	ID        string      `xml:"id"`
+	Title     string      `xml:"title"`
+	Updated   string      `xml:"updated"`
+	Generator string      `xml:"generator"`
+	Entries   []atomEntry `xml:"entry"`
+}
+
+// Feed holds our state.
+type Feed struct {
+
+	// path is the file the feed is written to.
+	path string
+
+	// limit is the number of entries the feed holds.
+	limit int
+
+	// entries are those entries, newest first.
+	entries []atomEntry
+
+	// changed is true if entries have been added since we last wrote
+	// the feed.
+	changed bool
+}
+
+// Path returns the default location of the feed.
+func Path() string {
+	return filepath.Join(configfile.New().StateDirectory(), "river.atom")
+}
+
+// Open reads the feed at the given path, if it exists, which will hold
+// the given number of items, or DefaultLimit if that is zero.
+func Open(path string, limit int) (*Feed, error) {
+
+	if limit <= 0 {
+		limit = DefaultLimit
+	}
+	f := &Feed{path: path, limit: limit}
+
+	data, err := os.ReadFile(path)
+	if errors.Is(err, fs.ErrNotExist) {
+		return f, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	var doc atomFeed
+	err = xml.Unmarshal(data, &doc)
+	if err != nil {
+		return nil, err
+	}
+	f.entries = doc.Entries
+	return f, nil
+}
+
+// hash returns a stable name for the given value.
+func hash(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// Add adds the given item, which is that we'd add to the HTML archive, to
+// the top of the feed.
+//
+// The feed is not updated until Write is called.
+func (f *Feed) Add(item river.Item) {
+
+	if item.Received.IsZero() {
+		item.Received = time.Now()
+	}
+
+	// The GUIDs of items needn't be URIs, as Atom requires, and are
+	// only unique within their feed.
+	id := item.GUID
+	if id == "" {
+		id = item.Link
+	}
+
+	entry := atomEntry{
+		ID:      "urn:sha1:" + hash(item.Feed+"\n"+id),
+		Title:   item.Title,
+		Updated: item.Received.UTC().Format(time.RFC3339),
+		Source:  atomSource{ID: item.Feed, Title: item.FeedTitle, Link: &atomLink{Href: item.Feed, Rel: "self"}},
+	}
+	if item.Link != "" {
+		entry.Link = &atomLink{Href: item.Link, Rel: "alternate"}
+	}
+	if !item.Published.IsZero() {
+		entry.Published = item.Published.UTC().Format(time.RFC3339)
+	}
+	if item.Author != "" {
+		entry.Author = &atomPerson{Name: item.Author}
+	}
+	if item.HTML != "" {
+		entry.Content = &atomContent{Type: "html", Body: item.HTML}
+	}
+
+	f.entries = append([]atomEntry{entry}, f.entries...)
+	if len(f.entries) > f.limit {
+		f.entries = f.entries[:f.limit]
+	}
+	f.changed = true
+}
+
+// Write writes the feed, if items have been added, replacing the previous
+// contents atomically.
+func (f *Feed) Write() error {
+
+	if !f.changed {
+		return nil
+	}
+
+	doc := atomFeed{
+		ID:        "urn:sha1:" + hash(f.path),
+		Title:     "rss2email",
+		Updated:   time.Now().UTC().Format(time.RFC3339),
+		Generator: "rss2email",
+		Entries:   f.entries,
+	}
+	if len(f.entries) > 0 {
+		doc.Updated = f.entries[0].Updated
+	}
+
+	data, err := xml.MarshalIndent(doc, "", "  ")
+	if err != nil {
+		return err
+	}
+	data = append([]byte(xml.Header), data...)
+
+	err = os.MkdirAll(filepath.Dir(f.path), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(f.path), ".aggregate")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	_, err = tmp.Write(data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	err = os.Rename(tmp.Name(), f.path)
+	if err != nil {
+		return err
+	}
+	f.changed = false
+	return nil
+}
@@ -0,0 +1,118 @@
+package aggregate
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/river"
+)
+
+func TestFeed(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "feeds", "river.atom")
+
+	// Nothing to do
+	f, err := Open(path, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	err = f.Write()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if _, err := os.Stat(path); err == nil {
+		t.Fatalf("empty feed was written")
+	}
+
+	published := time.Date(2021, 1, 2, 12, 0, 0, 0, time.UTC)
+	f.Add(river.Item{Feed: "https://example.com/rss", FeedTitle: "Example", Title: "First", Link: "https://example.com/1", GUID: "one", Published: published, HTML: "<p>one</p>"})
+	f.Add(river.Item{Feed: "https://example.net/atom", Title: "Other <b>", Link: "https://example.net/1", Author: "Steve"})
+	err = f.Write()
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	// A later run preserves the existing items, up to the limit.
+	f, err = Open(path, 2)
+	if err != nil {
+		t.Fatalf("failed to open: %s", err)
+	}
+	f.Add(river.Item{Feed: "https://example.com/rss", Title: "Second", Link: "https://example.com/2"})
+	err = f.Write()
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	// The feed is one which we, and others, can read.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+	feed, err := gofeed.NewParser().ParseString(string(data))
+	if err != nil {
+		t.Fatalf("failed to parse: %s\n%s", err, data)
+	}
+	if feed.FeedType != "atom" || len(feed.Items) != 2 {
+		t.Fatalf("unexpected feed: %s", data)
+	}
+	if feed.Items[0].Title != "Second" || feed.Items[1].Title != "Other <b>" || feed.Items[1].Author.Name != "Steve" {
+		t.Fatalf("unexpected items: %s", data)
+	}
+	if !strings.Contains(string(data), "<source>") || strings.Contains(string(data), "First") {
+		t.Fatalf("unexpected feed: %s", data)
+	}
+
+	// Items from different feeds have different IDs, even when their
+	// GUIDs are the same.
+	f.Add(river.Item{Feed: "https://example.org/rss", Title: "Second", Link: "https://example.com/2"})
+	if f.entries[0].ID == f.entries[1].ID {
+		t.Fatalf("items of different feeds share an ID")
+	}
+}
+
+func TestHandler(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "river.atom")
+	h := Handler(path)
+
+	// Nothing has been written yet.
+	rec := httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusNotFound {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+
+	f, _ := Open(path, 0)
+	f.Add(river.Item{Feed: "https://example.com/rss", Title: "First", Link: "https://example.com/1"})
+	err := f.Write()
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("GET", "/", nil))
+	if rec.Code != http.StatusOK || !strings.HasPrefix(rec.Header().Get("Content-Type"), "application/atom+xml") || !strings.Contains(rec.Body.String(), "First") {
+		t.Fatalf("unexpected response %d: %s", rec.Code, rec.Body.String())
+	}
+
+	// Unchanged feeds aren't sent again.
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("If-Modified-Since", rec.Header().Get("Last-Modified"))
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, req)
+	if rec.Code != http.StatusNotModified {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+
+	rec = httptest.NewRecorder()
+	h.ServeHTTP(rec, httptest.NewRequest("POST", "/", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+}
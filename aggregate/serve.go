@@ -0,0 +1,39 @@
+package aggregate
+
+import (
+	"net/http"
+	"os"
+)
+
+// Handler returns the HTTP handler which serves the feed at the given path,
+// such that it may be polled by other tools.
+//
+// The feed is read upon each request, so the changes made by each run are
+// served as soon as they're written.
+func Handler(path string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodGet && r.Method != http.MethodHead {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		f, err := os.Open(path)
+		if err != nil {
+			http.Error(w, "no items have been received", http.StatusNotFound)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		// ServeContent handles conditional requests for us, via the
+		// modification time of the feed.
+		w.Header().Set("Content-Type", "application/atom+xml; charset=utf-8")
+		http.ServeContent(w, r, "river.atom", info.ModTime(), f)
+	})
+}
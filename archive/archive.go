@@ -0,0 +1,225 @@
+// Package archive stores the items we've processed within a SQLite
+// database, so that they may be searched later.
+//
+// Each record holds the metadata of the item, its text and HTML content,
+// the complete rendered email, and the status of the delivery.
+package archive
+
+import (
+	"database/sql"
+	"path/filepath"
+	"strings"
+	"time"
+
+	// Register the SQLite driver.
+	_ "github.com/mattn/go-sqlite3"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// The possible delivery states of an archived item.
+const (
+	// StatusSent means the email was delivered to the MTA.
+	StatusSent = "sent"
+
+	// StatusFailed means delivery of the email failed.
+	StatusFailed = "failed"
+)
+
+// schema creates the table we use, if it doesn't exist.
+const schema = `
+CREATE TABLE IF NOT EXISTS items (
+	id         INTEGER PRIMARY KEY AUTOINCREMENT,
+	feed       TEXT NOT NULL,
+	feed_title TEXT,
+	guid       TEXT,
+	title      TEXT,
+	link       TEXT,
+	published  TEXT,
+	text       TEXT,
+	html       TEXT,
+	message    BLOB,
+	status     TEXT NOT NULL,
+	error      TEXT,
+	archived   TEXT NOT NULL
+);
+CREATE INDEX IF NOT EXISTS items_guid ON items (guid);
+`
+
+// columns are those we select when reading entries.
+const columns = `id, feed, feed_title, guid, title, link, published, text, html, message, status, error, archived`
+
+// Entry is a single archived item.
+type Entry struct {
+
+	// ID is the unique ID of the entry, populated when searching.
+	ID int64
+
+	// Feed is the URL of the feed, and FeedTitle its title.
+	Feed      string
+	FeedTitle string
+
+	// GUID, Title, and Link identify the item.
+	GUID  string
+	Title string
+	Link  string
+
+	// Published is the date the item was published, if known.
+	Published time.Time
+
+	// Text and HTML hold the content of the item.
+	Text string
+	HTML string
+
+	// Message is the complete email which was generated.
+	Message []byte
+
+	// Status is the delivery status, and Error holds the reason
+	// for any failure.
+	Status string
+	Error  string
+
+	// Archived is the time the entry was added to the archive.
+	Archived time.Time
+}
+
+// Archive holds our state.
+type Archive struct {
+
+	// db is the handle to the database.
+	db *sql.DB
+}
+
+// Path returns the default location of the archive.
+func Path() string {
+	return filepath.Join(configfile.New().StateDirectory(), "archive.db")
+}
+
+// Open opens the archive at the given path, creating it if necessary.
+func Open(path string) (*Archive, error) {
+
+	db, err := sql.Open("sqlite3", path)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.Exec(schema)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
+
+	return &Archive{db: db}, nil
+}
+
+// Close closes the archive.
+func (a *Archive) Close() error {
+	return a.db.Close()
+}
+
+// Add stores the given entry in the archive.
+func (a *Archive) Add(e Entry) error {
+
+	if e.Archived.IsZero() {
+		e.Archived = time.Now()
+	}
+
+	published := ""
+	if !e.Published.IsZero() {
+		published = e.Published.UTC().Format(time.RFC3339)
+	}
+
+	_, err := a.db.Exec(`INSERT INTO items
+		(feed, feed_title, guid, title, link, published, text, html, message, status, error, archived)
+		VALUES (?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?, ?)`,
+		e.Feed, e.FeedTitle, e.GUID, e.Title, e.Link, published,
+		e.Text, e.HTML, e.Message, e.Status, e.Error,
+		e.Archived.UTC().Format(time.RFC3339))
+	return err
+}
+
+// Search returns the entries containing the given term, within their
+// title, link, or text, newest first.
+//
+// The results are limited to the given number of entries, unless that
+// is zero.
+func (a *Archive) Search(term string, limit int) ([]Entry, error) {
+
+	// Escape the wildcards of LIKE.
+	esc := strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`).Replace(term)
+	pattern := "%" + esc + "%"
+
+	query := `SELECT ` + columns + `
+		FROM items
+		WHERE title LIKE ? ESCAPE '\' OR link LIKE ? ESCAPE '\' OR text LIKE ? ESCAPE '\'
+		ORDER BY archived DESC, id DESC`
+	args := []interface{}{pattern, pattern, pattern}
+	if limit > 0 {
+		query += ` LIMIT ?`
+		args = append(args, limit)
+	}
+
+	return a.query(query, args...)
+}
+
+// Get returns the entry with the given ID, or nil if there is none.
+func (a *Archive) Get(id int64) (*Entry, error) {
+
+	entries, err := a.query(`SELECT `+columns+` FROM items WHERE id = ?`, id)
+	if err != nil || len(entries) == 0 {
+		return nil, err
+	}
+	return &entries[0], nil
+}
+
+// List returns the entries archived since the given time, oldest first,
+// optionally limited to those from the feed with the given URL.
+func (a *Archive) List(feed string, since time.Time) ([]Entry, error) {
+
+	query := `SELECT ` + columns + ` FROM items WHERE archived >= ?`
+	args := []interface{}{since.UTC().Format(time.RFC3339)}
+	if feed != "" {
+		query += ` AND feed = ?`
+		args = append(args, feed)
+	}
+	query += ` ORDER BY archived, id`
+
+	return a.query(query, args...)
+}
+
+// query runs the given query, returning the entries it selects.
+func (a *Archive) query(query string, args ...interface{}) ([]Entry, error) {
+
+	rows, err := a.db.Query(query, args...)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	var entries []Entry
+	for rows.Next() {
+		var e Entry
+		var feedTitle, guid, title, link, published, text, html, errstr sql.NullString
+		var archived string
+
+		err = rows.Scan(&e.ID, &e.Feed, &feedTitle, &guid, &title, &link, &published,
+			&text, &html, &e.Message, &e.Status, &errstr, &archived)
+		if err != nil {
+			return nil, err
+		}
+
+		e.FeedTitle = feedTitle.String
+		e.GUID = guid.String
+		e.Title = title.String
+		e.Link = link.String
+		e.Text = text.String
+		e.HTML = html.String
+		e.Error = errstr.String
+		e.Published, _ = time.Parse(time.RFC3339, published.String)
+		e.Archived, _ = time.Parse(time.RFC3339, archived)
+
+		entries = append(entries, e)
+	}
+
+	return entries, rows.Err()
+}
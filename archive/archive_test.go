@@ -0,0 +1,95 @@
+package archive
+
+import (
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestArchive(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "archive.db")
+
+	db, err := Open(path)
+	if err != nil {
+		t.Fatalf("failed to open archive: %s", err)
+	}
+	defer db.Close()
+
+	published := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+
+	entries := []Entry{
+		{Feed: "https://example.com/rss", FeedTitle: "Example", Title: "Go 1.17 released", Link: "https://example.com/1", Text: "All about golang", Published: published, Message: []byte("Subject: one\n"), Status: StatusSent},
+		{Feed: "https://example.com/rss", Title: "Something else", Link: "https://example.com/2", Text: "100% unrelated", Status: StatusFailed, Error: "exit status 1"},
+	}
+	for _, e := range entries {
+		err = db.Add(e)
+		if err != nil {
+			t.Fatalf("failed to add entry: %s", err)
+		}
+	}
+
+	tests := []struct {
+		term  string
+		count int
+	}{
+		{"golang", 1},
+		{"GO 1.17", 1},
+		{"example.com", 2},
+		{"100%", 1},
+		{"%", 1},
+		{"_", 0},
+		{"missing", 0},
+	}
+
+	for _, test := range tests {
+		res, err := db.Search(test.term, 0)
+		if err != nil {
+			t.Fatalf("error searching for %s: %s", test.term, err)
+		}
+		if len(res) != test.count {
+			t.Errorf("searching for %q found %d entries, expected %d", test.term, len(res), test.count)
+		}
+	}
+
+	// The newest entry is first, and the fields are preserved.
+	res, err := db.Search("example", 1)
+	if err != nil {
+		t.Fatalf("error searching: %s", err)
+	}
+	if len(res) != 1 || res[0].Status != StatusFailed || res[0].Error != "exit status 1" {
+		t.Fatalf("unexpected result: %v", res)
+	}
+
+	res, err = db.Search("golang", 0)
+	if err != nil {
+		t.Fatalf("error searching: %s", err)
+	}
+	if !res[0].Published.Equal(published) || string(res[0].Message) != "Subject: one\n" || res[0].FeedTitle != "Example" {
+		t.Fatalf("fields not preserved: %v", res[0])
+	}
+
+	// Entries may be retrieved by ID.
+	e, err := db.Get(res[0].ID)
+	if err != nil || e == nil || e.Title != "Go 1.17 released" {
+		t.Fatalf("failed to get entry %d: %v %v", res[0].ID, e, err)
+	}
+	e, err = db.Get(1000)
+	if err != nil || e != nil {
+		t.Fatalf("expected no entry: %v %v", e, err)
+	}
+
+	// Or listed, oldest first.
+	all, err := db.List("https://example.com/rss", time.Now().Add(-time.Hour))
+	if err != nil || len(all) != 2 || all[0].Title != "Go 1.17 released" {
+		t.Fatalf("unexpected listing: %v %v", all, err)
+	}
+	all, err = db.List("https://example.org/", time.Now().Add(-time.Hour))
+	if err != nil || len(all) != 0 {
+		t.Fatalf("unexpected listing: %v %v", all, err)
+	}
+	all, err = db.List("", time.Now().Add(time.Hour))
+	if err != nil || len(all) != 0 {
+		t.Fatalf("unexpected listing: %v %v", all, err)
+	}
+}
@@ -0,0 +1,138 @@
+// Package audit records each attempt to deliver an email, so that the
+// fate of an item may be discovered later.
+//
+// The log is a file beneath the state directory holding one JSON record
+// per line, to which records are only ever appended.
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// The possible results of a delivery attempt.
+const (
+	// ResultSent means the email was accepted by the MTA.
+	ResultSent = "sent"
+
+	// ResultFailed means delivery of the email failed, but may
+	// succeed if it is tried again.
+	ResultFailed = "failed"
+
+	// ResultRejected means the email was refused permanently, such
+	// as for an unknown recipient.
+	ResultRejected = "rejected"
+)
+
+// Record describes a single delivery attempt.
+type Record struct {
+
+	// Time is the time of the attempt.
+	Time time.Time `json:"time"`
+
+	// Feed is the URL of the feed, and FeedTitle its title.
+	Feed      string `json:"feed"`
+	FeedTitle string `json:"feed_title,omitempty"`
+
+	// GUID, Title, and Link identify the item.
+	GUID  string `json:"guid,omitempty"`
+	Title string `json:"title,omitempty"`
+	Link  string `json:"link,omitempty"`
+
+	// Recipients holds the addresses the email was sent to.
+	Recipients []string `json:"recipients"`
+
+	// Backend is the means of delivery, "smtp" or "sendmail".
+	Backend string `json:"backend"`
+
+	// Result is the result of the attempt, and Error holds the
+	// reason for any failure.
+	Result string `json:"result"`
+	Error  string `json:"error,omitempty"`
+
+	// Response is the reply of the SMTP server, or the output of
+	// sendmail, if any.
+	Response string `json:"response,omitempty"`
+}
+
+// Path returns the default location of the log.
+func Path() string {
+	return filepath.Join(configfile.New().StateDirectory(), "delivery.log")
+}
+
+// Append adds the given record to the end of the log at the given path,
+// creating it if necessary.
+func Append(path string, rec Record) error {
+
+	if rec.Time.IsZero() {
+		rec.Time = time.Now()
+	}
+
+	data, err := json.Marshal(rec)
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(path), os.ModePerm)
+	if err != nil {
+		return err
+	}
+
+	file, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+	if err != nil {
+		return err
+	}
+
+	// Records are written with a single call, so that those written
+	// by concurrent processes are not interleaved.
+	_, err = file.Write(append(data, '\n'))
+	if err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Read returns the records of the log at the given path, oldest first.
+//
+// A missing log holds no records.
+func Read(path string) ([]Record, error) {
+
+	file, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+	defer file.Close()
+
+	var records []Record
+
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+
+		var rec Record
+		err = json.Unmarshal(scanner.Bytes(), &rec)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid record: %s", path, line, err)
+		}
+		records = append(records, rec)
+	}
+
+	return records, scanner.Err()
+}
@@ -0,0 +1,60 @@
+package audit
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestAppendRead(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "logs", "delivery.log")
+
+	// A missing log is empty
+	records, err := Read(path)
+	if err != nil || len(records) != 0 {
+		t.Fatalf("unexpected result reading missing log: %v %s", records, err)
+	}
+
+	err = Append(path, Record{Feed: "https://example.com/", Title: "One", Recipients: []string{"steve@example.com"}, Backend: "sendmail", Result: ResultSent})
+	if err != nil {
+		t.Fatalf("failed to append: %s", err)
+	}
+	err = Append(path, Record{Feed: "https://example.com/", Title: "Two", Backend: "smtp", Result: ResultFailed, Error: "550 no such user"})
+	if err != nil {
+		t.Fatalf("failed to append: %s", err)
+	}
+
+	records, err = Read(path)
+	if err != nil {
+		t.Fatalf("failed to read: %s", err)
+	}
+	if len(records) != 2 {
+		t.Fatalf("expected two records, got %d", len(records))
+	}
+	if records[0].Title != "One" || records[0].Recipients[0] != "steve@example.com" {
+		t.Errorf("wrong first record: %v", records[0])
+	}
+	if records[1].Result != ResultFailed || records[1].Error != "550 no such user" {
+		t.Errorf("wrong second record: %v", records[1])
+	}
+	if time.Since(records[0].Time) > time.Minute {
+		t.Errorf("record wasn't timestamped: %v", records[0].Time)
+	}
+}
+
+func TestReadBogus(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "delivery.log")
+	err := os.WriteFile(path, []byte("{\"feed\":\"x\"}\n\nnot json\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	_, err = Read(path)
+	if err == nil || !strings.Contains(err.Error(), ":3: invalid record") {
+		t.Fatalf("expected error for line 3, got %v", err)
+	}
+}
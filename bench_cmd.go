@@ -0,0 +1,222 @@
+//
+// Measure the processing of items, against local feeds.
+//
+
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"os"
+	"runtime"
+	"runtime/pprof"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+)
+
+// Structure for our options and state.
+type benchCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Use the options of this feed, by URL or name.
+	feed string
+
+	// The number of items of the feed we generate, if no feeds are
+	// given.
+	items int
+
+	// The number of times each feed is processed.
+	rounds int
+
+	// The number of items processed concurrently.
+	workers int
+
+	// The name of the embedded template to use.
+	style string
+
+	// The files to write CPU, and memory, profiles to, if any.
+	cpuProfile string
+	memProfile string
+}
+
+// Arguments handles our flag-setup.
+func (b *benchCmd) Arguments(f *flag.FlagSet) {
+	b.config = configfile.New()
+
+	f.StringVar(&b.feed, "feed", "", "Process the items with the options of this feed, by URL or name.")
+	f.IntVar(&b.items, "items", 1000, "The number of items of the feed we generate, if no feeds are given.")
+	f.IntVar(&b.rounds, "rounds", 3, "The number of times each feed is processed.")
+	f.IntVar(&b.workers, "workers", 1, "The number of items processed concurrently.")
+	f.StringVar(&b.style, "style", "", "The embedded template to use, 'plain' or 'styled'.")
+	f.StringVar(&b.cpuProfile, "cpuprofile", "", "Write a CPU profile to the given file.")
+	f.StringVar(&b.memProfile, "memprofile", "", "Write a profile of the memory allocated to the given file.")
+}
+
+// Info is part of the subcommand-API.
+func (b *benchCmd) Info() (string, string) {
+	return "bench", `Measure the processing of items, against local feeds.
+
+This sub-command parses the given feed files, or a feed it generates if
+none are given, and processes their items as though they were new, though
+nothing is sent, and the state of the items isn't changed.  The time taken,
+and the memory allocated, by each stage of that processing is reported:
+
+  parse    Parsing the feeds.
+  prepare  Site-specific handling, and the changes made by per-feed options.
+  content  Finding the HTML content of the items.
+  filter   Matching the include, and exclude, options.
+  text     Converting the content to text.
+  render   Rendering the email, via the template.
+
+Each stage runs for every item before the next begins, so its measurements
+are distinct.  The '-workers' flag processes that many items concurrently,
+to see how the processing scales, and the '-cpuprofile', and '-memprofile',
+flags write profiles which may be examined with 'go tool pprof'.
+
+If '-feed' is given the items are processed with the options of that feed,
+from the configuration file.  Options which fetch the links of items, such
+as 'expand-links', will do so.
+
+Example:
+
+    $ rss2email bench
+    $ rss2email bench -rounds 10 -workers 4 large-feed.xml
+    $ rss2email bench -feed blog -cpuprofile cpu.prof blog.xml
+`
+}
+
+// Execute is invoked if the user specifies `bench` as the subcommand.
+func (b *benchCmd) Execute(args []string) int {
+
+	// Use the options of the feed, if one is given.
+	entry := configfile.Feed{URL: "bench"}
+	if b.feed != "" {
+		entries, err := b.config.Parse()
+		if err != nil {
+			fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+			return 1
+		}
+
+		found := configfile.Find(entries, b.feed)
+		if len(found) == 0 {
+			fmt.Printf("'%s' doesn't match any feed\n", b.feed)
+			return 1
+		}
+		if len(found) > 1 {
+			fmt.Printf("'%s' matches %d feeds, please be more specific\n", b.feed, len(found))
+			return 1
+		}
+		entry = found[0]
+	}
+
+	var feeds [][]byte
+	for _, file := range args {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			fmt.Printf("failed to read %s: %s\n", file, err.Error())
+			return 1
+		}
+		feeds = append(feeds, data)
+	}
+	if len(feeds) == 0 {
+		feeds = append(feeds, benchFeed(b.items))
+	}
+
+	if b.cpuProfile != "" {
+		f, err := os.Create(b.cpuProfile)
+		if err != nil {
+			fmt.Printf("failed to create %s: %s\n", b.cpuProfile, err.Error())
+			return 1
+		}
+		defer f.Close()
+
+		err = pprof.StartCPUProfile(f)
+		if err != nil {
+			fmt.Printf("failed to start CPU profile: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	p := processor.New()
+	p.SetStyle(b.style)
+
+	res, err := p.Bench(entry, feeds, b.rounds, b.workers)
+
+	if b.cpuProfile != "" {
+		pprof.StopCPUProfile()
+	}
+	if err != nil {
+		fmt.Printf("benchmark failed: %s\n", err.Error())
+		return 1
+	}
+
+	if b.memProfile != "" {
+		runtime.GC()
+		err = writeProfile(b.memProfile, "allocs")
+		if err != nil {
+			fmt.Printf("failed to write memory profile: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	fmt.Fprintf(out, "%s", res)
+	return 0
+}
+
+// writeProfile writes the named profile to the given file.
+func writeProfile(path string, name string) error {
+
+	f, err := os.Create(path)
+	if err != nil {
+		return err
+	}
+
+	err = pprof.Lookup(name).WriteTo(f, 0)
+	if err != nil {
+		f.Close()
+		return err
+	}
+	return f.Close()
+}
+
+// benchFeed returns an RSS feed containing the given number of items,
+// whose content is typical of a blog.
+func benchFeed(items int) []byte {
+
+	var buf bytes.Buffer
+	buf.WriteString(`<?xml version="1.0" encoding="UTF-8"?>
+<rss version="2.0" xmlns:content="http://purl.org/rss/1.0/modules/content/">
+<channel>
+<title>Benchmark</title>
+<link>https://example.com/</link>
+<description>A generated feed</description>
+`)
+
+	published := time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)
+	for i := 0; i < items; i++ {
+		fmt.Fprintf(&buf, `<item>
+<title>Item %d: notes on feeds, and email</title>
+<link>https://example.com/posts/%d.html</link>
+<guid>https://example.com/posts/%d.html</guid>
+<pubDate>%s</pubDate>
+<content:encoded><![CDATA[`, i, i, i, published.Add(-time.Duration(i)*time.Hour).Format(time.RFC1123Z))
+
+		for j := 0; j < 5; j++ {
+			fmt.Fprintf(&buf, `<p>This is paragraph %d of item %d, which has <a href="https://example.com/posts/%d.html#p%d">a link</a>, some <em>emphasis</em>, and a little <code>code</code>.</p>
+`, j, i, i, j)
+		}
+		buf.WriteString(`<ul><li>One</li><li>Two</li><li>Three</li></ul>
+<img src="https://example.com/images/photo.jpg" alt="A photo">
+]]></content:encoded>
+</item>
+`)
+	}
+
+	buf.WriteString("</channel>\n</rss>\n")
+	return buf.Bytes()
+}
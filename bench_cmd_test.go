@@ -0,0 +1,72 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestBench(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(cfg, []byte("https://example.com/feed\n - name: Sample\n - exclude-title: Item 1\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+	fixture := filepath.Join(dir, "feed.xml")
+	err = os.WriteFile(fixture, benchFeed(5), 0644)
+	if err != nil {
+		t.Fatalf("failed to write feed: %s", err)
+	}
+
+	b := benchCmd{config: configfile.NewWithPath(cfg), items: 10, rounds: 2, workers: 2}
+
+	// The generated feed.
+	out = new(bytes.Buffer)
+	if b.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error")
+	}
+	output := out.(*bytes.Buffer).String()
+	if !strings.HasPrefix(output, "1 feed, 10 items, 2 rounds, 2 workers, in ") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+	for _, stage := range []string{"parse", "prepare", "content", "filter", "text", "render"} {
+		if !strings.Contains(output, "\n"+stage+" ") {
+			t.Errorf("output didn't contain stage %s: %s", stage, output)
+		}
+	}
+
+	// A fixture, with the options of a feed, and profiles.
+	b.feed = "sample"
+	b.cpuProfile = filepath.Join(dir, "cpu.prof")
+	b.memProfile = filepath.Join(dir, "mem.prof")
+	out = new(bytes.Buffer)
+	if b.Execute([]string{fixture, fixture}) != 0 {
+		t.Fatalf("unexpected error")
+	}
+	if !strings.HasPrefix(out.(*bytes.Buffer).String(), "2 feeds, 10 items, 2 rounds") {
+		t.Fatalf("unexpected output: %s", out.(*bytes.Buffer).String())
+	}
+	for _, file := range []string{b.cpuProfile, b.memProfile} {
+		if fi, err := os.Stat(file); err != nil || fi.Size() == 0 {
+			t.Fatalf("profile %s wasn't written: %v", file, err)
+		}
+	}
+
+	// Missing fixtures, and feeds, are reported.
+	if b.Execute([]string{filepath.Join(dir, "missing.xml")}) != 1 {
+		t.Fatalf("expected error with a missing fixture")
+	}
+	b.feed = "missing"
+	if b.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with an unknown feed")
+	}
+}
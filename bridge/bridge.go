@@ -0,0 +1,96 @@
+// Package bridge expands the "bridge:" scheme which may be used in our
+// configuration file, to subscribe to social-media sources which have
+// no feeds of their own.
+//
+// A bridge entry looks like this:
+//
+//	bridge:twitter:someuser
+//	bridge:instagram:someuser
+//	bridge:telegram:somechannel
+//	bridge:SomeBridge:param1=value&param2=value
+//
+// These are expanded to URLs upon an RSS-Bridge instance, which must be
+// configured via the RSS_BRIDGE_URL environmental variable.
+//
+// If the NITTER_URL environmental variable is set then twitter entries
+// will be expanded to a URL upon that Nitter instance instead.
+package bridge
+
+import (
+	"fmt"
+	"net/url"
+	"os"
+	"strings"
+)
+
+// Prefix is the prefix used for bridged entries.
+const Prefix = "bridge:"
+
+// known holds the details of the bridges we have shortcuts for.
+//
+// The key is the name used in the configuration file, and the value is
+// the RSS-Bridge name, context, and the parameter which holds the user.
+var known = map[string][3]string{
+	"twitter":   {"TwitterBridge", "By username", "u"},
+	"instagram": {"InstagramBridge", "Username", "u"},
+	"telegram":  {"TelegramBridge", "", "username"},
+}
+
+// IsBridge returns true if the given feed URL uses the bridge scheme.
+func IsBridge(uri string) bool {
+	return strings.HasPrefix(uri, Prefix)
+}
+
+// Expand returns the URL which should be fetched for the given entry.
+//
+// Entries which do not use the bridge scheme are returned unchanged.
+func Expand(uri string) (string, error) {
+
+	if !IsBridge(uri) {
+		return uri, nil
+	}
+
+	fields := strings.SplitN(strings.TrimPrefix(uri, Prefix), ":", 2)
+	if len(fields) != 2 || fields[0] == "" || fields[1] == "" {
+		return "", fmt.Errorf("invalid bridge entry '%s', expected bridge:name:value", uri)
+	}
+	name := fields[0]
+	value := fields[1]
+
+	// Twitter might be handled by Nitter.
+	if strings.ToLower(name) == "twitter" {
+		if nitter := os.Getenv("NITTER_URL"); nitter != "" {
+			return strings.TrimSuffix(nitter, "/") + "/" + url.PathEscape(value) + "/rss", nil
+		}
+	}
+
+	base := os.Getenv("RSS_BRIDGE_URL")
+	if base == "" {
+		return "", fmt.Errorf("RSS_BRIDGE_URL is not set, unable to expand '%s'", uri)
+	}
+
+	params := url.Values{}
+	params.Set("action", "display")
+	params.Set("format", "Atom")
+
+	if details, ok := known[strings.ToLower(name)]; ok {
+		params.Set("bridge", details[0])
+		if details[1] != "" {
+			params.Set("context", details[1])
+		}
+		params.Set(details[2], value)
+	} else {
+
+		// Otherwise the value holds the parameters for the bridge.
+		extra, err := url.ParseQuery(value)
+		if err != nil {
+			return "", fmt.Errorf("invalid parameters in bridge entry '%s': %s", uri, err)
+		}
+		for k, v := range extra {
+			params[k] = v
+		}
+		params.Set("bridge", name)
+	}
+
+	return strings.TrimSuffix(base, "/") + "/?" + params.Encode(), nil
+}
@@ -0,0 +1,84 @@
+package bridge
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// TestNotBridge ensures that normal URLs are unchanged
+func TestNotBridge(t *testing.T) {
+
+	out, err := Expand("https://example.com/index.rss")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out != "https://example.com/index.rss" {
+		t.Fatalf("URL was changed: %s", out)
+	}
+}
+
+// TestBridge tests expanding entries via RSS-Bridge
+func TestBridge(t *testing.T) {
+
+	os.Unsetenv("NITTER_URL")
+	os.Setenv("RSS_BRIDGE_URL", "https://bridge.example.com/")
+	defer os.Unsetenv("RSS_BRIDGE_URL")
+
+	type TestCase struct {
+		input  string
+		output []string
+	}
+
+	tests := []TestCase{
+		{"bridge:twitter:steve", []string{"https://bridge.example.com/?", "bridge=TwitterBridge", "context=By+username", "u=steve", "format=Atom"}},
+		{"bridge:telegram:news", []string{"bridge=TelegramBridge", "username=news"}},
+		{"bridge:FacebookBridge:u=steve&media_type=all", []string{"bridge=FacebookBridge", "u=steve", "media_type=all"}},
+	}
+
+	for _, tst := range tests {
+		out, err := Expand(tst.input)
+		if err != nil {
+			t.Fatalf("unexpected error expanding %s: %s", tst.input, err)
+		}
+		for _, txt := range tst.output {
+			if !strings.Contains(out, txt) {
+				t.Fatalf("expanding %s gave %s, which doesn't contain %s", tst.input, out, txt)
+			}
+		}
+	}
+}
+
+// TestNitter tests expanding twitter entries via nitter
+func TestNitter(t *testing.T) {
+
+	os.Setenv("NITTER_URL", "https://nitter.example.com")
+	defer os.Unsetenv("NITTER_URL")
+
+	out, err := Expand("bridge:twitter:steve")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if out != "https://nitter.example.com/steve/rss" {
+		t.Fatalf("unexpected expansion: %s", out)
+	}
+}
+
+// TestBridgeErrors tests our error-handling
+func TestBridgeErrors(t *testing.T) {
+
+	os.Unsetenv("NITTER_URL")
+	os.Unsetenv("RSS_BRIDGE_URL")
+
+	_, err := Expand("bridge:twitter:steve")
+	if err == nil || !strings.Contains(err.Error(), "RSS_BRIDGE_URL") {
+		t.Fatalf("expected error with no instance, got %v", err)
+	}
+
+	for _, entry := range []string{"bridge:", "bridge:twitter", "bridge::steve"} {
+		_, err = Expand(entry)
+		if err == nil {
+			t.Fatalf("expected error expanding %s", entry)
+		}
+	}
+}
@@ -0,0 +1,346 @@
+// Package calendar finds the events which feed items describe, and
+// generates the iCalendar invites which are attached to their emails, so
+// that they may be added to a calendar with a single click.
+//
+// Events are found via the metadata of the RSS event module, or of xCal,
+// if present, and otherwise, if the feed allows, via the first date found
+// within the title, or content, of the item.
+package calendar
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+)
+
+// maxDescription is the largest number of characters of the content of
+// an item we include within the description of its event.
+const maxDescription = 2000
+
+// Event is a single event.
+type Event struct {
+
+	// UID uniquely identifies the event, so that updated invites
+	// replace those sent before.
+	UID string
+
+	// Start and End hold the time of the event.  If AllDay is set
+	// only their dates are significant.
+	Start  time.Time
+	End    time.Time
+	AllDay bool
+
+	// Summary, Location, URL, and Description describe the event.
+	Summary     string
+	Location    string
+	URL         string
+	Description string
+}
+
+// Find returns the event the given item describes, or nil if it doesn't
+// describe one.
+//
+// The metadata of the item is always used, and if patterns is true the
+// title, and then content, of the item are searched for a date too.
+// Dates without a time zone are in the given location.
+func Find(item *gofeed.Item, patterns bool, loc *time.Location) *Event {
+
+	text := itemText(item)
+
+	ev := &Event{
+		Summary:     strings.TrimSpace(item.Title),
+		URL:         item.Link,
+		Description: text,
+	}
+	if r := []rune(text); len(r) > maxDescription {
+		ev.Description = string(r[:maxDescription]) + "…"
+	}
+
+	id := item.GUID
+	if id == "" {
+		id = item.Link
+	}
+	ev.UID = fmt.Sprintf("%x@rss2email", sha1.Sum([]byte(id)))
+
+	start, end, location := metadata(item)
+	switch {
+	case !start.IsZero():
+		ev.Start = start.In(loc)
+		ev.End = end
+		ev.Location = location
+	case patterns:
+		var ok bool
+		ev.Start, ev.AllDay, ok = findDate(item.Title, loc)
+		if !ok {
+			ev.Start, ev.AllDay, ok = findDate(text, loc)
+		}
+		if !ok {
+			return nil
+		}
+		ev.Location = findLocation(text)
+	default:
+		return nil
+	}
+
+	if ev.End.IsZero() || !ev.End.After(ev.Start) {
+		if ev.AllDay {
+			ev.End = ev.Start.AddDate(0, 0, 1)
+		} else {
+			ev.End = ev.Start.Add(time.Hour)
+		}
+	}
+	return ev
+}
+
+// itemText returns the content of the given item, as text.
+func itemText(item *gofeed.Item) string {
+
+	content := item.Content
+	if content == "" {
+		content = item.Description
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	// Keep the breaks between blocks, as locations are found upon
+	// their own line.
+	doc.Find("br, p, div, li, h1, h2, h3, h4, tr").Each(func(i int, s *goquery.Selection) {
+		s.AppendHtml("\n")
+	})
+	return strings.TrimSpace(doc.Text())
+}
+
+// metadata returns the start, end, and location of the event described by
+// the metadata of the given item, if any.
+func metadata(item *gofeed.Item) (time.Time, time.Time, string) {
+
+	value := func(prefix string, name string) string {
+		for _, ext := range item.Extensions[prefix][name] {
+			if v := strings.TrimSpace(ext.Value); v != "" {
+				return v
+			}
+		}
+		return ""
+	}
+
+	// The RSS event module.
+	start := parseTime(value("ev", "startdate"))
+	if !start.IsZero() {
+		return start, parseTime(value("ev", "enddate")), value("ev", "location")
+	}
+
+	// xCal.
+	start = parseTime(value("xCal", "dtstart"))
+	if !start.IsZero() {
+		return start, parseTime(value("xCal", "dtend")), value("xCal", "location")
+	}
+
+	return time.Time{}, time.Time{}, ""
+}
+
+// parseTime parses the time of an event given within feed metadata.
+func parseTime(val string) time.Time {
+
+	for _, layout := range []string{time.RFC3339, "2006-01-02T15:04Z07:00", "20060102T150405Z", time.RFC1123Z, time.RFC1123} {
+		t, err := time.Parse(layout, val)
+		if err == nil {
+			return t
+		}
+	}
+	return time.Time{}
+}
+
+// months holds the names of the months, which may be abbreviated to their
+// first three letters.
+var months = `(jan(?:uary)?|feb(?:ruary)?|mar(?:ch)?|apr(?:il)?|may|june?|july?|aug(?:ust)?|sep(?:t(?:ember)?)?|oct(?:ober)?|nov(?:ember)?|dec(?:ember)?)`
+
+// clock matches an optional time following a date, such as "at 6pm", or
+// ", 18:30".
+var clock = `(?:,?\s+(?:at\s+|from\s+|@\s*)?([0-9]{1,2})(?:[:.]([0-9]{2}))?\s*(am|pm)?)?`
+
+// The patterns of the dates we recognise.
+var (
+	isoDate   = regexp.MustCompile(`\b([0-9]{4})-([0-9]{2})-([0-9]{2})(?:[T ]([0-9]{1,2}):([0-9]{2}))?\b`)
+	dayMonth  = regexp.MustCompile(`(?i)\b([0-9]{1,2})(?:st|nd|rd|th)?\s+` + months + `\.?,?\s+([0-9]{4})\b` + clock)
+	monthDay  = regexp.MustCompile(`(?i)\b` + months + `\.?\s+([0-9]{1,2})(?:st|nd|rd|th)?,?\s+([0-9]{4})\b` + clock)
+	location  = regexp.MustCompile(`(?im)^\s*(?:location|venue|where|place)\s*:\s*(.+?)\s*$`)
+	monthName = []string{"jan", "feb", "mar", "apr", "may", "jun", "jul", "aug", "sep", "oct", "nov", "dec"}
+)
+
+// findDate returns the first date found within the given text, and whether
+// it is lacking a time.
+func findDate(text string, loc *time.Location) (time.Time, bool, bool) {
+
+	type match struct {
+		index                          int
+		year, month, day, hour, minute string
+		ampm                           string
+	}
+
+	var found []match
+	if m := isoDate.FindStringSubmatchIndex(text); m != nil {
+		g := groups(text, m)
+		found = append(found, match{index: m[0], year: g[1], month: g[2], day: g[3], hour: g[4], minute: g[5]})
+	}
+	if m := dayMonth.FindStringSubmatchIndex(text); m != nil {
+		g := groups(text, m)
+		found = append(found, match{index: m[0], day: g[1], month: g[2], year: g[3], hour: g[4], minute: g[5], ampm: g[6]})
+	}
+	if m := monthDay.FindStringSubmatchIndex(text); m != nil {
+		g := groups(text, m)
+		found = append(found, match{index: m[0], month: g[1], day: g[2], year: g[3], hour: g[4], minute: g[5], ampm: g[6]})
+	}
+
+	// Use the first date within the text.
+	var best *match
+	for i := range found {
+		if best == nil || found[i].index < best.index {
+			best = &found[i]
+		}
+	}
+	if best == nil {
+		return time.Time{}, false, false
+	}
+
+	year, _ := strconv.Atoi(best.year)
+	day, _ := strconv.Atoi(best.day)
+	month, err := strconv.Atoi(best.month)
+	if err != nil {
+		for i, name := range monthName {
+			if strings.HasPrefix(strings.ToLower(best.month), name) {
+				month = i + 1
+			}
+		}
+	}
+
+	hour, herr := strconv.Atoi(best.hour)
+	minute, _ := strconv.Atoi(best.minute)
+	switch strings.ToLower(best.ampm) {
+	case "pm":
+		if hour < 12 {
+			hour += 12
+		}
+	case "am":
+		if hour == 12 {
+			hour = 0
+		}
+	}
+
+	// A bare number following the date is only a time if it has
+	// minutes, or am/pm, otherwise it is probably something else.
+	allDay := herr != nil || (best.minute == "" && best.ampm == "")
+	if allDay {
+		hour, minute = 0, 0
+	}
+
+	if month < 1 || month > 12 || day < 1 || day > 31 || hour > 23 || minute > 59 {
+		return time.Time{}, false, false
+	}
+
+	t := time.Date(year, time.Month(month), day, hour, minute, 0, 0, loc)
+	if t.Day() != day {
+		return time.Time{}, false, false
+	}
+	return t, allDay, true
+}
+
+// groups returns the submatches of a regular expression, given their
+// indexes.
+func groups(text string, m []int) []string {
+
+	res := make([]string, len(m)/2)
+	for i := range res {
+		if m[2*i] >= 0 {
+			res[i] = text[m[2*i]:m[2*i+1]]
+		}
+	}
+	return res
+}
+
+// findLocation returns the location given upon a line of the given text,
+// such as "Venue: The Town Hall", if any.
+func findLocation(text string) string {
+
+	m := location.FindStringSubmatch(text)
+	if m == nil {
+		return ""
+	}
+	return m[1]
+}
+
+// ICS returns the event as an iCalendar file.
+func (e *Event) ICS(now time.Time) []byte {
+
+	lines := []string{
+		"BEGIN:VCALENDAR",
+		"VERSION:2.0",
+		"PRODID:-//rss2email//rss2email//EN",
+		"METHOD:PUBLISH",
+		"BEGIN:VEVENT",
+		"UID:" + e.UID,
+		"DTSTAMP:" + now.UTC().Format("20060102T150405Z"),
+	}
+
+	if e.AllDay {
+		lines = append(lines,
+			"DTSTART;VALUE=DATE:"+e.Start.Format("20060102"),
+			"DTEND;VALUE=DATE:"+e.End.Format("20060102"))
+	} else {
+		lines = append(lines,
+			"DTSTART:"+e.Start.UTC().Format("20060102T150405Z"),
+			"DTEND:"+e.End.UTC().Format("20060102T150405Z"))
+	}
+
+	lines = append(lines, "SUMMARY:"+escape(e.Summary))
+	if e.Location != "" {
+		lines = append(lines, "LOCATION:"+escape(e.Location))
+	}
+	if e.URL != "" {
+		lines = append(lines, "URL:"+e.URL)
+	}
+	if e.Description != "" {
+		lines = append(lines, "DESCRIPTION:"+escape(e.Description))
+	}
+	lines = append(lines, "END:VEVENT", "END:VCALENDAR")
+
+	var sb strings.Builder
+	for _, line := range lines {
+		sb.WriteString(fold(line))
+	}
+	return []byte(sb.String())
+}
+
+// escape escapes the given text for use within an iCalendar file.
+func escape(text string) string {
+
+	r := strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\r\n", `\n`, "\n", `\n`, "\r", `\n`)
+	return r.Replace(text)
+}
+
+// fold splits the given line into lines of no more than 75 octets, as
+// iCalendar requires, without splitting any character.
+func fold(line string) string {
+
+	var sb strings.Builder
+	width := 0
+	for _, r := range line {
+		n := len(string(r))
+		if width+n > 75 {
+			sb.WriteString("\r\n ")
+			width = 1
+		}
+		sb.WriteRune(r)
+		width += n
+	}
+	sb.WriteString("\r\n")
+	return sb.String()
+}
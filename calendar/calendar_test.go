@@ -0,0 +1,135 @@
+package calendar
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+)
+
+// TestFindDate ensures we find dates within text.
+func TestFindDate(t *testing.T) {
+
+	loc := time.FixedZone("test", 3600)
+
+	type testcase struct {
+		text   string
+		date   string
+		allDay bool
+	}
+
+	for _, tc := range []testcase{
+		{"Meetup on 2024-05-01 18:30 at the pub", "2024-05-01 18:30", false},
+		{"Meetup on 2024-05-01", "2024-05-01 00:00", true},
+		{"Join us on 3rd March 2024 at 7pm", "2024-03-03 19:00", false},
+		{"Join us on 3 Mar. 2024, 10.15am", "2024-03-03 10:15", false},
+		{"Talk: September 12, 2024 @ 12pm", "2024-09-12 12:00", false},
+		{"Talk: Sept 12th 2024 from 12:00", "2024-09-12 12:00", false},
+		{"Closed on December 25 2024 10 people", "2024-12-25 00:00", true},
+		{"From 1 June 2024 until 2024-07-01", "2024-06-01 00:00", true},
+	} {
+		got, allDay, ok := findDate(tc.text, loc)
+		if !ok {
+			t.Errorf("no date found in %q", tc.text)
+			continue
+		}
+		if got.Format("2006-01-02 15:04") != tc.date || allDay != tc.allDay || got.Location() != loc {
+			t.Errorf("unexpected date for %q: %s %v", tc.text, got, allDay)
+		}
+	}
+
+	for _, text := range []string{"nothing here", "On 31 February 2024", "2024-13-01"} {
+		if d, _, ok := findDate(text, loc); ok {
+			t.Errorf("unexpected date in %q: %s", text, d)
+		}
+	}
+}
+
+// TestFind ensures we find events via patterns, and metadata.
+func TestFind(t *testing.T) {
+
+	item := &gofeed.Item{
+		Title:   "Go meetup, 14 March 2024 at 6:30pm",
+		Link:    "https://example.com/events/1",
+		Content: "<p>Talks and pizza.</p><p>Venue: The Town Hall, Helsinki</p>",
+	}
+
+	if Find(item, false, time.UTC) != nil {
+		t.Fatalf("events should only be found via patterns if allowed")
+	}
+
+	ev := Find(item, true, time.UTC)
+	if ev == nil {
+		t.Fatalf("expected an event")
+	}
+	if ev.Start != time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC) || ev.End != ev.Start.Add(time.Hour) || ev.AllDay {
+		t.Fatalf("unexpected time: %s - %s", ev.Start, ev.End)
+	}
+	if ev.Location != "The Town Hall, Helsinki" {
+		t.Fatalf("unexpected location: %q", ev.Location)
+	}
+
+	// The metadata of the event module is used in preference.
+	item.Extensions = ext.Extensions{"ev": {
+		"startdate": {{Name: "startdate", Value: "2024-04-01T10:00:00+02:00"}},
+		"enddate":   {{Name: "enddate", Value: "2024-04-01T16:00:00+02:00"}},
+		"location":  {{Name: "location", Value: "Online"}},
+	}}
+	ev = Find(item, false, time.UTC)
+	if ev == nil {
+		t.Fatalf("expected an event")
+	}
+	if !ev.Start.Equal(time.Date(2024, 4, 1, 8, 0, 0, 0, time.UTC)) || !ev.End.Equal(time.Date(2024, 4, 1, 14, 0, 0, 0, time.UTC)) {
+		t.Fatalf("unexpected time: %s - %s", ev.Start, ev.End)
+	}
+	if ev.Location != "Online" {
+		t.Fatalf("unexpected location: %q", ev.Location)
+	}
+}
+
+// TestICS ensures invites are generated correctly.
+func TestICS(t *testing.T) {
+
+	ev := &Event{
+		UID:         "abc@rss2email",
+		Start:       time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC),
+		End:         time.Date(2024, 3, 14, 19, 30, 0, 0, time.UTC),
+		Summary:     "Go meetup; pizza, talks",
+		Location:    "Town Hall",
+		URL:         "https://example.com/events/1",
+		Description: strings.Repeat("long ", 30) + "\nline",
+	}
+
+	ics := string(ev.ICS(time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)))
+	for _, expected := range []string{
+		"BEGIN:VCALENDAR\r\n",
+		"UID:abc@rss2email\r\n",
+		"DTSTAMP:20240301T000000Z\r\n",
+		"DTSTART:20240314T183000Z\r\n",
+		"DTEND:20240314T193000Z\r\n",
+		"SUMMARY:Go meetup\\; pizza\\, talks\r\n",
+		"LOCATION:Town Hall\r\n",
+		"END:VCALENDAR\r\n",
+	} {
+		if !strings.Contains(ics, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, ics)
+		}
+	}
+	for _, line := range strings.Split(ics, "\r\n") {
+		if len(line) > 75 {
+			t.Fatalf("line too long: %q", line)
+		}
+	}
+	if !strings.Contains(strings.ReplaceAll(ics, "\r\n ", ""), `long \nline`) {
+		t.Fatalf("description wasn't folded correctly:\n%s", ics)
+	}
+
+	ev.AllDay = true
+	ev.End = ev.Start.AddDate(0, 0, 1)
+	ics = string(ev.ICS(time.Now()))
+	if !strings.Contains(ics, "DTSTART;VALUE=DATE:20240314\r\n") || !strings.Contains(ics, "DTEND;VALUE=DATE:20240315\r\n") {
+		t.Fatalf("unexpected all-day event:\n%s", ics)
+	}
+}
@@ -0,0 +1,7 @@
+//go:build !cgo
+// +build !cgo
+
+package main
+
+// cgoEnabled is true if we were built with cgo, which SQLite requires.
+const cgoEnabled = false
@@ -0,0 +1,39 @@
+//
+// Colours for interactive output.
+//
+
+package main
+
+import (
+	"os"
+)
+
+// The ANSI escapes of the colours we use.
+const (
+	colourRed    = "\033[31m"
+	colourGreen  = "\033[32m"
+	colourYellow = "\033[33m"
+	colourReset  = "\033[0m"
+)
+
+// noColour is set by the global "-no-color" flag, to disable colour even
+// upon a terminal.
+var noColour bool
+
+// useColour returns true if we should colour what we write to the given
+// file, which is the case only if it is a terminal, unless colour has been
+// disabled via "-no-color", or the NO_COLOR environmental variable, or we're
+// in container mode.
+//
+// See https://no-color.org/ for the details of NO_COLOR.
+func useColour(f *os.File) bool {
+	return !noColour && os.Getenv("NO_COLOR") == "" && !containerMode && isTerminal(f)
+}
+
+// paint returns the given text in the given colour, if colour is wanted.
+func paint(colour bool, code string, text string) string {
+	if !colour {
+		return text
+	}
+	return code + text + colourReset
+}
@@ -0,0 +1,321 @@
+//
+// Generate shell completion scripts.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/subcommands"
+)
+
+// feedCommands holds the names of the sub-commands whose arguments are
+// feeds, which are completed from the configuration file.
+var feedCommands = []string{"delete"}
+
+// Structure for our options and state.
+type completionCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Should we list our feeds, for use by the completion scripts?
+	feeds bool
+}
+
+// completionFlag describes a flag of a sub-command.
+type completionFlag struct {
+
+	// The name of the flag, without any leading dash.
+	name string
+
+	// The description of the flag.
+	usage string
+
+	// Does the flag take a value?
+	value bool
+}
+
+// completionCommand describes a sub-command.
+type completionCommand struct {
+
+	// The name of the command.
+	name string
+
+	// The first line of the description of the command.
+	synopsis string
+
+	// The flags the command accepts.
+	flags []completionFlag
+
+	// Are the arguments of the command feeds?
+	feeds bool
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (c *completionCmd) Arguments(flags *flag.FlagSet) {
+	c.config = configfile.New()
+
+	flags.BoolVar(&c.feeds, "feeds", false, "List the URLs and names of our feeds, as used by the completion scripts.")
+}
+
+// Info is part of the subcommand-API
+func (c *completionCmd) Info() (string, string) {
+	return "completion", `Generate a shell completion script.
+
+This command outputs a script which provides TAB-completion of the
+sub-commands, and their flags, for bash, zsh, or fish.  The arguments of
+the 'delete' sub-command are completed from the feeds which are present
+in the configuration file, by URL or name.
+
+To enable completion add one of the following to your shell's startup
+file:
+
+    source <(rss2email completion bash)        # ~/.bashrc
+    source <(rss2email completion zsh)         # ~/.zshrc
+    rss2email completion fish | source         # ~/.config/fish/config.fish
+
+Example:
+
+    $ rss2email completion bash
+`
+}
+
+// describe returns the details of each of our sub-commands, sorted by name.
+func describe() []completionCommand {
+
+	var res []completionCommand
+
+	// Our own commands, and those built into the subcommands library.
+	all := append(commands(), &subcommands.Help{}, &subcommands.CommandList{}, &subcommands.BashCompletion{})
+
+	for _, cmd := range all {
+
+		name, doc := cmd.Info()
+
+		entry := completionCommand{
+			name:     name,
+			synopsis: strings.Split(doc, "\n")[0],
+		}
+		for _, feed := range feedCommands {
+			if feed == name {
+				entry.feeds = true
+			}
+		}
+
+		fs := flag.NewFlagSet(name, flag.ContinueOnError)
+		cmd.Arguments(fs)
+		fs.VisitAll(func(f *flag.Flag) {
+			value := true
+			if b, ok := f.Value.(interface{ IsBoolFlag() bool }); ok && b.IsBoolFlag() {
+				value = false
+			}
+			entry.flags = append(entry.flags, completionFlag{name: f.Name, usage: f.Usage, value: value})
+		})
+
+		res = append(res, entry)
+	}
+
+	sort.Slice(res, func(i, j int) bool {
+		return res[i].name < res[j].name
+	})
+	return res
+}
+
+// bashCompletion returns a completion script for bash.
+func bashCompletion(cmds []completionCommand) string {
+
+	var names []string
+	var flags, feeds strings.Builder
+	for _, cmd := range cmds {
+		names = append(names, cmd.name)
+		if len(cmd.flags) > 0 {
+			var list []string
+			for _, f := range cmd.flags {
+				list = append(list, "-"+f.name)
+			}
+			fmt.Fprintf(&flags, "            %s) COMPREPLY=($(compgen -W \"%s\" -- \"$cur\")) ;;\n", cmd.name, strings.Join(list, " "))
+		}
+		if cmd.feeds {
+			fmt.Fprintf(&feeds, "        %s)\n            local IFS=$'\\n'\n            COMPREPLY=($(compgen -W \"$(rss2email completion -feeds 2>/dev/null)\" -- \"$cur\"))\n            ;;\n", cmd.name)
+		}
+	}
+
+	return `# bash completion for rss2email
+
+_rss2email()
+{
+    local cur="${COMP_WORDS[COMP_CWORD]}"
+    local cmd="${COMP_WORDS[1]}"
+    COMPREPLY=()
+
+    # The first argument is one of the available sub-commands.
+    local commands="` + strings.Join(names, " ") + `"
+    if [ "$COMP_CWORD" -eq 1 ]; then
+        COMPREPLY=($(compgen -W "${commands}" -- "$cur"))
+        return
+    fi
+
+    # Complete the flags of the sub-command.
+    if [[ "$cur" == -* ]]; then
+        case "$cmd" in
+` + flags.String() + `        esac
+        return
+    fi
+
+    case "$cmd" in
+` + feeds.String() + `        help)
+            COMPREPLY=($(compgen -W "${commands}" -- "$cur"))
+            ;;
+        *)
+            COMPREPLY=($(compgen -f -- "$cur"))
+            ;;
+    esac
+}
+
+complete -F _rss2email rss2email
+`
+}
+
+// zshQuote escapes the given description for use within a zsh spec.
+func zshQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `'\''`, `[`, `\[`, `]`, `\]`, `:`, `\:`)
+	return r.Replace(s)
+}
+
+// zshCompletion returns a completion script for zsh.
+func zshCompletion(cmds []completionCommand) string {
+
+	var list, args strings.Builder
+	for _, cmd := range cmds {
+		fmt.Fprintf(&list, "        '%s:%s'\n", cmd.name, zshQuote(cmd.synopsis))
+
+		var specs []string
+		for _, f := range cmd.flags {
+			spec := fmt.Sprintf("'-%s[%s]", f.name, zshQuote(f.usage))
+			if f.value {
+				spec += ":" + f.name + ":"
+			}
+			specs = append(specs, spec+"'")
+		}
+		switch {
+		case cmd.feeds:
+			specs = append(specs, `'*:feed:{local -a feeds; feeds=(${(f)"$(rss2email completion -feeds 2>/dev/null)"}); compadd -a feeds}'`)
+		case cmd.name == "help":
+			specs = append(specs, `'1:command:_rss2email_commands'`)
+		default:
+			specs = append(specs, `'*:file:_files'`)
+		}
+		fmt.Fprintf(&args, "        %s)\n            _arguments \\\n                %s\n            ;;\n", cmd.name, strings.Join(specs, " \\\n                "))
+	}
+
+	return `#compdef rss2email
+
+_rss2email_commands() {
+    local -a commands
+    commands=(
+` + list.String() + `    )
+    _describe 'command' commands
+}
+
+_rss2email() {
+    if (( CURRENT == 2 )); then
+        _rss2email_commands
+        return
+    fi
+
+    # Complete the arguments of the sub-command.
+    shift words
+    (( CURRENT-- ))
+    case "${words[1]}" in
+` + args.String() + `    esac
+}
+
+compdef _rss2email rss2email
+`
+}
+
+// fishQuote escapes the given description for use within fish quotes.
+func fishQuote(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `'`, `\'`)
+	return r.Replace(s)
+}
+
+// fishCompletion returns a completion script for fish.
+func fishCompletion(cmds []completionCommand) string {
+
+	var names []string
+	for _, cmd := range cmds {
+		names = append(names, cmd.name)
+	}
+
+	var b strings.Builder
+	b.WriteString("# fish completion for rss2email\n\n")
+	b.WriteString("complete -c rss2email -n '__fish_use_subcommand' -f\n")
+	for _, cmd := range cmds {
+		fmt.Fprintf(&b, "complete -c rss2email -n '__fish_use_subcommand' -a %s -d '%s'\n", cmd.name, fishQuote(cmd.synopsis))
+	}
+
+	for _, cmd := range cmds {
+		cond := "__fish_seen_subcommand_from " + cmd.name
+		for _, f := range cmd.flags {
+			extra := ""
+			if f.value {
+				extra = " -r"
+			}
+			fmt.Fprintf(&b, "complete -c rss2email -n '%s' -o %s%s -d '%s'\n", cond, f.name, extra, fishQuote(f.usage))
+		}
+		if cmd.feeds {
+			fmt.Fprintf(&b, "complete -c rss2email -n '%s' -f -a '(rss2email completion -feeds 2>/dev/null)'\n", cond)
+		}
+		if cmd.name == "help" {
+			fmt.Fprintf(&b, "complete -c rss2email -n '%s' -f -a '%s'\n", cond, strings.Join(names, " "))
+		}
+	}
+	return b.String()
+}
+
+// Execute is invoked if the user specifies `completion` as the subcommand.
+func (c *completionCmd) Execute(args []string) int {
+
+	// List our feeds, for the scripts to complete.
+	if c.feeds {
+		entries, err := c.config.Parse()
+		if err != nil {
+			return 1
+		}
+		for _, entry := range entries {
+			fmt.Fprintf(out, "%s\n", entry.URL)
+			if name := entry.Name(); name != "" {
+				fmt.Fprintf(out, "%s\n", name)
+			}
+		}
+		return 0
+	}
+
+	if len(args) != 1 {
+		fmt.Printf("Usage: rss2email completion bash|zsh|fish\n")
+		return 1
+	}
+
+	switch args[0] {
+	case "bash":
+		fmt.Fprint(out, bashCompletion(describe()))
+	case "zsh":
+		fmt.Fprint(out, zshCompletion(describe()))
+	case "fish":
+		fmt.Fprint(out, fishCompletion(describe()))
+	default:
+		fmt.Printf("Unknown shell '%s', valid choices are 'bash', 'zsh', or 'fish'\n", args[0])
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestCompletion(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	expected := map[string][]string{
+		"bash": {
+			`local commands="add bash-completion bench commands completion config cron`,
+			`add) COMPREPLY=($(compgen -W "-force -offline" -- "$cur")) ;;`,
+			`$(rss2email completion -feeds 2>/dev/null)`,
+			`complete -F _rss2email rss2email`,
+		},
+		"zsh": {
+			`#compdef rss2email`,
+			`'add:Add a new feed to our feed-list.'`,
+			`'-timeout[The time to allow when connecting to each host.]:timeout:'`,
+			`'-offline[Don'\''t check that the host of each feed is reachable.]'`,
+			`$(rss2email completion -feeds 2>/dev/null)`,
+		},
+		"fish": {
+			`complete -c rss2email -n '__fish_use_subcommand' -a add -d 'Add a new feed to our feed-list.'`,
+			`complete -c rss2email -n '__fish_seen_subcommand_from lint' -o timeout -r -d`,
+			`-o offline -d 'Don\'t check`,
+			`complete -c rss2email -n '__fish_seen_subcommand_from delete' -f -a '(rss2email completion -feeds 2>/dev/null)'`,
+		},
+	}
+
+	for shell, strs := range expected {
+		out = new(bytes.Buffer)
+
+		c := completionCmd{}
+		if c.Execute([]string{shell}) != 0 {
+			t.Fatalf("failed to generate %s completion", shell)
+		}
+
+		output := out.(*bytes.Buffer).String()
+		for _, str := range strs {
+			if !strings.Contains(output, str) {
+				t.Errorf("%s completion is missing %q", shell, str)
+			}
+		}
+	}
+
+	// Bogus shells are errors.
+	c := completionCmd{}
+	if c.Execute([]string{"csh"}) != 1 || c.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with bogus arguments")
+	}
+}
+
+func TestCompletionFeeds(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	tmpfile, err := os.CreateTemp("", "example")
+	if err != nil {
+		t.Fatalf("Error creating temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Write([]byte("https://example.com/\nname=\"Ars Technica\" https://example.org/\n"))
+	tmpfile.Close()
+
+	c := completionCmd{config: configfile.NewWithPath(tmpfile.Name()), feeds: true}
+	if c.Execute([]string{}) != 0 {
+		t.Fatalf("failed to list feeds")
+	}
+
+	output := out.(*bytes.Buffer).String()
+	if output != "https://example.com/\nhttps://example.org/\nArs Technica\n" {
+		t.Fatalf("unexpected output: %q", output)
+	}
+}
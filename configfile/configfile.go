@@ -13,16 +13,27 @@
 // It is assumed lines contain URLs, but anything prefixed with a "-"
 // is taken to be a parameter using a colon-deliminator.
 //
+// A feed may be given a human-readable name, either via the "name" option
+// or by prefixing its URL:
+//
+//       name="Ars Technica" https://feeds.arstechnica.com/arstechnica/index
+//
+// Values may be quoted, and long lines continued with a backslash, as
+// described by the parser.
+//
 package configfile
 
 import (
-	"bufio"
+	"bytes"
 	"fmt"
+	"io"
 	"os"
 	"os/user"
 	"path/filepath"
-	"regexp"
+	"runtime"
 	"strings"
+
+	"github.com/skx/rss2email/secret"
 )
 
 // Option contain options which are used on a per-feed basis.
@@ -36,6 +47,10 @@ type Option struct {
 
 	// Value contains the specified value of the configuration option.
 	Value string
+
+	// Line contains the line of the configuration file upon which
+	// the option was found, if it was read from one.
+	Line int
 }
 
 // Feed is an entry which is read from our configuration-file.
@@ -49,6 +64,61 @@ type Feed struct {
 	// Options contains a collection of any optional parameters
 	// which have been read after an URL
 	Options []Option
+
+	// Line contains the line of the configuration file upon which
+	// the URL was found, if it was read from one.
+	Line int
+}
+
+// These override the locations we use, and are set via the global
+// "-config-dir", "-state-dir", and "-profile" flags.
+var (
+	// configDir overrides the directory holding our configuration.
+	configDir string
+
+	// stateDir overrides the directory holding our state.
+	stateDir string
+
+	// profile holds the name of the profile in use, if any.
+	profile string
+)
+
+// goos and userConfigDir determine the default location of our
+// configuration, and may be changed for testing.
+var (
+	goos          = runtime.GOOS
+	userConfigDir = os.UserConfigDir
+)
+
+// SetDirectory overrides the directory beneath which our configuration
+// file and templates are stored.  An empty string restores the default.
+func SetDirectory(dir string) {
+	configDir = dir
+}
+
+// SetStateDirectory overrides the directory beneath which our state is
+// stored.  An empty string restores the default, which is to store the
+// state alongside our configuration.
+func SetStateDirectory(dir string) {
+	stateDir = dir
+}
+
+// SetProfile selects the named profile, which has its own feeds,
+// templates and state, stored beneath "profiles/<name>" within the
+// usual directories.  An empty name selects the default profile.
+func SetProfile(name string) error {
+
+	if name != "" && (name == "." || name == ".." || strings.ContainsAny(name, `/\`)) {
+		return fmt.Errorf("invalid profile name '%s'", name)
+	}
+	profile = name
+	return nil
+}
+
+// Profile returns the name of the selected profile, which is empty for
+// the default profile.
+func Profile() string {
+	return profile
 }
 
 // ConfigFile contains our state.
@@ -60,21 +130,26 @@ type ConfigFile struct {
 	// The entries we found.
 	entries []Feed
 
-	// Key:value regular expression
-	re *regexp.Regexp
+	// The warnings we found whilst parsing.
+	warnings []*ParseError
+
+	// The text we parsed, which is preserved when saving.
+	source source
+
+	// Was our list of feeds fetched from a remote location?
+	fetched bool
 }
 
 // New creates a new configuration-file reader.
 func New() *ConfigFile {
-	return &ConfigFile{re: regexp.MustCompile(`^([^:]+):(.*)$`)}
+	return &ConfigFile{}
 }
 
 // NewWithPath creates a configuration-file reader, using the given file as
 // a source.
 func NewWithPath(file string) *ConfigFile {
 
-	// Create new object - to avoid having to repeat our regexp
-	// initialization.
+	// Create new object.
 	x := New()
 
 	// Setup the path, and return the updated object.
@@ -85,12 +160,13 @@ func NewWithPath(file string) *ConfigFile {
 // Home returns the home-directory for the current user
 func (c *ConfigFile) Home() string {
 
-	// Default to using $HOME for our storage
-	home := os.Getenv("HOME")
+	// Default to using the platform-specific home, which
+	// is $HOME on Unix systems, and %USERPROFILE% on Windows.
+	home, err := os.UserHomeDir()
 
 	// If that fails then get the current user, and use
 	// their home if possible.
-	if home == "" {
+	if err != nil || home == "" {
 		usr, err := user.Current()
 		if err == nil {
 			home = usr.HomeDir
@@ -100,12 +176,71 @@ func (c *ConfigFile) Home() string {
 	return home
 }
 
+// Directory returns the directory beneath which our configuration file,
+// templates, and state are stored.
+//
+// On Windows this is `%AppData%\rss2email`, everywhere else it is
+// `~/.rss2email`.  This may be overridden via SetDirectory, and if
+// a profile is selected its subdirectory is used.
+func (c *ConfigFile) Directory() string {
+
+	dir := configDir
+	if dir == "" {
+		dir = c.defaultDirectory()
+	}
+	if profile != "" {
+		dir = filepath.Join(dir, "profiles", profile)
+	}
+	return dir
+}
+
+// StateDirectory returns the directory beneath which our state, such as
+// the record of the items we've seen, is stored.
+//
+// By default this is the same as Directory, but it may be overridden via
+// SetStateDirectory.
+func (c *ConfigFile) StateDirectory() string {
+
+	if stateDir == "" {
+		return c.Directory()
+	}
+	if profile != "" {
+		return filepath.Join(stateDir, "profiles", profile)
+	}
+	return stateDir
+}
+
+// defaultDirectory returns the default location of our configuration.
+func (c *ConfigFile) defaultDirectory() string {
+
+	if goos == "windows" {
+		dir, err := userConfigDir()
+		if err == nil && dir != "" {
+			return filepath.Join(dir, "rss2email")
+		}
+	}
+
+	return filepath.Join(c.Home(), ".rss2email")
+}
+
 // Path returns the path to the configuration-file.
+//
+// If "feeds.txt" doesn't exist, but an encrypted copy of it does, such
+// as "feeds.txt.age" or "feeds.txt.gpg", then the encrypted copy is used.
 func (c *ConfigFile) Path() string {
 
 	// If we've not calculated the path then do so now.
 	if c.path == "" {
-		c.path = filepath.Join(c.Home(), ".rss2email", "feeds.txt")
+		c.path = filepath.Join(c.Directory(), "feeds.txt")
+
+		if _, err := os.Stat(c.path); os.IsNotExist(err) {
+			for _, suffix := range []string{".age", ".gpg", ".asc"} {
+				if _, err := os.Stat(c.path + suffix); err == nil {
+					c.path += suffix
+					break
+				}
+			}
+		}
 	}
 
 	return c.path
@@ -118,6 +253,11 @@ func (c *ConfigFile) Path() string {
 // a different format and name.
 func (c *ConfigFile) Exists() bool {
 
+	// A remote list of feeds is fetched when parsed.
+	if c.useRemote() {
+		return true
+	}
+
 	_, err := os.Stat(c.Path())
 
 	return !os.IsNotExist(err)
@@ -134,7 +274,7 @@ func (c *ConfigFile) Upgrade() {
 	// OK create a new helper, and use that to read the
 	// older entries
 	old := New()
-	old.path = filepath.Join(c.Home(), ".rss2email", "feeds")
+	old.path = filepath.Join(c.Directory(), "feeds")
 
 	// Does it exist?
 	if !old.Exists() {
@@ -176,84 +316,122 @@ func (c *ConfigFile) Upgrade() {
 
 }
 
+// useRemote returns true if we should read our feeds from the remote
+// list configured via SetRemote, rather than a local file.
+func (c *ConfigFile) useRemote() bool {
+	return remote != "" && (c.path == "" || c.fetched)
+}
+
 // Parse returns the entries from the config-file
 func (c *ConfigFile) Parse() ([]Feed, error) {
 
 	// Remove all existing entries
 	c.entries = []Feed{}
 
-	// Open the file
-	file, err := os.Open(c.Path())
-	if err != nil {
-		return c.entries, err
+	// Fetch the remote list of feeds, if we're using one.
+	if c.useRemote() {
+		path, err := c.fetchRemote()
+		if err != nil {
+			return c.entries, err
+		}
+		c.path = path
+		c.fetched = true
 	}
-	defer file.Close()
 
-	// Temporary entry
-	var tmp Feed
-	tmp.Options = []Option{}
+	// Open the file, decrypting it if required.
+	var input io.Reader
+	if secret.Encrypted(c.Path()) {
+		data, err := secret.Decrypt(c.Path())
+		if err != nil {
+			return c.entries, err
+		}
+		input = bytes.NewReader(data)
+	} else {
+		file, err := os.Open(c.Path())
+		if err != nil {
+			return c.entries, err
+		}
+		defer file.Close()
+		input = file
+	}
 
-	// Create a scanner to process the file.
-	scanner := bufio.NewScanner(file)
+	p, err := newParser(input)
+	c.entries = p.feeds
+	c.warnings = p.warnings
+	c.source = p.source
+	return c.entries, err
+}
 
-	// Scan line by line
-	for scanner.Scan() {
+// Warnings returns the problems found by Parse which don't prevent the
+// configuration file from being used, such as unknown options.
+func (c *ConfigFile) Warnings() []*ParseError {
+	return c.warnings
+}
 
-		// Get the line, and strip leading/trailing space
-		line := scanner.Text()
-		line = strings.TrimSpace(line)
+// Name returns the human-readable name of the feed, as set via the "name"
+// option, or the empty string if it has none.
+func (f Feed) Name() string {
 
-		// skip comments
-		if strings.HasPrefix(line, "#") {
-			continue
+	name := ""
+	for _, opt := range f.Options {
+		if opt.Name == "name" {
+			name = opt.Value
 		}
+	}
+	return name
+}
 
-		// optional params have "-" prefix
-		if strings.HasPrefix(line, "-") {
-
-			// options go AFTER the URL to which they refer
-			if tmp.URL == "" {
-				return c.entries, fmt.Errorf("error: option outside a URL: %s", scanner.Text())
-			}
+// Archived returns true if the feed has been archived, via the "archived"
+// option, in which case it is no longer fetched.
+func (f Feed) Archived() bool {
+	for _, opt := range f.Options {
+		if opt.Name == "archived" {
+			return true
+		}
+	}
+	return false
+}
 
-			// Remove the prefix and split by ":"
-			line = strings.TrimPrefix(line, "-")
+// Label returns the name of the feed, if it has one, or its URL.
+//
+// This is used to refer to the feed in our output.
+func (f Feed) Label() string {
 
-			// Look for "foo:bar"
-			fields := c.re.FindStringSubmatch(line)
+	if name := f.Name(); name != "" {
+		return name
+	}
+	return f.URL
+}
 
-			// If we got key/val then save them away
-			if len(fields) == 3 {
-				key := strings.TrimSpace(fields[1])
-				val := strings.TrimSpace(fields[2])
-				tmp.Options = append(tmp.Options, Option{Name: key, Value: val})
-			}
-		} else {
-
-			// If we already have a URL stored then append
-			// it and reset our temporary structure
-			if tmp.URL != "" {
-				// store it, and reset our map
-				c.entries = append(c.entries, tmp)
-				tmp.Options = []Option{}
-			}
+// Find returns the feeds which match the given query, which may be the
+// URL of a feed, or its name.
+//
+// Names are matched without regard to case, and if no feed has the given
+// name then any feeds whose names begin with it are returned, so "ars"
+// will find "Ars Technica".
+func Find(feeds []Feed, query string) []Feed {
+
+	var found []Feed
+	if query == "" {
+		return found
+	}
 
-			// set the url
-			tmp.URL = line
+	for _, feed := range feeds {
+		if feed.URL == query || strings.EqualFold(feed.Name(), query) {
+			found = append(found, feed)
 		}
 	}
-
-	// Ensure we don't forget about the last item in the file.
-	if tmp.URL != "" {
-		c.entries = append(c.entries, tmp)
+	if len(found) > 0 {
+		return found
 	}
 
-	// Look for scanner-errors
-	if err := scanner.Err(); err != nil {
-		return c.entries, err
+	for _, feed := range feeds {
+		name := strings.ToLower(feed.Name())
+		if strings.HasPrefix(name, strings.ToLower(query)) {
+			found = append(found, feed)
+		}
 	}
-
-	return c.entries, nil
+	return found
 }
 
 // Add appends the given URIs to the config-file
@@ -279,6 +457,41 @@ func (c *ConfigFile) Add(uris ...string) {
 	}
 }
 
+// AddFeed appends the given feed, along with any options, to the
+// config-file, unless the URL is already present.
+//
+// It returns true if the feed was added.
+//
+// You must call `Save` if you wish this addition to be persisted.
+func (c *ConfigFile) AddFeed(feed Feed) bool {
+
+	for _, ent := range c.entries {
+		if ent.URL == feed.URL {
+			return false
+		}
+	}
+
+	c.entries = append(c.entries, feed)
+	return true
+}
+
+// Update replaces the entry which has the same URL as the given feed,
+// keeping its position within the file.
+//
+// It returns false if there is no such entry.
+//
+// You must call `Save` if you wish this update to be persisted.
+func (c *ConfigFile) Update(feed Feed) bool {
+
+	for i, ent := range c.entries {
+		if ent.URL == feed.URL {
+			c.entries[i] = feed
+			return true
+		}
+	}
+	return false
+}
+
 // Delete removes an entry from our list of feeds.
 //
 // You must call `Save` if you wish this removal to be persisted.
@@ -298,23 +511,197 @@ func (c *ConfigFile) Delete(url string) {
 // Save persists our list of feeds/options to disk.
 func (c *ConfigFile) Save() error {
 
+	// A remote list of feeds must be edited at its source.
+	if c.useRemote() {
+		return fmt.Errorf("the list of feeds is read from %s, and cannot be modified here", remote)
+	}
+
+	// An encrypted file is re-encrypted, and replaced.
+	if secret.Encrypted(c.Path()) {
+		data, err := secret.Encrypt(c.Path(), c.Bytes())
+		if err != nil {
+			return err
+		}
+
+		tmp := c.Path() + ".tmp"
+		err = os.WriteFile(tmp, data, 0600)
+		if err != nil {
+			return err
+		}
+		return os.Rename(tmp, c.Path())
+	}
+
 	// Open the file
 	file, err := os.Create(c.Path())
 	if err != nil {
 		return err
 	}
 
-	// For each entry do the necessary
-	for _, entry := range c.entries {
+	c.write(file)
 
-		fmt.Fprintf(file, "%s\n", entry.URL)
+	err = file.Close()
+	return err
+}
+
+// Bytes returns the contents of the configuration file, as Save would
+// write it.
+func (c *ConfigFile) Bytes() []byte {
+	buf := &bytes.Buffer{}
+	c.write(buf)
+	return buf.Bytes()
+}
 
-		for _, opt := range entry.Options {
-			fmt.Fprintf(file, " - %s:%s\n", opt.Name, opt.Value)
+// ArchivedHeader is the comment which begins the section of the file
+// holding the archived feeds.
+const ArchivedHeader = "# Archived feeds, which are no longer fetched."
+
+// write writes our list of feeds/options to the given writer.
+//
+// The file we parsed is written back unchanged, except for the feeds which
+// have been changed, which are rewritten in place, and those which have
+// been removed.  Comments, blank lines, and the formatting of the other
+// feeds, are preserved, and new feeds are appended.
+//
+// Archived feeds are kept in a section at the end of the file, beginning
+// with ArchivedHeader, so feeds which are archived, or restored, are moved
+// into, or out of, it and new feeds are added before it.
+func (c *ConfigFile) write(w io.Writer) {
+
+	written := make([]bool, len(c.entries))
+
+	// moved holds the comments amongst the options of the feeds which
+	// are moving to, or from, the archived section.
+	moved := make(map[int][]string)
+
+	// find returns the index of the first entry with the given URL we've
+	// not yet written, or -1 if there is none.
+	find := func(url string) int {
+		for i, entry := range c.entries {
+			if _, ok := moved[i]; !ok && !written[i] && entry.URL == url {
+				return i
+			}
 		}
+		return -1
+	}
 
+	// appendFeeds writes the entries we've not yet written which are,
+	// or are not, archived.
+	appendFeeds := func(archived bool) {
+		for i, entry := range c.entries {
+			if written[i] || entry.Archived() != archived {
+				continue
+			}
+			written[i] = true
+			writeFeed(w, entry)
+			for _, line := range moved[i] {
+				fmt.Fprintf(w, "%s\n", line)
+			}
+		}
 	}
 
-	err = file.Close()
-	return err
+	src := c.source
+
+	// New feeds are added before the archived section, and any blank
+	// lines which precede it.
+	header := -1
+	for l, line := range src.lines {
+		if src.comment[l] && strings.TrimSpace(line) == ArchivedHeader {
+			header = l
+			break
+		}
+	}
+	insert := header
+	for insert > 0 && strings.TrimSpace(src.lines[insert-1]) == "" {
+		insert--
+	}
+
+	// copyTo writes the lines of the file as they were, up to the given
+	// line, adding new feeds where they belong.
+	next := 0
+	copyTo := func(end int) {
+		for ; next < end; next++ {
+			if next == insert {
+				appendFeeds(false)
+			}
+			fmt.Fprintf(w, "%s\n", src.lines[next])
+		}
+	}
+
+	for n, span := range src.spans {
+
+		// Anything preceding the feed is written as it was.
+		copyTo(span[0])
+		next = span[1] + 1
+
+		// Removed?
+		i := find(src.feeds[n].URL)
+		if i < 0 {
+			continue
+		}
+
+		// Any comments amongst the options of a changed feed follow it.
+		var comments []string
+		for l := span[0]; l <= span[1]; l++ {
+			if src.comment[l] {
+				comments = append(comments, src.lines[l])
+			}
+		}
+
+		// Archived, or restored?
+		if c.entries[i].Archived() != src.feeds[n].Archived() {
+			moved[i] = comments
+			continue
+		}
+		written[i] = true
+
+		if same(c.entries[i], src.feeds[n]) {
+			for _, line := range src.lines[span[0] : span[1]+1] {
+				fmt.Fprintf(w, "%s\n", line)
+			}
+			continue
+		}
+
+		writeFeed(w, c.entries[i])
+		for _, line := range comments {
+			fmt.Fprintf(w, "%s\n", line)
+		}
+	}
+	copyTo(len(src.lines))
+
+	// For each new entry do the necessary
+	appendFeeds(false)
+
+	if header < 0 {
+		for i, entry := range c.entries {
+			if !written[i] && entry.Archived() {
+				fmt.Fprintf(w, "\n%s\n", ArchivedHeader)
+				break
+			}
+		}
+	}
+	appendFeeds(true)
+}
+
+// writeFeed writes the given feed, and its options, to the given writer.
+func writeFeed(w io.Writer, entry Feed) {
+
+	fmt.Fprintf(w, "%s\n", entry.URL)
+
+	for _, opt := range entry.Options {
+		fmt.Fprintf(w, " - %s:%s\n", opt.Name, quote(opt.Value))
+	}
+}
+
+// same returns true if the given feeds have the same URL and options.
+func same(a Feed, b Feed) bool {
+
+	if a.URL != b.URL || len(a.Options) != len(b.Options) {
+		return false
+	}
+	for i := range a.Options {
+		if a.Options[i].Name != b.Options[i].Name || a.Options[i].Value != b.Options[i].Value {
+			return false
+		}
+	}
+	return true
 }
@@ -1,8 +1,10 @@
 package configfile
 
 import (
-	"io/ioutil"
+	"errors"
 	"os"
+	"path/filepath"
+	"runtime"
 	"strings"
 	"testing"
 )
@@ -11,7 +13,7 @@ import (
 func TestExists(t *testing.T) {
 
 	// Create a temporary file
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("error creating temporary file")
 	}
@@ -186,6 +188,54 @@ http://example.com/
 }
 
 // TestComplexOption tests parsing an option containing ":"
+// Test feeds may be named
+func TestNames(t *testing.T) {
+
+	c := ParserHelper(t, `
+name="Ars Technica" https://example.com/ars
+ - retry: 7
+name=lwn https://example.com/lwn
+https://example.com/other
+ - name: Other Feed
+https://example.com/anonymous`)
+	defer os.Remove(c.path)
+
+	out, err := c.Parse()
+	if err != nil {
+		t.Fatalf("Error parsing file: %v", err)
+	}
+	if len(out) != 4 {
+		t.Fatalf("parsed wrong number of entries, got %d\n%v", len(out), out)
+	}
+
+	expected := []struct{ url, label string }{
+		{"https://example.com/ars", "Ars Technica"},
+		{"https://example.com/lwn", "lwn"},
+		{"https://example.com/other", "Other Feed"},
+		{"https://example.com/anonymous", "https://example.com/anonymous"},
+	}
+	for i, e := range expected {
+		if out[i].URL != e.url || out[i].Label() != e.label {
+			t.Errorf("unexpected entry %d: %v", i, out[i])
+		}
+	}
+
+	// Find by URL, name, and name-prefix.
+	for query, url := range map[string]string{
+		"https://example.com/anonymous": "https://example.com/anonymous",
+		"LWN":                           "https://example.com/lwn",
+		"ars":                           "https://example.com/ars",
+	} {
+		found := Find(out, query)
+		if len(found) != 1 || found[0].URL != url {
+			t.Errorf("unexpected result finding %s: %v", query, found)
+		}
+	}
+	if len(Find(out, "missing")) != 0 || len(Find(out, "")) != 0 {
+		t.Errorf("found bogus feeds")
+	}
+}
+
 func TestComplexOption(t *testing.T) {
 
 	c := ParserHelper(t, `
@@ -271,6 +321,59 @@ func TestAdd(t *testing.T) {
 	os.Remove(c.path)
 }
 
+// TestSavePreserves ensures that saving the file preserves comments, and
+// the formatting of the feeds which haven't changed.
+func TestSavePreserves(t *testing.T) {
+
+	c := ParserHelper(t, `# My feeds
+
+# News
+name="Ars Technica" https://example.com/ars
+ - retry:  3   # not a comment
+
+https://example.com/old
+ - paused: true
+# Work
+https://example.com/work
+ # Keep this quiet
+ - digest: daily
+ - include: foo|\
+     bar
+`)
+	defer os.Remove(c.path)
+
+	_, err := c.Parse()
+	if err != nil {
+		t.Fatalf("Error parsing file: %v", err)
+	}
+
+	c.Delete("https://example.com/old")
+	c.Update(Feed{URL: "https://example.com/work", Options: []Option{{Name: "digest", Value: "weekly"}}})
+	c.Add("https://example.com/new")
+
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("Error saving file: %s", err)
+	}
+
+	data, _ := os.ReadFile(c.path)
+	expected := `# My feeds
+
+# News
+name="Ars Technica" https://example.com/ars
+ - retry:  3   # not a comment
+
+# Work
+https://example.com/work
+ - digest:weekly
+ # Keep this quiet
+https://example.com/new
+`
+	if string(data) != expected {
+		t.Fatalf("unexpected file saved:\n%s", data)
+	}
+}
+
 // TestAddProperties tests adding to a file with properties doesn't fail
 func TestAddProperties(t *testing.T) {
 
@@ -324,6 +427,32 @@ http://example.com/
 }
 
 // TestDelete tests removing an entry.
+// Test entries may be updated in place
+func TestUpdate(t *testing.T) {
+
+	c := ParserHelper(t, `https://example.com/
+https://example.org/
+ - foo:bar
+https://example.net/`)
+	defer os.Remove(c.path)
+
+	_, err := c.Parse()
+	if err != nil {
+		t.Fatalf("Error parsing file: %v", err)
+	}
+
+	if !c.Update(Feed{URL: "https://example.org/", Options: []Option{{Name: "paused", Value: "true"}}}) {
+		t.Fatalf("failed to update entry")
+	}
+	if c.Update(Feed{URL: "https://example.invalid/"}) {
+		t.Fatalf("updated a missing entry")
+	}
+
+	if c.entries[1].URL != "https://example.org/" || len(c.entries[1].Options) != 1 || c.entries[1].Options[0].Name != "paused" {
+		t.Fatalf("unexpected entries: %v", c.entries)
+	}
+}
+
 func TestDelete(t *testing.T) {
 
 	c := ParserHelper(t, `
@@ -403,7 +532,7 @@ func TestFuzz(t *testing.T) {
 func ParserHelper(t *testing.T, content string) *ConfigFile {
 
 	data := []byte(content)
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("Error creating temporary file")
 	}
@@ -421,3 +550,198 @@ func ParserHelper(t *testing.T, content string) *ConfigFile {
 
 	return c
 }
+
+// TestDirectories ensures the locations we use may be overridden.
+func TestDirectories(t *testing.T) {
+
+	defer func() {
+		SetDirectory("")
+		SetStateDirectory("")
+		SetProfile("")
+	}()
+
+	c := New()
+	def := c.Directory()
+	if c.StateDirectory() != def {
+		t.Fatalf("state is not stored with the configuration by default")
+	}
+
+	SetDirectory("/tmp/config")
+	if c.Directory() != "/tmp/config" || c.StateDirectory() != "/tmp/config" {
+		t.Fatalf("failed to override directory: %s %s", c.Directory(), c.StateDirectory())
+	}
+
+	SetStateDirectory("/tmp/state")
+	if c.Directory() != "/tmp/config" || c.StateDirectory() != "/tmp/state" {
+		t.Fatalf("failed to override state directory: %s %s", c.Directory(), c.StateDirectory())
+	}
+
+	err := SetProfile("work")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if c.Directory() != filepath.Join("/tmp/config", "profiles", "work") ||
+		c.StateDirectory() != filepath.Join("/tmp/state", "profiles", "work") {
+		t.Fatalf("profile not used: %s %s", c.Directory(), c.StateDirectory())
+	}
+
+	for _, name := range []string{"..", ".", "a/b", `a\b`} {
+		if SetProfile(name) == nil {
+			t.Errorf("expected error with profile %q", name)
+		}
+	}
+
+	SetDirectory("")
+	SetStateDirectory("")
+	SetProfile("")
+	if c.Directory() != def {
+		t.Fatalf("failed to restore default")
+	}
+}
+
+// TestDefaultDirectory ensures our configuration is stored beneath
+// %AppData% on Windows, and ~/.rss2email everywhere else.
+func TestDefaultDirectory(t *testing.T) {
+
+	defer func(name string, fn func() (string, error)) {
+		goos = name
+		userConfigDir = fn
+	}(goos, userConfigDir)
+
+	home := t.TempDir()
+	t.Setenv("HOME", home)
+	t.Setenv("USERPROFILE", home)
+	appData := filepath.Join(home, "AppData", "Roaming")
+
+	tests := []struct {
+		goos string
+		dir  string
+		err  error
+		want string
+	}{
+		{"linux", appData, nil, filepath.Join(home, ".rss2email")},
+		{"darwin", appData, nil, filepath.Join(home, ".rss2email")},
+		{"windows", appData, nil, filepath.Join(appData, "rss2email")},
+		{"windows", "", nil, filepath.Join(home, ".rss2email")},
+		{"windows", "", errors.New("%AppData% is not defined"), filepath.Join(home, ".rss2email")},
+	}
+
+	for _, test := range tests {
+		goos = test.goos
+		userConfigDir = func() (string, error) { return test.dir, test.err }
+
+		got := New().Directory()
+		if got != test.want {
+			t.Errorf("%s with %q %v: expected %s, got %s", test.goos, test.dir, test.err, test.want, got)
+		}
+	}
+}
+
+// TestEncrypted ensures an encrypted configuration file is used if
+// present, and is re-encrypted when saved.
+func TestEncrypted(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake age command requires a POSIX shell")
+	}
+
+	// Our fake age requires the identity file, and "encrypts" by
+	// prefixing a header.
+	bin := t.TempDir()
+	script := `#!/bin/sh
+test -f "$3" || { echo "no identity" >&2; exit 1; }
+case "$1" in
+--decrypt) tail -n +2 "$4" ;;
+--encrypt) echo "-- fake age"; cat ;;
+esac
+`
+	err := os.WriteFile(filepath.Join(bin, "age"), []byte(script), 0755)
+	if err != nil {
+		t.Fatalf("failed to write fake age: %s", err)
+	}
+	os.Setenv("PATH", bin+string(os.PathListSeparator)+os.Getenv("PATH"))
+	defer os.Setenv("PATH", os.Getenv("PATH")[len(bin)+1:])
+
+	identity := filepath.Join(bin, "keys.txt")
+	os.WriteFile(identity, []byte("AGE-SECRET-KEY-1\n"), 0600)
+	os.Setenv("RSS2EMAIL_AGE_IDENTITY", identity)
+	defer os.Unsetenv("RSS2EMAIL_AGE_IDENTITY")
+
+	dir := t.TempDir()
+	SetDirectory(dir)
+	defer SetDirectory("")
+
+	path := filepath.Join(dir, "feeds.txt.age")
+	os.WriteFile(path, []byte("-- fake age\nhttps://example.com/\n - to:user@example.com\n"), 0600)
+
+	c := New()
+	if c.Path() != path || !c.Exists() {
+		t.Fatalf("encrypted file not used: %s", c.Path())
+	}
+	entries, err := c.Parse()
+	if err != nil || len(entries) != 1 || entries[0].Options[0].Value != "user@example.com" {
+		t.Fatalf("unexpected entries %v: %v", entries, err)
+	}
+
+	c.Add("https://example.org/")
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+	data, _ := os.ReadFile(path)
+	if string(data) != "-- fake age\nhttps://example.com/\n - to:user@example.com\nhttps://example.org/\n" {
+		t.Fatalf("unexpected file saved:\n%s", data)
+	}
+
+	// Without our identity the file cannot be read.
+	os.Remove(identity)
+	_, err = New().Parse()
+	if err == nil || !strings.Contains(err.Error(), "no identity") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestSaveArchived ensures archived feeds are kept in their own section.
+func TestSaveArchived(t *testing.T) {
+
+	c := ParserHelper(t, `https://example.com/one
+https://example.com/two
+ # Quiet
+ - retry: 3
+
+`+ArchivedHeader+`
+https://example.com/three
+ - archived: 2020-01-01, no new items
+`)
+	defer os.Remove(c.path)
+
+	_, err := c.Parse()
+	if err != nil {
+		t.Fatalf("Error parsing file: %v", err)
+	}
+
+	// Archive one feed, restore another, and add a new one.
+	c.Update(Feed{URL: "https://example.com/two", Options: []Option{{Name: "retry", Value: "3"}, {Name: "archived", Value: "today"}}})
+	c.Update(Feed{URL: "https://example.com/three"})
+	c.Add("https://example.com/four")
+
+	err = c.Save()
+	if err != nil {
+		t.Fatalf("Error saving file: %s", err)
+	}
+
+	data, _ := os.ReadFile(c.path)
+	expected := `https://example.com/one
+https://example.com/three
+https://example.com/four
+
+` + ArchivedHeader + `
+https://example.com/two
+ - retry:3
+ - archived:today
+ # Quiet
+`
+	if string(data) != expected {
+		t.Fatalf("unexpected file saved:\n%s", data)
+	}
+}
@@ -3,16 +3,15 @@
 package configfile
 
 import (
-	"io/ioutil"
+	"errors"
 	"os"
-	"strings"
 )
 
 // Fuzz is used for fuzz-testing
 func Fuzz(data []byte) int {
 
 	// Create a temporary file
-	tmpfile, _ := ioutil.TempFile("", "example")
+	tmpfile, _ := os.CreateTemp("", "example")
 
 	// Cleanup when we're done
 	defer os.Remove(tmpfile.Name())
@@ -29,8 +28,9 @@ func Fuzz(data []byte) int {
 	_, err := c.Parse()
 	if err != nil {
 
-		// This is a known error, we expect to get
-		if !strings.Contains(err.Error(), "option outside a URL") {
+		// Syntax errors are expected, others are not.
+		var perr *ParseError
+		if !errors.As(err, &perr) {
 			panic(err)
 		}
 	}
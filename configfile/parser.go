@@ -0,0 +1,405 @@
+package configfile
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// KnownOptions holds the names of the per-feed options we support, which
+// are documented by the "config" sub-command.
+var KnownOptions = []string{
+	"advisories",
+	"archived",
+	"attach",
+	"bcc",
+	"cc",
+	"connect-to",
+	"cookies",
+	"cron",
+	"date-format",
+	"dedupe-titles",
+	"delay",
+	"deliver-hours",
+	"digest",
+	"digest-template",
+	"digest-to",
+	"doh",
+	"emoji-text",
+	"enhance",
+	"encoding",
+	"envelope-from",
+	"event",
+	"exclude",
+	"exclude-title",
+	"expand-links",
+	"favicon",
+	"filter-plugin",
+	"from",
+	"group",
+	"highlight",
+	"html-encoding",
+	"identity",
+	"image-proxy",
+	"include",
+	"include-title",
+	"incremental",
+	"ip-version",
+	"max-size",
+	"min-gap",
+	"name",
+	"oauth-id",
+	"oauth-scope",
+	"oauth-secret",
+	"oauth-url",
+	"output-plugin",
+	"paused",
+	"reddit-text",
+	"resolver",
+	"retry",
+	"rewrite",
+	"rewrite-link",
+	"rewrite-title",
+	"strip-images",
+	"strip-selector",
+	"style",
+	"subject",
+	"subject-prefix",
+	"subject-suffix",
+	"summarise",
+	"template",
+	"text-encoding",
+	"timezone",
+	"to",
+	"translate",
+	"unescape-html",
+	"unix-socket",
+	"user-agent",
+	"wayback",
+	"when",
+	"youtube-embed",
+}
+
+// Known returns true if the named per-feed option is one we support.
+func Known(name string) bool {
+	for _, known := range KnownOptions {
+		if known == name {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseError is a problem found within the configuration file, along with
+// the line upon which it occurs.
+type ParseError struct {
+
+	// Line is the line upon which the problem occurs.
+	Line int
+
+	// Msg describes the problem.
+	Msg string
+}
+
+// Error is part of the error interface.
+func (e *ParseError) Error() string {
+	return fmt.Sprintf("error on line %d: %s", e.Line, e.Msg)
+}
+
+// inline matches an option given upon the same line as a URL, such as
+// `name="Ars Technica"`.
+var inline = regexp.MustCompile(`^([a-z][a-z0-9-]*)=(.*)$`)
+
+// parser reads the configuration file, which has this grammar:
+//
+//	file     = { line }
+//	line     = blank | comment | feed | option
+//	comment  = "#" text
+//	feed     = { key "=" value } url { key "=" value } [ comment ]
+//	option   = "-" key ":" value
+//	value    = text | quoted
+//	quoted   = `"` text, with Go escapes `"`
+//
+// Any line, other than a comment, which ends with a backslash continues
+// upon the next, and options apply to the URL above them.
+type parser struct {
+
+	// scanner reads the lines of the file.
+	scanner *bufio.Scanner
+
+	// number is the number of the last line read.
+	number int
+
+	// feeds holds the feeds we've parsed.
+	feeds []Feed
+
+	// warnings holds the problems we've found which don't prevent the
+	// file from being used, such as unknown options.
+	warnings []*ParseError
+
+	// source holds the lines we've read, so that the file may be saved
+	// without losing comments, or formatting.
+	source source
+}
+
+// source is the text of a configuration file, along with the location of
+// each feed within it.
+type source struct {
+
+	// lines holds the lines of the file.
+	lines []string
+
+	// comment is true for each line which is blank, or a comment.
+	comment []bool
+
+	// spans holds the first and last lines of each feed, and its
+	// options, counting from zero.
+	spans [][2]int
+
+	// feeds holds a copy of each feed, as it was read.
+	feeds []Feed
+}
+
+// parse returns the feeds within the given configuration file, along with
+// any warnings, or an error if it is malformed.
+func parse(r io.Reader) ([]Feed, []*ParseError, error) {
+	p, err := newParser(r)
+	return p.feeds, p.warnings, err
+}
+
+// newParser returns a parser which has parsed the given configuration
+// file, or an error if it is malformed.
+func newParser(r io.Reader) (*parser, error) {
+
+	p := &parser{scanner: bufio.NewScanner(r)}
+	p.feeds = []Feed{}
+
+	for {
+		line, number, ok := p.next()
+		if !ok {
+			break
+		}
+
+		if line == "" || strings.HasPrefix(line, "#") {
+			p.source.comment[number-1] = true
+			continue
+		}
+
+		var err error
+		if strings.HasPrefix(line, "-") {
+			err = p.option(line, number)
+		} else {
+			err = p.feed(line, number)
+		}
+		if err != nil {
+			return p, err
+		}
+	}
+
+	// Remember the feeds as they were read, so we can tell if they've
+	// been changed when saving.
+	for _, f := range p.feeds {
+		f.Options = append([]Option{}, f.Options...)
+		p.source.feeds = append(p.source.feeds, f)
+	}
+	return p, p.scanner.Err()
+}
+
+// next returns the next logical line, with any continuation lines joined
+// to it, and leading/trailing space removed, along with the number of
+// the line upon which it begins.
+func (p *parser) next() (string, int, bool) {
+
+	if !p.scan() {
+		return "", 0, false
+	}
+	start := p.number
+
+	line := strings.TrimSpace(p.scanner.Text())
+	if strings.HasPrefix(line, "#") {
+		return line, start, true
+	}
+
+	for strings.HasSuffix(line, `\`) && !strings.HasSuffix(line, `\\`) {
+		line = strings.TrimSuffix(line, `\`)
+		if !p.scan() {
+			break
+		}
+		line += strings.TrimSpace(p.scanner.Text())
+	}
+	return strings.TrimSpace(line), start, true
+}
+
+// scan reads the next line, recording it.
+func (p *parser) scan() bool {
+
+	if !p.scanner.Scan() {
+		return false
+	}
+	p.number++
+	p.source.lines = append(p.source.lines, p.scanner.Text())
+	p.source.comment = append(p.source.comment, false)
+	return true
+}
+
+// add appends the given option to the current feed, warning if it isn't
+// one we know of.
+func (p *parser) add(opt Option) {
+
+	if !Known(opt.Name) {
+		p.warnings = append(p.warnings, &ParseError{Line: opt.Line, Msg: fmt.Sprintf("unknown option '%s'", opt.Name)})
+	}
+
+	f := &p.feeds[len(p.feeds)-1]
+	f.Options = append(f.Options, opt)
+}
+
+// option parses a line holding an option, such as " - retry: 3".
+func (p *parser) option(line string, number int) error {
+
+	// options go AFTER the URL to which they refer
+	if len(p.feeds) == 0 {
+		return &ParseError{Line: number, Msg: fmt.Sprintf("option outside a URL: %s", line)}
+	}
+
+	line = strings.TrimPrefix(line, "-")
+	i := strings.Index(line, ":")
+	if i < 0 {
+		return &ParseError{Line: number, Msg: fmt.Sprintf("option without a value, expected '- key:value': %s", line)}
+	}
+
+	key := strings.TrimSpace(line[:i])
+	if key == "" {
+		return &ParseError{Line: number, Msg: fmt.Sprintf("option without a name: %s", line)}
+	}
+
+	val, err := value(strings.TrimSpace(line[i+1:]))
+	if err != nil {
+		return &ParseError{Line: number, Msg: fmt.Sprintf("option %s: %s", key, err)}
+	}
+
+	p.add(Option{Name: key, Value: val, Line: number})
+
+	// The feed extends to the end of its last option.
+	p.source.spans[len(p.source.spans)-1][1] = p.number - 1
+	return nil
+}
+
+// feed parses a line holding the URL of a feed, along with any options
+// given upon the same line, such as `name="Ars Technica"`.
+func (p *parser) feed(line string, number int) error {
+
+	feed := Feed{URL: "", Options: []Option{}, Line: number}
+	var options []Option
+
+	for _, token := range tokens(line) {
+
+		if strings.HasPrefix(token, "#") {
+			break
+		}
+
+		if m := inline.FindStringSubmatch(token); m != nil {
+			val, err := value(m[2])
+			if err != nil {
+				return &ParseError{Line: number, Msg: fmt.Sprintf("option %s: %s", m[1], err)}
+			}
+			options = append(options, Option{Name: m[1], Value: val, Line: number})
+			continue
+		}
+
+		if feed.URL != "" {
+			return &ParseError{Line: number, Msg: fmt.Sprintf("unexpected '%s' following the URL %s", token, feed.URL)}
+		}
+		feed.URL = token
+	}
+
+	if feed.URL == "" {
+		return &ParseError{Line: number, Msg: fmt.Sprintf("options without a URL: %s", line)}
+	}
+
+	p.feeds = append(p.feeds, feed)
+	p.source.spans = append(p.source.spans, [2]int{number - 1, p.number - 1})
+	for _, opt := range options {
+		p.add(opt)
+	}
+	return nil
+}
+
+// tokens splits the given line upon whitespace, except within quoted
+// values.
+func tokens(line string) []string {
+
+	var res []string
+	var cur strings.Builder
+	quoted := false
+	escaped := false
+
+	for _, r := range line {
+		switch {
+		case escaped:
+			escaped = false
+		case quoted && r == '\\':
+			escaped = true
+		case r == '"':
+			quoted = !quoted
+		case !quoted && (r == ' ' || r == '\t'):
+			if cur.Len() > 0 {
+				res = append(res, cur.String())
+				cur.Reset()
+			}
+			continue
+		}
+		cur.WriteRune(r)
+	}
+	if cur.Len() > 0 {
+		res = append(res, cur.String())
+	}
+	return res
+}
+
+// value returns the given value, removing the quotes, and expanding any
+// escapes, if it is quoted.
+func value(val string) (string, error) {
+
+	if !strings.HasPrefix(val, `"`) {
+		return val, nil
+	}
+
+	// Find the closing quote.
+	end := -1
+	for i := 1; i < len(val); i++ {
+		if val[i] == '\\' {
+			i++
+			continue
+		}
+		if val[i] == '"' {
+			end = i
+			break
+		}
+	}
+	if end < 0 {
+		return "", fmt.Errorf("unterminated quoted value %s", val)
+	}
+	if strings.TrimSpace(val[end+1:]) != "" {
+		return "", fmt.Errorf("unexpected '%s' following quoted value", strings.TrimSpace(val[end+1:]))
+	}
+
+	res, err := strconv.Unquote(val[:end+1])
+	if err != nil {
+		return "", fmt.Errorf("invalid quoted value %s", val[:end+1])
+	}
+	return res, nil
+}
+
+// quote returns the given value, quoted if it could not otherwise be read
+// back unchanged.
+func quote(val string) string {
+
+	if val != strings.TrimSpace(val) || strings.HasPrefix(val, `"`) || strings.HasSuffix(val, `\`) || strings.ContainsAny(val, "\r\n") {
+		return strconv.Quote(val)
+	}
+	return val
+}
@@ -0,0 +1,93 @@
+package configfile
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+// TestParse ensures quoted values, continuation lines, and options upon
+// the same line as a URL, are parsed.
+func TestParse(t *testing.T) {
+
+	feeds, warnings, err := parse(strings.NewReader(`
+name="Ars \"Technica\"" https://example.com/ars retry=3 # a comment
+ - exclude: "  padded  "
+ - include: foo|\
+     bar
+ - to: a@example.com, \
+       b@example.com
+ - exlude: typo
+https://example.com/?a=b
+`))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(feeds) != 2 || feeds[0].URL != "https://example.com/ars" || feeds[1].URL != "https://example.com/?a=b" || feeds[1].Line != 9 {
+		t.Fatalf("unexpected feeds: %v", feeds)
+	}
+
+	expected := []Option{
+		{Name: "name", Value: `Ars "Technica"`, Line: 2},
+		{Name: "retry", Value: "3", Line: 2},
+		{Name: "exclude", Value: "  padded  ", Line: 3},
+		{Name: "include", Value: "foo|bar", Line: 4},
+		{Name: "to", Value: "a@example.com, b@example.com", Line: 6},
+		{Name: "exlude", Value: "typo", Line: 8},
+	}
+	if len(feeds[0].Options) != len(expected) {
+		t.Fatalf("unexpected options: %v", feeds[0].Options)
+	}
+	for i, opt := range expected {
+		if feeds[0].Options[i] != opt {
+			t.Errorf("unexpected option %d: %v", i, feeds[0].Options[i])
+		}
+	}
+
+	if len(warnings) != 1 || warnings[0].Error() != "error on line 8: unknown option 'exlude'" {
+		t.Fatalf("unexpected warnings: %v", warnings)
+	}
+}
+
+// TestParseErrors ensures syntax errors are reported, along with the line
+// upon which they occur.
+func TestParseErrors(t *testing.T) {
+
+	tests := []struct {
+		input string
+		line  int
+		msg   string
+	}{
+		{"# comment\n - foo: bar\n", 2, "option outside a URL"},
+		{"https://example.com/\n - retry\n", 2, "option without a value"},
+		{"https://example.com/\n - : 3\n", 2, "option without a name"},
+		{"https://example.com/\n\n - exclude: \"foo\n", 3, "unterminated quoted value"},
+		{"https://example.com/\n - exclude: \"\\q\"\n", 2, "invalid quoted value"},
+		{"https://example.com/ https://example.org/\n", 1, "unexpected 'https://example.org/' following the URL"},
+		{"name=foo\n", 1, "options without a URL"},
+		{"https://example.com/\n - include: \"foo\" # bar\n", 2, "unexpected '# bar' following quoted value"},
+	}
+
+	for _, test := range tests {
+		_, _, err := parse(strings.NewReader(test.input))
+
+		var perr *ParseError
+		if !errors.As(err, &perr) {
+			t.Fatalf("expected a syntax error parsing %q, got %v", test.input, err)
+		}
+		if perr.Line != test.line || !strings.Contains(perr.Msg, test.msg) {
+			t.Errorf("unexpected error parsing %q: %s", test.input, err)
+		}
+	}
+}
+
+// TestQuote ensures values which are saved may be read back unchanged.
+func TestQuote(t *testing.T) {
+
+	for _, val := range []string{"plain", " padded ", `"quoted"`, `C:\dir\`, "two\nlines", "a: b # c"} {
+		feeds, _, err := parse(strings.NewReader("https://example.com/\n - to:" + quote(val) + "\n"))
+		if err != nil || len(feeds) != 1 || feeds[0].Options[0].Value != val {
+			t.Errorf("value %q wasn't preserved: %v %v", val, feeds, err)
+		}
+	}
+}
@@ -0,0 +1,183 @@
+package configfile
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// remote holds the location of a remote list of feeds, if any, and is
+// set via the global "-feeds" flag.
+var remote string
+
+// remoteTimeout is the time we allow for fetching a remote list of feeds.
+var remoteTimeout = 30 * time.Second
+
+// SetRemote configures a remote list of feeds to be used in place of
+// the local configuration file.
+//
+// The location may be an HTTP(S) URL, or a git repository prefixed with
+// "git+", with an optional "#path" suffix naming the file within it:
+//
+//	https://example.com/feeds.txt
+//	git+https://example.com/user/feeds.git#work/feeds.txt
+//
+// An empty string restores the use of the local configuration file.
+func SetRemote(location string) error {
+
+	if location != "" && !isRemote(location) {
+		return fmt.Errorf("unsupported feed list location '%s'", location)
+	}
+	remote = location
+	return nil
+}
+
+// Remote returns the location of the remote list of feeds in use, or
+// the empty string if the local configuration file is used.
+func Remote() string {
+	return remote
+}
+
+// isRemote returns true if the given location is one we can fetch.
+func isRemote(location string) bool {
+	for _, prefix := range []string{"https://", "http://", "git+"} {
+		if strings.HasPrefix(location, prefix) && len(location) > len(prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteCache returns the directory in which we cache the remote list
+// of feeds, which is specific to its location.
+func (c *ConfigFile) remoteCache() string {
+	sum := sha1.Sum([]byte(remote))
+	return filepath.Join(c.StateDirectory(), "remote", hex.EncodeToString(sum[:8]))
+}
+
+// fetchRemote updates our cached copy of the remote list of feeds,
+// returning the path to it.
+//
+// If the remote list cannot be fetched then we fall back to the copy
+// we fetched previously, if any, so that a temporary outage doesn't
+// prevent us from running.
+func (c *ConfigFile) fetchRemote() (string, error) {
+
+	dir := c.remoteCache()
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return "", err
+	}
+
+	var path string
+	if strings.HasPrefix(remote, "git+") {
+		path, err = fetchGit(dir, strings.TrimPrefix(remote, "git+"))
+	} else {
+		path = filepath.Join(dir, "feeds.txt")
+		err = fetchHTTP(path, remote)
+	}
+
+	if err != nil {
+		if _, serr := os.Stat(path); serr != nil {
+			return "", fmt.Errorf("failed to fetch feed list from %s: %s", remote, err)
+		}
+		fmt.Fprintf(os.Stderr, "failed to fetch feed list from %s, using cached copy: %s\n", remote, err)
+	}
+	return path, nil
+}
+
+// fetchHTTP downloads the given URL to the given path, making a
+// conditional request if we have a cached copy already.
+func fetchHTTP(path string, url string) error {
+
+	req, err := http.NewRequest("GET", url, nil)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("User-Agent", "rss2email (https://github.com/skx/rss2email)")
+
+	// The ETag of our cached copy is stored alongside it.
+	etag, err := os.ReadFile(path + ".etag")
+	if err == nil {
+		req.Header.Set("If-None-Match", strings.TrimSpace(string(etag)))
+	}
+	if info, err := os.Stat(path); err == nil {
+		req.Header.Set("If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat))
+	}
+
+	client := &http.Client{Timeout: remoteTimeout}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotModified {
+		return nil
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("status code %d", resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+
+	// Write to a temporary file and rename, so the cached copy is
+	// never left truncated.
+	err = os.WriteFile(path+".tmp", body, 0644)
+	if err != nil {
+		return err
+	}
+	err = os.Rename(path+".tmp", path)
+	if err != nil {
+		return err
+	}
+
+	// Record the validators for our next request.
+	if tag := resp.Header.Get("ETag"); tag != "" {
+		os.WriteFile(path+".etag", []byte(tag), 0644)
+	} else {
+		os.Remove(path + ".etag")
+	}
+	if t, err := http.ParseTime(resp.Header.Get("Last-Modified")); err == nil {
+		os.Chtimes(path, t, t)
+	}
+	return nil
+}
+
+// fetchGit clones, or updates, the given git repository beneath the
+// given directory, returning the path to the list of feeds within it.
+func fetchGit(dir string, location string) (string, error) {
+
+	repo := location
+	file := "feeds.txt"
+	if i := strings.LastIndex(location, "#"); i >= 0 {
+		repo = location[:i]
+		file = location[i+1:]
+	}
+
+	clone := filepath.Join(dir, "repo")
+	path := filepath.Join(clone, filepath.FromSlash(file))
+
+	var cmd *exec.Cmd
+	if _, err := os.Stat(filepath.Join(clone, ".git")); err == nil {
+		cmd = exec.Command("git", "-C", clone, "pull", "--quiet", "--ff-only")
+	} else {
+		cmd = exec.Command("git", "clone", "--quiet", "--depth", "1", repo, clone)
+	}
+
+	output, err := cmd.CombinedOutput()
+	if err != nil {
+		return path, fmt.Errorf("%s: %s", err, strings.TrimSpace(string(output)))
+	}
+	return path, nil
+}
@@ -0,0 +1,151 @@
+package configfile
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+)
+
+func TestRemote(t *testing.T) {
+
+	defer func() {
+		SetRemote("")
+		SetStateDirectory("")
+	}()
+
+	requests := 0
+	fail := false
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		if fail {
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		}
+		if r.Header.Get("If-None-Match") == `"v1"` {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		fmt.Fprintf(w, "https://example.com/\n - to:user@example.com\nhttps://example.org/\n")
+	}))
+	defer ts.Close()
+
+	SetStateDirectory(t.TempDir())
+	err := SetRemote(ts.URL + "/feeds.txt")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The first fetch populates the cache.
+	c := New()
+	entries, err := c.Parse()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(entries) != 2 || entries[0].Options[0].Value != "user@example.com" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	// The second is conditional, and uses the cache.
+	c = New()
+	entries, err = c.Parse()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("unexpected result: %v %v", entries, err)
+	}
+
+	// Failures fall back to the cache too.
+	fail = true
+	c = New()
+	entries, err = c.Parse()
+	if err != nil || len(entries) != 2 {
+		t.Fatalf("unexpected result: %v %v", entries, err)
+	}
+	if requests != 3 {
+		t.Fatalf("unexpected number of requests: %d", requests)
+	}
+
+	// Remote lists cannot be modified.
+	c.Add("https://example.net/")
+	if c.Save() == nil {
+		t.Fatalf("expected error saving a remote list")
+	}
+
+	// Without a cache a failure is an error.
+	SetStateDirectory(t.TempDir())
+	_, err = New().Parse()
+	if err == nil {
+		t.Fatalf("expected error without a cache")
+	}
+}
+
+func TestRemoteGit(t *testing.T) {
+
+	defer func() {
+		SetRemote("")
+		SetStateDirectory("")
+	}()
+
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git is not available")
+	}
+
+	// Create a repository holding a list of feeds.
+	repo := t.TempDir()
+	os.MkdirAll(filepath.Join(repo, "lists"), 0755)
+	os.WriteFile(filepath.Join(repo, "lists", "work.txt"), []byte("https://example.com/\n"), 0644)
+	for _, args := range [][]string{
+		{"init", "--quiet"},
+		{"add", "."},
+		{"-c", "user.name=test", "-c", "user.email=test@example.com", "commit", "--quiet", "-m", "feeds"},
+	} {
+		if out, err := gitCommand(repo, args...); err != nil {
+			t.Fatalf("git %v failed: %s %s", args, err, out)
+		}
+	}
+
+	SetStateDirectory(t.TempDir())
+	SetRemote("git+file://" + repo + "#lists/work.txt")
+
+	// Once to clone, and again to update.
+	for i := 0; i < 2; i++ {
+		entries, err := New().Parse()
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if len(entries) != 1 || entries[0].URL != "https://example.com/" {
+			t.Fatalf("unexpected entries: %v", entries)
+		}
+	}
+}
+
+func TestSetRemote(t *testing.T) {
+
+	defer SetRemote("")
+
+	for _, bad := range []string{"/etc/feeds.txt", "ftp://example.com/", "git+", "https://"} {
+		if SetRemote(bad) == nil {
+			t.Errorf("expected error with %q", bad)
+		}
+	}
+	if SetRemote("") != nil || Remote() != "" {
+		t.Fatalf("failed to reset remote")
+	}
+
+	// Explicit paths aren't affected.
+	SetRemote("https://example.com/feeds.txt")
+	c := NewWithPath("/nonexistent/feeds.txt")
+	if c.useRemote() {
+		t.Fatalf("explicit path should not use the remote list")
+	}
+}
+
+// gitCommand runs git within the given directory.
+func gitCommand(dir string, args ...string) ([]byte, error) {
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	return cmd.CombinedOutput()
+}
@@ -0,0 +1,73 @@
+//
+// Support for running within a container.
+//
+
+package main
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/skx/rss2email/logger"
+)
+
+// containerMode is set by the global "-container" flag, or by setting
+// $RSS2EMAIL_CONTAINER, and tailors our behaviour to running within a
+// container:
+//
+//   - Our configuration and state are stored beneath /data, rather than
+//     the home directory of the user, which may not exist.
+//   - Messages and errors are written to STDOUT as lines of JSON.
+//   - The daemon is run if no sub-command is given.
+var containerMode bool
+
+// dataDir is the directory holding our configuration and state, in
+// container mode.
+var dataDir = "/data"
+
+// showErrors shows the given errors, which are written to STDERR, in red
+// upon a terminal, or as JSON in container mode.
+//
+// If items are written to STDOUT as JSON, via the "jsonl" output, errors
+// are always written to STDERR to avoid corrupting that output.
+func showErrors(errors []error, outputs []string) {
+
+	w := os.Stderr
+	if containerMode && !hasOutput(outputs, "jsonl") {
+		w = os.Stdout
+	}
+
+	colour := useColour(w)
+	for _, err := range errors {
+		if containerMode {
+			logger.JSON(w, "error", err.Error())
+		} else {
+			fmt.Fprintln(w, paint(colour, colourRed, err.Error()))
+		}
+	}
+}
+
+// newLogger returns the logger used by the cron, and daemon, sub-commands,
+// for which every component is verbose if verbose is set, or otherwise
+// those in the given comma-separated list.
+//
+// Messages are written to STDOUT, as JSON in container mode, unless items
+// are written to STDOUT as JSON, via the "jsonl" output, in which case
+// they're written to STDERR to avoid corrupting that output.  Errors are
+// coloured upon a terminal.
+func newLogger(outputs []string, verbose bool, only string) *logger.Writer {
+
+	w := os.Stdout
+	if hasOutput(outputs, "jsonl") {
+		w = os.Stderr
+	}
+
+	l := logger.New(w)
+	l.SetJSON(containerMode)
+	l.SetColour(useColour(w))
+	if verbose {
+		l.SetVerbose("all")
+	}
+	l.SetVerbose(splitFeeds(only)...)
+	return l
+}
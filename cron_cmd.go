@@ -9,8 +9,12 @@ import (
 	"fmt"
 	"os"
 	"strings"
+	"time"
 
+	"github.com/skx/rss2email/aggregate"
+	"github.com/skx/rss2email/logger"
 	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/processor/emailer"
 )
 
 // Structure for our options and state.
@@ -18,10 +22,183 @@ type cronCmd struct {
 	// Should we be verbose in operation?
 	verbose bool
 
+	// The components which should be verbose, if not all of them.
+	verboseOnly string
+
+	// The address to use in the From: header.
+	from string
+
+	// The envelope sender to pass to the MTA.
+	envelopeFrom string
+
+	// Comma-separated addresses to copy upon each email.
+	cc string
+
+	// Comma-separated addresses to blind-copy upon each email.
+	bcc string
+
+	// The maximum size of an email body, such as "512k".
+	maxSize string
+
+	// The name of the embedded template to use.
+	style string
+
+	// The time zone, and layout, of the dates shown within emails.
+	timezone   string
+	dateFormat string
+
+	// Should we embed the icon of each feed?
+	favicon bool
+
+	// Should we process the unread items of a feed-reader?
+	unread bool
+
+	// Should we archive the items we process?
+	archive bool
+
+	// The directory to write a static HTML archive to.
+	htmlArchive string
+
+	// Comma-separated outputs to send new items to.
+	output string
+
+	// The command to pipe new items to, for the "exec" output.
+	execCommand string
+
+	// The format of the items piped to that command.
+	execFormat string
+
+	// The path of the Atom feed written by the "feed" output, and the
+	// number of items it holds.
+	feedFile  string
+	feedItems int
+
+	// Should we show a summary of each run?
+	summary bool
+
+	// Comma-separated addresses to email the summary to.
+	summaryTo string
+
+	// Comma-separated URLs, or names, of the feeds to process.
+	only string
+
+	// The hours within which emails may be delivered, "HH:MM-HH:MM".
+	deliverHours string
+
+	// The time within which each run must complete.
+	timeout time.Duration
+
+	// The number of months after which dead feeds are archived.
+	archiveDead int
+
+	// The number of days for which the content of sent items is kept.
+	cacheItems int
+
+	// The maximum number of items to send in each run.
+	maxItems int
+
+	// Should we show the progress of the run, upon a terminal?
+	progress bool
+
 	// Should we send emails?
 	send bool
 }
 
+// parseOutputs parses the comma-separated list of outputs given to the
+// "-output" flag, ensuring each is valid.
+func parseOutputs(value string) ([]string, error) {
+
+	var outputs []string
+	for _, name := range strings.Split(value, ",") {
+		name = strings.TrimSpace(name)
+		if name == "" {
+			continue
+		}
+		if !hasOutput(processor.Outputs, name) {
+			return nil, fmt.Errorf("unknown output '%s', valid outputs are: %s", name, strings.Join(processor.Outputs, ", "))
+		}
+		outputs = append(outputs, name)
+	}
+
+	// Default to sending email.
+	if len(outputs) == 0 {
+		outputs = []string{"email"}
+	}
+	return outputs, nil
+}
+
+// checkExec ensures the settings of the "exec" output are valid, if it
+// is being used.
+func checkExec(outputs []string, command string, format string) error {
+
+	if !hasOutput(outputs, "exec") {
+		return nil
+	}
+	if command == "" {
+		return fmt.Errorf("the exec output requires a command, via the '-exec' flag")
+	}
+	if !hasOutput(processor.ExecFormats, format) {
+		return fmt.Errorf("unknown exec-format '%s', valid formats are: %s", format, strings.Join(processor.ExecFormats, ", "))
+	}
+	return nil
+}
+
+// needRecipients returns true if the given outputs generate emails, and
+// so require recipients.
+func needRecipients(outputs []string, execFormat string) bool {
+	return hasOutput(outputs, "email") || (hasOutput(outputs, "exec") && execFormat != "json")
+}
+
+// reportSummary shows the summary of a run, if show is true, and emails
+// it to the given comma-separated addresses, if any.
+//
+// The summary is written to STDERR if items are being written to STDOUT.
+func reportSummary(summary processor.Summary, show bool, outputs []string, to string, from string) error {
+
+	if show {
+		if hasOutput(outputs, "jsonl") {
+			fmt.Fprint(os.Stderr, summary.String())
+		} else {
+			fmt.Fprint(out, summary.String())
+		}
+	}
+
+	addresses := emailer.SplitAddresses(to)
+	if len(addresses) == 0 {
+		return nil
+	}
+
+	err := emailer.Notify(from, addresses, "rss2email summary: "+summary.Line(), summary.String())
+	if err != nil {
+		return fmt.Errorf("failed to email summary: %s", err)
+	}
+	return nil
+}
+
+// splitFeeds splits the comma-separated list of feeds given to the
+// "-only" flag.
+func splitFeeds(value string) []string {
+
+	var feeds []string
+	for _, feed := range strings.Split(value, ",") {
+		feed = strings.TrimSpace(feed)
+		if feed != "" {
+			feeds = append(feeds, feed)
+		}
+	}
+	return feeds
+}
+
+// hasOutput returns true if the given output is present in the list.
+func hasOutput(outputs []string, name string) bool {
+	for _, output := range outputs {
+		if output == name {
+			return true
+		}
+	}
+	return false
+}
+
 // Info is part of the subcommand-API.
 func (c *cronCmd) Info() (string, string) {
 	return "cron", `Send emails for each new entry in our feed lists.
@@ -40,6 +217,30 @@ Example:
     $ rss2email cron user1@example.com user2@example.com
 
 
+Selecting Feeds:
+
+The '-only' flag restricts processing to the given comma-separated feeds,
+which may be given by URL or by name.  Feeds are named via the 'name'
+option, and names may be abbreviated to a unique prefix:
+
+    $ rss2email cron -only=ars user@example.com
+
+
+Recipients:
+
+A single email is generated for each new item, addressed to all of the
+recipients given upon the command-line.  Addresses may also be given as
+a comma-separated list.  Each recipient can see the addresses of the
+others, unlike earlier releases which sent a separate email to each, so
+if that is a problem give a single address and list the others via
+'-bcc'.
+
+You may use the '-cc' and '-bcc' flags to specify comma-separated lists of
+addresses which should receive a copy, or blind-copy, of each email.
+
+The recipients may be replaced on a per-feed basis via the 'to', 'cc', and
+'bcc' options.
+
 Email Sending:
 
 By default we pipe outgoing messages through '/usr/sbin/sendmail' for delivery,
@@ -51,19 +252,313 @@ wish to use SMTP you need to configure the following environmental variables:
     SMTP_USERNAME   (e.g. "user@domain.com")
     SMTP_PASSWORD   (e.g. "secret!word#here")
 
+To authenticate via XOAUTH2, as Gmail and Office365 require, set these
+instead of SMTP_PASSWORD, where the refresh token was obtained by
+authorizing your client with the account in SMTP_USERNAME:
+
+    SMTP_OAUTH_TOKEN_URL      (e.g. "https://oauth2.googleapis.com/token")
+    SMTP_OAUTH_CLIENT_ID
+    SMTP_OAUTH_CLIENT_SECRET
+    SMTP_OAUTH_REFRESH_TOKEN
+    SMTP_OAUTH_SCOPE          (optional)
+
+Access tokens are cached beneath the state directory, until they expire.
+
+The password, client secret, and refresh token may be references to
+secrets, rather than the secrets themselves:
+
+    env:SMTP_PASS                  (the value of another variable)
+    file:/run/secrets/smtp         (the contents of a file)
+    cmd:pass show rss2email/smtp   (the output of a command)
+    age:/path/to/smtp.age          (a file, decrypted with age)
+    gpg:/path/to/smtp.gpg          (a file, decrypted with gpg)
+
+Upon Windows SMTP is always used, and the configuration is stored beneath
+'%AppData%\rss2email' rather than '~/.rss2email'.
+
+
+Delivery Hours:
+
+The '-deliver-hours' flag restricts the sending of emails to the given
+local times, so that items discovered overnight don't disturb you:
+
+    $ rss2email daemon -deliver-hours=08:00-22:00 user@example.com
+
+Items discovered outside those hours are queued beneath the state
+directory, and sent by the first run once they begin.  The hours may
+span midnight, for example "22:00-07:00".  They may also be set for
+a single feed via the 'deliver-hours' option, which may be "always" to
+send that feed's items immediately.
+
+Chatty feeds may be limited via the 'min-gap' option, which holds the
+minimum time between their emails, such as "2h".  Items discovered
+before that time has passed are queued, and once it has they are sent
+together as a single digest email.
+
+
+Digests:
+
+Rather than receiving an email for each item, the items of a feed may
+be collected and sent as a single roundup email, via the 'digest'
+option:
+
+    https://example.com/index.rss
+     - digest: weekly sunday 18:00
+
+The schedule may be "daily" or "weekly", optionally followed by a time,
+with "weekly" also accepting the day of the week.  Cron expressions may
+be used for anything more complex.  Items are accumulated beneath the
+state directory, and the digest is sent by the first run after the
+scheduled time.
+
+Feeds which also have a 'group' option share a single digest for that
+group, which uses the options of the first such feed.
+
+
+Summary:
+
+The '-summary' flag shows a summary of each run once it is complete, which
+begins with a single line like this:
+
+    12 feeds processed, 3 new items, 2 emailed, 1 skipped, 0 errors, in 4.2s
+
+Items which were queued, due to the '-deliver-hours' flag, are counted
+after those which were skipped.
+
+Any errors are listed after that line.  The '-summary-to' flag emails the
+summary to the given comma-separated addresses, which is useful for cron
+users who want a single daily status email.
+
+
+Dead Feeds:
+
+The '-archive-dead' flag archives feeds which have had no new items, or
+have failed every fetch, for the given number of months, as reported by
+the 'health' sub-command.  Archived feeds are given an 'archived' option,
+recording when and why, and moved to a section at the end of the
+configuration file.  They are no longer fetched, but their state is kept,
+and an email listing them is sent to the recipients.  They may be restored
+via the 'restore' sub-command:
+
+    $ rss2email cron -archive-dead 6 user@example.com
+    $ rss2email restore https://example.com/index.rss
+
+
+Tracing:
+
+The fetching, parsing, and sending of each feed may be traced via
+OpenTelemetry.  To export spans via OTLP/HTTP set the standard
+OTEL_EXPORTER_OTLP_ENDPOINT (or OTEL_EXPORTER_OTLP_TRACES_ENDPOINT)
+environmental variable, for example "http://localhost:4318".
+
+
+Interruption:
+
+Upon receiving SIGINT, or SIGTERM, no further items are processed but
+emails which are being sent are allowed to complete, for up to 30 seconds.
+The remaining items are processed upon the next run.  A second signal
+causes an immediate exit.
+
+The '-timeout' flag limits the time a run may take, such as "10m", so
+that a pathological feed can't cause runs to pile up.  Once it has passed
+no further items are processed, in the same way, and the feeds which
+weren't reached are skipped, and reported as an error.  In daemon mode
+the limit applies to each run.
+
+
+Sender Addresses:
+
+By default emails are sent from the recipient address, or from the first
+recipient if there are several.  You may use the '-from' flag to change
+the address used in the From: header, and the '-envelope-from' flag to
+change the envelope sender given to the MTA, which is where bounces are
+delivered.  If only '-from' is given it is also used as the envelope
+sender.
+
+Both settings may be overridden on a per-feed basis, via the 'from' and
+'envelope-from' options.
+
+
+Feed Readers:
+
+If you run a self-hosted feed-reader then rss2email may be used as its
+email-delivery arm.  The subscriptions of a Miniflux, or FreshRSS, server
+are fetched as if they were present within the configuration file, once
+the following environmental variables are set:
+
+    MINIFLUX_URL       (e.g. "https://miniflux.example.com")
+    MINIFLUX_TOKEN     (an API key)
+
+or:
+
+    FRESHRSS_URL       (e.g. "https://example.com/api/greader.php")
+    FRESHRSS_USER      (e.g. "steve")
+    FRESHRSS_PASSWORD  (the API password of that user)
+
+The '-reader-unread' flag causes the unread items of the reader to be
+processed instead, and those items are then marked as read.  Per-feed
+options in the configuration file still apply to these feeds.
+
+
+Message Size:
+
+Some feeds contain enormous items, for example with inline images.  You may
+use the '-max-size' flag to limit the size of the email bodies we generate,
+for example '-max-size=512k'.  Oversized items first have heavy elements,
+such as inline images and videos, removed.  If they are still too large they
+are truncated, and a link to the full item is included.
+
+This may be overridden on a per-feed basis via the 'max-size' option.
+
+
+Outputs:
+
+By default new items are emailed, however the '-output' flag allows them
+to be written to STDOUT as JSON objects, one per line, instead.  This is
+useful for piping items into tools such as jq, other scripts, or logging
+pipelines.  Both outputs may be used at once:
+
+    $ rss2email cron -output=jsonl | jq .title
+    $ rss2email cron -output=email,jsonl user@example.com
+
+The 'exec' output pipes each new item to the command given via the '-exec'
+flag, which is run via the shell.  By default the command receives the
+rendered email, but '-exec-format=json' causes it to receive the item as
+JSON instead.  This allows custom delivery without code changes:
+
+    $ rss2email cron -output=exec -exec="notmuch insert" user@example.com
+    $ rss2email cron -output=exec -exec-format=json -exec=~/bin/handle-item
+
+The command may also use the RSS2EMAIL_FEED, RSS2EMAIL_NAME, RSS2EMAIL_TITLE,
+and RSS2EMAIL_LINK environmental variables.  If it fails then processing of
+the feed stops, and the item will be retried upon the next run.
+
+The 'feed' output adds each new item to an Atom feed, which holds the
+newest items of all your feeds, after their filters have been applied, so
+that other tools may consume them as a feed once again.  The feed is
+written to '~/.rss2email/river.atom', or the path given via '-feed-file',
+and holds the number of items given via '-feed-items', 100 by default:
+
+    $ rss2email cron -output=feed -feed-file=/var/www/html/river.atom
+
+Recipients are not required unless emails are being generated.  When writing
+JSON any verbose output, and the output of commands, is written to STDERR.
+
+
+Progress:
+
+When run by hand, upon a terminal, the progress of the run is shown upon a
+single line, with the number of feeds processed, items sent, and errors
+seen, which is updated as the run proceeds.  Above it an aligned line is
+written for each feed, once processed, with the number of new items it had,
+the time it took, and whether it succeeded.  This may be disabled via the
+'-progress=false' flag, and isn't shown when '-verbose', or
+'-verbose-only', is used, or when the output isn't a terminal, such as
+when run via cron, so that plain messages are written instead.
+
+Upon a terminal errors are coloured, unless the global '-no-color' flag is
+given, or the NO_COLOR environmental variable is set.
+
+
+Archive:
+
+The '-archive' flag causes each item which is emailed to be stored within
+a SQLite database at '~/.rss2email/archive.db', along with the email which
+was generated and the status of its delivery.  The archive may be searched
+via the 'search' sub-command.
+
+The '-html-archive' flag writes the items which are emailed into a static
+HTML site within the given directory, with a page for each day and each
+feed, so that there is a browsable web archive of everything received.
+
+The '-cache-items' flag keeps the content of each item which is sent, as
+it was fetched, for the given number of days.  The 'rerender' sub-command
+may then send those items again, with your current template, and options,
+even once they're no longer present within their feeds.
+
+The '-max-items' flag limits the number of items sent in each run, which
+protects against a feed which changes the GUIDs of its existing items, so
+that they all appear new.  Once the limit is reached the remaining new
+items are left unseen, and the feeds they belong to are reported to the
+recipients, so that they may be dealt with by hand.
+
+Regardless of these flags each attempt to send an email is recorded in
+'~/.rss2email/delivery.log', along with the response of the mailserver,
+which may be viewed via the 'log' sub-command.
+
+An item is only recorded as seen once its email has been accepted.  If
+delivery fails temporarily, such as when the mailserver is down, the item
+will be tried again upon the next run.  If it is refused permanently, due
+to a 5xx SMTP reply or a sendmail exit status such as EX_NOUSER, it is
+reported as "rejected" and not retried.  Any other failure, even a crash,
+while processing an item only affects that item, which is retried upon the
+next run, while the remaining items of the feed are still delivered.
+
 
 Email Template:
 
-An embedded template is used to generate the emails which are sent, you
-may create a local override for this, for more details see :
+An embedded template is used to generate the emails which are sent.  By
+default the HTML part contains the feed item as-is, but you may use the
+'-style=styled' flag to wrap it in a layout with readable typography, which
+also supports dark-mode.  The 'style' option allows choosing the style on a
+per-feed basis.
+
+The dates upon which items were published, and updated, are available to
+templates as {{.Published}} and {{.Updated}}.  They're shown in the local
+time zone, and the layout "Mon, 02 Jan 2006 15:04 MST", which may be
+changed via the '-timezone' and '-date-format' flags, or the 'timezone'
+and 'date-format' options on a per-feed basis.
+
+The '-favicon' flag will cause the icon of each feed to be fetched, and
+embedded within the HTML part of the emails.  Icons are cached beneath
+'~/.rss2email/favicons/'.  The 'favicon' option may be used to enable, or
+disable, this on a per-feed basis.
+
+You may create a local override for the template, for more details see :
 
     $ rss2email help list-default-template
+
+
+Environment:
+
+Each flag may be set via an environmental variable, which is overridden by
+the command-line.  '-from' may be set via $RSS2EMAIL_CRON_FROM, or via
+$RSS2EMAIL_FROM which applies to every sub-command with that flag, and the
+global '-state-dir' flag via $RSS2EMAIL_STATE_DIR.  If no recipients are
+given they are read from $RSS2EMAIL_RECIPIENTS.
 `
 }
 
 // Arguments handles our flag-setup.
 func (c *cronCmd) Arguments(f *flag.FlagSet) {
 	f.BoolVar(&c.verbose, "verbose", false, "Should we be extra verbose?")
+	f.StringVar(&c.verboseOnly, "verbose-only", "", "Comma-separated list of the components to be verbose for, from \"process\", \"fetch\", and \"send\".")
+	f.StringVar(&c.from, "from", "", "The address to use in the From: header of the emails we send.")
+	f.StringVar(&c.envelopeFrom, "envelope-from", "", "The envelope sender to use when delivering emails.")
+	f.StringVar(&c.cc, "cc", "", "Comma-separated list of addresses to copy upon each email.")
+	f.StringVar(&c.bcc, "bcc", "", "Comma-separated list of addresses to blind-copy upon each email.")
+	f.StringVar(&c.style, "style", "plain", "The embedded template to use, \"plain\" or \"styled\".")
+	f.StringVar(&c.timezone, "timezone", "", "The time zone in which dates are shown within emails, such as \"Europe/Madrid\", rather than the local one.")
+	f.StringVar(&c.dateFormat, "date-format", emailer.DefaultDateFormat, "The layout of the dates shown within emails, as used by Go's time package.")
+	f.BoolVar(&c.favicon, "favicon", false, "Embed the icon of each feed within the emails we send?")
+	f.BoolVar(&c.archive, "archive", false, "Store the items we send within an archive, which may be searched later?")
+	f.StringVar(&c.output, "output", "email", "Comma-separated list of outputs for new items, \"email\", \"jsonl\", \"exec\", and/or \"feed\".")
+	f.StringVar(&c.execCommand, "exec", "", "The command to pipe new items to, for the \"exec\" output.")
+	f.StringVar(&c.execFormat, "exec-format", "message", "The format of the items piped to that command, \"message\" or \"json\".")
+	f.StringVar(&c.feedFile, "feed-file", "", "The path of the Atom feed written by the \"feed\" output, rather than ~/.rss2email/river.atom.")
+	f.IntVar(&c.feedItems, "feed-items", aggregate.DefaultLimit, "The number of items held by the Atom feed written by the \"feed\" output.")
+	f.BoolVar(&c.summary, "summary", false, "Show a summary at the end of each run?")
+	f.StringVar(&c.summaryTo, "summary-to", "", "Comma-separated list of addresses to email the summary of each run to.")
+	f.StringVar(&c.only, "only", "", "Comma-separated list of the feeds to process, by URL or name, rather than all of them.")
+	f.IntVar(&c.archiveDead, "archive-dead", 0, "Archive feeds which have had no new items, or have failed every fetch, for this many months.")
+	f.IntVar(&c.cacheItems, "cache-items", 0, "Keep the content of the items we send for this many days, so they may be sent again via 'rerender'.")
+	f.IntVar(&c.maxItems, "max-items", 0, "The maximum number of items to send in each run, e.g. 200, after which the remaining new items are left unseen.")
+	f.DurationVar(&c.timeout, "timeout", 0, "The time within which each run must complete, e.g. \"10m\", after which the remaining feeds are skipped.")
+	f.StringVar(&c.deliverHours, "deliver-hours", "", "Only deliver emails between these local times, e.g. \"08:00-22:00\", queueing items discovered outside them.")
+	f.StringVar(&c.htmlArchive, "html-archive", "", "Write a static HTML archive of the items we send to the given directory.")
+	f.BoolVar(&c.unread, "reader-unread", false, "Process the unread items of the configured feed-reader, rather than its subscriptions?")
+	f.StringVar(&c.maxSize, "max-size", "", "The maximum size of an email body, larger items are truncated (e.g. \"512k\").")
+	f.BoolVar(&c.progress, "progress", true, "Show the progress of the run, rather than messages, when run upon a terminal?")
 	f.BoolVar(&c.send, "send", true, "Should we send emails, or just pretend to?")
 }
 
@@ -72,8 +567,30 @@ func (c *cronCmd) Arguments(f *flag.FlagSet) {
 //
 func (c *cronCmd) Execute(args []string) int {
 
-	// No argument?  That's a bug
-	if len(args) == 0 {
+	// Parse the outputs we're using.
+	outputs, err := parseOutputs(c.output)
+	if err == nil {
+		err = checkExec(outputs, c.execCommand, c.execFormat)
+	}
+	if err == nil && c.deliverHours != "" {
+		_, err = processor.ParseWindow(c.deliverHours)
+	}
+	if err == nil && c.verboseOnly != "" {
+		err = logger.Check(c.verboseOnly)
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+
+	// Do we need recipients?
+	needed := needRecipients(outputs, c.execFormat)
+
+	// Recipients may be given via the environment.
+	args = envRecipients(args)
+
+	// No argument?  That's a bug, unless we're not generating emails.
+	if len(args) == 0 && needed {
 		fmt.Printf("Usage: rss2email cron email1@example.com .. emailN@example.com\n")
 		return 1
 	}
@@ -82,7 +599,9 @@ func (c *cronCmd) Execute(args []string) int {
 	recipients := []string{}
 
 	// Save each argument away, checking it is fully-qualified.
-	for _, email := range args {
+	//
+	// Arguments may contain comma-separated lists of addresses.
+	for _, email := range emailer.SplitAddresses(args...) {
 		if strings.Contains(email, "@") {
 			recipients = append(recipients, email)
 		} else {
@@ -91,21 +610,91 @@ func (c *cronCmd) Execute(args []string) int {
 		}
 	}
 
+	// Only commas?  That's a bug too
+	if len(recipients) == 0 && needed {
+		fmt.Printf("Usage: rss2email cron [flags] email1 .. emailN\n")
+		return 1
+	}
+
+	// Ensure our time zone exists.
+	if _, err := emailer.Location(nil, c.timezone); err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+
+	// Parse our size limit, if any.
+	maxSize := 0
+	if c.maxSize != "" {
+		n, err := emailer.ParseSize(c.maxSize)
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+			return 1
+		}
+		maxSize = n
+	}
+
 	// Create the helper
 	p := processor.New()
 
-	// Setup the state
-	p.SetVerbose(c.verbose)
+	// Setup the state, showing our progress instead of messages if
+	// we're run by hand and nothing is verbose.
+	log := newLogger(outputs, c.verbose, c.verboseOnly)
+	if c.progress && !c.verbose && c.verboseOnly == "" {
+		if bar := terminalProgress(outputs); bar != nil {
+			log = logger.New(bar)
+			log.SetColour(bar.colour)
+			p.AddObserver(bar)
+		}
+	}
+	p.SetLogger(log)
+	p.SetFrom(c.from)
+	p.SetEnvelopeFrom(c.envelopeFrom)
+	p.SetCC(emailer.SplitAddresses(c.cc))
+	p.SetBCC(emailer.SplitAddresses(c.bcc))
+	p.SetMaxSize(maxSize)
+	p.SetStyle(c.style)
+	p.SetTimezone(c.timezone)
+	p.SetDateFormat(c.dateFormat)
+	p.SetFavicon(c.favicon)
+	p.SetUnread(c.unread)
+	p.SetArchive(c.archive)
+	p.SetHTMLArchive(c.htmlArchive)
+	p.SetOnly(splitFeeds(c.only))
+	p.SetDeliveryWindow(c.deliverHours)
+	p.SetTimeout(c.timeout)
+	p.SetArchiveDead(time.Duration(c.archiveDead) * 30 * 24 * time.Hour)
+	p.SetCacheItems(time.Duration(c.cacheItems) * 24 * time.Hour)
+	p.SetMaxItems(c.maxItems)
+	p.SetOutputs(outputs)
+	p.SetExecCommand(c.execCommand)
+	p.SetExecFormat(c.execFormat)
+	p.SetFeedFile(c.feedFile)
+	p.SetFeedItems(c.feedItems)
 	p.SetSendEmail(c.send)
 
-	errors := p.ProcessFeeds(recipients)
+	// Export traces, if configured.
+	flush, err := setupTracing()
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+	defer flush()
+
+	// Stop gracefully upon SIGINT/SIGTERM.
+	ctx, done := signalContext()
+	defer done()
+
+	errors := p.ProcessFeeds(ctx, recipients)
+
+	// Report upon the run, if we should.
+	err = reportSummary(p.Summary(), c.summary, outputs, c.summaryTo, c.from)
+	if err != nil {
+		errors = append(errors, err)
+	}
 
 	// If we found errors then show them.
 	if len(errors) > 0 {
-		for _, err := range errors {
-			fmt.Fprintln(os.Stderr, err.Error())
-		}
-
+		showErrors(errors, outputs)
 		return 1
 	}
 
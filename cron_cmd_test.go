@@ -1,6 +1,7 @@
 package main
 
 import (
+	"strings"
 	"testing"
 )
 
@@ -23,3 +24,57 @@ func TestCronNotEmails(t *testing.T) {
 		t.Fatalf("Expected error when called with non-email addresses")
 	}
 }
+
+func TestCronOutputs(t *testing.T) {
+
+	tests := []struct {
+		value   string
+		outputs []string
+		valid   bool
+	}{
+		{"email", []string{"email"}, true},
+		{"jsonl", []string{"jsonl"}, true},
+		{"email, jsonl", []string{"email", "jsonl"}, true},
+		{"", []string{"email"}, true},
+		{"carrier-pigeon", nil, false},
+	}
+
+	for _, test := range tests {
+		outputs, err := parseOutputs(test.value)
+		if test.valid != (err == nil) {
+			t.Fatalf("%q: unexpected error result %v", test.value, err)
+		}
+		if strings.Join(outputs, ",") != strings.Join(test.outputs, ",") {
+			t.Fatalf("%q: unexpected outputs %v", test.value, outputs)
+		}
+	}
+
+	// An unknown output is an error
+	c := cronCmd{output: "carrier-pigeon"}
+	if c.Execute([]string{"foo@example.com"}) != 1 {
+		t.Fatalf("expected error with an unknown output")
+	}
+}
+
+func TestCronExec(t *testing.T) {
+
+	if checkExec([]string{"email"}, "", "bogus") != nil {
+		t.Fatalf("exec settings should be ignored without the exec output")
+	}
+	if checkExec([]string{"exec"}, "", "message") == nil {
+		t.Fatalf("expected error without a command")
+	}
+	if checkExec([]string{"exec"}, "cat", "bogus") == nil {
+		t.Fatalf("expected error with an unknown format")
+	}
+	if checkExec([]string{"exec"}, "cat", "json") != nil {
+		t.Fatalf("unexpected error with valid settings")
+	}
+
+	if !needRecipients([]string{"exec"}, "message") {
+		t.Fatalf("rendered messages need recipients")
+	}
+	if needRecipients([]string{"exec", "jsonl"}, "json") {
+		t.Fatalf("JSON doesn't need recipients")
+	}
+}
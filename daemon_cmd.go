@@ -7,12 +7,17 @@ package main
 import (
 	"flag"
 	"fmt"
+	"net"
+	"net/http"
 	"os"
 	"strconv"
 	"strings"
 	"time"
 
+	"github.com/skx/rss2email/aggregate"
+	"github.com/skx/rss2email/logger"
 	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/processor/emailer"
 )
 
 // Structure for our options and state.
@@ -20,6 +25,84 @@ type daemonCmd struct {
 
 	// Should we be verbose in operation?
 	verbose bool
+
+	// The components which should be verbose, if not all of them.
+	verboseOnly string
+
+	// The address to use in the From: header.
+	from string
+
+	// The envelope sender to pass to the MTA.
+	envelopeFrom string
+
+	// Comma-separated addresses to copy upon each email.
+	cc string
+
+	// Comma-separated addresses to blind-copy upon each email.
+	bcc string
+
+	// The maximum size of an email body, such as "512k".
+	maxSize string
+
+	// The name of the embedded template to use.
+	style string
+
+	// The time zone, and layout, of the dates shown within emails.
+	timezone   string
+	dateFormat string
+
+	// Should we embed the icon of each feed?
+	favicon bool
+
+	// Should we process the unread items of a feed-reader?
+	unread bool
+
+	// Should we archive the items we process?
+	archive bool
+
+	// The directory to write a static HTML archive to.
+	htmlArchive string
+
+	// Comma-separated outputs to send new items to.
+	output string
+
+	// The command to pipe new items to, for the "exec" output.
+	execCommand string
+
+	// The format of the items piped to that command.
+	execFormat string
+
+	// The path of the Atom feed written by the "feed" output, and the
+	// number of items it holds.
+	feedFile  string
+	feedItems int
+
+	// The address to serve that feed upon, if any.
+	feedListen string
+
+	// Should we show a summary of each run?
+	summary bool
+
+	// Comma-separated addresses to email the summary to.
+	summaryTo string
+
+	// Comma-separated URLs, or names, of the feeds to process.
+	only string
+
+	// The hours within which emails may be delivered, "HH:MM-HH:MM".
+	deliverHours string
+
+	// The time within which each run must complete.
+	timeout time.Duration
+
+	// The number of months after which dead feeds are archived.
+	archiveDead int
+
+	// The number of days for which the content of sent items is kept.
+	cacheItems int
+
+	// The maximum number of items to send in each run.
+	maxItems int
 }
 
 // Info is part of the subcommand-API.
@@ -39,6 +122,21 @@ In terms of implementation this command follows everything documented
 in the 'cron' sub-command.  The only difference is this one never
 terminates - even if email-generation fails.
 
+Feeds may be given their own schedule via the 'cron' option, which holds
+a standard five-field cron expression.  Such feeds are only checked by
+the first run after the expression fires, for example a feed of job
+adverts might be checked upon weekday mornings:
+
+    https://example.com/jobs.rss
+     - cron: 0 8 * * MON-FRI
+
+Feeds without a schedule are checked upon every run.
+
+When the 'feed' output is used the Atom feed it writes may also be served
+via HTTP, upon the address given via '-feed-listen', so that other tools
+may poll it:
+
+    $ rss2email daemon -output=email,feed -feed-listen=127.0.0.1:8080 user@example.com
 
 Example:
 
@@ -49,6 +147,33 @@ Example:
 // Arguments handles our flag-setup.
 func (d *daemonCmd) Arguments(f *flag.FlagSet) {
 	f.BoolVar(&d.verbose, "verbose", false, "Should we be extra verbose?")
+	f.StringVar(&d.verboseOnly, "verbose-only", "", "Comma-separated list of the components to be verbose for, from \"process\", \"fetch\", and \"send\".")
+	f.StringVar(&d.from, "from", "", "The address to use in the From: header of the emails we send.")
+	f.StringVar(&d.envelopeFrom, "envelope-from", "", "The envelope sender to use when delivering emails.")
+	f.StringVar(&d.cc, "cc", "", "Comma-separated list of addresses to copy upon each email.")
+	f.StringVar(&d.bcc, "bcc", "", "Comma-separated list of addresses to blind-copy upon each email.")
+	f.StringVar(&d.style, "style", "plain", "The embedded template to use, \"plain\" or \"styled\".")
+	f.StringVar(&d.timezone, "timezone", "", "The time zone in which dates are shown within emails, such as \"Europe/Madrid\", rather than the local one.")
+	f.StringVar(&d.dateFormat, "date-format", emailer.DefaultDateFormat, "The layout of the dates shown within emails, as used by Go's time package.")
+	f.BoolVar(&d.favicon, "favicon", false, "Embed the icon of each feed within the emails we send?")
+	f.BoolVar(&d.archive, "archive", false, "Store the items we send within an archive, which may be searched later?")
+	f.StringVar(&d.output, "output", "email", "Comma-separated list of outputs for new items, \"email\", \"jsonl\", \"exec\", and/or \"feed\".")
+	f.StringVar(&d.execCommand, "exec", "", "The command to pipe new items to, for the \"exec\" output.")
+	f.StringVar(&d.execFormat, "exec-format", "message", "The format of the items piped to that command, \"message\" or \"json\".")
+	f.StringVar(&d.feedFile, "feed-file", "", "The path of the Atom feed written by the \"feed\" output, rather than ~/.rss2email/river.atom.")
+	f.IntVar(&d.feedItems, "feed-items", aggregate.DefaultLimit, "The number of items held by the Atom feed written by the \"feed\" output.")
+	f.StringVar(&d.feedListen, "feed-listen", "", "The address to serve the Atom feed written by the \"feed\" output upon, e.g. \"127.0.0.1:8080\".")
+	f.BoolVar(&d.summary, "summary", false, "Show a summary at the end of each run?")
+	f.StringVar(&d.summaryTo, "summary-to", "", "Comma-separated list of addresses to email the summary of each run to.")
+	f.StringVar(&d.only, "only", "", "Comma-separated list of the feeds to process, by URL or name, rather than all of them.")
+	f.IntVar(&d.archiveDead, "archive-dead", 0, "Archive feeds which have had no new items, or have failed every fetch, for this many months.")
+	f.IntVar(&d.cacheItems, "cache-items", 0, "Keep the content of the items we send for this many days, so they may be sent again via 'rerender'.")
+	f.IntVar(&d.maxItems, "max-items", 0, "The maximum number of items to send in each run, e.g. 200, after which the remaining new items are left unseen.")
+	f.DurationVar(&d.timeout, "timeout", 0, "The time within which each run must complete, e.g. \"10m\", after which the remaining feeds are skipped.")
+	f.StringVar(&d.deliverHours, "deliver-hours", "", "Only deliver emails between these local times, e.g. \"08:00-22:00\", queueing items discovered outside them.")
+	f.StringVar(&d.htmlArchive, "html-archive", "", "Write a static HTML archive of the items we send to the given directory.")
+	f.BoolVar(&d.unread, "reader-unread", false, "Process the unread items of the configured feed-reader, rather than its subscriptions?")
+	f.StringVar(&d.maxSize, "max-size", "", "The maximum size of an email body, larger items are truncated (e.g. \"512k\").")
 }
 
 //
@@ -56,8 +181,33 @@ func (d *daemonCmd) Arguments(f *flag.FlagSet) {
 //
 func (d *daemonCmd) Execute(args []string) int {
 
-	// No argument?  That's a bug
-	if len(args) == 0 {
+	// Parse the outputs we're using.
+	outputs, err := parseOutputs(d.output)
+	if err == nil {
+		err = checkExec(outputs, d.execCommand, d.execFormat)
+	}
+	if err == nil && d.deliverHours != "" {
+		_, err = processor.ParseWindow(d.deliverHours)
+	}
+	if err == nil && d.verboseOnly != "" {
+		err = logger.Check(d.verboseOnly)
+	}
+	if err == nil && d.feedListen != "" && !hasOutput(outputs, "feed") {
+		err = fmt.Errorf("'-feed-listen' requires the \"feed\" output")
+	}
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+
+	// Do we need recipients?
+	needed := needRecipients(outputs, d.execFormat)
+
+	// Recipients may be given via the environment.
+	args = envRecipients(args)
+
+	// No argument?  That's a bug, unless we're not generating emails.
+	if len(args) == 0 && needed {
 		fmt.Printf("Usage: rss2email daemon email1@example.com .. emailN@example.com\n")
 		return 1
 	}
@@ -66,7 +216,9 @@ func (d *daemonCmd) Execute(args []string) int {
 	recipients := []string{}
 
 	// Save each argument away, checking it is fully-qualified.
-	for _, email := range args {
+	//
+	// Arguments may contain comma-separated lists of addresses.
+	for _, email := range emailer.SplitAddresses(args...) {
 		if strings.Contains(email, "@") {
 			recipients = append(recipients, email)
 		} else {
@@ -75,40 +227,139 @@ func (d *daemonCmd) Execute(args []string) int {
 		}
 	}
 
+	// Only commas?  That's a bug too
+	if len(recipients) == 0 && needed {
+		fmt.Printf("Usage: rss2email daemon [flags] email1 .. emailN\n")
+		return 1
+	}
+
+	// Ensure our time zone exists.
+	if _, err := emailer.Location(nil, d.timezone); err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+
+	// Parse our size limit, if any.
+	maxSize := 0
+	if d.maxSize != "" {
+		n, err := emailer.ParseSize(d.maxSize)
+		if err != nil {
+			fmt.Printf("%s\n", err.Error())
+			return 1
+		}
+		maxSize = n
+	}
+
+	// Export traces, if configured.
+	flush, err := setupTracing()
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+	defer flush()
+
+	// Stop gracefully upon SIGINT/SIGTERM.
+	ctx, done := signalContext()
+	defer done()
+
+	// Serve our feed, if we should.
+	if d.feedListen != "" {
+		path := d.feedFile
+		if path == "" {
+			path = aggregate.Path()
+		}
+
+		ln, err := net.Listen("tcp", d.feedListen)
+		if err != nil {
+			fmt.Printf("failed to serve the feed: %s\n", err.Error())
+			return 1
+		}
+		srv := &http.Server{Handler: aggregate.Handler(path)}
+		go srv.Serve(ln)
+		defer srv.Close()
+	}
+
+	// Default time to sleep - in minutes
+	n := 15
+
+	// Get the user's sleep period
+	sleep := os.Getenv("SLEEP")
+	if sleep != "" {
+		v, err := strconv.Atoi(sleep)
+		if err == nil {
+			n = v
+		}
+	}
+
+	// The time our previous run started, which is used to decide which
+	// feeds with a "cron" option are due.  For our first run we pretend
+	// we ran a single sleep-period ago.
+	lastRun := time.Now().Add(-60 * time.Duration(n) * time.Second)
+
+	// Our messages, and those of each run.
+	log := newLogger(outputs, d.verbose, d.verboseOnly)
+
 	for {
 
+		started := time.Now()
+
 		// Create the helper
 		p := processor.New()
 
 		// Setup the state - note we ALWAYS send emails in this mode.
-		p.SetVerbose(d.verbose)
+		p.SetLogger(log)
+		p.SetFrom(d.from)
+		p.SetEnvelopeFrom(d.envelopeFrom)
+		p.SetCC(emailer.SplitAddresses(d.cc))
+		p.SetBCC(emailer.SplitAddresses(d.bcc))
+		p.SetMaxSize(maxSize)
+		p.SetStyle(d.style)
+		p.SetTimezone(d.timezone)
+		p.SetDateFormat(d.dateFormat)
+		p.SetFavicon(d.favicon)
+		p.SetUnread(d.unread)
+		p.SetArchive(d.archive)
+		p.SetHTMLArchive(d.htmlArchive)
+		p.SetOnly(splitFeeds(d.only))
+		p.SetDeliveryWindow(d.deliverHours)
+		p.SetTimeout(d.timeout)
+		p.SetArchiveDead(time.Duration(d.archiveDead) * 30 * 24 * time.Hour)
+		p.SetCacheItems(time.Duration(d.cacheItems) * 24 * time.Hour)
+		p.SetMaxItems(d.maxItems)
+		p.SetLastRun(lastRun)
+		p.SetOutputs(outputs)
+		p.SetExecCommand(d.execCommand)
+		p.SetExecFormat(d.execFormat)
+		p.SetFeedFile(d.feedFile)
+		p.SetFeedItems(d.feedItems)
 		p.SetSendEmail(true)
 
-		errors := p.ProcessFeeds(recipients)
+		errors := p.ProcessFeeds(ctx, recipients)
+		lastRun = started
+
+		// Report upon the run, if we should.
+		err = reportSummary(p.Summary(), d.summary, outputs, d.summaryTo, d.from)
+		if err != nil {
+			errors = append(errors, err)
+		}
 
 		// If we found errors then show them.
 		if len(errors) > 0 {
-			for _, err := range errors {
-				fmt.Fprintln(os.Stderr, err.Error())
-			}
+			showErrors(errors, outputs)
 		}
 
-		// Default time to sleep - in minutes
-		n := 15
-
-		// Get the user's sleep period
-		sleep := os.Getenv("SLEEP")
-		if sleep != "" {
-			v, err := strconv.Atoi(sleep)
-			if err == nil {
-				n = v
-			}
+		// Stop if we've been interrupted.
+		if ctx.Err() != nil {
+			return 0
 		}
 
-		if d.verbose {
-			fmt.Printf("sleeping for %d minutes.\n", n)
+		log.Named("process").Infof("sleeping for %d minutes.", n)
+
+		// Sleep, unless interrupted.
+		select {
+		case <-ctx.Done():
+			return 0
+		case <-time.After(60 * time.Duration(n) * time.Second):
 		}
-		time.Sleep(60 * time.Duration(n) * time.Second)
 	}
-
 }
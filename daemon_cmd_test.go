@@ -23,3 +23,13 @@ func TestDaemonNotEmails(t *testing.T) {
 		t.Fatalf("Expected error when called with non-email addresses")
 	}
 }
+
+func TestDaemonFeedListen(t *testing.T) {
+
+	d := daemonCmd{output: "email", feedListen: "127.0.0.1:0"}
+
+	out := d.Execute([]string{"foo@example.com"})
+	if out != 1 {
+		t.Fatalf("Expected error when serving a feed without the feed output")
+	}
+}
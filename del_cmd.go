@@ -34,7 +34,8 @@ func (d *delCmd) Arguments(flags *flag.FlagSet) {
 func (d *delCmd) Info() (string, string) {
 	return "delete", `Remove a feed from our feed-list.
 
-Remove one or more specified URLs from the configuration file.
+Remove one or more specified URLs, or named feeds, from the configuration
+file.
 
 To see details of the configuration file, including the location,
 please run:
@@ -55,15 +56,24 @@ func (d *delCmd) Execute(args []string) int {
 	// Upgrade our configuration-file if necessary
 	d.config.Upgrade()
 
-	_, err := d.config.Parse()
+	entries, err := d.config.Parse()
 	if err != nil {
 		fmt.Printf("Error parsing file: %s\n", err.Error())
 		return 1
 	}
 
 	// For each argument remove it from the list, if present.
-	for _, entry := range args {
-		d.config.Delete(entry)
+	//
+	// Feeds may be given by name, as well as by URL.
+	for _, arg := range args {
+		found := configfile.Find(entries, arg)
+		if len(found) > 1 {
+			fmt.Printf("'%s' matches more than one feed, please be more specific\n", arg)
+			return 1
+		}
+		for _, entry := range found {
+			d.config.Delete(entry.URL)
+		}
 	}
 
 	// Save the list.
@@ -1,7 +1,6 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
 	"testing"
 
@@ -18,7 +17,7 @@ https://example.net/
  - foo: bar
 `
 	data := []byte(content)
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("Error creating temporary file")
 	}
@@ -58,3 +57,31 @@ https://example.net/
 
 	os.Remove(tmpfile.Name())
 }
+
+func TestDelName(t *testing.T) {
+
+	tmpfile, err := os.CreateTemp("", "example")
+	if err != nil {
+		t.Fatalf("Error creating temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpfile.Write([]byte(`name="Ars Technica" https://example.org/
+https://example.net/
+ - name: Example
+`))
+	tmpfile.Close()
+
+	del := delCmd{config: configfile.NewWithPath(tmpfile.Name())}
+	if del.Execute([]string{"ars"}) != 0 {
+		t.Fatalf("failed to delete by name")
+	}
+
+	entries, err := configfile.NewWithPath(tmpfile.Name()).Parse()
+	if err != nil {
+		t.Fatalf("Error parsing written file")
+	}
+	if len(entries) != 1 || entries[0].URL != "https://example.net/" {
+		t.Fatalf("Wrong item deleted: %v", entries)
+	}
+}
@@ -0,0 +1,101 @@
+//
+// Allow our flags to be set via environmental variables.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/subcommands"
+)
+
+// envName returns the name of the environmental variable which may set
+// the given flag, of the given sub-command, or of the global flags if
+// the command is empty.
+//
+// For example the "-state-dir" flag may be set via $RSS2EMAIL_STATE_DIR,
+// and the "-from" flag of the cron sub-command via $RSS2EMAIL_CRON_FROM.
+func envName(command string, name string) string {
+
+	if command != "" {
+		name = command + "-" + name
+	}
+	return "RSS2EMAIL_" + strings.ToUpper(strings.ReplaceAll(name, "-", "_"))
+}
+
+// envFlags sets the flags in the given set from the environment, so that
+// they become the defaults which the command-line may then override.
+//
+// The flags of a sub-command may be set via a variable naming the command,
+// or via a variable which doesn't, which applies to every sub-command
+// with such a flag, such as both "cron" and "daemon".
+func envFlags(fs *flag.FlagSet, command string) error {
+
+	var err error
+	fs.VisitAll(func(f *flag.Flag) {
+
+		if err != nil {
+			return
+		}
+
+		names := []string{envName(command, f.Name)}
+		if command != "" {
+			names = append(names, envName("", f.Name))
+		}
+
+		for _, name := range names {
+			val, ok := os.LookupEnv(name)
+			if !ok {
+				continue
+			}
+			if serr := fs.Set(f.Name, val); serr != nil {
+				err = fmt.Errorf("invalid value %q for $%s: %s", val, name, serr)
+			}
+			return
+		}
+	})
+	return err
+}
+
+// envRecipients returns the given recipients, or if there are none, the
+// recipients given via $RSS2EMAIL_RECIPIENTS.
+func envRecipients(args []string) []string {
+
+	if len(args) > 0 {
+		return args
+	}
+	return emailer.SplitAddresses(os.Getenv("RSS2EMAIL_RECIPIENTS"))
+}
+
+// envCommand wraps a sub-command, so that its flags may be set from the
+// environment.
+type envCommand struct {
+	subcommands.Subcommand
+
+	// err holds any error found whilst setting our flags.
+	err error
+}
+
+// Arguments is part of the subcommand-API.
+func (e *envCommand) Arguments(fs *flag.FlagSet) {
+
+	e.Subcommand.Arguments(fs)
+
+	name, _ := e.Info()
+	e.err = envFlags(fs, name)
+}
+
+// Execute is part of the subcommand-API.
+func (e *envCommand) Execute(args []string) int {
+
+	if e.err != nil {
+		fmt.Printf("%s\n", e.err.Error())
+		return 1
+	}
+	return e.Subcommand.Execute(args)
+}
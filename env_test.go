@@ -0,0 +1,99 @@
+package main
+
+import (
+	"flag"
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestEnvFlags(t *testing.T) {
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	from := fs.String("from", "", "")
+	verbose := fs.Bool("verbose", false, "")
+	timeout := fs.Duration("timeout", 0, "")
+	style := fs.String("style", "plain", "")
+
+	os.Setenv("RSS2EMAIL_FROM", "generic@example.com")
+	os.Setenv("RSS2EMAIL_CRON_FROM", "cron@example.com")
+	os.Setenv("RSS2EMAIL_VERBOSE", "true")
+	os.Setenv("RSS2EMAIL_CRON_TIMEOUT", "5m")
+	defer func() {
+		for _, name := range []string{"RSS2EMAIL_FROM", "RSS2EMAIL_CRON_FROM", "RSS2EMAIL_VERBOSE", "RSS2EMAIL_CRON_TIMEOUT"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	err := envFlags(fs, "cron")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// The command-line overrides the environment.
+	err = fs.Parse([]string{"-timeout", "1m", "user@example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if *from != "cron@example.com" || !*verbose || *timeout != time.Minute || *style != "plain" {
+		t.Fatalf("unexpected flags: %s %v %s %s", *from, *verbose, *timeout, *style)
+	}
+
+	// Bogus values are reported.
+	os.Setenv("RSS2EMAIL_VERBOSE", "sometimes")
+	err = envFlags(flag.NewFlagSet("test", flag.ContinueOnError), "")
+	if err != nil {
+		t.Fatalf("unexpected error with no flags: %s", err)
+	}
+	err = envFlags(fs, "cron")
+	if err == nil || !strings.Contains(err.Error(), "$RSS2EMAIL_VERBOSE") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEnvGlobalFlags(t *testing.T) {
+
+	defer configfile.SetStateDirectory("")
+
+	dir := t.TempDir()
+	os.Setenv("RSS2EMAIL_STATE_DIR", dir)
+	defer os.Unsetenv("RSS2EMAIL_STATE_DIR")
+
+	_, err := globalFlags([]string{"list"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if configfile.New().StateDirectory() != dir {
+		t.Fatalf("state directory not set from the environment")
+	}
+}
+
+func TestEnvRecipients(t *testing.T) {
+
+	os.Setenv("RSS2EMAIL_RECIPIENTS", "a@example.com, b@example.com")
+	defer os.Unsetenv("RSS2EMAIL_RECIPIENTS")
+
+	if got := envRecipients(nil); strings.Join(got, " ") != "a@example.com b@example.com" {
+		t.Fatalf("unexpected recipients: %v", got)
+	}
+	if got := envRecipients([]string{"c@example.com"}); strings.Join(got, " ") != "c@example.com" {
+		t.Fatalf("unexpected recipients: %v", got)
+	}
+}
+
+func TestEnvCommand(t *testing.T) {
+
+	os.Setenv("RSS2EMAIL_LINT_TIMEOUT", "never")
+	defer os.Unsetenv("RSS2EMAIL_LINT_TIMEOUT")
+
+	cmd := &envCommand{Subcommand: &lintCmd{}}
+	cmd.Arguments(flag.NewFlagSet("lint", flag.ContinueOnError))
+
+	if cmd.Execute(nil) != 1 || cmd.err == nil || !strings.Contains(cmd.err.Error(), "$RSS2EMAIL_LINT_TIMEOUT") {
+		t.Fatalf("expected failure with a bogus timeout: %v", cmd.err)
+	}
+}
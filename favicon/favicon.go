@@ -0,0 +1,182 @@
+// Package favicon fetches the icon associated with a feed, so that it
+// may be embedded within the emails we send.
+//
+// We prefer the image which the feed itself advertises, falling back
+// to the `/favicon.ico` file of the site the feed belongs to.
+//
+// Icons are cached upon the local filesystem, to avoid fetching them
+// every time we run.  Failures are cached too, so that sites without
+// an icon don't cost us a request upon every run.
+package favicon
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// maxSize is the largest icon we're willing to embed.
+const maxSize = 100 * 1024
+
+// Icon holds the content of an icon.
+type Icon struct {
+
+	// ContentType holds the MIME-type of the image.
+	ContentType string
+
+	// Data holds the image itself.
+	Data []byte
+}
+
+// Favicon is our state-storing structure
+type Favicon struct {
+
+	// dir is the directory in which we cache icons.
+	dir string
+
+	// ttl is the length of time for which cached icons are used.
+	ttl time.Duration
+
+	// userAgent is the User-Agent header to send when fetching icons.
+	userAgent string
+}
+
+// New creates a new object, which caches icons beneath our configuration
+// directory.
+func New() *Favicon {
+	return &Favicon{
+		dir:       filepath.Join(configfile.New().StateDirectory(), "favicons"),
+		ttl:       7 * 24 * time.Hour,
+		userAgent: "rss2email (https://github.com/skx/rss2email)",
+	}
+}
+
+// SetUserAgent updates the User-Agent header we send when fetching icons.
+func (f *Favicon) SetUserAgent(agent string) {
+	f.userAgent = agent
+}
+
+// Source returns the URL of the icon we'd use for the given feed, or the
+// empty string if we cannot determine one.
+func Source(feed *gofeed.Feed) string {
+
+	if feed.Image != nil && feed.Image.URL != "" {
+		return feed.Image.URL
+	}
+
+	if feed.Link == "" {
+		return ""
+	}
+
+	u, err := url.Parse(feed.Link)
+	if err != nil || u.Host == "" {
+		return ""
+	}
+
+	return u.Scheme + "://" + u.Host + "/favicon.ico"
+}
+
+// Get returns the icon for the given feed.
+//
+// A nil icon, with no error, is returned if the feed has no icon.
+func (f *Favicon) Get(feed *gofeed.Feed) (*Icon, error) {
+
+	src := Source(feed)
+	if src == "" {
+		return nil, nil
+	}
+
+	// Look for a cached copy
+	path := filepath.Join(f.dir, fmt.Sprintf("%x", sha1.Sum([]byte(src))))
+
+	data, err := f.cached(path)
+	if err != nil {
+
+		// Not present, or expired, so fetch it.
+		data, err = f.fetch(src)
+
+		// Cache failures too, as an empty file.
+		os.MkdirAll(f.dir, os.ModePerm)
+		_ = os.WriteFile(path, data, 0644)
+
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return newIcon(data), nil
+}
+
+// cached returns the content of the cached icon, if it exists and has
+// not expired.
+func (f *Favicon) cached(path string) ([]byte, error) {
+
+	fi, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+	if time.Since(fi.ModTime()) > f.ttl {
+		return nil, fmt.Errorf("%s has expired", path)
+	}
+
+	return os.ReadFile(path)
+}
+
+// fetch retrieves the given icon from the remote server.
+func (f *Favicon) fetch(src string) ([]byte, error) {
+
+	client := &http.Client{Timeout: 10 * time.Second}
+	req, err := http.NewRequest("GET", src, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", f.userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetching %s returned status %d", src, resp.StatusCode)
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, maxSize+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > maxSize {
+		return nil, fmt.Errorf("icon %s is too large", src)
+	}
+	if newIcon(data) == nil {
+		return nil, fmt.Errorf("%s is not an image", src)
+	}
+
+	return data, nil
+}
+
+// newIcon creates an icon from the given data, returning nil if the data
+// does not appear to be an image.
+func newIcon(data []byte) *Icon {
+
+	if len(data) == 0 {
+		return nil
+	}
+
+	ctype := http.DetectContentType(data)
+	if !strings.HasPrefix(ctype, "image/") {
+		return nil
+	}
+
+	return &Icon{ContentType: ctype, Data: data}
+}
@@ -0,0 +1,98 @@
+package favicon
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// A minimal GIF image.
+var gif = []byte("GIF89a\x01\x00\x01\x00\x80\x00\x00\x00\x00\x00\xff\xff\xff!\xf9\x04\x01\x00\x00\x00\x00,\x00\x00\x00\x00\x01\x00\x01\x00\x00\x02\x02D\x01\x00;")
+
+// TestSource ensures we find the right icon URL
+func TestSource(t *testing.T) {
+
+	feed := &gofeed.Feed{Link: "https://example.com/blog/"}
+	if Source(feed) != "https://example.com/favicon.ico" {
+		t.Fatalf("unexpected source: %s", Source(feed))
+	}
+
+	feed.Image = &gofeed.Image{URL: "https://example.com/logo.png"}
+	if Source(feed) != "https://example.com/logo.png" {
+		t.Fatalf("unexpected source: %s", Source(feed))
+	}
+
+	feed = &gofeed.Feed{}
+	if Source(feed) != "" {
+		t.Fatalf("unexpected source: %s", Source(feed))
+	}
+}
+
+// TestGet fetches an icon, and ensures it is cached.
+func TestGet(t *testing.T) {
+
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		if r.URL.Path == "/favicon.ico" {
+			w.Write(gif)
+			return
+		}
+		http.NotFound(w, r)
+	}))
+	defer ts.Close()
+
+	f := New()
+	f.dir = t.TempDir()
+
+	// Fetch twice, the second should be cached.
+	for i := 0; i < 2; i++ {
+		icon, err := f.Get(&gofeed.Feed{Link: ts.URL + "/blog"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if icon == nil || icon.ContentType != "image/gif" {
+			t.Fatalf("unexpected icon: %v", icon)
+		}
+	}
+	if count != 1 {
+		t.Fatalf("icon was fetched %d times", count)
+	}
+
+	// A missing icon is an error, the first time.
+	feed := &gofeed.Feed{Image: &gofeed.Image{URL: ts.URL + "/missing.png"}}
+	_, err := f.Get(feed)
+	if err == nil {
+		t.Fatalf("expected an error with a missing icon")
+	}
+
+	// Thereafter it is cached as missing.
+	icon, err := f.Get(feed)
+	if icon != nil || err != nil {
+		t.Fatalf("expected a cached failure, got %v %v", icon, err)
+	}
+	if count != 2 {
+		t.Fatalf("icon was fetched %d times", count)
+	}
+
+	// Until the cache expires.
+	f.ttl = time.Duration(0)
+	f.Get(feed)
+	if count != 3 {
+		t.Fatalf("icon was fetched %d times", count)
+	}
+}
+
+// TestNotImage ensures that we don't accept non-images
+func TestNotImage(t *testing.T) {
+
+	if newIcon([]byte("<html><body>Hello</body></html>")) != nil {
+		t.Fatalf("HTML was regarded as an image")
+	}
+	if newIcon(gif) == nil {
+		t.Fatalf("GIF was not regarded as an image")
+	}
+}
@@ -0,0 +1,122 @@
+// Package fetch retrieves feeds, via the Fetcher registered for the scheme
+// of their URL.
+//
+// Feeds are usually fetched via HTTP, by the httpfetch package, but they
+// may also be read from local files, from the output of commands, from
+// Gemini capsules, or provided by plugins:
+//
+//	https://blog.steve.fi/index.rss
+//	file:///home/steve/feeds/local.xml
+//	exec:/usr/local/bin/generate-feed --since yesterday
+//	gemini://gemini.example.com/atom.xml
+//	plugin:/usr/local/bin/mastodon-source --account steve
+//
+// Further schemes may be added via Register, which also allows fake
+// fetchers to be used when testing.
+package fetch
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/bridge"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/httpfetch"
+	"github.com/skx/rss2email/logger"
+)
+
+// Validators hold the values which allow a feed to be fetched only if it
+// has changed, for the fetchers which support that.
+type Validators = httpfetch.Validators
+
+// Fetcher is the interface to the retrieval of a single feed.
+type Fetcher interface {
+
+	// FetchContext retrieves, and parses, the feed.
+	FetchContext(ctx context.Context) (*gofeed.Feed, error)
+
+	// FinalURL returns the URL from which the feed was retrieved,
+	// after following any redirects, or the empty string if it hasn't
+	// been retrieved.
+	FinalURL() string
+
+	// SetLogger sets the logger which receives our messages.
+	SetLogger(l logger.Logger)
+
+	// SetValidators sets the validators returned when the feed was
+	// last fetched, so that it is only retrieved if it has changed.
+	SetValidators(v Validators)
+
+	// Validators returns the validators of the feed we retrieved.
+	Validators() Validators
+
+	// NotModified returns true if the feed hadn't changed since it
+	// was last fetched, in which case it has no items.
+	NotModified() bool
+
+	// BogusDates returns the number of items whose implausible dates
+	// were removed.
+	BogusDates() int
+}
+
+// Driver creates the Fetcher for the given feed.
+type Driver func(entry configfile.Feed) (Fetcher, error)
+
+// drivers holds the registered drivers, by scheme.
+var drivers = make(map[string]Driver)
+
+// Register makes a driver available, for the feeds whose URLs have the
+// given scheme.  A later registration of the same scheme replaces the
+// first.
+func Register(scheme string, driver Driver) {
+	drivers[strings.ToLower(scheme)] = driver
+}
+
+func init() {
+	web := func(entry configfile.Feed) (Fetcher, error) {
+		return httpfetch.New(entry), nil
+	}
+	Register("http", web)
+	Register("https", web)
+	Register(strings.TrimSuffix(bridge.Prefix, ":"), web)
+
+	Register("file", newFile)
+	Register("exec", newExec)
+	Register("gemini", newGemini)
+	Register("plugin", newPlugin)
+}
+
+// Scheme returns the scheme of the given URL, in lower-case, or the empty
+// string if it has none.
+func Scheme(url string) string {
+	i := strings.Index(url, ":")
+	if i <= 0 {
+		return ""
+	}
+	return strings.ToLower(url[:i])
+}
+
+// Schemes returns the schemes for which drivers are registered, sorted.
+func Schemes() []string {
+
+	var known []string
+	for name := range drivers {
+		known = append(known, name)
+	}
+	sort.Strings(known)
+	return known
+}
+
+// New returns the Fetcher for the given feed, via the driver registered
+// for the scheme of its URL.
+func New(entry configfile.Feed) (Fetcher, error) {
+
+	driver, ok := drivers[Scheme(entry.URL)]
+	if !ok {
+		return nil, fmt.Errorf("cannot fetch '%s', expected a URL with one of the schemes: %s", entry.URL, strings.Join(Schemes(), ", "))
+	}
+	return driver(entry)
+}
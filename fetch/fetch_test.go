@@ -0,0 +1,291 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/httpfetch"
+)
+
+// sample is a feed with a single item.
+const sample = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Sample</title>
+<item><title>First</title><link>https://example.com/first</link></item>
+</channel>
+</rss>`
+
+func TestScheme(t *testing.T) {
+
+	tests := map[string]string{
+		"https://example.com/":   "https",
+		"HTTP://example.com/":    "http",
+		"exec:generate-feed":     "exec",
+		"bridge:twitter:someone": "bridge",
+		"example.com/feed":       "",
+		":foo":                   "",
+	}
+	for in, out := range tests {
+		if got := Scheme(in); got != out {
+			t.Errorf("unexpected scheme of %s: %s != %s", in, got, out)
+		}
+	}
+}
+
+func TestNew(t *testing.T) {
+
+	// The schemes of the web are fetched via HTTP.
+	for _, uri := range []string{"https://example.com/", "http://example.com/", "bridge:twitter:someone"} {
+		f, err := New(configfile.Feed{URL: uri})
+		if err != nil {
+			t.Fatalf("failed to create fetcher for %s: %s", uri, err)
+		}
+		if _, ok := f.(*httpfetch.HTTPFetch); !ok {
+			t.Fatalf("unexpected fetcher for %s: %T", uri, f)
+		}
+	}
+
+	// Unknown schemes are reported.
+	for _, uri := range []string{"ftp://example.com/", "example.com/feed"} {
+		_, err := New(configfile.Feed{URL: uri})
+		if err == nil || !strings.Contains(err.Error(), "exec, file, gemini, http, https, plugin") {
+			t.Fatalf("expected error for %s, got %v", uri, err)
+		}
+	}
+
+	// Other schemes may be registered.
+	Register("test", func(entry configfile.Feed) (Fetcher, error) {
+		return &reader{url: entry.URL, read: func(ctx context.Context) ([]byte, error) {
+			return []byte(sample), nil
+		}}, nil
+	})
+	defer delete(drivers, "test")
+	if strings.Join(Schemes(), ",") != "bridge,exec,file,gemini,http,https,plugin,test" {
+		t.Fatalf("unexpected schemes: %v", Schemes())
+	}
+
+	f, err := New(configfile.Feed{URL: "TEST:sample"})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %s", err)
+	}
+	feed, err := f.FetchContext(context.Background())
+	if err != nil || len(feed.Items) != 1 || f.FinalURL() != "TEST:sample" {
+		t.Fatalf("unexpected result: %v %s", feed, err)
+	}
+}
+
+func TestFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "feed.xml")
+	err := os.WriteFile(path, []byte(sample), 0644)
+	if err != nil {
+		t.Fatalf("failed to write feed: %s", err)
+	}
+
+	for _, uri := range []string{"file://" + filepath.ToSlash(path), "file:" + filepath.ToSlash(path)} {
+		f, err := New(configfile.Feed{URL: uri})
+		if err != nil {
+			t.Fatalf("failed to create fetcher for %s: %s", uri, err)
+		}
+		if f.FinalURL() != "" {
+			t.Fatalf("unexpected final URL before fetching")
+		}
+		feed, err := f.FetchContext(context.Background())
+		if err != nil || len(feed.Items) != 1 || feed.Items[0].Title != "First" {
+			t.Fatalf("unexpected result for %s: %v %s", uri, feed, err)
+		}
+		if f.NotModified() || f.Validators() != (Validators{}) {
+			t.Fatalf("unexpected validators")
+		}
+	}
+
+	f, _ := New(configfile.Feed{URL: "file:///does/not/exist"})
+	if _, err = f.FetchContext(context.Background()); err == nil {
+		t.Fatalf("expected error with a missing file")
+	}
+	if _, err = New(configfile.Feed{URL: "file:"}); err == nil {
+		t.Fatalf("expected error without a file")
+	}
+}
+
+func TestExec(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the commands assume a unix shell")
+	}
+
+	path := filepath.Join(t.TempDir(), "feed.xml")
+	err := os.WriteFile(path, []byte(sample), 0644)
+	if err != nil {
+		t.Fatalf("failed to write feed: %s", err)
+	}
+
+	f, err := New(configfile.Feed{URL: "exec:cat " + path})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %s", err)
+	}
+	feed, err := f.FetchContext(context.Background())
+	if err != nil || len(feed.Items) != 1 {
+		t.Fatalf("unexpected result: %v %s", feed, err)
+	}
+
+	// Failures include what the command said.
+	f, _ = New(configfile.Feed{URL: "exec:echo broken >&2; exit 3"})
+	_, err = f.FetchContext(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	// Output which isn't a feed is reported.
+	f, _ = New(configfile.Feed{URL: "exec:echo hello"})
+	if _, err = f.FetchContext(context.Background()); err == nil {
+		t.Fatalf("expected error parsing output")
+	}
+
+	if _, err = New(configfile.Feed{URL: "exec: "}); err == nil {
+		t.Fatalf("expected error without a command")
+	}
+}
+
+func TestPlugin(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins assume a unix shell")
+	}
+
+	f, err := New(configfile.Feed{URL: `plugin:echo '{"title": "Plugin", "items": [{"title": "First", "link": "https://example.com/first"}]}'`})
+	if err != nil {
+		t.Fatalf("failed to create fetcher: %s", err)
+	}
+	feed, err := f.FetchContext(context.Background())
+	if err != nil || feed.Title != "Plugin" || len(feed.Items) != 1 || feed.Items[0].Link != "https://example.com/first" {
+		t.Fatalf("unexpected result: %v %s", feed, err)
+	}
+
+	f, _ = New(configfile.Feed{URL: "plugin:exit 2"})
+	if _, err = f.FetchContext(context.Background()); err == nil || !strings.Contains(err.Error(), "source plugin 'exit 2' failed") {
+		t.Fatalf("expected error, got %v", err)
+	}
+
+	if _, err = New(configfile.Feed{URL: "plugin:"}); err == nil {
+		t.Fatalf("expected error without a plugin")
+	}
+}
+
+// startGemini starts a Gemini server, which responds to each request via
+// the given function, returning its address.
+func startGemini(t *testing.T, respond func(uri string) string) string {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatalf("failed to generate key: %s", err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: "localhost"},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	if err != nil {
+		t.Fatalf("failed to create certificate: %s", err)
+	}
+
+	l, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{
+		Certificates: []tls.Certificate{{Certificate: [][]byte{der}, PrivateKey: key}},
+	})
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+				line, err := bufio.NewReader(conn).ReadString('\n')
+				if err != nil {
+					return
+				}
+				conn.Write([]byte(respond(strings.TrimRight(line, "\r\n"))))
+			}(conn)
+		}
+	}()
+	return l.Addr().String()
+}
+
+func TestGemini(t *testing.T) {
+
+	var addr string
+	addr = startGemini(t, func(uri string) string {
+		switch uri {
+		case "gemini://" + addr + "/atom.xml":
+			return "20 application/rss+xml\r\n" + sample
+		case "gemini://" + addr + "/old.xml":
+			return "31 /atom.xml\r\n"
+		case "gemini://" + addr + "/loop.xml":
+			return "30 /loop.xml\r\n"
+		case "gemini://" + addr + "/garbage":
+			return "hello\r\n"
+		}
+		return "51 Not found\r\n"
+	})
+
+	fetch := func(path string) (*gofeed.Feed, error) {
+		f, err := New(configfile.Feed{URL: "gemini://" + addr + path})
+		if err != nil {
+			t.Fatalf("failed to create fetcher: %s", err)
+		}
+		return f.FetchContext(context.Background())
+	}
+
+	for _, path := range []string{"/atom.xml", "/old.xml"} {
+		feed, err := fetch(path)
+		if err != nil || len(feed.Items) != 1 {
+			t.Fatalf("unexpected result for %s: %v %s", path, feed, err)
+		}
+	}
+
+	errors := map[string]string{
+		"/missing":  "returned status 51: Not found",
+		"/loop.xml": "redirected too many times",
+		"/garbage":  "invalid response 'hello'",
+	}
+	for path, msg := range errors {
+		_, err := fetch(path)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected error %s for %s, got %v", msg, path, err)
+		}
+	}
+
+	// Nobody is listening.
+	l, _ := net.Listen("tcp", "127.0.0.1:0")
+	closed := l.Addr().String()
+	l.Close()
+	f, _ := New(configfile.Feed{URL: fmt.Sprintf("gemini://%s/atom.xml", closed)})
+	if _, err := f.FetchContext(context.Background()); err == nil {
+		t.Fatalf("expected error connecting")
+	}
+}
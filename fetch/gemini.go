@@ -0,0 +1,128 @@
+package fetch
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/logger"
+)
+
+// geminiTimeout is the time we'll wait for a Gemini capsule to respond.
+const geminiTimeout = 60 * time.Second
+
+// geminiRedirects is the number of redirects we'll follow.
+const geminiRedirects = 5
+
+// geminiMaxSize is the size of the largest feed we'll read.
+const geminiMaxSize = 16 * 1024 * 1024
+
+// newGemini creates a fetcher which requests the feed from a Gemini
+// capsule, via a URL such as "gemini://gemini.example.com/atom.xml".
+//
+// Only Atom, RSS, and JSON feeds are understood, not gemtext.
+func newGemini(entry configfile.Feed) (Fetcher, error) {
+
+	r := &reader{url: entry.URL, logger: logger.Discard}
+	r.read = func(ctx context.Context) ([]byte, error) {
+
+		uri := entry.URL
+		for i := 0; i <= geminiRedirects; i++ {
+			status, meta, body, err := gemini(ctx, uri)
+			if err != nil {
+				return nil, err
+			}
+
+			switch status[0] {
+			case '2':
+				return body, nil
+			case '3':
+				next, err := url.Parse(meta)
+				if err != nil {
+					return nil, fmt.Errorf("%s redirected to '%s': %s", uri, meta, err)
+				}
+				base, _ := url.Parse(uri)
+				uri = base.ResolveReference(next).String()
+				r.logger.Infof("\tRedirected to %s\n", uri)
+			default:
+				return nil, fmt.Errorf("%s returned status %s: %s", uri, status, meta)
+			}
+		}
+		return nil, fmt.Errorf("%s redirected too many times", entry.URL)
+	}
+	return r, nil
+}
+
+// gemini makes a single request for the given URL, returning the status,
+// and meta-data, of the response, and its body if it succeeded.
+func gemini(ctx context.Context, uri string) (string, string, []byte, error) {
+
+	u, err := url.Parse(uri)
+	if err != nil {
+		return "", "", nil, err
+	}
+	if u.Scheme != "gemini" {
+		return "", "", nil, fmt.Errorf("cannot fetch '%s' via gemini", uri)
+	}
+	addr := u.Host
+	if u.Port() == "" {
+		addr = net.JoinHostPort(u.Hostname(), "1965")
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, geminiTimeout)
+	defer cancel()
+
+	// Capsules almost always use self-signed certificates, which
+	// clients are expected to trust upon first use, so they aren't
+	// verified.
+	dialer := &tls.Dialer{Config: &tls.Config{
+		ServerName:         u.Hostname(),
+		InsecureSkipVerify: true,
+		MinVersion:         tls.VersionTLS12,
+	}}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return "", "", nil, err
+	}
+	defer conn.Close()
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	_, err = fmt.Fprintf(conn, "%s\r\n", uri)
+	if err != nil {
+		return "", "", nil, err
+	}
+
+	rd := bufio.NewReader(io.LimitReader(conn, geminiMaxSize))
+	header, err := rd.ReadString('\n')
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to read the response from %s: %s", uri, err)
+	}
+
+	header = strings.TrimRight(header, "\r\n")
+	status, meta := header, ""
+	if i := strings.IndexAny(header, " \t"); i >= 0 {
+		status, meta = header[:i], strings.TrimSpace(header[i+1:])
+	}
+	if len(status) != 2 || status[0] < '1' || status[0] > '6' {
+		return "", "", nil, fmt.Errorf("%s returned an invalid response '%s'", uri, header)
+	}
+	if status[0] != '2' {
+		return status, meta, nil, nil
+	}
+
+	body, err := io.ReadAll(rd)
+	if err != nil {
+		return "", "", nil, fmt.Errorf("failed to read the response from %s: %s", uri, err)
+	}
+	return status, meta, body, nil
+}
@@ -0,0 +1,194 @@
+package fetch
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/httpfetch"
+	"github.com/skx/rss2email/logger"
+	"github.com/skx/rss2email/plugin"
+	"github.com/skx/rss2email/tracing"
+	"go.opentelemetry.io/otel/attribute"
+)
+
+// reader fetches feeds whose content is read in full, by the given
+// function, and which can't tell us whether they've changed.
+type reader struct {
+
+	// url is the URL of the feed, and read returns its content.
+	// Alternatively decode returns the feed itself, if it isn't read
+	// from a file.
+	url    string
+	read   func(ctx context.Context) ([]byte, error)
+	decode func(ctx context.Context) (*gofeed.Feed, error)
+
+	// fetched is set once the feed has been read.
+	fetched bool
+
+	// bogus is the number of items whose implausible dates were
+	// removed.
+	bogus int
+
+	// logger receives our messages.
+	logger logger.Logger
+}
+
+// FetchContext is part of the Fetcher interface.
+func (r *reader) FetchContext(ctx context.Context) (*gofeed.Feed, error) {
+
+	ctx, span := tracing.Start(ctx, "fetch", attribute.String("feed.url", r.url))
+
+	var feed *gofeed.Feed
+	if r.decode != nil {
+		var err error
+		feed, err = r.decode(ctx)
+		if err != nil {
+			tracing.End(span, err)
+			return nil, err
+		}
+	} else {
+		data, err := r.read(ctx)
+		if err != nil {
+			tracing.End(span, err)
+			return nil, err
+		}
+		span.SetAttributes(attribute.Int("fetch.bytes", len(data)))
+
+		feed, err = gofeed.NewParser().Parse(bytes.NewReader(data))
+		if err != nil {
+			err = fmt.Errorf("error parsing %s contents: %s", r.url, err.Error())
+			tracing.End(span, err)
+			return nil, err
+		}
+	}
+	tracing.End(span, nil)
+
+	r.fetched = true
+	r.bogus = httpfetch.SaneDates(feed)
+	return feed, nil
+}
+
+// FinalURL is part of the Fetcher interface.
+func (r *reader) FinalURL() string {
+	if !r.fetched {
+		return ""
+	}
+	return r.url
+}
+
+// SetLogger is part of the Fetcher interface.
+func (r *reader) SetLogger(l logger.Logger) {
+	r.logger = l
+}
+
+// SetValidators is part of the Fetcher interface.
+//
+// The feed is always read, so the validators are ignored.
+func (r *reader) SetValidators(v Validators) {
+}
+
+// Validators is part of the Fetcher interface.
+func (r *reader) Validators() Validators {
+	return Validators{}
+}
+
+// NotModified is part of the Fetcher interface.
+func (r *reader) NotModified() bool {
+	return false
+}
+
+// BogusDates is part of the Fetcher interface.
+func (r *reader) BogusDates() int {
+	return r.bogus
+}
+
+// newFile creates a fetcher which reads the feed from a local file, named
+// by a URL such as "file:///home/steve/feed.xml".
+func newFile(entry configfile.Feed) (Fetcher, error) {
+
+	u, err := url.Parse(entry.URL)
+	if err != nil {
+		return nil, err
+	}
+	path := u.Path
+	if path == "" {
+		path = u.Opaque
+	}
+	if path == "" {
+		return nil, fmt.Errorf("'%s' doesn't name a file", entry.URL)
+	}
+
+	return &reader{url: entry.URL, logger: logger.Discard, read: func(ctx context.Context) ([]byte, error) {
+		return os.ReadFile(path)
+	}}, nil
+}
+
+// newExec creates a fetcher which runs the command given by a URL such as
+// "exec:/usr/local/bin/generate-feed --verbose", via the shell, and reads
+// the feed from its output.
+func newExec(entry configfile.Feed) (Fetcher, error) {
+
+	command := strings.TrimSpace(entry.URL[len("exec:"):])
+	if command == "" {
+		return nil, fmt.Errorf("'%s' doesn't name a command", entry.URL)
+	}
+
+	r := &reader{url: entry.URL, logger: logger.Discard}
+	r.read = func(ctx context.Context) ([]byte, error) {
+
+		var cmd *exec.Cmd
+		if runtime.GOOS == "windows" {
+			cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+		} else {
+			cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+		}
+
+		stdout := &bytes.Buffer{}
+		stderr := &bytes.Buffer{}
+		cmd.Stdout = stdout
+		cmd.Stderr = stderr
+
+		err := cmd.Run()
+		if err != nil {
+			msg := strings.TrimSpace(stderr.String())
+			if msg != "" {
+				return nil, fmt.Errorf("command '%s' failed: %s: %s", command, err, msg)
+			}
+			return nil, fmt.Errorf("command '%s' failed: %s", command, err)
+		}
+
+		// Anything written to stderr is worth seeing.
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			r.logger.Infof("\tCommand '%s' said: %s\n", command, msg)
+		}
+		return stdout.Bytes(), nil
+	}
+	return r, nil
+}
+
+// newPlugin creates a fetcher which runs the source plugin given by a URL
+// such as "plugin:/usr/local/bin/mastodon-source --account steve", which
+// provides the feed via our plugin protocol.
+func newPlugin(entry configfile.Feed) (Fetcher, error) {
+
+	command := strings.TrimSpace(entry.URL[len("plugin:"):])
+	if command == "" {
+		return nil, fmt.Errorf("'%s' doesn't name a plugin", entry.URL)
+	}
+
+	return &reader{url: entry.URL, logger: logger.Discard, decode: func(ctx context.Context) (*gofeed.Feed, error) {
+		res, err := plugin.Run(ctx, command, plugin.Request{Point: plugin.Source, Feed: plugin.NewFeed(entry, "")})
+		if err != nil {
+			return nil, err
+		}
+		return res.Feed(), nil
+	}}, nil
+}
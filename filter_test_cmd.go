@@ -0,0 +1,129 @@
+//
+// Show which items of a feed would be filtered out.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+)
+
+// Structure for our options and state.
+type filterTestCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// The URL, or name, of the feed to test.
+	feed string
+
+	// Should we only show the items which are new?
+	new bool
+}
+
+// Arguments handles our flag-setup.
+func (f *filterTestCmd) Arguments(flags *flag.FlagSet) {
+	f.config = configfile.New()
+
+	flags.StringVar(&f.feed, "feed", "", "The URL, or name, of the feed to test.")
+	flags.BoolVar(&f.new, "new", false, "Only show the items which haven't been seen.")
+}
+
+// Info is part of the subcommand-API.
+func (f *filterTestCmd) Info() (string, string) {
+	return "filter-test", `Show which items of a feed would be filtered out.
+
+This sub-command fetches the given feed, and shows which of its current
+items would be delivered, and which would be filtered out, by the
+'exclude', 'exclude-title', 'include', and 'include-title' options of the
+feed, along with the option which decided.
+
+Nothing is sent, and the state of the items is not changed, so you may
+edit the options of the feed, and run this command again, until they
+work as you expect.
+
+Each item is shown along with whether it is "new", or has been "seen",
+as only new items are sent.
+
+Example:
+
+    $ rss2email filter-test -feed https://blog.steve.fi/index.rss
+    $ rss2email filter-test -new -feed blog
+`
+}
+
+// Execute is invoked if the user specifies `filter-test` as the subcommand.
+func (f *filterTestCmd) Execute(args []string) int {
+
+	if f.feed == "" {
+		fmt.Printf("Usage: rss2email filter-test [-new] -feed URL\n")
+		return 1
+	}
+
+	// Use the options of the feed, if it is configured.
+	entry := configfile.Feed{URL: f.feed}
+	if f.config.Exists() {
+		entries, err := f.config.Parse()
+		if err != nil {
+			fmt.Printf("Error with config-file: %s\n", err.Error())
+			return 1
+		}
+
+		found := configfile.Find(entries, f.feed)
+		if len(found) > 1 {
+			fmt.Printf("'%s' matches %d feeds, please be more specific\n", f.feed, len(found))
+			return 1
+		}
+		if len(found) == 1 {
+			entry = found[0]
+		}
+	}
+
+	results, err := processor.New().Filter(context.Background(), entry)
+	if err != nil {
+		fmt.Printf("failed to fetch %s: %s\n", entry.Label(), err.Error())
+		return 1
+	}
+
+	delivered, filtered, fresh := 0, 0, 0
+	for _, res := range results {
+
+		if f.new && !res.New {
+			continue
+		}
+
+		action := "deliver"
+		if res.Skipped {
+			action = "filter"
+			filtered++
+		} else {
+			delivered++
+		}
+
+		state := "seen"
+		if res.New {
+			state = "new"
+			fresh++
+		}
+
+		fmt.Fprintf(out, "%-8s %-5s %s\n", action, state, res.Title)
+		fmt.Fprintf(out, "%-14s %s\n", "", res.Link)
+
+		switch {
+		case res.Rule != nil && res.Skipped:
+			fmt.Fprintf(out, "%-14s excluded by %s:%s\n", "", res.Rule.Name, res.Rule.Value)
+		case res.Rule != nil:
+			fmt.Fprintf(out, "%-14s included by %s:%s\n", "", res.Rule.Name, res.Rule.Value)
+		case res.Skipped:
+			fmt.Fprintf(out, "%-14s excluded as no include, or include-title, option matched\n", "")
+		}
+	}
+
+	fmt.Fprintf(out, "\n%d would be delivered, %d filtered out, %d new\n", delivered, filtered, fresh)
+	return 0
+}
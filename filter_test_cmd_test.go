@@ -0,0 +1,84 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestFilterTest(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>Release 1.0</title><link>https://example.com/1</link><description>One</description></item>
+<item><title>Sponsored</title><link>https://example.com/2</link><description>Two</description></item>
+<item><title>Lunch</title><link>https://example.com/3</link><description>Three</description></item>
+</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	cfg := filepath.Join(t.TempDir(), "feeds.txt")
+	err := os.WriteFile(cfg, []byte(ts.URL+"/feed\n - name: Sample\n - exclude-title: ^Sponsored\n - include-title: Release|Sponsored\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	// The first item has been seen.
+	seen := withstate.FeedItem{Item: &gofeed.Item{Link: "https://example.com/1"}}
+	seen.RecordSeen()
+
+	f := filterTestCmd{config: configfile.NewWithPath(cfg)}
+	if f.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with no feed")
+	}
+
+	out = new(bytes.Buffer)
+	f.feed = "sample"
+	if f.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error")
+	}
+	expected := `deliver  seen  Release 1.0
+               https://example.com/1
+               included by include-title:Release|Sponsored
+filter   new   Sponsored
+               https://example.com/2
+               excluded by exclude-title:^Sponsored
+filter   new   Lunch
+               https://example.com/3
+               excluded as no include, or include-title, option matched
+
+1 would be delivered, 2 filtered out, 2 new
+`
+	if out.(*bytes.Buffer).String() != expected {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+
+	// Nothing was recorded.
+	item := withstate.FeedItem{Item: &gofeed.Item{Link: "https://example.com/2"}}
+	if !item.IsNew() {
+		t.Fatalf("state was changed")
+	}
+
+	// Only the new items.
+	out = new(bytes.Buffer)
+	f.new = true
+	f.Execute([]string{})
+	if strings.Contains(out.(*bytes.Buffer).String(), "Release") {
+		t.Fatalf("unexpected output:\n%s", out)
+	}
+}
@@ -0,0 +1,144 @@
+//
+// Search the current items of each feed.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+)
+
+// Structure for our options and state.
+type grepCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Should we ignore case?
+	ignoreCase bool
+
+	// Should we only match titles?
+	titles bool
+
+	// Only items published within this period are shown, if set.
+	since time.Duration
+}
+
+// Arguments handles our flag-setup.
+func (g *grepCmd) Arguments(f *flag.FlagSet) {
+	g.config = configfile.New()
+
+	f.BoolVar(&g.ignoreCase, "i", false, "Ignore case when matching.")
+	f.BoolVar(&g.titles, "title", false, "Only match the titles of items, rather than their content too.")
+	f.DurationVar(&g.since, "since", 0, "Only show items published within this period, e.g. '168h'.")
+}
+
+// Info is part of the subcommand-API.
+func (g *grepCmd) Info() (string, string) {
+	return "grep", `Search the current items of each feed.
+
+This sub-command fetches each of the feeds within the configuration file,
+other than those which have been archived, and shows the title, and
+link, of those of their items whose title, or content, matches the given
+regular expression.
+
+The '-title' flag only matches the titles of items, the '-i' flag ignores
+case, and the '-since' flag only shows items published within the given
+period, so undated items are not shown.
+
+Nothing is sent, and the state of the items is not changed.  As with
+grep(1) the exit code is 1 if no items matched.
+
+Example:
+
+    $ rss2email grep -i -since 168h 'golang|rust'
+    $ rss2email grep -title "release notes"
+`
+}
+
+// Execute is invoked if the user specifies `grep` as the subcommand.
+func (g *grepCmd) Execute(args []string) int {
+
+	if len(args) == 0 {
+		fmt.Printf("Usage: rss2email grep [flags] pattern\n")
+		return 1
+	}
+
+	pattern := strings.Join(args, " ")
+	if g.ignoreCase {
+		pattern = "(?i)" + pattern
+	}
+	re, err := regexp.Compile(pattern)
+	if err != nil {
+		fmt.Printf("invalid pattern: %s\n", err.Error())
+		return 1
+	}
+
+	entries, err := g.config.Parse()
+	if err != nil {
+		fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+		return 1
+	}
+
+	var feeds []configfile.Feed
+	for _, entry := range entries {
+		if !entry.Archived() {
+			feeds = append(feeds, entry)
+		}
+	}
+
+	// Fetch the feeds in parallel, keeping their results in order.
+	items := make([][]processor.PreviewItem, len(feeds))
+	errors := make([]error, len(feeds))
+
+	var wg sync.WaitGroup
+	sem := make(chan bool, 8)
+	for i, entry := range feeds {
+		wg.Add(1)
+		go func(i int, entry configfile.Feed) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+
+			items[i], errors[i] = processor.New().Preview(context.Background(), entry)
+		}(i, entry)
+	}
+	wg.Wait()
+
+	matched := 0
+	for i, entry := range feeds {
+
+		if errors[i] != nil {
+			fmt.Printf("failed to fetch %s: %s\n", entry.Label(), errors[i].Error())
+			continue
+		}
+
+		for _, item := range items[i] {
+
+			if g.since > 0 && (item.Date.IsZero() || time.Since(item.Date) > g.since) {
+				continue
+			}
+			if !re.MatchString(item.Title) && (g.titles || !re.MatchString(item.Text)) {
+				continue
+			}
+
+			matched++
+			fmt.Fprintf(out, "%s: %s\n", entry.Label(), item.Title)
+			fmt.Fprintf(out, "  %s\n", item.Link)
+		}
+	}
+
+	if matched == 0 {
+		return 1
+	}
+	return 0
+}
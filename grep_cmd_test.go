@@ -0,0 +1,78 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestGrep(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	recent := time.Now().Add(-time.Hour).Format(time.RFC1123Z)
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			http.Error(w, "broken", http.StatusInternalServerError)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>Golang 2.0</title><link>https://example.com%s/1</link><pubDate>%s</pubDate><description>Released</description></item>
+<item><title>Lunch</title><link>https://example.com%s/2</link><description>I ate &lt;b&gt;golang&lt;/b&gt; noodles</description></item>
+</channel></rss>`, r.URL.Path, recent, r.URL.Path)
+	}))
+	defer ts.Close()
+
+	cfg := filepath.Join(t.TempDir(), "feeds.txt")
+	err := os.WriteFile(cfg, []byte(ts.URL+"/one\n - name: One\n"+ts.URL+"/two\n"+ts.URL+"/archived\n - archived: yes\n"+ts.URL+"/broken\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	g := grepCmd{config: configfile.NewWithPath(cfg)}
+	if g.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with no pattern")
+	}
+	if g.Execute([]string{"("}) != 1 {
+		t.Fatalf("expected error with an invalid pattern")
+	}
+
+	// Titles, and content, are matched, in the order of the feeds.
+	out = new(bytes.Buffer)
+	g.Execute([]string{"golang"})
+	expected := "One: Lunch\n  https://example.com/one/2\n" + ts.URL + "/two: Lunch\n  https://example.com/two/2\n"
+	if out.(*bytes.Buffer).String() != expected {
+		t.Fatalf("unexpected output:\n%s\n%s", out.(*bytes.Buffer).String(), expected)
+	}
+
+	// Ignoring case, recent titles.
+	out = new(bytes.Buffer)
+	g.ignoreCase = true
+	g.titles = true
+	g.since = 24 * time.Hour
+	if g.Execute([]string{"golang"}) != 0 {
+		t.Fatalf("unexpected failure")
+	}
+	expected = "One: Golang 2.0\n  https://example.com/one/1\n" + ts.URL + "/two: Golang 2.0\n  https://example.com/two/1\n"
+	if out.(*bytes.Buffer).String() != expected {
+		t.Fatalf("unexpected output:\n%s\n%s", out.(*bytes.Buffer).String(), expected)
+	}
+
+	// Nothing matching is a failure.
+	out = new(bytes.Buffer)
+	if g.Execute([]string{"lunch", "time"}) != 1 || strings.Contains(out.(*bytes.Buffer).String(), "Lunch") {
+		t.Fatalf("expected failure without a match")
+	}
+}
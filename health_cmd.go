@@ -0,0 +1,162 @@
+//
+// Report upon the health of our feeds.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Structure for our options and state.
+type healthCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// The number of months without new items after which a feed is
+	// probably dead.
+	months int
+
+	// Should we only show the feeds which are probably dead?
+	dead bool
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (h *healthCmd) Arguments(f *flag.FlagSet) {
+	h.config = configfile.New()
+
+	f.IntVar(&h.months, "months", 6, "The number of months without new items after which a feed is reported as probably dead.")
+	f.BoolVar(&h.dead, "dead", false, "Only show the feeds which are probably dead.")
+}
+
+// Info is part of the subcommand-API
+func (h *healthCmd) Info() (string, string) {
+	return "health", `Report upon the health of each feed.
+
+Each time a feed is fetched by the 'cron', or 'daemon', sub-commands the
+result is recorded, and this command uses that record to report upon the
+health of each feed, so that your subscriptions may be cleaned up.
+
+Each feed is given a score, from 0 to 100, which is reduced by recent
+failures, slow fetches, and a lack of new items, and feeds which have had
+no new items, or have failed every fetch, for six months are reported as
+"dead".  The number of months may be changed via the '-months' flag.
+
+The columns shown are:
+
+  SCORE      The health of the feed, from 0 to 100.
+  STATUS     "ok", "failing", "dead", "archived", or "unknown" if it
+             hasn't been fetched since this record began.
+  FAIL       The proportion of recent fetches which failed.
+  LATENCY    The average time taken by a fetch.
+  ITEMS/MO   The average number of new items each month.
+  LAST ITEM  The date of the newest item.
+
+Feeds are shown in order of their score, the least healthy first.
+
+Example:
+
+    $ rss2email health
+    $ rss2email health -dead -months 12
+`
+}
+
+// healthRow is the health of a single feed.
+type healthRow struct {
+	feed   configfile.Feed
+	health withstate.Health
+	score  int
+	status string
+}
+
+// Execute is invoked if the user specifies `health` as the subcommand.
+func (h *healthCmd) Execute(args []string) int {
+
+	// Upgrade our configuration-file if necessary
+	h.config.Upgrade()
+
+	entries, err := h.config.Parse()
+	if err != nil {
+		fmt.Printf("Error with config-file: %s\n", err.Error())
+		return 1
+	}
+
+	now := time.Now()
+	silence := time.Duration(h.months) * 30 * 24 * time.Hour
+
+	var rows []healthRow
+	for _, entry := range entries {
+
+		health, err := withstate.FeedHealth(entry.URL)
+		if err != nil {
+			fmt.Printf("Error reading the health of %s: %s\n", entry.Label(), err.Error())
+			return 1
+		}
+
+		row := healthRow{feed: entry, health: health, score: health.Score(now)}
+		switch {
+		case entry.Archived():
+			row.status = "archived"
+		case !health.Known():
+			row.status = "unknown"
+		case health.Dead(now, silence):
+			row.status = "dead"
+		case health.Consecutive > 0:
+			row.status = "failing"
+		default:
+			row.status = "ok"
+		}
+
+		if h.dead && row.status != "dead" {
+			continue
+		}
+		rows = append(rows, row)
+	}
+
+	// The least healthy first, with feeds we know nothing of last.
+	sort.SliceStable(rows, func(i, j int) bool {
+		if rows[i].health.Known() != rows[j].health.Known() {
+			return rows[i].health.Known()
+		}
+		return rows[i].score < rows[j].score
+	})
+
+	fmt.Fprintf(out, "%5s  %-7s %5s %8s %9s  %-10s  %s\n", "SCORE", "STATUS", "FAIL", "LATENCY", "ITEMS/MO", "LAST ITEM", "FEED")
+	for _, row := range rows {
+
+		if !row.health.Known() {
+			fmt.Fprintf(out, "%5s  %-7s %5s %8s %9s  %-10s  %s\n", "-", row.status, "-", "-", "-", "-", row.feed.Label())
+			continue
+		}
+
+		last := "never"
+		if !row.health.LastItem.IsZero() {
+			last = row.health.LastItem.Format("2006-01-02")
+		}
+
+		fmt.Fprintf(out, "%5d  %-7s %4.0f%% %7.1fs %9.1f  %-10s  %s\n",
+			row.score,
+			row.status,
+			100*row.health.FailureRate,
+			row.health.Latency.Seconds(),
+			row.health.PerMonth(now),
+			last,
+			row.feed.Label())
+
+		if row.health.Consecutive > 0 {
+			fmt.Fprintf(out, "%5s  %s\n", "", row.health.LastError)
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,66 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestHealth(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	path := filepath.Join(t.TempDir(), "feeds.txt")
+	os.WriteFile(path, []byte(`name=Healthy https://example.com/healthy
+name=Silent https://example.com/silent
+name=Broken https://example.com/broken
+name=New https://example.com/new
+`), 0644)
+
+	withstate.RecordFetch("https://example.com/healthy", withstate.Fetch{Latency: time.Second, NewItems: 2, Newest: time.Now()})
+	withstate.RecordFetch("https://example.com/silent", withstate.Fetch{Latency: time.Second, Newest: time.Now().AddDate(-1, 0, 0)})
+	withstate.RecordFetch("https://example.com/broken", withstate.Fetch{Err: errors.New("connection refused")})
+
+	h := healthCmd{months: 6}
+	h.config = configfile.NewWithPath(path)
+	if h.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected failure")
+	}
+
+	lines := strings.Split(strings.TrimSpace(out.(*bytes.Buffer).String()), "\n")
+	if len(lines) != 6 || !strings.HasPrefix(lines[0], "SCORE") {
+		t.Fatalf("unexpected output:\n%s", strings.Join(lines, "\n"))
+	}
+
+	// The least healthy come first.
+	expected := []string{"failing", "connection refused", "dead", "ok", "unknown"}
+	for i, e := range expected {
+		if !strings.Contains(lines[i+1], e) {
+			t.Fatalf("expected %q upon line %d:\n%s", e, i+1, strings.Join(lines, "\n"))
+		}
+	}
+	if !strings.HasSuffix(lines[3], "Silent") || !strings.Contains(lines[3], time.Now().AddDate(-1, 0, 0).Format("2006-01-02")) {
+		t.Fatalf("unexpected line: %s", lines[3])
+	}
+
+	// We may show just the dead.
+	out = new(bytes.Buffer)
+	h.dead = true
+	h.Execute([]string{})
+	output := out.(*bytes.Buffer).String()
+	if strings.Count(output, "\n") != 2 || !strings.Contains(output, "Silent") {
+		t.Fatalf("unexpected output:\n%s", output)
+	}
+}
@@ -0,0 +1,281 @@
+package httpfetch
+
+import (
+	"bufio"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// httpOnlyPrefix marks the cookies of a cookies.txt file which are
+// HttpOnly, as written by curl and browser extensions.
+const httpOnlyPrefix = "#HttpOnly_"
+
+// CookiesPath returns the path of the cookies.txt file given via the
+// "cookies" option, which is relative to the configuration directory.
+func CookiesPath(name string) string {
+
+	path := filepath.FromSlash(name)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(configfile.New().Directory(), path)
+	}
+	return path
+}
+
+// cookie is a single cookie, as stored in a cookies.txt file.
+type cookie struct {
+
+	// domain is the domain of the cookie, and subdomains is true if
+	// it is also sent to the subdomains of that domain.
+	domain     string
+	subdomains bool
+
+	path     string
+	secure   bool
+	httpOnly bool
+
+	// expires holds the time the cookie expires, or the zero time for
+	// a session cookie.
+	expires time.Time
+
+	name  string
+	value string
+}
+
+// expired returns true if the cookie has expired.
+func (c *cookie) expired(now time.Time) bool {
+	return !c.expires.IsZero() && !c.expires.After(now)
+}
+
+// matches returns true if the cookie should be sent with a request for the
+// given URL.
+func (c *cookie) matches(u *url.URL) bool {
+
+	host := strings.ToLower(u.Hostname())
+	if host != c.domain && !(c.subdomains && strings.HasSuffix(host, "."+c.domain)) {
+		return false
+	}
+
+	path := u.EscapedPath()
+	if path == "" {
+		path = "/"
+	}
+	if path != c.path && !strings.HasPrefix(path, strings.TrimSuffix(c.path, "/")+"/") {
+		return false
+	}
+
+	return !c.secure || u.Scheme == "https"
+}
+
+// cookieJar is a http.CookieJar holding the contents of a cookies.txt file,
+// in the Netscape format used by curl and wget.
+type cookieJar struct {
+
+	// mutex protects our cookies.
+	mutex   sync.Mutex
+	cookies []*cookie
+
+	// changed is true if the server has set, or removed, cookies.
+	changed bool
+}
+
+// loadCookies loads the cookies.txt file at the given path.  A missing
+// file results in an empty jar, which may be saved.
+func loadCookies(path string) (*cookieJar, error) {
+
+	jar := &cookieJar{}
+
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return jar, nil
+		}
+		return nil, err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+
+		text := strings.TrimRight(scanner.Text(), "\r")
+		httpOnly := strings.HasPrefix(text, httpOnlyPrefix)
+		text = strings.TrimPrefix(text, httpOnlyPrefix)
+		if strings.TrimSpace(text) == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+
+		fields := strings.Split(text, "\t")
+		if len(fields) != 7 {
+			return nil, fmt.Errorf("%s:%d: invalid cookie, expected seven tab-separated fields", path, line)
+		}
+
+		expires, err := strconv.ParseInt(fields[4], 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s:%d: invalid expiry '%s'", path, line, fields[4])
+		}
+
+		c := &cookie{
+			domain:     strings.ToLower(strings.TrimPrefix(fields[0], ".")),
+			subdomains: strings.EqualFold(fields[1], "TRUE"),
+			path:       fields[2],
+			secure:     strings.EqualFold(fields[3], "TRUE"),
+			httpOnly:   httpOnly,
+			name:       fields[5],
+			value:      fields[6],
+		}
+		if expires > 0 {
+			c.expires = time.Unix(expires, 0)
+		}
+		jar.cookies = append(jar.cookies, c)
+	}
+	return jar, scanner.Err()
+}
+
+// save writes the cookies which haven't expired to the given path.
+func (j *cookieJar) save(path string) error {
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	var sb strings.Builder
+	sb.WriteString("# Netscape HTTP Cookie File\n")
+	sb.WriteString("# This file is maintained by rss2email, and may be edited.\n\n")
+
+	now := time.Now()
+	for _, c := range j.cookies {
+		if c.expired(now) {
+			continue
+		}
+
+		domain := c.domain
+		if c.subdomains {
+			domain = "." + domain
+		}
+		if c.httpOnly {
+			domain = httpOnlyPrefix + domain
+		}
+
+		expires := int64(0)
+		if !c.expires.IsZero() {
+			expires = c.expires.Unix()
+		}
+
+		fmt.Fprintf(&sb, "%s\t%s\t%s\t%s\t%d\t%s\t%s\n",
+			domain, flag(c.subdomains), c.path, flag(c.secure), expires, c.name, c.value)
+	}
+
+	err := os.MkdirAll(filepath.Dir(path), 0755)
+	if err != nil {
+		return err
+	}
+
+	// Write a temporary file first, so that the cookies aren't lost
+	// if we fail part-way through.
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, []byte(sb.String()), 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+// flag returns the form of a boolean used within cookies.txt files.
+func flag(b bool) string {
+	if b {
+		return "TRUE"
+	}
+	return "FALSE"
+}
+
+// SetCookies is part of the http.CookieJar interface, it records the
+// cookies set by a response from the given URL.
+func (j *cookieJar) SetCookies(u *url.URL, cookies []*http.Cookie) {
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	now := time.Now()
+	for _, hc := range cookies {
+
+		c := &cookie{
+			domain:   strings.ToLower(u.Hostname()),
+			path:     hc.Path,
+			secure:   hc.Secure,
+			httpOnly: hc.HttpOnly,
+			name:     hc.Name,
+			value:    hc.Value,
+		}
+
+		// A cookie may only be set for the host of the URL, or a
+		// domain which contains it.
+		if hc.Domain != "" {
+			domain := strings.ToLower(strings.TrimPrefix(hc.Domain, "."))
+			if domain != c.domain && !strings.HasSuffix(c.domain, "."+domain) {
+				continue
+			}
+			if net.ParseIP(c.domain) == nil {
+				c.domain = domain
+				c.subdomains = true
+			}
+		}
+
+		// The default path is the directory of the URL.
+		if !strings.HasPrefix(c.path, "/") {
+			c.path = "/"
+			if i := strings.LastIndex(u.EscapedPath(), "/"); i > 0 {
+				c.path = u.EscapedPath()[:i]
+			}
+		}
+
+		switch {
+		case hc.MaxAge < 0:
+			c.expires = now
+		case hc.MaxAge > 0:
+			c.expires = now.Add(time.Duration(hc.MaxAge) * time.Second)
+		case !hc.Expires.IsZero():
+			c.expires = hc.Expires
+		}
+
+		// Replace any existing cookie, removing it if the new one
+		// has already expired.
+		kept := j.cookies[:0]
+		for _, old := range j.cookies {
+			if old.domain != c.domain || old.path != c.path || old.name != c.name {
+				kept = append(kept, old)
+			}
+		}
+		j.cookies = kept
+		if !c.expired(now) {
+			j.cookies = append(j.cookies, c)
+		}
+		j.changed = true
+	}
+}
+
+// Cookies is part of the http.CookieJar interface, it returns the cookies
+// to send in a request to the given URL.
+func (j *cookieJar) Cookies(u *url.URL) []*http.Cookie {
+
+	j.mutex.Lock()
+	defer j.mutex.Unlock()
+
+	now := time.Now()
+	var cookies []*http.Cookie
+	for _, c := range j.cookies {
+		if !c.expired(now) && c.matches(u) {
+			cookies = append(cookies, &http.Cookie{Name: c.name, Value: c.value})
+		}
+	}
+	return cookies
+}
@@ -0,0 +1,118 @@
+package httpfetch
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestCookieFile ensures cookies.txt files may be read, and written.
+func TestCookieFile(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	content := `# Netscape HTTP Cookie File
+
+.example.com	TRUE	/	FALSE	0	session	one
+#HttpOnly_www.example.com	FALSE	/feeds	TRUE	4102444800	secret	two
+old.example.com	FALSE	/	FALSE	1	expired	three
+`
+	err := os.WriteFile(path, []byte(content), 0600)
+	if err != nil {
+		t.Fatalf("failed to write cookies: %s", err)
+	}
+
+	jar, err := loadCookies(path)
+	if err != nil {
+		t.Fatalf("failed to load cookies: %s", err)
+	}
+
+	tests := []struct {
+		url      string
+		expected string
+	}{
+		{"http://example.com/", "session=one"},
+		{"http://www.example.com/feeds/rss", "session=one"},
+		{"https://www.example.com/feeds/rss", "session=one secret=two"},
+		{"https://www.example.com/feedsx", "session=one"},
+		{"http://old.example.com/", "session=one"},
+		{"http://example.org/", ""},
+	}
+	for _, test := range tests {
+		u, _ := url.Parse(test.url)
+
+		var found []string
+		for _, c := range jar.Cookies(u) {
+			found = append(found, c.Name+"="+c.Value)
+		}
+		if strings.Join(found, " ") != test.expected {
+			t.Fatalf("unexpected cookies for %s: %v", test.url, found)
+		}
+	}
+
+	// Expired cookies aren't saved.
+	err = jar.save(path)
+	if err != nil {
+		t.Fatalf("failed to save cookies: %s", err)
+	}
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "#HttpOnly_www.example.com\tFALSE\t/feeds\tTRUE\t4102444800\tsecret\ttwo\n") ||
+		!strings.Contains(string(data), ".example.com\tTRUE\t/\tFALSE\t0\tsession\tone\n") ||
+		strings.Contains(string(data), "expired") {
+		t.Fatalf("unexpected cookies saved:\n%s", data)
+	}
+
+	// Malformed files are reported.
+	os.WriteFile(path, []byte("example.com\tFALSE\t/\n"), 0600)
+	_, err = loadCookies(path)
+	if err == nil || !strings.Contains(err.Error(), ":1: invalid cookie") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if CheckOption(configfile.Option{Name: "cookies", Value: path}) == nil {
+		t.Fatalf("expected error checking malformed cookies")
+	}
+}
+
+// TestCookies ensures our cookies are sent with the fetch, and those the
+// server sets are saved.
+func TestCookies(t *testing.T) {
+
+	sent := ""
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if c, err := r.Cookie("login"); err == nil {
+			sent = c.Value
+		}
+		http.SetCookie(w, &http.Cookie{Name: "consent", Value: "yes", Path: "/", Expires: time.Now().Add(time.Hour)})
+		http.SetCookie(w, &http.Cookie{Name: "login", MaxAge: -1})
+		fmt.Fprintln(w, "Hello, client")
+	}))
+	defer ts.Close()
+
+	u, _ := url.Parse(ts.URL)
+	path := filepath.Join(t.TempDir(), "cookies.txt")
+	os.WriteFile(path, []byte(u.Hostname()+"\tFALSE\t/\tFALSE\t0\tlogin\tsecret\n"), 0600)
+
+	obj := New(configfile.Feed{URL: ts.URL + "/rss", Options: []configfile.Option{
+		{Name: "cookies", Value: path},
+		{Name: "retry", Value: "1"},
+	}})
+	_, err := obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if sent != "secret" {
+		t.Fatalf("our cookie wasn't sent")
+	}
+
+	data, _ := os.ReadFile(path)
+	if !strings.Contains(string(data), "\tconsent\tyes\n") || strings.Contains(string(data), "login") {
+		t.Fatalf("unexpected cookies saved:\n%s", data)
+	}
+}
@@ -0,0 +1,58 @@
+package httpfetch
+
+import (
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// earliest is the earliest plausible date of a feed item, anything before
+// it is probably the Unix epoch, or a zero-value, in disguise.
+var earliest = time.Date(1995, 1, 1, 0, 0, 0, 0, time.UTC)
+
+// slack is how far into the future the date of an item may be before it
+// is implausible, which allows for feeds which give the wrong time zone.
+const slack = 24 * time.Hour
+
+// SaneDates removes the implausible dates of the items of the given feed,
+// which was retrieved by some other means, as saneDates does.
+func SaneDates(feed *gofeed.Feed) int {
+	return saneDates(feed, time.Now())
+}
+
+// saneDates removes the implausible dates of the items of the given feed,
+// returning the number of items which had them.
+//
+// Dates slightly in the future are clamped to the present, as they're
+// usually the result of the wrong time zone, while those far in the future,
+// or before the web existed, are removed, so the items are treated as if
+// they were undated when sorting, and showing, them.
+func saneDates(feed *gofeed.Feed, now time.Time) int {
+
+	sane := func(t *time.Time) (*time.Time, bool) {
+		switch {
+		case t == nil:
+			return nil, true
+		case t.Before(earliest), t.After(now.Add(slack)):
+			return nil, false
+		case t.After(now):
+			clamped := now
+			return &clamped, true
+		}
+		return t, true
+	}
+
+	count := 0
+	for _, item := range feed.Items {
+		var okp, oku bool
+		item.PublishedParsed, okp = sane(item.PublishedParsed)
+		item.UpdatedParsed, oku = sane(item.UpdatedParsed)
+		if !okp || !oku {
+			count++
+		}
+	}
+
+	feed.PublishedParsed, _ = sane(feed.PublishedParsed)
+	feed.UpdatedParsed, _ = sane(feed.UpdatedParsed)
+	return count
+}
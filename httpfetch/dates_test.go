@@ -0,0 +1,75 @@
+package httpfetch
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestSaneDates ensures implausible dates are removed, or clamped.
+func TestSaneDates(t *testing.T) {
+
+	now := time.Date(2024, 3, 14, 12, 0, 0, 0, time.UTC)
+	date := func(t time.Time) *time.Time { return &t }
+
+	epoch := time.Unix(0, 0)
+	good := now.Add(-48 * time.Hour)
+	soon := now.Add(3 * time.Hour)
+	future := now.AddDate(5, 0, 0)
+
+	feed := &gofeed.Feed{Items: []*gofeed.Item{
+		{Title: "good", PublishedParsed: date(good), UpdatedParsed: date(good)},
+		{Title: "undated"},
+		{Title: "epoch", PublishedParsed: date(epoch)},
+		{Title: "soon", PublishedParsed: date(soon)},
+		{Title: "future", PublishedParsed: date(good), UpdatedParsed: date(future)},
+	}}
+
+	if n := saneDates(feed, now); n != 2 {
+		t.Fatalf("expected two bogus items, got %d", n)
+	}
+
+	items := feed.Items
+	if !items[0].PublishedParsed.Equal(good) || !items[0].UpdatedParsed.Equal(good) {
+		t.Fatalf("good dates were changed")
+	}
+	if items[1].PublishedParsed != nil {
+		t.Fatalf("undated item was dated")
+	}
+	if items[2].PublishedParsed != nil {
+		t.Fatalf("epoch date wasn't removed")
+	}
+	if !items[3].PublishedParsed.Equal(now) {
+		t.Fatalf("near-future date wasn't clamped: %s", items[3].PublishedParsed)
+	}
+	if !items[4].PublishedParsed.Equal(good) || items[4].UpdatedParsed != nil {
+		t.Fatalf("future date wasn't removed")
+	}
+}
+
+// TestBogusDates ensures bogus dates are removed when fetching.
+func TestBogusDates(t *testing.T) {
+
+	x := New(configfile.Feed{URL: "https://example.com/index.rss"})
+	x.content = `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Example</title>
+<item><title>One</title><link>https://example.com/1</link><pubDate>Thu, 01 Jan 1970 00:00:00 +0000</pubDate></item>
+<item><title>Two</title><link>https://example.com/2</link><pubDate>Fri, 22 May 2020 09:00:00 +0000</pubDate></item>
+</channel>
+</rss>
+`
+	feed, err := x.Fetch()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if x.BogusDates() != 1 {
+		t.Fatalf("expected one bogus date, got %d", x.BogusDates())
+	}
+	if feed.Items[0].PublishedParsed != nil || feed.Items[1].PublishedParsed == nil {
+		t.Fatalf("unexpected dates")
+	}
+}
@@ -0,0 +1,210 @@
+package httpfetch
+
+import (
+	"bytes"
+	"context"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+// dohClient is the client used to make DNS-over-HTTPS queries.
+var dohClient = http.DefaultClient
+
+// parseResolver parses the address of a DNS server, given via the
+// "resolver" option, adding the default port if there is none.
+func parseResolver(value string) (string, error) {
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return "", fmt.Errorf("invalid resolver '%s', expected host[:port]", value)
+	}
+	if _, _, err := net.SplitHostPort(value); err == nil {
+		return value, nil
+	}
+	return net.JoinHostPort(strings.Trim(value, "[]"), "53"), nil
+}
+
+// parseDoH parses the URL of a DNS-over-HTTPS server, given via the "doh"
+// option.
+func parseDoH(value string) (string, error) {
+
+	u, err := url.Parse(strings.TrimSpace(value))
+	if err != nil || u.Scheme != "https" || u.Host == "" {
+		return "", fmt.Errorf("invalid doh '%s', expected an https:// URL", value)
+	}
+	return u.String(), nil
+}
+
+// parseIPVersion parses the "ip-version" option, returning the network
+// to which our connections are restricted.
+func parseIPVersion(value string) (string, error) {
+
+	switch strings.TrimSpace(value) {
+	case "4":
+		return "tcp4", nil
+	case "6":
+		return "tcp6", nil
+	default:
+		return "", fmt.Errorf("invalid ip-version '%s', expected 4 or 6", value)
+	}
+}
+
+// resolver returns the resolver to use when fetching our feed, or nil if
+// the system resolver should be used.
+func (h *HTTPFetch) resolver() (*net.Resolver, error) {
+
+	if h.doh != "" {
+		server, err := parseDoH(h.doh)
+		if err != nil {
+			return nil, err
+		}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				return &dohConn{ctx: ctx, server: server}, nil
+			},
+		}, nil
+	}
+
+	if h.dnsServer != "" {
+		server, err := parseResolver(h.dnsServer)
+		if err != nil {
+			return nil, err
+		}
+		return &net.Resolver{
+			PreferGo: true,
+			Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+				d := net.Dialer{Timeout: 10 * time.Second}
+				return d.DialContext(ctx, network, server)
+			},
+		}, nil
+	}
+
+	return nil, nil
+}
+
+// dohConn is a connection to a DNS server, which sends each query to a
+// DNS-over-HTTPS server instead.
+//
+// The resolver uses the TCP framing of DNS messages with connections
+// which aren't packet-based, so each message written to us is preceded
+// by its length, as is the reply we return.
+type dohConn struct {
+
+	// ctx is the context of the lookup.
+	ctx context.Context
+
+	// server is the URL of the DNS-over-HTTPS server.
+	server string
+
+	// query holds the query written to us, and reply the reply which
+	// is yet to be read.
+	query bytes.Buffer
+	reply bytes.Reader
+}
+
+// Write buffers a query, sending it once it is complete.
+func (c *dohConn) Write(b []byte) (int, error) {
+
+	c.query.Write(b)
+
+	data := c.query.Bytes()
+	if len(data) < 2 || len(data) < 2+int(binary.BigEndian.Uint16(data)) {
+		return len(b), nil
+	}
+
+	reply, err := c.exchange(data[2:])
+	c.query.Reset()
+	if err != nil {
+		return 0, err
+	}
+
+	framed := make([]byte, 2+len(reply))
+	binary.BigEndian.PutUint16(framed, uint16(len(reply)))
+	copy(framed[2:], reply)
+	c.reply.Reset(framed)
+
+	return len(b), nil
+}
+
+// exchange sends a single query to our server, returning its reply.
+func (c *dohConn) exchange(query []byte) ([]byte, error) {
+
+	req, err := http.NewRequestWithContext(c.ctx, "POST", c.server, bytes.NewReader(query))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/dns-message")
+	req.Header.Set("Accept", "application/dns-message")
+
+	resp, err := dohClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("DNS-over-HTTPS query to %s failed: %s", c.server, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("DNS-over-HTTPS query to %s failed: %s", c.server, resp.Status)
+	}
+
+	reply, err := io.ReadAll(io.LimitReader(resp.Body, 65535))
+	if err != nil {
+		return nil, err
+	}
+	return reply, nil
+}
+
+// Read returns the reply to our query.
+func (c *dohConn) Read(b []byte) (int, error) {
+	return c.reply.Read(b)
+}
+
+// Close is part of the net.Conn interface.
+func (c *dohConn) Close() error {
+	return nil
+}
+
+// LocalAddr is part of the net.Conn interface.
+func (c *dohConn) LocalAddr() net.Addr {
+	return dohAddr(c.server)
+}
+
+// RemoteAddr is part of the net.Conn interface.
+func (c *dohConn) RemoteAddr() net.Addr {
+	return dohAddr(c.server)
+}
+
+// SetDeadline is part of the net.Conn interface, our deadline comes
+// from the context of the lookup.
+func (c *dohConn) SetDeadline(t time.Time) error {
+	return nil
+}
+
+// SetReadDeadline is part of the net.Conn interface.
+func (c *dohConn) SetReadDeadline(t time.Time) error {
+	return nil
+}
+
+// SetWriteDeadline is part of the net.Conn interface.
+func (c *dohConn) SetWriteDeadline(t time.Time) error {
+	return nil
+}
+
+// dohAddr is the address of a DNS-over-HTTPS server.
+type dohAddr string
+
+// Network is part of the net.Addr interface.
+func (a dohAddr) Network() string {
+	return "https"
+}
+
+// String is part of the net.Addr interface.
+func (a dohAddr) String() string {
+	return string(a)
+}
@@ -0,0 +1,132 @@
+package httpfetch
+
+import (
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// answer returns the reply to the given DNS query, answering queries for
+// A records with 127.0.0.1, and any others with no records.
+func answer(query []byte) []byte {
+
+	// Find the end of the question, which follows the header.
+	end := 12
+	for end < len(query) && query[end] != 0 {
+		end += int(query[end]) + 1
+	}
+	end += 5
+
+	reply := append([]byte{}, query[:end]...)
+	binary.BigEndian.PutUint16(reply[2:], 0x8180)
+	binary.BigEndian.PutUint16(reply[10:], 0)
+
+	qtype := binary.BigEndian.Uint16(query[end-4:])
+	if qtype == 1 {
+		binary.BigEndian.PutUint16(reply[6:], 1)
+		reply = append(reply, 0xc0, 0x0c, 0, 1, 0, 1, 0, 0, 0, 60, 0, 4, 127, 0, 0, 1)
+	}
+	return reply
+}
+
+// feedServer starts a HTTP-server, upon localhost, returning the URL of a
+// feed upon it with the given hostname rather than its address.
+func feedServer(t *testing.T, host string) string {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintln(w, "Hello, client")
+	}))
+	t.Cleanup(ts.Close)
+
+	_, port, _ := net.SplitHostPort(strings.TrimPrefix(ts.URL, "http://"))
+	return "http://" + net.JoinHostPort(host, port) + "/"
+}
+
+// TestResolver ensures the host of a feed is resolved via the DNS server
+// we're given.
+func TestResolver(t *testing.T) {
+
+	pc, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Skipf("failed to listen: %s", err)
+	}
+	defer pc.Close()
+
+	go func() {
+		buf := make([]byte, 512)
+		for {
+			n, addr, err := pc.ReadFrom(buf)
+			if err != nil {
+				return
+			}
+			pc.WriteTo(answer(buf[:n]), addr)
+		}
+	}()
+
+	uri := feedServer(t, "feed.rss2email.invalid")
+
+	obj := New(configfile.Feed{URL: uri, Options: []configfile.Option{
+		{Name: "resolver", Value: pc.LocalAddr().String()},
+	}})
+
+	// We get a parse error, rather than a lookup failure, once the
+	// host has been resolved.
+	_, err = obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// There are no IPv6 addresses.
+	obj = New(configfile.Feed{URL: uri, Options: []configfile.Option{
+		{Name: "resolver", Value: pc.LocalAddr().String()},
+		{Name: "ip-version", Value: "6"},
+		{Name: "retry", Value: "1"},
+	}})
+	_, err = obj.Fetch()
+	if err == nil || strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("expected a lookup failure, got: %v", err)
+	}
+}
+
+// TestDoH ensures the host of a feed is resolved via DNS-over-HTTPS, if
+// requested.
+func TestDoH(t *testing.T) {
+
+	queries := 0
+	doh := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != "POST" || r.Header.Get("Content-Type") != "application/dns-message" {
+			http.Error(w, "bad request", http.StatusBadRequest)
+			return
+		}
+		query, _ := io.ReadAll(r.Body)
+		queries++
+		w.Header().Set("Content-Type", "application/dns-message")
+		w.Write(answer(query))
+	}))
+	defer doh.Close()
+
+	dohClient = doh.Client()
+	defer func() {
+		dohClient = http.DefaultClient
+	}()
+
+	obj := New(configfile.Feed{URL: feedServer(t, "feed.rss2email.invalid"), Options: []configfile.Option{
+		{Name: "doh", Value: doh.URL + "/dns-query"},
+		{Name: "ip-version", Value: "4"},
+	}})
+
+	_, err := obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if queries == 0 {
+		t.Fatalf("the DNS-over-HTTPS server wasn't queried")
+	}
+}
@@ -0,0 +1,66 @@
+package httpfetch
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// fixtureExtensions are the extensions a fixture may have.
+var fixtureExtensions = []string{".xml", ".json", ""}
+
+// FixtureNames returns the names of the files which may hold the content
+// of the given feed, within a directory of fixtures, in order of preference.
+//
+// These are the name of the feed, if it has one, and then its URL, without
+// the scheme, in which characters other than letters, digits, dots, and
+// dashes are replaced by underscores.  So the fixture of the feed
+// "https://blog.steve.fi/index.rss" may be "blog.steve.fi_index.rss.xml".
+// Each name may be followed by ".xml", ".json", or nothing at all.
+func FixtureNames(entry configfile.Feed) []string {
+
+	clean := func(s string) string {
+		return strings.Map(func(r rune) rune {
+			if (r >= 'a' && r <= 'z') || (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') || r == '.' || r == '-' {
+				return r
+			}
+			return '_'
+		}, s)
+	}
+
+	var names []string
+	if name := entry.Name(); name != "" {
+		names = append(names, clean(name))
+	}
+
+	url := entry.URL
+	if i := strings.Index(url, "://"); i >= 0 {
+		url = url[i+3:]
+	}
+	names = append(names, clean(strings.TrimSuffix(url, "/")))
+	return names
+}
+
+// FixturePath returns the path of the fixture of the given feed, within
+// the given directory, or an error if there isn't one.
+func FixturePath(dir string, entry configfile.Feed) (string, error) {
+
+	for _, name := range FixtureNames(entry) {
+		for _, ext := range fixtureExtensions {
+			path := filepath.Join(dir, name+ext)
+			if fi, err := os.Stat(path); err == nil && !fi.IsDir() {
+				return path, nil
+			}
+		}
+	}
+	return "", fmt.Errorf("there is no fixture for %s within %s, expected %s.xml", entry.Label(), dir, filepath.Join(dir, FixtureNames(entry)[0]))
+}
+
+// SetFixtures sets the directory of fixtures, which hold the content of
+// feeds, so that the fixture of our feed is used rather than fetching it.
+func (h *HTTPFetch) SetFixtures(dir string) {
+	h.fixtures = dir
+}
@@ -0,0 +1,57 @@
+package httpfetch
+
+import (
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestFixtureNames(t *testing.T) {
+
+	names := FixtureNames(configfile.Feed{URL: "https://blog.steve.fi/index.rss?page=1"})
+	if !reflect.DeepEqual(names, []string{"blog.steve.fi_index.rss_page_1"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+
+	names = FixtureNames(configfile.Feed{URL: "https://example.com/", Options: []configfile.Option{{Name: "name", Value: "My Blog"}}})
+	if !reflect.DeepEqual(names, []string{"My_Blog", "example.com"}) {
+		t.Fatalf("unexpected names: %v", names)
+	}
+}
+
+func TestFixtures(t *testing.T) {
+
+	dir := t.TempDir()
+	err := os.WriteFile(filepath.Join(dir, "example.com_feed.json"), []byte(`{"version": "https://jsonfeed.org/version/1", "title": "T", "items": [{"id": "1", "title": "One"}]}`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+	os.WriteFile(filepath.Join(dir, "empty.xml"), []byte{}, 0644)
+
+	// The fixture is used, rather than fetching the feed.
+	x := New(configfile.Feed{URL: "http://example.com/feed"})
+	x.SetFixtures(dir)
+	feed, err := x.Fetch()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(feed.Items) != 1 || feed.Items[0].Title != "One" {
+		t.Fatalf("unexpected feed: %v", feed)
+	}
+	if x.FinalURL() != "http://example.com/feed" {
+		t.Fatalf("unexpected final URL: %s", x.FinalURL())
+	}
+
+	// Missing, and empty, fixtures are errors.
+	for _, url := range []string{"http://example.com/missing", "http://empty.xml"} {
+		x = New(configfile.Feed{URL: url})
+		x.SetFixtures(dir)
+		_, err = x.Fetch()
+		if err == nil {
+			t.Fatalf("expected an error for %s", url)
+		}
+	}
+}
@@ -5,21 +5,36 @@
 package httpfetch
 
 import (
+	"context"
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"net/http"
+	"os"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/bridge"
 	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/logger"
+	"github.com/skx/rss2email/oauth"
+	"github.com/skx/rss2email/secret"
+	"github.com/skx/rss2email/tracing"
+	"go.opentelemetry.io/otel/attribute"
 )
 
 // HTTPFetch is our state-storing structure
 type HTTPFetch struct {
 
-	// The URL we should fetch
-	url string
+	// The URL we should fetch, and the configuration of its feed.
+	url   string
+	entry configfile.Feed
+
+	// The directory holding the fixtures used instead of fetching
+	// feeds, if any.
+	fixtures string
 
 	// Contents of the remote URL, used for testing
 	content string
@@ -35,6 +50,77 @@ type HTTPFetch struct {
 
 	// The User-Agent header to send when making our HTTP fetch
 	userAgent string
+
+	// The URL we fetched, after following any redirects.
+	final string
+
+	// The address of the DNS server to use, if not the system's.
+	dnsServer string
+
+	// The URL of the DNS-over-HTTPS server to use, if any.
+	doh string
+
+	// The version of IP to connect via, "4" or "6", if we're
+	// restricted to one.
+	ipVersion string
+
+	// The address to connect to, rather than the host of the URL,
+	// or the path of a unix socket to connect to instead.
+	connectTo  string
+	unixSocket string
+
+	// The path of the cookies.txt file to use, if any, and the jar
+	// holding its cookies whilst we fetch.
+	cookies string
+	jar     *cookieJar
+
+	// The OAuth2 client credentials used to authenticate, if any.
+	oauth *oauth.Config
+
+	// The number of items whose implausible dates were removed.
+	bogus int
+
+	// The validators we send, to fetch the feed only if it has
+	// changed, and those the server returned.
+	validators Validators
+	returned   Validators
+
+	// Set if the server told us the feed hasn't changed.
+	notModified bool
+
+	// logger receives our messages.
+	logger logger.Logger
+}
+
+// Validators hold the values of the ETag, and Last-Modified, headers of a
+// response, which allow the feed to be requested only if it has changed.
+type Validators struct {
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+}
+
+// parseError is returned if the feed was fetched, but couldn't be parsed,
+// which isn't worth retrying.
+type parseError struct {
+	error
+}
+
+// countingReader counts the bytes read from the given reader, and records
+// the error which stopped us reading, if any.
+type countingReader struct {
+	r   io.Reader
+	n   int
+	err error
+}
+
+// Read is part of the io.Reader interface.
+func (c *countingReader) Read(p []byte) (int, error) {
+	n, err := c.r.Read(p)
+	c.n += n
+	if err != nil && err != io.EOF {
+		c.err = err
+	}
+	return n, err
 }
 
 // New creates a new object which will fetch our content
@@ -42,9 +128,11 @@ func New(entry configfile.Feed) *HTTPFetch {
 
 	// Create object with defaults
 	state := &HTTPFetch{url: entry.URL,
+		entry:      entry,
 		maxRetries: 3,
 		retryDelay: 1000 * time.Millisecond,
 		userAgent:  "rss2email (https://github.com/skx/rss2email)",
+		logger:     logger.Discard,
 	}
 
 	// Are any of our options overridden?
@@ -72,6 +160,45 @@ func New(entry configfile.Feed) *HTTPFetch {
 		if opt.Name == "user-agent" {
 			state.userAgent = opt.Value
 		}
+
+		// DNS resolution.
+		if opt.Name == "resolver" {
+			state.dnsServer = opt.Value
+		}
+		if opt.Name == "doh" {
+			state.doh = opt.Value
+		}
+		if opt.Name == "ip-version" {
+			state.ipVersion = opt.Value
+		}
+
+		// Connection targets.
+		if opt.Name == "connect-to" {
+			state.connectTo = opt.Value
+		}
+		if opt.Name == "unix-socket" {
+			state.unixSocket = opt.Value
+		}
+
+		// Cookies.
+		if opt.Name == "cookies" {
+			state.cookies = CookiesPath(opt.Value)
+		}
+
+		// OAuth2 client credentials.
+		if strings.HasPrefix(opt.Name, "oauth-") && state.oauth == nil {
+			state.oauth = &oauth.Config{}
+		}
+		switch opt.Name {
+		case "oauth-url":
+			state.oauth.TokenURL = opt.Value
+		case "oauth-id":
+			state.oauth.ClientID = opt.Value
+		case "oauth-secret":
+			state.oauth.ClientSecret = opt.Value
+		case "oauth-scope":
+			state.oauth.Scopes = append(state.oauth.Scopes, strings.Fields(strings.ReplaceAll(opt.Value, ",", " "))...)
+		}
 	}
 
 	return state
@@ -82,44 +209,154 @@ func New(entry configfile.Feed) *HTTPFetch {
 // If the `content` field is non-empty it will be used in preference
 // to the remote URLs content, for testing.
 func (h *HTTPFetch) Fetch() (*gofeed.Feed, error) {
+	return h.FetchContext(context.Background())
+}
+
+// FetchContext performs the HTTP-fetch, and returns the feed-contents,
+// recording the time taken to fetch and parse the feed as spans within
+// the given context.  The feed is parsed as it is downloaded, so the
+// parse span is a child of the fetch span.
+//
+// If the context is cancelled, or its deadline passes, an in-progress
+// fetch is abandoned, and no further attempts are made.
+func (h *HTTPFetch) FetchContext(ctx context.Context) (*gofeed.Feed, error) {
 
 	var feed *gofeed.Feed
 	var err error
 
-	// Download contents, if not already present.
-	for i := 0; h.content == "" && i < h.maxRetries; i++ {
+	ctx, span := tracing.Start(ctx, "fetch", attribute.String("feed.url", h.url))
 
-		err = h.fetch()
+	// Use our fixture, if we're using fixtures.
+	if h.fixtures != "" && h.content == "" {
+		path, err := FixturePath(h.fixtures, h.entry)
+		var data []byte
 		if err == nil {
+			data, err = os.ReadFile(path)
+		}
+		if err == nil && len(data) == 0 {
+			err = fmt.Errorf("the fixture %s is empty", path)
+		}
+		if err != nil {
+			tracing.End(span, err)
+			return nil, err
+		}
+		h.content = string(data)
+		h.final = h.url
+	}
+
+	// Load our cookies, if we have any.
+	if h.cookies != "" && h.content == "" {
+		h.jar, err = loadCookies(h.cookies)
+		if err != nil {
+			err = fmt.Errorf("failed to load cookies: %s", err)
+			tracing.End(span, err)
+			return nil, err
+		}
+	}
+
+	// Parse the contents we were given, or download the feed, which
+	// is parsed as it arrives.
+	attempts := 0
+	bytes := len(h.content)
+	if h.content != "" {
+		feed, err = h.parse(ctx, strings.NewReader(h.content))
+	}
+	for i := 0; h.content == "" && i < h.maxRetries; i++ {
+
+		attempts++
+		feed, bytes, err = h.fetch(ctx)
+		var perr parseError
+		if err == nil || ctx.Err() != nil || errors.As(err, &perr) {
 			break
 		}
-		time.Sleep(h.retryDelay)
+		h.logger.Infof("\tAttempt %d of %d to fetch %s failed: %s\n", attempts, h.maxRetries, h.url, err)
+
+		select {
+		case <-ctx.Done():
+		case <-time.After(h.retryDelay):
+		}
+
+	}
 
+	// Save any cookies the server set, for next time.
+	if h.jar != nil && h.jar.changed {
+		serr := h.jar.save(h.cookies)
+		if serr != nil && err == nil {
+			err = fmt.Errorf("failed to save cookies to %s: %s", h.cookies, serr)
+		}
 	}
 
+	span.SetAttributes(attribute.Int("fetch.attempts", attempts), attribute.Int("fetch.bytes", bytes))
+	tracing.End(span, err)
+
 	// Failed, after all the retries?
 	if err != nil {
-		return feed, err
+		return nil, err
 	}
 
-	// Parse it
-	fp := gofeed.NewParser()
-	feed, err2 := fp.ParseString(h.content)
-	if err2 != nil {
-		return nil, fmt.Errorf("error parsing %s contents: %s", h.url, err2.Error())
-	}
+	// Some feeds date their items in 1970, or years in the future.
+	h.bogus = saneDates(feed, time.Now())
 
 	return feed, nil
 }
 
-// fetchURL fetches the text from the remote URL.
-func (h *HTTPFetch) fetch() error {
+// FinalURL returns the URL from which the feed was fetched, after following
+// any redirects.  It is empty if the feed has not been fetched.
+func (h *HTTPFetch) FinalURL() string {
+	return h.final
+}
+
+// SetLogger sets the logger which receives our messages, which are
+// discarded by default.
+func (h *HTTPFetch) SetLogger(l logger.Logger) {
+	h.logger = l
+}
+
+// SetValidators sets the validators of the response we received when we
+// last fetched the feed, so that it is only sent again if it has changed.
+func (h *HTTPFetch) SetValidators(v Validators) {
+	h.validators = v
+}
+
+// Validators returns the validators of the response to our fetch, which
+// should be given to SetValidators when the feed is next fetched.
+func (h *HTTPFetch) Validators() Validators {
+	return h.returned
+}
+
+// NotModified returns true if the feed hadn't changed since it was last
+// fetched, in which case the feed we returned has no items.
+func (h *HTTPFetch) NotModified() bool {
+	return h.notModified
+}
+
+// BogusDates returns the number of items of the feed we fetched whose
+// dates were implausible, and so were removed.
+func (h *HTTPFetch) BogusDates() int {
+	return h.bogus
+}
+
+// fetch fetches the remote URL, and parses the feed as it is downloaded,
+// returning it along with the number of bytes we read.
+func (h *HTTPFetch) fetch(ctx context.Context) (*gofeed.Feed, int, error) {
+
+	// Expand any bridge entry
+	uri, err := bridge.Expand(h.url)
+	if err != nil {
+		return nil, 0, err
+	}
 
 	// Create a HTTP-client
-	client := &http.Client{}
-	req, err := http.NewRequest("GET", h.url, nil)
+	client, err := h.client()
 	if err != nil {
-		return err
+		return nil, 0, err
+	}
+	if h.jar != nil {
+		client.Jar = h.jar
+	}
+	req, err := http.NewRequestWithContext(ctx, "GET", uri, nil)
+	if err != nil {
+		return nil, 0, err
 	}
 
 	// Populate the HTTP User-Agent header.
@@ -127,15 +364,93 @@ func (h *HTTPFetch) fetch() error {
 	// Some sites (e.g. reddit) fail without a header set.
 	req.Header.Set("User-Agent", h.userAgent)
 
+	// Only fetch the feed if it has changed, if we can tell.
+	if h.validators.ETag != "" {
+		req.Header.Set("If-None-Match", h.validators.ETag)
+	}
+	if h.validators.LastModified != "" {
+		req.Header.Set("If-Modified-Since", h.validators.LastModified)
+	}
+
+	// Present our access token, if we need one.
+	var conf oauth.Config
+	if h.oauth != nil {
+		if h.oauth.TokenURL == "" || h.oauth.ClientID == "" {
+			return nil, 0, fmt.Errorf("the oauth-url, and oauth-id, options are required to use OAuth2")
+		}
+
+		// The client secret may be a reference to a secret.
+		conf = *h.oauth
+		conf.ClientSecret, err = secret.Resolve(conf.ClientSecret)
+		if err != nil {
+			return nil, 0, err
+		}
+
+		token, err := conf.Token(ctx)
+		if err != nil {
+			return nil, 0, err
+		}
+		req.Header.Set("Authorization", token.Header())
+	}
+
 	// Make the actual HTTP request.
 	resp, err := client.Do(req)
 	if err != nil {
-		return err
+		return nil, 0, err
 	}
 	defer resp.Body.Close()
 
-	// save the result
-	data, err2 := ioutil.ReadAll(resp.Body)
-	h.content = string(data)
-	return err2
+	// If our token was refused then forget it, so that we request
+	// another if we retry.
+	if h.oauth != nil && resp.StatusCode == http.StatusUnauthorized {
+		conf.Invalidate()
+		return nil, 0, fmt.Errorf("%s refused our access token: %s", uri, resp.Status)
+	}
+
+	// Record where we ended up.
+	h.final = resp.Request.URL.String()
+
+	// Nothing has changed since we last fetched the feed.
+	if resp.StatusCode == http.StatusNotModified && h.validators != (Validators{}) {
+		h.notModified = true
+		h.returned = h.validators
+		if etag := resp.Header.Get("ETag"); etag != "" {
+			h.returned.ETag = etag
+		}
+		return &gofeed.Feed{}, 0, nil
+	}
+
+	// Parse the feed as we read it, rather than holding all of it.
+	body := &countingReader{r: resp.Body}
+	feed, err := h.parse(ctx, body)
+	if body.err != nil {
+		return nil, body.n, body.err
+	}
+	if err != nil {
+		return nil, body.n, err
+	}
+
+	h.notModified = false
+	h.returned = Validators{
+		ETag:         resp.Header.Get("ETag"),
+		LastModified: resp.Header.Get("Last-Modified"),
+	}
+	return feed, body.n, nil
+}
+
+// parse parses the feed read from the given reader.
+func (h *HTTPFetch) parse(ctx context.Context, r io.Reader) (*gofeed.Feed, error) {
+
+	_, span := tracing.Start(ctx, "parse", attribute.String("feed.url", h.url))
+
+	feed, err := gofeed.NewParser().Parse(r)
+	if err != nil {
+		err = parseError{fmt.Errorf("error parsing %s contents: %s", h.url, err.Error())}
+		tracing.End(span, err)
+		return nil, err
+	}
+
+	span.SetAttributes(attribute.Int("feed.items", len(feed.Items)))
+	tracing.End(span, nil)
+	return feed, nil
 }
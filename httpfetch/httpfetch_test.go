@@ -1,6 +1,7 @@
 package httpfetch
 
 import (
+	"context"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
@@ -183,6 +184,34 @@ func TestRetry(t *testing.T) {
 	}
 }
 
+// TestFetchCancelled ensures a fetch is abandoned once its context has
+// timed out, rather than being retried.
+func TestFetchCancelled(t *testing.T) {
+
+	// Setup a stub server which never responds
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer ts.Close()
+
+	obj := New(configfile.Feed{URL: ts.URL})
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := obj.FetchContext(ctx)
+	if err == nil {
+		t.Fatalf("expected an error from the fetch")
+	}
+	if time.Since(start) > 2*time.Second {
+		t.Fatalf("fetch wasn't abandoned, it took %s", time.Since(start))
+	}
+}
+
 // Make a HTTP-request against a local entry
 func TestHTTPFetch(t *testing.T) {
 
@@ -274,3 +303,76 @@ func TestHTTPFetchValid(t *testing.T) {
 		t.Fatalf("wrong feed count")
 	}
 }
+
+// Redirects are followed, and the final URL recorded
+func TestFinalURL(t *testing.T) {
+
+	var ts *httptest.Server
+	ts = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/old" {
+			http.Redirect(w, r, ts.URL+"/new", http.StatusMovedPermanently)
+			return
+		}
+		fmt.Fprintln(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title></channel></rss>`)
+	}))
+	defer ts.Close()
+
+	obj := New(configfile.Feed{URL: ts.URL + "/old"})
+	if obj.FinalURL() != "" {
+		t.Fatalf("unexpected final URL before fetching")
+	}
+
+	_, err := obj.Fetch()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if obj.FinalURL() != ts.URL+"/new" {
+		t.Fatalf("unexpected final URL: %s", obj.FinalURL())
+	}
+}
+
+// The feed is only fetched if it has changed, given the validators of the
+// last response.
+func TestNotModified(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("If-None-Match") == `"v1"` || r.Header.Get("If-Modified-Since") == "Mon, 01 Jan 2024 12:00:00 GMT" {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", `"v1"`)
+		w.Header().Set("Last-Modified", "Mon, 01 Jan 2024 12:00:00 GMT")
+		fmt.Fprintln(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>T</title><item><title>One</title></item></channel></rss>`)
+	}))
+	defer ts.Close()
+
+	obj := New(configfile.Feed{URL: ts.URL})
+	feed, err := obj.Fetch()
+	if err != nil || len(feed.Items) != 1 || obj.NotModified() {
+		t.Fatalf("unexpected result: %v %v", feed, err)
+	}
+	v := obj.Validators()
+	if v.ETag != `"v1"` || v.LastModified != "Mon, 01 Jan 2024 12:00:00 GMT" {
+		t.Fatalf("unexpected validators: %v", v)
+	}
+
+	for _, v := range []Validators{{ETag: `"v1"`}, {LastModified: "Mon, 01 Jan 2024 12:00:00 GMT"}} {
+		obj = New(configfile.Feed{URL: ts.URL})
+		obj.SetValidators(v)
+		feed, err = obj.Fetch()
+		if err != nil || len(feed.Items) != 0 || !obj.NotModified() {
+			t.Fatalf("unexpected result: %v %v", feed, err)
+		}
+		if obj.Validators() != v {
+			t.Fatalf("unexpected validators: %v", obj.Validators())
+		}
+	}
+
+	// Stale validators fetch the feed again.
+	obj = New(configfile.Feed{URL: ts.URL})
+	obj.SetValidators(Validators{ETag: `"v0"`})
+	feed, err = obj.Fetch()
+	if err != nil || len(feed.Items) != 1 || obj.NotModified() {
+		t.Fatalf("unexpected result: %v %v", feed, err)
+	}
+}
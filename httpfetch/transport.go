@@ -0,0 +1,135 @@
+package httpfetch
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// CheckOption returns an error if the given per-feed option is one of
+// those which control how we connect to the host of a feed, and its value
+// is invalid.  The cookies.txt file named by a "cookies" option must be
+// valid, if it exists.
+func CheckOption(opt configfile.Option) error {
+
+	var err error
+	switch opt.Name {
+	case "resolver":
+		_, err = parseResolver(opt.Value)
+	case "doh":
+		_, err = parseDoH(opt.Value)
+	case "ip-version":
+		_, err = parseIPVersion(opt.Value)
+	case "connect-to":
+		err = parseConnectTo(opt.Value)
+	case "unix-socket":
+		if strings.TrimSpace(opt.Value) == "" {
+			err = fmt.Errorf("invalid unix-socket '%s', expected the path to a socket", opt.Value)
+		}
+	case "cookies":
+		_, err = loadCookies(CookiesPath(opt.Value))
+	case "oauth-url":
+		u, uerr := url.Parse(opt.Value)
+		if uerr != nil || (u.Scheme != "https" && u.Scheme != "http") || u.Host == "" {
+			err = fmt.Errorf("invalid oauth-url '%s', expected the URL of a token endpoint", opt.Value)
+		}
+	}
+	return err
+}
+
+// parseConnectTo validates the "connect-to" option, which holds the host,
+// and optionally the port, to connect to instead of those of the URL.
+func parseConnectTo(value string) error {
+
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return fmt.Errorf("invalid connect-to '%s', expected host[:port]", value)
+	}
+	if _, port, err := net.SplitHostPort(value); err == nil && port == "" {
+		return fmt.Errorf("invalid connect-to '%s', expected host[:port]", value)
+	}
+	return nil
+}
+
+// target returns the address we should connect to, in place of the given
+// address taken from the URL of our feed.  The port of the URL is used if
+// the "connect-to" option doesn't specify one.
+func (h *HTTPFetch) target(addr string) string {
+
+	if h.connectTo == "" {
+		return addr
+	}
+
+	value := strings.TrimSpace(h.connectTo)
+	if _, _, err := net.SplitHostPort(value); err == nil {
+		return value
+	}
+
+	_, port, err := net.SplitHostPort(addr)
+	if err != nil {
+		return value
+	}
+	return net.JoinHostPort(strings.Trim(value, "[]"), port)
+}
+
+// client returns the HTTP client to use when fetching our feed, which
+// honours any of the options controlling how we connect to its host.
+//
+// The URL of the feed is unchanged, so the Host header we send, and the
+// name we expect its TLS certificate to hold, are those of its host even
+// if we connect elsewhere.
+func (h *HTTPFetch) client() (*http.Client, error) {
+
+	if h.dnsServer == "" && h.doh == "" && h.ipVersion == "" && h.connectTo == "" && h.unixSocket == "" {
+		return &http.Client{}, nil
+	}
+
+	if h.connectTo != "" {
+		if err := parseConnectTo(h.connectTo); err != nil {
+			return nil, err
+		}
+	}
+
+	resolver, err := h.resolver()
+	if err != nil {
+		return nil, err
+	}
+
+	network := ""
+	if h.ipVersion != "" {
+		network, err = parseIPVersion(h.ipVersion)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	dialer := &net.Dialer{
+		Timeout:   30 * time.Second,
+		KeepAlive: 30 * time.Second,
+		Resolver:  resolver,
+	}
+
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	transport.DialContext = func(ctx context.Context, proto, addr string) (net.Conn, error) {
+		if h.unixSocket != "" {
+			return dialer.DialContext(ctx, "unix", h.unixSocket)
+		}
+		if network != "" {
+			proto = network
+		}
+		return dialer.DialContext(ctx, proto, h.target(addr))
+	}
+
+	// A proxy would defeat the point of choosing where we connect.
+	if h.connectTo != "" || h.unixSocket != "" {
+		transport.Proxy = nil
+	}
+
+	return &http.Client{Transport: transport}, nil
+}
@@ -0,0 +1,159 @@
+package httpfetch
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestConnectTo ensures we connect to the given address, rather than the
+// host of the URL.
+func TestConnectTo(t *testing.T) {
+
+	uri := feedServer(t, "feed.rss2email.invalid")
+
+	// Without a port that of the URL is used.
+	obj := New(configfile.Feed{URL: uri, Options: []configfile.Option{
+		{Name: "connect-to", Value: "127.0.0.1"},
+	}})
+	_, err := obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	// With one the port of the URL is ignored.
+	_, port, _ := net.SplitHostPort(strings.TrimSuffix(strings.TrimPrefix(uri, "http://"), "/"))
+	obj = New(configfile.Feed{URL: "http://feed.rss2email.invalid:1/", Options: []configfile.Option{
+		{Name: "connect-to", Value: "127.0.0.1:" + port},
+	}})
+	_, err = obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestUnixSocket ensures we may fetch a feed via a unix socket.
+func TestUnixSocket(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("unix sockets aren't available")
+	}
+
+	path := filepath.Join(t.TempDir(), "http.sock")
+	l, err := net.Listen("unix", path)
+	if err != nil {
+		t.Skipf("failed to listen: %s", err)
+	}
+	defer l.Close()
+
+	host := ""
+	go http.Serve(l, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		host = r.Host
+		fmt.Fprintln(w, "Hello, client")
+	}))
+
+	obj := New(configfile.Feed{URL: "http://feed.rss2email.invalid/rss", Options: []configfile.Option{
+		{Name: "unix-socket", Value: path},
+	}})
+	_, err = obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if host != "feed.rss2email.invalid" {
+		t.Fatalf("unexpected Host header: %s", host)
+	}
+}
+
+// TestCheckOption ensures invalid connection options are reported.
+func TestCheckOption(t *testing.T) {
+
+	valid := []configfile.Option{
+		{Name: "resolver", Value: "1.1.1.1"},
+		{Name: "resolver", Value: "[2606:4700::1111]:53"},
+		{Name: "doh", Value: "https://cloudflare-dns.com/dns-query"},
+		{Name: "ip-version", Value: "6"},
+		{Name: "connect-to", Value: "192.0.2.10"},
+		{Name: "connect-to", Value: "[2001:db8::1]:8080"},
+		{Name: "unix-socket", Value: "/run/app.sock"},
+		{Name: "retry", Value: "bogus"},
+	}
+	for _, opt := range valid {
+		if err := CheckOption(opt); err != nil {
+			t.Fatalf("unexpected error with %v: %s", opt, err)
+		}
+	}
+
+	invalid := []configfile.Option{
+		{Name: "resolver", Value: ""},
+		{Name: "doh", Value: "http://example.com/dns-query"},
+		{Name: "ip-version", Value: "5"},
+		{Name: "connect-to", Value: "192.0.2.10:"},
+		{Name: "unix-socket", Value: " "},
+		{Name: "oauth-url", Value: "/token"},
+	}
+	for _, opt := range invalid {
+		if err := CheckOption(opt); err == nil {
+			t.Fatalf("expected error with %v", opt)
+		}
+	}
+
+	if addr, _ := parseResolver("1.1.1.1"); addr != "1.1.1.1:53" {
+		t.Fatalf("default port wasn't added: %s", addr)
+	}
+}
+
+// TestOAuth ensures we present an access token, and request another if
+// ours is refused.
+func TestOAuth(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	issued := 0
+	auth := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		issued++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "token_type": "Bearer", "expires_in": 3600}`, issued)
+	}))
+	defer auth.Close()
+
+	// The feed only accepts the second token we're issued.
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token-2" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		fmt.Fprintln(w, "Hello, client")
+	}))
+	defer ts.Close()
+
+	obj := New(configfile.Feed{URL: ts.URL, Options: []configfile.Option{
+		{Name: "oauth-url", Value: auth.URL},
+		{Name: "oauth-id", Value: "rss2email"},
+		{Name: "oauth-secret", Value: "secret"},
+		{Name: "delay", Value: "1"},
+	}})
+	_, err := obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "Failed to detect feed type") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if issued != 2 {
+		t.Fatalf("unexpected number of tokens issued: %d", issued)
+	}
+
+	// Both the URL, and ID, are required.
+	obj = New(configfile.Feed{URL: ts.URL, Options: []configfile.Option{
+		{Name: "oauth-id", Value: "rss2email"},
+		{Name: "retry", Value: "1"},
+	}})
+	_, err = obj.Fetch()
+	if err == nil || !strings.Contains(err.Error(), "options are required") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
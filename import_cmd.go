@@ -1,14 +1,17 @@
 //
-// Import an OPML feedlist.
+// Import an OPML, or newsboat, feedlist.
 //
 
 package main
 
 import (
+	"bufio"
+	"bytes"
 	"encoding/xml"
 	"flag"
 	"fmt"
-	"io/ioutil"
+	"os"
+	"strings"
 
 	"github.com/skx/rss2email/configfile"
 	"github.com/skx/subcommands"
@@ -28,6 +31,126 @@ type outline struct {
 	XMLURL  string `xml:"xmlUrl,attr"`
 	HTMLURL string `xml:"htmlUrl,attr"`
 	Favicon string `xml:"rssfr-favicon,attr"`
+
+	// Outlines contains the children of folders.
+	Outlines []outline `xml:"outline"`
+}
+
+// feeds returns the feeds beneath this outline, with folders mapped
+// to groups.
+func (o outline) feeds(groups []string) []configfile.Feed {
+
+	if o.XMLURL != "" {
+		feed := configfile.Feed{URL: o.XMLURL}
+		if len(groups) > 0 {
+			feed.Options = append(feed.Options, configfile.Option{Name: "group", Value: strings.Join(groups, "/")})
+		}
+		return []configfile.Feed{feed}
+	}
+
+	// This is a folder, so descend into it.
+	name := o.Title
+	if name == "" {
+		name = o.Text
+	}
+	if name != "" {
+		groups = append(groups[:len(groups):len(groups)], name)
+	}
+
+	var feeds []configfile.Feed
+	for _, child := range o.Outlines {
+		feeds = append(feeds, child.feeds(groups)...)
+	}
+	return feeds
+}
+
+// parseOPML returns the feeds contained in the given OPML document.
+func parseOPML(data []byte) ([]configfile.Feed, error) {
+
+	o := opml{}
+	err := xml.Unmarshal(data, &o)
+	if err != nil {
+		return nil, err
+	}
+
+	var feeds []configfile.Feed
+	for _, outline := range o.Outlines {
+		feeds = append(feeds, outline.feeds(nil)...)
+	}
+	return feeds, nil
+}
+
+// parseNewsboat returns the feeds contained in the given newsboat `urls`
+// file, with tags mapped to groups.
+//
+// Entries look like this, with renames prefixed by "~", and query-feeds
+// (which have no URL) ignored:
+//
+//	https://example.com/index.rss tag1 "another tag" "~Example Blog"
+func parseNewsboat(data []byte) ([]configfile.Feed, error) {
+
+	var feeds []configfile.Feed
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for scanner.Scan() {
+		fields, err := splitQuoted(strings.TrimSpace(scanner.Text()))
+		if err != nil {
+			return nil, err
+		}
+		if len(fields) == 0 || strings.HasPrefix(fields[0], "#") {
+			continue
+		}
+
+		// Only import real feeds, not queries, or filters.
+		if !strings.Contains(fields[0], "://") {
+			continue
+		}
+
+		feed := configfile.Feed{URL: fields[0]}
+		for _, tag := range fields[1:] {
+			if tag == "" || strings.HasPrefix(tag, "~") || strings.HasPrefix(tag, "!") {
+				continue
+			}
+			feed.Options = append(feed.Options, configfile.Option{Name: "group", Value: tag})
+		}
+		feeds = append(feeds, feed)
+	}
+	return feeds, scanner.Err()
+}
+
+// splitQuoted splits the given line into whitespace-separated fields,
+// honouring double-quotes.
+func splitQuoted(line string) ([]string, error) {
+
+	var fields []string
+	var cur strings.Builder
+	quoted := false
+	inField := false
+
+	for _, r := range line {
+		switch {
+		case r == '"':
+			quoted = !quoted
+			inField = true
+		case !quoted && (r == ' ' || r == '\t'):
+			if inField {
+				fields = append(fields, cur.String())
+				cur.Reset()
+				inField = false
+			}
+		default:
+			cur.WriteRune(r)
+			inField = true
+		}
+	}
+
+	if quoted {
+		return nil, fmt.Errorf("unterminated quote: %s", line)
+	}
+	if inField {
+		fields = append(fields, cur.String())
+	}
+	return fields, nil
 }
 
 // Structure for our options and state.
@@ -42,10 +165,15 @@ type importCmd struct {
 
 // Info is part of the subcommand-API
 func (i *importCmd) Info() (string, string) {
-	return "import", `Import a list of feeds via an OPML file.
+	return "import", `Import a list of feeds via an OPML, or newsboat, file.
 
 This command imports a series of feeds from the specified OPML
-file into the configuration file this application uses.
+file into the configuration file this application uses.  Files
+which are not XML are assumed to be newsboat 'urls' files.
+
+Feeds contained within folders (as exported by Liferea, or feedly)
+are assigned to a group of the same name, via the per-feed 'group'
+option, as are the tags of newsboat feeds.
 
 To see details of the configuration file, including the location,
 please run:
@@ -55,6 +183,7 @@ please run:
 Example:
 
     $ rss2email import file1.opml file2.opml .. fileN.opml
+    $ rss2email import ~/.newsboat/urls
 `
 }
 
@@ -82,25 +211,27 @@ func (i *importCmd) Execute(args []string) int {
 	for _, file := range args {
 
 		// Read content
-		data, err := ioutil.ReadFile(file)
+		data, err := os.ReadFile(file)
 		if err != nil {
 			fmt.Printf("failed to read %s: %s\n", file, err.Error())
 			continue
 		}
 
 		// Parse
-		o := opml{}
-		err = xml.Unmarshal(data, &o)
+		var feeds []configfile.Feed
+		if bytes.HasPrefix(bytes.TrimSpace(data), []byte("<")) {
+			feeds, err = parseOPML(data)
+		} else {
+			feeds, err = parseNewsboat(data)
+		}
 		if err != nil {
 			fmt.Printf("failed to parse %s: %s\n", file, err.Error())
 			continue
 		}
 
-		for _, outline := range o.Outlines {
-
-			if outline.XMLURL != "" {
-				fmt.Printf("Adding %s\n", outline.XMLURL)
-				i.config.Add(outline.XMLURL)
+		for _, feed := range feeds {
+			if i.config.AddFeed(feed) {
+				fmt.Printf("Adding %s\n", feed.URL)
 			}
 		}
 
@@ -1,8 +1,9 @@
 package main
 
 import (
-	"io/ioutil"
 	"os"
+	"path/filepath"
+	"strings"
 	"testing"
 
 	"github.com/skx/rss2email/configfile"
@@ -17,7 +18,7 @@ https://example.net/
  - foo: bar
 `
 	data := []byte(content)
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("Error creating temporary file")
 	}
@@ -30,7 +31,7 @@ https://example.net/
 	}
 
 	// Create an OPML file to use as input
-	opml, err := ioutil.TempFile("", "opml")
+	opml, err := os.CreateTemp("", "opml")
 	if err != nil {
 		t.Fatalf("Error creating temporary file for OMPL input")
 	}
@@ -51,7 +52,7 @@ https://example.net/
 </body>
 </opml>
 `)
-	err = ioutil.WriteFile(opml.Name(), d1, 0644)
+	err = os.WriteFile(opml.Name(), d1, 0644)
 	if err != nil {
 		t.Fatalf("failed to write OPML file")
 	}
@@ -78,3 +79,92 @@ https://example.net/
 	os.Remove(tmpfile.Name())
 	os.Remove(opml.Name())
 }
+
+func TestImportGroups(t *testing.T) {
+
+	dir := t.TempDir()
+	config := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(config, []byte("# Feeds\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config file")
+	}
+
+	// An OPML file with folders, as exported by Liferea
+	opml := filepath.Join(dir, "feeds.opml")
+	err = os.WriteFile(opml, []byte(`<?xml version="1.0"?>
+<opml version="1.0">
+<body>
+<outline title="News" text="News">
+  <outline title="Local" text="Local">
+    <outline xmlUrl="https://local.example.com/rss"/>
+  </outline>
+  <outline xmlUrl="https://news.example.com/rss"/>
+</outline>
+<outline xmlUrl="https://example.com/rss"/>
+</body>
+</opml>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write OPML file")
+	}
+
+	// A newsboat urls file
+	urls := filepath.Join(dir, "urls")
+	err = os.WriteFile(urls, []byte(`# comment
+https://blog.example.com/rss tech "open source" "~My Blog"
+"query:Unread:unread = \"yes\""
+https://news.example.com/rss
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write urls file")
+	}
+
+	im := importCmd{}
+	im.config = configfile.NewWithPath(config)
+	im.Execute([]string{opml, urls})
+
+	entries, err := configfile.NewWithPath(config).Parse()
+	if err != nil {
+		t.Fatalf("error parsing the (updated) config file: %s", err)
+	}
+
+	groups := make(map[string][]string)
+	for _, entry := range entries {
+		groups[entry.URL] = []string{}
+		for _, opt := range entry.Options {
+			if opt.Name == "group" {
+				groups[entry.URL] = append(groups[entry.URL], opt.Value)
+			}
+		}
+	}
+
+	expected := map[string]string{
+		"https://local.example.com/rss": "News/Local",
+		"https://news.example.com/rss":  "News",
+		"https://example.com/rss":       "",
+		"https://blog.example.com/rss":  "tech,open source",
+	}
+	if len(groups) != len(expected) {
+		t.Fatalf("unexpected entries: %v", groups)
+	}
+	for url, group := range expected {
+		if strings.Join(groups[url], ",") != group {
+			t.Errorf("%s: expected groups %q, got %v", url, group, groups[url])
+		}
+	}
+}
+
+func TestSplitQuoted(t *testing.T) {
+
+	fields, err := splitQuoted(`a "b c"  d`)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(fields, "|") != "a|b c|d" {
+		t.Fatalf("unexpected fields: %v", fields)
+	}
+
+	_, err = splitQuoted(`a "b`)
+	if err == nil {
+		t.Fatalf("expected error with unterminated quote")
+	}
+}
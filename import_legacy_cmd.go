@@ -0,0 +1,297 @@
+//
+// Import the configuration of the Python rss2email.
+//
+
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"flag"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// legacyState is the structure of the state-file of the Python rss2email.
+type legacyState struct {
+	Version int `json:"version"`
+	Feeds   []struct {
+		Name string                     `json:"name"`
+		URL  string                     `json:"url"`
+		Seen map[string]json.RawMessage `json:"seen"`
+	} `json:"feeds"`
+}
+
+// legacyOptions are the per-feed settings of the Python rss2email which
+// we preserve, as they have the same names as our per-feed options.
+var legacyOptions = []string{"to", "from", "user-agent"}
+
+// Structure for our options and state.
+type importLegacyCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// The path to the state-file of the Python rss2email.
+	state string
+
+	// Should we import the seen-state?
+	seen bool
+
+	// markSeen records the given GUID as having been seen, and is
+	// replaced during testing.
+	markSeen func(guid string)
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (i *importLegacyCmd) Arguments(flags *flag.FlagSet) {
+	i.config = configfile.New()
+
+	flags.StringVar(&i.state, "state", legacyDataPath(), "The path to the state-file of the Python rss2email.")
+	flags.BoolVar(&i.seen, "seen", true, "Import the record of which items have been seen?")
+}
+
+// Info is part of the subcommand-API
+func (i *importLegacyCmd) Info() (string, string) {
+	return "import-legacy", `Import the configuration of the Python rss2email.
+
+This command imports the feeds configured for the original, Python-based,
+rss2email into the configuration file this application uses.
+
+The 'to', 'from', and 'user-agent' settings of each feed are preserved,
+and feeds which were marked as inactive are skipped.
+
+Where possible the record of which items have been seen is imported too,
+to avoid a flood of emails on the first run.  This uses the state-file
+which is found at '~/.local/share/rss2email.json' by default.
+
+To see details of the configuration file, including the location,
+please run:
+
+   $ rss2email help config
+
+Example:
+
+    $ rss2email import-legacy ~/.config/rss2email.cfg
+`
+}
+
+// legacyConfigPath returns the default location of the configuration file
+// of the Python rss2email.
+func legacyConfigPath() string {
+	dir := os.Getenv("XDG_CONFIG_HOME")
+	if dir == "" {
+		dir = filepath.Join(configfile.New().Home(), ".config")
+	}
+	return filepath.Join(dir, "rss2email.cfg")
+}
+
+// legacyDataPath returns the default location of the state-file of the
+// Python rss2email.
+func legacyDataPath() string {
+	dir := os.Getenv("XDG_DATA_HOME")
+	if dir == "" {
+		dir = filepath.Join(configfile.New().Home(), ".local", "share")
+	}
+	return filepath.Join(dir, "rss2email.json")
+}
+
+// parseINI parses the given file, returning the values in each section.
+//
+// Values in the "DEFAULT" section are not merged into the others.
+func parseINI(path string) (map[string]map[string]string, []string, error) {
+
+	sections := make(map[string]map[string]string)
+	order := []string{}
+
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer file.Close()
+
+	section := ""
+	key := ""
+
+	scanner := bufio.NewScanner(file)
+	for scanner.Scan() {
+		line := scanner.Text()
+		trimmed := strings.TrimSpace(line)
+
+		// Comments and blank lines
+		if trimmed == "" || strings.HasPrefix(trimmed, "#") || strings.HasPrefix(trimmed, ";") {
+			continue
+		}
+
+		// Continuation lines
+		if (line[0] == ' ' || line[0] == '\t') && section != "" && key != "" {
+			sections[section][key] += "\n" + trimmed
+			continue
+		}
+
+		// Section headers
+		if strings.HasPrefix(trimmed, "[") && strings.HasSuffix(trimmed, "]") {
+			section = strings.TrimSpace(trimmed[1 : len(trimmed)-1])
+			key = ""
+			if _, ok := sections[section]; !ok {
+				sections[section] = make(map[string]string)
+				order = append(order, section)
+			}
+			continue
+		}
+
+		if section == "" {
+			return nil, nil, fmt.Errorf("value outside a section: %s", line)
+		}
+
+		// Keys are separated from values by "=", or ":".
+		idx := strings.IndexAny(trimmed, "=:")
+		if idx < 0 {
+			return nil, nil, fmt.Errorf("invalid line: %s", line)
+		}
+		key = strings.ToLower(strings.TrimSpace(trimmed[:idx]))
+		sections[section][key] = strings.TrimSpace(trimmed[idx+1:])
+	}
+
+	return sections, order, scanner.Err()
+}
+
+// importSeen imports the seen-state of the Python rss2email, for the feeds
+// with the given names.
+//
+// It returns the number of items which were recorded.
+func (i *importLegacyCmd) importSeen(names map[string]bool) (int, error) {
+
+	data, err := os.ReadFile(i.state)
+	if err != nil {
+		return 0, err
+	}
+
+	var state legacyState
+	err = json.Unmarshal(data, &state)
+	if err != nil {
+		return 0, fmt.Errorf("failed to parse %s: %s", i.state, err)
+	}
+
+	count := 0
+	for _, feed := range state.Feeds {
+		if !names[feed.Name] {
+			continue
+		}
+
+		// The keys are the IDs of each item, which is the
+		// GUID, falling back to the link, as we use.
+		for guid := range feed.Seen {
+			i.markSeen(guid)
+			count++
+		}
+	}
+
+	return count, nil
+}
+
+// Execute is invoked if the user specifies `import-legacy` as the subcommand.
+func (i *importLegacyCmd) Execute(args []string) int {
+
+	// Default to recording state via our normal mechanism.
+	if i.markSeen == nil {
+		i.markSeen = func(guid string) {
+			item := withstate.FeedItem{Item: &gofeed.Item{GUID: guid}}
+			item.RecordSeen()
+		}
+	}
+
+	path := legacyConfigPath()
+	if len(args) > 0 {
+		path = args[0]
+	}
+
+	// Upgrade it if necessary
+	i.config.Upgrade()
+
+	_, err := i.config.Parse()
+	if err != nil && !os.IsNotExist(err) {
+		fmt.Printf("Error parsing file: %s\n", err.Error())
+		return 1
+	}
+
+	sections, order, err := parseINI(path)
+	if err != nil {
+		fmt.Printf("failed to read %s: %s\n", path, err.Error())
+		return 1
+	}
+
+	// The names of the feeds we imported
+	names := make(map[string]bool)
+
+	for _, section := range order {
+
+		if !strings.HasPrefix(section, "feed.") {
+			continue
+		}
+		name := strings.TrimPrefix(section, "feed.")
+		values := sections[section]
+
+		if values["url"] == "" {
+			fmt.Printf("Skipping %s, which has no URL\n", name)
+			continue
+		}
+
+		if active, ok := values["active"]; ok {
+			val, err := strconv.ParseBool(strings.ToLower(active))
+			if err == nil && !val {
+				fmt.Printf("Skipping %s, which is inactive\n", name)
+				continue
+			}
+		}
+
+		feed := configfile.Feed{URL: values["url"]}
+		for _, opt := range legacyOptions {
+			// Our options are a single line.
+			val := strings.Join(strings.Fields(values[opt]), " ")
+			if val != "" {
+				feed.Options = append(feed.Options, configfile.Option{Name: opt, Value: val})
+			}
+		}
+
+		if i.config.AddFeed(feed) {
+			fmt.Printf("Adding %s\n", feed.URL)
+		}
+		names[name] = true
+	}
+
+	err = i.config.Save()
+	if err != nil {
+		fmt.Printf("failed to update feed list: %s\n", err.Error())
+		return 1
+	}
+
+	// The default recipient is something the user needs to know.
+	if def, ok := sections["DEFAULT"]; ok && def["to"] != "" {
+		fmt.Printf("\nThe default recipient was %s, use this when running:\n\n", def["to"])
+		fmt.Printf("    $ rss2email cron %s\n\n", def["to"])
+	}
+
+	if !i.seen {
+		return 0
+	}
+
+	count, err := i.importSeen(names)
+	if err != nil {
+		fmt.Printf("failed to import seen-state: %s\n", err.Error())
+		return 1
+	}
+	fmt.Printf("Imported %d seen items\n", count)
+
+	return 0
+}
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestImportLegacy(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// Create a simple configuration file
+	config := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(config, []byte("https://example.org/\n"), 0644)
+	if err != nil {
+		t.Fatalf("Error writing to config file")
+	}
+
+	// The configuration of the Python rss2email
+	cfg := filepath.Join(dir, "rss2email.cfg")
+	err = os.WriteFile(cfg, []byte(`[DEFAULT]
+to = steve@example.com
+verbose = info
+
+[feed.example]
+url = https://example.org/
+
+[feed.blog]
+url = https://blog.example.com/index.rss
+to = other@example.com
+user-agent = rss2email/legacy
+
+[feed.old]
+url = https://old.example.com/
+active = False
+`), 0644)
+	if err != nil {
+		t.Fatalf("Error writing legacy config file")
+	}
+
+	// The seen-state of the Python rss2email
+	state := filepath.Join(dir, "rss2email.json")
+	err = os.WriteFile(state, []byte(`{
+ "version": 2,
+ "feeds": [
+  {"name": "blog", "url": "https://blog.example.com/index.rss",
+   "seen": {"guid-one": {"id": "guid-one"}, "guid-two": {"id": "guid-two"}}},
+  {"name": "old", "url": "https://old.example.com/",
+   "seen": {"guid-three": {"id": "guid-three"}}}
+ ]
+}`), 0644)
+	if err != nil {
+		t.Fatalf("Error writing legacy state file")
+	}
+
+	seen := make(map[string]bool)
+
+	im := importLegacyCmd{}
+	im.config = configfile.NewWithPath(config)
+	im.state = state
+	im.seen = true
+	im.markSeen = func(guid string) { seen[guid] = true }
+
+	res := im.Execute([]string{cfg})
+	if res != 0 {
+		t.Fatalf("unexpected failure importing")
+	}
+
+	// Reparse and ensure the inactive feed was skipped,
+	// and the duplicate not added twice.
+	c := configfile.NewWithPath(config)
+	entries, err := c.Parse()
+	if err != nil {
+		t.Fatalf("Error parsing written file: %s", err.Error())
+	}
+	if len(entries) != 2 {
+		t.Fatalf("Expected two entries, got %d", len(entries))
+	}
+
+	opts := make(map[string]string)
+	for _, opt := range entries[1].Options {
+		opts[opt.Name] = opt.Value
+	}
+	if opts["to"] != "other@example.com" {
+		t.Fatalf("'to' was not imported: %v", entries[1].Options)
+	}
+	if opts["user-agent"] != "rss2email/legacy" {
+		t.Fatalf("'user-agent' was not imported: %v", entries[1].Options)
+	}
+
+	if len(seen) != 2 || !seen["guid-one"] || !seen["guid-two"] {
+		t.Fatalf("unexpected seen-state: %v", seen)
+	}
+
+	// A missing file is an error
+	res = im.Execute([]string{filepath.Join(dir, "missing.cfg")})
+	if res != 1 {
+		t.Fatalf("expected failure with missing file")
+	}
+
+	os.Remove(config)
+}
+
+func TestParseINI(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "test.cfg")
+	err := os.WriteFile(path, []byte(`# comment
+[feed.one]
+url: https://example.com/
+to = one@example.com,
+  two@example.com
+`), 0644)
+	if err != nil {
+		t.Fatalf("Error writing file")
+	}
+
+	sections, order, err := parseINI(path)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(order) != 1 || order[0] != "feed.one" {
+		t.Fatalf("unexpected sections: %v", order)
+	}
+	if sections["feed.one"]["url"] != "https://example.com/" {
+		t.Fatalf("wrong URL: %v", sections["feed.one"])
+	}
+	if sections["feed.one"]["to"] != "one@example.com,\ntwo@example.com" {
+		t.Fatalf("wrong continuation: %q", sections["feed.one"]["to"])
+	}
+
+	err = os.WriteFile(path, []byte("url = outside\n"), 0644)
+	if err != nil {
+		t.Fatalf("Error writing file")
+	}
+	_, _, err = parseINI(path)
+	if err == nil {
+		t.Fatalf("expected error with value outside a section")
+	}
+}
@@ -0,0 +1,335 @@
+//
+// Interactively set up rss2email, upon the first run.
+//
+
+package main
+
+import (
+	"bufio"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+)
+
+// Structure for our options and state.
+type initCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Should we replace an existing environment file?
+	force bool
+
+	// in holds the answers to our questions, which is STDIN unless
+	// we're testing.
+	in io.Reader
+
+	// scanner reads those answers.
+	scanner *bufio.Scanner
+
+	// executable is the path to our binary, as shown within the lines
+	// we suggest installing.
+	executable string
+}
+
+// Arguments handles our flag-setup.
+func (i *initCmd) Arguments(f *flag.FlagSet) {
+	i.config = configfile.New()
+
+	f.BoolVar(&i.force, "force", false, "Replace the environment file, if it already exists.")
+}
+
+// Info is part of the subcommand-API.
+func (i *initCmd) Info() (string, string) {
+	return "init", `Interactively set up rss2email, upon the first run.
+
+This sub-command asks for the address emails should be sent to, the
+address they should be sent from, whether they should be delivered via
+sendmail or SMTP, along with the details of the SMTP server, and the
+first feed to follow.
+
+The feed is added to the configuration file, and the rest of the answers
+are written to the file 'env' beside it, as the environmental variables
+which configure the other sub-commands, such as $RSS2EMAIL_RECIPIENTS and
+$SMTP_HOST.  That file is only readable by you, as it may hold the SMTP
+password, which may instead be a reference such as 'cmd:pass show smtp'.
+
+Finally a test email may be sent, and the crontab line, and the systemd
+service, which run rss2email with that environment are shown, for you to
+install.
+
+An existing environment file is only replaced if '-force' is given.
+
+Example:
+
+    $ rss2email init
+`
+}
+
+// ask shows the given question, and returns the answer, or the default if
+// there is no answer.  If there is no more input false is returned.
+func (i *initCmd) ask(question string, def string) (string, bool) {
+
+	if def != "" {
+		fmt.Fprintf(out, "%s [%s]: ", question, def)
+	} else {
+		fmt.Fprintf(out, "%s: ", question)
+	}
+
+	if !i.scanner.Scan() {
+		fmt.Fprintln(out)
+		return "", false
+	}
+	answer := strings.TrimSpace(i.scanner.Text())
+	if answer == "" {
+		answer = def
+	}
+	return answer, true
+}
+
+// require asks the given question until it is answered with a value the
+// given function accepts, which returns the reason it doesn't otherwise.
+func (i *initCmd) require(question string, def string, valid func(string) string) (string, bool) {
+
+	for {
+		answer, ok := i.ask(question, def)
+		if !ok {
+			return "", false
+		}
+		reason := valid(answer)
+		if reason == "" {
+			return answer, true
+		}
+		fmt.Fprintf(out, "  %s\n", reason)
+	}
+}
+
+// shellQuote returns the given value quoted for the shell, and for the
+// EnvironmentFile of systemd, which both understand single quotes.
+func shellQuote(value string) string {
+	return "'" + strings.ReplaceAll(value, "'", `'\''`) + "'"
+}
+
+// Execute is invoked if the user specifies `init` as the subcommand.
+func (i *initCmd) Execute(args []string) int {
+
+	// Upgrade our configuration-file if necessary
+	i.config.Upgrade()
+
+	// Upon the first run there are no feeds.
+	var entries []configfile.Feed
+	var err error
+	if i.config.Exists() {
+		entries, err = i.config.Parse()
+		if err != nil {
+			fmt.Printf("Error parsing file: %s\n", err.Error())
+			return 1
+		}
+	}
+
+	dir := i.config.Directory()
+	path := filepath.Join(dir, "env")
+	if _, err := os.Stat(path); err == nil && !i.force {
+		fmt.Printf("%s already exists, use '-force' to replace it\n", path)
+		return 1
+	}
+
+	if i.in == nil {
+		i.in = os.Stdin
+	}
+	i.scanner = bufio.NewScanner(i.in)
+
+	fmt.Fprintf(out, "Setting up rss2email, within %s\n\n", dir)
+
+	// The values we'll write, in order.
+	var env [][2]string
+	set := func(name, value string) {
+		env = append(env, [2]string{name, value})
+		os.Setenv(name, value)
+	}
+
+	address := func(answer string) string {
+		if !strings.Contains(answer, "@") {
+			return "Please enter an email address, such as steve@example.com."
+		}
+		return ""
+	}
+	anything := func(answer string) string {
+		if answer == "" {
+			return "Please enter a value."
+		}
+		return ""
+	}
+
+	to, ok := i.require("Send emails to", "", func(answer string) string {
+		for _, addr := range emailer.SplitAddresses(answer) {
+			if reason := address(addr); reason != "" {
+				return reason
+			}
+		}
+		return anything(answer)
+	})
+	if !ok {
+		fmt.Printf("Aborted, nothing was written\n")
+		return 1
+	}
+	set("RSS2EMAIL_RECIPIENTS", to)
+	recipients := emailer.SplitAddresses(to)
+
+	from, ok := i.require("Send emails from", recipients[0], address)
+	if !ok {
+		fmt.Printf("Aborted, nothing was written\n")
+		return 1
+	}
+	set("RSS2EMAIL_FROM", from)
+
+	// There is no sendmail upon Windows.
+	method := "sendmail"
+	if runtime.GOOS == "windows" {
+		method = "smtp"
+	} else {
+		method, ok = i.require("Deliver via sendmail, or smtp", method, func(answer string) string {
+			if answer != "sendmail" && answer != "smtp" {
+				return "Please enter 'sendmail', or 'smtp'."
+			}
+			return ""
+		})
+		if !ok {
+			fmt.Printf("Aborted, nothing was written\n")
+			return 1
+		}
+	}
+
+	if method == "smtp" {
+		questions := []struct {
+			name     string
+			question string
+			def      string
+		}{
+			{"SMTP_HOST", "SMTP server", ""},
+			{"SMTP_PORT", "SMTP port", "587"},
+			{"SMTP_USERNAME", "SMTP username", from},
+			{"SMTP_PASSWORD", "SMTP password, or a reference such as cmd:pass show smtp", ""},
+		}
+		for _, q := range questions {
+			answer, ok := i.require(q.question, q.def, anything)
+			if !ok {
+				fmt.Printf("Aborted, nothing was written\n")
+				return 1
+			}
+			set(q.name, answer)
+		}
+	}
+
+	feed, ok := i.ask("The URL of the first feed to follow, if any", "")
+	if !ok {
+		fmt.Printf("Aborted, nothing was written\n")
+		return 1
+	}
+
+	// Write our environment, which only we may read.
+	err = os.MkdirAll(dir, 0700)
+	if err != nil {
+		fmt.Printf("failed to create %s: %s\n", dir, err.Error())
+		return 1
+	}
+	var content strings.Builder
+	content.WriteString("# Written by 'rss2email init', for the cron-job, or systemd service,\n# which runs rss2email.\n")
+	for _, kv := range env {
+		fmt.Fprintf(&content, "%s=%s\n", kv[0], shellQuote(kv[1]))
+	}
+	err = os.WriteFile(path, []byte(content.String()), 0600)
+	if err != nil {
+		fmt.Printf("failed to write %s: %s\n", path, err.Error())
+		return 1
+	}
+	fmt.Fprintf(out, "\nWrote %s\n", path)
+
+	// Add the feed, unless we follow it already.
+	if feed != "" {
+		dup := ""
+		for _, entry := range entries {
+			if urlKey(entry.URL) == urlKey(feed) {
+				dup = entry.URL
+			}
+		}
+		if dup != "" {
+			fmt.Fprintf(out, "Already following %s\n", dup)
+		} else {
+			i.config.Add(feed)
+			err = i.config.Save()
+			if err != nil {
+				fmt.Printf("failed to save the updated feed list: %s\n", err.Error())
+				return 1
+			}
+			fmt.Fprintf(out, "Added %s to %s\n", feed, i.config.Path())
+		}
+	}
+
+	// Prove that delivery works.
+	failed := false
+	answer, _ := i.ask("\nSend a test email now? (y/n)", "y")
+	if strings.HasPrefix(strings.ToLower(answer), "y") {
+		err = emailer.Notify(from, recipients, "rss2email is set up", fmt.Sprintf("This is a test email from rss2email, which will send new items to %s.\n", strings.Join(recipients, ", ")))
+		if err != nil {
+			fmt.Printf("failed to send the test email: %s\n", err.Error())
+			failed = true
+		} else {
+			fmt.Fprintf(out, "Sent a test email to %s\n", strings.Join(recipients, ", "))
+		}
+	}
+
+	i.showInstall(path)
+
+	if failed {
+		return 1
+	}
+	return 0
+}
+
+// showInstall shows the crontab line, and systemd service, which run
+// rss2email with the given environment.
+func (i *initCmd) showInstall(env string) {
+
+	exe := i.executable
+	if exe == "" {
+		exe, _ = os.Executable()
+	}
+
+	// Pass along the global flags we were given, such as "-profile".
+	command := []string{shellQuote(exe)}
+	for _, arg := range globalArgs {
+		command = append(command, shellQuote(arg))
+	}
+	run := strings.Join(command, " ")
+
+	fmt.Fprintf(out, `
+To process your feeds every 15 minutes add this line to your crontab, via
+'crontab -e':
+
+  */15 * * * * set -a && . %s && %s cron
+
+Or to run the daemon via systemd save this as
+~/.config/systemd/user/rss2email.service, then run
+'systemctl --user enable --now rss2email':
+
+  [Unit]
+  Description=rss2email
+  After=network-online.target
+
+  [Service]
+  EnvironmentFile=%s
+  ExecStart=%s daemon
+  Restart=on-failure
+
+  [Install]
+  WantedBy=default.target
+`, shellQuote(env), run, env, run)
+}
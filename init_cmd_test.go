@@ -0,0 +1,156 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestInit(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake sendmail assumes a unix shell")
+	}
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	defer func() {
+		for _, name := range []string{"RSS2EMAIL_RECIPIENTS", "RSS2EMAIL_FROM", "RSS2EMAIL_SENDMAIL", "SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD"} {
+			os.Unsetenv(name)
+		}
+	}()
+
+	// Our sendmail records the emails it is given.
+	sent := filepath.Join(t.TempDir(), "sent")
+	sendmail := filepath.Join(t.TempDir(), "sendmail")
+	err := os.WriteFile(sendmail, []byte("#!/bin/sh\ncat >> "+sent+"\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to write sendmail: %s", err)
+	}
+	os.Setenv("RSS2EMAIL_SENDMAIL", sendmail)
+
+	bak := out
+	buf := &bytes.Buffer{}
+	out = buf
+	defer func() { out = bak }()
+
+	// Invalid answers are asked again.
+	answers := strings.Join([]string{
+		"steve",
+		"steve@example.com",
+		"",
+		"pigeon",
+		"sendmail",
+		"https://blog.steve.fi/index.rss",
+		"yes",
+	}, "\n")
+
+	i := initCmd{}
+	i.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+	i.in = strings.NewReader(answers)
+	i.executable = "/usr/bin/rss2email"
+	if res := i.Execute([]string{}); res != 0 {
+		t.Fatalf("unexpected result %d: %s", res, buf.String())
+	}
+
+	env, err := os.ReadFile(filepath.Join(dir, "env"))
+	if err != nil {
+		t.Fatalf("environment wasn't written: %s", err)
+	}
+	if !strings.Contains(string(env), "RSS2EMAIL_RECIPIENTS='steve@example.com'\nRSS2EMAIL_FROM='steve@example.com'\n") || strings.Contains(string(env), "SMTP_") {
+		t.Fatalf("unexpected environment: %s", env)
+	}
+	if info, _ := os.Stat(filepath.Join(dir, "env")); info.Mode().Perm() != 0600 {
+		t.Fatalf("environment is readable by others: %s", info.Mode())
+	}
+
+	entries, err := configfile.New().Parse()
+	if err != nil || len(entries) != 1 || entries[0].URL != "https://blog.steve.fi/index.rss" {
+		t.Fatalf("feed wasn't added: %v %v", entries, err)
+	}
+
+	email, err := os.ReadFile(sent)
+	if err != nil || !strings.Contains(string(email), "Subject: rss2email is set up") {
+		t.Fatalf("test email wasn't sent: %s %v", email, err)
+	}
+
+	for _, expected := range []string{
+		"Please enter an email address",
+		"Please enter 'sendmail', or 'smtp'.",
+		"*/15 * * * * set -a && . '" + filepath.Join(dir, "env") + "' && '/usr/bin/rss2email' cron",
+		"ExecStart='/usr/bin/rss2email' daemon",
+	} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("expected %q within output: %s", expected, buf.String())
+		}
+	}
+
+	// The environment isn't replaced unless forced.
+	i = initCmd{}
+	i.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+	i.in = strings.NewReader("")
+	if res := i.Execute([]string{}); res != 1 {
+		t.Fatalf("expected failure with existing environment")
+	}
+
+	// SMTP is configured too, and the feed isn't added twice.
+	answers = strings.Join([]string{
+		"one@example.com, two@example.com",
+		"rss@example.com",
+		"smtp",
+		"smtp.example.com",
+		"",
+		"",
+		"cmd:pass show smtp",
+		"https://blog.steve.fi/index.rss/",
+		"n",
+	}, "\n")
+	i = initCmd{force: true}
+	i.config = configfile.New()
+	i.in = strings.NewReader(answers)
+	if res := i.Execute([]string{}); res != 0 {
+		t.Fatalf("unexpected result %d: %s", res, buf.String())
+	}
+	env, _ = os.ReadFile(filepath.Join(dir, "env"))
+	if !strings.Contains(string(env), "SMTP_HOST='smtp.example.com'\nSMTP_PORT='587'\nSMTP_USERNAME='rss@example.com'\nSMTP_PASSWORD='cmd:pass show smtp'\n") {
+		t.Fatalf("unexpected environment: %s", env)
+	}
+	entries, _ = configfile.New().Parse()
+	if len(entries) != 1 || !strings.Contains(buf.String(), "Already following https://blog.steve.fi/index.rss") {
+		t.Fatalf("feed was added twice: %v", entries)
+	}
+
+	// Running out of answers writes nothing.
+	os.Remove(filepath.Join(dir, "env"))
+	i = initCmd{}
+	i.config = configfile.New()
+	i.in = strings.NewReader("steve@example.com\n")
+	if res := i.Execute([]string{}); res != 1 {
+		t.Fatalf("expected failure without answers")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "env")); err == nil {
+		t.Fatalf("environment was written without answers")
+	}
+}
+
+func TestShellQuote(t *testing.T) {
+
+	tests := map[string]string{
+		"simple": "'simple'",
+		"it's":   `'it'\''s'`,
+		"a b $c": "'a b $c'",
+		"":       "''",
+	}
+	for in, expected := range tests {
+		if got := shellQuote(in); got != expected {
+			t.Errorf("unexpected quoting of %q: %s != %s", in, got, expected)
+		}
+	}
+}
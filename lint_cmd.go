@@ -0,0 +1,331 @@
+//
+// Check our feed-list for problems.
+//
+
+package main
+
+import (
+	"errors"
+	"flag"
+	"fmt"
+	"net"
+	"net/url"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skx/rss2email/bridge"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/fetch"
+	"github.com/skx/rss2email/httpfetch"
+	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/schedule"
+)
+
+// connectsElsewhere returns true if the given feed is fetched from an
+// address other than the host of its URL, which needn't be reachable.
+func connectsElsewhere(entry configfile.Feed) bool {
+	for _, opt := range entry.Options {
+		if opt.Name == "connect-to" || opt.Name == "unix-socket" {
+			return true
+		}
+	}
+	return false
+}
+
+// Structure for our options and state.
+type lintCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Should we skip checking that hosts are reachable?
+	offline bool
+
+	// The time to allow when connecting to each host.
+	timeout time.Duration
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (l *lintCmd) Arguments(flags *flag.FlagSet) {
+	l.config = configfile.New()
+
+	flags.BoolVar(&l.offline, "offline", false, "Don't check that the host of each feed is reachable.")
+	flags.DurationVar(&l.timeout, "timeout", 10*time.Second, "The time to allow when connecting to each host.")
+}
+
+// Info is part of the subcommand-API
+func (l *lintCmd) Info() (string, string) {
+	return "lint", `Check the feed list for problems.
+
+This command checks the configuration file for common problems, which
+are reported along with the line upon which they occur:
+
+  * URLs which are malformed.
+  * Feeds which are present more than once, including trivial variations
+    such as a trailing slash, or a change of scheme.
+  * Hosts which cannot be reached.
+  * Per-feed options which are unknown, for example due to a typo.
+  * Syntax errors, such as an unterminated quoted value.
+
+Checking that hosts are reachable requires network access, and may be
+skipped via the '-offline' flag.
+
+The command exits with a non-zero status if any problems are found.
+
+To see details of the configuration file, including the location,
+please run:
+
+   $ rss2email help config
+
+Example:
+
+    $ rss2email lint
+    $ rss2email lint -offline
+`
+}
+
+// problem is a single issue found within the configuration file.
+type problem struct {
+
+	// The line upon which the problem occurs.
+	line int
+
+	// A description of the problem.
+	msg string
+}
+
+// normalizeURL returns the given URL in a canonical form, so that trivial
+// variations of the same URL may be detected as duplicates.
+func normalizeURL(u *url.URL) string {
+
+	host := strings.ToLower(u.Hostname())
+	port := u.Port()
+	if port != "" && port != "80" && port != "443" {
+		host += ":" + port
+	}
+
+	path := strings.TrimSuffix(u.EscapedPath(), "/")
+
+	res := host + path
+	if u.RawQuery != "" {
+		res += "?" + u.RawQuery
+	}
+	return res
+}
+
+// checkURL returns the parsed URL of the given feed, which is expanded if
+// it is bridged, or an error if it is malformed.
+//
+// Feeds which aren't fetched via HTTP, such as local files, have no host
+// to check, so no URL is returned for them.
+func checkURL(entry configfile.Feed) (*url.URL, error) {
+
+	feed := entry.URL
+	switch fetch.Scheme(feed) {
+	case "http", "https", "bridge":
+	case "":
+		return nil, fmt.Errorf("malformed URL '%s': there is no scheme", feed)
+	default:
+		if _, err := fetch.New(entry); err != nil {
+			return nil, fmt.Errorf("malformed URL '%s': unknown scheme '%s'", feed, fetch.Scheme(feed))
+		}
+		return nil, nil
+	}
+
+	expanded, err := bridge.Expand(feed)
+	if err != nil {
+		return nil, err
+	}
+
+	u, err := url.Parse(expanded)
+	if err != nil {
+		return nil, fmt.Errorf("malformed URL '%s': %s", feed, err)
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		return nil, fmt.Errorf("malformed URL '%s': the scheme must be http or https", feed)
+	}
+	if u.Hostname() == "" {
+		return nil, fmt.Errorf("malformed URL '%s': there is no host", feed)
+	}
+	return u, nil
+}
+
+// checkHost returns an error if we cannot connect to the host of the
+// given URL.
+func (l *lintCmd) checkHost(u *url.URL) error {
+
+	port := u.Port()
+	if port == "" {
+		port = "80"
+		if u.Scheme == "https" {
+			port = "443"
+		}
+	}
+
+	conn, err := net.DialTimeout("tcp", net.JoinHostPort(u.Hostname(), port), l.timeout)
+	if err != nil {
+		return fmt.Errorf("unreachable host '%s': %s", u.Host, err)
+	}
+	conn.Close()
+	return nil
+}
+
+// lint returns the problems found within the given entries.
+func (l *lintCmd) lint(entries []configfile.Feed) []problem {
+
+	var problems []problem
+
+	// The URLs we've seen, and the line upon which we saw them.
+	seen := make(map[string]int)
+
+	// The URLs whose hosts we should check.
+	var check []*url.URL
+	var lines []int
+
+	for _, entry := range entries {
+
+		u, err := checkURL(entry)
+		if err != nil {
+			problems = append(problems, problem{line: entry.Line, msg: err.Error()})
+		} else {
+			key := entry.URL
+			if u != nil {
+				key = normalizeURL(u)
+			}
+			if line, ok := seen[key]; ok {
+				problems = append(problems, problem{line: entry.Line, msg: fmt.Sprintf("duplicate of the feed on line %d: %s", line, entry.URL)})
+			} else {
+				seen[key] = entry.Line
+				if u != nil && !connectsElsewhere(entry) {
+					check = append(check, u)
+					lines = append(lines, entry.Line)
+				}
+			}
+		}
+
+		for _, opt := range entry.Options {
+			if !configfile.Known(opt.Name) {
+				problems = append(problems, problem{line: opt.Line, msg: fmt.Sprintf("unknown option '%s'", opt.Name)})
+			}
+			if opt.Name == "template" {
+				if path := emailer.TemplatePath(opt.Value); !exists(path) {
+					problems = append(problems, problem{line: opt.Line, msg: fmt.Sprintf("template %s does not exist", path)})
+				}
+			}
+			if opt.Name == "subject" {
+				if err := emailer.CheckSubject(opt.Value); err != nil {
+					problems = append(problems, problem{line: opt.Line, msg: fmt.Sprintf("invalid subject: %s", err)})
+				}
+			}
+			if opt.Name == "attach" {
+				if _, err := emailer.ParseSize(opt.Value); err != nil {
+					problems = append(problems, problem{line: opt.Line, msg: fmt.Sprintf("invalid attach size: %s", err)})
+				}
+			}
+			if opt.Name == "image-proxy" {
+				if err := emailer.CheckImageProxy(opt.Value); err != nil {
+					problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+				}
+			}
+			if opt.Name == "timezone" {
+				if _, err := emailer.Location([]configfile.Option{opt}, ""); err != nil {
+					problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+				}
+			}
+			if opt.Name == "cron" {
+				if _, err := schedule.Parse(opt.Value); err != nil {
+					problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+				}
+			}
+			if err := processor.CheckRewrite(opt); err != nil {
+				problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+			}
+			if err := processor.CheckDedupe(opt); err != nil {
+				problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+			}
+			if err := processor.CheckIdentity(opt); err != nil {
+				problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+			}
+			if err := processor.CheckSummarise(opt); err != nil {
+				problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+			}
+			if err := httpfetch.CheckOption(opt); err != nil {
+				problems = append(problems, problem{line: opt.Line, msg: err.Error()})
+			}
+		}
+	}
+
+	if l.offline {
+		return problems
+	}
+
+	// Check each host once, in parallel, as this may be slow.
+	hosts := make(map[string]error)
+	var unique []*url.URL
+	for _, u := range check {
+		if _, ok := hosts[u.Scheme+"://"+u.Host]; !ok {
+			hosts[u.Scheme+"://"+u.Host] = nil
+			unique = append(unique, u)
+		}
+	}
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+	for _, u := range unique {
+		wg.Add(1)
+		go func(u *url.URL) {
+			defer wg.Done()
+			err := l.checkHost(u)
+			mutex.Lock()
+			hosts[u.Scheme+"://"+u.Host] = err
+			mutex.Unlock()
+		}(u)
+	}
+	wg.Wait()
+
+	for i, u := range check {
+		if err := hosts[u.Scheme+"://"+u.Host]; err != nil {
+			problems = append(problems, problem{line: lines[i], msg: err.Error()})
+		}
+	}
+	return problems
+}
+
+// Execute is invoked if the user specifies `lint` as the subcommand.
+func (l *lintCmd) Execute(args []string) int {
+
+	// Upgrade our configuration-file if necessary
+	l.config.Upgrade()
+
+	entries, err := l.config.Parse()
+	if err != nil {
+		var perr *configfile.ParseError
+		if errors.As(err, &perr) {
+			fmt.Fprintf(out, "%s:%d: %s\n", l.config.Path(), perr.Line, perr.Msg)
+			return 1
+		}
+		fmt.Printf("Error with config-file: %s\n", err.Error())
+		return 1
+	}
+
+	problems := l.lint(entries)
+
+	// Show the problems in the order they appear in the file.
+	sort.SliceStable(problems, func(i, j int) bool {
+		return problems[i].line < problems[j].line
+	})
+	for _, p := range problems {
+		fmt.Fprintf(out, "%s:%d: %s\n", l.config.Path(), p.line, p.msg)
+	}
+
+	if len(problems) > 0 {
+		return 1
+	}
+	return 0
+}
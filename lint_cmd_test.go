@@ -0,0 +1,132 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// lintFile runs the lint command against the given configuration file,
+// returning the exit code and output.
+func lintFile(t *testing.T, content string, offline bool) (int, string) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	tmpfile, err := os.CreateTemp("", "example")
+	if err != nil {
+		t.Fatalf("Error creating temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpfile.Write([]byte(content))
+	tmpfile.Close()
+
+	l := lintCmd{offline: offline, timeout: 5 * time.Second}
+	l.config = configfile.NewWithPath(tmpfile.Name())
+	res := l.Execute([]string{})
+
+	// Remove the path from the output, for easier comparisons.
+	output := out.(*bytes.Buffer).String()
+	output = strings.ReplaceAll(output, tmpfile.Name()+":", "")
+	return res, output
+}
+
+func TestLint(t *testing.T) {
+
+	res, output := lintFile(t, `# A comment
+https://example.com/feed
+ - retry: 3
+ - exclude-titel: foo
+https://Example.com/feed/
+http://example.com/feed
+ftp://example.com/feed
+https:///feed
+https://example.com/other
+ - cron: 0 25 * * *
+ - template: /does/not/exist.tmpl
+ - ip-version: 5
+file:///tmp/feed.xml
+file:///tmp/feed.xml
+`, true)
+
+	if res != 1 {
+		t.Fatalf("expected problems to be found")
+	}
+
+	expected := `4: unknown option 'exclude-titel'
+5: duplicate of the feed on line 2: https://Example.com/feed/
+6: duplicate of the feed on line 2: http://example.com/feed
+7: malformed URL 'ftp://example.com/feed': unknown scheme 'ftp'
+8: malformed URL 'https:///feed': there is no host
+10: invalid cron expression '0 25 * * *': invalid hour '25'
+11: template ` + filepath.FromSlash("/does/not/exist.tmpl") + ` does not exist
+12: invalid ip-version '5', expected 4 or 6
+14: duplicate of the feed on line 13: file:///tmp/feed.xml
+`
+	if output != expected {
+		t.Fatalf("unexpected output:\n%s", output)
+	}
+
+	// A valid file has no problems.
+	res, output = lintFile(t, "https://example.com/\n - name: Example\n - cron: @daily\n", true)
+	if res != 0 || output != "" {
+		t.Fatalf("unexpected result %d: %s", res, output)
+	}
+
+	// Syntax errors are reported along with their line.
+	res, output = lintFile(t, "https://example.com/\n - name: \"Example\n", true)
+	if res != 1 || output != "2: option name: unterminated quoted value \"Example\n" {
+		t.Fatalf("unexpected result %d: %s", res, output)
+	}
+}
+
+func TestLintHosts(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	defer ts.Close()
+
+	// Find a port upon which nothing is listening.
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	closed := l.Addr().String()
+	l.Close()
+
+	res, output := lintFile(t, fmt.Sprintf("%s/feed\nhttp://%s/feed\n", ts.URL, closed), false)
+	if res != 1 {
+		t.Fatalf("expected problems to be found")
+	}
+	if !strings.HasPrefix(output, "2: unreachable host '"+closed+"'") || strings.Count(output, "\n") != 1 {
+		t.Fatalf("unexpected output: %s", output)
+	}
+}
+
+// TestLintOptions ensures that the options we know about are those which
+// are documented.
+func TestLintOptions(t *testing.T) {
+
+	_, doc := (&configCmd{}).Info()
+
+	re := regexp.MustCompile(`(?m)^([a-z-]+) +\|`)
+	var documented []string
+	for _, m := range re.FindAllStringSubmatch(doc, -1) {
+		documented = append(documented, m[1])
+	}
+
+	if strings.Join(documented, ",") != strings.Join(configfile.KnownOptions, ",") {
+		t.Fatalf("documented options differ from known options:\n%v\n%v", documented, configfile.KnownOptions)
+	}
+}
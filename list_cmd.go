@@ -5,12 +5,14 @@
 package main
 
 import (
+	"context"
 	"flag"
 	"fmt"
 	"time"
 
+	"github.com/mmcdole/gofeed"
 	"github.com/skx/rss2email/configfile"
-	"github.com/skx/rss2email/httpfetch"
+	"github.com/skx/rss2email/fetch"
 )
 
 var (
@@ -67,8 +69,11 @@ Example:
 func (l *listCmd) showFeedDetails(entry configfile.Feed) {
 
 	// Fetch the details
-	helper := httpfetch.New(entry)
-	feed, err := helper.Fetch()
+	helper, err := fetch.New(entry)
+	var feed *gofeed.Feed
+	if err == nil {
+		feed, err = helper.FetchContext(context.Background())
+	}
 	if err != nil {
 		fmt.Fprintf(out, "# %s\n%s\n", err.Error(), entry.URL)
 		return
@@ -85,7 +90,7 @@ func (l *listCmd) showFeedDetails(entry configfile.Feed) {
 	newest := maxInt
 	for _, item := range feed.Items {
 		if item.PublishedParsed == nil {
-			break
+			continue
 		}
 
 		age := int(time.Since(*item.PublishedParsed) / (24 * time.Hour))
@@ -121,6 +126,11 @@ func (l *listCmd) Execute(args []string) int {
 	// Show the feeds
 	for _, entry := range entries {
 
+		// Show the name of the feed, if it has one.
+		if name := entry.Name(); name != "" {
+			fmt.Fprintf(out, "# %s\n", name)
+		}
+
 		if l.verbose {
 			l.showFeedDetails(entry)
 		} else {
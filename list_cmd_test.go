@@ -3,7 +3,6 @@ package main
 import (
 	"bytes"
 	"flag"
-	"io/ioutil"
 	"os"
 	"strings"
 	"testing"
@@ -27,7 +26,7 @@ https://example.net/index.rss
  - foo: bar
 `
 	data := []byte(content)
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("Error creating temporary file")
 	}
@@ -70,3 +69,32 @@ https://example.net/index.rss
 
 	os.Remove(tmpfile.Name())
 }
+
+// TestListNames confirms that the names of feeds are shown
+func TestListNames(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	tmpfile, err := os.CreateTemp("", "example")
+	if err != nil {
+		t.Fatalf("Error creating temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+
+	tmpfile.Write([]byte(`https://example.org/
+name="Example Net" https://example.net/index.rss
+`))
+	tmpfile.Close()
+
+	list := listCmd{config: configfile.NewWithPath(tmpfile.Name())}
+	if list.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error running list")
+	}
+
+	output := out.(*bytes.Buffer).String()
+	if output != "https://example.org/\n# Example Net\nhttps://example.net/index.rss\n" {
+		t.Errorf("unexpected output: %q", output)
+	}
+}
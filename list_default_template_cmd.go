@@ -34,6 +34,19 @@ This sub-command can be used to give you a starting point for your edits:
 
    $ rss2email list-default-template > ~/.rss2email/email.tmpl
 
+Once you've made your changes you may check them for problems:
+
+   $ rss2email template check
+
+And see how the email for an item of a feed will appear:
+
+   $ rss2email render -o sample.eml https://blog.steve.fi/index.rss
+
+If you'd prefer to start with the styled template, which wraps the HTML
+in a layout with embedded CSS, you may specify its name:
+
+   $ rss2email list-default-template styled > ~/.rss2email/email.tmpl
+
 
 Example:
 
@@ -48,6 +61,19 @@ func (l *listDefaultTemplateCmd) Execute(args []string) int {
 
 	// Load the default template from the embedded resource.
 	content := template.EmailTemplate()
+
+	// Unless the styled one was requested.
+	if len(args) > 0 {
+		switch args[0] {
+		case "plain":
+		case "styled":
+			content = template.StyledTemplate()
+		default:
+			fmt.Printf("Unknown template '%s', valid choices are 'plain' or 'styled'\n", args[0])
+			return 1
+		}
+	}
+
 	fmt.Fprintf(out, "%s\n", string(content))
 	return 0
 }
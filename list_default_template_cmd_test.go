@@ -44,3 +44,25 @@ func TestDefaultTemplate(t *testing.T) {
 		}
 	}
 }
+
+func TestStyledTemplate(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	s := listDefaultTemplateCmd{}
+
+	if s.Execute([]string{"styled"}) != 0 {
+		t.Fatalf("unexpected error showing styled template")
+	}
+
+	output := out.(*bytes.Buffer).String()
+	if !strings.Contains(output, "prefers-color-scheme") {
+		t.Fatalf("Failed to find expected output")
+	}
+
+	if s.Execute([]string{"steve"}) != 1 {
+		t.Fatalf("expected error with unknown template")
+	}
+}
@@ -0,0 +1,146 @@
+//
+// Show the log of delivery attempts.
+//
+
+package main
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/audit"
+)
+
+// Structure for our options and state.
+type logCmd struct {
+
+	// The path to the log, used for testing
+	path string
+
+	// Only show attempts for the feed with this URL.
+	feed string
+
+	// Only show attempts made within this period.
+	since time.Duration
+
+	// Only show failed attempts?
+	failed bool
+
+	// Output each record as JSON?
+	json bool
+
+	// The maximum number of records to show.
+	limit int
+}
+
+// Arguments handles our flag-setup.
+func (l *logCmd) Arguments(f *flag.FlagSet) {
+	l.path = audit.Path()
+
+	f.StringVar(&l.feed, "feed", "", "Only show deliveries of items from the feed with this URL.")
+	f.DurationVar(&l.since, "since", 0, "Only show deliveries attempted within this period, e.g. '24h'.")
+	f.BoolVar(&l.failed, "failed", false, "Only show failed, or rejected, deliveries?")
+	f.BoolVar(&l.json, "json", false, "Output each record as JSON?")
+	f.IntVar(&l.limit, "limit", 0, "The maximum number of records to show, the most recent, zero for no limit.")
+}
+
+// Info is part of the subcommand-API.
+func (l *logCmd) Info() (string, string) {
+	return "log", `Show the log of delivery attempts.
+
+Each attempt to deliver an email, by the 'cron' or 'daemon' sub-commands,
+is recorded in the file '~/.rss2email/delivery.log'.  The record holds
+the item, the recipients, the means of delivery (smtp or sendmail), the
+time, whether the attempt succeeded, and the response of the server,
+which usually includes the ID with which the message was queued.
+
+This sub-command shows that log, oldest first, optionally limited to
+those records which contain the given term within their title, link,
+GUID, feed, or recipients.
+
+Example:
+
+    $ rss2email log -failed -since 24h
+    $ rss2email log -feed https://blog.steve.fi/index.rss
+    $ rss2email log -json steve@example.com
+`
+}
+
+// matches returns true if the given record contains the given term.
+func (l *logCmd) matches(rec audit.Record, term string) bool {
+
+	if term == "" {
+		return true
+	}
+
+	fields := append([]string{rec.Title, rec.Link, rec.GUID, rec.Feed, rec.FeedTitle}, rec.Recipients...)
+	for _, field := range fields {
+		if strings.Contains(strings.ToLower(field), term) {
+			return true
+		}
+	}
+	return false
+}
+
+// Execute is invoked if the user specifies `log` as the subcommand.
+func (l *logCmd) Execute(args []string) int {
+
+	records, err := audit.Read(l.path)
+	if err != nil {
+		fmt.Printf("failed to read delivery log %s: %s\n", l.path, err.Error())
+		return 1
+	}
+
+	term := strings.ToLower(strings.Join(args, " "))
+
+	var found []audit.Record
+	for _, rec := range records {
+
+		if l.feed != "" && rec.Feed != l.feed {
+			continue
+		}
+		if l.since > 0 && time.Since(rec.Time) > l.since {
+			continue
+		}
+		if l.failed && rec.Result == audit.ResultSent {
+			continue
+		}
+		if !l.matches(rec, term) {
+			continue
+		}
+		found = append(found, rec)
+	}
+
+	if l.limit > 0 && len(found) > l.limit {
+		found = found[len(found)-l.limit:]
+	}
+
+	for _, rec := range found {
+
+		if l.json {
+			data, err := json.Marshal(rec)
+			if err != nil {
+				fmt.Printf("failed to encode record: %s\n", err.Error())
+				return 1
+			}
+			fmt.Fprintf(out, "%s\n", data)
+			continue
+		}
+
+		fmt.Fprintf(out, "%s %s via %s to %s: %s\n",
+			rec.Time.Local().Format("2006-01-02 15:04:05"), rec.Result, rec.Backend,
+			strings.Join(rec.Recipients, ", "), rec.Title)
+		fmt.Fprintf(out, "\t%s\n", rec.Link)
+		if rec.Error != "" {
+			fmt.Fprintf(out, "\t%s\n", rec.Error)
+		}
+		if rec.Response != "" {
+			fmt.Fprintf(out, "\t%s\n", rec.Response)
+		}
+	}
+
+	return 0
+}
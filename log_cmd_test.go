@@ -0,0 +1,68 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/audit"
+)
+
+func TestLog(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	path := filepath.Join(t.TempDir(), "delivery.log")
+
+	records := []audit.Record{
+		{Time: time.Now().Add(-48 * time.Hour), Feed: "https://example.com/rss", Title: "Old", Link: "https://example.com/old", Recipients: []string{"steve@example.com"}, Backend: "sendmail", Result: audit.ResultSent},
+		{Feed: "https://example.com/rss", Title: "Hello World", Link: "https://example.com/1", Recipients: []string{"steve@example.com"}, Backend: "smtp", Result: audit.ResultSent, Response: "250 2.0.0 Ok: queued as ABC123"},
+		{Feed: "https://example.org/atom", Title: "Broken", Link: "https://example.org/2", Recipients: []string{"bob@example.org"}, Backend: "smtp", Result: audit.ResultFailed, Error: "550 no such user"},
+	}
+	for _, rec := range records {
+		if err := audit.Append(path, rec); err != nil {
+			t.Fatalf("failed to append: %s", err)
+		}
+	}
+
+	type TestCase struct {
+		cmd      logCmd
+		args     []string
+		expected []string
+		missing  []string
+	}
+
+	tests := []TestCase{
+		{logCmd{}, nil, []string{"Old", "Hello World", "queued as ABC123", "failed via smtp to bob@example.org: Broken", "550 no such user"}, nil},
+		{logCmd{failed: true}, nil, []string{"Broken"}, []string{"Hello World", "Old"}},
+		{logCmd{since: 24 * time.Hour}, nil, []string{"Hello World", "Broken"}, []string{"Old"}},
+		{logCmd{feed: "https://example.com/rss"}, nil, []string{"Hello World", "Old"}, []string{"Broken"}},
+		{logCmd{limit: 1}, nil, []string{"Broken"}, []string{"Hello World", "Old"}},
+		{logCmd{}, []string{"BOB@"}, []string{"Broken"}, []string{"Hello World"}},
+		{logCmd{json: true}, []string{"hello"}, []string{`"response":"250 2.0.0 Ok: queued as ABC123"`}, []string{"Broken"}},
+	}
+
+	for _, tst := range tests {
+
+		out = new(bytes.Buffer)
+		tst.cmd.path = path
+		if tst.cmd.Execute(tst.args) != 0 {
+			t.Fatalf("unexpected error for %v", tst)
+		}
+
+		output := out.(*bytes.Buffer).String()
+		for _, expected := range tst.expected {
+			if !strings.Contains(output, expected) {
+				t.Errorf("output didn't contain %q: %s", expected, output)
+			}
+		}
+		for _, missing := range tst.missing {
+			if strings.Contains(output, missing) {
+				t.Errorf("output contained %q: %s", missing, output)
+			}
+		}
+	}
+}
@@ -0,0 +1,189 @@
+// Package logger contains the Logger our components write their messages
+// to, which is given to each of them rather than being global, so that
+// they may be used as a library, and so that each component may be more,
+// or less, verbose than the others.
+//
+// The components are named:
+//
+//   - "process", the processing of feeds, and their items.
+//   - "fetch", the fetching of feeds.
+//   - "send", the sending of emails.
+package logger
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Components holds the names of our components.
+var Components = []string{"process", "fetch", "send"}
+
+// Logger receives the messages of a component.
+type Logger interface {
+
+	// Infof records progress, which is only of interest to those
+	// who want verbose output.
+	Infof(format string, args ...interface{})
+
+	// Warnf records a problem which doesn't prevent us from working,
+	// such as an unknown option.
+	Warnf(format string, args ...interface{})
+
+	// Errorf records a failure.
+	Errorf(format string, args ...interface{})
+
+	// Named returns the logger of the named component.
+	Named(component string) Logger
+}
+
+// Discard is a Logger which ignores every message.
+var Discard Logger = discard{}
+
+// discard is the implementation of Discard.
+type discard struct{}
+
+// Infof is part of the Logger interface.
+func (discard) Infof(format string, args ...interface{}) {}
+
+// Warnf is part of the Logger interface.
+func (discard) Warnf(format string, args ...interface{}) {}
+
+// Errorf is part of the Logger interface.
+func (discard) Errorf(format string, args ...interface{}) {}
+
+// Named is part of the Logger interface.
+func (d discard) Named(component string) Logger {
+	return d
+}
+
+// Writer is a Logger which writes messages to an io.Writer, as lines of
+// text or JSON.
+//
+// Errors and warnings are always written, but informational messages are only written
+// for the components which are verbose.
+type Writer struct {
+
+	// out is where messages are written, and mutex serialises those
+	// writes.
+	out   io.Writer
+	mutex *sync.Mutex
+
+	// json is true if messages are written as JSON, and colour if
+	// errors are coloured for a terminal.
+	json   bool
+	colour bool
+
+	// verbose holds the names of the verbose components, or "all".
+	verbose map[string]bool
+
+	// component is the name of the component we're logging for.
+	component string
+}
+
+// New returns a Writer which writes to the given writer, and for which no
+// component is verbose.
+func New(out io.Writer) *Writer {
+	return &Writer{out: out, mutex: &sync.Mutex{}, verbose: make(map[string]bool)}
+}
+
+// SetJSON controls whether messages are written as JSON.
+func (w *Writer) SetJSON(json bool) {
+	w.json = json
+}
+
+// SetColour controls whether errors are coloured red, which should only
+// be the case if messages are written to a terminal.
+func (w *Writer) SetColour(colour bool) {
+	w.colour = colour
+}
+
+// SetVerbose makes the given components verbose, or every component if
+// one is named "all".
+func (w *Writer) SetVerbose(components ...string) {
+	for _, c := range components {
+		w.verbose[c] = true
+	}
+}
+
+// Check returns an error if the given names of components aren't known,
+// the names being separated by commas.
+func Check(names string) error {
+
+	for _, name := range strings.Split(names, ",") {
+		name = strings.TrimSpace(name)
+		known := name == "all"
+		for _, c := range Components {
+			known = known || c == name
+		}
+		if !known {
+			return fmt.Errorf("unknown component '%s', valid components are: %s", name, strings.Join(Components, ", "))
+		}
+	}
+	return nil
+}
+
+// Verbose returns true if our component is verbose.
+func (w *Writer) Verbose() bool {
+	return w.verbose["all"] || w.verbose[w.component]
+}
+
+// Infof is part of the Logger interface.
+func (w *Writer) Infof(format string, args ...interface{}) {
+	if w.Verbose() {
+		w.write("info", fmt.Sprintf(format, args...))
+	}
+}
+
+// Warnf is part of the Logger interface.
+func (w *Writer) Warnf(format string, args ...interface{}) {
+	w.write("warning", fmt.Sprintf(format, args...))
+}
+
+// Errorf is part of the Logger interface.
+func (w *Writer) Errorf(format string, args ...interface{}) {
+	w.write("error", fmt.Sprintf(format, args...))
+}
+
+// Named is part of the Logger interface.
+func (w *Writer) Named(component string) Logger {
+	named := *w
+	named.component = component
+	return &named
+}
+
+// write writes the given message, at the given level.
+func (w *Writer) write(level string, msg string) {
+
+	w.mutex.Lock()
+	defer w.mutex.Unlock()
+
+	if w.json {
+		JSON(w.out, level, msg)
+	} else if w.colour && level == "error" {
+		fmt.Fprintf(w.out, "\033[31m%s\033[0m\n", msg)
+	} else {
+		fmt.Fprintf(w.out, "%s\n", msg)
+	}
+}
+
+// entry is a single line of our JSON log.
+type entry struct {
+	Time  string `json:"time"`
+	Level string `json:"level"`
+	Msg   string `json:"msg"`
+}
+
+// JSON writes the given message to the given writer as a single line of
+// JSON, which is what the log collectors of Docker and Kubernetes expect.
+// The level is "info", "warning", or "error".
+func JSON(w io.Writer, level string, msg string) {
+	json.NewEncoder(w).Encode(entry{
+		Time:  time.Now().UTC().Format(time.RFC3339),
+		Level: level,
+		Msg:   msg,
+	})
+}
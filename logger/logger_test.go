@@ -0,0 +1,82 @@
+package logger
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestJSON(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	JSON(buf, "error", "something \"broke\"")
+
+	var entry map[string]string
+	err := json.Unmarshal(buf.Bytes(), &entry)
+	if err != nil || !strings.HasSuffix(buf.String(), "}\n") {
+		t.Fatalf("invalid JSON %q: %v", buf.String(), err)
+	}
+	if entry["level"] != "error" || entry["msg"] != `something "broke"` || entry["time"] == "" {
+		t.Fatalf("unexpected entry: %v", entry)
+	}
+}
+
+func TestWriter(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	l := New(buf)
+	l.SetVerbose("fetch")
+
+	// Errors and warnings are always shown, but only verbose
+	// components show their progress.
+	l.Named("send").Infof("sending %d", 1)
+	l.Named("send").Errorf("failed %d", 2)
+	l.Named("process").Warnf("unknown %d", 3)
+	l.Named("fetch").Infof("fetching %d", 4)
+	if buf.String() != "failed 2\nunknown 3\nfetching 4\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	// Every component may be verbose.
+	buf.Reset()
+	l.SetVerbose("all")
+	l.Named("process").Infof("processing")
+	if buf.String() != "processing\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	// Errors may be coloured.
+	buf.Reset()
+	l.SetColour(true)
+	l.Named("process").Infof("processing")
+	l.Named("process").Errorf("failed")
+	if buf.String() != "processing\n\033[31mfailed\033[0m\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	// As JSON.
+	buf.Reset()
+	l.SetJSON(true)
+	l.Named("send").Infof("sending")
+	if !strings.Contains(buf.String(), `"level":"info","msg":"sending"`) {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	// Nothing is written by Discard.
+	Discard.Named("fetch").Errorf("failed")
+}
+
+func TestCheck(t *testing.T) {
+
+	for _, ok := range []string{"all", "fetch", "process, send"} {
+		if err := Check(ok); err != nil {
+			t.Errorf("unexpected error for %q: %s", ok, err)
+		}
+	}
+	for _, bad := range []string{"", "fetch,bogus"} {
+		if err := Check(bad); err == nil {
+			t.Errorf("expected an error for %q", bad)
+		}
+	}
+}
@@ -5,9 +5,12 @@
 package main
 
 import (
+	"flag"
 	"fmt"
 	"os"
 
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
 	"github.com/skx/subcommands"
 )
 
@@ -20,6 +23,99 @@ func recoverPanic() {
 	}
 }
 
+// globalArgs holds the global flags we were given, so that they may be
+// repeated within the commands we suggest.
+var globalArgs []string
+
+// globalFlags parses the flags which may precede the name of the
+// subcommand, and apply to all of them, returning the remaining
+// arguments.  Errors are reported before they're returned.
+//
+// For example:
+//
+//	rss2email -profile work cron user@example.com
+func globalFlags(args []string) ([]string, error) {
+
+	fs := flag.NewFlagSet("rss2email", flag.ContinueOnError)
+
+	configDir := fs.String("config-dir", "", "The directory holding our configuration file, and templates.")
+	stateDir := fs.String("state-dir", "", "The directory holding our state, if not the configuration directory.")
+	profile := fs.String("profile", "", "The name of the profile to use, which has its own feeds and state.")
+	feeds := fs.String("feeds", "", "An HTTPS URL, or git repository, to read the list of feeds from.")
+	stateStore := fs.String("state-store", "", "A redis://, postgres://, mysql://, or sqlite:// URL to store our state in, rather than the state directory, or \"memory\" to keep it only whilst running.")
+	container := fs.Bool("container", false, "Run in container mode, storing everything beneath /data, logging JSON, and running the daemon by default.")
+	fs.BoolVar(&noColour, "no-color", false, "Never colour our output, even upon a terminal, as is also the case if $NO_COLOR is set.")
+
+	// The environment provides our defaults.
+	err := envFlags(fs, "")
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err.Error())
+		return nil, err
+	}
+
+	err = fs.Parse(args)
+	if err != nil {
+		return nil, err
+	}
+
+	globalArgs = args[:len(args)-len(fs.Args())]
+	containerMode = *container
+	if containerMode && *configDir == "" {
+		*configDir = dataDir
+	}
+
+	configfile.SetDirectory(*configDir)
+	configfile.SetStateDirectory(*stateDir)
+	err = configfile.SetProfile(*profile)
+	if err == nil {
+		err = configfile.SetRemote(*feeds)
+	}
+	if err == nil {
+		err = withstate.SetStore(*stateStore)
+	}
+	if err != nil {
+		fmt.Fprintln(fs.Output(), err.Error())
+		return nil, err
+	}
+
+	return fs.Args(), nil
+}
+
+// commands returns each of our subcommands.
+func commands() []subcommands.Subcommand {
+	return []subcommands.Subcommand{
+		&addCmd{},
+		&benchCmd{},
+		&completionCmd{},
+		&cronCmd{},
+		&configCmd{},
+		&daemonCmd{},
+		&delCmd{},
+		&exportCmd{},
+		&filterTestCmd{},
+		&grepCmd{},
+		&healthCmd{},
+		&importCmd{},
+		&importLegacyCmd{},
+		&initCmd{},
+		&lintCmd{},
+		&listCmd{},
+		&listDefaultTemplateCmd{},
+		&logCmd{},
+		&manifestCmd{},
+		&previewCmd{},
+		&renderCmd{},
+		&rerenderCmd{},
+		&resendCmd{},
+		&restoreCmd{},
+		&searchCmd{},
+		&templateCmd{},
+		&testCmd{},
+		&tuiCmd{},
+		&versionCmd{},
+	}
+}
+
 //
 // Register the subcommands, and run the one the user chose.
 //
@@ -31,18 +127,32 @@ func main() {
 	defer recoverPanic()
 
 	//
-	// Register each of our subcommands.
+	// Register each of our subcommands, allowing their flags to be
+	// set via the environment.
 	//
-	subcommands.Register(&addCmd{})
-	subcommands.Register(&cronCmd{})
-	subcommands.Register(&configCmd{})
-	subcommands.Register(&daemonCmd{})
-	subcommands.Register(&delCmd{})
-	subcommands.Register(&exportCmd{})
-	subcommands.Register(&importCmd{})
-	subcommands.Register(&listCmd{})
-	subcommands.Register(&listDefaultTemplateCmd{})
-	subcommands.Register(&versionCmd{})
+	for _, cmd := range commands() {
+		subcommands.Register(&envCommand{Subcommand: cmd})
+	}
+
+	//
+	// Handle any global flags, leaving the subcommand
+	// in place for the subcommands library.
+	//
+	args, err := globalFlags(os.Args[1:])
+	if err != nil {
+		if err == flag.ErrHelp {
+			os.Exit(0)
+		}
+		os.Exit(1)
+	}
+
+	//
+	// Containers run the daemon by default.
+	//
+	if containerMode && len(args) == 0 {
+		args = []string{"daemon"}
+	}
+	os.Args = append(os.Args[:1], args...)
 
 	//
 	// Execute the one the user chose.
@@ -0,0 +1,100 @@
+package main
+
+import (
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestGlobalFlags(t *testing.T) {
+
+	defer func() {
+		configfile.SetDirectory("")
+		configfile.SetStateDirectory("")
+		configfile.SetProfile("")
+		configfile.SetRemote("")
+	}()
+
+	dir := t.TempDir()
+
+	args, err := globalFlags([]string{"-config-dir", dir, "-state-dir", filepath.Join(dir, "state"), "-profile=work", "cron", "-verbose", "user@example.com"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Join(args, " ") != "cron -verbose user@example.com" {
+		t.Fatalf("unexpected remaining arguments: %v", args)
+	}
+
+	c := configfile.New()
+	if c.Path() != filepath.Join(dir, "profiles", "work", "feeds.txt") {
+		t.Fatalf("unexpected config path: %s", c.Path())
+	}
+	if c.StateDirectory() != filepath.Join(dir, "state", "profiles", "work") {
+		t.Fatalf("unexpected state directory: %s", c.StateDirectory())
+	}
+
+	// Without flags the arguments are untouched.
+	args, err = globalFlags([]string{"list"})
+	if err != nil || len(args) != 1 || args[0] != "list" {
+		t.Fatalf("unexpected result: %v %v", args, err)
+	}
+
+	// Remote lists of feeds may be used.
+	_, err = globalFlags([]string{"-feeds", "https://example.com/feeds.txt", "list"})
+	if err != nil || configfile.Remote() != "https://example.com/feeds.txt" {
+		t.Fatalf("failed to set remote feed list: %v", err)
+	}
+	_, err = globalFlags([]string{"-feeds", "feeds.txt", "list"})
+	if err == nil {
+		t.Fatalf("expected error with bogus feed list")
+	}
+
+	// State may be stored in Redis.
+	_, err = globalFlags([]string{"-state-store", "redis://localhost:6379/1", "list"})
+	if err != nil {
+		t.Fatalf("failed to set state store: %v", err)
+	}
+	_, err = globalFlags([]string{"-state-store", "memcache://localhost/", "list"})
+	if err == nil {
+		t.Fatalf("expected error with bogus state store")
+	}
+	withstate.SetStore("")
+
+	// Bogus profiles are errors.
+	_, err = globalFlags([]string{"-profile", "../other", "list"})
+	if err == nil {
+		t.Fatalf("expected error with bogus profile")
+	}
+}
+
+func TestContainerFlag(t *testing.T) {
+
+	defer func() {
+		configfile.SetDirectory("")
+		containerMode = false
+		dataDir = "/data"
+	}()
+
+	dataDir = t.TempDir()
+	_, err := globalFlags([]string{"-container"})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !containerMode {
+		t.Fatalf("container mode wasn't enabled")
+	}
+	c := configfile.New()
+	if c.Path() != filepath.Join(dataDir, "feeds.txt") || c.StateDirectory() != dataDir {
+		t.Fatalf("unexpected locations: %s %s", c.Path(), c.StateDirectory())
+	}
+
+	// The configuration directory may still be given.
+	dir := t.TempDir()
+	_, err = globalFlags([]string{"-container", "-config-dir", dir})
+	if err != nil || configfile.New().Directory() != dir {
+		t.Fatalf("unexpected directory %s: %v", configfile.New().Directory(), err)
+	}
+}
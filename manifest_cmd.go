@@ -0,0 +1,270 @@
+//
+// Generate manifests to deploy rss2email.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"text/template"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+)
+
+// Structure for our options and state.
+type manifestCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// The name given to the resources we create.
+	name string
+
+	// The namespace in which they're created, if any.
+	namespace string
+
+	// The image to run.
+	image string
+
+	// The schedule upon which to run.
+	schedule string
+
+	// The size of the volume holding our state.
+	storage string
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (m *manifestCmd) Arguments(f *flag.FlagSet) {
+	m.config = configfile.New()
+
+	f.StringVar(&m.name, "name", "rss2email", "The name given to the resources which are created.")
+	f.StringVar(&m.namespace, "namespace", "", "The namespace in which to create the resources, if not the default.")
+	f.StringVar(&m.image, "image", "skx/rss2email:latest", "The container image to run.")
+	f.StringVar(&m.schedule, "schedule", "*/15 * * * *", "The cron expression upon which to run.")
+	f.StringVar(&m.storage, "storage", "100Mi", "The size of the volume which holds our state.")
+}
+
+// Info is part of the subcommand-API
+func (m *manifestCmd) Info() (string, string) {
+	return "manifest", `Generate manifests to deploy rss2email.
+
+This command writes the manifests which deploy rss2email to a cluster,
+reflecting the current configuration, to STDOUT.  At the moment only
+Kubernetes is supported, via the "k8s" argument, which generates:
+
+  * A ConfigMap holding the configuration file.
+  * A Secret holding the SMTP settings, with placeholders for the
+    password, which you must replace.
+  * A PersistentVolumeClaim to hold our state.
+  * A CronJob, which runs the "cron" sub-command in container mode.
+
+The recipients may be given following the "k8s" argument, otherwise they
+are taken from $RSS2EMAIL_RECIPIENTS.
+
+If a remote list of feeds is used, via the global '-feeds' flag, that is
+used in place of the ConfigMap.
+
+Example:
+
+    $ rss2email manifest k8s user@example.com > rss2email.yaml
+    $ rss2email manifest -schedule "@hourly" -namespace feeds k8s ..
+    $ kubectl apply -f rss2email.yaml
+`
+}
+
+// manifestK8s is the template of our Kubernetes manifests.
+//
+// Values are quoted via "quote", which produces strings which are valid
+// YAML as well as JSON.
+var manifestK8s = `{{- if not .Remote -}}
+apiVersion: v1
+kind: ConfigMap
+metadata:
+  name: {{.Name}}-config
+{{- if .Namespace}}
+  namespace: {{.Namespace}}
+{{- end}}
+data:
+  feeds.txt: |
+{{- range .Feeds}}
+    {{.}}
+{{- end}}
+---
+{{end -}}
+apiVersion: v1
+kind: Secret
+metadata:
+  name: {{.Name}}-smtp
+{{- if .Namespace}}
+  namespace: {{.Namespace}}
+{{- end}}
+type: Opaque
+stringData:
+{{- range .Secret}}
+  {{.Name}}: {{quote .Value}}
+{{- end}}
+---
+apiVersion: v1
+kind: PersistentVolumeClaim
+metadata:
+  name: {{.Name}}-state
+{{- if .Namespace}}
+  namespace: {{.Namespace}}
+{{- end}}
+spec:
+  accessModes:
+    - ReadWriteOnce
+  resources:
+    requests:
+      storage: {{.Storage}}
+---
+apiVersion: batch/v1
+kind: CronJob
+metadata:
+  name: {{.Name}}
+{{- if .Namespace}}
+  namespace: {{.Namespace}}
+{{- end}}
+spec:
+  schedule: {{quote .Schedule}}
+  concurrencyPolicy: Forbid
+  jobTemplate:
+    spec:
+      backoffLimit: 0
+      template:
+        spec:
+          restartPolicy: Never
+          securityContext:
+            runAsNonRoot: true
+            runAsUser: 65532
+            runAsGroup: 65532
+            fsGroup: 65532
+          containers:
+            - name: rss2email
+              image: {{quote .Image}}
+              args: ["cron"]
+              env:
+{{- range .Env}}
+                - name: {{.Name}}
+                  value: {{quote .Value}}
+{{- end}}
+              envFrom:
+                - secretRef:
+                    name: {{.Name}}-smtp
+              volumeMounts:
+                - name: state
+                  mountPath: /data
+{{- if not .Remote}}
+                - name: config
+                  mountPath: /config
+                  readOnly: true
+{{- end}}
+          volumes:
+            - name: state
+              persistentVolumeClaim:
+                claimName: {{.Name}}-state
+{{- if not .Remote}}
+            - name: config
+              configMap:
+                name: {{.Name}}-config
+{{- end}}
+`
+
+// manifestVar is an environmental variable within our manifests.
+type manifestVar struct {
+	Name  string
+	Value string
+}
+
+// Execute is invoked if the user specifies `manifest` as the subcommand.
+func (m *manifestCmd) Execute(args []string) int {
+
+	if len(args) < 1 || args[0] != "k8s" {
+		fmt.Printf("Usage: rss2email manifest [flags] k8s [email1 .. emailN]\n")
+		return 1
+	}
+
+	recipients := strings.Join(emailer.SplitAddresses(envRecipients(args[1:])...), ",")
+	if recipients == "" {
+		recipients = "user@example.com"
+	}
+
+	data := struct {
+		Name      string
+		Namespace string
+		Image     string
+		Schedule  string
+		Storage   string
+		Remote    bool
+		Feeds     []string
+		Secret    []manifestVar
+		Env       []manifestVar
+	}{
+		Name:      m.name,
+		Namespace: m.namespace,
+		Image:     m.image,
+		Schedule:  m.schedule,
+		Storage:   m.storage,
+		Remote:    configfile.Remote() != "",
+	}
+
+	data.Env = []manifestVar{
+		{"RSS2EMAIL_CONTAINER", "true"},
+		{"RSS2EMAIL_STATE_DIR", "/data"},
+		{"RSS2EMAIL_RECIPIENTS", recipients},
+	}
+	if data.Remote {
+		data.Env = append(data.Env, manifestVar{"RSS2EMAIL_FEEDS", configfile.Remote()})
+	} else {
+		data.Env = append(data.Env, manifestVar{"RSS2EMAIL_CONFIG_DIR", "/config"})
+	}
+	if profile := configfile.Profile(); profile != "" {
+		data.Env = append(data.Env, manifestVar{"RSS2EMAIL_PROFILE", profile})
+	}
+
+	// The feeds are parsed, and written back, so we reflect exactly
+	// what we'd read, and it is valid.
+	if !data.Remote {
+		m.config.Upgrade()
+
+		_, err := m.config.Parse()
+		if err != nil {
+			fmt.Printf("Error with config-file: %s\n", err.Error())
+			return 1
+		}
+		data.Feeds = strings.Split(strings.TrimRight(string(m.config.Bytes()), "\n"), "\n")
+	}
+
+	// The SMTP settings we're using, without the secrets themselves.
+	for _, name := range []string{"SMTP_HOST", "SMTP_PORT", "SMTP_USERNAME", "SMTP_PASSWORD"} {
+		val := os.Getenv(name)
+		switch {
+		case name == "SMTP_PASSWORD":
+			val = "CHANGE-ME"
+		case val == "" && name == "SMTP_HOST":
+			val = "smtp.example.com"
+		case val == "" && name == "SMTP_PORT":
+			val = "587"
+		case val == "":
+			val = "user@example.com"
+		}
+		data.Secret = append(data.Secret, manifestVar{name, val})
+	}
+
+	funcs := template.FuncMap{"quote": strconv.Quote}
+	t := template.Must(template.New("k8s").Funcs(funcs).Parse(manifestK8s))
+	err := t.Execute(out, data)
+	if err != nil {
+		fmt.Printf("error rendering template: %s\n", err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,85 @@
+package main
+
+import (
+	"bytes"
+	"flag"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestManifest(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	path := filepath.Join(t.TempDir(), "feeds.txt")
+	os.WriteFile(path, []byte("# News\nhttps://example.com/\n - retry: 3\n"), 0644)
+
+	os.Setenv("SMTP_HOST", "smtp.example.org")
+	os.Setenv("SMTP_PASSWORD", "s3cr3t")
+	defer os.Unsetenv("SMTP_HOST")
+	defer os.Unsetenv("SMTP_PASSWORD")
+
+	m := manifestCmd{}
+	m.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+	m.config = configfile.NewWithPath(path)
+	m.namespace = "feeds"
+
+	if m.Execute([]string{"k8s", "a@example.com,b@example.com"}) != 0 {
+		t.Fatalf("failed to generate manifest")
+	}
+	output := out.(*bytes.Buffer).String()
+
+	for _, expected := range []string{
+		"kind: ConfigMap\nmetadata:\n  name: rss2email-config\n  namespace: feeds\ndata:\n  feeds.txt: |\n    # News\n    https://example.com/\n     - retry: 3\n---\n",
+		`  SMTP_HOST: "smtp.example.org"`,
+		`  SMTP_PASSWORD: "CHANGE-ME"`,
+		`  schedule: "*/15 * * * *"`,
+		`              image: "skx/rss2email:latest"`,
+		"                - name: RSS2EMAIL_RECIPIENTS\n                  value: \"a@example.com,b@example.com\"\n",
+		"                - name: RSS2EMAIL_CONFIG_DIR\n                  value: \"/config\"\n",
+		"                name: rss2email-config\n",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Fatalf("manifest is missing %q:\n%s", expected, output)
+		}
+	}
+	if strings.Contains(output, "s3cr3t") {
+		t.Fatalf("manifest contains our password")
+	}
+
+	// Only Kubernetes is supported.
+	if m.Execute([]string{"nomad"}) != 1 {
+		t.Fatalf("expected failure with unknown target")
+	}
+}
+
+func TestManifestRemote(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	configfile.SetRemote("https://example.com/feeds.txt")
+	defer configfile.SetRemote("")
+
+	m := manifestCmd{}
+	m.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	if m.Execute([]string{"k8s"}) != 0 {
+		t.Fatalf("failed to generate manifest")
+	}
+	output := out.(*bytes.Buffer).String()
+
+	if strings.Contains(output, "ConfigMap") || strings.Contains(output, "/config") || !strings.HasPrefix(output, "apiVersion: v1\nkind: Secret") {
+		t.Fatalf("unexpected ConfigMap for remote feeds:\n%s", output)
+	}
+	if !strings.Contains(output, "value: \"https://example.com/feeds.txt\"") {
+		t.Fatalf("remote feeds missing:\n%s", output)
+	}
+}
@@ -0,0 +1,260 @@
+// Package oauth fetches the OAuth2 access tokens used to authenticate
+// against servers which require them, via either the client credentials
+// grant, or a refresh token.
+//
+// Tokens are cached, in memory and beneath the state directory, until
+// shortly before they expire, so that a token isn't requested for every
+// fetch.
+package oauth
+
+import (
+	"context"
+	"crypto/sha1"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// expiryMargin is the time before a token expires at which we stop using
+// it, so that it doesn't expire whilst in use.
+const expiryMargin = time.Minute
+
+// Config holds the details required to request an access token.
+type Config struct {
+
+	// TokenURL is the token endpoint of the authorization server.
+	TokenURL string
+
+	// ClientID and ClientSecret are the credentials of our client.
+	ClientID     string
+	ClientSecret string
+
+	// Scopes holds the scopes to request, if any.
+	Scopes []string
+
+	// RefreshToken holds a refresh token, obtained by authorizing our
+	// client, which is used to request access tokens if it is set.
+	RefreshToken string
+}
+
+// Token is an access token, as cached.
+type Token struct {
+
+	// AccessToken is the token itself, and TokenType its type, which
+	// is usually "Bearer".
+	AccessToken string `json:"access_token"`
+	TokenType   string `json:"token_type"`
+
+	// Expiry holds the time at which the token expires, or the zero
+	// time if it doesn't.
+	Expiry time.Time `json:"expiry"`
+
+	// RefreshToken holds the refresh token to use next time, as some
+	// servers issue a new refresh token along with each access token.
+	RefreshToken string `json:"refresh_token,omitempty"`
+}
+
+// valid returns true if the token may be used.
+func (t *Token) valid() bool {
+	return t != nil && t.AccessToken != "" && (t.Expiry.IsZero() || time.Now().Add(expiryMargin).Before(t.Expiry))
+}
+
+// Header returns the value of the Authorization header which presents the
+// token.
+func (t *Token) Header() string {
+
+	kind := t.TokenType
+	if kind == "" || strings.EqualFold(kind, "bearer") {
+		kind = "Bearer"
+	}
+	return kind + " " + t.AccessToken
+}
+
+// cache holds the tokens we've fetched, by key.
+var cache = struct {
+	mutex  sync.Mutex
+	tokens map[string]*Token
+}{tokens: make(map[string]*Token)}
+
+// key returns the key of our token within the cache, which is also the
+// name of the file in which it is stored.
+func (c Config) key() string {
+	return fmt.Sprintf("%x", sha1.Sum([]byte(strings.Join([]string{c.TokenURL, c.ClientID, c.ClientSecret, strings.Join(c.Scopes, " "), c.RefreshToken}, "\n"))))
+}
+
+// path returns the file in which our token is stored.
+func (c Config) path() string {
+	return filepath.Join(configfile.New().StateDirectory(), "oauth", c.key()+".json")
+}
+
+// Token returns an access token, from the cache if we have one which
+// hasn't expired, otherwise from the token endpoint via our refresh
+// token, if we have one, or the client credentials grant.
+func (c Config) Token(ctx context.Context) (*Token, error) {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	key := c.key()
+	previous := cache.tokens[key]
+	if previous.valid() {
+		return previous, nil
+	}
+
+	// A token saved by a previous run may still be valid.
+	if previous == nil {
+		if data, err := os.ReadFile(c.path()); err == nil {
+			t := &Token{}
+			if json.Unmarshal(data, t) == nil {
+				previous = t
+			}
+		}
+		if previous.valid() {
+			cache.tokens[key] = previous
+			return previous, nil
+		}
+	}
+
+	form := url.Values{}
+	refresh := ""
+	if c.RefreshToken != "" {
+		refresh = c.RefreshToken
+		if previous != nil && previous.RefreshToken != "" {
+			refresh = previous.RefreshToken
+		}
+		form.Set("grant_type", "refresh_token")
+		form.Set("refresh_token", refresh)
+	} else {
+		form.Set("grant_type", "client_credentials")
+	}
+	if len(c.Scopes) > 0 {
+		form.Set("scope", strings.Join(c.Scopes, " "))
+	}
+
+	t, err := c.request(ctx, form)
+	if err != nil {
+		return nil, err
+	}
+
+	// Keep using our refresh token, unless we were given another.
+	if t.RefreshToken == "" {
+		t.RefreshToken = refresh
+	}
+
+	cache.tokens[key] = t
+	if err = c.save(t); err != nil {
+		return nil, fmt.Errorf("failed to save token: %s", err)
+	}
+	return t, nil
+}
+
+// Invalidate forgets our cached token, if any, so that a new one is
+// requested.  This is used if the token is refused before it expires,
+// for example because it was revoked.
+//
+// Any refresh token we were issued along with the access token is kept.
+func (c Config) Invalidate() {
+
+	cache.mutex.Lock()
+	defer cache.mutex.Unlock()
+
+	key := c.key()
+	if t := cache.tokens[key]; t != nil && t.RefreshToken != "" && t.RefreshToken != c.RefreshToken {
+		kept := &Token{RefreshToken: t.RefreshToken}
+		cache.tokens[key] = kept
+		c.save(kept)
+		return
+	}
+
+	delete(cache.tokens, key)
+	os.Remove(c.path())
+}
+
+// request makes a request to the token endpoint, with the given form and
+// our client credentials, returning the token it grants.
+func (c Config) request(ctx context.Context, form url.Values) (*Token, error) {
+
+	form.Set("client_id", c.ClientID)
+	if c.ClientSecret != "" {
+		form.Set("client_secret", c.ClientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", c.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token from %s: %s", c.TokenURL, err)
+	}
+	defer resp.Body.Close()
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 1<<20))
+	if err != nil {
+		return nil, fmt.Errorf("failed to request token from %s: %s", c.TokenURL, err)
+	}
+
+	var reply struct {
+		AccessToken      string `json:"access_token"`
+		TokenType        string `json:"token_type"`
+		ExpiresIn        int64  `json:"expires_in"`
+		RefreshToken     string `json:"refresh_token"`
+		Error            string `json:"error"`
+		ErrorDescription string `json:"error_description"`
+	}
+	jerr := json.Unmarshal(body, &reply)
+
+	if resp.StatusCode != http.StatusOK || reply.Error != "" {
+		msg := resp.Status
+		if reply.Error != "" {
+			msg = reply.Error
+			if reply.ErrorDescription != "" {
+				msg += ": " + reply.ErrorDescription
+			}
+		}
+		return nil, fmt.Errorf("token request to %s failed: %s", c.TokenURL, msg)
+	}
+	if jerr != nil || reply.AccessToken == "" {
+		return nil, fmt.Errorf("token request to %s failed: the reply held no access token", c.TokenURL)
+	}
+
+	t := &Token{AccessToken: reply.AccessToken, TokenType: reply.TokenType, RefreshToken: reply.RefreshToken}
+	if reply.ExpiresIn > 0 {
+		t.Expiry = time.Now().Add(time.Duration(reply.ExpiresIn) * time.Second)
+	}
+	return t, nil
+}
+
+// save writes the given token to our file, which only we may read.
+func (c Config) save(t *Token) error {
+
+	data, err := json.Marshal(t)
+	if err != nil {
+		return err
+	}
+
+	path := c.path()
+	err = os.MkdirAll(filepath.Dir(path), 0700)
+	if err != nil {
+		return err
+	}
+
+	tmp := path + ".tmp"
+	err = os.WriteFile(tmp, data, 0600)
+	if err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
@@ -0,0 +1,119 @@
+package oauth
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// tokenServer returns a server which grants tokens, counting the number
+// of requests it has received.
+func tokenServer(t *testing.T, requests *int) *httptest.Server {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "client_credentials" || r.Form.Get("client_id") != "id" || r.Form.Get("client_secret") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			fmt.Fprintln(w, `{"error": "invalid_client", "error_description": "Bad credentials"}`)
+			return
+		}
+		*requests++
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": fmt.Sprintf("token-%d-%s", *requests, r.Form.Get("scope")),
+			"token_type":   "bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(ts.Close)
+	return ts
+}
+
+func TestToken(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	requests := 0
+	ts := tokenServer(t, &requests)
+
+	c := Config{TokenURL: ts.URL, ClientID: "id", ClientSecret: "secret", Scopes: []string{"a", "b"}}
+
+	token, err := c.Token(context.Background())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if token.Header() != "Bearer token-1-a b" {
+		t.Fatalf("unexpected token: %s", token.Header())
+	}
+
+	// The token is cached.
+	token, _ = c.Token(context.Background())
+	if token.AccessToken != "token-1-a b" || requests != 1 {
+		t.Fatalf("token wasn't cached: %v", token)
+	}
+
+	// Including beneath the state directory.
+	cache.tokens = make(map[string]*Token)
+	token, _ = c.Token(context.Background())
+	if token.AccessToken != "token-1-a b" || requests != 1 {
+		t.Fatalf("token wasn't saved: %v", token)
+	}
+	if fi, err := os.Stat(c.path()); err != nil || fi.Mode().Perm() != 0600 {
+		t.Fatalf("token file missing, or readable by others: %v", err)
+	}
+
+	// Until it is invalidated.
+	c.Invalidate()
+	token, _ = c.Token(context.Background())
+	if token.AccessToken != "token-2-a b" {
+		t.Fatalf("token wasn't replaced: %v", token)
+	}
+
+	// Errors are reported.
+	c.ClientSecret = "wrong"
+	_, err = c.Token(context.Background())
+	if err == nil || !strings.Contains(err.Error(), "invalid_client: Bad credentials") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+// TestRefreshToken ensures we use the latest refresh token we've been
+// issued, and keep it if our access token is invalidated.
+func TestRefreshToken(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	var used []string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		used = append(used, r.Form.Get("refresh_token"))
+		fmt.Fprintf(w, `{"access_token": "token-%d", "refresh_token": "refresh-%d", "expires_in": 3600}`, len(used), len(used))
+	}))
+	defer ts.Close()
+
+	c := Config{TokenURL: ts.URL, ClientID: "id", RefreshToken: "initial"}
+
+	token, err := c.Token(context.Background())
+	if err != nil || token.AccessToken != "token-1" {
+		t.Fatalf("unexpected token %v: %v", token, err)
+	}
+
+	c.Invalidate()
+	cache.tokens = make(map[string]*Token)
+
+	token, err = c.Token(context.Background())
+	if err != nil || token.AccessToken != "token-2" {
+		t.Fatalf("unexpected token %v: %v", token, err)
+	}
+	if strings.Join(used, ",") != "initial,refresh-1" {
+		t.Fatalf("unexpected refresh tokens used: %v", used)
+	}
+}
@@ -0,0 +1,275 @@
+// Package plugin runs external plugins, which may be written in any
+// language, to extend rss2email without changing it.
+//
+// Plugins are commands, run via the shell, which are given a single JSON
+// request upon STDIN, and which write a single JSON response to STDOUT.
+// There are three kinds of plugin, for the three points at which they may
+// be used:
+//
+//   - A "source" plugin provides the items of a feed, whose URL has the
+//     form "plugin:command".  It responds with the title, and link, of
+//     the feed, along with its items.
+//
+//   - A "filter" plugin is given each new item of a feed, via the
+//     "filter-plugin" option.  It may skip the item, or change it by
+//     responding with the item as it should be.
+//
+//   - An "output" plugin is given each item of a feed once it has been
+//     sent, via the "output-plugin" option.  Its response is ignored.
+//
+//   - A "when" condition is given each new item of a feed, via the "when"
+//     option, and the item is only sent if it exits with a status of
+//     zero.  It needn't write anything.
+//
+// A plugin fails if it exits with a non-zero status, or responds with an
+// error, in which case the item will be tried again upon the next run.
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// Version is the version of the protocol, which is sent with each
+// request, and which changes only if the protocol does so incompatibly.
+const Version = 1
+
+// Timeout is the time a plugin may take to respond.
+const Timeout = 2 * time.Minute
+
+// Point is the point at which a plugin is used.
+type Point string
+
+// The points at which plugins may be used.
+const (
+	Source Point = "source"
+	Filter Point = "filter"
+	Output Point = "output"
+	When   Point = "when"
+)
+
+// Option is a per-feed option.
+type Option struct {
+	Name  string `json:"name"`
+	Value string `json:"value"`
+}
+
+// Feed describes the feed a request concerns.
+type Feed struct {
+	URL     string   `json:"url"`
+	Name    string   `json:"name,omitempty"`
+	Title   string   `json:"title,omitempty"`
+	Options []Option `json:"options,omitempty"`
+}
+
+// Item is a single item of a feed.
+type Item struct {
+	Title      string     `json:"title"`
+	Link       string     `json:"link,omitempty"`
+	GUID       string     `json:"guid,omitempty"`
+	Published  *time.Time `json:"published,omitempty"`
+	Updated    *time.Time `json:"updated,omitempty"`
+	Author     string     `json:"author,omitempty"`
+	Categories []string   `json:"categories,omitempty"`
+	Summary    string     `json:"summary,omitempty"`
+	Content    string     `json:"content,omitempty"`
+}
+
+// Request is written to the STDIN of a plugin.
+type Request struct {
+
+	// Version is the version of the protocol, and Point the point
+	// at which the plugin is being used.
+	Version int   `json:"version"`
+	Point   Point `json:"point"`
+
+	// Feed is the feed the request concerns.
+	Feed Feed `json:"feed"`
+
+	// Item is the item given to filter, and output, plugins, and to
+	// conditions.
+	Item *Item `json:"item,omitempty"`
+}
+
+// Response is read from the STDOUT of a plugin, only the fields which are
+// relevant to the point at which it is used are read.
+type Response struct {
+
+	// Error reports the failure of the plugin.
+	Error string `json:"error,omitempty"`
+
+	// Title, Link, and Items describe the feed a source plugin
+	// provides.
+	Title string `json:"title,omitempty"`
+	Link  string `json:"link,omitempty"`
+	Items []Item `json:"items,omitempty"`
+
+	// Skip is set by a filter plugin if the item shouldn't be sent,
+	// with the reason why.  Otherwise Item holds the item as it should
+	// be, if it was changed.
+	Skip   bool   `json:"skip,omitempty"`
+	Reason string `json:"reason,omitempty"`
+	Item   *Item  `json:"item,omitempty"`
+}
+
+// NewFeed returns the description of the given feed, with the given title
+// if it is known.
+func NewFeed(entry configfile.Feed, title string) Feed {
+
+	f := Feed{URL: entry.URL, Name: entry.Name(), Title: title}
+	for _, opt := range entry.Options {
+		f.Options = append(f.Options, Option{Name: opt.Name, Value: opt.Value})
+	}
+	return f
+}
+
+// NewItem returns the given item of a feed.
+func NewItem(xp *gofeed.Item) *Item {
+
+	i := &Item{
+		Title:      xp.Title,
+		Link:       xp.Link,
+		GUID:       xp.GUID,
+		Published:  xp.PublishedParsed,
+		Updated:    xp.UpdatedParsed,
+		Categories: xp.Categories,
+		Summary:    xp.Description,
+		Content:    xp.Content,
+	}
+	if xp.Author != nil {
+		i.Author = xp.Author.Name
+	}
+	return i
+}
+
+// Apply changes the given item of a feed to match this item, as returned
+// by a filter plugin.
+//
+// The GUID, and link, of an item identify it, so they aren't changed.
+func (i *Item) Apply(xp *gofeed.Item) {
+
+	xp.Title = i.Title
+	xp.PublishedParsed = i.Published
+	xp.UpdatedParsed = i.Updated
+	if i.Published != nil {
+		xp.Published = i.Published.Format(time.RFC3339)
+	}
+	if i.Updated != nil {
+		xp.Updated = i.Updated.Format(time.RFC3339)
+	}
+	xp.Categories = i.Categories
+	xp.Description = i.Summary
+	xp.Content = i.Content
+	if i.Author == "" {
+		xp.Author = nil
+	} else if xp.Author == nil || xp.Author.Name != i.Author {
+		xp.Author = &gofeed.Person{Name: i.Author}
+	}
+}
+
+// Feed returns the feed provided by a source plugin.
+func (r *Response) Feed() *gofeed.Feed {
+
+	feed := &gofeed.Feed{Title: r.Title, Link: r.Link}
+	for _, i := range r.Items {
+		xp := &gofeed.Item{GUID: i.GUID, Link: i.Link}
+		i.Apply(xp)
+		feed.Items = append(feed.Items, xp)
+	}
+	return feed
+}
+
+// Run runs the given command, via the shell, giving it the request and
+// returning its response.
+//
+// Failures include anything the plugin wrote to STDERR.  A plugin which
+// writes nothing to STDOUT returns an empty response.
+func Run(ctx context.Context, command string, req Request) (*Response, error) {
+
+	stdout, err := execute(ctx, command, req)
+	if err != nil {
+		return nil, err
+	}
+
+	res := &Response{}
+	if len(bytes.TrimSpace(stdout)) == 0 {
+		return res, nil
+	}
+	err = json.Unmarshal(stdout, res)
+	if err != nil {
+		return nil, fmt.Errorf("%s plugin '%s' returned an invalid response: %s", req.Point, command, err)
+	}
+	if res.Error != "" {
+		return nil, fmt.Errorf("%s plugin '%s' failed: %s", req.Point, command, res.Error)
+	}
+	return res, nil
+}
+
+// Check runs the given condition, via the shell, giving it the request and
+// returning true if it exits with a status of zero, or false if it exits
+// with any other status.
+//
+// Conditions which can't be run, or which are killed, such as when they
+// take too long, fail.
+func Check(ctx context.Context, command string, req Request) (bool, error) {
+
+	req.Point = When
+	_, err := execute(ctx, command, req)
+	if err != nil {
+		var exit *exec.ExitError
+		if errors.As(err, &exit) && exit.ExitCode() > 0 {
+			return false, nil
+		}
+		return false, err
+	}
+	return true, nil
+}
+
+// execute runs the given command, via the shell, giving it the request and
+// returning what it wrote to STDOUT.
+func execute(ctx context.Context, command string, req Request) ([]byte, error) {
+
+	req.Version = Version
+	input, err := json.Marshal(req)
+	if err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.CommandContext(ctx, "cmd", "/C", command)
+	} else {
+		cmd = exec.CommandContext(ctx, "/bin/sh", "-c", command)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader(append(input, '\n'))
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), "RSS2EMAIL_PLUGIN="+string(req.Point))
+
+	err = cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return nil, fmt.Errorf("%s plugin '%s' failed: %w: %s", req.Point, command, err, msg)
+		}
+		return nil, fmt.Errorf("%s plugin '%s' failed: %w", req.Point, command, err)
+	}
+	return stdout.Bytes(), nil
+}
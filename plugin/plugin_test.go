@@ -0,0 +1,139 @@
+package plugin
+
+import (
+	"context"
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestRun(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins assume a unix shell")
+	}
+
+	// The plugin is given the request upon STDIN.
+	input := filepath.Join(t.TempDir(), "input.json")
+	entry := configfile.Feed{URL: "https://example.com/", Options: []configfile.Option{{Name: "name", Value: "Example"}}}
+	res, err := Run(context.Background(), "cat > "+input+"; echo \"{\\\"skip\\\": true, \\\"reason\\\": \\\"$RSS2EMAIL_PLUGIN\\\"}\"", Request{
+		Point: Filter,
+		Feed:  NewFeed(entry, "Title"),
+		Item:  &Item{Title: "Hello"},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !res.Skip || res.Reason != "filter" {
+		t.Fatalf("unexpected response: %v", res)
+	}
+
+	data, err := os.ReadFile(input)
+	if err != nil {
+		t.Fatalf("failed to read input: %s", err)
+	}
+	var req Request
+	err = json.Unmarshal(data, &req)
+	if err != nil || req.Version != Version || req.Point != Filter || req.Feed.Name != "Example" || req.Feed.Title != "Title" || req.Item.Title != "Hello" {
+		t.Fatalf("unexpected request: %s %v", data, err)
+	}
+
+	// Nothing is an empty response.
+	res, err = Run(context.Background(), "true", Request{Point: Output})
+	if err != nil || res.Skip || res.Item != nil {
+		t.Fatalf("unexpected response: %v %s", res, err)
+	}
+
+	// Failures are reported.
+	failures := map[string]string{
+		"echo broken >&2; exit 1":       "output plugin 'echo broken >&2; exit 1' failed: exit status 1: broken",
+		"echo nonsense":                 "returned an invalid response",
+		`echo '{"error": "no access"}'`: "failed: no access",
+	}
+	for command, msg := range failures {
+		_, err = Run(context.Background(), command, Request{Point: Output})
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected error %s for %s, got %v", msg, command, err)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the conditions assume a unix shell")
+	}
+
+	req := Request{Feed: Feed{URL: "https://example.com/"}, Item: &Item{Title: "Hello"}}
+	tests := map[string]bool{
+		"grep -q Hello":                                true,
+		"grep -q Goodbye":                              false,
+		`test "$RSS2EMAIL_PLUGIN" = when`:              true,
+		`grep -q '"point":"when"' && exit 0 || exit 3`: true,
+	}
+	for command, expected := range tests {
+		ok, err := Check(context.Background(), command, req)
+		if err != nil {
+			t.Fatalf("unexpected error for %s: %s", command, err)
+		}
+		if ok != expected {
+			t.Fatalf("expected %v for %s, got %v", expected, command, ok)
+		}
+	}
+
+	// Conditions which are killed fail.
+	_, err := Check(context.Background(), "kill -9 $$", req)
+	if err == nil || !strings.Contains(err.Error(), "when plugin 'kill -9 $$' failed") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestItems(t *testing.T) {
+
+	published := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	xp := &gofeed.Item{
+		Title:           "Title",
+		Link:            "https://example.com/one",
+		GUID:            "one",
+		PublishedParsed: &published,
+		Author:          &gofeed.Person{Name: "Steve", Email: "steve@example.com"},
+		Description:     "Summary",
+		Content:         "Content",
+	}
+
+	i := NewItem(xp)
+	if i.Title != "Title" || i.Author != "Steve" || i.Summary != "Summary" || i.Content != "Content" || !i.Published.Equal(published) {
+		t.Fatalf("unexpected item: %v", i)
+	}
+
+	// Changes are applied, except to the identity of the item.
+	i.Title = "Changed"
+	i.Link = "https://example.com/two"
+	i.GUID = "two"
+	i.Apply(xp)
+	if xp.Title != "Changed" || xp.Link != "https://example.com/one" || xp.GUID != "one" {
+		t.Fatalf("unexpected item: %v", xp)
+	}
+	if xp.Author.Email != "steve@example.com" {
+		t.Fatalf("author was replaced needlessly: %v", xp.Author)
+	}
+	i.Author = ""
+	i.Apply(xp)
+	if xp.Author != nil {
+		t.Fatalf("author wasn't removed")
+	}
+
+	// Source plugins provide whole feeds.
+	res := &Response{Title: "Feed", Items: []Item{{Title: "First", Link: "https://example.com/first", Published: &published}}}
+	feed := res.Feed()
+	if feed.Title != "Feed" || len(feed.Items) != 1 || feed.Items[0].Link != "https://example.com/first" || feed.Items[0].Published != "2024-01-02T03:04:05Z" {
+		t.Fatalf("unexpected feed: %v", feed)
+	}
+}
@@ -0,0 +1,310 @@
+package plugin
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/tetratelabs/wazero"
+	"github.com/tetratelabs/wazero/api"
+	"github.com/tetratelabs/wazero/imports/wasi_snapshot_preview1"
+)
+
+// MemoryLimit is the number of 64KiB pages of memory a WASM plugin may use.
+const MemoryLimit = 1024
+
+// IsWASM returns true if the given plugin is a WASM module, rather than a
+// command, which is the case if it names a file ending in ".wasm".
+func IsWASM(command string) bool {
+	return strings.HasSuffix(strings.ToLower(strings.TrimSpace(command)), ".wasm")
+}
+
+// wasm holds the runtime shared by all WASM plugins, created when the
+// first of them is run, and the modules it has compiled.
+var wasm struct {
+	sync.Mutex
+	runtime wazero.Runtime
+	modules map[string]compiled
+}
+
+// compiled is a module, compiled from the file with the given
+// modification time.
+type compiled struct {
+	module   wazero.CompiledModule
+	modified time.Time
+}
+
+// callKey is the key of the call within the context given to our host
+// functions.
+type callKey struct{}
+
+// call is the state of a single run of a WASM plugin, which its host
+// functions read, and change.
+type call struct {
+	feed  Feed
+	item  *Item
+	skip  bool
+	why   string
+	wrote bool
+}
+
+// RunWASM runs the WASM module in the given file as a filter plugin,
+// giving it the item of the request and returning its response.
+//
+// Rather than reading a request, the module imports functions from the
+// host module "rss2email", and exports a function named "filter" which
+// takes no arguments and returns nothing:
+//
+//	get(name, name_len, buf, buf_len i32) i32
+//	set(name, name_len, value, value_len i32) i32
+//	drop(reason, reason_len i32)
+//
+// get copies as much of the named field as fits into the buffer, and
+// returns its length, or -1 if there is no such field.  set replaces the
+// named field, returning 0, or -1 if it can't be set.  drop skips the
+// item, for the given reason.
+//
+// The fields are "title", "summary", "content", "author", and
+// "categories", one per line, which may be set, along with "link",
+// "guid", "published", "updated", "feed.url", "feed.name", and
+// "feed.title", which may not.
+//
+// Modules compiled for WASI may be used, but they can't see the
+// filesystem, the network, or the environment.
+func RunWASM(ctx context.Context, path string, req Request) (*Response, error) {
+
+	path = strings.TrimSpace(path)
+	if req.Point != Filter || req.Item == nil {
+		return nil, fmt.Errorf("%s plugin '%s' failed: WASM plugins may only be used as filters", req.Point, path)
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, Timeout)
+	defer cancel()
+
+	module, err := compile(path)
+	if err != nil {
+		return nil, fmt.Errorf("%s plugin '%s' failed: %s", req.Point, path, err)
+	}
+
+	item := *req.Item
+	c := &call{feed: req.Feed, item: &item}
+	ctx = context.WithValue(ctx, callKey{}, c)
+
+	// Each item is given a fresh instance of the module, so nothing
+	// leaks from one to the next.
+	stderr := &bytes.Buffer{}
+	mod, err := wasm.runtime.InstantiateModule(ctx, module, wazero.NewModuleConfig().
+		WithName("").
+		WithStartFunctions("_initialize").
+		WithStderr(stderr))
+	if err != nil {
+		return nil, wasmError(req.Point, path, err, stderr)
+	}
+	defer mod.Close(ctx)
+
+	filter := mod.ExportedFunction("filter")
+	if filter == nil {
+		return nil, fmt.Errorf("%s plugin '%s' failed: it doesn't export a 'filter' function", req.Point, path)
+	}
+	_, err = filter.Call(ctx)
+	if err != nil {
+		return nil, wasmError(req.Point, path, err, stderr)
+	}
+
+	res := &Response{Skip: c.skip, Reason: c.why}
+	if c.wrote && !c.skip {
+		res.Item = c.item
+	}
+	return res, nil
+}
+
+// wasmError reports the failure of a WASM plugin, including anything it
+// wrote to STDERR.
+func wasmError(point Point, path string, err error, stderr *bytes.Buffer) error {
+	msg := strings.TrimSpace(stderr.String())
+	if msg != "" {
+		return fmt.Errorf("%s plugin '%s' failed: %s: %s", point, path, err, msg)
+	}
+	return fmt.Errorf("%s plugin '%s' failed: %s", point, path, err)
+}
+
+// compile returns the module in the given file, compiling it if it hasn't
+// been compiled since it was last changed.
+func compile(path string) (wazero.CompiledModule, error) {
+
+	info, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	wasm.Lock()
+	defer wasm.Unlock()
+
+	ctx := context.Background()
+	if wasm.runtime == nil {
+		r := wazero.NewRuntimeWithConfig(ctx, wazero.NewRuntimeConfig().
+			WithCloseOnContextDone(true).
+			WithMemoryLimitPages(MemoryLimit))
+
+		_, err = wasi_snapshot_preview1.Instantiate(ctx, r)
+		if err != nil {
+			return nil, err
+		}
+		_, err = r.NewHostModuleBuilder("rss2email").
+			NewFunctionBuilder().WithFunc(hostGet).Export("get").
+			NewFunctionBuilder().WithFunc(hostSet).Export("set").
+			NewFunctionBuilder().WithFunc(hostDrop).Export("drop").
+			Instantiate(ctx)
+		if err != nil {
+			return nil, err
+		}
+		wasm.runtime = r
+		wasm.modules = make(map[string]compiled)
+	}
+
+	if c, ok := wasm.modules[path]; ok && c.modified.Equal(info.ModTime()) {
+		return c.module, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	module, err := wasm.runtime.CompileModule(ctx, data)
+	if err != nil {
+		return nil, err
+	}
+	if c, ok := wasm.modules[path]; ok {
+		c.module.Close(ctx)
+	}
+	wasm.modules[path] = compiled{module: module, modified: info.ModTime()}
+	return module, nil
+}
+
+// field returns the value of the named field, and whether it exists.
+func (c *call) field(name string) (string, bool) {
+
+	date := func(t *time.Time) string {
+		if t == nil {
+			return ""
+		}
+		return t.Format(time.RFC3339)
+	}
+
+	switch name {
+	case "title":
+		return c.item.Title, true
+	case "link":
+		return c.item.Link, true
+	case "guid":
+		return c.item.GUID, true
+	case "published":
+		return date(c.item.Published), true
+	case "updated":
+		return date(c.item.Updated), true
+	case "author":
+		return c.item.Author, true
+	case "categories":
+		return strings.Join(c.item.Categories, "\n"), true
+	case "summary":
+		return c.item.Summary, true
+	case "content":
+		return c.item.Content, true
+	case "feed.url":
+		return c.feed.URL, true
+	case "feed.name":
+		return c.feed.Name, true
+	case "feed.title":
+		return c.feed.Title, true
+	}
+	return "", false
+}
+
+// setField changes the named field, returning false if it can't be set.
+func (c *call) setField(name, value string) bool {
+
+	switch name {
+	case "title":
+		c.item.Title = value
+	case "author":
+		c.item.Author = value
+	case "categories":
+		c.item.Categories = nil
+		for _, category := range strings.Split(value, "\n") {
+			if strings.TrimSpace(category) != "" {
+				c.item.Categories = append(c.item.Categories, strings.TrimSpace(category))
+			}
+		}
+	case "summary":
+		c.item.Summary = value
+	case "content":
+		c.item.Content = value
+	default:
+		return false
+	}
+	c.wrote = true
+	return true
+}
+
+// read returns the string at the given location in the memory of the
+// given module.
+func read(m api.Module, offset, length uint32) (string, bool) {
+	if m.Memory() == nil {
+		return "", false
+	}
+	data, ok := m.Memory().Read(offset, length)
+	if !ok {
+		return "", false
+	}
+	return string(data), true
+}
+
+// hostGet implements the "get" function of our host module.
+func hostGet(ctx context.Context, m api.Module, name, nameLen, buf, bufLen uint32) int32 {
+
+	c := ctx.Value(callKey{}).(*call)
+	n, ok := read(m, name, nameLen)
+	if !ok {
+		return -1
+	}
+	value, ok := c.field(n)
+	if !ok {
+		return -1
+	}
+
+	data := []byte(value)
+	if uint32(len(data)) < bufLen {
+		bufLen = uint32(len(data))
+	}
+	if !m.Memory().Write(buf, data[:bufLen]) {
+		return -1
+	}
+	return int32(len(data))
+}
+
+// hostSet implements the "set" function of our host module.
+func hostSet(ctx context.Context, m api.Module, name, nameLen, value, valueLen uint32) int32 {
+
+	c := ctx.Value(callKey{}).(*call)
+	n, ok := read(m, name, nameLen)
+	if !ok {
+		return -1
+	}
+	v, ok := read(m, value, valueLen)
+	if !ok || !c.setField(n, v) {
+		return -1
+	}
+	return 0
+}
+
+// hostDrop implements the "drop" function of our host module.
+func hostDrop(ctx context.Context, m api.Module, reason, reasonLen uint32) {
+
+	c := ctx.Value(callKey{}).(*call)
+	c.skip = true
+	c.why, _ = read(m, reason, reasonLen)
+}
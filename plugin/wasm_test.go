@@ -0,0 +1,202 @@
+package plugin
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+// sleb encodes the given number as a signed LEB128.
+func sleb(n int) []byte {
+	var out []byte
+	for {
+		b := byte(n & 0x7f)
+		n >>= 7
+		if (n == 0 && b&0x40 == 0) || (n == -1 && b&0x40 != 0) {
+			return append(out, b)
+		}
+		out = append(out, b|0x80)
+	}
+}
+
+// vec encodes the given entries as a vector, which is prefixed with its
+// length.
+func vec(entries ...[]byte) []byte {
+	out := []byte{byte(len(entries))}
+	for _, e := range entries {
+		out = append(out, e...)
+	}
+	return out
+}
+
+// name encodes the given string, which is prefixed with its length.
+func name(s string) []byte {
+	return append([]byte{byte(len(s))}, s...)
+}
+
+// section encodes a section of a module.
+func section(id byte, content []byte) []byte {
+	return append([]byte{id, byte(len(content))}, content...)
+}
+
+// module assembles a WASM module which imports our host functions, and
+// exports a filter function with the given code, and a memory holding the
+// given strings.
+func module(code []byte, data map[int]string) []byte {
+
+	i32 := byte(0x7f)
+	types := vec(
+		[]byte{0x60, 4, i32, i32, i32, i32, 1, i32},
+		[]byte{0x60, 2, i32, i32, 0},
+		[]byte{0x60, 0, 0},
+	)
+	imports := vec(
+		append(append(name("rss2email"), name("get")...), 0, 0),
+		append(append(name("rss2email"), name("set")...), 0, 0),
+		append(append(name("rss2email"), name("drop")...), 0, 1),
+	)
+	exports := vec(
+		append(name("memory"), 2, 0),
+		append(name("filter"), 0, 3),
+	)
+
+	// A single local, and the end of the function.
+	body := append([]byte{1, 1, i32}, code...)
+	body = append(body, 0x0b)
+
+	var segments [][]byte
+	for offset, s := range data {
+		seg := append([]byte{0, 0x41}, sleb(offset)...)
+		seg = append(seg, 0x0b)
+		seg = append(seg, name(s)...)
+		segments = append(segments, seg)
+	}
+
+	out := []byte{0, 'a', 's', 'm', 1, 0, 0, 0}
+	out = append(out, section(1, types)...)
+	out = append(out, section(2, imports)...)
+	out = append(out, section(3, vec([]byte{2}))...)
+	out = append(out, section(5, vec([]byte{0, 1}))...)
+	out = append(out, section(7, exports)...)
+	out = append(out, section(10, vec(append([]byte{byte(len(body))}, body...)))...)
+	out = append(out, section(11, vec(segments...))...)
+	return out
+}
+
+// i32 encodes an i32.const instruction.
+func i32(n int) []byte {
+	return append([]byte{0x41}, sleb(n)...)
+}
+
+// join concatenates instructions.
+func join(code ...[]byte) []byte {
+	var out []byte
+	for _, c := range code {
+		out = append(out, c...)
+	}
+	return out
+}
+
+func TestRunWASM(t *testing.T) {
+
+	dir := t.TempDir()
+	write := func(file string, data []byte) string {
+		path := filepath.Join(dir, file)
+		err := os.WriteFile(path, data, 0644)
+		if err != nil {
+			t.Fatalf("failed to write module: %s", err)
+		}
+		return path
+	}
+
+	// Prefix the title with "[wasm] ", by reading it after the prefix
+	// and then setting the title to both.
+	prefix := write("prefix.wasm", module(join(
+		i32(0), i32(5), i32(71), i32(1000),
+		[]byte{0x10, 0}, // call get
+		[]byte{0x21, 0}, // local.set 0
+		i32(0), i32(5), i32(64),
+		[]byte{0x20, 0}, // local.get 0
+		i32(7),
+		[]byte{0x6a},    // i32.add
+		[]byte{0x10, 1}, // call set
+		[]byte{0x1a},    // drop the result
+	), map[int]string{0: "title", 64: "[wasm] "}))
+
+	// Skip everything.
+	skip := write("skip.wasm", module(join(
+		i32(16), i32(4),
+		[]byte{0x10, 2}, // call drop
+	), map[int]string{16: "spam"}))
+
+	// Try to change the link, which isn't allowed.
+	link := write("link.wasm", module(join(
+		i32(0), i32(4), i32(0), i32(4),
+		[]byte{0x10, 1}, // call set
+		[]byte{0x21, 0}, // local.set 0
+		[]byte{0x20, 0}, // local.get 0
+		i32(-1),
+		[]byte{0x47},       // i32.ne
+		[]byte{0x04, 0x40}, // if
+		[]byte{0x00},       // unreachable
+		[]byte{0x0b},       // end
+	), map[int]string{0: "link"}))
+
+	req := Request{
+		Point: Filter,
+		Feed:  Feed{URL: "https://example.com/"},
+		Item:  &Item{Title: "Hello", Link: "https://example.com/hello"},
+	}
+
+	res, err := RunWASM(context.Background(), prefix, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if res.Skip || res.Item == nil || res.Item.Title != "[wasm] Hello" || res.Item.Link != "https://example.com/hello" {
+		t.Fatalf("unexpected response: %v", res)
+	}
+	if req.Item.Title != "Hello" {
+		t.Fatalf("the request was changed")
+	}
+
+	res, err = RunWASM(context.Background(), skip, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !res.Skip || res.Reason != "spam" || res.Item != nil {
+		t.Fatalf("unexpected response: %v", res)
+	}
+
+	res, err = RunWASM(context.Background(), link, req)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !reflect.DeepEqual(res, &Response{}) {
+		t.Fatalf("unexpected response: %v", res)
+	}
+
+	// Failures are reported.
+	failures := map[string]string{
+		filepath.Join(dir, "missing.wasm"):            "no such file",
+		write("broken.wasm", []byte("nonsense")):      "failed",
+		write("trap.wasm", module([]byte{0x00}, nil)): "unreachable",
+	}
+	for path, msg := range failures {
+		_, err = RunWASM(context.Background(), path, req)
+		if err == nil || !strings.Contains(err.Error(), msg) {
+			t.Fatalf("expected error %s for %s, got %v", msg, path, err)
+		}
+	}
+
+	// Only filters may be WASM modules.
+	_, err = RunWASM(context.Background(), prefix, Request{Point: Output, Item: req.Item})
+	if err == nil {
+		t.Fatalf("expected an error for an output plugin")
+	}
+	if !IsWASM(" filter.WASM") || IsWASM("python3 filter.py") {
+		t.Fatalf("wrong detection of WASM plugins")
+	}
+}
@@ -0,0 +1,101 @@
+//
+// Show the items of a feed, before subscribing to it.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+)
+
+// Structure for our options and state.
+type previewCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+}
+
+// Arguments handles our flag-setup.
+func (p *previewCmd) Arguments(f *flag.FlagSet) {
+	p.config = configfile.New()
+}
+
+// Info is part of the subcommand-API.
+func (p *previewCmd) Info() (string, string) {
+	return "preview", `Show the items of a feed, before subscribing to it.
+
+This sub-command fetches the given feed, and shows a table of its items,
+with the date each was published, its title, and the GUID which
+identifies it, along with whether it is "new", and so would be sent, or
+has been "seen".
+
+Nothing is sent, and the state of the items is not changed, so you may
+evaluate a feed before adding it.  If the feed is present within the
+configuration file, which may be given by its URL or name, its options
+are used.
+
+Example:
+
+    $ rss2email preview https://blog.steve.fi/index.rss
+`
+}
+
+// Execute is invoked if the user specifies `preview` as the subcommand.
+func (p *previewCmd) Execute(args []string) int {
+
+	if len(args) != 1 {
+		fmt.Printf("Usage: rss2email preview feed-url\n")
+		return 1
+	}
+
+	// Use the options of the feed, if it is configured.
+	entry := configfile.Feed{URL: args[0]}
+	if p.config.Exists() {
+		entries, err := p.config.Parse()
+		if err != nil {
+			fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+			return 1
+		}
+
+		found := configfile.Find(entries, args[0])
+		if len(found) > 1 {
+			fmt.Printf("'%s' matches %d feeds, please be more specific\n", args[0], len(found))
+			return 1
+		}
+		if len(found) == 1 {
+			entry = found[0]
+		}
+	}
+
+	items, err := processor.New().Preview(context.Background(), entry)
+	if err != nil {
+		fmt.Printf("failed to fetch %s: %s\n", entry.Label(), err.Error())
+		return 1
+	}
+
+	fresh := 0
+	fmt.Fprintf(out, "%-5s %-16s %-40s %s\n", "STATE", "DATE", "TITLE", "GUID")
+	for _, item := range items {
+
+		state := "seen"
+		if item.New {
+			state = "new"
+			fresh++
+		}
+
+		date := "-"
+		if !item.Date.IsZero() {
+			date = item.Date.Local().Format("2006-01-02 15:04")
+		}
+
+		fmt.Fprintf(out, "%-5s %-16s %-40s %s\n", state, date, truncate(item.Title, 40), item.GUID)
+	}
+
+	fmt.Fprintf(out, "\n%d items, %d new\n", len(items), fresh)
+	return 0
+}
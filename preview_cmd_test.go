@@ -0,0 +1,59 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestPreview(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/preview/1</link><guid>tag:example.com,1</guid><pubDate>Tue, 02 Jan 2024 03:04:05 +0000</pubDate></item>
+<item><title>A title which is far too long to be shown within the table</title><link>https://example.com/preview/2</link></item>
+</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	// The first item has been seen.
+	seen := withstate.FeedItem{Item: &gofeed.Item{GUID: "tag:example.com,1"}}
+	seen.RecordSeen()
+
+	p := previewCmd{config: configfile.NewWithPath(t.TempDir() + "/feeds.txt")}
+	if p.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with no feed")
+	}
+
+	for i := 0; i < 2; i++ {
+		out = new(bytes.Buffer)
+		if p.Execute([]string{ts.URL}) != 0 {
+			t.Fatalf("unexpected error")
+		}
+
+		// Nothing is recorded, so the second item is still new.
+		date := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Local().Format("2006-01-02 15:04")
+		expected := `STATE DATE             TITLE                                    GUID
+seen  ` + date + ` First                                    tag:example.com,1
+new   -                A title which is far too long to be sho… https://example.com/preview/2
+
+2 items, 1 new
+`
+		if out.(*bytes.Buffer).String() != expected {
+			t.Fatalf("unexpected output:\n%s\n%s", out.(*bytes.Buffer).String(), expected)
+		}
+	}
+}
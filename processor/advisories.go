@@ -0,0 +1,98 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/advisory"
+	"github.com/skx/rss2email/configfile"
+)
+
+// maxAdvisories is the largest number of advisories we'll look up for a
+// single item, any others are linked without their score.
+const maxAdvisories = 20
+
+// wantAdvisories returns true if the given feed has the "advisories"
+// option set, so that the advisories its items mention are linked.
+func wantAdvisories(config configfile.Feed) bool {
+	return boolOption(config, "advisories", false)
+}
+
+// boolOption returns the boolean value of the named per-feed option, or
+// the given default if it was not set.
+func boolOption(config configfile.Feed, name string, def bool) bool {
+
+	for _, opt := range config.Options {
+		if opt.Name == name {
+			val, err := strconv.ParseBool(opt.Value)
+			if err == nil {
+				def = val
+			}
+		}
+	}
+	return def
+}
+
+// linkAdvisories appends a list of the advisories the given item mentions
+// to its content, linking to each along with its score.
+//
+// Failing to look up an advisory isn't fatal, it is linked without its
+// score.  Returns true if the item was changed.
+func (p *Processor) linkAdvisories(xp *gofeed.Item) bool {
+
+	ids := advisory.IDs(xp.Title + "\n" + xp.Content + "\n" + xp.Description)
+	if len(ids) == 0 {
+		return false
+	}
+
+	client := advisory.New()
+	found := make(map[string]*advisory.Advisory)
+	for i, id := range ids {
+		if i >= maxAdvisories {
+			break
+		}
+		adv, err := client.Lookup(id)
+		if err != nil {
+			p.message(fmt.Sprintf("\t\tFailed to look up %s: %s\n", id, err))
+			continue
+		}
+		found[id] = adv
+	}
+
+	list := advisoryList(ids, found)
+	if xp.Content != "" {
+		xp.Content += list
+	} else {
+		xp.Description += list
+	}
+	return true
+}
+
+// advisoryList returns the HTML listing the given advisories, along with
+// the scores of those we found.
+func advisoryList(ids []string, found map[string]*advisory.Advisory) string {
+
+	var sb strings.Builder
+	sb.WriteString("\n<h3>Advisories</h3>\n<ul>\n")
+	for _, id := range ids {
+
+		adv := found[id]
+		if adv == nil {
+			adv = &advisory.Advisory{ID: id}
+		}
+
+		score := "score unknown"
+		switch {
+		case adv.Scored():
+			score = fmt.Sprintf("CVSS %s %.1f %s", adv.Version, adv.Score, adv.Severity)
+		case found[id] != nil:
+			score = "not yet scored"
+		}
+		fmt.Fprintf(&sb, "<li><a href=\"%s\">%s</a> &mdash; %s</li>\n", html.EscapeString(adv.URL()), html.EscapeString(id), html.EscapeString(strings.TrimSpace(score)))
+	}
+	sb.WriteString("</ul>\n")
+	return sb.String()
+}
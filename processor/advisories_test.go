@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/advisory"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestWantAdvisories ensures the option is parsed.
+func TestWantAdvisories(t *testing.T) {
+
+	if wantAdvisories(configfile.Feed{}) {
+		t.Fatalf("advisories shouldn't be linked by default")
+	}
+	if !wantAdvisories(configfile.Feed{Options: []configfile.Option{{Name: "advisories", Value: "true"}}}) {
+		t.Fatalf("advisories should be linked")
+	}
+}
+
+// TestAdvisoryList ensures the advisories are listed, with their scores.
+func TestAdvisoryList(t *testing.T) {
+
+	found := map[string]*advisory.Advisory{
+		"CVE-2021-44228": {ID: "CVE-2021-44228", Score: 10, Severity: "CRITICAL", Version: "3.1"},
+		"CVE-2024-0001":  {ID: "CVE-2024-0001"},
+	}
+	out := advisoryList([]string{"CVE-2021-44228", "CVE-2024-0001", "CVE-2024-0002"}, found)
+
+	for _, expected := range []string{
+		`<a href="https://nvd.nist.gov/vuln/detail/CVE-2021-44228">CVE-2021-44228</a> &mdash; CVSS 3.1 10.0 CRITICAL`,
+		`<a href="https://nvd.nist.gov/vuln/detail/CVE-2024-0001">CVE-2024-0001</a> &mdash; not yet scored`,
+		`<a href="https://nvd.nist.gov/vuln/detail/CVE-2024-0002">CVE-2024-0002</a> &mdash; score unknown`,
+	} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, out)
+		}
+	}
+}
+
+// TestLinkAdvisoriesNone ensures items which mention no advisories are
+// unchanged.
+func TestLinkAdvisoriesNone(t *testing.T) {
+
+	p := New()
+
+	xp := &gofeed.Item{Title: "Release 1.2", Content: "<p>Bug fixes.</p>"}
+	if p.linkAdvisories(xp) {
+		t.Fatalf("item shouldn't have changed")
+	}
+	if xp.Content != "<p>Bug fixes.</p>" {
+		t.Fatalf("unexpected content: %s", xp.Content)
+	}
+}
@@ -0,0 +1,239 @@
+package processor
+
+import (
+	"bytes"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/k3a/html2text"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Stage holds the measurements of a single stage of the processing of
+// items, as made by Bench.
+type Stage struct {
+
+	// Name is the name of the stage, and Count the number of times it
+	// was run.
+	Name  string
+	Count int
+
+	// Duration is the time the stage took, in total.
+	Duration time.Duration
+
+	// Allocs and Bytes hold the number of allocations, and the number
+	// of bytes, allocated by the stage in total.
+	Allocs uint64
+	Bytes  uint64
+}
+
+// Benchmark contains the results of Bench.
+type Benchmark struct {
+
+	// Feeds and Items hold the number of feeds, and items, which were
+	// processed in each of the Rounds.
+	Feeds  int
+	Items  int
+	Rounds int
+
+	// Workers is the number of items processed concurrently.
+	Workers int
+
+	// Stages holds the measurements of each stage, in order.
+	Stages []Stage
+}
+
+// Bench processes the given feeds, which are held in memory, as though
+// they had been fetched from the given configuration entry, the given
+// number of times, and measures each stage of that processing.
+//
+// Each stage is run for every item before the next begins, by the given
+// number of workers, so the measurements of one stage aren't mixed with
+// those of another.  Nothing is sent, the state of the items isn't
+// changed, and icons aren't fetched, though the options of the entry
+// which change items may fetch the links of the items.
+func (p *Processor) Bench(entry configfile.Feed, feeds [][]byte, rounds int, workers int) (Benchmark, error) {
+
+	b := Benchmark{Feeds: len(feeds), Rounds: rounds, Workers: workers}
+	if rounds < 1 || workers < 1 {
+		return b, fmt.Errorf("the number of rounds, and workers, must be positive")
+	}
+
+	// The work of each stage, which is indexed by the number of the
+	// feed, or item.
+	parsed := make([]*gofeed.Feed, len(feeds)*rounds)
+	err := b.measure("parse", len(parsed), func(i int) error {
+		var err error
+		parsed[i], err = gofeed.NewParser().Parse(bytes.NewReader(feeds[i%len(feeds)]))
+		return err
+	})
+	if err != nil {
+		return b, err
+	}
+
+	type work struct {
+		feed    *gofeed.Feed
+		item    withstate.FeedItem
+		content string
+		text    string
+	}
+	var items []*work
+	for _, feed := range parsed {
+		for _, xp := range feed.Items {
+			items = append(items, &work{feed: feed, item: withstate.FeedItem{Item: xp}})
+		}
+	}
+	b.Items = len(items) / rounds
+
+	stages := []struct {
+		name string
+		fn   func(w *work) error
+	}{
+		{"prepare", func(w *work) error {
+			return p.prepareItem(entry, w.feed, w.item.Item)
+		}},
+		{"content", func(w *work) error {
+			var err error
+			w.content, err = w.item.HTMLContent()
+			if err != nil {
+				w.content = w.item.RawContent()
+			}
+			return nil
+		}},
+		{"filter", func(w *work) error {
+			filter(entry, w.item.Title, w.content)
+			return nil
+		}},
+		{"text", func(w *work) error {
+			w.text = html2text.HTML2Text(w.content)
+			return nil
+		}},
+		{"render", func(w *work) error {
+			msg, err := p.newEmailer(entry, w.feed, w.item, nil).Render([]string{"bench@example.com"}, w.text, w.content)
+			if err == nil && msg.TemplateError != nil {
+				err = msg.TemplateError
+			}
+			return err
+		}},
+	}
+
+	for _, s := range stages {
+		fn := s.fn
+		err = b.measure(s.name, len(items), func(i int) error {
+			return fn(items[i])
+		})
+		if err != nil {
+			return b, err
+		}
+	}
+
+	return b, nil
+}
+
+// measure runs the given function for each of the given number of items,
+// via our workers, and records the measurements of the stage.
+//
+// If any run fails the error of the first is returned.
+func (b *Benchmark) measure(name string, count int, fn func(i int) error) error {
+
+	var before, after runtime.MemStats
+	runtime.GC()
+	runtime.ReadMemStats(&before)
+	started := time.Now()
+
+	var wg sync.WaitGroup
+	var mutex sync.Mutex
+	var failed error
+	next := int64(-1)
+	for n := 0; n < b.Workers; n++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for {
+				i := int(atomic.AddInt64(&next, 1))
+				if i >= count {
+					return
+				}
+				if err := fn(i); err != nil {
+					mutex.Lock()
+					if failed == nil {
+						failed = fmt.Errorf("%s failed: %s", name, err)
+					}
+					mutex.Unlock()
+				}
+			}
+		}()
+	}
+	wg.Wait()
+
+	elapsed := time.Since(started)
+	runtime.ReadMemStats(&after)
+
+	b.Stages = append(b.Stages, Stage{
+		Name:     name,
+		Count:    count,
+		Duration: elapsed,
+		Allocs:   after.Mallocs - before.Mallocs,
+		Bytes:    after.TotalAlloc - before.TotalAlloc,
+	})
+	return failed
+}
+
+// Duration returns the total time taken by each stage.
+func (b Benchmark) Duration() time.Duration {
+
+	var total time.Duration
+	for _, s := range b.Stages {
+		total += s.Duration
+	}
+	return total
+}
+
+// String returns the results as a table, showing the time taken, and the
+// memory allocated, by each stage, both in total and for each run.
+func (b Benchmark) String() string {
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "%s, %s, %s, %s, in %s\n\n",
+		plural(b.Feeds, "feed"),
+		plural(b.Items, "item"),
+		plural(b.Rounds, "round"),
+		plural(b.Workers, "worker"),
+		b.Duration().Round(time.Millisecond))
+
+	fmt.Fprintf(&sb, "%-8s %8s %12s %12s %12s %12s\n", "Stage", "Runs", "Total", "Per run", "Allocs/run", "Bytes/run")
+	for _, s := range b.Stages {
+		if s.Count == 0 {
+			fmt.Fprintf(&sb, "%-8s %8d\n", s.Name, s.Count)
+			continue
+		}
+		n := uint64(s.Count)
+		fmt.Fprintf(&sb, "%-8s %8d %12s %12s %12d %12d\n",
+			s.Name,
+			s.Count,
+			roundDuration(s.Duration),
+			roundDuration(s.Duration/time.Duration(s.Count)),
+			s.Allocs/n,
+			s.Bytes/n)
+	}
+	return sb.String()
+}
+
+// roundDuration rounds the given duration, so that it isn't shown with
+// needless precision.
+func roundDuration(d time.Duration) time.Duration {
+
+	switch {
+	case d >= time.Second:
+		return d.Round(time.Millisecond)
+	case d >= time.Millisecond:
+		return d.Round(time.Microsecond)
+	}
+	return d
+}
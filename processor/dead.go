@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// activeFeeds returns the given entries, without those which have been
+// archived.
+func (p *Processor) activeFeeds(entries []configfile.Feed) []configfile.Feed {
+
+	var active []configfile.Feed
+	for _, entry := range entries {
+		if entry.Archived() {
+			p.message(fmt.Sprintf("Skipping %s, which is archived", entry.Label()))
+			continue
+		}
+		active = append(active, entry)
+	}
+	return active
+}
+
+// deadReason returns the reason the feed with the given health is probably
+// dead, which is recorded as the value of its "archived" option.
+func deadReason(h withstate.Health, now time.Time) string {
+
+	reason := "no new items since " + h.LastItem.Format("2006-01-02")
+	if h.LastItem.IsZero() {
+		reason = "no new items since " + h.Since.Format("2006-01-02")
+	}
+
+	// A failing feed is reported as such, unless it was silent for
+	// long enough before it began to fail.
+	if h.Consecutive > 0 && (h.LastSuccess.IsZero() || h.Silence(now) < now.Sub(h.LastSuccess)) {
+		since := h.LastSuccess
+		if since.IsZero() {
+			since = h.Since
+		}
+		reason = fmt.Sprintf("failing since %s: %s", since.Format("2006-01-02"), h.LastError)
+	}
+	return now.Format("2006-01-02") + ", " + reason
+}
+
+// archiveDead archives those of the given entries which are probably dead,
+// having had no new items, or having failed every fetch, for longer than
+// the time set via SetArchiveDead.
+//
+// Archived feeds are moved to the end of the configuration file, and are no
+// longer fetched, but their state is kept.  A notification listing them,
+// and describing how they may be restored, is sent to the recipients.
+func (p *Processor) archiveDead(conf *configfile.ConfigFile, entries []configfile.Feed, recipients []string, now time.Time) error {
+
+	var archived []configfile.Feed
+	for _, entry := range entries {
+
+		h, err := withstate.FeedHealth(entry.URL)
+		if err != nil {
+			return err
+		}
+		if !h.Dead(now, p.archiveAfter) {
+			continue
+		}
+
+		entry.Options = append(entry.Options, configfile.Option{Name: "archived", Value: deadReason(h, now)})
+
+		// Feeds of a feed-reader aren't within our configuration.
+		if conf.Update(entry) {
+			archived = append(archived, entry)
+		}
+	}
+
+	if len(archived) == 0 {
+		return nil
+	}
+
+	err := conf.Save()
+	if err != nil {
+		return fmt.Errorf("error archiving feeds - %s", err)
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The following feeds have had no new items, or have failed every fetch,\n")
+	fmt.Fprintf(&sb, "for %d days, and have been archived.  They are no longer fetched, but\n", int(p.archiveAfter.Hours()/24))
+	fmt.Fprintf(&sb, "their state has been kept.\n\n")
+	for _, entry := range archived {
+		fmt.Fprintf(&sb, "  %s\n", entry.Label())
+		if entry.Label() != entry.URL {
+			fmt.Fprintf(&sb, "    %s\n", entry.URL)
+		}
+		for _, opt := range entry.Options {
+			if opt.Name == "archived" {
+				fmt.Fprintf(&sb, "    Archived %s\n", opt.Value)
+			}
+		}
+	}
+	fmt.Fprintf(&sb, "\nTo restore a feed run:\n\n    rss2email restore URL\n\n")
+	fmt.Fprintf(&sb, "Or remove its \"archived\" option from %s.\n", conf.Path())
+
+	p.message(sb.String())
+
+	if !p.send || !p.wantOutput("email") || len(recipients) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("rss2email archived %s", plural(len(archived), "feed"))
+	err = p.sendNotice(recipients, subject, sb.String())
+	if err != nil {
+		return fmt.Errorf("failed to email the list of archived feeds: %s", err)
+	}
+	return nil
+}
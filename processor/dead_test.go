@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestArchiveDead(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	path := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(path, []byte(`# My feeds
+https://example.com/alive
+https://example.com/dead
+ - name: Dead
+`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	withstate.RecordFetch("https://example.com/alive", withstate.Fetch{NewItems: 1, Newest: time.Now()})
+	withstate.RecordFetch("https://example.com/dead", withstate.Fetch{Newest: time.Now().AddDate(-1, 0, 0)})
+
+	conf := configfile.New()
+	entries, err := conf.Parse()
+	if err != nil {
+		t.Fatalf("failed to parse config: %s", err)
+	}
+
+	p := New()
+	p.SetOutputs([]string{"jsonl"})
+	p.SetArchiveDead(180 * 24 * time.Hour)
+
+	err = p.archiveDead(conf, entries, nil, time.Now())
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, _ := os.ReadFile(path)
+	expected := `# My feeds
+https://example.com/alive
+
+` + configfile.ArchivedHeader + `
+https://example.com/dead
+ - name:Dead
+ - archived:` + time.Now().Format("2006-01-02") + `, no new items since ` + time.Now().AddDate(-1, 0, 0).Format("2006-01-02") + `
+`
+	if string(data) != expected {
+		t.Fatalf("unexpected file saved:\n%s", data)
+	}
+
+	// The archived feed isn't fetched.
+	entries, _ = configfile.New().Parse()
+	active := p.activeFeeds(entries)
+	if len(active) != 1 || active[0].URL != "https://example.com/alive" {
+		t.Fatalf("unexpected active feeds: %v", active)
+	}
+}
+
+func TestCatchUp(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+	p.SetOutputs([]string{"jsonl"})
+
+	entry := configfile.Feed{URL: "https://example.com/rss"}
+	feed := &gofeed.Feed{Items: []*gofeed.Item{{Title: "Hello", GUID: "rss2email-catch-up-test"}}}
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	// The items of a restored feed are recorded, but not sent.
+	withstate.SetCatchUp(entry.URL, true)
+
+	err := p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("items of a restored feed were sent: %s", buf.String())
+	}
+
+	// But only once.
+	feed.Items[0].GUID = "rss2email-catch-up-test-2"
+	err = p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(buf.String(), "Hello") {
+		t.Fatalf("new item of a restored feed wasn't sent")
+	}
+}
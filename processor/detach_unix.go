@@ -0,0 +1,17 @@
+//go:build !windows
+// +build !windows
+
+package processor
+
+import (
+	"os/exec"
+	"syscall"
+)
+
+// detach places the given command within its own process group, so that
+// an interrupt from the terminal doesn't kill it part-way through.
+//
+// We handle interrupts ourselves, allowing commands to complete.
+func detach(cmd *exec.Cmd) {
+	cmd.SysProcAttr = &syscall.SysProcAttr{Setpgid: true}
+}
@@ -0,0 +1,10 @@
+//go:build windows
+// +build windows
+
+package processor
+
+import "os/exec"
+
+// detach is a no-op upon Windows.
+func detach(cmd *exec.Cmd) {
+}
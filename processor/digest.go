@@ -0,0 +1,337 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/k3a/html2text"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/schedule"
+	"github.com/skx/rss2email/wayback"
+	"github.com/skx/rss2email/withstate"
+)
+
+// inPageLink matches links to anchors within the same document, such as
+// those of the table of contents of a digest.
+var inPageLink = regexp.MustCompile(`(?is)<a\s[^>]*href="#[^"]*"[^>]*>(.*?)</a>`)
+
+// plainText returns the text of the given HTML, for the text part of an
+// email.
+//
+// Links to anchors within the email are replaced by their text, as they
+// lead nowhere within the text part, so that the table of contents of a
+// digest becomes a numbered list of the titles of its items.
+func plainText(content string) string {
+	if strings.Contains(content, `href="#`) {
+		content = inPageLink.ReplaceAllString(content, "$1")
+	}
+	return html2text.HTML2Text(content)
+}
+
+// digestItem returns a single item which contains each of the given
+// queued items, so that they may be sent as one email.
+//
+// The digest is rendered via the usual template of its feed, with the
+// items forming its content.  If the items came from several feeds the
+// title of the feed is shown alongside each.
+//
+// If there is more than one item the digest begins with a numbered table
+// of contents, which links to the numbered section of each item.
+func digestItem(feed *gofeed.Feed, queued []withstate.QueuedItem) *gofeed.Item {
+
+	sources := make(map[string]bool)
+	for _, q := range queued {
+		sources[q.Feed] = true
+	}
+
+	var sb strings.Builder
+	if len(queued) > 1 {
+		var contents []string
+		for i, q := range queued {
+			contents = append(contents, fmt.Sprintf("%d. <a href=\"#item-%d\">%s</a>", i+1, i+1, html.EscapeString(q.Item.Title)))
+		}
+		fmt.Fprintf(&sb, "<p id=\"contents\"><strong>Contents</strong></p>\n<p>%s</p>\n<hr>\n", strings.Join(contents, "<br>"))
+	}
+
+	for i, q := range queued {
+
+		item := q.Item
+		if len(queued) > 1 {
+			fmt.Fprintf(&sb, "<h2 id=\"item-%d\">%d. <a href=\"%s\">%s</a></h2>\n", i+1, i+1, html.EscapeString(item.Link), html.EscapeString(item.Title))
+		} else {
+			fmt.Fprintf(&sb, "<h2><a href=\"%s\">%s</a></h2>\n", html.EscapeString(item.Link), html.EscapeString(item.Title))
+		}
+
+		var about []string
+		if len(sources) > 1 {
+			title := q.FeedTitle
+			if title == "" {
+				title = q.Feed
+			}
+			about = append(about, html.EscapeString(title))
+		}
+		if item.PublishedParsed != nil {
+			about = append(about, item.PublishedParsed.Format("Mon, 02 Jan 2006 15:04"))
+		}
+		if snapshot := item.Custom[wayback.Key]; snapshot != "" {
+			about = append(about, fmt.Sprintf("<a href=\"%s\">archived</a>", html.EscapeString(snapshot)))
+		}
+		if len(about) > 0 {
+			fmt.Fprintf(&sb, "<p><small>%s</small></p>\n", strings.Join(about, " &middot; "))
+		}
+
+		content := (&withstate.FeedItem{Item: item}).RawContent()
+		if content != "" {
+			fmt.Fprintf(&sb, "%s\n", content)
+		}
+		sb.WriteString("<hr>\n")
+	}
+
+	now := time.Now()
+	title := feed.Title
+	if title == "" {
+		title = feed.FeedLink
+	}
+
+	return &gofeed.Item{
+		Title:           fmt.Sprintf("%s from %s", plural(len(queued), "new item"), title),
+		Link:            feed.Link,
+		Content:         sb.String(),
+		GUID:            fmt.Sprintf("rss2email-digest-%s-%d", feed.FeedLink, now.UnixNano()),
+		Published:       now.Format(time.RFC1123Z),
+		PublishedParsed: &now,
+	}
+}
+
+// days holds the abbreviations of the days of the week, as used within
+// cron expressions.
+var days = []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}
+
+// ParseDigest parses the schedule of a digest, which may be "daily",
+// "weekly", either followed by a time such as "18:00", with "weekly"
+// also accepting a day such as "sunday".  Cron expressions are accepted
+// too, for more complex schedules.
+func ParseDigest(spec string) (*schedule.Schedule, error) {
+
+	fields := strings.Fields(strings.ToLower(spec))
+	if len(fields) == 0 || (fields[0] != "daily" && fields[0] != "weekly") {
+		return schedule.Parse(spec)
+	}
+
+	day := "*"
+	if fields[0] == "weekly" {
+		day = "SUN"
+		if len(fields) > 1 && !strings.Contains(fields[1], ":") {
+			day = ""
+			for _, d := range days {
+				if len(fields[1]) >= 3 && strings.HasPrefix(strings.ToUpper(fields[1]), d) {
+					day = d
+				}
+			}
+			if day == "" {
+				return nil, fmt.Errorf("invalid digest '%s': unknown day '%s'", spec, fields[1])
+			}
+			fields = append(fields[:1], fields[2:]...)
+		}
+	}
+
+	minutes := 0
+	switch len(fields) {
+	case 1:
+	case 2:
+		var err error
+		minutes, err = parseClock(fields[1])
+		if err != nil {
+			return nil, fmt.Errorf("invalid digest '%s': %s", spec, err)
+		}
+	default:
+		return nil, fmt.Errorf("invalid digest '%s'", spec)
+	}
+
+	return schedule.Parse(fmt.Sprintf("%d %d * * %s", minutes%60, minutes/60, day))
+}
+
+// digestQueue returns the name of the queue upon which the items of the
+// given feed are accumulated, if it has a "digest" option, along with
+// that option.
+//
+// Feeds within a group share the digest of that group.
+func digestQueue(config configfile.Feed) (string, string) {
+
+	spec := ""
+	group := ""
+	for _, opt := range config.Options {
+		switch opt.Name {
+		case "digest":
+			spec = opt.Value
+		case "group":
+			if group == "" {
+				group = opt.Value
+			}
+		}
+	}
+
+	switch {
+	case spec == "":
+		return "", ""
+	case group != "":
+		return "digest-group:" + group, spec
+	default:
+		return "digest:" + config.URL, spec
+	}
+}
+
+// digestEntry returns the feed whose options are used for the digest of
+// the given feeds, which is the first of them.
+//
+// The "digest-template", and "digest-to", options of any of the feeds
+// replace its "template", and "to", options, so that the digest of a group
+// may have its own template, and recipients, without changing those of the
+// emails of its feeds.
+func digestEntry(feeds []configfile.Feed) configfile.Feed {
+
+	entry := feeds[0]
+
+	template := ""
+	var to []string
+	for _, feed := range feeds {
+		var addresses []string
+		for _, opt := range feed.Options {
+			switch opt.Name {
+			case "digest-template":
+				if template == "" {
+					template = opt.Value
+				}
+			case "digest-to":
+				addresses = append(addresses, opt.Value)
+			}
+		}
+		if len(to) == 0 {
+			to = addresses
+		}
+	}
+
+	if template == "" && len(to) == 0 {
+		return entry
+	}
+
+	var opts []configfile.Option
+	for _, opt := range entry.Options {
+		if (opt.Name == "template" && template != "") || (opt.Name == "to" && len(to) > 0) {
+			continue
+		}
+		opts = append(opts, opt)
+	}
+	if template != "" {
+		opts = append(opts, configfile.Option{Name: "template", Value: template})
+	}
+	for _, addr := range to {
+		opts = append(opts, configfile.Option{Name: "to", Value: addr})
+	}
+	entry.Options = opts
+	return entry
+}
+
+// sendDigests sends the digest of each of the given feeds, or their
+// groups, if it is due.
+func (p *Processor) sendDigests(ctx context.Context, entries []configfile.Feed, recipients []string, now time.Time) []error {
+
+	var errors []error
+
+	// Find the feeds which have digests, grouped by their digest.
+	var queues []string
+	members := make(map[string][]configfile.Feed)
+	for _, entry := range entries {
+		queue, _ := digestQueue(entry)
+		if queue == "" {
+			continue
+		}
+		if _, ok := members[queue]; !ok {
+			queues = append(queues, queue)
+		}
+		members[queue] = append(members[queue], entry)
+	}
+
+	for _, queue := range queues {
+
+		// Stop if we've been interrupted.
+		if ctx.Err() != nil {
+			break
+		}
+
+		entry := digestEntry(members[queue])
+		_, spec := digestQueue(entry)
+
+		// Only a single instance may send each digest.
+		locked, err := withstate.Lock(queue, lockTime)
+		if err != nil || !locked {
+			if err != nil {
+				errors = append(errors, fmt.Errorf("error locking digest of %s - %s", entry.Label(), err))
+			}
+			continue
+		}
+		err = p.sendDigest(ctx, queue, entry, spec, recipients, now)
+		if err != nil {
+			errors = append(errors, err)
+		}
+		err = withstate.Unlock(queue)
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+	return errors
+}
+
+// sendDigest sends the digest accumulated upon the given queue, if it is
+// due, using the options of the given entry.
+func (p *Processor) sendDigest(ctx context.Context, queue string, entry configfile.Feed, spec string, recipients []string, now time.Time) error {
+
+	sched, err := ParseDigest(spec)
+	if err != nil {
+		return fmt.Errorf("error processing %s - %s", entry.Label(), err)
+	}
+
+	queued, err := withstate.Queued(queue)
+	if err != nil {
+		return fmt.Errorf("error processing %s - %s", entry.Label(), err)
+	}
+	if len(queued) == 0 {
+		return nil
+	}
+
+	// Have we passed the time of the digest, since we last sent
+	// one, or since the items began accumulating?
+	since := withstate.LastSent(queue)
+	if since.IsZero() {
+		since = queued[0].Queued
+	}
+	if !sched.Due(since, now) {
+		return nil
+	}
+
+	feed := queued[len(queued)-1].Source()
+	if strings.HasPrefix(queue, "digest-group:") {
+		feed = &gofeed.Feed{Title: strings.TrimPrefix(queue, "digest-group:")}
+	}
+
+	item := withstate.FeedItem{Item: digestItem(feed, queued)}
+	p.message(fmt.Sprintf("Sending digest: %s\n", item.Title))
+
+	err = p.sendItem(ctx, entry, feed, item, nil, recipients, item.Content)
+	if err != nil && !isOutputError(err) {
+		return fmt.Errorf("error sending digest of %s - %s", entry.Label(), err)
+	}
+
+	for _, q := range queued {
+		err = q.Remove()
+		if err != nil {
+			return err
+		}
+	}
+	return withstate.RecordSent(queue)
+}
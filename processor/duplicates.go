@@ -0,0 +1,146 @@
+package processor
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+	"unicode"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// similarTitles is the similarity, from 0 to 1, above which two titles are
+// considered to be the same.
+const similarTitles = 0.9
+
+// repostWords are words which are ignored at the start of titles, as they
+// are added to items which are reposted.
+var repostWords = map[string]bool{
+	"corrected":  true,
+	"correction": true,
+	"repost":     true,
+	"update":     true,
+	"updated":    true,
+}
+
+// dedupeWindow returns the time for which the titles of delivered items
+// are remembered, from the "dedupe-titles" option of the given feed, which
+// holds a number of days, or zero if there is none.
+func dedupeWindow(config configfile.Feed) (time.Duration, error) {
+
+	for _, opt := range config.Options {
+		if opt.Name == "dedupe-titles" {
+			days, err := strconv.Atoi(strings.TrimSpace(opt.Value))
+			if err != nil || days < 0 {
+				return 0, fmt.Errorf("invalid dedupe-titles '%s', expected a number of days", opt.Value)
+			}
+			return time.Duration(days) * 24 * time.Hour, nil
+		}
+	}
+	return 0, nil
+}
+
+// CheckDedupe returns an error if the given per-feed option is the
+// "dedupe-titles" option, and its value is invalid.
+func CheckDedupe(opt configfile.Option) error {
+	if opt.Name != "dedupe-titles" {
+		return nil
+	}
+	_, err := dedupeWindow(configfile.Feed{Options: []configfile.Option{opt}})
+	return err
+}
+
+// normaliseTitle returns the given title in lower-case, without punctuation
+// or leading repost markers, such as "Updated:", so that reposted items
+// may be recognised.
+func normaliseTitle(title string) string {
+
+	words := strings.FieldsFunc(strings.ToLower(title), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsNumber(r)
+	})
+	for len(words) > 0 && repostWords[words[0]] {
+		words = words[1:]
+	}
+	return strings.Join(words, " ")
+}
+
+// similarity returns the similarity of the given strings, from 0 to 1, via
+// their edit distance.
+func similarity(a string, b string) float64 {
+
+	ra, rb := []rune(a), []rune(b)
+	longest := len(ra)
+	if len(rb) > longest {
+		longest = len(rb)
+	}
+	if longest == 0 {
+		return 1
+	}
+
+	// The Levenshtein distance, keeping only two rows.
+	prev := make([]int, len(rb)+1)
+	cur := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = prev[j-1] + cost
+			if prev[j]+1 < cur[j] {
+				cur[j] = prev[j] + 1
+			}
+			if cur[j-1]+1 < cur[j] {
+				cur[j] = cur[j-1] + 1
+			}
+		}
+		prev, cur = cur, prev
+	}
+	return 1 - float64(prev[len(rb)])/float64(longest)
+}
+
+// duplicateTitle returns the time at which an item with a title like the
+// given one was delivered for the given feed, within the given window, or
+// the zero time if none was.
+func duplicateTitle(feedURL string, title string, window time.Duration) (time.Time, error) {
+
+	titles, err := withstate.SentTitles(feedURL)
+	if err != nil {
+		return time.Time{}, err
+	}
+
+	title = normaliseTitle(title)
+	if title == "" {
+		return time.Time{}, nil
+	}
+
+	now := time.Now()
+	for _, t := range titles {
+		if now.Sub(t.Sent) <= window && similarity(title, t.Title) >= similarTitles {
+			return t.Sent, nil
+		}
+	}
+	return time.Time{}, nil
+}
+
+// recordTitle records that an item with the given title was delivered for
+// the given feed, so that later items with a similar title are skipped.
+func (p *Processor) recordTitle(feedURL string, title string, window time.Duration) {
+
+	title = normaliseTitle(title)
+	if title == "" {
+		return
+	}
+
+	// Failure isn't fatal, as the item was delivered.
+	err := withstate.RecordTitle(feedURL, title, window)
+	if err != nil {
+		p.message(fmt.Sprintf("\t\tFailed to record title: %s\n", err))
+	}
+}
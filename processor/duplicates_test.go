@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestDedupeWindow ensures the option is parsed.
+func TestDedupeWindow(t *testing.T) {
+
+	window, err := dedupeWindow(configfile.Feed{})
+	if err != nil || window != 0 {
+		t.Fatalf("unexpected default: %v %v", window, err)
+	}
+
+	window, err = dedupeWindow(configfile.Feed{Options: []configfile.Option{{Name: "dedupe-titles", Value: "7"}}})
+	if err != nil || window != 7*24*time.Hour {
+		t.Fatalf("unexpected window: %v %v", window, err)
+	}
+
+	for _, bogus := range []string{"", "7d", "-1", "week"} {
+		if CheckDedupe(configfile.Option{Name: "dedupe-titles", Value: bogus}) == nil {
+			t.Fatalf("expected an error for '%s'", bogus)
+		}
+	}
+}
+
+// TestNormaliseTitle ensures titles are normalised.
+func TestNormaliseTitle(t *testing.T) {
+
+	tests := map[string]string{
+		"Acme Corp Announces Q3 Results":           "acme corp announces q3 results",
+		"UPDATED: Acme Corp announces Q3 results!": "acme corp announces q3 results",
+		"  Acme -- Corp,  announces  Q3 results ":  "acme corp announces q3 results",
+		"Update":        "",
+		"Ünïcode Títle": "ünïcode títle",
+	}
+	for in, out := range tests {
+		if got := normaliseTitle(in); got != out {
+			t.Fatalf("normaliseTitle(%q) = %q, expected %q", in, got, out)
+		}
+	}
+}
+
+// TestSimilarity ensures near-identical titles are found.
+func TestSimilarity(t *testing.T) {
+
+	tests := []struct {
+		a, b    string
+		similar bool
+	}{
+		{"acme corp announces q3 results", "acme corp announces q3 results", true},
+		{"acme corp announces q3 results", "acme corp announce q3 results", true},
+		{"acme corp announces q3 results", "acme corp announces q4 results", true},
+		{"acme corp announces q3 results", "acme corp opens new office", false},
+		{"one", "two", false},
+		{"", "", true},
+	}
+	for _, test := range tests {
+		if got := similarity(test.a, test.b) >= similarTitles; got != test.similar {
+			t.Fatalf("similarity(%q, %q) = %v", test.a, test.b, similarity(test.a, test.b))
+		}
+	}
+}
+
+// TestDuplicateTitle ensures reposts of delivered items are found.
+func TestDuplicateTitle(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	url := "https://example.com/press.rss"
+	p := New()
+	p.recordTitle(url, "Acme Corp Announces Q3 Results", time.Hour)
+
+	sent, err := duplicateTitle(url, "UPDATED: Acme Corp announces Q3 results", time.Hour)
+	if err != nil || sent.IsZero() {
+		t.Fatalf("expected a duplicate: %v %v", sent, err)
+	}
+
+	sent, err = duplicateTitle(url, "Acme Corp opens new office", time.Hour)
+	if err != nil || !sent.IsZero() {
+		t.Fatalf("unexpected duplicate: %v %v", sent, err)
+	}
+
+	// Only titles within the window count.
+	sent, _ = duplicateTitle(url, "Acme Corp Announces Q3 Results", time.Nanosecond)
+	if !sent.IsZero() {
+		t.Fatalf("unexpected duplicate outside of the window")
+	}
+
+	// Nor do those of other feeds.
+	sent, _ = duplicateTitle("https://example.org/press.rss", "Acme Corp Announces Q3 Results", time.Hour)
+	if !sent.IsZero() {
+		t.Fatalf("unexpected duplicate from another feed")
+	}
+}
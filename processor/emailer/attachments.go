@@ -0,0 +1,158 @@
+package emailer
+
+import (
+	"fmt"
+	"html"
+	"mime"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// Attachment is a file, such as the enclosure of an item, which is
+// attached to the email.
+type Attachment struct {
+
+	// URL is the address the file was downloaded from.
+	URL string
+
+	// Name is the name of the file, and ContentType its MIME-type.
+	Name        string
+	ContentType string
+
+	// Data holds the file itself.
+	Data []byte
+}
+
+// templateAttachment is an attachment, as made available to our template.
+type templateAttachment struct {
+
+	// ContentType and Disposition are the values of the headers of
+	// the MIME part.
+	ContentType string
+	Disposition string
+
+	// Data holds the base64-encoded file.
+	Data string
+}
+
+// SetAttachments sets the files which will be attached to the email.
+//
+// If the feed has the "attach" option set, any enclosures of the item
+// which aren't attached are linked from the email instead.
+func (e *Emailer) SetAttachments(files []Attachment) {
+	e.attachments = files
+}
+
+// AttachName returns the filename to use for an enclosure with the given
+// URL.
+func AttachName(link string) string {
+
+	name := link
+	if i := strings.IndexAny(name, "?#"); i >= 0 {
+		name = name[:i]
+	}
+	name = path.Base(name)
+	if n, err := url.PathUnescape(name); err == nil {
+		name = n
+	}
+	name = strings.Map(func(r rune) rune {
+		if r == '/' || r == '\\' || r < ' ' {
+			return '_'
+		}
+		return r
+	}, name)
+	if name == "" || name == "." || name == "_" {
+		return "enclosure"
+	}
+	return name
+}
+
+// templateAttachments returns our attachments, as made available to our
+// template.
+func (e *Emailer) templateAttachments() ([]templateAttachment, error) {
+
+	var res []templateAttachment
+	for _, a := range e.attachments {
+
+		ctype := mime.FormatMediaType(a.ContentType, map[string]string{"name": a.Name})
+		if ctype == "" {
+			ctype = "application/octet-stream"
+		}
+
+		data, err := encode("base64", string(a.Data))
+		if err != nil {
+			return nil, err
+		}
+
+		disposition := mime.FormatMediaType("attachment", map[string]string{"filename": a.Name})
+		if disposition == "" {
+			disposition = "attachment"
+		}
+
+		res = append(res, templateAttachment{
+			ContentType: ctype,
+			Disposition: disposition,
+			Data:        data,
+		})
+	}
+	return res, nil
+}
+
+// enclosureLinks returns the text, and HTML, which link to the enclosures
+// of the item which weren't attached, if the feed attaches enclosures.
+func (e *Emailer) enclosureLinks() (string, string) {
+
+	if e.option("attach") == "" {
+		return "", ""
+	}
+
+	attached := make(map[string]bool)
+	for _, a := range e.attachments {
+		attached[a.URL] = true
+	}
+
+	var text, htm strings.Builder
+	for _, enc := range e.item.Enclosures {
+		if enc == nil || enc.URL == "" || attached[enc.URL] {
+			continue
+		}
+
+		about := enc.Type
+		if size := enclosureSize(enc.Length); size != "" {
+			if about != "" {
+				about += ", "
+			}
+			about += size
+		}
+		if about != "" {
+			about = " (" + about + ")"
+		}
+
+		fmt.Fprintf(&text, "  %s%s\n  %s\n", AttachName(enc.URL), about, enc.URL)
+		fmt.Fprintf(&htm, "<li><a href=\"%s\">%s</a>%s</li>\n", html.EscapeString(enc.URL), html.EscapeString(AttachName(enc.URL)), html.EscapeString(about))
+	}
+
+	if text.Len() == 0 {
+		return "", ""
+	}
+	return "\n\nEnclosures:\n\n" + text.String(), "\n<h3>Enclosures</h3>\n<ul>\n" + htm.String() + "</ul>\n"
+}
+
+// enclosureSize returns the given length of an enclosure, in bytes, in a
+// human-readable form, or the empty string if it is unknown.
+func enclosureSize(length string) string {
+
+	var n float64
+	if _, err := fmt.Sscanf(length, "%g", &n); err != nil || n <= 0 {
+		return ""
+	}
+
+	switch {
+	case n >= 1024*1024:
+		return fmt.Sprintf("%.1fM", n/(1024*1024))
+	case n >= 1024:
+		return fmt.Sprintf("%.0fk", n/1024)
+	}
+	return fmt.Sprintf("%.0f bytes", n)
+}
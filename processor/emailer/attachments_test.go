@@ -0,0 +1,83 @@
+package emailer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// TestAttachName ensures we find sensible filenames.
+func TestAttachName(t *testing.T) {
+
+	for link, expected := range map[string]string{
+		"https://example.com/files/report.pdf":        "report.pdf",
+		"https://example.com/episode%201.mp3?x=1#top": "episode 1.mp3",
+		"https://example.com/a%2Fb.pdf":               "a_b.pdf",
+		"https://example.com/":                        "example.com",
+		"":                                            "enclosure",
+	} {
+		if got := AttachName(link); got != expected {
+			t.Errorf("unexpected name for %s: %s != %s", link, got, expected)
+		}
+	}
+}
+
+// TestAttachments ensures files are attached, and those which weren't
+// are linked.
+func TestAttachments(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	item := withstate.FeedItem{Item: &gofeed.Item{
+		Title: "Episode 1",
+		Link:  "https://example.com/1",
+		Enclosures: []*gofeed.Enclosure{
+			{URL: "https://example.com/notes.pdf", Type: "application/pdf"},
+			{URL: "https://example.com/episode.mp3", Type: "audio/mpeg", Length: "52428800"},
+		},
+	}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{{Name: "attach", Value: "1M"}, {Name: "encoding", Value: "8bit"}})
+	e.SetAttachments([]Attachment{{URL: "https://example.com/notes.pdf", Name: "notes.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4\n")}})
+
+	msg, err := e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.TemplateError != nil {
+		t.Fatalf("unexpected template error: %s", msg.TemplateError)
+	}
+
+	content := string(msg.Content)
+	for _, expected := range []string{
+		"Content-Type: application/pdf; name=notes.pdf",
+		"Content-Disposition: attachment; filename=notes.pdf",
+		"JVBERi0xLjQK",
+		`<a href="https://example.com/episode.mp3">episode.mp3</a> (audio/mpeg, 50.0M)`,
+		"  episode.mp3 (audio/mpeg, 50.0M)\n  https://example.com/episode.mp3",
+	} {
+		if !strings.Contains(content, expected) {
+			t.Fatalf("expected %q in:\n%s", expected, content)
+		}
+	}
+	if strings.Contains(content, `<a href="https://example.com/notes.pdf">`) {
+		t.Fatalf("attached enclosure was linked")
+	}
+
+	// Without the option enclosures aren't linked.
+	e = New(&gofeed.Feed{}, item, []configfile.Option{})
+	msg, err = e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if strings.Contains(string(msg.Content), "Enclosures") {
+		t.Fatalf("unexpected enclosures")
+	}
+}
@@ -0,0 +1,190 @@
+package emailer
+
+import (
+	"bytes"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net/mail"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/favicon"
+	"github.com/skx/rss2email/withstate"
+)
+
+// The details of the sample item used to check templates, which we look
+// for within the email generated.
+const (
+	sampleTitle   = "A sample item to check the template"
+	sampleLink    = "https://example.com/2021/sample-item"
+	sampleContent = "The content of the sample item"
+)
+
+// CheckTemplate renders the given template against a sample item, and
+// verifies the email it generates, returning any problems found.
+//
+// This catches templates which fail to parse or execute, reference
+// fields which don't exist, or produce an email whose headers or MIME
+// structure are broken, or which omits the item.
+func CheckTemplate(content []byte, opts []configfile.Option) []error {
+
+	feed := &gofeed.Feed{Title: "Example Feed", Link: "https://example.com/", FeedLink: "https://example.com/index.rss"}
+	item := withstate.FeedItem{Item: &gofeed.Item{
+		Title:   sampleTitle,
+		Link:    sampleLink,
+		GUID:    sampleLink,
+		Content: "<p>" + sampleContent + ".</p>",
+	}}
+
+	e := New(feed, item, opts)
+	e.SetTemplate(content)
+	e.SetFavicon(&favicon.Icon{ContentType: "image/png", Data: []byte("\x89PNG\r\n\x1a\n")})
+	e.SetAttachments([]Attachment{{URL: "https://example.com/sample.pdf", Name: "sample.pdf", ContentType: "application/pdf", Data: []byte("%PDF-1.4\n")}})
+
+	msg, err := e.Render([]string{"user@example.com"}, sampleContent+".", item.Content)
+	if err != nil {
+		return []error{err}
+	}
+	return checkMessage(msg.Content)
+}
+
+// checkMessage verifies the structure of the given email, which was
+// generated for our sample item.
+func checkMessage(content []byte) []error {
+
+	var errs []error
+
+	// Lines may not be longer than 998 characters.
+	for i, line := range strings.Split(string(content), "\n") {
+		if len(strings.TrimSuffix(line, "\r")) > 998 {
+			errs = append(errs, fmt.Errorf("line %d is longer than 998 characters", i+1))
+		}
+	}
+
+	msg, err := mail.ReadMessage(bytes.NewReader(content))
+	if err != nil {
+		return append(errs, fmt.Errorf("the headers of the email are malformed: %s", err))
+	}
+
+	for _, name := range []string{"From", "To", "Subject"} {
+		if msg.Header.Get(name) == "" {
+			errs = append(errs, fmt.Errorf("the email has no %s: header", name))
+		}
+	}
+	for _, name := range []string{"From", "To"} {
+		if val := msg.Header.Get(name); val != "" {
+			if _, err = mail.ParseAddressList(val); err != nil {
+				errs = append(errs, fmt.Errorf("the %s: header is invalid: %s", name, err))
+			}
+		}
+	}
+
+	dec := new(mime.WordDecoder)
+	subject, err := dec.DecodeHeader(msg.Header.Get("Subject"))
+	if err == nil && msg.Header.Get("Subject") != "" && !strings.Contains(subject, sampleTitle) {
+		errs = append(errs, fmt.Errorf("the subject doesn't contain the title of the item: %q", subject))
+	}
+
+	ctype := msg.Header.Get("Content-Type")
+	if strings.HasPrefix(strings.ToLower(ctype), "multipart/") && msg.Header.Get("Mime-Version") == "" {
+		errs = append(errs, fmt.Errorf("the email is multipart, but has no MIME-Version: header"))
+	}
+
+	var bodies []string
+	err = checkPart(msg.Header, msg.Body, &bodies, 0)
+	if err != nil {
+		return append(errs, err)
+	}
+
+	all := strings.Join(bodies, "\n")
+	if !strings.Contains(all, sampleLink) {
+		errs = append(errs, fmt.Errorf("the link to the item doesn't appear within the body of the email"))
+	}
+	if !strings.Contains(all, sampleContent) {
+		errs = append(errs, fmt.Errorf("the content of the item doesn't appear within the body of the email"))
+	}
+	return errs
+}
+
+// header is the subset of the headers of a message, or MIME part, which
+// we examine.
+type header interface {
+	Get(key string) string
+}
+
+// checkPart verifies the given part, and its children, of an email,
+// collecting the decoded content of each text part.
+func checkPart(h header, body io.Reader, bodies *[]string, depth int) error {
+
+	if depth > 10 {
+		return fmt.Errorf("MIME parts are nested too deeply")
+	}
+
+	ctype := h.Get("Content-Type")
+	if ctype == "" {
+		ctype = "text/plain"
+	}
+	mediaType, params, err := mime.ParseMediaType(ctype)
+	if err != nil {
+		return fmt.Errorf("invalid Content-Type %q: %s", ctype, err)
+	}
+
+	if strings.HasPrefix(mediaType, "multipart/") {
+
+		boundary := params["boundary"]
+		if boundary == "" {
+			return fmt.Errorf("the %s part has no boundary", mediaType)
+		}
+
+		mr := multipart.NewReader(body, boundary)
+		count := 0
+		for {
+			part, err := mr.NextPart()
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				return fmt.Errorf("the %s part with boundary %q is broken: %s", mediaType, boundary, err)
+			}
+			count++
+
+			err = checkPart(part.Header, part, bodies, depth+1)
+			if err != nil {
+				return err
+			}
+		}
+		if count == 0 {
+			return fmt.Errorf("the %s part with boundary %q has no parts", mediaType, boundary)
+		}
+		return nil
+	}
+
+	var rd io.Reader = body
+	switch enc := strings.ToLower(strings.TrimSpace(h.Get("Content-Transfer-Encoding"))); enc {
+	case "", "7bit", "8bit", "binary":
+	case "quoted-printable":
+		rd = quotedprintable.NewReader(body)
+	case "base64":
+		rd = base64.NewDecoder(base64.StdEncoding, body)
+	default:
+		return fmt.Errorf("the %s part has an unknown Content-Transfer-Encoding %q", mediaType, enc)
+	}
+
+	data, err := io.ReadAll(rd)
+	if err == io.ErrUnexpectedEOF {
+		return fmt.Errorf("the %s part is truncated, is a closing boundary missing?", mediaType)
+	}
+	if err != nil {
+		return fmt.Errorf("the %s part is not correctly encoded: %s", mediaType, err)
+	}
+
+	if strings.HasPrefix(mediaType, "text/") {
+		*bodies = append(*bodies, string(data))
+	}
+	return nil
+}
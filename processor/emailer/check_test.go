@@ -0,0 +1,60 @@
+package emailer
+
+import (
+	"strings"
+	"testing"
+
+	emailtemplate "github.com/skx/rss2email/template"
+)
+
+func TestCheckTemplate(t *testing.T) {
+
+	// Our embedded templates are fine.
+	for _, content := range [][]byte{emailtemplate.EmailTemplate(), emailtemplate.StyledTemplate()} {
+		errs := CheckTemplate(content, nil)
+		if len(errs) != 0 {
+			t.Fatalf("unexpected problems with embedded template: %v", errs)
+		}
+	}
+
+	// A minimal template, without MIME parts.
+	plain := "From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\n\n{{.Link}}\n{{.RawText}}\n"
+	if errs := CheckTemplate([]byte(plain), nil); len(errs) != 0 {
+		t.Fatalf("unexpected problems with plain template: %v", errs)
+	}
+
+	type TestCase struct {
+		template string
+		problem  string
+	}
+
+	tests := []TestCase{
+		{"{{if}}", "failed to parse template"},
+		{"Subject: {{.Nope}}\n", "can't evaluate field Nope"},
+		{"From: {{.From}}\nTo: {{.To}}\n\n{{.Link}} {{.RawText}}\n", "no Subject: header"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: hello\n\n{{.Link}} {{.RawText}}\n", "subject doesn't contain the title"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\n\n{{.RawText}}\n", "link to the item doesn't appear"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\n\n{{.Link}}\n", "content of the item doesn't appear"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\nMime-Version: 1.0\nContent-Type: multipart/alternative\n\n{{.Link}} {{.RawText}}\n", "has no boundary"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\nMime-Version: 1.0\nContent-Type: multipart/alternative; boundary=xx\n\n--xx\nContent-Type: text/plain\n\n{{.Link}} {{.RawText}}\n", "closing boundary missing"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\nMime-Version: 1.0\nContent-Type: multipart/alternative; boundary=xx\n\n{{.Link}} {{.RawText}}\n", "is broken"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\nContent-Type: multipart/alternative; boundary=xx\n\n--xx\n\n{{.Link}} {{.RawText}}\n--xx--\n", "no MIME-Version"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\nContent-Transfer-Encoding: base64\n\n{{.Link}} {{.RawText}}\n", "not correctly encoded"},
+		{"From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\n\n{{.Link}} {{.RawText}} " + strings.Repeat("x", 1000) + "\n", "longer than 998"},
+	}
+
+	for _, tst := range tests {
+
+		errs := CheckTemplate([]byte(tst.template), nil)
+
+		found := false
+		for _, err := range errs {
+			if strings.Contains(err.Error(), tst.problem) {
+				found = true
+			}
+		}
+		if !found {
+			t.Errorf("expected problem %q with %q, got %v", tst.problem, tst.template, errs)
+		}
+	}
+}
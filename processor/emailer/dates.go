@@ -0,0 +1,73 @@
+package emailer
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// DefaultDateFormat is the layout of the dates made available to our
+// templates, unless another is configured.
+const DefaultDateFormat = "Mon, 02 Jan 2006 15:04 MST"
+
+// SetTimezone sets the name of the time zone in which the dates made
+// available to our templates are shown, such as "Europe/Madrid".  This may
+// be overridden by the per-feed "timezone" option.
+//
+// The empty string means the local time zone.
+func (e *Emailer) SetTimezone(name string) {
+	e.timezone = name
+}
+
+// SetDateFormat sets the layout of the dates made available to our
+// templates, as used by the time package.  This may be overridden by the
+// per-feed "date-format" option.
+func (e *Emailer) SetDateFormat(layout string) {
+	e.dateFormat = layout
+}
+
+// Location returns the time zone of the feed with the given options, set
+// via its "timezone" option, or the named default.
+func Location(opts []configfile.Option, def string) (*time.Location, error) {
+
+	name := def
+	for _, opt := range opts {
+		if opt.Name == "timezone" {
+			name = opt.Value
+		}
+	}
+
+	loc, err := time.LoadLocation(name)
+	if err != nil {
+		return time.Local, fmt.Errorf("invalid time zone '%s'", name)
+	}
+	return loc, nil
+}
+
+// dates returns the dates upon which the item was published, and updated,
+// formatted for our templates.  Either is empty if it is unknown.
+//
+// If the time zone is invalid the local time zone is used, and the error
+// returned.
+func (e *Emailer) dates() (string, string, error) {
+
+	loc, err := Location(e.opts, e.timezone)
+
+	layout := e.dateFormat
+	if val := e.option("date-format"); val != "" {
+		layout = val
+	}
+	if layout == "" {
+		layout = DefaultDateFormat
+	}
+
+	format := func(t *time.Time) string {
+		if t == nil || t.IsZero() {
+			return ""
+		}
+		return t.In(loc).Format(layout)
+	}
+
+	return format(e.item.PublishedParsed), format(e.item.UpdatedParsed), err
+}
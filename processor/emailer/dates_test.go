@@ -0,0 +1,68 @@
+package emailer
+
+import (
+	"os"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// TestDates ensures dates are shown in the configured zone, and layout.
+func TestDates(t *testing.T) {
+
+	published := time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC)
+	item := withstate.FeedItem{Item: &gofeed.Item{PublishedParsed: &published}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	e.SetTimezone("UTC")
+	pub, upd, err := e.dates()
+	if err != nil || pub != "Thu, 14 Mar 2024 18:30 UTC" || upd != "" {
+		t.Fatalf("unexpected dates: %q %q %v", pub, upd, err)
+	}
+
+	// The global settings may be overridden per-feed.
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "timezone", Value: "Asia/Tokyo"},
+		{Name: "date-format", Value: "2006-01-02 15:04"},
+	})
+	e.SetTimezone("UTC")
+	e.SetDateFormat("Jan 2")
+	pub, _, err = e.dates()
+	if err != nil || pub != "2024-03-15 03:30" {
+		t.Fatalf("unexpected date: %q %v", pub, err)
+	}
+
+	// Invalid zones are reported.
+	e = New(&gofeed.Feed{}, item, []configfile.Option{{Name: "timezone", Value: "Mars/Olympus"}})
+	pub, _, err = e.dates()
+	if err == nil || pub == "" {
+		t.Fatalf("expected an error, and a date: %q %v", pub, err)
+	}
+}
+
+// TestDatesTemplate ensures the dates are available to templates.
+func TestDatesTemplate(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	published := time.Date(2024, 3, 14, 18, 30, 0, 0, time.UTC)
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Post", PublishedParsed: &published}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{{Name: "timezone", Value: "UTC"}})
+	e.SetTemplate([]byte("Subject: {{.Subject}}\n\nPublished {{.Published}}\n"))
+
+	msg, err := e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(string(msg.Content), "Published Thu, 14 Mar 2024 18:30 UTC") {
+		t.Fatalf("unexpected content: %s", msg.Content)
+	}
+}
@@ -13,21 +13,33 @@ package emailer
 
 import (
 	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/base64"
 	"errors"
 	"fmt"
 	"html"
-	"io/ioutil"
+	"io"
+	"mime"
 	"mime/quotedprintable"
 	"net/smtp"
 	"os"
 	"os/exec"
-	"os/user"
 	"path/filepath"
+	"runtime"
 	"strconv"
+	"strings"
 	"text/template"
+	"time"
+	"unicode/utf8"
 
+	"github.com/PuerkitoBio/goquery"
 	"github.com/mmcdole/gofeed"
 	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/favicon"
+	"github.com/skx/rss2email/logger"
+	"github.com/skx/rss2email/oauth"
+	"github.com/skx/rss2email/secret"
 	emailtemplate "github.com/skx/rss2email/template"
 	"github.com/skx/rss2email/withstate"
 )
@@ -41,6 +53,77 @@ type Emailer struct {
 	item withstate.FeedItem
 	// Config options for the feed.
 	opts []configfile.Option
+
+	// from is the address used in the From: header of the email.
+	from string
+
+	// envelopeFrom is the address given to the MTA as the envelope
+	// sender, which is where bounces will be delivered.
+	envelopeFrom string
+
+	// cc holds the default addresses to which a copy is sent.
+	cc []string
+
+	// bcc holds the default addresses to which a blind copy is sent.
+	bcc []string
+
+	// maxSize holds the default maximum size of the message body,
+	// in bytes.  Zero means there is no limit.
+	maxSize int
+
+	// style holds the name of the default embedded template.
+	style string
+
+	// icon holds the icon of the feed, if any.
+	icon *favicon.Icon
+
+	// archive holds the address of a snapshot of the item, if any.
+	archive string
+
+	// attachments holds the files attached to the email.
+	attachments []Attachment
+
+	// timezone and dateFormat hold the default time zone, and layout,
+	// of the dates made available to our template.
+	timezone   string
+	dateFormat string
+
+	// template holds the content of the template to use, if it has
+	// been set explicitly, rather than being found upon disk.
+	template []byte
+
+	// logger receives our messages.
+	logger logger.Logger
+
+	// recorder records our emails, rather than them being sent, if
+	// it is set.
+	recorder *Recorder
+}
+
+// Message is a rendered email, along with the envelope details needed
+// to deliver it.
+type Message struct {
+
+	// Sender is the envelope sender.
+	Sender string
+
+	// Recipients holds the envelope recipients, which includes any
+	// blind-copy addresses.
+	Recipients []string
+
+	// Content is the complete message, including headers.
+	Content []byte
+
+	// Backend is the means by which the message was delivered, "smtp",
+	// "sendmail", or "record", and Response holds the final reply of
+	// the SMTP server, the output of sendmail, or the name of the
+	// recorded file.  These are set by Send.
+	Backend  string
+	Response string
+
+	// TemplateError is set if the custom template failed, in which
+	// case the message was rendered with the built-in template.
+	TemplateError error
 }
 
 // New creates a new Emailer object.
@@ -51,54 +134,237 @@ func New(feed *gofeed.Feed, item withstate.FeedItem, opts []configfile.Option) *
 	return &Emailer{feed: feed, item: item, opts: opts}
 }
 
+// SetLogger sets the logger which receives our messages, which are
+// discarded by default.
+func (e *Emailer) SetLogger(l logger.Logger) {
+	e.logger = l
+}
+
+// log returns the logger which receives our messages.
+func (e *Emailer) log() logger.Logger {
+	if e.logger == nil {
+		return logger.Discard
+	}
+	return e.logger
+}
+
+// SetFrom sets the default address used in the From: header, this may be
+// overridden by the per-feed "from" option.
+func (e *Emailer) SetFrom(from string) {
+	e.from = from
+}
+
+// SetEnvelopeFrom sets the default envelope sender, this may be overridden
+// by the per-feed "envelope-from" option.
+func (e *Emailer) SetEnvelopeFrom(from string) {
+	e.envelopeFrom = from
+}
+
+// SetCC sets the default addresses which receive a copy of each email,
+// these may be replaced by the per-feed "cc" option.
+func (e *Emailer) SetCC(addresses []string) {
+	e.cc = addresses
+}
+
+// SetBCC sets the default addresses which receive a blind copy of each
+// email, these may be replaced by the per-feed "bcc" option.
+func (e *Emailer) SetBCC(addresses []string) {
+	e.bcc = addresses
+}
+
+// SetMaxSize sets the default maximum size of the text and HTML bodies
+// of an email, in bytes, this may be overridden by the per-feed "max-size"
+// option.  Zero means there is no limit.
+func (e *Emailer) SetMaxSize(size int) {
+	e.maxSize = size
+}
+
+// SetStyle sets the name of the embedded template to use by default,
+// which may be "plain" or "styled".  This may be overridden by the
+// per-feed "style" option.
+//
+// Any template present on-disk will be used in preference.
+func (e *Emailer) SetStyle(style string) {
+	e.style = style
+}
+
+// SetFavicon sets the icon of the feed, which will be embedded within the
+// generated email.
+func (e *Emailer) SetFavicon(icon *favicon.Icon) {
+	e.icon = icon
+}
+
+// SetArchive sets the address of a snapshot of the item, such as that
+// taken by the Wayback Machine, which is linked from the email.
+func (e *Emailer) SetArchive(link string) {
+	e.archive = link
+}
+
+// SetTemplate sets the content of the template to use, rather than the
+// embedded template, or that found upon disk.
+func (e *Emailer) SetTemplate(content []byte) {
+	e.template = content
+}
+
+// option returns the value of the last per-feed option with the given
+// name, or the empty string if it was not set.
+func (e *Emailer) option(name string) string {
+	val := ""
+	for _, opt := range e.opts {
+		if opt.Name == name {
+			val = opt.Value
+		}
+	}
+	return val
+}
+
+// unescapeHTML returns true if the per-feed "unescape-html" option has
+// been set, to request that the HTML content of items be unescaped.
+func (e *Emailer) unescapeHTML() bool {
+	val, err := strconv.ParseBool(e.option("unescape-html"))
+	return err == nil && val
+}
+
+// sender returns the address to use in the From: header of an email
+// sent to the given recipient.
+//
+// If nothing has been configured we use the recipient address, which
+// was the historical behaviour.  As a single email is sent to all the
+// recipients this is the first of them.
+func (e *Emailer) sender(recipient string) string {
+	if from := e.option("from"); from != "" {
+		return from
+	}
+	if e.from != "" {
+		return e.from
+	}
+	return recipient
+}
+
+// envelopeSender returns the envelope sender to use when delivering
+// an email to the given recipient.
+//
+// An explicit envelope-from setting is preferred, otherwise we fall
+// back to the header From: address.
+func (e *Emailer) envelopeSender(recipient string) string {
+	if from := e.option("envelope-from"); from != "" {
+		return from
+	}
+	if e.envelopeFrom != "" {
+		return e.envelopeFrom
+	}
+	return e.sender(recipient)
+}
+
 // loadTemplate loads the template used for sending the email notification.
 func (e *Emailer) loadTemplate() (*template.Template, error) {
 
-	// Load the default template from the embedded resource.
-	content := emailtemplate.EmailTemplate()
+	// Use the template we've been given, if any.
+	if e.template != nil {
+		return e.parseTemplate(e.template)
+	}
 
 	//
 	// Is there an on-disk template instead?  If so use it.
 	//
-	home := os.Getenv("HOME")
-
-	// If that fails then get the current user, and use
-	// their home if possible.
-	if home == "" {
-		usr, errr := user.Current()
-		if errr == nil {
-			home = usr.HomeDir
-		}
-	}
-
-	// The path to the overridden template
-	override := filepath.Join(home, ".rss2email", "email.tmpl")
+	// A template given to the feed must exist, while the global
+	// template is optional.
+	//
+	name := e.option("template")
+	override := TemplatePath(name)
 
-	// If a per feed template was set, get it here.
-	for _, opt := range e.opts {
-		if opt.Name == "template" {
-			override = filepath.Join(home, ".rss2email", opt.Value)
+	_, err := os.Stat(override)
+	if os.IsNotExist(err) {
+		if name != "" {
+			return nil, fmt.Errorf("template %s does not exist", override)
 		}
+		return e.builtinTemplate()
 	}
 
-	// If the file exists, use it.
-	_, err := os.Stat(override)
-	if !os.IsNotExist(err) {
-		content, err = ioutil.ReadFile(override)
+	return e.cachedTemplate(override, func() ([]byte, error) {
+		content, err := os.ReadFile(override)
 		if err != nil {
 			return nil, fmt.Errorf("failed to read %s: %s", override, err.Error())
 		}
+		return content, nil
+	})
+}
+
+// builtinTemplate loads the embedded template, of the style chosen for
+// the feed.
+func (e *Emailer) builtinTemplate() (*template.Template, error) {
+
+	// Load the default template from the embedded resource.
+	content := emailtemplate.EmailTemplate()
+
+	// Unless a different embedded style was chosen.
+	style := e.style
+	if val := e.option("style"); val != "" {
+		style = val
 	}
+	switch style {
+	case "", "plain":
+		style = "plain"
+	case "styled":
+		content = emailtemplate.StyledTemplate()
+	default:
+		return nil, fmt.Errorf("unknown template style '%s'", style)
+	}
+
+	return e.cachedTemplate("embedded:"+style, func() ([]byte, error) {
+		return content, nil
+	})
+}
+
+// TemplatePath returns the location of the on-disk template with the
+// given name, as used by the per-feed "template" option, or that of the
+// global template if the name is empty.
+//
+// Relative paths are relative to our configuration directory, and may
+// use "/" as a separator on all platforms.
+func TemplatePath(name string) string {
+
+	dir := configfile.New().Directory()
+	if name == "" {
+		return filepath.Join(dir, "email.tmpl")
+	}
+
+	path := filepath.FromSlash(name)
+	if !filepath.IsAbs(path) {
+		path = filepath.Join(dir, path)
+	}
+	return path
+}
+
+// parseTemplate parses the given template, making our functions
+// available to it.
+func (e *Emailer) parseTemplate(content []byte) (*template.Template, error) {
 
 	//
 	// Function map allows exporting functions to the template
 	//
+	// The "encodepart" function needs access to the template it is
+	// part of, so that it can render a named sub-template.
+	var tmpl *template.Template
+
 	funcMap := template.FuncMap{
 		"quoteprintable": e.toQuotedPrintable,
+		"encode":         encode,
+		"encodepart": func(encoding string, name string, data interface{}) (string, error) {
+			buf := &bytes.Buffer{}
+			err := tmpl.ExecuteTemplate(buf, name, data)
+			if err != nil {
+				return "", err
+			}
+			return encode(encoding, buf.String())
+		},
 	}
 
-	tmpl := template.Must(template.New("email.tmpl").Funcs(funcMap).Parse(string(content)))
-
+	var err error
+	tmpl, err = template.New("email.tmpl").Funcs(funcMap).Parse(string(content))
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %s", err)
+	}
 	return tmpl, nil
 }
 
@@ -109,119 +375,571 @@ func (e *Emailer) loadTemplate() (*template.Template, error) {
 // NOTE: We use this function both directly, and from within our
 // template.
 func (e *Emailer) toQuotedPrintable(s string) (string, error) {
-	var ac bytes.Buffer
-	w := quotedprintable.NewWriter(&ac)
-	_, err := w.Write([]byte(s))
-	if err != nil {
-		return "", err
+	return encode("quoted-printable", s)
+}
+
+// encode converts the given string to the named Content-Transfer-Encoding,
+// which must be one of "quoted-printable", "base64", or "8bit".
+func encode(encoding string, s string) (string, error) {
+
+	switch encoding {
+	case "quoted-printable":
+		var ac bytes.Buffer
+		w := quotedprintable.NewWriter(&ac)
+		_, err := w.Write([]byte(s))
+		if err != nil {
+			return "", err
+		}
+		err = w.Close()
+		if err != nil {
+			return "", err
+		}
+		return ac.String(), nil
+
+	case "base64":
+		// Wrap the output at 76 characters, as RFC 2045 requires.
+		enc := base64.StdEncoding.EncodeToString([]byte(s))
+		var out strings.Builder
+		for len(enc) > 76 {
+			out.WriteString(enc[:76] + "\n")
+			enc = enc[76:]
+		}
+		out.WriteString(enc)
+		return out.String(), nil
+
+	case "8bit":
+		return s, nil
 	}
-	err = w.Close()
-	if err != nil {
-		return "", err
+
+	return "", fmt.Errorf("unknown content-transfer-encoding '%s'", encoding)
+}
+
+// encodings returns the Content-Transfer-Encoding to use for the text and
+// HTML parts of our message.
+//
+// The "encoding" option applies to both parts, but may be overridden by the
+// "text-encoding" and "html-encoding" options.  The default is to use
+// quoted-printable.
+func (e *Emailer) encodings() (string, string, error) {
+
+	text := "quoted-printable"
+	html := "quoted-printable"
+
+	if val := e.option("encoding"); val != "" {
+		text = val
+		html = val
+	}
+	if val := e.option("text-encoding"); val != "" {
+		text = val
+	}
+	if val := e.option("html-encoding"); val != "" {
+		html = val
+	}
+
+	text = normalizeEncoding(text)
+	html = normalizeEncoding(html)
+
+	// Test the encodings are valid.
+	for _, enc := range []string{text, html} {
+		if _, err := encode(enc, ""); err != nil {
+			return "", "", err
+		}
+	}
+
+	return text, html, nil
+}
+
+// normalizeEncoding converts the user-supplied name of an encoding to
+// the form used in the Content-Transfer-Encoding header.
+func normalizeEncoding(name string) string {
+	name = strings.ToLower(strings.TrimSpace(name))
+	if name == "qp" {
+		name = "quoted-printable"
 	}
-	return ac.String(), nil
+	return name
 }
 
-// Sendmail is a simple function that emails the given address.
+// Sendmail is a simple function that emails the given addresses.
 //
 // We send a MIME message with both a plain-text and a HTML-version of the
 // message.  This should be nicer for users.
+//
+// A single message is generated which is addressed to all the recipients,
+// along with any CC and BCC addresses which have been configured.
 func (e *Emailer) Sendmail(addresses []string, textstr string, htmlstr string) error {
+
+	msg, err := e.Render(addresses, textstr, htmlstr)
+	if err != nil {
+		return err
+	}
+	return e.Send(msg)
+}
+
+// Notify sends a plain-text email which isn't associated with any feed
+// item, such as the summary of a run.
+//
+// If the sender is empty the first recipient is used.
+func Notify(from string, to []string, subject string, body string) error {
+	return (&Emailer{}).Notify(from, to, subject, body)
+}
+
+// Notify sends a plain-text email which isn't associated with any feed
+// item, as the Notify function does, but via our recorder if one is set.
+func (e *Emailer) Notify(from string, to []string, subject string, body string) error {
+
+	if len(to) < 1 {
+		return errors.New("empty recipient address, did you not setup a recipient?")
+	}
+	if from == "" {
+		from = to[0]
+	}
+
+	encoded, err := encode("quoted-printable", body)
+	if err != nil {
+		return err
+	}
+
+	content := fmt.Sprintf(`From: %s
+To: %s
+Subject: %s
+Date: %s
+X-RSS-Generator: rss2email
+MIME-Version: 1.0
+Content-Type: text/plain; charset=UTF-8
+Content-Transfer-Encoding: quoted-printable
+
+%s
+`, from, strings.Join(to, ", "), mime.QEncoding.Encode("utf-8", subject), time.Now().Format(time.RFC1123Z), encoded)
+
+	return e.Send(&Message{Sender: from, Recipients: to, Content: []byte(content)})
+}
+
+// Render generates the email for the given recipients, text and HTML,
+// without sending it.
+//
+// A single email is generated, with each of the recipients in its To:
+// header, rather than one for each recipient.
+func (e *Emailer) Render(addresses []string, textstr string, htmlstr string) (*Message, error) {
 	var err error
 
+	//
+	// Work out who we're sending to.
+	//
+	to, cc, bcc := e.recipients(addresses)
+
 	//
 	// Ensure we have a recipient.
 	//
-	if len(addresses) < 1 {
+	if len(to) < 1 {
 		e := errors.New("empty recipient address, did you not setup a recipient?")
-		return e
+		return nil, e
 	}
 
 	//
-	// Process each address
+	// Find the groups captured by the include options, against the
+	// content the item was filtered upon.
 	//
-	for _, addr := range addresses {
+	captures := e.captures(htmlstr)
 
-		//
-		// Here is a temporary structure we'll use to popular our email
-		// template.
-		//
-		type TemplateParms struct {
-			Feed      string
-			FeedTitle string
-			To        string
-			From      string
-			Text      string
-			HTML      string
-			Subject   string
-			Link      string
+	//
+	// Some feeds double-escape their content, so allow them to
+	// opt into having it unescaped.
+	//
+	// This isn't the default as it would turn escaped code samples,
+	// such as "&lt;script&gt;", into live HTML.
+	//
+	if e.unescapeHTML() {
+		htmlstr = html.UnescapeString(htmlstr)
+	}
 
-			// In case people need access to fields
-			// we've not wrapped/exported explicitly
-			RSSFeed *gofeed.Feed
-			RSSItem withstate.FeedItem
-		}
+	//
+	// Highlight any keywords, so that they stand out.
+	//
+	if re := e.highlighter(); re != nil {
+		textstr = highlightText(re, textstr)
+		htmlstr = highlightHTML(re, htmlstr)
+	}
+
+	//
+	// Proxy, or remove, remote images, if we should.
+	//
+	if rewrite := e.imageRewriter(); rewrite != nil {
+		htmlstr = rewriteImages(htmlstr, rewrite)
+	}
+
+	//
+	// Replace emoji in the text part, if we should.
+	//
+	if e.emojiText() {
+		textstr = replaceEmoji(textstr)
+	}
+
+	//
+	// Ensure the body isn't too large.
+	//
+	textstr, htmlstr = e.limitSize(textstr, htmlstr)
+
+	//
+	// Link to any enclosures which weren't attached.
+	//
+	linkText, linkHTML := e.enclosureLinks()
+	textstr += linkText
+	htmlstr += linkHTML
+
+	//
+	// Here is a temporary structure we'll use to popular our email
+	// template.
+	//
+	type TemplateParms struct {
+		Feed      string
+		FeedTitle string
+		Name      string
+		To        string
+		Cc        string
+		From      string
+		Text      string
+		HTML      string
+		Subject   string
+		Link      string
+		Archive   string
+
+		// The dates upon which the item was published, and
+		// updated, if known, in the time zone, and layout, of
+		// the feed.
+		Published string
+		Updated   string
+
+		// The groups captured by the include, or include-title,
+		// option which matched the item, by number and name.
+		Captures map[string]string
+
+		// The unencoded text and HTML bodies, along with the
+		// Content-Transfer-Encoding to use for each part.
+		RawText      string
+		RawHTML      string
+		TextEncoding string
+		HTMLEncoding string
+
+		// The base64-encoded icon of the feed, if any, and
+		// its MIME-type.
+		Favicon     string
+		FaviconType string
+
+		// The files attached to the email, if any.
+		Attachments []templateAttachment
+
+		// In case people need access to fields
+		// we've not wrapped/exported explicitly
+		RSSFeed *gofeed.Feed
+		RSSItem withstate.FeedItem
+	}
+
+	//
+	// Populate it appropriately.
+	//
+	// Note that BCC addresses are deliberately not available.
+	//
+	var x TemplateParms
+	x.Feed = e.feed.Link
+	x.FeedTitle = e.feed.Title
+	x.Name = e.option("name")
+	x.From = e.sender(to[0])
+	x.Link = e.item.Link
+	x.Archive = e.archive
+	x.Subject = e.item.Title
+	x.To = strings.Join(to, ", ")
+	x.Cc = strings.Join(cc, ", ")
+	x.RSSFeed = e.feed
+	x.RSSItem = e.item
+	x.Captures = captures
+
+	// The subject may be set via the "subject" option, which is a
+	// template.  If it fails the title of the item is used, and the
+	// failure reported, as with the template of the email.
+	var terr error
+	x.Subject, terr = e.subject(x)
+	if terr != nil {
+		terr = fmt.Errorf("subject: %s", terr)
+	}
+	x.Subject = e.affix(x.Subject)
+
+	// Likewise an invalid time zone is reported, with dates shown
+	// in the local time zone.
+	var derr error
+	x.Published, x.Updated, derr = e.dates()
+	if derr != nil && terr == nil {
+		terr = derr
+	}
+
+	// The raw parts are encoded by the template, as it
+	// creates the MIME parts.
+	x.RawText = textstr
+	x.RawHTML = htmlstr
+	x.TextEncoding, x.HTMLEncoding, err = e.encodings()
+	if err != nil {
+		return nil, err
+	}
 
-		//
-		// Populate it appropriately.
-		//
-		var x TemplateParms
-		x.Feed = e.feed.Link
-		x.FeedTitle = e.feed.Title
-		x.From = addr
-		x.Link = e.item.Link
-		x.Subject = e.item.Title
-		x.To = addr
-		x.RSSFeed = e.feed
-		x.RSSItem = e.item
-
-		// The real meat of the mail is the text & HTML
-		// parts.  They need to be encoded, unconditionally.
-		x.Text, err = e.toQuotedPrintable(textstr)
+	// The icon, if present, is always base64-encoded.
+	if e.icon != nil {
+		x.FaviconType = e.icon.ContentType
+		x.Favicon, err = encode("base64", string(e.icon.Data))
 		if err != nil {
-			return err
+			return nil, err
 		}
-		x.HTML, err = e.toQuotedPrintable(html.UnescapeString(htmlstr))
+	}
+
+	x.Attachments, err = e.templateAttachments()
+	if err != nil {
+		return nil, err
+	}
+
+	// For compatibility with older templates we also make
+	// quoted-printable versions of the parts available.
+	x.Text, err = e.toQuotedPrintable(textstr)
+	if err != nil {
+		return nil, err
+	}
+	x.HTML, err = e.toQuotedPrintable(htmlstr)
+	if err != nil {
+		return nil, err
+	}
+
+	//
+	// Load the template we're going to render, and render it.
+	//
+	var t *template.Template
+	var content []byte
+	t, err = e.loadTemplate()
+	if err == nil {
+		content, err = execute(t, x)
+	}
+
+	//
+	// If a custom template failed we use the built-in template
+	// instead, rather than failing to send, or sending a broken
+	// message.
+	//
+	if err != nil && e.template == nil {
+		terr = err
+
+		t, err = e.builtinTemplate()
 		if err != nil {
-			return err
+			t, err = e.cachedTemplate("embedded:plain", func() ([]byte, error) {
+				return emailtemplate.EmailTemplate(), nil
+			})
 		}
+		if err == nil {
+			content, err = execute(t, x)
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
 
-		//
-		// Load the template we're going to render.
-		//
-		var t *template.Template
-		t, err = e.loadTemplate()
-		if err != nil {
-			return err
+	//
+	// The envelope recipients are everybody.
+	//
+	rcpts := append(append(append([]string{}, to...), cc...), bcc...)
+
+	return &Message{Sender: e.envelopeSender(to[0]), Recipients: rcpts, Content: content, TemplateError: terr}, nil
+}
+
+// execute renders the given template, with the given data, recovering
+// from any panic.
+func execute(t *template.Template, data interface{}) (content []byte, err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("template panicked: %v", r)
 		}
+	}()
 
-		//
-		// Render the template into the buffer.
-		//
-		buf := &bytes.Buffer{}
-		err = t.Execute(buf, x)
-		if err != nil {
-			return err
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, data)
+	if err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// Send delivers a previously rendered message.
+func (e *Emailer) Send(msg *Message) error {
+
+	//
+	// Are we sending via SMTP?
+	//
+	var err error
+	if e.recorder != nil {
+		msg.Backend = "record"
+		msg.Response, err = e.recorder.record(msg)
+	} else if e.isSMTP() {
+		msg.Backend = "smtp"
+		msg.Response, err = e.sendSMTP(msg.Sender, msg.Recipients, msg.Content)
+	} else {
+		msg.Backend = "sendmail"
+		msg.Response, err = e.sendSendmail(msg.Sender, msg.Recipients, msg.Content)
+	}
+	return err
+}
+
+// recipients returns the To, CC, and BCC addresses for this email.
+//
+// The given addresses are used as the default To: list, but each list
+// may be replaced by the per-feed "to", "cc", and "bcc" options.
+func (e *Emailer) recipients(addresses []string) ([]string, []string, []string) {
+
+	to := SplitAddresses(addresses...)
+	cc := e.cc
+	bcc := e.bcc
+
+	var feedTo, feedCc, feedBcc []string
+	for _, opt := range e.opts {
+		switch opt.Name {
+		case "to":
+			feedTo = append(feedTo, SplitAddresses(opt.Value)...)
+		case "cc":
+			feedCc = append(feedCc, SplitAddresses(opt.Value)...)
+		case "bcc":
+			feedBcc = append(feedBcc, SplitAddresses(opt.Value)...)
 		}
+	}
 
-		//
-		// Are we sending via SMTP?
-		//
-		if e.isSMTP() {
+	if len(feedTo) > 0 {
+		to = feedTo
+	}
+	if len(feedCc) > 0 {
+		cc = feedCc
+	}
+	if len(feedBcc) > 0 {
+		bcc = feedBcc
+	}
 
-			err := e.sendSMTP(addr, buf.Bytes())
-			if err != nil {
-				return err
-			}
-		} else {
+	return to, cc, bcc
+}
 
-			err := e.sendSendmail(addr, buf.Bytes())
-			if err != nil {
-				return err
+// heavyElements are the elements we remove from a message which is
+// too large, before resorting to truncation.
+var heavyElements = "img[src^='data:'], svg, video, audio, iframe, object, embed, style"
+
+// limitSize ensures that the text and HTML bodies of our message fit
+// within the configured maximum size.
+//
+// If the message is too large we first strip any heavy elements from the
+// HTML, such as inline images.  If that doesn't suffice then both parts
+// are truncated, and a link to the full item is appended.
+func (e *Emailer) limitSize(textstr string, htmlstr string) (string, string) {
+
+	max := e.maxSize
+	if val := e.option("max-size"); val != "" {
+		n, err := ParseSize(val)
+		if err == nil {
+			max = n
+		}
+	}
+
+	if max <= 0 || len(textstr)+len(htmlstr) <= max {
+		return textstr, htmlstr
+	}
+
+	// Strip heavy elements from the HTML.
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(htmlstr))
+	if err == nil {
+		doc.Find(heavyElements).Remove()
+		out, err := doc.Html()
+		if err == nil {
+			htmlstr = out
+		}
+	}
+
+	if len(textstr)+len(htmlstr) <= max {
+		return textstr, htmlstr
+	}
+
+	// Still too large, so we'll truncate.  Since we cannot
+	// cut HTML at an arbitrary point the HTML part becomes
+	// a copy of the truncated text.
+	notice := fmt.Sprintf("[This item was too large, and has been truncated.  Read the rest at %s ]", e.item.Link)
+
+	wrapper := "<pre></pre>\n<p></p>\n\n"
+	budget := max - len(notice) - len(html.EscapeString(notice)) - len(wrapper)
+
+	// Each byte of text appears in both parts, and escaping
+	// might make the HTML-copy larger still.
+	cut := truncate(textstr, budget/2)
+	for len(cut) > 0 {
+		over := len(cut) + len(html.EscapeString(cut)) - budget
+		if over <= 0 {
+			break
+		}
+		cut = truncate(cut, len(cut)-over)
+	}
+
+	htmlstr = "<pre>" + html.EscapeString(cut) + "</pre>\n" +
+		"<p>" + html.EscapeString(notice) + "</p>"
+	textstr = cut + "\n\n" + notice
+
+	return textstr, htmlstr
+}
+
+// truncate returns the given string cut down to no more than the
+// given number of bytes, without splitting any UTF-8 sequence.
+func truncate(s string, max int) string {
+	if len(s) <= max {
+		return s
+	}
+	if max < 0 {
+		max = 0
+	}
+	for max > 0 && !utf8.RuneStart(s[max]) {
+		max--
+	}
+	return s[:max]
+}
+
+// ParseSize parses a size, such as "2048", "512k", or "2M", into a
+// number of bytes.
+func ParseSize(val string) (int, error) {
+
+	val = strings.TrimSpace(val)
+	mul := 1
+
+	switch {
+	case strings.HasSuffix(strings.ToLower(val), "k"):
+		mul = 1024
+	case strings.HasSuffix(strings.ToLower(val), "m"):
+		mul = 1024 * 1024
+	}
+	if mul != 1 {
+		val = val[:len(val)-1]
+	}
+
+	n, err := strconv.Atoi(strings.TrimSpace(val))
+	if err != nil {
+		return 0, fmt.Errorf("invalid size '%s': %s", val, err)
+	}
+	if n < 0 {
+		return 0, fmt.Errorf("invalid size '%s': negative", val)
+	}
+	return n * mul, nil
+}
+
+// SplitAddresses takes a series of values, each of which might contain
+// a comma-separated list of email addresses, and returns the individual
+// addresses found within them.
+func SplitAddresses(values ...string) []string {
+	var out []string
+
+	for _, val := range values {
+		for _, addr := range strings.Split(val, ",") {
+			addr = strings.TrimSpace(addr)
+			if addr != "" {
+				out = append(out, addr)
 			}
 		}
 	}
-	return nil
+
+	return out
 }
 
 // isSMTP determines whether we should use SMTP to send the email.
@@ -229,10 +947,22 @@ func (e *Emailer) Sendmail(addresses []string, textstr string, htmlstr string) e
 // We just check to see that the obvious mandatory parameters are set in the
 // environment.  If they're wrong we'll get an error at delivery time, as
 // expected.
+//
+// On Windows there is no sendmail binary, so SMTP is always used, unless
+// $RSS2EMAIL_SENDMAIL names the sendmail to use, which is always used.
 func (e *Emailer) isSMTP() bool {
 
+	// Sendmail was chosen explicitly.
+	if os.Getenv("RSS2EMAIL_SENDMAIL") != "" {
+		return false
+	}
+
+	if goos == "windows" {
+		return true
+	}
+
 	// Mandatory environmental variables
-	vars := []string{"SMTP_HOST", "SMTP_USERNAME", "SMTP_PASSWORD"}
+	vars := []string{"SMTP_HOST", "SMTP_USERNAME"}
 
 	for _, name := range vars {
 		if os.Getenv(name) == "" {
@@ -240,52 +970,189 @@ func (e *Emailer) isSMTP() bool {
 		}
 	}
 
-	return true
+	// We need either a password, or the means to get a token.
+	return os.Getenv("SMTP_PASSWORD") != "" || os.Getenv("SMTP_OAUTH_TOKEN_URL") != ""
 }
 
-// sendSMTP sends the content of the email to the destination address
-// via SMTP.
-func (e *Emailer) sendSMTP(to string, content []byte) error {
+// sendSMTP sends the content of the email to the destination addresses
+// via SMTP, using the given envelope sender.
+//
+// The final reply of the server is returned, which generally contains the
+// ID the message was queued with.
+func (e *Emailer) sendSMTP(from string, to []string, content []byte) (string, error) {
 
 	// basics
 	host := os.Getenv("SMTP_HOST")
 	port := os.Getenv("SMTP_PORT")
 
+	if host == "" {
+		return "", errors.New("SMTP_HOST is not set, unable to send email via SMTP")
+	}
+
 	p := 587
 	if port != "" {
 		n, err := strconv.Atoi(port)
 		if err != nil {
-			return err
+			return "", err
 		}
 		p = n
 	}
 
 	// auth
 	user := os.Getenv("SMTP_USERNAME")
-	pass := os.Getenv("SMTP_PASSWORD")
+	pass, err := secret.Getenv("SMTP_PASSWORD")
+	if err != nil {
+		return "", &DeliveryError{Backend: "smtp", Err: err}
+	}
 
-	// Authenticate
-	auth := smtp.PlainAuth("", user, pass, host)
+	// Authenticate, if we have credentials, via XOAUTH2 if we're
+	// configured to use OAuth2.
+	conf, err := smtpOAuth()
+	if err != nil {
+		return "", &DeliveryError{Backend: "smtp", Err: err}
+	}
+	auth, err := smtpAuth(conf, host, user, pass)
+	if err != nil {
+		return "", &DeliveryError{Backend: "smtp", Err: err}
+	}
 
 	// Get the mailserver
 	addr := fmt.Sprintf("%s:%d", host, p)
 
 	// Send the mail
-	err := smtp.SendMail(addr, auth, to, []string{to}, content)
+	response, err := smtpSend(addr, host, auth, from, to, content)
+	if err != nil {
 
-	return err
+		// If our token was refused then a new one is requested
+		// next time.
+		var aerr *authError
+		if conf != nil && errors.As(err, &aerr) {
+			conf.Invalidate()
+		}
+		return "", smtpError(err)
+	}
+	return response, nil
 }
 
-// sendSendmail sends the content of the email to the destination address
-// via /usr/sbin/sendmail
-func (e *Emailer) sendSendmail(addr string, content []byte) error {
+// smtpAuth returns the means of authenticating to the given SMTP server,
+// via XOAUTH2 if conf is set, or nil if no username was given.
+func smtpAuth(conf *oauth.Config, host string, user string, pass string) (smtp.Auth, error) {
+
+	if user == "" {
+		return nil, nil
+	}
+	if conf != nil {
+		token, err := conf.Token(context.Background())
+		if err != nil {
+			return nil, err
+		}
+		return &xoauth2Auth{user: user, token: token.AccessToken}, nil
+	}
+	return smtp.PlainAuth("", user, pass, host), nil
+}
+
+// smtpSend delivers the given message to the SMTP server at the given
+// address, as smtp.SendMail does, but returns the final reply of the
+// server.
+func smtpSend(addr string, host string, auth smtp.Auth, from string, to []string, content []byte) (string, error) {
+
+	c, err := smtp.Dial(addr)
+	if err != nil {
+		return "", err
+	}
+	defer c.Close()
+
+	if ok, _ := c.Extension("STARTTLS"); ok {
+		if err = c.StartTLS(&tls.Config{ServerName: host}); err != nil {
+			return "", err
+		}
+	}
+	if auth != nil {
+		if ok, _ := c.Extension("AUTH"); !ok {
+			return "", errors.New("smtp: server doesn't support AUTH")
+		}
+		if err = c.Auth(auth); err != nil {
+			return "", &authError{err: err}
+		}
+	}
+
+	if err = c.Mail(from); err != nil {
+		return "", err
+	}
+	for _, addr := range to {
+		if err = c.Rcpt(addr); err != nil {
+			return "", err
+		}
+	}
+
+	// We send the data ourselves, as the writer returned by c.Data
+	// discards the reply once the message has been sent.
+	id, err := c.Text.Cmd("DATA")
+	if err != nil {
+		return "", err
+	}
+	c.Text.StartResponse(id)
+	_, _, err = c.Text.ReadResponse(354)
+	c.Text.EndResponse(id)
+	if err != nil {
+		return "", err
+	}
+
+	w := c.Text.DotWriter()
+	if _, err = w.Write(content); err != nil {
+		return "", err
+	}
+	if err = w.Close(); err != nil {
+		return "", err
+	}
+	code, msg, err := c.Text.ReadResponse(250)
+	if err != nil {
+		return "", err
+	}
+
+	// Failing to say goodbye doesn't matter, the message was sent.
+	c.Quit()
+
+	return fmt.Sprintf("%d %s", code, msg), nil
+}
+
+// sendmailPath is the location of sendmail, which may be changed for
+// testing.
+var sendmailPath = "/usr/sbin/sendmail"
+
+// goos is the operating system we're running upon, which may be changed
+// for testing.
+var goos = runtime.GOOS
+
+// sendmailLocation returns the location of sendmail, which is given by
+// $RSS2EMAIL_SENDMAIL if that is set.
+func sendmailLocation() string {
+	if path := os.Getenv("RSS2EMAIL_SENDMAIL"); path != "" {
+		return path
+	}
+	return sendmailPath
+}
+
+// sendSendmail sends the content of the email to the destination addresses
+// via /usr/sbin/sendmail, using the given envelope sender.
+//
+// Any output of sendmail is returned.  If it fails the error is a
+// DeliveryError, classified by its exit status.
+func (e *Emailer) sendSendmail(from string, to []string, content []byte) (string, error) {
 
 	// Get the command to run.
-	sendmail := exec.Command("/usr/sbin/sendmail", "-i", "-f", addr, addr)
+	args := append([]string{"-i", "-f", from, "--"}, to...)
+	sendmail := exec.Command(sendmailLocation(), args...)
+	detach(sendmail)
+
+	// Keep any errors it reports.
+	var stderr bytes.Buffer
+	sendmail.Stderr = &stderr
+
 	stdin, err := sendmail.StdinPipe()
 	if err != nil {
-		fmt.Printf("Error sending email: %s\n", err.Error())
-		return err
+		e.log().Errorf("Error sending email: %s", err.Error())
+		return "", err
 	}
 
 	//
@@ -293,37 +1160,50 @@ func (e *Emailer) sendSendmail(addr string, content []byte) error {
 	//
 	stdout, err := sendmail.StdoutPipe()
 	if err != nil {
-		fmt.Printf("Error sending email: %s\n", err.Error())
-		return err
+		e.log().Errorf("Error sending email: %s", err.Error())
+		return "", err
 	}
 
 	//
 	// Run the command, and pipe in the rendered template-result
 	//
-	sendmail.Start()
-	_, err = stdin.Write(content)
+	err = sendmail.Start()
 	if err != nil {
-		fmt.Printf("Failed to write to sendmail pipe: %s\n", err.Error())
-		return err
+		e.log().Errorf("Error sending email: %s", err.Error())
+		return "", sendmailError(err, "")
 	}
+	_, err = stdin.Write(content)
 	stdin.Close()
+	if err != nil {
+		e.log().Errorf("Failed to write to sendmail pipe: %s", err.Error())
+
+		// Wait for it to exit, as its exit status will be
+		// more informative than a broken pipe.
+		if werr := sendmail.Wait(); werr != nil {
+			err = werr
+		}
+		return "", sendmailError(err, strings.TrimSpace(stderr.String()))
+	}
 
 	//
 	// Read the output of Sendmail.
 	//
-	_, err = ioutil.ReadAll(stdout)
+	output, err := io.ReadAll(stdout)
 	if err != nil {
-		fmt.Printf("Error reading mail output: %s\n", err.Error())
-		return nil
+		e.log().Errorf("Error reading mail output: %s", err.Error())
 	}
 
 	//
 	// Wait for the command to complete.
 	//
+	// The message has only been accepted if sendmail exits
+	// successfully.
+	//
 	err = sendmail.Wait()
 	if err != nil {
-		fmt.Printf("Waiting for process to terminate failed: %s\n", err.Error())
+		e.log().Errorf("Waiting for process to terminate failed: %s", err.Error())
+		return strings.TrimSpace(string(output)), sendmailError(err, strings.TrimSpace(stderr.String()))
 	}
 
-	return err
+	return strings.TrimSpace(string(output)), nil
 }
@@ -0,0 +1,481 @@
+package emailer
+
+import (
+	"net"
+	"net/smtp"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/oauth"
+	"github.com/skx/rss2email/withstate"
+)
+
+// TestSender ensures the From: header and envelope sender are chosen
+// appropriately.
+func TestSender(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+
+	// With no configuration everything is the recipient
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	if e.sender("bob@example.com") != "bob@example.com" {
+		t.Fatalf("unexpected sender")
+	}
+	if e.envelopeSender("bob@example.com") != "bob@example.com" {
+		t.Fatalf("unexpected envelope sender")
+	}
+
+	// Setting the From: address changes both
+	e.SetFrom("rss@example.com")
+	if e.sender("bob@example.com") != "rss@example.com" {
+		t.Fatalf("unexpected sender")
+	}
+	if e.envelopeSender("bob@example.com") != "rss@example.com" {
+		t.Fatalf("unexpected envelope sender")
+	}
+
+	// Setting the envelope only changes the envelope
+	e.SetEnvelopeFrom("bounces@example.com")
+	if e.sender("bob@example.com") != "rss@example.com" {
+		t.Fatalf("unexpected sender")
+	}
+	if e.envelopeSender("bob@example.com") != "bounces@example.com" {
+		t.Fatalf("unexpected envelope sender")
+	}
+
+	// Per-feed options win
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "from", Value: "feed@example.com"},
+		{Name: "envelope-from", Value: "feed-bounces@example.com"},
+	})
+	e.SetFrom("rss@example.com")
+	e.SetEnvelopeFrom("bounces@example.com")
+	if e.sender("bob@example.com") != "feed@example.com" {
+		t.Fatalf("unexpected sender")
+	}
+	if e.envelopeSender("bob@example.com") != "feed-bounces@example.com" {
+		t.Fatalf("unexpected envelope sender")
+	}
+}
+
+// TestIsSMTP ensures SMTP is used when it is configured, and always upon
+// Windows unless sendmail was chosen explicitly.
+func TestIsSMTP(t *testing.T) {
+
+	defer func(name string) { goos = name }(goos)
+
+	tests := []struct {
+		goos string
+		env  map[string]string
+		want bool
+	}{
+		{"linux", nil, false},
+		{"linux", map[string]string{"SMTP_HOST": "smtp.example.com"}, false},
+		{"linux", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_USERNAME": "bob"}, false},
+		{"linux", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_PASSWORD": "secret"}, false},
+		{"linux", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_USERNAME": "bob", "SMTP_PASSWORD": "secret"}, true},
+		{"linux", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_USERNAME": "bob", "SMTP_OAUTH_TOKEN_URL": "https://example.com/token"}, true},
+		{"linux", map[string]string{"SMTP_HOST": "smtp.example.com", "SMTP_USERNAME": "bob", "SMTP_PASSWORD": "secret", "RSS2EMAIL_SENDMAIL": "/usr/bin/msmtp"}, false},
+		{"windows", nil, true},
+		{"windows", map[string]string{"SMTP_HOST": "smtp.example.com"}, true},
+		{"windows", map[string]string{"RSS2EMAIL_SENDMAIL": `C:\msmtp\msmtp.exe`}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.goos, func(t *testing.T) {
+			for _, name := range []string{"SMTP_HOST", "SMTP_USERNAME", "SMTP_PASSWORD", "SMTP_OAUTH_TOKEN_URL", "RSS2EMAIL_SENDMAIL"} {
+				t.Setenv(name, test.env[name])
+			}
+			goos = test.goos
+
+			e := &Emailer{}
+			if e.isSMTP() != test.want {
+				t.Errorf("expected %v with %v", test.want, test.env)
+			}
+		})
+	}
+}
+
+// TestSMTPAuth ensures we only authenticate if we have a username.
+func TestSMTPAuth(t *testing.T) {
+
+	server := &smtp.ServerInfo{Name: "smtp.example.com", TLS: true, Auth: []string{"PLAIN"}}
+
+	tests := []struct {
+		user string
+		pass string
+		mech string
+	}{
+		{"", "", ""},
+		{"", "secret", ""},
+		{"bob", "secret", "PLAIN"},
+		{"bob", "", "PLAIN"},
+	}
+
+	for _, test := range tests {
+		auth, err := smtpAuth(nil, "smtp.example.com", test.user, test.pass)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if test.mech == "" {
+			if auth != nil {
+				t.Errorf("%q: expected no authentication", test.user)
+			}
+			continue
+		}
+		if auth == nil {
+			t.Fatalf("%q: expected authentication", test.user)
+		}
+		mech, _, err := auth.Start(server)
+		if err != nil || mech != test.mech {
+			t.Errorf("%q: unexpected mechanism %s %v", test.user, mech, err)
+		}
+	}
+
+	// Without a username OAuth2 isn't used either.
+	auth, err := smtpAuth(&oauth.Config{TokenURL: "http://127.0.0.1:0/token"}, "smtp.example.com", "", "")
+	if auth != nil || err != nil {
+		t.Fatalf("unexpected authentication: %v %v", auth, err)
+	}
+
+	// Nor do we authenticate when sending, which would fail.
+	defer func(name string) { goos = name }(goos)
+	goos = "windows"
+
+	host, port, _ := net.SplitHostPort(fakeSMTP(t, map[string]string{"AUTH": "535 5.7.8 Authentication failed"}))
+	t.Setenv("SMTP_HOST", host)
+	t.Setenv("SMTP_PORT", port)
+	t.Setenv("SMTP_USERNAME", "")
+	t.Setenv("SMTP_PASSWORD", "")
+	t.Setenv("SMTP_OAUTH_TOKEN_URL", "")
+	t.Setenv("RSS2EMAIL_SENDMAIL", "")
+
+	e := &Emailer{}
+	if !e.isSMTP() {
+		t.Fatalf("SMTP isn't used on Windows")
+	}
+	_, err = e.sendSMTP("steve@example.com", []string{"bob@example.com"}, []byte("Hello\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+// TestSplitAddresses ensures we can split comma-separated addresses.
+func TestSplitAddresses(t *testing.T) {
+
+	out := SplitAddresses("a@example.com, b@example.com", "", "c@example.com,")
+	if len(out) != 3 {
+		t.Fatalf("unexpected addresses: %v", out)
+	}
+	if out[0] != "a@example.com" || out[1] != "b@example.com" || out[2] != "c@example.com" {
+		t.Fatalf("unexpected addresses: %v", out)
+	}
+}
+
+// TestRecipients ensures per-feed recipients replace the defaults.
+func TestRecipients(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	e.SetCC([]string{"cc@example.com"})
+	e.SetBCC([]string{"bcc@example.com"})
+
+	to, cc, bcc := e.recipients([]string{"a@example.com,b@example.com"})
+	if len(to) != 2 || len(cc) != 1 || len(bcc) != 1 {
+		t.Fatalf("unexpected recipients %v %v %v", to, cc, bcc)
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "to", Value: "feed@example.com"},
+		{Name: "cc", Value: "one@example.com, two@example.com"},
+		{Name: "cc", Value: "three@example.com"},
+	})
+	e.SetCC([]string{"cc@example.com"})
+	e.SetBCC([]string{"bcc@example.com"})
+
+	to, cc, bcc = e.recipients([]string{"a@example.com"})
+	if len(to) != 1 || to[0] != "feed@example.com" {
+		t.Fatalf("unexpected to: %v", to)
+	}
+	if len(cc) != 3 {
+		t.Fatalf("unexpected cc: %v", cc)
+	}
+	if len(bcc) != 1 || bcc[0] != "bcc@example.com" {
+		t.Fatalf("unexpected bcc: %v", bcc)
+	}
+}
+
+// TestRenderRecipients ensures a single email is rendered for several
+// recipients, which is sent from the first of them.
+func TestRenderRecipients(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Post", Link: "https://example.com/post"}}
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	e.SetBCC([]string{"bcc@example.com"})
+
+	msg, err := e.Render([]string{"a@example.com", "b@example.com"}, "text", "<p>html</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.Sender != "a@example.com" {
+		t.Fatalf("unexpected envelope sender: %s", msg.Sender)
+	}
+	if strings.Join(msg.Recipients, ",") != "a@example.com,b@example.com,bcc@example.com" {
+		t.Fatalf("unexpected recipients: %v", msg.Recipients)
+	}
+	content := string(msg.Content)
+	if !strings.Contains(content, "From: a@example.com") || !strings.Contains(content, "To: a@example.com, b@example.com") {
+		t.Fatalf("unexpected headers: %s", content)
+	}
+	if strings.Contains(content, "bcc@example.com") {
+		t.Fatalf("the BCC address is visible: %s", content)
+	}
+}
+
+// TestParseSize tests our size-parsing
+func TestParseSize(t *testing.T) {
+
+	type TestCase struct {
+		input  string
+		output int
+		err    bool
+	}
+
+	tests := []TestCase{
+		{"1024", 1024, false},
+		{"2k", 2048, false},
+		{"2K", 2048, false},
+		{" 3M ", 3 * 1024 * 1024, false},
+		{"steve", 0, true},
+		{"-3", 0, true},
+	}
+
+	for _, tst := range tests {
+		out, err := ParseSize(tst.input)
+		if tst.err && err == nil {
+			t.Fatalf("expected error parsing %s", tst.input)
+		}
+		if !tst.err && err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", tst.input, err)
+		}
+		if out != tst.output {
+			t.Fatalf("parsing %s gave %d not %d", tst.input, out, tst.output)
+		}
+	}
+}
+
+// TestLimitSize ensures large messages are stripped and truncated.
+func TestLimitSize(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{Link: "https://example.com/item"}}
+
+	// No limit means no change
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	text, htm := e.limitSize("text", "<p>html</p>")
+	if text != "text" || htm != "<p>html</p>" {
+		t.Fatalf("unexpected change with no limit")
+	}
+
+	// An inline image is removed, if that suffices
+	image := `<p>Hello</p><img src="data:image/png;base64,` + strings.Repeat("A", 4096) + `">`
+	e.SetMaxSize(1024)
+	text, htm = e.limitSize("Hello", image)
+	if text != "Hello" {
+		t.Fatalf("text was changed unexpectedly: %s", text)
+	}
+	if strings.Contains(htm, "data:") || !strings.Contains(htm, "Hello") {
+		t.Fatalf("inline image was not removed: %s", htm)
+	}
+
+	// Large text is truncated, via the per-feed option
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "max-size", Value: "1k"},
+	})
+	body := strings.Repeat("word ", 2048)
+	text, htm = e.limitSize(body, "<p>"+body+"</p>")
+	if len(text)+len(htm) > 1024 {
+		t.Fatalf("message was not truncated: %d", len(text)+len(htm))
+	}
+	if !strings.Contains(text, "https://example.com/item") {
+		t.Fatalf("missing link in text: %s", text)
+	}
+	if !strings.Contains(htm, "https://example.com/item") {
+		t.Fatalf("missing link in HTML: %s", htm)
+	}
+}
+
+// TestTruncate ensures we don't split UTF-8 characters.
+func TestTruncate(t *testing.T) {
+
+	if truncate("héllo", 2) != "h" {
+		t.Fatalf("split a multi-byte character")
+	}
+	if truncate("hello", 10) != "hello" {
+		t.Fatalf("unexpected truncation")
+	}
+}
+
+// TestUnescapeHTML ensures that unescaping is opt-in.
+func TestUnescapeHTML(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	if e.unescapeHTML() {
+		t.Fatalf("unescaping should be disabled by default")
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "unescape-html", Value: "true"},
+	})
+	if !e.unescapeHTML() {
+		t.Fatalf("unescaping should have been enabled")
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "unescape-html", Value: "steve"},
+	})
+	if e.unescapeHTML() {
+		t.Fatalf("bogus values should be ignored")
+	}
+}
+
+// TestEncode tests our content-transfer-encodings
+func TestEncode(t *testing.T) {
+
+	out, err := encode("quoted-printable", "a=b")
+	if err != nil || out != "a=3Db" {
+		t.Fatalf("unexpected quoted-printable output %s %v", out, err)
+	}
+
+	out, err = encode("base64", strings.Repeat("x", 100))
+	if err != nil {
+		t.Fatalf("unexpected error %v", err)
+	}
+	for _, line := range strings.Split(out, "\n") {
+		if len(line) > 76 {
+			t.Fatalf("base64 output was not wrapped")
+		}
+	}
+
+	out, err = encode("8bit", "héllo")
+	if err != nil || out != "héllo" {
+		t.Fatalf("unexpected 8bit output %s %v", out, err)
+	}
+
+	_, err = encode("steve", "kemp")
+	if err == nil {
+		t.Fatalf("expected error with bogus encoding")
+	}
+}
+
+// TestEncodings ensures we select encodings correctly
+func TestEncodings(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	text, htm, err := e.encodings()
+	if err != nil || text != "quoted-printable" || htm != "quoted-printable" {
+		t.Fatalf("unexpected default encodings %s %s %v", text, htm, err)
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "encoding", Value: "Base64"},
+		{Name: "text-encoding", Value: "qp"},
+	})
+	text, htm, err = e.encodings()
+	if err != nil || text != "quoted-printable" || htm != "base64" {
+		t.Fatalf("unexpected encodings %s %s %v", text, htm, err)
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "html-encoding", Value: "7bit"},
+	})
+	_, _, err = e.encodings()
+	if err == nil {
+		t.Fatalf("expected error with bogus encoding")
+	}
+}
+
+// TestStyle ensures we can select the embedded templates.
+func TestStyle(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	tmpl, err := e.loadTemplate()
+	if err != nil {
+		t.Fatalf("unexpected error loading template: %s", err)
+	}
+	if tmpl.Lookup("html") == nil {
+		t.Fatalf("html part missing from template")
+	}
+
+	e.SetStyle("styled")
+	_, err = e.loadTemplate()
+	if err != nil {
+		t.Fatalf("unexpected error loading template: %s", err)
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "style", Value: "fancy"},
+	})
+	_, err = e.loadTemplate()
+	if err == nil {
+		t.Fatalf("expected error with unknown style")
+	}
+}
+
+// TestArchive ensures the snapshot of an item is linked by both of our
+// embedded templates.
+func TestArchive(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Post", Link: "https://example.com/post"}}
+	snapshot := "https://web.archive.org/web/20240101120000/https://example.com/post"
+
+	for _, style := range []string{"plain", "styled"} {
+
+		e := New(&gofeed.Feed{}, item, []configfile.Option{{Name: "encoding", Value: "8bit"}})
+		e.SetStyle(style)
+
+		msg, err := e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if strings.Contains(string(msg.Content), "Archived") {
+			t.Fatalf("unexpected snapshot in %s email", style)
+		}
+
+		e.SetArchive(snapshot)
+		msg, err = e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(string(msg.Content), "Archived: "+snapshot) {
+			t.Fatalf("snapshot missing from the text part of the %s email", style)
+		}
+		if !strings.Contains(string(msg.Content), `<a href="`+snapshot+`">`) {
+			t.Fatalf("snapshot missing from the HTML part of the %s email", style)
+		}
+	}
+}
@@ -0,0 +1,123 @@
+package emailer
+
+import (
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/text/unicode/runenames"
+)
+
+// shortcodes holds the shortcodes of common emoji, as used by GitHub, and
+// Slack, which differ from the names of the characters.  The shortcodes
+// of other emoji are derived from their names.
+var shortcodes = map[rune]string{
+	0x2705:  "white_check_mark",
+	0x274c:  "x",
+	0x2728:  "sparkles",
+	0x2764:  "heart",
+	0x26a0:  "warning",
+	0x2b50:  "star",
+	0x1f389: "tada",
+	0x1f41b: "bug",
+	0x1f440: "eyes",
+	0x1f44b: "wave",
+	0x1f44d: "+1",
+	0x1f44e: "-1",
+	0x1f449: "point_right",
+	0x1f4a1: "bulb",
+	0x1f4af: "100",
+	0x1f4cc: "pushpin",
+	0x1f4e2: "loudspeaker",
+	0x1f4e3: "mega",
+	0x1f525: "fire",
+	0x1f517: "link",
+	0x1f512: "lock",
+	0x1f600: "grinning",
+	0x1f602: "joy",
+	0x1f609: "wink",
+	0x1f60a: "blush",
+	0x1f60e: "sunglasses",
+	0x1f642: "slightly_smiling_face",
+	0x1f64f: "pray",
+	0x1f680: "rocket",
+	0x1f914: "thinking",
+}
+
+// emoji holds the ranges of characters we treat as emoji.
+var emoji = &unicode.RangeTable{
+	R16: []unicode.Range16{
+		{Lo: 0x231a, Hi: 0x231b, Stride: 1},
+		{Lo: 0x23e9, Hi: 0x23fa, Stride: 1},
+		{Lo: 0x2600, Hi: 0x27bf, Stride: 1},
+		{Lo: 0x2b05, Hi: 0x2b07, Stride: 1},
+		{Lo: 0x2b1b, Hi: 0x2b1c, Stride: 1},
+		{Lo: 0x2b50, Hi: 0x2b55, Stride: 5},
+	},
+	R32: []unicode.Range32{
+		{Lo: 0x1f000, Hi: 0x1faff, Stride: 1},
+	},
+}
+
+// emojiText returns true if the per-feed "emoji-text" option has been set,
+// to request that emoji are replaced by shortcodes in the text part of
+// emails.
+func (e *Emailer) emojiText() bool {
+	val, err := strconv.ParseBool(e.option("emoji-text"))
+	return err == nil && val
+}
+
+// replaceEmoji returns the given text with each emoji replaced by its
+// shortcode, such as ":rocket:", as many terminal mail clients can't
+// display them, or get confused about their width.
+//
+// Pairs of regional indicators become the shortcodes of flags, such as
+// ":flag_es:", and the modifiers, and joiners, of emoji sequences are
+// removed.
+func replaceEmoji(text string) string {
+
+	var sb strings.Builder
+	runes := []rune(text)
+	for i := 0; i < len(runes); i++ {
+		r := runes[i]
+
+		switch {
+		case r >= 0x1f1e6 && r <= 0x1f1ff:
+			// Regional indicators, which come in pairs.
+			if i+1 < len(runes) && runes[i+1] >= 0x1f1e6 && runes[i+1] <= 0x1f1ff {
+				sb.WriteString(":flag_" + string('a'+r-0x1f1e6) + string('a'+runes[i+1]-0x1f1e6) + ":")
+				i++
+			}
+		case r >= 0x1f3fb && r <= 0x1f3ff:
+			// Skin tones.
+		case r == 0xfe0e || r == 0xfe0f || r == 0x20e3:
+			// Variation selectors, and the keycap.
+		case r == 0x200d && i > 0 && unicode.Is(emoji, runes[i-1]):
+			// The joiner within an emoji sequence.
+		case unicode.Is(emoji, r):
+			sb.WriteString(":" + shortcode(r) + ":")
+		default:
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// shortcode returns the shortcode of the given emoji, without colons.
+func shortcode(r rune) string {
+
+	if code, ok := shortcodes[r]; ok {
+		return code
+	}
+
+	name := runenames.Name(r)
+	if name == "" || strings.HasPrefix(name, "<") {
+		return strconv.FormatInt(int64(r), 16)
+	}
+	return strings.Map(func(c rune) rune {
+		if c == ' ' || c == '-' {
+			return '_'
+		}
+		return unicode.ToLower(c)
+	}, name)
+}
@@ -0,0 +1,23 @@
+package emailer
+
+import "testing"
+
+// TestReplaceEmoji ensures emoji are replaced by their shortcodes.
+func TestReplaceEmoji(t *testing.T) {
+
+	tests := map[string]string{
+		"No emoji here, café.":           "No emoji here, café.",
+		"Launched \U0001f680":            "Launched :rocket:",
+		"Nice \U0001f44d\U0001f3fd":      "Nice :+1:",
+		"\u26a0\ufe0f Warning":           ":warning: Warning",
+		"Hola \U0001f1ea\U0001f1f8!":     "Hola :flag_es:!",
+		"\U0001f996 dinosaur":            ":t_rex: dinosaur",
+		"\U0001f468\u200d\U0001f4bb":     ":man::personal_computer:",
+		"Keep the \u200d joiner of text": "Keep the \u200d joiner of text",
+	}
+	for in, out := range tests {
+		if got := replaceEmoji(in); got != out {
+			t.Fatalf("replaceEmoji(%q) = %q, expected %q", in, got, out)
+		}
+	}
+}
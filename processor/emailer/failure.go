@@ -0,0 +1,119 @@
+package emailer
+
+import (
+	"errors"
+	"fmt"
+	"net/textproto"
+	"os/exec"
+)
+
+// DeliveryError is returned when the MTA didn't accept a message.
+//
+// Failures are either permanent, such as an unknown recipient, in which
+// case trying again would be pointless, or temporary, such as a server
+// which is down, in which case the message should be sent again later.
+type DeliveryError struct {
+
+	// Backend is the means of delivery, "smtp" or "sendmail".
+	Backend string
+
+	// Code is the SMTP reply code, or the exit status of sendmail, or
+	// zero if there was neither.
+	Code int
+
+	// Permanent is true if the failure is permanent.
+	Permanent bool
+
+	// Detail holds the message of the server, or the output sendmail
+	// wrote to STDERR, if any.
+	Detail string
+
+	// Err is the underlying error.
+	Err error
+}
+
+// Error is part of the error interface.
+func (e *DeliveryError) Error() string {
+
+	kind := "temporary"
+	if e.Permanent {
+		kind = "permanent"
+	}
+
+	msg := fmt.Sprintf("%s failure delivering via %s: %s", kind, e.Backend, e.Err)
+	if e.Detail != "" {
+		msg += ": " + e.Detail
+	}
+	return msg
+}
+
+// Unwrap returns the underlying error.
+func (e *DeliveryError) Unwrap() error {
+	return e.Err
+}
+
+// IsPermanent returns true if the given error is a permanent delivery
+// failure, which shouldn't be retried.
+func IsPermanent(err error) bool {
+
+	var derr *DeliveryError
+	return errors.As(err, &derr) && derr.Permanent
+}
+
+// IsTemporary returns true if the given error is a temporary delivery
+// failure, which should be retried later.
+func IsTemporary(err error) bool {
+
+	var derr *DeliveryError
+	return errors.As(err, &derr) && !derr.Permanent
+}
+
+// permanentExits holds the exit codes of sendmail, from sysexits.h, which
+// indicate permanent failures.  Others, such as EX_TEMPFAIL, are treated
+// as temporary, including those caused by a broken local setup which
+// will hopefully be fixed.
+var permanentExits = map[int]bool{
+	64: true, // EX_USAGE
+	65: true, // EX_DATAERR
+	66: true, // EX_NOINPUT
+	67: true, // EX_NOUSER
+	68: true, // EX_NOHOST
+	76: true, // EX_PROTOCOL
+	77: true, // EX_NOPERM
+}
+
+// sendmailError classifies the failure of sendmail, given the error
+// returned when running it, and anything it wrote to STDERR.
+func sendmailError(err error, stderr string) error {
+
+	derr := &DeliveryError{Backend: "sendmail", Detail: stderr, Err: err}
+
+	var exit *exec.ExitError
+	if errors.As(err, &exit) {
+		derr.Code = exit.ExitCode()
+		derr.Permanent = permanentExits[derr.Code]
+	}
+	return derr
+}
+
+// smtpError classifies the failure of an SMTP transaction.  Replies in
+// the 5xx range are permanent failures, anything else, including
+// network errors, is temporary.
+func smtpError(err error) error {
+
+	derr := &DeliveryError{Backend: "smtp", Err: err}
+
+	var proto *textproto.Error
+	if errors.As(err, &proto) {
+		derr.Code = proto.Code
+		derr.Permanent = proto.Code >= 500 && proto.Code < 600
+	}
+
+	// Failing to authenticate is a problem with our configuration,
+	// which will affect every message until it is fixed.
+	var aerr *authError
+	if errors.As(err, &aerr) {
+		derr.Permanent = false
+	}
+	return derr
+}
@@ -0,0 +1,218 @@
+package emailer
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestSendmailFailure(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sendmail is not used upon Windows")
+	}
+
+	bak := sendmailPath
+	defer func() { sendmailPath = bak }()
+
+	type TestCase struct {
+		script    string
+		response  string
+		permanent bool
+		failed    bool
+	}
+
+	tests := []TestCase{
+		{"cat >/dev/null; echo queued; exit 0", "queued", false, false},
+		{"cat >/dev/null; echo 'no such user' >&2; exit 67", "", true, true},
+		{"cat >/dev/null; echo 'try again' >&2; exit 75", "", false, true},
+		{"exit 1", "", false, true},
+	}
+
+	for _, tst := range tests {
+
+		sendmailPath = filepath.Join(t.TempDir(), "sendmail")
+		err := os.WriteFile(sendmailPath, []byte("#!/bin/sh\n"+tst.script+"\n"), 0755)
+		if err != nil {
+			t.Fatalf("failed to write script: %s", err)
+		}
+
+		e := &Emailer{}
+		response, err := e.sendSendmail("steve@example.com", []string{"bob@example.com"}, []byte("Subject: test\n\nHello\n"))
+		if (err != nil) != tst.failed {
+			t.Fatalf("%q: unexpected error %v", tst.script, err)
+		}
+		if response != tst.response {
+			t.Errorf("%q: unexpected response %q", tst.script, response)
+		}
+		if IsPermanent(err) != tst.permanent {
+			t.Errorf("%q: wrong classification of %v", tst.script, err)
+		}
+		if err != nil && strings.Contains(tst.script, ">&2") {
+			var derr *DeliveryError
+			if !errors.As(err, &derr) || derr.Detail == "" || !strings.Contains(err.Error(), derr.Detail) {
+				t.Errorf("%q: STDERR was not recorded: %v", tst.script, err)
+			}
+		}
+	}
+
+	// A missing sendmail is a temporary failure.
+	sendmailPath = filepath.Join(t.TempDir(), "missing")
+	_, err := (&Emailer{}).sendSendmail("steve@example.com", []string{"bob@example.com"}, []byte("Hello\n"))
+	if err == nil || IsPermanent(err) {
+		t.Fatalf("unexpected result with missing sendmail: %v", err)
+	}
+}
+
+func TestSendmailOverride(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("sendmail is not used upon Windows")
+	}
+
+	dir := t.TempDir()
+	sendmail := filepath.Join(dir, "sendmail")
+	err := os.WriteFile(sendmail, []byte("#!/bin/sh\ncat > "+filepath.Join(dir, "sent")+"\necho \"$@\"\n"), 0755)
+	if err != nil {
+		t.Fatalf("failed to write script: %s", err)
+	}
+
+	// The override is used, even if SMTP is configured.
+	os.Setenv("SMTP_HOST", "smtp.example.com")
+	os.Setenv("SMTP_USERNAME", "steve")
+	os.Setenv("SMTP_PASSWORD", "secret")
+	os.Setenv("RSS2EMAIL_SENDMAIL", sendmail)
+	defer os.Unsetenv("SMTP_HOST")
+	defer os.Unsetenv("SMTP_USERNAME")
+	defer os.Unsetenv("SMTP_PASSWORD")
+	defer os.Unsetenv("RSS2EMAIL_SENDMAIL")
+
+	msg := &Message{Sender: "steve@example.com", Recipients: []string{"bob@example.com"}, Content: []byte("Subject: test\n\nHello\n")}
+	err = (&Emailer{}).Send(msg)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.Backend != "sendmail" || msg.Response != "-i -f steve@example.com -- bob@example.com" {
+		t.Fatalf("unexpected result %s: %q", msg.Backend, msg.Response)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "sent"))
+	if err != nil || string(data) != string(msg.Content) {
+		t.Fatalf("unexpected content %q: %v", data, err)
+	}
+}
+
+// fakeSMTP runs a minimal SMTP server, which rejects the given
+// recipients with the given reply, returning its address.  Attempts to
+// authenticate are rejected with the reply given for "AUTH", if any.
+func fakeSMTP(t *testing.T, reject map[string]string) string {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go func(conn net.Conn) {
+				defer conn.Close()
+
+				rd := bufio.NewReader(conn)
+				reply := func(s string) { conn.Write([]byte(s + "\r\n")) }
+
+				reply("220 localhost ESMTP")
+				for {
+					line, err := rd.ReadString('\n')
+					if err != nil {
+						return
+					}
+					line = strings.TrimSpace(line)
+					cmd := strings.ToUpper(strings.SplitN(line, " ", 2)[0])
+
+					switch cmd {
+					case "EHLO", "HELO":
+						reply("250-localhost")
+						reply("250 AUTH PLAIN XOAUTH2")
+					case "AUTH":
+						if r, ok := reject["AUTH"]; ok {
+							reply(r)
+						} else {
+							reply("235 2.7.0 Authentication successful")
+						}
+					case "RCPT":
+						done := false
+						for addr, r := range reject {
+							if strings.Contains(line, addr) {
+								reply(r)
+								done = true
+							}
+						}
+						if !done {
+							reply("250 2.1.5 Ok")
+						}
+					case "DATA":
+						reply("354 End data with <CR><LF>.<CR><LF>")
+						for {
+							l, err := rd.ReadString('\n')
+							if err != nil || l == ".\r\n" {
+								break
+							}
+						}
+						reply("250 2.0.0 Ok: queued as ABC123")
+					case "QUIT":
+						reply("221 2.0.0 Bye")
+						return
+					default:
+						reply("250 Ok")
+					}
+				}
+			}(conn)
+		}
+	}()
+
+	return l.Addr().String()
+}
+
+func TestSMTPFailure(t *testing.T) {
+
+	addr := fakeSMTP(t, map[string]string{
+		"unknown@example.com": "550 5.1.1 No such user",
+		"busy@example.com":    "451 4.3.0 Try again later",
+	})
+
+	response, err := smtpSend(addr, "localhost", nil, "steve@example.com", []string{"bob@example.com"}, []byte("Subject: test\r\n\r\nHello\r\n"))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if response != "250 2.0.0 Ok: queued as ABC123" {
+		t.Fatalf("unexpected response %q", response)
+	}
+
+	_, err = smtpSend(addr, "localhost", nil, "steve@example.com", []string{"unknown@example.com"}, []byte("Hello\r\n"))
+	if err == nil || !IsPermanent(smtpError(err)) {
+		t.Fatalf("expected a permanent failure, got %v", err)
+	}
+
+	_, err = smtpSend(addr, "localhost", nil, "steve@example.com", []string{"busy@example.com"}, []byte("Hello\r\n"))
+	if err == nil || IsPermanent(smtpError(err)) {
+		t.Fatalf("expected a temporary failure, got %v", err)
+	}
+
+	// Failing to connect is temporary.
+	if IsPermanent(smtpError(errors.New("connection refused"))) {
+		t.Fatalf("network errors should be temporary")
+	}
+	if !strings.Contains(smtpError(err).Error(), "temporary failure delivering via smtp: 451") {
+		t.Fatalf("unexpected error message: %s", smtpError(err))
+	}
+}
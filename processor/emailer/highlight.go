@@ -0,0 +1,116 @@
+package emailer
+
+import (
+	"html"
+	"regexp"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+// wordStart and wordEnd match keywords which begin, or end, with a letter
+// or digit.
+var (
+	wordStart = regexp.MustCompile(`^\w`)
+	wordEnd   = regexp.MustCompile(`\w$`)
+)
+
+// highlighter returns the regular expression which matches the keywords
+// given via the "highlight" options of the feed, or nil if there are none.
+//
+// Each option holds a comma-separated list of keywords, which are matched
+// without regard to case, as whole words.
+func (e *Emailer) highlighter() *regexp.Regexp {
+
+	var words []string
+	for _, opt := range e.opts {
+		if opt.Name != "highlight" {
+			continue
+		}
+		for _, word := range strings.Split(opt.Value, ",") {
+			word = strings.TrimSpace(word)
+			if word == "" {
+				continue
+			}
+
+			// Only match whole words, where the keyword begins,
+			// or ends, with a letter or digit.
+			expr := regexp.QuoteMeta(word)
+			if wordStart.MatchString(word) {
+				expr = `\b` + expr
+			}
+			if wordEnd.MatchString(word) {
+				expr = expr + `\b`
+			}
+			words = append(words, expr)
+		}
+	}
+
+	if len(words) == 0 {
+		return nil
+	}
+	return regexp.MustCompile(`(?i)(` + strings.Join(words, "|") + `)`)
+}
+
+// highlightText returns the given text with the keywords which match the
+// given expression in upper-case.
+func highlightText(re *regexp.Regexp, text string) string {
+	return re.ReplaceAllStringFunc(text, strings.ToUpper)
+}
+
+// highlightHTML returns the given HTML with the keywords which match the
+// given expression wrapped in <mark> elements.
+//
+// Only text is changed, not the names of elements, or their attributes,
+// and the HTML is returned unchanged if there are no matches.
+func highlightHTML(re *regexp.Regexp, content string) string {
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	found := false
+
+	var walk func(s *goquery.Selection)
+	walk = func(s *goquery.Selection) {
+		s.Contents().Each(func(i int, c *goquery.Selection) {
+
+			switch goquery.NodeName(c) {
+			case "script", "style", "mark", "title":
+				return
+			case "#text":
+			default:
+				walk(c)
+				return
+			}
+
+			text := c.Text()
+			matches := re.FindAllStringIndex(text, -1)
+			if matches == nil {
+				return
+			}
+			found = true
+
+			var sb strings.Builder
+			last := 0
+			for _, m := range matches {
+				sb.WriteString(html.EscapeString(text[last:m[0]]))
+				sb.WriteString("<mark>" + html.EscapeString(text[m[0]:m[1]]) + "</mark>")
+				last = m[1]
+			}
+			sb.WriteString(html.EscapeString(text[last:]))
+			c.ReplaceWithHtml(sb.String())
+		})
+	}
+	walk(doc.Selection)
+
+	if !found {
+		return content
+	}
+	out, err := doc.Html()
+	if err != nil {
+		return content
+	}
+	return out
+}
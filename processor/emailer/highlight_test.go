@@ -0,0 +1,50 @@
+package emailer
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestHighlight(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{})
+	if e.highlighter() != nil {
+		t.Fatalf("unexpected highlighter")
+	}
+
+	e = New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "highlight", Value: "steve, rss2email"},
+		{Name: "highlight", Value: "C++,"},
+	})
+	re := e.highlighter()
+	if re == nil {
+		t.Fatalf("expected a highlighter")
+	}
+
+	text := highlightText(re, "Steve wrote rss2email in Go, not C++, for steven.")
+	if text != "STEVE wrote RSS2EMAIL in Go, not C++, for steven." {
+		t.Fatalf("unexpected text: %s", text)
+	}
+
+	content := highlightHTML(re, `<p class="steve"><a href="https://steve.fi/">Steve</a> &amp; C++ <b>rss2email</b></p><script>steve</script>`)
+	for _, expected := range []string{
+		`<p class="steve">`,
+		`<a href="https://steve.fi/"><mark>Steve</mark></a> &amp; <mark>C++</mark> <b><mark>rss2email</mark></b>`,
+		`<script>steve</script>`,
+	} {
+		if !strings.Contains(content, expected) {
+			t.Fatalf("expected %s within %s", expected, content)
+		}
+	}
+
+	// Nothing to highlight, nothing changed.
+	if highlightHTML(re, "<p>Nothing</p>") != "<p>Nothing</p>" {
+		t.Fatalf("unexpected change")
+	}
+}
@@ -0,0 +1,144 @@
+package emailer
+
+import (
+	"crypto/hmac"
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/skx/rss2email/secret"
+)
+
+// remote returns true if the given address is that of a remote image,
+// rather than one which is embedded.
+func remote(link string) bool {
+	link = strings.ToLower(strings.TrimSpace(link))
+	return strings.HasPrefix(link, "http://") || strings.HasPrefix(link, "https://") || strings.HasPrefix(link, "//")
+}
+
+// CheckImageProxy returns an error if the given value of an "image-proxy"
+// option isn't the address of a proxy.
+func CheckImageProxy(proxy string) error {
+
+	u, err := url.Parse(strings.ReplaceAll(proxy, "{url}", ""))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		return fmt.Errorf("invalid image-proxy '%s', expected an http, or https, URL", proxy)
+	}
+	return nil
+}
+
+// imageRewriter returns the function which replaces the addresses of the
+// remote images of the HTML part, according to the "strip-images", and
+// "image-proxy", options of the feed, or nil if they're left alone.
+//
+// The function returns the empty string if the image should be removed.
+func (e *Emailer) imageRewriter() func(string) string {
+
+	if strip, err := strconv.ParseBool(e.option("strip-images")); err == nil && strip {
+		return func(string) string { return "" }
+	}
+
+	proxy := e.option("image-proxy")
+	if proxy == "" {
+		return nil
+	}
+
+	// Images are stripped, rather than being shown directly, if the
+	// proxy can't be used.
+	if CheckImageProxy(proxy) != nil {
+		return func(string) string { return "" }
+	}
+	if strings.Contains(proxy, "{url}") {
+		return func(link string) string {
+			return strings.ReplaceAll(proxy, "{url}", url.QueryEscape(link))
+		}
+	}
+
+	key, err := secret.Getenv("IMAGE_PROXY_KEY")
+	if err != nil || key == "" {
+		return func(string) string { return "" }
+	}
+	return func(link string) string {
+		return camoURL(proxy, key, link)
+	}
+}
+
+// camoURL returns the address of the given image, via the camo proxy at
+// the given address, which shares the given key.
+//
+// The address holds the hex-encoded HMAC-SHA1 digest of the image's
+// address, and then the hex-encoded address itself.
+func camoURL(proxy string, key string, link string) string {
+
+	if strings.HasPrefix(link, "//") {
+		link = "https:" + link
+	}
+
+	mac := hmac.New(sha1.New, []byte(key))
+	mac.Write([]byte(link))
+	return strings.TrimSuffix(proxy, "/") + "/" + hex.EncodeToString(mac.Sum(nil)) + "/" + hex.EncodeToString([]byte(link))
+}
+
+// rewriteImages returns the given HTML with the addresses of its remote
+// images replaced via the given function, or removed if it returns the
+// empty string.
+//
+// Images which are removed are replaced by their alternative text, and
+// the HTML is returned unchanged if there are no remote images.
+func rewriteImages(content string, rewrite func(string) string) string {
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	found := false
+
+	doc.Find("img[src]").Each(func(i int, s *goquery.Selection) {
+		src, _ := s.Attr("src")
+		if !remote(src) {
+			return
+		}
+		found = true
+
+		s.RemoveAttr("srcset")
+		if link := rewrite(src); link != "" {
+			s.SetAttr("src", link)
+			return
+		}
+		alt := strings.TrimSpace(s.AttrOr("alt", ""))
+		if alt == "" {
+			s.Remove()
+		} else {
+			s.ReplaceWithHtml("[" + html.EscapeString(alt) + "]")
+		}
+	})
+
+	// Responsive images, and the posters of videos, are removed as
+	// they're rarely worth proxying.
+	doc.Find("img[srcset], picture source, video[poster]").Each(func(i int, s *goquery.Selection) {
+		found = true
+		switch goquery.NodeName(s) {
+		case "img":
+			s.RemoveAttr("srcset")
+		case "video":
+			s.RemoveAttr("poster")
+		default:
+			s.Remove()
+		}
+	})
+
+	if !found {
+		return content
+	}
+	out, err := doc.Html()
+	if err != nil {
+		return content
+	}
+	return out
+}
@@ -0,0 +1,78 @@
+package emailer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// imagesHTML holds HTML with a selection of images.
+var imagesHTML = `<p><img src="https://example.com/a.png" alt="A &amp; B"> <img src="http://example.com/b.png" srcset="https://example.com/b2.png 2x"> <img src="cid:icon"> <img src="data:image/png;base64,AAAA"></p>`
+
+func TestImageRewriter(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{}}
+	rewriter := func(opts ...configfile.Option) func(string) string {
+		return New(&gofeed.Feed{}, item, opts).imageRewriter()
+	}
+
+	if rewriter() != nil {
+		t.Fatalf("images should be left alone by default")
+	}
+	if rewriter(configfile.Option{Name: "strip-images", Value: "true"})("https://example.com/a.png") != "" {
+		t.Fatalf("images should be stripped")
+	}
+
+	proxy := rewriter(configfile.Option{Name: "image-proxy", Value: "https://images.example.net/?url={url}&w=600"})
+	if out := proxy("https://example.com/a.png?x=1"); out != "https://images.example.net/?url=https%3A%2F%2Fexample.com%2Fa.png%3Fx%3D1&w=600" {
+		t.Fatalf("unexpected address: %s", out)
+	}
+
+	// A camo proxy requires a key, without which images are removed.
+	os.Unsetenv("IMAGE_PROXY_KEY")
+	camo := configfile.Option{Name: "image-proxy", Value: "https://camo.example.net/"}
+	if rewriter(camo)("https://example.com/a.png") != "" {
+		t.Fatalf("images should be stripped without a key")
+	}
+
+	os.Setenv("IMAGE_PROXY_KEY", "0x24FEEDFACEDEADBEEFCAFE")
+	defer os.Unsetenv("IMAGE_PROXY_KEY")
+	out := rewriter(camo)("http://example.com/image.png")
+	if out != "https://camo.example.net/f5e1e6ff9afd6a9100783031e7c146cfb135e370/687474703a2f2f6578616d706c652e636f6d2f696d6167652e706e67" {
+		t.Fatalf("unexpected address: %s", out)
+	}
+
+	if CheckImageProxy("ftp://example.com/") == nil || CheckImageProxy("https://camo.example.net") != nil {
+		t.Fatalf("unexpected validation of proxies")
+	}
+}
+
+func TestRewriteImages(t *testing.T) {
+
+	out := rewriteImages(imagesHTML, func(link string) string { return "" })
+	for _, expected := range []string{`[A &amp; B]`, `<img src="cid:icon"/>`, `<img src="data:image/png;base64,AAAA"/>`} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %s in %s", expected, out)
+		}
+	}
+	if strings.Contains(out, "example.com") {
+		t.Fatalf("remote images weren't removed: %s", out)
+	}
+
+	out = rewriteImages(imagesHTML, func(link string) string { return "https://proxy.example.net/" + link })
+	for _, expected := range []string{`<img src="https://proxy.example.net/https://example.com/a.png" alt="A &amp; B"/>`, `<img src="https://proxy.example.net/http://example.com/b.png"/>`} {
+		if !strings.Contains(out, expected) {
+			t.Fatalf("expected %s in %s", expected, out)
+		}
+	}
+
+	// Without remote images the content is unchanged.
+	local := `<p><img src="cid:icon"></p>`
+	if rewriteImages(local, func(string) string { return "" }) != local {
+		t.Fatalf("content was changed")
+	}
+}
@@ -0,0 +1,66 @@
+package emailer
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// Recorder is a stub which records the emails it is given, rather than
+// delivering them, so that the processing of feeds may be tested without
+// sending anything.
+type Recorder struct {
+
+	// dir is the directory the emails are written to, if any.
+	dir string
+
+	// mutex protects messages, which holds the emails we've recorded.
+	mutex    sync.Mutex
+	messages []*Message
+}
+
+// NewRecorder returns a Recorder which also writes each email it records
+// to a file within the given directory, unless it is empty.  The files are
+// named after the order in which the emails were recorded, "0001.eml" and
+// so on.
+func NewRecorder(dir string) *Recorder {
+	return &Recorder{dir: dir}
+}
+
+// Messages returns the emails we've recorded, in order.
+func (r *Recorder) Messages() []*Message {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return append([]*Message(nil), r.messages...)
+}
+
+// record records the given email, and returns the response we report.
+func (r *Recorder) record(msg *Message) (string, error) {
+
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	r.messages = append(r.messages, msg)
+	name := fmt.Sprintf("%04d.eml", len(r.messages))
+	if r.dir == "" {
+		return "recorded " + name, nil
+	}
+
+	err := os.MkdirAll(r.dir, os.ModePerm)
+	if err == nil {
+		err = os.WriteFile(filepath.Join(r.dir, name), msg.Content, 0644)
+	}
+	if err != nil {
+		return "", err
+	}
+	return "recorded " + name, nil
+}
+
+// SetRecorder sets the recorder which records our emails, rather than
+// them being sent.
+func (e *Emailer) SetRecorder(r *Recorder) {
+	e.recorder = r
+}
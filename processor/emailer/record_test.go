@@ -0,0 +1,44 @@
+package emailer
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestRecorder(t *testing.T) {
+
+	dir := filepath.Join(t.TempDir(), "out")
+	r := NewRecorder(dir)
+
+	e := &Emailer{}
+	e.SetRecorder(r)
+
+	for _, body := range []string{"First", "Second"} {
+		msg := &Message{Sender: "steve@example.com", Recipients: []string{"bob@example.com"}, Content: []byte("Subject: " + body + "\n\n" + body + "\n")}
+		err := e.Send(msg)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if msg.Backend != "record" {
+			t.Fatalf("unexpected backend: %s", msg.Backend)
+		}
+	}
+
+	messages := r.Messages()
+	if len(messages) != 2 || messages[1].Response != "recorded 0002.eml" {
+		t.Fatalf("unexpected messages: %v", messages)
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "0001.eml"))
+	if err != nil || string(data) != "Subject: First\n\nFirst\n" {
+		t.Fatalf("unexpected file %q: %v", data, err)
+	}
+
+	// Without a directory nothing is written.
+	e.SetRecorder(NewRecorder(""))
+	msg := &Message{Content: []byte("Hello\n")}
+	if err = e.Send(msg); err != nil || msg.Response != "recorded 0001.eml" {
+		t.Fatalf("unexpected result %q: %v", msg.Response, err)
+	}
+}
@@ -0,0 +1,103 @@
+package emailer
+
+import (
+	"bytes"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+)
+
+// captures returns the groups captured by the first of the "include-title",
+// or "include", options of the feed which matches the item, so that they
+// may be used within the subject of its email.
+//
+// Groups are available by number, with "0" being the whole match, and by
+// name, if they are named.
+func (e *Emailer) captures(content string) map[string]string {
+
+	res := make(map[string]string)
+
+	for _, opt := range e.opts {
+
+		var text string
+		switch opt.Name {
+		case "include-title":
+			text = e.item.Title
+		case "include":
+			text = content
+		default:
+			continue
+		}
+
+		re, err := regexp.Compile(opt.Value)
+		if err != nil {
+			continue
+		}
+		match := re.FindStringSubmatch(text)
+		if match == nil {
+			continue
+		}
+
+		for i, name := range re.SubexpNames() {
+			res[strconv.Itoa(i)] = match[i]
+			if name != "" {
+				res[name] = match[i]
+			}
+		}
+		break
+	}
+	return res
+}
+
+// parseSubject parses the given value of the "subject" option, which is a
+// template for the subject of the emails of a feed.
+func parseSubject(text string) (*template.Template, error) {
+	return template.New("subject").Option("missingkey=zero").Parse(text)
+}
+
+// CheckSubject returns an error if the given value of the "subject" option
+// isn't a valid template.
+func CheckSubject(text string) error {
+	_, err := parseSubject(text)
+	return err
+}
+
+// affix adds the values of the "subject-prefix", and "subject-suffix",
+// options to the given subject, such as "[work]", so that the filters of
+// mail clients may match them whatever the template.
+func (e *Emailer) affix(subject string) string {
+
+	if prefix := e.option("subject-prefix"); prefix != "" {
+		subject = prefix + " " + subject
+	}
+	if suffix := e.option("subject-suffix"); suffix != "" {
+		subject = subject + " " + suffix
+	}
+	return subject
+}
+
+// subject returns the subject of the email, rendered from the template
+// given via the "subject" option with the given data, or the title of the
+// item if there is none.
+func (e *Emailer) subject(data interface{}) (string, error) {
+
+	text := e.option("subject")
+	if text == "" {
+		return e.item.Title, nil
+	}
+
+	t, err := parseSubject(text)
+	if err != nil {
+		return e.item.Title, err
+	}
+
+	buf := &bytes.Buffer{}
+	err = t.Execute(buf, data)
+	if err != nil {
+		return e.item.Title, err
+	}
+
+	// The subject is a single line.
+	return strings.Join(strings.Fields(buf.String()), " "), nil
+}
@@ -0,0 +1,116 @@
+package emailer
+
+import (
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestCaptures(t *testing.T) {
+
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Release v1.2.3 of Thing"}}
+
+	e := New(&gofeed.Feed{}, item, []configfile.Option{
+		{Name: "exclude-title", Value: "(beta)"},
+		{Name: "include", Value: "(nothing)"},
+		{Name: "include-title", Value: `v(?P<version>[0-9.]+) of (\w+)`},
+		{Name: "include", Value: "(body)"},
+	})
+
+	got := e.captures("the body")
+	expected := map[string]string{"0": "v1.2.3 of Thing", "1": "1.2.3", "version": "1.2.3", "2": "Thing"}
+	if len(got) != len(expected) {
+		t.Fatalf("unexpected captures %v", got)
+	}
+	for k, v := range expected {
+		if got[k] != v {
+			t.Fatalf("unexpected capture %s: %q != %q", k, got[k], v)
+		}
+	}
+
+	// No match, no captures.
+	e = New(&gofeed.Feed{}, item, []configfile.Option{{Name: "include", Value: "(nothing)"}})
+	if len(e.captures("the body")) != 0 {
+		t.Fatalf("unexpected captures")
+	}
+}
+
+func TestSubject(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Ticket #1234: the sky is falling"}}
+
+	tests := []struct {
+		subject  string
+		expected string
+		fails    bool
+	}{
+		{"", "Ticket #1234: the sky is falling", false},
+		{"[{{.Captures.id}}] {{.Captures.summary}}", "[1234] the sky is falling", false},
+		{"{{.Captures.missing}}\n{{.Name}}", "Example", false},
+		{"{{.Captures.id", "Ticket #1234: the sky is falling", true},
+		{"{{.Nothing}}", "Ticket #1234: the sky is falling", true},
+	}
+
+	for _, test := range tests {
+		e := New(&gofeed.Feed{}, item, []configfile.Option{
+			{Name: "name", Value: "Example"},
+			{Name: "include-title", Value: `^Ticket #(?P<id>\d+): (?P<summary>.*)$`},
+			{Name: "subject", Value: test.subject},
+		})
+
+		msg, err := e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(string(msg.Content), "Subject: [Example] "+test.expected+"\n") {
+			t.Fatalf("unexpected subject for %q:\n%s", test.subject, msg.Content)
+		}
+		if (msg.TemplateError != nil) != test.fails {
+			t.Fatalf("unexpected result for %q: %v", test.subject, msg.TemplateError)
+		}
+		if CheckSubject(test.subject) != nil && !test.fails {
+			t.Fatalf("valid subject rejected: %q", test.subject)
+		}
+	}
+}
+
+func TestSubjectAffixes(t *testing.T) {
+
+	// Ensure we don't find a local template
+	home := os.Getenv("HOME")
+	os.Setenv("HOME", t.TempDir())
+	defer os.Setenv("HOME", home)
+
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Ticket #1234: the sky is falling"}}
+
+	tests := []struct {
+		opts     []configfile.Option
+		expected string
+	}{
+		{nil, "Ticket #1234: the sky is falling"},
+		{[]configfile.Option{{Name: "subject-prefix", Value: "[work]"}}, "[work] Ticket #1234: the sky is falling"},
+		{[]configfile.Option{{Name: "subject-suffix", Value: "[security]"}}, "Ticket #1234: the sky is falling [security]"},
+		{[]configfile.Option{{Name: "subject", Value: "Sky"}, {Name: "subject-prefix", Value: "[work]"}, {Name: "subject-suffix", Value: "[security]"}}, "[work] Sky [security]"},
+	}
+
+	for _, test := range tests {
+		e := New(&gofeed.Feed{}, item, append([]configfile.Option{{Name: "name", Value: "Example"}}, test.opts...))
+
+		msg, err := e.Render([]string{"user@example.com"}, "text", "<p>html</p>")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if !strings.Contains(string(msg.Content), "Subject: [Example] "+test.expected+"\n") {
+			t.Fatalf("unexpected subject for %v:\n%s", test.opts, msg.Content)
+		}
+	}
+}
@@ -0,0 +1,60 @@
+package emailer
+
+import (
+	"sync"
+	"text/template"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// templates caches the templates we've parsed, by the file, or embedded
+// style, they came from, so that each is read and parsed once per run
+// rather than once per email.
+var templates = struct {
+	sync.Mutex
+	parsed map[string]*template.Template
+}{parsed: make(map[string]*template.Template)}
+
+// ResetTemplates forgets the templates we've parsed, so that any changes
+// made to them are noticed.  This is called at the start of each run.
+func ResetTemplates() {
+	templates.Lock()
+	templates.parsed = make(map[string]*template.Template)
+	templates.Unlock()
+}
+
+// PrepareTemplate finds, and parses, the template which will be used for
+// a feed with the given options, if the given style is the default, so
+// that any problem with it is found before items are processed.
+//
+// The template is cached, to be used for the emails of the feed.
+func PrepareTemplate(opts []configfile.Option, style string) error {
+
+	e := &Emailer{opts: opts, style: style}
+	_, err := e.loadTemplate()
+	return err
+}
+
+// cachedTemplate returns the template cached with the given key, parsing
+// the content returned by the given function if there is none.
+func (e *Emailer) cachedTemplate(key string, load func() ([]byte, error)) (*template.Template, error) {
+
+	templates.Lock()
+	defer templates.Unlock()
+
+	if tmpl, ok := templates.parsed[key]; ok {
+		return tmpl, nil
+	}
+
+	content, err := load()
+	if err != nil {
+		return nil, err
+	}
+
+	tmpl, err := e.parseTemplate(content)
+	if err != nil {
+		return nil, err
+	}
+	templates.parsed[key] = tmpl
+	return tmpl, nil
+}
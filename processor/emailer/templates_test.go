@@ -0,0 +1,135 @@
+package emailer
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"text/template"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestTemplateCache(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	ResetTemplates()
+	defer ResetTemplates()
+
+	path := filepath.Join(dir, "podcast.tmpl")
+	err := os.WriteFile(path, []byte("first"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+
+	opts := []configfile.Option{{Name: "template", Value: "podcast.tmpl"}}
+	if err = PrepareTemplate(opts, ""); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Changes aren't noticed until the cache is reset.
+	err = os.WriteFile(path, []byte("second"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+
+	render := func() string {
+		tmpl, err := (&Emailer{opts: opts}).loadTemplate()
+		if err != nil {
+			t.Fatalf("failed to load template: %s", err)
+		}
+		var sb strings.Builder
+		tmpl.Execute(&sb, nil)
+		return sb.String()
+	}
+	if render() != "first" {
+		t.Fatalf("template was not cached")
+	}
+	ResetTemplates()
+	if render() != "second" {
+		t.Fatalf("template was not reloaded")
+	}
+
+	// Feeds without their own template use the embedded one.
+	if err = PrepareTemplate(nil, "styled"); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Problems are reported.
+	for _, tst := range []struct {
+		opts  []configfile.Option
+		style string
+		err   string
+	}{
+		{[]configfile.Option{{Name: "template", Value: "missing.tmpl"}}, "", "does not exist"},
+		{nil, "fancy", "unknown template style"},
+	} {
+		err = PrepareTemplate(tst.opts, tst.style)
+		if err == nil || !strings.Contains(err.Error(), tst.err) {
+			t.Errorf("expected error %q, got %v", tst.err, err)
+		}
+	}
+
+	err = os.WriteFile(path, []byte("{{if}}"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+	ResetTemplates()
+	err = PrepareTemplate(opts, "")
+	if err == nil || !strings.Contains(err.Error(), "failed to parse template") {
+		t.Errorf("expected parse error, got %v", err)
+	}
+}
+
+func TestTemplateFallback(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	ResetTemplates()
+	defer ResetTemplates()
+
+	err := os.WriteFile(filepath.Join(dir, "email.tmpl"), []byte("Subject: {{template \"missing\" .}}\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+
+	feed := &gofeed.Feed{Title: "Example"}
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Hello", Link: "https://example.com/1"}}
+
+	// The built-in template is used instead.
+	msg, err := New(feed, item, nil).Render([]string{"steve@example.com"}, "text", "<p>text</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if msg.TemplateError == nil || !strings.Contains(string(msg.Content), "X-RSS-Link: https://example.com/1") {
+		t.Fatalf("built-in template wasn't used: %v %s", msg.TemplateError, msg.Content)
+	}
+
+	// Unless the template was given explicitly.
+	e := New(feed, item, nil)
+	e.SetTemplate([]byte("{{.Nope}}"))
+	_, err = e.Render([]string{"steve@example.com"}, "text", "<p>text</p>")
+	if err == nil {
+		t.Fatalf("expected error with explicit template")
+	}
+
+	// Panics are recovered.
+	tmpl := template.Must(template.New("x").Parse("{{.Boom}}"))
+	_, err = execute(tmpl, boom{})
+	if err == nil || !strings.Contains(err.Error(), "kaboom") {
+		t.Fatalf("expected error from panic, got %v", err)
+	}
+}
+
+// boom is used to cause a template to panic.
+type boom struct{}
+
+// Boom panics.
+func (b boom) Boom() string {
+	panic("kaboom")
+}
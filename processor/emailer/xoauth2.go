@@ -0,0 +1,91 @@
+package emailer
+
+import (
+	"errors"
+	"net/smtp"
+	"os"
+	"strings"
+
+	"github.com/skx/rss2email/oauth"
+	"github.com/skx/rss2email/secret"
+)
+
+// smtpOAuth returns the details used to request the access tokens with
+// which we authenticate via XOAUTH2, or nil if we should use a password.
+//
+// These are taken from the SMTP_OAUTH_* environmental variables, of which
+// the client secret and refresh token may be references to secrets.
+func smtpOAuth() (*oauth.Config, error) {
+
+	url := os.Getenv("SMTP_OAUTH_TOKEN_URL")
+	if url == "" {
+		return nil, nil
+	}
+
+	conf := &oauth.Config{
+		TokenURL: url,
+		ClientID: os.Getenv("SMTP_OAUTH_CLIENT_ID"),
+		Scopes:   strings.Fields(os.Getenv("SMTP_OAUTH_SCOPE")),
+	}
+
+	var err error
+	conf.ClientSecret, err = secret.Getenv("SMTP_OAUTH_CLIENT_SECRET")
+	if err != nil {
+		return nil, err
+	}
+	conf.RefreshToken, err = secret.Getenv("SMTP_OAUTH_REFRESH_TOKEN")
+	if err != nil {
+		return nil, err
+	}
+	return conf, nil
+}
+
+// xoauth2Auth implements the XOAUTH2 mechanism, used by Gmail and
+// Office365, which authenticates with an OAuth2 access token rather than
+// a password.
+type xoauth2Auth struct {
+	user  string
+	token string
+}
+
+// Start is part of the smtp.Auth interface.
+func (a *xoauth2Auth) Start(server *smtp.ServerInfo) (string, []byte, error) {
+
+	// As with PLAIN we refuse to send our token in the clear, except
+	// to the local host.
+	if !server.TLS && server.Name != "localhost" && server.Name != "127.0.0.1" && server.Name != "::1" {
+		return "", nil, errors.New("unencrypted connection")
+	}
+
+	return "XOAUTH2", []byte("user=" + a.user + "\x01auth=Bearer " + a.token + "\x01\x01"), nil
+}
+
+// Next is part of the smtp.Auth interface.
+//
+// If our token is refused the server sends a challenge describing the
+// error, to which we reply with an empty response to receive the final
+// failure.
+func (a *xoauth2Auth) Next(fromServer []byte, more bool) ([]byte, error) {
+	if more {
+		return []byte{}, nil
+	}
+	return nil, nil
+}
+
+// authError is a failure to authenticate with the SMTP server.
+//
+// This is a problem with our configuration, rather than the message, so
+// it is never a permanent failure, even though it has a 5xx code.
+type authError struct {
+	err error
+}
+
+// Error is part of the error interface.
+func (e *authError) Error() string {
+	return "authentication failed: " + e.err.Error()
+}
+
+// Unwrap returns the underlying error.
+func (e *authError) Unwrap() error {
+	return e.err
+}
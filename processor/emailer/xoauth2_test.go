@@ -0,0 +1,94 @@
+package emailer
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/smtp"
+	"os"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestXOAUTH2Auth(t *testing.T) {
+
+	a := &xoauth2Auth{user: "bob@example.com", token: "ya29.token"}
+
+	mech, resp, err := a.Start(&smtp.ServerInfo{Name: "smtp.example.com", TLS: true})
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if mech != "XOAUTH2" || string(resp) != "user=bob@example.com\x01auth=Bearer ya29.token\x01\x01" {
+		t.Fatalf("unexpected response %s %q", mech, resp)
+	}
+
+	// Our token isn't sent in the clear.
+	_, _, err = a.Start(&smtp.ServerInfo{Name: "smtp.example.com"})
+	if err == nil {
+		t.Fatalf("expected error without TLS")
+	}
+}
+
+// TestSMTPOAuth ensures we authenticate via XOAUTH2, using a token gained
+// via our refresh token, if configured to.
+func TestSMTPOAuth(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	issued := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r.ParseForm()
+		if r.Form.Get("grant_type") != "refresh_token" || r.Form.Get("refresh_token") != "refresh" {
+			w.WriteHeader(http.StatusBadRequest)
+			fmt.Fprintln(w, `{"error": "invalid_grant"}`)
+			return
+		}
+		issued++
+		fmt.Fprintf(w, `{"access_token": "token-%d", "expires_in": 3600}`, issued)
+	}))
+	defer ts.Close()
+
+	send := func(addr string) error {
+		host, port, _ := net.SplitHostPort(addr)
+		env := map[string]string{
+			"SMTP_HOST":                host,
+			"SMTP_PORT":                port,
+			"SMTP_USERNAME":            "bob@example.com",
+			"SMTP_OAUTH_TOKEN_URL":     ts.URL,
+			"SMTP_OAUTH_CLIENT_ID":     "rss2email",
+			"SMTP_OAUTH_REFRESH_TOKEN": "refresh",
+		}
+		for k, v := range env {
+			os.Setenv(k, v)
+			defer os.Unsetenv(k)
+		}
+
+		e := &Emailer{}
+		if !e.isSMTP() {
+			t.Fatalf("SMTP isn't configured")
+		}
+		_, err := e.sendSMTP("steve@example.com", []string{"bob@example.com"}, []byte("Hello\r\n"))
+		return err
+	}
+
+	err := send(fakeSMTP(t, nil))
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// A refused token is a temporary failure, and is replaced.
+	err = send(fakeSMTP(t, map[string]string{"AUTH": "535 5.7.8 Username and Password not accepted"}))
+	if err == nil || IsPermanent(err) {
+		t.Fatalf("expected a temporary failure, got %v", err)
+	}
+	if issued != 1 {
+		t.Fatalf("unexpected number of tokens issued: %d", issued)
+	}
+	err = send(fakeSMTP(t, nil))
+	if err != nil || issued != 2 {
+		t.Fatalf("token wasn't replaced: %v %d", err, issued)
+	}
+}
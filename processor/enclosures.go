@@ -0,0 +1,138 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+)
+
+// maxEnclosures is the largest number of enclosures we'll attach to a
+// single email.
+const maxEnclosures = 10
+
+// attachLimit returns the size of the largest enclosure which may be
+// attached to the emails of the given feed, via the "attach" option, or
+// zero if enclosures aren't attached.
+func attachLimit(config configfile.Feed) (int, error) {
+
+	limit := 0
+	for _, opt := range config.Options {
+		if opt.Name == "attach" {
+			n, err := emailer.ParseSize(opt.Value)
+			if err != nil {
+				return 0, fmt.Errorf("invalid attach size: %s", err)
+			}
+			limit = n
+		}
+	}
+	return limit, nil
+}
+
+// fetchEnclosures downloads the enclosures of the given item which are no
+// larger than the limit of its feed, so that they may be attached to its
+// email.
+//
+// Failure isn't fatal, enclosures which aren't attached are linked from
+// the email instead.
+func (p *Processor) fetchEnclosures(ctx context.Context, entry configfile.Feed, xp *gofeed.Item) []emailer.Attachment {
+
+	limit, err := attachLimit(entry)
+	if err != nil {
+		p.message(fmt.Sprintf("\t\t%s\n", err))
+		return nil
+	}
+	if limit == 0 || xp == nil {
+		return nil
+	}
+
+	agent := "rss2email (https://github.com/skx/rss2email)"
+	for _, opt := range entry.Options {
+		if opt.Name == "user-agent" {
+			agent = opt.Value
+		}
+	}
+
+	var files []emailer.Attachment
+	for _, enc := range xp.Enclosures {
+
+		if len(files) >= maxEnclosures {
+			break
+		}
+		if enc == nil || enc.URL == "" {
+			continue
+		}
+
+		// Don't fetch those we know are too large.
+		if n, err := strconv.Atoi(strings.TrimSpace(enc.Length)); err == nil && n > limit {
+			continue
+		}
+
+		file, err := download(ctx, enc, agent, limit)
+		if err != nil {
+			p.message(fmt.Sprintf("\t\tNot attaching %s: %s\n", enc.URL, err))
+			continue
+		}
+		files = append(files, *file)
+	}
+	return files
+}
+
+// download fetches the given enclosure, failing if it is larger than the
+// given limit.
+func download(ctx context.Context, enc *gofeed.Enclosure, agent string, limit int) (*emailer.Attachment, error) {
+
+	if !strings.HasPrefix(enc.URL, "http://") && !strings.HasPrefix(enc.URL, "https://") {
+		return nil, fmt.Errorf("not a web link")
+	}
+
+	client := &http.Client{Timeout: 60 * time.Second}
+	req, err := http.NewRequestWithContext(ctx, "GET", enc.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("User-Agent", agent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+	if resp.ContentLength > int64(limit) {
+		return nil, fmt.Errorf("too large")
+	}
+
+	data, err := io.ReadAll(io.LimitReader(resp.Body, int64(limit)+1))
+	if err != nil {
+		return nil, err
+	}
+	if len(data) > limit {
+		return nil, fmt.Errorf("too large")
+	}
+
+	ctype := enc.Type
+	if ctype == "" {
+		ctype = resp.Header.Get("Content-Type")
+	}
+	if ctype == "" {
+		ctype = http.DetectContentType(data)
+	}
+
+	return &emailer.Attachment{
+		URL:         enc.URL,
+		Name:        emailer.AttachName(enc.URL),
+		ContentType: ctype,
+		Data:        data,
+	}, nil
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestAttachLimit ensures the option is parsed.
+func TestAttachLimit(t *testing.T) {
+
+	limit, err := attachLimit(configfile.Feed{})
+	if err != nil || limit != 0 {
+		t.Fatalf("unexpected result: %d %v", limit, err)
+	}
+
+	limit, err = attachLimit(configfile.Feed{Options: []configfile.Option{{Name: "attach", Value: "2M"}}})
+	if err != nil || limit != 2*1024*1024 {
+		t.Fatalf("unexpected result: %d %v", limit, err)
+	}
+
+	_, err = attachLimit(configfile.Feed{Options: []configfile.Option{{Name: "attach", Value: "lots"}}})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+// TestFetchEnclosures ensures only those enclosures within our limit are
+// attached.
+func TestFetchEnclosures(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Path {
+		case "/small.pdf":
+			w.Write([]byte("%PDF-1.4\n"))
+		case "/large.mp3":
+			w.Write([]byte(strings.Repeat("x", 2048)))
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer ts.Close()
+
+	xp := &gofeed.Item{Enclosures: []*gofeed.Enclosure{
+		{URL: ts.URL + "/small.pdf", Type: "application/pdf"},
+		{URL: ts.URL + "/large.mp3", Type: "audio/mpeg"},
+		{URL: ts.URL + "/huge.mp3", Type: "audio/mpeg", Length: "104857600"},
+		{URL: ts.URL + "/missing.pdf"},
+	}}
+
+	p := New()
+	entry := configfile.Feed{Options: []configfile.Option{{Name: "attach", Value: "1k"}}}
+
+	files := p.fetchEnclosures(context.Background(), entry, xp)
+	if len(files) != 1 {
+		t.Fatalf("expected one attachment, got %d", len(files))
+	}
+	if files[0].Name != "small.pdf" || files[0].ContentType != "application/pdf" || string(files[0].Data) != "%PDF-1.4\n" {
+		t.Fatalf("unexpected attachment: %v", files[0])
+	}
+
+	// Nothing is attached without the option.
+	if len(p.fetchEnclosures(context.Background(), configfile.Feed{}, xp)) != 0 {
+		t.Fatalf("unexpected attachments")
+	}
+}
@@ -0,0 +1,62 @@
+package processor
+
+import (
+	"context"
+	"strconv"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/calendar"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+)
+
+// invite returns the calendar invite to attach to the email of the given
+// item, if it describes an event.
+//
+// Events are found via the metadata of the item, unless the feed has the
+// "event" option set to false, and via the dates within the item if it
+// is set to true.  Dates without a time zone are in the given location.
+func invite(config configfile.Feed, xp *gofeed.Item, loc *time.Location) *emailer.Attachment {
+
+	if xp == nil {
+		return nil
+	}
+
+	metadata, patterns := true, false
+	for _, opt := range config.Options {
+		if opt.Name == "event" {
+			val, err := strconv.ParseBool(opt.Value)
+			if err == nil {
+				metadata, patterns = val, val
+			}
+		}
+	}
+	if !metadata {
+		return nil
+	}
+
+	ev := calendar.Find(xp, patterns, loc)
+	if ev == nil {
+		return nil
+	}
+
+	return &emailer.Attachment{
+		Name:        "invite.ics",
+		ContentType: "text/calendar",
+		Data:        ev.ICS(time.Now()),
+	}
+}
+
+// attachments returns the files to attach to the email of the given item,
+// its enclosures and any calendar invite.
+func (p *Processor) attachments(ctx context.Context, entry configfile.Feed, xp *gofeed.Item) []emailer.Attachment {
+
+	files := p.fetchEnclosures(ctx, entry, xp)
+	// Failure is reported by lint, and the local time zone used.
+	loc, _ := emailer.Location(entry.Options, p.timezone)
+	if file := invite(entry, xp, loc); file != nil {
+		files = append(files, *file)
+	}
+	return files
+}
@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestInvite ensures invites are attached to the items which describe
+// events.
+func TestInvite(t *testing.T) {
+
+	item := &gofeed.Item{Title: "Meetup on 2024-05-01 18:30", Link: "https://example.com/1"}
+
+	on := configfile.Feed{Options: []configfile.Option{{Name: "event", Value: "true"}}}
+	off := configfile.Feed{Options: []configfile.Option{{Name: "event", Value: "false"}}}
+
+	// Dates are only found via patterns if enabled.
+	if invite(configfile.Feed{}, item, time.UTC) != nil {
+		t.Fatalf("unexpected invite")
+	}
+	file := invite(on, item, time.UTC)
+	if file == nil {
+		t.Fatalf("expected an invite")
+	}
+	if file.Name != "invite.ics" || file.ContentType != "text/calendar" || !strings.Contains(string(file.Data), "SUMMARY:Meetup on 2024-05-01 18:30") {
+		t.Fatalf("unexpected invite: %s", file.Data)
+	}
+
+	// Metadata is used unless disabled.
+	item = &gofeed.Item{Title: "Meetup", Extensions: ext.Extensions{"ev": {
+		"startdate": {{Name: "startdate", Value: "2024-05-01T18:30:00Z"}},
+	}}}
+	file = invite(configfile.Feed{}, item, time.UTC)
+	if file == nil || !strings.Contains(string(file.Data), "DTSTART:20240501T183000Z") {
+		t.Fatalf("unexpected invite: %v", file)
+	}
+	if invite(off, item, time.UTC) != nil {
+		t.Fatalf("unexpected invite")
+	}
+}
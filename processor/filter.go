@@ -0,0 +1,79 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/sites"
+	"github.com/skx/rss2email/withstate"
+)
+
+// FilterResult describes what would happen to an item of a feed, given
+// the exclude and include options of that feed.
+type FilterResult struct {
+
+	// Title and Link identify the item.
+	Title string
+	Link  string
+
+	// New is true if the item hasn't been seen.
+	New bool
+
+	// Skipped is true if the item would be filtered out.
+	Skipped bool
+
+	// Rule holds the option which decided whether the item is skipped,
+	// if any.  If the item is skipped because it didn't match any of
+	// the include options this is nil.
+	Rule *configfile.Option
+}
+
+// Filter fetches the given feed, and returns what would happen to each of
+// its items, given the exclude and include options of the feed.
+//
+// Nothing is sent, and the state of the items is not changed, so that the
+// options may be developed against the current contents of the feed.
+func (p *Processor) Filter(ctx context.Context, entry configfile.Feed) ([]FilterResult, error) {
+
+	rules, err := parseItemRules(entry)
+	if err != nil {
+		return nil, err
+	}
+	byLink, err := identityByLink(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	helper, err := p.newFetcher(entry)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := helper.FetchContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []FilterResult
+	for _, xp := range feed.Items {
+
+		// The options match against the item as it would be sent.
+		sites.Enhance(feed, xp, entry.Options)
+		rules.apply(xp)
+		item := withstate.FeedItem{Item: xp, ByLink: byLink}
+
+		content, err := item.HTMLContent()
+		if err != nil {
+			content = item.RawContent()
+		}
+
+		skip, rule := filter(entry, item.Title, content)
+		results = append(results, FilterResult{
+			Title:   item.Title,
+			Link:    item.Link,
+			New:     item.IsNew(),
+			Skipped: skip,
+			Rule:    rule,
+		})
+	}
+	return results, nil
+}
@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// wantIncremental returns true if the given feed has the "incremental"
+// option set to true.
+//
+// Feeds which are fetched incrementally are only fetched if they've
+// changed, and their items which are no newer than the newest item we'd
+// processed are skipped, rather than being considered again.  As that
+// skips new items which are backdated that isn't the default.
+func wantIncremental(config configfile.Feed) bool {
+	return boolOption(config, "incremental", false)
+}
+
+// itemDate returns the date the given item was published, or updated if
+// it wasn't, or the zero time if it is undated.
+func itemDate(xp *gofeed.Item) time.Time {
+
+	if xp.PublishedParsed != nil {
+		return *xp.PublishedParsed
+	}
+	if xp.UpdatedParsed != nil {
+		return *xp.UpdatedParsed
+	}
+	return time.Time{}
+}
+
+// newestItem returns the date of the newest of the given items, or the zero
+// time if none are dated.
+func newestItem(items []*gofeed.Item) time.Time {
+
+	var newest time.Time
+	for _, xp := range items {
+		if t := itemDate(xp); t.After(newest) {
+			newest = t
+		}
+	}
+	return newest
+}
+
+// recentItems returns those of the given items which are newer than the
+// given time, along with any which are undated, and separately those
+// which are older.
+//
+// The items are filtered in place, as their order is kept.
+func recentItems(items []*gofeed.Item, since time.Time) ([]*gofeed.Item, []*gofeed.Item) {
+
+	if since.IsZero() {
+		return items, nil
+	}
+
+	var older []*gofeed.Item
+	recent := items[:0]
+	for _, xp := range items {
+		if t := itemDate(xp); t.IsZero() || t.After(since) {
+			recent = append(recent, xp)
+		} else {
+			older = append(older, xp)
+		}
+	}
+	return recent, older
+}
+
+// touchItems refreshes the seen records of those of the given items which
+// we've seen, so that they aren't pruned whilst we skip them.
+func touchItems(items []*gofeed.Item, byLink bool) {
+
+	for _, xp := range items {
+		item := withstate.FeedItem{Item: xp, ByLink: byLink}
+		if !item.IsNew() {
+			item.RecordSeen()
+		}
+	}
+}
@@ -0,0 +1,212 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestRecentItems(t *testing.T) {
+
+	day := func(n int) *time.Time {
+		t := time.Date(2024, 1, n, 12, 0, 0, 0, time.UTC)
+		return &t
+	}
+
+	items := []*gofeed.Item{
+		{Title: "new", PublishedParsed: day(3)},
+		{Title: "undated"},
+		{Title: "updated", UpdatedParsed: day(2)},
+		{Title: "old", PublishedParsed: day(1), UpdatedParsed: day(3)},
+	}
+
+	// Feeds are only processed incrementally if they ask to be.
+	if wantIncremental(configfile.Feed{}) {
+		t.Fatalf("feeds are incremental by default")
+	}
+	if !wantIncremental(configfile.Feed{Options: []configfile.Option{{Name: "incremental", Value: "true"}}}) {
+		t.Fatalf("feed wasn't incremental")
+	}
+
+	if newestItem(items) != *day(3) {
+		t.Fatalf("unexpected newest item: %s", newestItem(items))
+	}
+	if recent, _ := recentItems(items, time.Time{}); len(recent) != 4 {
+		t.Fatalf("items were skipped without a checkpoint")
+	}
+
+	var titles []string
+	recent, older := recentItems(items, *day(2))
+	for _, xp := range append(recent, older...) {
+		titles = append(titles, xp.Title)
+	}
+	if strings.Join(titles, ",") != "new,undated,updated,old" {
+		t.Fatalf("unexpected items: %v", titles)
+	}
+}
+
+func TestIncremental(t *testing.T) {
+
+	items := `<item><title>First</title><guid>rss2email-incremental-1</guid><pubDate>Mon, 01 Jan 2024 12:00:00 GMT</pubDate></item>`
+	requests := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests++
+		etag := fmt.Sprintf(`"%d"`, len(items))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title><link>https://example.com/</link>%s</channel></rss>`, items)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte(ts.URL+"/rss\n - incremental: true\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	run := func() string {
+		buf := &bytes.Buffer{}
+		p := New()
+		p.out = buf
+		p.SetOutputs([]string{"jsonl"})
+		if errs := p.ProcessFeeds(context.Background(), nil); len(errs) != 0 {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		return buf.String()
+	}
+
+	out := run()
+	if !strings.Contains(out, `"title":"First"`) {
+		t.Fatalf("the item wasn't processed: %s", out)
+	}
+	checkpoint, _ := withstate.FeedCheckpoint(ts.URL + "/rss")
+	if checkpoint.ETag == "" || checkpoint.Title != "Example" || checkpoint.Newest.IsZero() {
+		t.Fatalf("unexpected checkpoint: %v", checkpoint)
+	}
+
+	// The feed is unchanged.
+	out = run()
+	if out != "" || requests != 2 {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	// A new item is added, and the older skipped, even though we've
+	// forgotten we've seen it.
+	items = `<item><title>Second</title><guid>rss2email-incremental-2</guid><pubDate>Tue, 02 Jan 2024 12:00:00 GMT</pubDate></item>` + items
+	configfile.SetStateDirectory(t.TempDir())
+	withstate.SetCheckpoint(ts.URL+"/rss", checkpoint)
+	out = run()
+	if !strings.Contains(out, `"title":"Second"`) || strings.Contains(out, `"title":"First"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
+
+// forgetfulStore is a state store which forgets each item that wasn't
+// recorded as seen since it was last pruned, as though each run were days
+// apart.
+type forgetfulStore struct {
+	withstate.Store
+	seen    map[string]bool
+	touched map[string]bool
+}
+
+// Seen is part of the withstate.Store interface.
+func (f *forgetfulStore) Seen(id string) (bool, error) {
+	return f.seen[id], nil
+}
+
+// MarkSeen is part of the withstate.Store interface.
+func (f *forgetfulStore) MarkSeen(id string, link string) error {
+	f.seen[id] = true
+	f.touched[id] = true
+	return nil
+}
+
+// Prune is part of the withstate.Store interface.
+func (f *forgetfulStore) Prune(age time.Duration) (int, []error) {
+	n := 0
+	for id := range f.seen {
+		if !f.touched[id] {
+			delete(f.seen, id)
+			n++
+		}
+	}
+	f.touched = make(map[string]bool)
+	return n, nil
+}
+
+func TestIncrementalUnchanged(t *testing.T) {
+
+	items := `<item><title>Undated</title><guid>rss2email-unchanged-1</guid></item>`
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		etag := fmt.Sprintf(`"%d"`, len(items))
+		if r.Header.Get("If-None-Match") == etag {
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+		w.Header().Set("ETag", etag)
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title><link>https://example.com/</link>%s</channel></rss>`, items)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+
+	withstate.Use(&forgetfulStore{Store: withstate.NewMemoryStore(), seen: make(map[string]bool), touched: make(map[string]bool)})
+	defer withstate.SetStore("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte(ts.URL+"/rss\n - incremental: true\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	run := func() string {
+		buf := &bytes.Buffer{}
+		p := New()
+		p.out = buf
+		p.SetOutputs([]string{"jsonl"})
+		if errs := p.ProcessFeeds(context.Background(), nil); len(errs) != 0 {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		return buf.String()
+	}
+
+	if out := run(); !strings.Contains(out, `"title":"Undated"`) {
+		t.Fatalf("the item wasn't processed: %s", out)
+	}
+
+	// The feed is unchanged for longer than we remember items, yet
+	// its items aren't forgotten.
+	for i := 0; i < 2; i++ {
+		if out := run(); out != "" {
+			t.Fatalf("unexpected output: %s", out)
+		}
+	}
+
+	// Once it changes only the new item is processed.
+	items = `<item><title>Added</title><guid>rss2email-unchanged-2</guid></item>` + items
+	out := run()
+	if !strings.Contains(out, `"title":"Added"`) || strings.Contains(out, `"title":"Undated"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
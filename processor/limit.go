@@ -0,0 +1,81 @@
+package processor
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// limitedFeed records a feed which had new items which weren't sent, as
+// the maximum number of items per run was reached.
+type limitedFeed struct {
+
+	// entry is the configuration of the feed.
+	entry configfile.Feed
+
+	// sent holds the number of its new items which were sent before
+	// the limit was reached, and held the number which weren't.
+	sent int
+	held int
+}
+
+// limitReached returns true if we've sent as many items as we may during
+// this run, as set via SetMaxItems.
+func (p *Processor) limitReached() bool {
+	return p.maxItems > 0 && p.summary.Sent >= p.maxItems
+}
+
+// reportLimit describes the feeds whose new items weren't sent, as the
+// maximum number of items per run was reached, and emails that to the
+// recipients.
+//
+// The items are left unseen, so they're considered again upon the next
+// run, or may be dealt with by hand.  An error is returned describing
+// them, if there were any.
+func (p *Processor) reportLimit(recipients []string) error {
+
+	if len(p.limited) == 0 {
+		return nil
+	}
+
+	// Those with the most new items are most likely to be at fault.
+	sort.SliceStable(p.limited, func(i, j int) bool {
+		return p.limited[i].sent+p.limited[i].held > p.limited[j].sent+p.limited[j].held
+	})
+
+	held := 0
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The limit of %s per run was reached, so the remaining new items\n", plural(p.maxItems, "item"))
+	fmt.Fprintf(&sb, "weren't sent.  They have been left unseen, and will be considered again\n")
+	fmt.Fprintf(&sb, "upon the next run.\n\n")
+	fmt.Fprintf(&sb, "The following feeds had more new items than expected, which may be\n")
+	fmt.Fprintf(&sb, "because they changed the GUIDs of their existing items:\n\n")
+	for _, l := range p.limited {
+		held += l.held
+		fmt.Fprintf(&sb, "  %s\n", l.entry.Label())
+		if l.entry.Label() != l.entry.URL {
+			fmt.Fprintf(&sb, "    %s\n", l.entry.URL)
+		}
+		fmt.Fprintf(&sb, "    %d sent, %d not sent\n", l.sent, l.held)
+	}
+	fmt.Fprintf(&sb, "\nTo record the items of a feed as seen, without sending them, run:\n\n")
+	fmt.Fprintf(&sb, "    rss2email cron -send=false -only URL\n\n")
+	fmt.Fprintf(&sb, "Or raise the limit, via the -max-items flag, to send them.\n")
+
+	p.message(sb.String())
+
+	err := fmt.Errorf("the limit of %s per run was reached, %s left unseen", plural(p.maxItems, "item"), plural(held, "new item"))
+
+	if !p.send || !p.wantOutput("email") || len(recipients) == 0 {
+		return err
+	}
+
+	subject := fmt.Sprintf("rss2email held back %s", plural(held, "new item"))
+	nerr := p.sendNotice(recipients, subject, sb.String())
+	if nerr != nil {
+		return fmt.Errorf("%s - failed to email the list of feeds: %s", err, nerr)
+	}
+	return err
+}
@@ -0,0 +1,75 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestMaxItems(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/rss\nhttps://example.net/rss\n - name: Storm\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	fixtures := t.TempDir()
+	for name, count := range map[string]int{"example.com_rss.xml": 1, "Storm.xml": 4} {
+		items := ""
+		for i := 1; i <= count; i++ {
+			items += fmt.Sprintf(`<item><title>%s %d</title><guid>%s-%d</guid><pubDate>Mon, 0%d Jan 2024 12:00:00 GMT</pubDate></item>`, name, i, name, i, i)
+		}
+		err = os.WriteFile(filepath.Join(fixtures, name), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>`+items+`</channel></rss>`), 0644)
+		if err != nil {
+			t.Fatalf("failed to write fixture: %s", err)
+		}
+	}
+
+	run := func() (int, Summary) {
+		buf := &bytes.Buffer{}
+		p := New()
+		p.out = buf
+		p.SetOutputs([]string{"jsonl"})
+		p.SetFixtures(fixtures)
+		p.SetMaxItems(3)
+		p.ProcessFeeds(context.Background(), nil)
+		return strings.Count(buf.String(), "\n"), p.Summary()
+	}
+
+	// Only three items are sent, the others are reported.
+	sent, summary := run()
+	if sent != 3 || summary.Sent != 3 || summary.Held != 2 {
+		t.Fatalf("unexpected result: %d %v", sent, summary)
+	}
+	if len(summary.Errors) != 1 || summary.Errors[0].Error() != "the limit of 3 items per run was reached, 2 new items left unseen" {
+		t.Fatalf("unexpected errors: %v", summary.Errors)
+	}
+	if !strings.Contains(summary.Line(), ", 2 held,") {
+		t.Fatalf("unexpected summary: %s", summary.Line())
+	}
+
+	// The feed which reached the limit isn't skipped next time.
+	checkpoint, _ := withstate.FeedCheckpoint("https://example.net/rss")
+	if !checkpoint.Newest.IsZero() {
+		t.Fatalf("unexpected checkpoint: %v", checkpoint)
+	}
+
+	// The items which weren't sent are sent next time.
+	sent, summary = run()
+	if sent != 2 || summary.Held != 0 || len(summary.Errors) != 0 {
+		t.Fatalf("unexpected result: %d %v", sent, summary)
+	}
+}
@@ -0,0 +1,99 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/unshorten"
+)
+
+// maxExpansions is the largest number of links we'll expand within a
+// single item, as each may require several requests.
+const maxExpansions = 50
+
+// wantExpandLinks returns true if the given feed has the "expand-links"
+// option set, so that the shortened links of its items are expanded.
+func wantExpandLinks(config configfile.Feed) bool {
+	return boolOption(config, "expand-links", false)
+}
+
+// expandLinks replaces the shortened links of the given item, its own
+// link and those within its content, with their destinations.
+//
+// Failing to expand a link isn't fatal, it is left unchanged.  Returns
+// true if the item was changed.
+func (p *Processor) expandLinks(xp *gofeed.Item) bool {
+
+	e := unshorten.New()
+	count := 0
+
+	expand := func(link string) string {
+		if !e.Shortened(link) || count >= maxExpansions {
+			return link
+		}
+		count++
+		res, err := e.Expand(link)
+		if err != nil {
+			p.message(fmt.Sprintf("\t\tFailed to expand %s: %s\n", link, err))
+		}
+		return res
+	}
+
+	changed := false
+	if link := expand(xp.Link); link != xp.Link {
+
+		// The item keeps its identity.
+		if xp.GUID == "" {
+			xp.GUID = xp.Link
+		}
+		xp.Link = link
+		changed = true
+	}
+
+	for _, field := range []*string{&xp.Content, &xp.Description} {
+		res := expandHTML(*field, expand)
+		if res != *field {
+			*field = res
+			changed = true
+		}
+	}
+	return changed
+}
+
+// expandHTML returns the given HTML with the links within it replaced via
+// the given function.
+//
+// The HTML is returned unchanged if no link was replaced.
+func expandHTML(content string, expand func(string) string) string {
+
+	if !strings.Contains(content, "href") {
+		return content
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	found := false
+	doc.Find("a[href]").Each(func(i int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		if link := expand(href); link != href {
+			a.SetAttr("href", link)
+			found = true
+		}
+	})
+	if !found {
+		return content
+	}
+
+	// The content was parsed as the body of a document.
+	res, err := doc.Find("body").Html()
+	if err != nil {
+		return content
+	}
+	return res
+}
@@ -0,0 +1,54 @@
+package processor
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestWantExpandLinks ensures the option is parsed.
+func TestWantExpandLinks(t *testing.T) {
+
+	if wantExpandLinks(configfile.Feed{}) {
+		t.Fatalf("links shouldn't be expanded by default")
+	}
+	if !wantExpandLinks(configfile.Feed{Options: []configfile.Option{{Name: "expand-links", Value: "true"}}}) {
+		t.Fatalf("links should be expanded")
+	}
+}
+
+// TestExpandHTML ensures the links within HTML are replaced.
+func TestExpandHTML(t *testing.T) {
+
+	expand := func(link string) string {
+		return strings.Replace(link, "https://t.co/", "https://example.com/", 1)
+	}
+
+	in := `<p>See <a href="https://t.co/abc">this</a>, and <a href="https://example.org/">that</a>.</p>`
+	out := expandHTML(in, expand)
+	if out != `<p>See <a href="https://example.com/abc">this</a>, and <a href="https://example.org/">that</a>.</p>` {
+		t.Fatalf("unexpected result: %s", out)
+	}
+
+	// Content without shortened links is unchanged.
+	in = `<p>Nothing <b>to see</p>`
+	if expandHTML(in, expand) != in {
+		t.Fatalf("content changed")
+	}
+}
+
+// TestExpandLinksNone ensures items without shortened links are unchanged.
+func TestExpandLinksNone(t *testing.T) {
+
+	p := New()
+
+	xp := &gofeed.Item{Link: "https://example.com/post", Content: `<a href="https://example.com/other">link</a>`}
+	if p.expandLinks(xp) {
+		t.Fatalf("item shouldn't have changed")
+	}
+	if xp.GUID != "" {
+		t.Fatalf("unexpected GUID: %s", xp.GUID)
+	}
+}
@@ -0,0 +1,115 @@
+package processor
+
+import (
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// EventType identifies the kind of an Event.
+type EventType string
+
+// The events an Observer receives.
+const (
+
+	// RunStarted is sent once the feeds to process are known, with
+	// their number.
+	RunStarted EventType = "run-started"
+
+	// FeedStarted is sent before a feed is fetched, and FeedFinished
+	// once it has been processed, with the error which stopped us, if
+	// any.
+	FeedStarted  EventType = "feed-started"
+	FeedFinished EventType = "feed-finished"
+
+	// ItemDiscovered is sent for each new item of a feed.
+	ItemDiscovered EventType = "item-discovered"
+
+	// ItemFiltered is sent for each new item which won't be sent, with
+	// the reason why.
+	ItemFiltered EventType = "item-filtered"
+
+	// ItemQueued is sent for each new item which was added to a digest,
+	// or held back until it may be sent.
+	ItemQueued EventType = "item-queued"
+
+	// ItemDelivered is sent for each item once it has been sent to
+	// each of our outputs.
+	ItemDelivered EventType = "item-delivered"
+
+	// Error is sent for each feed which failed, and each item which
+	// couldn't be sent.
+	Error EventType = "error"
+
+	// RunFinished is sent once the run is complete, with its summary,
+	// which holds every error of the run.
+	RunFinished EventType = "run-finished"
+)
+
+// Event describes something which happened whilst processing our feeds.
+//
+// Only the fields which are relevant to the type of the event are set.
+type Event struct {
+
+	// Type is the kind of the event.
+	Type EventType
+
+	// Feed is the configuration of the feed the event concerns.
+	Feed configfile.Feed
+
+	// Item is the item the event concerns, which mustn't be changed.
+	Item *gofeed.Item
+
+	// Reason explains why an item was filtered, or queued.
+	Reason string
+
+	// Err is the error which occurred.
+	Err error
+
+	// Feeds holds the number of feeds which will be processed, for
+	// RunStarted events.
+	Feeds int
+
+	// Summary holds the summary of the run, for RunFinished events.
+	Summary *Summary
+}
+
+// Observer receives the events of a Processor, as they happen.
+//
+// Events are delivered synchronously, from the goroutine processing the
+// feeds, so observers should return promptly, and mustn't use the
+// Processor which sent them.
+type Observer interface {
+	Event(e Event)
+}
+
+// ObserverFunc allows a function to be used as an Observer.
+type ObserverFunc func(e Event)
+
+// Event is part of the Observer interface.
+func (f ObserverFunc) Event(e Event) {
+	f(e)
+}
+
+// AddObserver adds an observer, which receives the events of each run,
+// so that metrics, progress, or hooks, may be attached to us.
+func (p *Processor) AddObserver(o Observer) {
+	p.observers = append(p.observers, o)
+}
+
+// notify sends the given event to each of our observers.
+func (p *Processor) notify(e Event) {
+	for _, o := range p.observers {
+		o.Event(e)
+	}
+}
+
+// filterReason returns the reason the entry with the given title, and
+// content, is skipped by the include and exclude options of the feed.
+func filterReason(config configfile.Feed, title string, content string) string {
+
+	_, rule := filter(config, title, content)
+	if rule == nil {
+		return "it didn't match any include, or include-title, patterns"
+	}
+	return "it matched the " + rule.Name + " pattern " + rule.Value
+}
@@ -0,0 +1,77 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestObserver(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/rss\n - exclude-title: Second\nhttps://example.com/missing\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	fixtures := t.TempDir()
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_rss.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/observed/first</link></item>
+<item><title>Second</title><link>https://example.com/observed/second</link></item>
+</channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+
+	var events []string
+	var last Event
+	p := New()
+	p.out = &bytes.Buffer{}
+	p.SetOutputs([]string{"jsonl"})
+	p.SetFixtures(fixtures)
+	p.AddObserver(ObserverFunc(func(e Event) {
+		s := string(e.Type)
+		if e.Item != nil {
+			s += " " + e.Item.Title
+		}
+		if e.Reason != "" {
+			s += " (" + e.Reason + ")"
+		}
+		if e.Err != nil {
+			s += " !"
+		}
+		events = append(events, s)
+		last = e
+	}))
+	p.ProcessFeeds(context.Background(), nil)
+
+	expected := []string{
+		"run-started",
+		"feed-started",
+		"item-discovered First",
+		"item-delivered First",
+		"item-discovered Second",
+		"item-filtered Second (it matched the exclude-title pattern Second)",
+		"feed-finished",
+		"feed-started",
+		"error !",
+		"feed-finished !",
+		"run-finished",
+	}
+	if strings.Join(events, "\n") != strings.Join(expected, "\n") {
+		t.Fatalf("unexpected events:\n%s", strings.Join(events, "\n"))
+	}
+	if last.Summary == nil || last.Summary.Sent != 1 || len(last.Summary.Errors) != 1 {
+		t.Fatalf("unexpected summary: %v", last.Summary)
+	}
+}
@@ -0,0 +1,173 @@
+package processor
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Outputs holds the names of the outputs which may be used for new
+// items.
+var Outputs = []string{"email", "jsonl", "exec", "feed"}
+
+// ExecFormats holds the formats which may be piped to the command used
+// by the "exec" output.
+var ExecFormats = []string{"message", "json"}
+
+// jsonItem is the structure written for each item by the "jsonl" output.
+type jsonItem struct {
+	Feed       string     `json:"feed"`
+	FeedTitle  string     `json:"feed_title,omitempty"`
+	FeedName   string     `json:"feed_name,omitempty"`
+	Title      string     `json:"title"`
+	Link       string     `json:"link,omitempty"`
+	GUID       string     `json:"guid,omitempty"`
+	Published  *time.Time `json:"published,omitempty"`
+	Updated    *time.Time `json:"updated,omitempty"`
+	Author     string     `json:"author,omitempty"`
+	Categories []string   `json:"categories,omitempty"`
+	Content    string     `json:"content"`
+	Text       string     `json:"text"`
+}
+
+// outputError is returned when an item was emailed, but couldn't be given
+// to one of our other outputs.  The item mustn't be retried, as it would
+// be emailed again.
+type outputError struct {
+	msg string
+}
+
+// Error returns the failures of the outputs.
+func (e *outputError) Error() string {
+	return e.msg
+}
+
+// isOutputError returns true if the given error is an outputError.
+func isOutputError(err error) bool {
+
+	var oerr *outputError
+	return errors.As(err, &oerr)
+}
+
+// wantOutput returns true if the given output is enabled.
+func (p *Processor) wantOutput(name string) bool {
+	for _, output := range p.outputs {
+		if output == name {
+			return true
+		}
+	}
+	return false
+}
+
+// newJSONItem returns the JSON representation of the given item.
+func newJSONItem(entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, text string, content string) jsonItem {
+
+	res := jsonItem{
+		Feed:       entry.URL,
+		FeedTitle:  feed.Title,
+		FeedName:   entry.Name(),
+		Title:      item.Title,
+		Link:       item.Link,
+		GUID:       item.GUID,
+		Published:  item.PublishedParsed,
+		Updated:    item.UpdatedParsed,
+		Categories: item.Categories,
+		Content:    content,
+		Text:       text,
+	}
+	if item.Author != nil {
+		res.Author = item.Author.Name
+	}
+	return res
+}
+
+// writeJSON writes the given item to our output, as a single line of JSON.
+func (p *Processor) writeJSON(entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, text string, content string) error {
+
+	data, err := json.Marshal(newJSONItem(entry, feed, item, text, content))
+	if err != nil {
+		return err
+	}
+
+	_, err = fmt.Fprintf(p.out, "%s\n", data)
+	return err
+}
+
+// execItem pipes the given item to the command configured for the "exec"
+// output, either as a rendered email or as JSON.
+//
+// Details of the item are also available to the command via the
+// environmental variables RSS2EMAIL_FEED, RSS2EMAIL_TITLE, and
+// RSS2EMAIL_LINK.
+func (p *Processor) execItem(helper *emailer.Emailer, entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, recipients []string, text string, content string) error {
+
+	var input []byte
+	var err error
+
+	if p.execFormat == "json" {
+		input, err = json.Marshal(newJSONItem(entry, feed, item, text, content))
+		if err != nil {
+			return err
+		}
+		input = append(input, '\n')
+	} else {
+		msg, err := helper.Render(recipients, text, content)
+		if err != nil {
+			return err
+		}
+		p.checkTemplate(entry, item, msg)
+		input = msg.Content
+	}
+
+	env := []string{
+		"RSS2EMAIL_FEED=" + entry.URL,
+		"RSS2EMAIL_NAME=" + entry.Name(),
+		"RSS2EMAIL_TITLE=" + item.Title,
+		"RSS2EMAIL_LINK=" + item.Link,
+	}
+	return runCommand(p.execCommand, input, env)
+}
+
+// runCommand runs the given command via the shell, with the given input
+// on STDIN and the given additions to the environment.
+//
+// A failing command is an error, which includes anything the command
+// wrote to STDERR.
+func runCommand(command string, input []byte, env []string) error {
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+
+	detach(cmd)
+
+	stderr := &bytes.Buffer{}
+	cmd.Stdin = bytes.NewReader(input)
+	cmd.Stdout = os.Stderr
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), env...)
+
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return fmt.Errorf("command '%s' failed: %s: %s", command, err, msg)
+		}
+		return fmt.Errorf("command '%s' failed: %s", command, err)
+	}
+	return nil
+}
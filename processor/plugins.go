@@ -0,0 +1,102 @@
+package processor
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/plugin"
+)
+
+// pluginCommands returns the commands given by each of the options of the
+// feed with the given name, such as "filter-plugin", in order.
+func pluginCommands(config configfile.Feed, name string) []string {
+
+	var commands []string
+	for _, opt := range config.Options {
+		if opt.Name == name && strings.TrimSpace(opt.Value) != "" {
+			commands = append(commands, strings.TrimSpace(opt.Value))
+		}
+	}
+	return commands
+}
+
+// runFilters gives the given item to each of the filter plugins of the
+// feed, in order, which may change it.  Filters which name a ".wasm" file
+// are run as WASM modules, rather than commands.  If one of them skips the
+// item we return true, and the reason it gave, and the others aren't run.
+func (p *Processor) runFilters(ctx context.Context, f *feedState, xp *gofeed.Item) (bool, string, error) {
+
+	for _, command := range f.filters {
+
+		run := plugin.Run
+		if plugin.IsWASM(command) {
+			run = plugin.RunWASM
+		}
+
+		res, err := run(ctx, command, plugin.Request{
+			Point: plugin.Filter,
+			Feed:  plugin.NewFeed(f.entry, f.feed.Title),
+			Item:  plugin.NewItem(xp),
+		})
+		if err != nil {
+			return false, "", err
+		}
+
+		if res.Skip {
+			reason := res.Reason
+			if reason == "" {
+				reason = fmt.Sprintf("the plugin '%s' skipped it", command)
+			}
+			p.message(fmt.Sprintf("\t\t\tSkipping, as %s.\n", reason))
+			return true, reason, nil
+		}
+		if res.Item != nil {
+			res.Item.Apply(xp)
+		}
+	}
+	return false, "", nil
+}
+
+// runConditions gives the given item to each of the "when" conditions of
+// the feed, in order.  If one of them doesn't accept the item we return
+// true, and the reason, and the others aren't run.
+func (p *Processor) runConditions(ctx context.Context, f *feedState, xp *gofeed.Item) (bool, string, error) {
+
+	for _, command := range f.conditions {
+
+		ok, err := plugin.Check(ctx, command, plugin.Request{
+			Feed: plugin.NewFeed(f.entry, f.feed.Title),
+			Item: plugin.NewItem(xp),
+		})
+		if err != nil {
+			return false, "", err
+		}
+		if !ok {
+			reason := fmt.Sprintf("the condition '%s' wasn't met", command)
+			p.message(fmt.Sprintf("\t\t\tSkipping, as %s.\n", reason))
+			return true, reason, nil
+		}
+	}
+	return false, "", nil
+}
+
+// runOutputs gives the given item, which has been sent, to each of the
+// output plugins of the feed.
+func (p *Processor) runOutputs(ctx context.Context, entry configfile.Feed, feed *gofeed.Feed, xp *gofeed.Item) error {
+
+	for _, command := range pluginCommands(entry, "output-plugin") {
+
+		_, err := plugin.Run(ctx, command, plugin.Request{
+			Point: plugin.Output,
+			Feed:  plugin.NewFeed(entry, feed.Title),
+			Item:  plugin.NewItem(xp),
+		})
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
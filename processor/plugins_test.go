@@ -0,0 +1,184 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestPlugins(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the plugins assume a unix shell")
+	}
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	// The filter skips the second item, and changes the title of the
+	// others, while the output records what it is given.
+	plugins := t.TempDir()
+	filter := filepath.Join(plugins, "filter")
+	err := os.WriteFile(filter, []byte(`#!/bin/sh
+if grep -q Second; then
+  echo '{"skip": true, "reason": "it is second"}'
+else
+  echo '{"item": {"title": "Changed", "content": "<p>Changed</p>"}}'
+fi
+`), 0755)
+	if err != nil {
+		t.Fatalf("failed to write plugin: %s", err)
+	}
+	outputs := filepath.Join(plugins, "outputs")
+
+	config := "https://example.com/plugins\n - filter-plugin: " + filter + "\n - output-plugin: cat >> " + outputs + "\n"
+	err = os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte(config), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	fixtures := t.TempDir()
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_plugins.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/plugins/first</link></item>
+<item><title>Second</title><link>https://example.com/plugins/second</link></item>
+</channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+
+	var reasons []string
+	buf := &bytes.Buffer{}
+	p := New()
+	p.out = buf
+	p.SetOutputs([]string{"jsonl"})
+	p.SetFixtures(fixtures)
+	p.AddObserver(ObserverFunc(func(e Event) {
+		if e.Type == ItemFiltered {
+			reasons = append(reasons, e.Reason)
+		}
+	}))
+
+	// Output plugins aren't run when we're using fixtures.
+	p.ProcessFeeds(context.Background(), nil)
+	summary := p.Summary()
+	if summary.Sent != 1 || summary.Skipped != 1 || len(summary.Errors) != 0 {
+		t.Fatalf("unexpected summary: %s", summary.Line())
+	}
+	if !strings.Contains(buf.String(), `"title":"Changed"`) || !strings.Contains(buf.String(), `"link":"https://example.com/plugins/first"`) {
+		t.Fatalf("item wasn't changed: %s", buf.String())
+	}
+	if strings.Join(reasons, ",") != "it is second" {
+		t.Fatalf("unexpected reasons: %v", reasons)
+	}
+	if _, err = os.Stat(outputs); err == nil {
+		t.Fatalf("output plugin was run with fixtures")
+	}
+
+	// Items are given to the output plugins once sent.
+	item := &gofeed.Item{Title: "Sent", Link: "https://example.com/plugins/sent"}
+	err = p.runOutputs(context.Background(), configfile.Feed{URL: "https://example.com/plugins", Options: []configfile.Option{{Name: "output-plugin", Value: "cat >> " + outputs}}}, &gofeed.Feed{Title: "Example"}, item)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	data, _ := os.ReadFile(outputs)
+	if !strings.Contains(string(data), `"point":"output"`) || !strings.Contains(string(data), `"title":"Sent"`) {
+		t.Fatalf("unexpected output: %s", data)
+	}
+
+	// Failing filters leave the item to be retried.
+	err = os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/plugins\n - filter-plugin: exit 1\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_plugins.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>Third</title><link>https://example.com/plugins/third</link></item>
+</channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+	p.ProcessFeeds(context.Background(), nil)
+	summary = p.Summary()
+	if summary.Sent != 0 || len(summary.Errors) != 1 || !strings.Contains(summary.Errors[0].Error(), "filter plugin 'exit 1' failed") {
+		t.Fatalf("unexpected summary: %s", summary.Line())
+	}
+}
+
+func TestConditions(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the conditions assume a unix shell")
+	}
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	// Only the first item is sent.
+	config := "https://example.com/conditions\n - when: grep -q '\"title\":\"First\"'\n"
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte(config), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	fixtures := t.TempDir()
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_conditions.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/conditions/first</link></item>
+<item><title>Second</title><link>https://example.com/conditions/second</link></item>
+</channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+
+	var reasons []string
+	buf := &bytes.Buffer{}
+	p := New()
+	p.out = buf
+	p.SetOutputs([]string{"jsonl"})
+	p.SetFixtures(fixtures)
+	p.AddObserver(ObserverFunc(func(e Event) {
+		if e.Type == ItemFiltered {
+			reasons = append(reasons, e.Reason)
+		}
+	}))
+
+	p.ProcessFeeds(context.Background(), nil)
+	summary := p.Summary()
+	if summary.Sent != 1 || summary.Skipped != 1 || len(summary.Errors) != 0 {
+		t.Fatalf("unexpected summary: %s", summary.Line())
+	}
+	if !strings.Contains(buf.String(), `"link":"https://example.com/conditions/first"`) {
+		t.Fatalf("wrong item was sent: %s", buf.String())
+	}
+	if strings.Join(reasons, ",") != `the condition 'grep -q '"title":"First"'' wasn't met` {
+		t.Fatalf("unexpected reasons: %v", reasons)
+	}
+
+	// Conditions which can't be run leave the item to be retried.
+	err = os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/conditions\n - when: kill -9 $$\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_conditions.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>Third</title><link>https://example.com/conditions/third</link></item>
+</channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+	p.ProcessFeeds(context.Background(), nil)
+	summary = p.Summary()
+	if summary.Sent != 0 || len(summary.Errors) != 1 || !strings.Contains(summary.Errors[0].Error(), "when plugin 'kill -9 $$' failed") {
+		t.Fatalf("unexpected summary: %s", summary.Line())
+	}
+}
@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"context"
+	"time"
+
+	"github.com/k3a/html2text"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/sites"
+	"github.com/skx/rss2email/withstate"
+)
+
+// PreviewItem describes an item of a feed, as it would be seen by the
+// processor.
+type PreviewItem struct {
+
+	// Title and Link describe the item.
+	Title string
+	Link  string
+
+	// GUID is the value which identifies the item, which is its link if
+	// it has no GUID, or the feed is identified by link.
+	GUID string
+
+	// Text is the content of the item, as plain text.
+	Text string
+
+	// Date is the time the item was published, or updated, which is the
+	// zero time if it is undated.
+	Date time.Time
+
+	// New is true if the item hasn't been seen, so would be sent.
+	New bool
+}
+
+// Preview fetches the given feed, and returns its items, along with
+// whether each would be sent.
+//
+// Nothing is sent, and the state of the items is not changed, so that a
+// feed may be evaluated before it is added.
+func (p *Processor) Preview(ctx context.Context, entry configfile.Feed) ([]PreviewItem, error) {
+
+	rules, err := parseItemRules(entry)
+	if err != nil {
+		return nil, err
+	}
+	byLink, err := identityByLink(entry)
+	if err != nil {
+		return nil, err
+	}
+
+	helper, err := p.newFetcher(entry)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := helper.FetchContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []PreviewItem
+	for _, xp := range feed.Items {
+
+		sites.Enhance(feed, xp, entry.Options)
+		rules.apply(xp)
+		item := withstate.FeedItem{Item: xp, ByLink: byLink}
+
+		content, err := item.HTMLContent()
+		if err != nil {
+			content = item.RawContent()
+		}
+
+		results = append(results, PreviewItem{
+			Title: item.Title,
+			Link:  item.Link,
+			GUID:  item.Identity(),
+			Text:  html2text.HTML2Text(content),
+			Date:  itemDate(xp),
+			New:   item.IsNew(),
+		})
+	}
+	return results, nil
+}
@@ -9,160 +9,1733 @@
 package processor
 
 import (
+	"context"
 	"fmt"
+	"io"
+	"os"
 	"regexp"
+	"strconv"
+	"strings"
+	"time"
 
-	"github.com/k3a/html2text"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/aggregate"
+	"github.com/skx/rss2email/archive"
+	"github.com/skx/rss2email/audit"
 	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/favicon"
+	"github.com/skx/rss2email/fetch"
 	"github.com/skx/rss2email/httpfetch"
+	"github.com/skx/rss2email/logger"
 	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/processor/sites"
+	"github.com/skx/rss2email/reader"
+	"github.com/skx/rss2email/river"
+	"github.com/skx/rss2email/schedule"
+	"github.com/skx/rss2email/tracing"
+	"github.com/skx/rss2email/wayback"
 	"github.com/skx/rss2email/withstate"
+	"go.opentelemetry.io/otel/attribute"
 )
 
+// lockTime is the time for which we lock a feed whilst processing it, if
+// our state is shared by several instances.  Should we die the lock
+// expires after this time.
+//
+// The lock isn't refreshed, so a feed which takes longer than this to
+// process may be processed by another instance at the same time.
+const lockTime = 15 * time.Minute
+
 // Processor stores our state
 type Processor struct {
 
-	// send controls whether we send emails, or just pretend to.
-	send bool
+	// send controls whether we send emails, or just pretend to.
+	send bool
+
+	// logger receives our messages, and those of the components we
+	// use.
+	logger logger.Logger
+
+	// from holds the default address for the From: header of
+	// the emails we generate.
+	from string
+
+	// envelopeFrom holds the default envelope sender of the emails
+	// we generate.
+	envelopeFrom string
+
+	// cc holds the addresses which receive a copy of each email.
+	cc []string
+
+	// bcc holds the addresses which receive a blind copy of each email.
+	bcc []string
+
+	// maxSize holds the maximum size of an email body, in bytes.
+	maxSize int
+
+	// style holds the name of the embedded template to use.
+	style string
+
+	// timezone and dateFormat hold the time zone, and layout, of
+	// the dates shown within our emails.
+	timezone   string
+	dateFormat string
+
+	// favicon controls whether we embed the icon of each feed
+	// within the emails we send.
+	favicon bool
+
+	// unread controls whether we process the unread items of a
+	// feed-reader, rather than fetching its subscriptions.
+	unread bool
+
+	// archive controls whether we store the items we process within
+	// our archive.
+	archive bool
+
+	// db is the archive, while we're processing feeds.
+	db *archive.Archive
+
+	// htmlArchive holds the directory to which we write a static
+	// HTML archive of the items we send, if any.
+	htmlArchive string
+
+	// river writes that archive, while we're processing feeds.
+	river *river.River
+
+	// outputs holds the names of the outputs new items are sent to.
+	outputs []string
+
+	// feedFile holds the path of the feed written by the "feed" output,
+	// and feedItems the number of items it holds.
+	feedFile  string
+	feedItems int
+
+	// aggregate is that feed, while we're processing feeds.
+	aggregate *aggregate.Feed
+
+	// out is where the "jsonl" output is written.
+	out io.Writer
+
+	// execCommand holds the command used by the "exec" output.
+	execCommand string
+
+	// execFormat holds the format of the input to that command,
+	// "message" or "json".
+	execFormat string
+
+	// summary holds the details of the most recent run.
+	summary Summary
+
+	// templateErrors holds the first failure of the template of each
+	// feed during the current run, and templateFailed the URLs of
+	// those feeds.
+	templateErrors []error
+	templateFailed map[string]bool
+
+	// only holds the URLs, or names, of the feeds to process, if
+	// we're not processing all of them.
+	only []string
+
+	// lastRun holds the time of our previous run, in daemon mode, which
+	// is used to decide which feeds with a "cron" option are due.
+	lastRun time.Time
+
+	// unscheduled is set if we skipped feeds which weren't due.
+	unscheduled bool
+
+	// window holds the default delivery window, "HH:MM-HH:MM", if
+	// emails should only be sent at particular times of day.
+	window string
+
+	// timeout holds the time within which a run must complete, if
+	// any, after which the remaining feeds are skipped.
+	timeout time.Duration
+
+	// archiveAfter holds the time after which feeds which are probably
+	// dead are archived, if they should be.
+	archiveAfter time.Duration
+
+	// cacheItems holds the time for which we keep the raw content of
+	// the items we send, if we do.
+	cacheItems time.Duration
+
+	// fixtures holds the directory of the fixtures used rather than
+	// fetching feeds, and recorder records the emails we'd send, if
+	// we're being tested.
+	fixtures string
+	recorder *emailer.Recorder
+
+	// maxItems holds the largest number of items we'll send during a
+	// single run, if non-zero, and limited the feeds whose items we
+	// didn't send once it was reached.
+	maxItems int
+	limited  []limitedFeed
+
+	// rotating holds the feeds which repeatedly published items we'd
+	// seen under new GUIDs during this run.
+	rotating []rotatingFeed
+
+	// observers receive the events of each run.
+	observers []Observer
+}
+
+// New creates a new Processor object
+func New() *Processor {
+	return &Processor{send: true, outputs: []string{"email"}, out: os.Stdout, execFormat: "message", logger: logger.Discard}
+}
+
+// ProcessFeeds is the main workhorse here, we process each feed and send
+// emails appropriately.
+//
+// If the given context is cancelled we stop picking up new items, but
+// the items which are already being processed are completed.  The same
+// is true if the run doesn't complete within the timeout, if one is set.
+func (p *Processor) ProcessFeeds(ctx context.Context, recipients []string) []error {
+
+	p.summary = Summary{Started: time.Now()}
+	p.templateErrors = nil
+	p.limited = nil
+	p.rotating = nil
+	p.templateFailed = make(map[string]bool)
+
+	if p.timeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, p.timeout)
+		defer cancel()
+	}
+
+	ctx, span := tracing.Start(ctx, "run")
+
+	errors := p.processFeeds(ctx, recipients)
+
+	// Items whose template failed were sent, so these are reported
+	// once for each feed.
+	errors = append(errors, p.templateErrors...)
+
+	p.summary.Duration = time.Since(p.summary.Started)
+	p.summary.Errors = errors
+	p.summary.Interrupted = ctx.Err() != nil
+	p.summary.TimedOut = ctx.Err() == context.DeadlineExceeded
+
+	span.SetAttributes(
+		attribute.Int("run.feeds", p.summary.Feeds),
+		attribute.Int("run.items", p.summary.Items),
+		attribute.Int("run.sent", p.summary.Sent),
+		attribute.Int("run.errors", len(errors)),
+	)
+	span.End()
+
+	summary := p.summary
+	p.notify(Event{Type: RunFinished, Summary: &summary})
+
+	return errors
+}
+
+// Summary returns the details of the most recent run.
+func (p *Processor) Summary() Summary {
+	return p.summary
+}
+
+// processFeeds processes each of our feeds, returning any errors.
+func (p *Processor) processFeeds(ctx context.Context, recipients []string) []error {
+
+	//
+	// If we receive errors we'll store them here,
+	// so we can keep processing subsequent URIs.
+	//
+	var errors []error
+
+	// Get the configuration-file
+	conf := configfile.New()
+
+	// Upgrade it if necessary
+	conf.Upgrade()
+
+	// Now do the parsing
+	entries, err := conf.Parse()
+	if err != nil {
+		errors = append(errors, fmt.Errorf("error with config-file %s - %s", conf.Path(), err))
+		return errors
+	}
+
+	// Problems which don't prevent us from running, such as unknown
+	// options, are reported but otherwise ignored.
+	for _, w := range conf.Warnings() {
+		p.logger.Named("process").Warnf("warning: %s:%d: %s", conf.Path(), w.Line, w.Msg)
+	}
+
+	// Templates are parsed afresh upon each run, so that changes
+	// to them are noticed.
+	emailer.ResetTemplates()
+
+	// Open the archive, if we should.
+	if p.archive {
+		p.db, err = archive.Open(archive.Path())
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error opening archive %s - %s", archive.Path(), err))
+			return errors
+		}
+		defer func() {
+			p.db.Close()
+			p.db = nil
+		}()
+	}
+
+	// Prepare the HTML archive, if we should.
+	if p.htmlArchive != "" {
+		p.river = river.New(p.htmlArchive)
+		defer func() {
+			p.river = nil
+		}()
+	}
+
+	// Open the aggregated feed, if we should.
+	if p.wantOutput("feed") {
+		p.aggregate, err = aggregate.Open(p.FeedFile(), p.feedItems)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error opening feed %s - %s", p.FeedFile(), err))
+			return errors
+		}
+		defer func() {
+			p.aggregate = nil
+		}()
+	}
+
+	// Add the subscriptions of a feed-reader, if one is configured.
+	src, err := reader.New()
+	if err != nil {
+		errors = append(errors, err)
+		return errors
+	}
+	if src != nil {
+		entries, err = p.processReader(ctx, src, entries, recipients)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error with %s - %s", src.Name(), err))
+		}
+	}
+
+	// Restrict ourselves to the requested feeds, if any.
+	if len(p.only) > 0 {
+		entries, err = p.selectFeeds(entries)
+		if err != nil {
+			errors = append(errors, err)
+			return errors
+		}
+	}
+
+	// Archived feeds aren't fetched.
+	entries = p.activeFeeds(entries)
+
+	// Skip feeds which aren't yet due, if they have a schedule.
+	entries, errs := p.scheduledFeeds(entries, time.Now())
+	errors = append(errors, errs...)
+
+	// Load the template of each feed, so that any which are broken
+	// are reported before we start.
+	if p.send && p.rendersEmail() {
+		p.prepareTemplates(entries)
+	}
+
+	// For each feed-item contained in the feed
+	processed := 0
+	p.summary.Total = len(entries)
+	p.notify(Event{Type: RunStarted, Feeds: len(entries)})
+	for _, entry := range entries {
+
+		// Stop if we've been interrupted.
+		if ctx.Err() != nil {
+			break
+		}
+
+		// If our state is shared only a single instance may
+		// process each feed at a time.
+		locked, err := withstate.Lock("feed:"+entry.URL, lockTime)
+		if err != nil {
+			err = fmt.Errorf("error locking %s - %s", entry.Label(), err)
+			errors = append(errors, err)
+			p.notify(Event{Type: Error, Feed: entry, Err: err})
+			processed++
+			continue
+		}
+		if !locked {
+			p.message(fmt.Sprintf("Skipping %s, which is being processed by another instance", entry.Label()))
+			processed++
+			continue
+		}
+
+		// Process this specific entry.
+		p.notify(Event{Type: FeedStarted, Feed: entry})
+		err = p.processFeed(ctx, entry, recipients)
+		if err != nil {
+			err = fmt.Errorf("error processing %s - %s", entry.Label(), err)
+			errors = append(errors, err)
+			p.notify(Event{Type: Error, Feed: entry, Err: err})
+		}
+		p.notify(Event{Type: FeedFinished, Feed: entry, Err: err})
+		err = withstate.Unlock("feed:" + entry.URL)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error unlocking %s - %s", entry.Label(), err))
+		}
+		processed++
+		p.summary.Feeds++
+	}
+
+	// Report the items we didn't send, if we reached our limit.
+	err = p.reportLimit(recipients)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	// Identify the items of feeds which change their GUIDs by their
+	// links instead.
+	err = p.switchIdentities(conf, recipients)
+	if err != nil {
+		errors = append(errors, err)
+	}
+
+	// Send any digests which are due.
+	if p.send && ctx.Err() == nil {
+		errors = append(errors, p.sendDigests(ctx, entries, recipients, time.Now())...)
+	}
+
+	// Update the pages of the HTML archive.
+	if p.river != nil {
+		err = p.river.Write()
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error writing HTML archive %s - %s", p.htmlArchive, err))
+		}
+	}
+
+	// Update the aggregated feed.
+	if p.aggregate != nil {
+		err = p.aggregate.Write()
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error writing feed %s - %s", p.FeedFile(), err))
+		}
+	}
+
+	// If we ran out of time then report the feeds we didn't reach,
+	// so that a slow feed doesn't silently starve the others.
+	if ctx.Err() == context.DeadlineExceeded {
+		var skipped []string
+		for _, entry := range entries[processed:] {
+			skipped = append(skipped, entry.Label())
+		}
+		err = fmt.Errorf("run deadline of %s exceeded, after processing %d of %d feeds", p.timeout, processed, len(entries))
+		if len(skipped) > 0 {
+			err = fmt.Errorf("%s - skipped %s", err, strings.Join(skipped, ", "))
+		}
+		errors = append(errors, err)
+		return errors
+	}
+
+	// If we were interrupted then we've not refreshed the state of
+	// all our feeds, so we mustn't prune.
+	if ctx.Err() != nil {
+		errors = append(errors, fmt.Errorf("interrupted, after processing %d of %d feeds", processed, len(entries)))
+		return errors
+	}
+
+	// Likewise if we've only processed some of our feeds.
+	if len(p.only) > 0 || p.unscheduled {
+		return errors
+	}
+
+	// Prune old state files
+	prunedCount, pruneErrors := withstate.PruneStateFiles()
+
+	// If we got any errors propagate them
+	errors = append(errors, pruneErrors...)
+
+	// Show what we did, if we should
+	if prunedCount > 0 {
+		p.message(fmt.Sprintf("Pruned %d entry state files\n", prunedCount))
+	}
+
+	// Archive the feeds which are probably dead, if we should.  A
+	// remote list of feeds must be edited at its source.
+	if p.archiveAfter > 0 && configfile.Remote() == "" {
+		err = p.archiveDead(conf, entries, recipients, time.Now())
+		if err != nil {
+			errors = append(errors, err)
+		}
+	}
+
+	return errors
+}
+
+// selectFeeds returns the entries which match the feeds we've been asked
+// to process, via SetOnly.
+//
+// It is an error if any of those feeds cannot be found.
+func (p *Processor) selectFeeds(entries []configfile.Feed) ([]configfile.Feed, error) {
+
+	var selected []configfile.Feed
+	seen := make(map[string]bool)
+
+	for _, query := range p.only {
+		found := configfile.Find(entries, query)
+		if len(found) == 0 {
+			return nil, fmt.Errorf("no feed matches '%s'", query)
+		}
+		for _, entry := range found {
+			if !seen[entry.URL] {
+				seen[entry.URL] = true
+				selected = append(selected, entry)
+			}
+		}
+	}
+	return selected, nil
+}
+
+// rendersEmail returns true if our outputs require emails to be generated.
+func (p *Processor) rendersEmail() bool {
+	return p.wantOutput("email") || (p.wantOutput("exec") && p.execFormat != "json")
+}
+
+// prepareTemplates loads, and caches, the email template of each of the
+// given entries, reporting those which are broken.
+//
+// Feeds may have their own template, via the "template" option, so that
+// podcasts may use a different layout to news, for example.  The emails
+// of feeds whose template is broken use the built-in template instead.
+func (p *Processor) prepareTemplates(entries []configfile.Feed) {
+
+	for _, entry := range entries {
+		err := emailer.PrepareTemplate(entry.Options, p.style)
+		if err != nil {
+			p.recordTemplateError(entry, fmt.Errorf("error loading template for %s, the built-in template will be used - %s", entry.Label(), err))
+		}
+	}
+}
+
+// recordTemplateError records the failure of the template of the given
+// feed, if it is the first during this run.
+func (p *Processor) recordTemplateError(entry configfile.Feed, err error) {
+
+	if p.templateFailed == nil {
+		p.templateFailed = make(map[string]bool)
+	}
+	if !p.templateFailed[entry.URL] {
+		p.templateFailed[entry.URL] = true
+		p.templateErrors = append(p.templateErrors, err)
+	}
+}
+
+// checkTemplate reports the failure of the template used to render the
+// given item, if it failed, in which case the built-in template was used.
+func (p *Processor) checkTemplate(entry configfile.Feed, item withstate.FeedItem, msg *emailer.Message) {
+
+	if msg.TemplateError == nil {
+		return
+	}
+	p.message(fmt.Sprintf("\t\t\tTemplate failed, using the built-in template: %s\n", msg.TemplateError))
+	p.recordTemplateError(entry, fmt.Errorf("error rendering %s with the template of %s, the built-in template was used - %s", item.Link, entry.Label(), msg.TemplateError))
+}
+
+// scheduledFeeds returns the entries which should be processed now,
+// removing those whose "cron" option didn't fire since our last run.
+//
+// If we've no record of a previous run, as is the case when we're invoked
+// via cron, all feeds are processed.
+func (p *Processor) scheduledFeeds(entries []configfile.Feed, now time.Time) ([]configfile.Feed, []error) {
+
+	if p.lastRun.IsZero() {
+		return entries, nil
+	}
+
+	var selected []configfile.Feed
+	var errors []error
+
+	for _, entry := range entries {
+
+		expr := ""
+		for _, opt := range entry.Options {
+			if opt.Name == "cron" {
+				expr = opt.Value
+			}
+		}
+		if expr == "" {
+			selected = append(selected, entry)
+			continue
+		}
+
+		sched, err := schedule.Parse(expr)
+		if err != nil {
+			errors = append(errors, fmt.Errorf("error processing %s - %s", entry.Label(), err))
+			p.unscheduled = true
+			continue
+		}
+		if !sched.Due(p.lastRun, now) {
+			p.message(fmt.Sprintf("Skipping %s, not scheduled until %s", entry.Label(), sched.Next(now).Format(time.RFC1123)))
+			p.unscheduled = true
+			continue
+		}
+		selected = append(selected, entry)
+	}
+	return selected, errors
+}
+
+// message shows a message, if the "process" component of our logger is
+// verbose.
+func (p *Processor) message(msg string) {
+	p.logger.Named("process").Infof("%s", msg)
+}
+
+// sendNotice emails the given notice, which isn't associated with any feed
+// item, such as the list of feeds we've archived.  If we're being tested
+// the notice is given to our recorder rather than being sent.
+func (p *Processor) sendNotice(recipients []string, subject string, body string) error {
+
+	if p.recorder != nil {
+		helper := &emailer.Emailer{}
+		helper.SetRecorder(p.recorder)
+		return helper.Notify(p.from, recipients, subject, body)
+	}
+	return emailer.Notify(p.from, recipients, subject, body)
+}
+
+// processFeed takes a configuration entry as input, fetches the appropriate
+// remote contents, and then processes each feed item found within it.
+//
+// Feed items which are new/unread will generate an email, unless they are
+// specifically excluded by the per-feed options.
+func (p *Processor) processFeed(ctx context.Context, entry configfile.Feed, recipients []string) (err error) {
+
+	ctx, span := tracing.Start(ctx, "feed",
+		attribute.String("feed.url", entry.URL),
+		attribute.String("feed.name", entry.Name()))
+	defer func() { tracing.End(span, err) }()
+
+	// Show what we're doing.
+	p.message(fmt.Sprintf("Fetching feed: %s\n", entry.Label()))
+
+	// Find how far we got with the feed, so that it is only fetched
+	// if it has changed, and we skip the items we've processed.
+	incremental := wantIncremental(entry)
+	var checkpoint withstate.Checkpoint
+	if incremental {
+		checkpoint, err = withstate.FeedCheckpoint(entry.URL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Fetch the feed for the input URL
+	started := time.Now()
+	helper, err := p.newFetcher(entry)
+	if err != nil {
+		return err
+	}
+	helper.SetValidators(httpfetch.Validators{ETag: checkpoint.ETag, LastModified: checkpoint.LastModified})
+	feed, err := helper.FetchContext(ctx)
+	fetch := withstate.Fetch{Latency: time.Since(started), Err: err}
+
+	// Record the health of the feed, unless we were interrupted, which
+	// isn't the fault of the feed.
+	defer func() {
+		if ctx.Err() == nil {
+			if herr := withstate.RecordFetch(entry.URL, fetch); herr != nil && err == nil {
+				err = herr
+			}
+		}
+	}()
+
+	if err != nil {
+		return err
+	}
+
+	if helper.NotModified() {
+		p.message("\tFeed is unchanged since it was last fetched\n")
+		feed.Title = checkpoint.Title
+		feed.Link = checkpoint.Link
+
+		// We receive none of its items, so keep the records of
+		// those we've seen from being pruned.
+		err = withstate.RefreshFeedItems(entry.URL)
+		if err != nil {
+			return err
+		}
+	} else {
+		p.message(fmt.Sprintf("\tFeed contains %d entries\n", len(feed.Items)))
+	}
+	if n := helper.BogusDates(); n > 0 {
+		p.message(fmt.Sprintf("\tWarning: ignored the implausible dates of %s\n", plural(n, "item")))
+	}
+
+	for _, item := range feed.Items {
+		for _, t := range []*time.Time{item.PublishedParsed, item.UpdatedParsed} {
+			if t != nil && t.After(fetch.Newest) {
+				fetch.Newest = *t
+			}
+		}
+	}
+
+	// Skip the items which are no newer than those we've processed,
+	// though we still refresh the records of those we've seen, so
+	// that they aren't pruned.
+	newest := newestItem(feed.Items)
+	all := append([]*gofeed.Item(nil), feed.Items...)
+	if incremental {
+		var older []*gofeed.Item
+		feed.Items, older = recentItems(feed.Items, checkpoint.Newest)
+		if len(older) > 0 {
+			p.message(fmt.Sprintf("\tSkipping %s no newer than those already processed\n", plural(len(older), "item")))
+			for _, xp := range older {
+				p.message(fmt.Sprintf("\t\tSkipping item, it is no newer than those already processed: %s\n", xp.Title))
+			}
+			byLink, _ := identityByLink(entry)
+			touchItems(older, byLink)
+		}
+	}
+
+	items := p.summary.Items
+	held := p.summary.Held
+	err = p.processItems(ctx, entry, feed, recipients)
+	fetch.NewItems = p.summary.Items - items
+	if err != nil || ctx.Err() != nil || !incremental || p.summary.Held > held {
+		return err
+	}
+
+	// Only once every item was processed may we skip them in the
+	// future, otherwise those which failed, or weren't sent as we
+	// reached our limit, wouldn't be considered again.
+	validators := helper.Validators()
+	checkpoint.ETag = validators.ETag
+	checkpoint.LastModified = validators.LastModified
+	if !helper.NotModified() {
+		checkpoint.Title = feed.Title
+		checkpoint.Link = feed.Link
+	}
+	if newest.After(checkpoint.Newest) {
+		checkpoint.Newest = newest
+	}
+	if !helper.NotModified() {
+		byLink, _ := identityByLink(entry)
+		err = withstate.RecordFeedItems(entry.URL, all, byLink)
+		if err != nil {
+			return err
+		}
+	}
+	return withstate.SetCheckpoint(entry.URL, checkpoint)
+}
+
+// processReader handles the feeds of a feed-reader, returning the list of
+// entries which should then be fetched.
+//
+// Normally the subscriptions of the reader are added to the given entries,
+// so that they are fetched as if they were present in the configuration
+// file.  If we're processing unread items then those items are processed
+// here, instead, and the subscriptions are removed from the entries.
+//
+// Options set for a feed in the configuration file are used in either case.
+func (p *Processor) processReader(ctx context.Context, src reader.Source, entries []configfile.Feed, recipients []string) ([]configfile.Feed, error) {
+
+	p.message(fmt.Sprintf("Fetching subscriptions from %s\n", src.Name()))
+
+	subs, err := src.Subscriptions()
+	if err != nil {
+		return entries, err
+	}
+
+	// Find the options of each subscription.
+	subscribed := make(map[string]configfile.Feed)
+	for _, sub := range subs {
+		subscribed[sub] = configfile.Feed{URL: sub}
+	}
+
+	var local []configfile.Feed
+	for _, entry := range entries {
+		if _, ok := subscribed[entry.URL]; ok {
+			subscribed[entry.URL] = entry
+		} else {
+			local = append(local, entry)
+		}
+	}
+
+	// If we're not processing unread items then the subscriptions
+	// are just fetched as normal.
+	if !p.unread {
+		for _, sub := range subs {
+			local = append(local, subscribed[sub])
+		}
+		return local, nil
+	}
+
+	p.message(fmt.Sprintf("Fetching unread items from %s\n", src.Name()))
+
+	streams, err := src.Unread()
+	if err != nil {
+		return local, err
+	}
+
+	for _, stream := range streams {
+
+		entry, ok := subscribed[stream.URL]
+		if !ok {
+			entry = configfile.Feed{URL: stream.URL}
+		}
+
+		p.message(fmt.Sprintf("Processing unread items of %s\n", entry.Label()))
+
+		err = p.processItems(ctx, entry, stream.Feed, recipients)
+		if err != nil {
+			return local, fmt.Errorf("error processing %s - %s", entry.Label(), err)
+		}
+
+		// If we were interrupted then not all of the items were
+		// processed, so we can't mark them as read.
+		if ctx.Err() != nil {
+			return local, nil
+		}
+
+		// Only mark items as read if we're really sending emails.
+		if p.send {
+			err = src.MarkRead(stream.IDs)
+			if err != nil {
+				return local, err
+			}
+		}
+	}
+
+	return local, nil
+}
+
+// processItems processes each of the items of the given feed, which was
+// retrieved for the given configuration entry.
+//
+// If the context is cancelled we stop processing, leaving the remaining
+// items to be processed upon the next run.
+func (p *Processor) processItems(ctx context.Context, entry configfile.Feed, feed *gofeed.Feed, recipients []string) error {
+
+	var err error
+
+	f := &feedState{entry: entry, feed: feed, recipients: recipients}
+	sent := p.summary.Sent
+
+	f.paused = IsPaused(entry)
+	if f.paused {
+		p.message("\tFeed is paused, new items will not be sent\n")
+	}
+
+	// The items of a feed which has been restored, after it was
+	// archived, are recorded as seen, as the state of its items may have
+	// been pruned while it wasn't fetched.
+	catchUp, err := withstate.CatchUp(entry.URL)
+	if err != nil {
+		return err
+	}
+	if catchUp && !f.paused {
+		p.message("\tFeed was restored, existing items will not be sent\n")
+		f.paused = true
+	}
+
+	// Fetch the icon of the feed, if we should.
+	//
+	// Failure isn't fatal, we'll just send emails without it.
+	if p.wantFavicon(entry) {
+		f.icon, err = favicon.New().Get(feed)
+		if err != nil {
+			p.message(fmt.Sprintf("\tFailed to fetch icon: %s\n", err))
+		}
+	}
+
+	// Find the window within which we may deliver emails, if any.
+	f.window, err = p.deliveryWindow(entry)
+	if err != nil {
+		return err
+	}
+	// Find the minimum time between emails, if any.
+	f.gap, err = minGap(entry)
+	if err != nil {
+		return err
+	}
+
+	// Find the digest the items of this feed are added to, if any.
+	f.digest, _ = digestQueue(entry)
+
+	// Find the changes made to its items, if any.
+	f.rules, err = parseItemRules(entry)
+	if err != nil {
+		return err
+	}
+	f.advisories = wantAdvisories(entry)
+	f.expand = wantExpandLinks(entry)
+	f.wayback = wantWayback(entry)
+	f.translate = translateTarget(entry)
+	f.summarise, err = summariseWords(entry)
+	if err != nil {
+		return err
+	}
+	f.dedupe, err = dedupeWindow(entry)
+	if err != nil {
+		return err
+	}
+	f.byLink, err = identityByLink(entry)
+	if err != nil {
+		return err
+	}
+	f.filters = pluginCommands(entry, "filter-plugin")
+	f.conditions = pluginCommands(entry, "when")
+
+	// Find the items we've seen, to recognise those whose GUIDs have
+	// changed, unless they're identified by their links.
+	if !f.byLink {
+		f.prints, err = withstate.FeedFingerprints(entry.URL)
+		if err != nil {
+			return err
+		}
+	}
+
+	// If we can't send emails now, find when we can.
+	f.until = holdUntil(entry.URL, f.window, f.gap)
+
+	// Send any items we held back, now that we may.
+	if p.send && !f.paused && f.until.IsZero() {
+		sent, err := p.sendQueued(ctx, entry, f.icon, recipients, f.gap > 0)
+		if err != nil {
+			return err
+		}
+		if sent && f.gap > 0 {
+			f.until = holdUntil(entry.URL, f.window, f.gap)
+		}
+	}
+
+	// For each entry in the feed ..
+	for _, xp := range feed.Items {
+
+		// Stop if we've been interrupted.
+		if ctx.Err() != nil {
+			p.message("\tInterrupted, skipping remaining entries\n")
+			return nil
+		}
+
+		err = p.processItemSafely(ctx, f, xp)
+		if err != nil {
+			return err
+		}
+	}
+
+	err = p.checkRotation(f)
+	if err != nil {
+		return err
+	}
+
+	if f.held > 0 {
+		p.limited = append(p.limited, limitedFeed{entry: entry, sent: p.summary.Sent - sent, held: f.held})
+	}
+
+	if catchUp {
+		err = withstate.SetCatchUp(entry.URL, false)
+		if err != nil {
+			return err
+		}
+	}
+
+	// Forget the content of the items we sent long ago.
+	if p.cacheItems > 0 {
+		_, err = withstate.PruneCache(entry.URL, p.cacheItems)
+		if err != nil {
+			p.message(fmt.Sprintf("\tFailed to prune cached items: %s\n", err))
+		}
+	}
+
+	var problems []string
+	if len(f.rejected) > 0 {
+		problems = append(problems, fmt.Sprintf("%s permanently rejected: %s", plural(len(f.rejected), "item"), strings.Join(f.rejected, ", ")))
+	}
+	if len(f.failed) > 0 {
+		problems = append(problems, fmt.Sprintf("%s failed, and will be retried: %s", plural(len(f.failed), "item"), strings.Join(f.failed, ", ")))
+	}
+	if len(f.incomplete) > 0 {
+		problems = append(problems, fmt.Sprintf("%s emailed, but not output, and won't be retried: %s", plural(len(f.incomplete), "item"), strings.Join(f.incomplete, ", ")))
+	}
+	if len(problems) > 0 {
+		return fmt.Errorf("%s", strings.Join(problems, "; "))
+	}
+	return nil
+}
+
+// feedState holds the details of a feed, and its settings, which are
+// shared by the processing of each of its items.
+type feedState struct {
+
+	// entry is the configuration of the feed, and feed its content.
+	entry configfile.Feed
+	feed  *gofeed.Feed
+
+	// recipients holds the default recipients of emails.
+	recipients []string
+
+	// icon is the icon of the feed, if we're embedding it.
+	icon *favicon.Icon
+
+	// paused is true if the feed is paused.
+	paused bool
+
+	// window and gap restrict when emails may be sent, and until
+	// holds the time before which they must be held back.
+	window *Window
+	gap    time.Duration
+	until  time.Time
+
+	// digest is the queue items are added to, if the feed has a
+	// digest.
+	digest string
+
+	// rules holds the changes made to each item, filters the
+	// commands of the filter plugins each item is given to, and
+	// conditions the commands which must accept each item.
+	rules      *itemRules
+	filters    []string
+	conditions []string
+
+	// advisories is true if we link the advisories each item
+	// mentions.
+	advisories bool
+
+	// expand is true if we expand the shortened links of each item.
+	expand bool
+
+	// wayback is true if we archive the link of each item.
+	wayback bool
+
+	// translate holds the language we translate each item to, if
+	// any.
+	translate string
+
+	// summarise holds the number of words above which we summarise
+	// items, or -1 if we don't.
+	summarise int
+
+	// dedupe is the time for which we skip items whose titles are like
+	// those of items we delivered, if any.
+	dedupe time.Duration
+
+	// byLink is true if items are identified by their links, rather
+	// than their GUIDs.  Otherwise prints holds the fingerprints of
+	// the items we've seen, and rotated the number of items seen again
+	// under new GUIDs.
+	byLink  bool
+	prints  *withstate.Fingerprints
+	rotated int
+
+	// rejected holds the items which were permanently rejected by
+	// the MTA, and failed those which couldn't be processed.
+	rejected []string
+	failed   []string
+
+	// incomplete holds the items which were emailed, but couldn't
+	// be given to every other output.
+	incomplete []string
+
+	// held holds the number of new items which weren't sent, as we
+	// reached the maximum number of items per run.
+	held int
+}
+
+// processItemSafely processes a single item, recovering from any panic,
+// so that a single broken item can't prevent the remaining items of the
+// feed from being delivered.
+//
+// Items which fail aren't recorded as seen, so they'll be tried again
+// upon the next run.  Errors are only returned if processing of the
+// feed should stop.
+func (p *Processor) processItemSafely(ctx context.Context, f *feedState, xp *gofeed.Item) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			label := "item"
+			if xp != nil {
+				label = xp.Link
+			}
+			p.message(fmt.Sprintf("\t\tFailed to process %s: %v\n", label, r))
+			f.failed = append(f.failed, fmt.Sprintf("%s (panic: %v)", label, r))
+			p.notify(Event{Type: Error, Feed: f.entry, Item: xp, Err: fmt.Errorf("panic: %v", r)})
+			err = nil
+		}
+	}()
+
+	return p.processItem(ctx, f, xp)
+}
+
+// processItem processes a single item of a feed, sending it if it is new.
+func (p *Processor) processItem(ctx context.Context, f *feedState, xp *gofeed.Item) error {
+
+	entry := f.entry
+	feed := f.feed
+
+	// Keep the item as it was fetched, in case we cache it.
+	raw := *xp
+
+	// Apply any site-specific handling, for example
+	// to populate the content of YouTube items.
+	sites.Enhance(feed, xp, entry.Options)
+
+	// Then any changes made by the options of the feed.
+	f.rules.apply(xp)
+
+	// Wrap the feed-item in a class of our own,
+	// so that we can use our helper methods to mark
+	// read-state.
+	item := withstate.FeedItem{Item: xp, ByLink: f.byLink}
+
+	// Skip items we've seen, whose GUIDs have changed.
+	if f.prints != nil && item.IsNew() && f.prints.Rotated(&item) {
+		p.message(fmt.Sprintf("\t\tSkipping item, it was seen before with another GUID: %s\n", item.Title))
+		f.rotated++
+		f.prints.Add(&item)
+		item.RecordSeen()
+		return nil
+	}
+
+	// If we've not already notified about this one.
+	if item.IsNew() {
+
+		// Show the new item.
+		p.message(fmt.Sprintf("\t\tFeed entry: %s\n", item.Title))
+		p.summary.Items++
+		p.notify(Event{Type: ItemDiscovered, Feed: entry, Item: xp})
+
+		// Items of paused feeds are recorded as seen, but
+		// not sent, so there's no flood when resumed.
+		if f.paused {
+			p.summary.Skipped++
+			p.notify(Event{Type: ItemFiltered, Feed: entry, Item: xp, Reason: "the feed is paused"})
+		} else if p.send {
+			// If we're supposed to send email then do that.
+
+			// Get the content of the feed-item.
+			//
+			// This has to be done ahead of sending email,
+			// as we can use this to skip entries via
+			// regular expression on the title/body contents.
+			content, err := item.HTMLContent()
+			if err != nil {
+				content = item.RawContent()
+			}
+
+			// Should we skip this entry?
+			//
+			// Skipping here means that we don't send an email,
+			// however we do mark it as read - so it will only
+			// be processed once.
+			skip := p.shouldSkip(entry, item.Title, content)
+			reason := ""
+			if skip {
+				reason = filterReason(entry, item.Title, content)
+			}
+
+			// Then the filter plugins of the feed, which may
+			// skip, or change, the item.  If they fail the item
+			// is retried upon the next run.
+			if !skip && len(f.filters) > 0 {
+				skip, reason, err = p.runFilters(ctx, f, xp)
+				if err != nil {
+					p.message(fmt.Sprintf("\t\tFailed: %s\n", err))
+					f.failed = append(f.failed, fmt.Sprintf("%s (%s)", item.Link, err))
+					p.notify(Event{Type: Error, Feed: entry, Item: xp, Err: err})
+					return nil
+				}
+				content, err = item.HTMLContent()
+				if err != nil {
+					content = item.RawContent()
+				}
+			}
+
+			// Then the conditions of the feed, which must all
+			// accept the item, as they see it once changed.
+			if !skip && len(f.conditions) > 0 {
+				skip, reason, err = p.runConditions(ctx, f, xp)
+				if err != nil {
+					p.message(fmt.Sprintf("\t\tFailed: %s\n", err))
+					f.failed = append(f.failed, fmt.Sprintf("%s (%s)", item.Link, err))
+					p.notify(Event{Type: Error, Feed: entry, Item: xp, Err: err})
+					return nil
+				}
+			}
+
+			// Skip items which repost one we recently delivered,
+			// under a new GUID.
+			if !skip && f.dedupe > 0 {
+				sent, err := duplicateTitle(entry.URL, item.Title, f.dedupe)
+				if err != nil {
+					return err
+				}
+				if !sent.IsZero() {
+					p.message(fmt.Sprintf("\t\tSkipping item, its title is like one sent %s\n", sent.Format("2006-01-02 15:04")))
+					skip = true
+					reason = "its title is like one sent " + sent.Format("2006-01-02 15:04")
+				}
+			}
+
+			// Once we've sent as many items as we may the
+			// others are left unseen, rather than sent.
+			if !skip && f.digest == "" && f.until.IsZero() && p.limitReached() {
+				p.message(fmt.Sprintf("\t\tNot sending item, the limit of %s per run was reached\n", plural(p.maxItems, "item")))
+				p.summary.Held++
+				f.held++
+				p.notify(Event{Type: ItemFiltered, Feed: entry, Item: xp, Reason: fmt.Sprintf("the limit of %s per run was reached", plural(p.maxItems, "item"))})
+				return nil
+			}
+
+			// Expand the shortened links of the item, summarise
+			// and translate it, and link the advisories it
+			// mentions, which require fetching, so are only done
+			// for items we'll send.
+			//
+			// The original title is kept, as that of the item
+			// we've delivered.
+			title := item.Title
+			changed := false
+			if !skip && f.expand && p.expandLinks(xp) {
+				changed = true
+			}
+			if !skip && f.summarise >= 0 && p.summariseItem(xp, f.summarise) {
+				changed = true
+			}
+			if !skip && f.translate != "" && p.translateItem(xp, f.translate) {
+				changed = true
+			}
+			if !skip && f.advisories && p.linkAdvisories(xp) {
+				changed = true
+			}
+			if !skip && f.wayback {
+				p.archiveLink(xp)
+			}
+			if changed {
+				content, err = item.HTMLContent()
+				if err != nil {
+					content = item.RawContent()
+				}
+			}
+
+			if skip {
+				p.summary.Skipped++
+				p.notify(Event{Type: ItemFiltered, Feed: entry, Item: xp, Reason: reason})
+			} else if f.digest != "" {
+
+				// Items of feeds with digests are
+				// accumulated, to be sent together.
+				err = withstate.Enqueue(f.digest, entry.URL, feed, xp)
+				if err != nil {
+					return err
+				}
+				p.message("\t\tAdded to digest\n")
+				p.summary.Queued++
+				p.notify(Event{Type: ItemQueued, Feed: entry, Item: xp, Reason: "it was added to the digest " + f.digest})
+			} else if !f.until.IsZero() {
+
+				// If we can't send now the item is
+				// queued, to be sent once we may.
+				err = withstate.Enqueue(entry.URL, entry.URL, feed, xp)
+				if err != nil {
+					return err
+				}
+				p.message(fmt.Sprintf("\t\tQueued until %s\n", f.until.Format("Mon 15:04")))
+				p.summary.Queued++
+				p.notify(Event{Type: ItemQueued, Feed: entry, Item: xp, Reason: "it may not be sent until " + f.until.Format(time.RFC1123)})
+			} else {
+				err = p.sendItem(ctx, entry, feed, item, f.icon, f.recipients, content)
+				if isOutputError(err) {
+
+					// The email was sent, so the item
+					// is recorded as seen, and the
+					// failure only reported.
+					f.incomplete = append(f.incomplete, fmt.Sprintf("%s (%s)", item.Link, err))
+					err = nil
+				}
+				if emailer.IsPermanent(err) {
+
+					// The item would be rejected again,
+					// so it is recorded as seen rather
+					// than being retried forever.
+					p.message(fmt.Sprintf("\t\tRejected: %s\n", err))
+					p.summary.Rejected++
+					f.rejected = append(f.rejected, fmt.Sprintf("%s (%s)", item.Link, err))
+					p.notify(Event{Type: Error, Feed: entry, Item: xp, Err: err})
+					item.RecordSeen()
+					return nil
+				}
+				if emailer.IsTemporary(err) {
+
+					// There's no point trying the
+					// remaining items until the MTA
+					// recovers.
+					return err
+				}
+				if err != nil {
+
+					// Other failures only affect this
+					// item, which will be retried.
+					p.message(fmt.Sprintf("\t\tFailed: %s\n", err))
+					f.failed = append(f.failed, fmt.Sprintf("%s (%s)", item.Link, err))
+					p.notify(Event{Type: Error, Feed: entry, Item: xp, Err: err})
+					return nil
+				}
+
+				// Hold any further items, if we should.
+				if f.gap > 0 {
+					err = withstate.RecordSent(entry.URL)
+					if err != nil {
+						return err
+					}
+					f.until = holdUntil(entry.URL, f.window, f.gap)
+				}
+			}
 
-	// verbose denotes how verbose we should be in execution.
-	verbose bool
-}
+			// Remember the titles of the items we deliver, or
+			// queue, so reposts of them are skipped.
+			if !skip && f.dedupe > 0 {
+				p.recordTitle(entry.URL, title, f.dedupe)
+			}
 
-// New creates a new Processor object
-func New() *Processor {
-	return &Processor{send: true}
+			// Keep their content too, if we should, so that
+			// they may be rendered again.
+			if !skip && p.cacheItems > 0 {
+				if cerr := withstate.CacheItem(entry.URL, feed, &raw); cerr != nil {
+					p.message(fmt.Sprintf("\t\tFailed to cache item: %s\n", cerr))
+				}
+			}
+		}
+	}
+
+	// Mark the item as having been seen, after the
+	// email was accepted.
+	//
+	// If sending failed we've already returned, so
+	// the item will be retried upon the next run.
+	if f.prints != nil {
+		f.prints.Add(&item)
+	}
+	item.RecordSeen()
+	return nil
 }
 
-// ProcessFeeds is the main workhorse here, we process each feed and send
-// emails appropriately.
-func (p *Processor) ProcessFeeds(recipients []string) []error {
+// sendItem sends the given item, with the given content, to each of our
+// outputs, and adds it to our HTML archive.
+func (p *Processor) sendItem(ctx context.Context, entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, icon *favicon.Icon, recipients []string, content string) error {
 
+	// Convert the content to text.
+	text := plainText(content)
+
+	// Create the helper to render the email
+	helper := p.newEmailer(entry, feed, item, icon)
+	helper.SetAttachments(p.attachments(ctx, entry, item.Item))
+
+	// Send the item to each output.
 	//
-	// If we receive errors we'll store them here,
-	// so we can keep processing subsequent URIs.
-	//
-	var errors []error
+	// If the email was sent, but another output failed, the item is
+	// still delivered, and the failure is only reported.
+	err := p.output(ctx, helper, entry, feed, item, recipients, text, content)
+	if err != nil && !isOutputError(err) {
+		return err
+	}
+	if err != nil {
+		p.message(fmt.Sprintf("\t\tEmailed, but failed to output: %s\n", err))
+		p.notify(Event{Type: Error, Feed: entry, Item: item.Item, Err: err})
+	}
+	p.summary.Sent++
+	p.notify(Event{Type: ItemDelivered, Feed: entry, Item: item.Item})
 
-	// Get the configuration-file
-	conf := configfile.New()
+	// Add the item to the HTML archive.
+	if p.river != nil {
+		if rerr := p.river.Add(riverItem(entry, feed, item, content)); rerr != nil {
+			p.message(fmt.Sprintf("\t\t\tFailed to add item to HTML archive: %s\n", rerr))
+		}
+	}
+	return err
+}
 
-	// Upgrade it if necessary
-	conf.Upgrade()
+// riverItem returns the record of the given item, with the given content,
+// which is added to the HTML archive, and the aggregated feed.
+func riverItem(entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, content string) river.Item {
 
-	// Now do the parsing
-	entries, err := conf.Parse()
-	if err != nil {
-		errors = append(errors, fmt.Errorf("error with config-file %s - %s", conf.Path(), err))
-		return errors
+	record := river.Item{
+		Feed:      entry.URL,
+		FeedTitle: feed.Title,
+		Title:     item.Title,
+		Link:      item.Link,
+		GUID:      item.GUID,
+		HTML:      content,
+	}
+	if item.PublishedParsed != nil {
+		record.Published = *item.PublishedParsed
 	}
+	if item.Author != nil {
+		record.Author = item.Author.Name
+	}
+	return record
+}
 
-	// For each feed-item contained in the feed
-	for _, entry := range entries {
+// newFetcher creates the helper which fetches the given feed, via the
+// fetcher registered for the scheme of its URL, configured with our
+// settings.
+//
+// If we're using fixtures then the feed is read from its fixture,
+// whatever its scheme.
+func (p *Processor) newFetcher(entry configfile.Feed) (fetch.Fetcher, error) {
 
-		// Process this specific entry.
-		err := p.processFeed(entry, recipients)
+	var helper fetch.Fetcher
+	if p.fixtures != "" {
+		h := httpfetch.New(entry)
+		h.SetFixtures(p.fixtures)
+		helper = h
+	} else {
+		var err error
+		helper, err = fetch.New(entry)
 		if err != nil {
-			errors = append(errors, fmt.Errorf("error processing %s - %s", entry.URL, err))
+			return nil, err
 		}
 	}
+	helper.SetLogger(p.logger.Named("fetch"))
+	return helper, nil
+}
 
-	// Prune old state files
-	prunedCount, pruneErrors := withstate.PruneStateFiles()
+// newEmailer creates the helper which renders, and sends, the email for the
+// given item, configured with our settings.
+func (p *Processor) newEmailer(entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, icon *favicon.Icon) *emailer.Emailer {
 
-	// If we got any errors propagate them
-	errors = append(errors, pruneErrors...)
+	helper := emailer.New(feed, item, entry.Options)
+	helper.SetLogger(p.logger.Named("send"))
+	helper.SetRecorder(p.recorder)
+	helper.SetFrom(p.from)
+	helper.SetEnvelopeFrom(p.envelopeFrom)
+	helper.SetCC(p.cc)
+	helper.SetBCC(p.bcc)
+	helper.SetMaxSize(p.maxSize)
+	helper.SetStyle(p.style)
+	helper.SetTimezone(p.timezone)
+	helper.SetDateFormat(p.dateFormat)
+	helper.SetFavicon(icon)
+	helper.SetArchive(item.Custom[wayback.Key])
+	return helper
+}
 
-	// Show what we did, if we should
-	if prunedCount > 0 {
-		p.message(fmt.Sprintf("Pruned %d entry state files\n", prunedCount))
+// Render fetches the given feed, and generates the email for the item at
+// the given index, as it would be sent to the given recipients.
+//
+// Nothing is sent, and the state of the item isn't changed, so this may
+// be used to preview the effect of templates, and per-feed options.
+func (p *Processor) Render(ctx context.Context, entry configfile.Feed, index int, recipients []string) ([]byte, error) {
+
+	fetcher, err := p.newFetcher(entry)
+	if err != nil {
+		return nil, err
+	}
+	feed, err := fetcher.FetchContext(ctx)
+	if err != nil {
+		return nil, err
 	}
 
-	return errors
+	if index < 0 || index >= len(feed.Items) {
+		return nil, fmt.Errorf("there is no item %d, the feed contains %d items", index+1, len(feed.Items))
+	}
+
+	xp := feed.Items[index]
+	err = p.prepareItem(entry, feed, xp)
+	if err != nil {
+		return nil, err
+	}
+	item := withstate.FeedItem{Item: xp}
+
+	content, err := item.HTMLContent()
+	if err != nil {
+		content = item.RawContent()
+	}
+
+	// Failing to fetch the icon isn't fatal.
+	var icon *favicon.Icon
+	if p.wantFavicon(entry) {
+		icon, _ = favicon.New().Get(feed)
+	}
+
+	helper := p.newEmailer(entry, feed, item, icon)
+	helper.SetAttachments(p.attachments(ctx, entry, xp))
+
+	msg, err := helper.Render(recipients, plainText(content), content)
+	if err != nil {
+		return nil, err
+	}
+
+	// Rather than silently using the built-in template, show why
+	// the template failed.
+	if msg.TemplateError != nil {
+		return nil, msg.TemplateError
+	}
+	return msg.Content, nil
 }
 
-// message shows a message if our verbose flag is set
-func (p *Processor) message(msg string) {
-	if p.verbose {
-		fmt.Printf("%s\n", msg)
+// prepareItem makes the changes to the given item, of the given feed,
+// which are made before it is sent, regardless of its filters.
+func (p *Processor) prepareItem(entry configfile.Feed, feed *gofeed.Feed, xp *gofeed.Item) error {
+
+	rules, err := parseItemRules(entry)
+	if err != nil {
+		return err
+	}
+
+	sites.Enhance(feed, xp, entry.Options)
+	rules.apply(xp)
+	if wantExpandLinks(entry) {
+		p.expandLinks(xp)
+	}
+	if words, err := summariseWords(entry); err == nil && words >= 0 {
+		p.summariseItem(xp, words)
+	}
+	if target := translateTarget(entry); target != "" {
+		p.translateItem(xp, target)
 	}
+	if wantAdvisories(entry) {
+		p.linkAdvisories(xp)
+	}
+	if wantWayback(entry) {
+		p.archiveLink(xp)
+	}
+	return nil
 }
 
-// processFeed takes a configuration entry as input, fetches the appropriate
-// remote contents, and then processes each feed item found within it.
+// sendQueued sends the items of the given feed which were queued, as
+// they were discovered when we couldn't send them, returning true if any
+// were sent.
 //
-// Feed items which are new/unread will generate an email, unless they are
-// specifically excluded by the per-feed options.
-func (p *Processor) processFeed(entry configfile.Feed, recipients []string) error {
+// If fold is true several queued items are sent as a single digest,
+// otherwise each is sent individually.
+func (p *Processor) sendQueued(ctx context.Context, entry configfile.Feed, icon *favicon.Icon, recipients []string, fold bool) (bool, error) {
 
-	// Show what we're doing.
-	p.message(fmt.Sprintf("Fetching feed: %s\n", entry.URL))
+	queued, err := withstate.Queued(entry.URL)
+	if err != nil || len(queued) == 0 {
+		return false, err
+	}
 
-	// Fetch the feed for the input URL
-	helper := httpfetch.New(entry)
-	feed, err := helper.Fetch()
+	if fold && len(queued) > 1 && !p.limitReached() {
+
+		feed := queued[len(queued)-1].Source()
+		item := withstate.FeedItem{Item: digestItem(feed, queued)}
+		p.message(fmt.Sprintf("\t\tQueued entries: %s\n", item.Title))
+
+		err = p.sendItem(ctx, entry, feed, item, icon, recipients, item.Content)
+		if err != nil && !isOutputError(err) {
+			return false, err
+		}
+		for _, q := range queued {
+			err = q.Remove()
+			if err != nil {
+				return true, err
+			}
+		}
+		return true, withstate.RecordSent(entry.URL)
+	}
+
+	for _, q := range queued {
+
+		// Stop if we've been interrupted, or have sent as many
+		// items as we may.
+		if ctx.Err() != nil || p.limitReached() {
+			return true, nil
+		}
+
+		item := withstate.FeedItem{Item: q.Item}
+		p.message(fmt.Sprintf("\t\tQueued entry: %s\n", item.Title))
+
+		content, err := item.HTMLContent()
+		if err != nil {
+			content = item.RawContent()
+		}
+
+		err = p.sendItem(ctx, entry, q.Source(), item, icon, recipients, content)
+		if err != nil && !isOutputError(err) {
+			return true, err
+		}
+
+		err = q.Remove()
+		if err != nil {
+			return true, err
+		}
+	}
+	if fold {
+		return true, withstate.RecordSent(entry.URL)
+	}
+	return true, nil
+}
+
+// output sends the given item to each of our outputs.
+func (p *Processor) output(ctx context.Context, helper *emailer.Emailer, entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, recipients []string, text string, content string) (err error) {
+
+	_, span := tracing.Start(ctx, "send",
+		attribute.String("feed.url", entry.URL),
+		attribute.String("item.link", item.Link),
+		attribute.StringSlice("outputs", p.outputs))
+	defer func() { tracing.End(span, err) }()
+
+	// Once the email has been sent the item mustn't be retried, lest
+	// it be sent again, so the failures of the remaining outputs are
+	// collected rather than returned.
+	sent := false
+	var failures []string
+	failed := func(e error) error {
+		if !sent {
+			return e
+		}
+		failures = append(failures, e.Error())
+		return nil
+	}
+
+	// Send the mail
+	if p.wantOutput("email") {
+		err = p.deliver(helper, entry, feed, item, recipients, text, content)
+		if err != nil {
+			return err
+		}
+		sent = true
+	}
+
+	// Write the item as JSON
+	if p.wantOutput("jsonl") {
+		if e := p.writeJSON(entry, feed, item, text, content); e != nil {
+			if err = failed(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Pipe the item to a command
+	if p.wantOutput("exec") {
+		if e := p.execItem(helper, entry, feed, item, recipients, text, content); e != nil {
+			if err = failed(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	// Add the item to the aggregated feed
+	if p.wantOutput("feed") && p.aggregate != nil {
+		p.aggregate.Add(riverItem(entry, feed, item, content))
+	}
+
+	// Give the item to the output plugins of the feed, unless we're
+	// being tested.
+	if p.fixtures == "" && p.recorder == nil {
+		if e := p.runOutputs(ctx, entry, feed, item.Item); e != nil {
+			if err = failed(e); err != nil {
+				return err
+			}
+		}
+	}
+
+	if len(failures) > 0 {
+		err = &outputError{msg: strings.Join(failures, "; ")}
+		return err
+	}
+	return nil
+}
+
+// deliver sends the email for the given item, recording the attempt in
+// the delivery log, and the item within our archive if we should.
+func (p *Processor) deliver(helper *emailer.Emailer, entry configfile.Feed, feed *gofeed.Feed, item withstate.FeedItem, recipients []string, text string, content string) error {
+
+	msg, err := helper.Render(recipients, text, content)
 	if err != nil {
 		return err
 	}
+	p.checkTemplate(entry, item, msg)
 
-	p.message(fmt.Sprintf("\tFeed contains %d entries\n", len(feed.Items)))
+	err = helper.Send(msg)
 
-	// For each entry in the feed ..
-	for _, xp := range feed.Items {
+	// Record the attempt, failing to do so isn't fatal.
+	attempt := audit.Record{
+		Feed:       entry.URL,
+		FeedTitle:  feed.Title,
+		GUID:       item.GUID,
+		Title:      item.Title,
+		Link:       item.Link,
+		Recipients: recipients,
+		Backend:    msg.Backend,
+		Result:     audit.ResultSent,
+		Response:   msg.Response,
+	}
+	if err != nil {
+		attempt.Result = audit.ResultFailed
+		if emailer.IsPermanent(err) {
+			attempt.Result = audit.ResultRejected
+		}
+		attempt.Error = err.Error()
+	}
+	if aerr := audit.Append(audit.Path(), attempt); aerr != nil {
+		p.message(fmt.Sprintf("\t\t\tFailed to record delivery: %s\n", aerr))
+	}
 
-		// Wrap the feed-item in a class of our own,
-		// so that we can use our helper methods to mark
-		// read-state.
-		item := withstate.FeedItem{Item: xp}
+	if p.db == nil {
+		return err
+	}
 
-		// If we've not already notified about this one.
-		if item.IsNew() {
+	record := archive.Entry{
+		Feed:      entry.URL,
+		FeedTitle: feed.Title,
+		GUID:      item.GUID,
+		Title:     item.Title,
+		Link:      item.Link,
+		Text:      text,
+		HTML:      content,
+		Message:   msg.Content,
+		Status:    archive.StatusSent,
+	}
+	if item.PublishedParsed != nil {
+		record.Published = *item.PublishedParsed
+	}
+	if err != nil {
+		record.Status = archive.StatusFailed
+		record.Error = err.Error()
+	}
 
-			// Show the new item.
-			p.message(fmt.Sprintf("\t\tFeed entry: %s\n", item.Title))
-			// If we're supposed to send email then do that.
-			if p.send {
-
-				// Get the content of the feed-item.
-				//
-				// This has to be done ahead of sending email,
-				// as we can use this to skip entries via
-				// regular expression on the title/body contents.
-				content, err := item.HTMLContent()
-				if err != nil {
-					content = item.RawContent()
-				}
+	// Failing to archive isn't fatal, as the email was handled.
+	if aerr := p.db.Add(record); aerr != nil {
+		p.message(fmt.Sprintf("\t\t\tFailed to archive item: %s\n", aerr))
+	}
 
-				// Should we skip this entry?
-				//
-				// Skipping here means that we don't send an email,
-				// however we do mark it as read - so it will only
-				// be processed once.
-				if !p.shouldSkip(entry, item.Title, content) {
+	return err
+}
 
-					// Convert the content to text.
-					text := html2text.HTML2Text(content)
+// IsPaused returns true if the given feed has been paused, via the
+// per-feed "paused" option.
+//
+// The items of paused feeds are recorded as having been seen, but they
+// are not sent.
+func IsPaused(config configfile.Feed) bool {
 
-					// Send the mail
-					helper := emailer.New(feed, item, entry.Options)
-					err = helper.Sendmail(recipients, text, content)
-					if err != nil {
-						return err
-					}
-				}
+	paused := false
+	for _, opt := range config.Options {
+		if opt.Name == "paused" {
+			val, err := strconv.ParseBool(opt.Value)
+			if err == nil {
+				paused = val
 			}
 		}
+	}
+	return paused
+}
 
-		// Mark the item as having been seen, after the
-		// email was (probably) sent.
-		//
-		// This does run the risk that sending mail
-		// fails, due to error, and that keeps happening
-		// forever...
-		item.RecordSeen()
+// wantFavicon returns true if we should embed the icon of the given feed
+// within our emails, which may be set globally or via the per-feed
+// "favicon" option.
+func (p *Processor) wantFavicon(config configfile.Feed) bool {
+
+	// Nothing is fetched when we're using fixtures.
+	if p.fixtures != "" {
+		return false
 	}
 
-	return nil
+	want := p.favicon
+	for _, opt := range config.Options {
+		if opt.Name == "favicon" {
+			val, err := strconv.ParseBool(opt.Value)
+			if err == nil {
+				want = val
+			}
+		}
+	}
+	return want
 }
 
 // shouldSkip returns true if this entry should be skipped/ignored.
@@ -178,18 +1751,42 @@ func (p *Processor) processFeed(entry configfile.Feed, recipients []string) erro
 // having been read, but no email is sent.
 func (p *Processor) shouldSkip(config configfile.Feed, title string, content string) bool {
 
+	skip, rule := filter(config, title, content)
+
+	switch {
+	case rule == nil && skip:
+		p.message("\t\t\tExcluding entry, as it didn't match any include, or include-title, patterns\n")
+	case rule == nil:
+	case rule.Name == "exclude-title":
+		p.message(fmt.Sprintf("\t\t\tSkipping due to 'exclude-title' match of '%s'.\n", rule.Value))
+	case rule.Name == "exclude":
+		p.message(fmt.Sprintf("\t\t\tSkipping due to 'exclude' match of %s.\n", rule.Value))
+	case rule.Name == "include-title":
+		p.message(fmt.Sprintf("\t\t\tIncluding as this entry's title matches %s.\n", rule.Value))
+	case rule.Name == "include":
+		p.message(fmt.Sprintf("\t\t\tIncluding as this entry matches %s.\n", rule.Value))
+	}
+	return skip
+}
+
+// filter returns true if the entry with the given title, and content,
+// should be skipped, according to the exclude and include options of the
+// given feed, along with the option which decided that, if any.
+//
+// If an entry is skipped because it didn't match any of the include
+// options the option returned is nil.
+func filter(config configfile.Feed, title string, content string) (bool, *configfile.Option) {
+
 	// Walk over the options to see if there are any exclude* options
 	// specified.
-	for _, opt := range config.Options {
+	for i, opt := range config.Options {
 
 		// Exclude by title?
 		if opt.Name == "exclude-title" {
 			match, _ := regexp.MatchString(opt.Value, title)
 			if match {
-				p.message(fmt.Sprintf("\t\t\tSkipping due to 'exclude-title' match of '%s'.\n", opt.Value))
-
 				// True: skip/ignore this entry
-				return true
+				return true, &config.Options[i]
 			}
 		}
 
@@ -198,10 +1795,8 @@ func (p *Processor) shouldSkip(config configfile.Feed, title string, content str
 
 			match, _ := regexp.MatchString(opt.Value, content)
 			if match {
-				p.message(fmt.Sprintf("\t\t\tSkipping due to 'exclude' match of %s.\n", opt.Value))
-
 				// True: skip/ignore this entry
-				return true
+				return true, &config.Options[i]
 			}
 		}
 	}
@@ -214,7 +1809,7 @@ func (p *Processor) shouldSkip(config configfile.Feed, title string, content str
 	//
 	include := false
 
-	for _, opt := range config.Options {
+	for i, opt := range config.Options {
 		if opt.Name == "include-title" {
 
 			// We found (at least one) include option
@@ -224,10 +1819,8 @@ func (p *Processor) shouldSkip(config configfile.Feed, title string, content str
 			// so we MUST skip unless there is a match
 			match, _ := regexp.MatchString(opt.Value, title)
 			if match {
-				p.message(fmt.Sprintf("\t\t\tIncluding as this entry's title matches %s.\n", opt.Value))
-
 				// False: Do not skip/ignore this entry
-				return false
+				return false, &config.Options[i]
 			}
 		}
 		if opt.Name == "include" {
@@ -239,10 +1832,8 @@ func (p *Processor) shouldSkip(config configfile.Feed, title string, content str
 			// so we MUST skip unless there is a match
 			match, _ := regexp.MatchString(opt.Value, content)
 			if match {
-				p.message(fmt.Sprintf("\t\t\tIncluding as this entry matches %s.\n", opt.Value))
-
 				// False: Do not skip/ignore this entry
-				return false
+				return false, &config.Options[i]
 			}
 		}
 	}
@@ -251,20 +1842,16 @@ func (p *Processor) shouldSkip(config configfile.Feed, title string, content str
 	// the we had no match.
 	//
 	// i.e. The entry did not include a string we regarded as mandatory.
-	if include {
-		p.message("\t\t\tExcluding entry, as it didn't match any include, or include-title, patterns\n")
-
-		// True: skip/ignore this entry
-		return true
-	}
-
-	// False: Do not skip/ignore this entry
-	return false
+	//
+	// True: skip/ignore this entry, False: Do not skip/ignore this entry
+	return include, nil
 }
 
-// SetVerbose updates the verbosity state of this object.
-func (p *Processor) SetVerbose(state bool) {
-	p.verbose = state
+// SetLogger sets the logger which receives our messages, and those of the
+// components we use to fetch feeds, and send emails.  By default messages
+// are discarded.
+func (p *Processor) SetLogger(l logger.Logger) {
+	p.logger = l
 }
 
 // SetSendEmail updates the state of this object, when the send-flag
@@ -272,3 +1859,177 @@ func (p *Processor) SetVerbose(state bool) {
 func (p *Processor) SetSendEmail(state bool) {
 	p.send = state
 }
+
+// SetFrom updates the default address used in the From: header of
+// the emails we send.
+func (p *Processor) SetFrom(from string) {
+	p.from = from
+}
+
+// SetEnvelopeFrom updates the default envelope sender of the emails
+// we send, which is distinct from the From: header.
+func (p *Processor) SetEnvelopeFrom(from string) {
+	p.envelopeFrom = from
+}
+
+// SetCC updates the list of addresses which receive a copy of each
+// email we send.
+func (p *Processor) SetCC(addresses []string) {
+	p.cc = addresses
+}
+
+// SetBCC updates the list of addresses which receive a blind copy of
+// each email we send.
+func (p *Processor) SetBCC(addresses []string) {
+	p.bcc = addresses
+}
+
+// SetMaxSize updates the maximum size of the email bodies we generate,
+// in bytes.  Larger items will be truncated, and zero means there is
+// no limit.
+func (p *Processor) SetMaxSize(size int) {
+	p.maxSize = size
+}
+
+// SetStyle updates the name of the embedded template used to generate
+// our emails, which may be "plain" or "styled".
+func (p *Processor) SetStyle(style string) {
+	p.style = style
+}
+
+// SetTimezone updates the name of the time zone in which dates are shown
+// within our emails, the empty string meaning the local time zone.
+func (p *Processor) SetTimezone(name string) {
+	p.timezone = name
+}
+
+// SetDateFormat updates the layout of the dates shown within our emails.
+func (p *Processor) SetDateFormat(layout string) {
+	p.dateFormat = layout
+}
+
+// SetFavicon updates whether we embed the icon of each feed within the
+// emails we send.
+func (p *Processor) SetFavicon(state bool) {
+	p.favicon = state
+}
+
+// SetArchive updates whether we store the items we process, and the
+// emails we generate, within our archive.
+func (p *Processor) SetArchive(state bool) {
+	p.archive = state
+}
+
+// SetHTMLArchive sets the directory to which a static HTML archive of the
+// items we send is written.  An empty string disables the archive.
+func (p *Processor) SetHTMLArchive(dir string) {
+	p.htmlArchive = dir
+}
+
+// SetOutputs updates the list of outputs which new items are sent to,
+// which may contain "email", "jsonl", "exec", and "feed".
+func (p *Processor) SetOutputs(outputs []string) {
+	p.outputs = outputs
+}
+
+// SetFeedFile sets the path of the Atom feed written by the "feed" output.
+// An empty string uses the default, within our state directory.
+func (p *Processor) SetFeedFile(path string) {
+	p.feedFile = path
+}
+
+// FeedFile returns the path of the Atom feed written by the "feed" output.
+func (p *Processor) FeedFile() string {
+	if p.feedFile == "" {
+		return aggregate.Path()
+	}
+	return p.feedFile
+}
+
+// SetFeedItems sets the number of items held by the Atom feed written by
+// the "feed" output.  Zero uses the default.
+func (p *Processor) SetFeedItems(n int) {
+	p.feedItems = n
+}
+
+// SetExecCommand updates the command which new items are piped to, by
+// the "exec" output.
+func (p *Processor) SetExecCommand(command string) {
+	p.execCommand = command
+}
+
+// SetExecFormat updates the format of the items piped to the command of
+// the "exec" output, which may be "message" for the rendered email, or
+// "json" for the item itself.
+func (p *Processor) SetExecFormat(format string) {
+	p.execFormat = format
+}
+
+// SetUnread updates whether we process the unread items of a configured
+// feed-reader, rather than fetching its subscriptions ourselves.
+func (p *Processor) SetUnread(state bool) {
+	p.unread = state
+}
+
+// SetOnly restricts processing to the given feeds, which may be given by
+// URL or by name.  An empty list means all feeds are processed.
+func (p *Processor) SetOnly(feeds []string) {
+	p.only = feeds
+}
+
+// SetLastRun records the time at which our previous run started, which is
+// used to decide which feeds with a "cron" option are due.  The zero time,
+// the default, means all feeds are processed.
+func (p *Processor) SetLastRun(since time.Time) {
+	p.lastRun = since
+}
+
+// SetDeliveryWindow sets the default window, in the form "HH:MM-HH:MM",
+// within which emails may be sent.  Items discovered outside the window
+// are queued until it opens.  An empty string, the default, means emails
+// are sent immediately.
+func (p *Processor) SetDeliveryWindow(window string) {
+	p.window = window
+}
+
+// SetTimeout sets the time within which a run must complete.  Once it has
+// passed no further items are processed, and the feeds which haven't been
+// processed are skipped, and reported.  Zero, the default, means there's
+// no limit.
+func (p *Processor) SetTimeout(timeout time.Duration) {
+	p.timeout = timeout
+}
+
+// SetArchiveDead sets the time after which feeds which have had no new
+// items, or have failed every fetch, are archived.  Zero, the default,
+// means feeds are never archived.
+func (p *Processor) SetArchiveDead(after time.Duration) {
+	p.archiveAfter = after
+}
+
+// SetCacheItems sets the time for which the raw content of the items we
+// send is kept, so that they may be rendered again by Rerender.  Zero, the
+// default, means their content isn't kept.
+func (p *Processor) SetCacheItems(keep time.Duration) {
+	p.cacheItems = keep
+}
+
+// SetMaxItems sets the largest number of items we'll send during a single
+// run, or zero for no limit.  Once it is reached the remaining new items
+// are left unseen, and the feeds they belong to are reported.
+func (p *Processor) SetMaxItems(n int) {
+	p.maxItems = n
+}
+
+// SetFixtures sets the directory holding the fixtures which are used as
+// the content of feeds, rather than fetching them, for testing.  Icons
+// aren't fetched when fixtures are used.
+func (p *Processor) SetFixtures(dir string) {
+	p.fixtures = dir
+}
+
+// SetRecorder sets the recorder which records the emails we'd send, rather
+// than them being sent, for testing.
+func (p *Processor) SetRecorder(r *emailer.Recorder) {
+	p.recorder = r
+}
@@ -1,9 +1,25 @@
 package processor
 
 import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
 	"testing"
+	"time"
 
+	"github.com/mmcdole/gofeed"
 	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/fetch"
+	"github.com/skx/rss2email/logger"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/withstate"
 )
 
 func TestSendEmail(t *testing.T) {
@@ -26,17 +42,34 @@ func TestVerbose(t *testing.T) {
 
 	p := New()
 
-	if p.verbose {
-		t.Fatalf("unexpected default to verbose")
+	if p.logger != logger.Discard {
+		t.Fatalf("unexpected default logger")
 	}
 
-	p.SetVerbose(true)
+	// Only the messages of verbose components are shown.
+	buf := &bytes.Buffer{}
+	l := logger.New(buf)
+	l.SetVerbose("fetch")
+	p.SetLogger(l)
+	p.message("hidden")
+	if buf.Len() != 0 {
+		t.Fatalf("unexpected message: %s", buf.String())
+	}
 
-	if !p.verbose {
-		t.Fatalf("unexpected verbose setting")
+	l.SetVerbose("process")
+	p.message("shown")
+	if buf.String() != "shown\n" {
+		t.Fatalf("unexpected message: %s", buf.String())
 	}
 }
 
+// verboseLogger returns a logger for which every component is verbose.
+func verboseLogger() logger.Logger {
+	l := logger.New(os.Stdout)
+	l.SetVerbose("all")
+	return l
+}
+
 // TestSkipExclude ensures that we can exclude items by regexp
 func TestSkipExclude(t *testing.T) {
 
@@ -52,7 +85,7 @@ func TestSkipExclude(t *testing.T) {
 	x := New()
 
 	// Set it as verbose
-	x.SetVerbose(true)
+	x.SetLogger(verboseLogger())
 
 	if !x.shouldSkip(feed, "Title here", "<p>foo, bar baz</p>") {
 		t.Fatalf("failed to skip entry by regexp")
@@ -88,7 +121,7 @@ func TestSkipInclude(t *testing.T) {
 	x := New()
 
 	// Set it as verbose
-	x.SetVerbose(true)
+	x.SetLogger(verboseLogger())
 
 	if x.shouldSkip(feed, "Title here", "<p>This is good</p>") {
 		t.Fatalf("this should be included because it contains good")
@@ -125,7 +158,7 @@ func TestSkipIncludeTitle(t *testing.T) {
 	x := New()
 
 	// Set it as verbose
-	x.SetVerbose(true)
+	x.SetLogger(verboseLogger())
 
 	if x.shouldSkip(feed, "Title here", "<p>This is good</p>") {
 		t.Fatalf("this should be included because it contains good")
@@ -155,7 +188,7 @@ func TestSkipIncludeTitle(t *testing.T) {
 	x = New()
 
 	// Set it as verbose
-	x.SetVerbose(true)
+	x.SetLogger(verboseLogger())
 
 	// include
 	for _, entry := range valid {
@@ -171,3 +204,940 @@ func TestSkipIncludeTitle(t *testing.T) {
 		}
 	}
 }
+
+// TestSenders ensures our sender-setters work
+func TestSenders(t *testing.T) {
+
+	p := New()
+
+	if p.from != "" || p.envelopeFrom != "" {
+		t.Fatalf("unexpected default senders")
+	}
+
+	p.SetFrom("rss@example.com")
+	p.SetEnvelopeFrom("bounces@example.com")
+
+	if p.from != "rss@example.com" {
+		t.Fatalf("unexpected from setting")
+	}
+	if p.envelopeFrom != "bounces@example.com" {
+		t.Fatalf("unexpected envelope-from setting")
+	}
+}
+
+// TestCopies ensures our cc/bcc setters work
+func TestCopies(t *testing.T) {
+
+	p := New()
+
+	p.SetCC([]string{"a@example.com"})
+	p.SetBCC([]string{"b@example.com", "c@example.com"})
+
+	if len(p.cc) != 1 {
+		t.Fatalf("unexpected cc setting")
+	}
+	if len(p.bcc) != 2 {
+		t.Fatalf("unexpected bcc setting")
+	}
+}
+
+// TestMaxSize ensures our size-setter works
+func TestMaxSize(t *testing.T) {
+
+	p := New()
+	if p.maxSize != 0 {
+		t.Fatalf("unexpected default size limit")
+	}
+
+	p.SetMaxSize(1024)
+	if p.maxSize != 1024 {
+		t.Fatalf("unexpected size limit")
+	}
+}
+
+// TestFavicon ensures the favicon setting can be changed per-feed
+func TestFavicon(t *testing.T) {
+
+	p := New()
+
+	feed := configfile.Feed{URL: "blah"}
+	if p.wantFavicon(feed) {
+		t.Fatalf("favicons should be disabled by default")
+	}
+
+	p.SetFavicon(true)
+	if !p.wantFavicon(feed) {
+		t.Fatalf("favicons should have been enabled")
+	}
+
+	feed.Options = []configfile.Option{{Name: "favicon", Value: "false"}}
+	if p.wantFavicon(feed) {
+		t.Fatalf("favicons should have been disabled for this feed")
+	}
+}
+
+// TestWriteJSON ensures items are written as a single line of JSON.
+func TestWriteJSON(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+
+	if !p.wantOutput("email") || p.wantOutput("jsonl") {
+		t.Fatalf("unexpected default outputs: %v", p.outputs)
+	}
+	p.SetOutputs([]string{"jsonl"})
+	if p.wantOutput("email") || !p.wantOutput("jsonl") {
+		t.Fatalf("unexpected outputs: %v", p.outputs)
+	}
+
+	published := time.Date(2021, 1, 2, 3, 4, 5, 0, time.UTC)
+	feed := &gofeed.Feed{Title: "Example"}
+	item := withstate.FeedItem{Item: &gofeed.Item{
+		Title:           "Hello",
+		Link:            "https://example.com/1",
+		PublishedParsed: &published,
+		Author:          &gofeed.Person{Name: "Steve"},
+	}}
+
+	err := p.writeJSON(configfile.Feed{URL: "https://example.com/rss"}, feed, item, "text", "<p>text</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected a single line: %q", buf.String())
+	}
+
+	var res map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &res)
+	if err != nil {
+		t.Fatalf("invalid JSON: %s", err)
+	}
+
+	expected := map[string]string{
+		"feed":       "https://example.com/rss",
+		"feed_title": "Example",
+		"title":      "Hello",
+		"link":       "https://example.com/1",
+		"published":  "2021-01-02T03:04:05Z",
+		"author":     "Steve",
+		"content":    "<p>text</p>",
+		"text":       "text",
+	}
+	for key, val := range expected {
+		if res[key] != val {
+			t.Errorf("%s: expected %q, got %v", key, val, res[key])
+		}
+	}
+}
+
+// TestOutputFailure ensures an item which was emailed isn't emailed again
+// if another of our outputs fails.
+func TestOutputFailure(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	recorder := emailer.NewRecorder("")
+	p := New()
+	p.SetSendEmail(true)
+	p.SetRecorder(recorder)
+	p.SetOutputs([]string{"email", "exec"})
+	p.SetExecCommand("exit 1")
+
+	guid := fmt.Sprintf("rss2email-output-failure-test-%d", time.Now().UnixNano())
+	entry := configfile.Feed{URL: "https://example.com/output-failure"}
+	feed := &gofeed.Feed{Title: "Example", Items: []*gofeed.Item{{Title: "Hello", Link: "https://example.com/1", GUID: guid}}}
+
+	for i := 0; i < 2; i++ {
+		err := p.processItems(context.Background(), entry, feed, []string{"steve@example.com"})
+		if i == 0 && (err == nil || !strings.Contains(err.Error(), "won't be retried")) {
+			t.Fatalf("the failure wasn't reported: %v", err)
+		}
+		if i == 1 && err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	if len(recorder.Messages()) != 1 {
+		t.Fatalf("expected a single email, got %d", len(recorder.Messages()))
+	}
+	if p.summary.Sent != 1 {
+		t.Fatalf("the item wasn't counted as sent: %d", p.summary.Sent)
+	}
+}
+
+// TestExecItem ensures items are piped to commands.
+func TestExecItem(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	dest := filepath.Join(dir, "output")
+
+	p := New()
+	p.SetExecCommand("cat > " + dest + "; echo \"$RSS2EMAIL_TITLE\" >> " + dest)
+	p.SetExecFormat("json")
+
+	feed := &gofeed.Feed{Title: "Example"}
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Hello", Link: "https://example.com/1"}}
+	entry := configfile.Feed{URL: "https://example.com/rss"}
+
+	err := p.execItem(emailer.New(feed, item, nil), entry, feed, item, nil, "text", "<p>text</p>")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	data, err := os.ReadFile(dest)
+	if err != nil {
+		t.Fatalf("command didn't run: %s", err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 2 || !strings.Contains(lines[0], `"title":"Hello"`) || lines[1] != "Hello" {
+		t.Fatalf("unexpected output: %q", data)
+	}
+
+	// Failing commands are errors, which include the output.
+	p.SetExecCommand("echo broken >&2; exit 3")
+	err = p.execItem(emailer.New(feed, item, nil), entry, feed, item, nil, "text", "<p>text</p>")
+	if err == nil || !strings.Contains(err.Error(), "broken") {
+		t.Fatalf("expected error, got %v", err)
+	}
+}
+
+// TestItemIsolation ensures that a broken item doesn't prevent the other
+// items of a feed being sent.
+func TestItemIsolation(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	dest := filepath.Join(t.TempDir(), "output")
+
+	p := New()
+	p.SetSendEmail(true)
+	p.SetOutputs([]string{"exec"})
+	p.SetExecFormat("json")
+	p.SetExecCommand("test \"$RSS2EMAIL_TITLE\" != Bad && cat >> " + dest)
+
+	guid := fmt.Sprintf("rss2email-isolation-%d", time.Now().UnixNano())
+	feed := &gofeed.Feed{Items: []*gofeed.Item{
+		{Title: "Bad", GUID: guid + "-1", Link: "https://example.com/1"},
+		nil,
+		{Title: "Good", GUID: guid + "-2", Link: "https://example.com/2"},
+	}}
+	entry := configfile.Feed{URL: "https://example.com/rss"}
+
+	err := p.processItems(context.Background(), entry, feed, nil)
+	if err == nil || !strings.Contains(err.Error(), "2 items failed, and will be retried: https://example.com/1") || !strings.Contains(err.Error(), "panic") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	data, _ := os.ReadFile(dest)
+	if !strings.Contains(string(data), `"title":"Good"`) || strings.Contains(string(data), `"title":"Bad"`) {
+		t.Fatalf("unexpected output: %s", data)
+	}
+
+	// The failed item is retried, while the other isn't.
+	bad := withstate.FeedItem{Item: feed.Items[0]}
+	good := withstate.FeedItem{Item: feed.Items[2]}
+	if !bad.IsNew() || good.IsNew() {
+		t.Fatalf("wrong items were recorded as seen")
+	}
+}
+
+// TestFeedOutput ensures the "feed" output adds new items to our Atom feed.
+func TestFeedOutput(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/rss\n - exclude-title: Second\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	fixtures := t.TempDir()
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_rss.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/aggregated/first</link></item>
+<item><title>Second</title><link>https://example.com/aggregated/second</link></item>
+</channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "river.atom")
+	p := New()
+	p.SetOutputs([]string{"feed"})
+	p.SetFeedFile(path)
+	p.SetFixtures(fixtures)
+	if errs := p.ProcessFeeds(context.Background(), nil); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	// Only the item which passed the filters is present.
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("feed wasn't written: %s", err)
+	}
+	if !strings.Contains(string(data), "<title>First</title>") || strings.Contains(string(data), "Second") || !strings.Contains(string(data), "https://example.com/aggregated/first") {
+		t.Fatalf("unexpected feed: %s", data)
+	}
+}
+
+// TestInterrupted ensures no items are processed once the context has
+// been cancelled.
+func TestInterrupted(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+	p.SetOutputs([]string{"jsonl"})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+
+	feed := &gofeed.Feed{Items: []*gofeed.Item{{Title: "Hello", GUID: "rss2email-interrupted-test"}}}
+	err := p.processItems(ctx, configfile.Feed{URL: "https://example.com/rss"}, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("items were processed after interruption: %s", buf.String())
+	}
+	if p.Summary().Items != 0 {
+		t.Fatalf("unexpected summary: %v", p.Summary())
+	}
+}
+
+// TestTimeout ensures the feeds which weren't reached, before the run
+// timed out, are reported.
+func TestTimeout(t *testing.T) {
+
+	// A feed which never responds.
+	slow := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		select {
+		case <-r.Context().Done():
+		case <-time.After(5 * time.Second):
+		}
+	}))
+	defer slow.Close()
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	config := slow.URL + "/rss\nhttps://example.com/never.rss\n"
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte(config), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	p := New()
+	p.out = &bytes.Buffer{}
+	p.SetOutputs([]string{"jsonl"})
+	p.SetTimeout(100 * time.Millisecond)
+
+	errors := p.ProcessFeeds(context.Background(), nil)
+
+	found := false
+	for _, err := range errors {
+		if strings.HasPrefix(err.Error(), "run deadline of 100ms exceeded, after processing 1 of 2 feeds - skipped https://example.com/never.rss") {
+			found = true
+		}
+	}
+	if !found {
+		t.Fatalf("skipped feeds weren't reported: %v", errors)
+	}
+	if !p.Summary().TimedOut || !p.Summary().Interrupted {
+		t.Fatalf("unexpected summary: %v", p.Summary())
+	}
+}
+
+func TestSelectFeeds(t *testing.T) {
+
+	entries := []configfile.Feed{
+		{URL: "https://example.com/ars", Options: []configfile.Option{{Name: "name", Value: "Ars Technica"}}},
+		{URL: "https://example.com/lwn", Options: []configfile.Option{{Name: "name", Value: "LWN"}}},
+		{URL: "https://example.com/other"},
+	}
+
+	p := New()
+	p.SetOnly([]string{"ars", "https://example.com/other", "Ars Technica"})
+
+	out, err := p.selectFeeds(entries)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(out) != 2 || out[0].URL != "https://example.com/ars" || out[1].URL != "https://example.com/other" {
+		t.Fatalf("unexpected feeds selected: %v", out)
+	}
+
+	p.SetOnly([]string{"missing"})
+	_, err = p.selectFeeds(entries)
+	if err == nil {
+		t.Fatalf("expected error with missing feed")
+	}
+}
+
+func TestTemplateFallback(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("uses a POSIX shell")
+	}
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	defer emailer.ResetTemplates()
+
+	err := os.WriteFile(filepath.Join(dir, "broken.tmpl"), []byte("Subject: {{.Nope}}\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+
+	entries := []configfile.Feed{
+		{URL: "https://example.com/news"},
+		{URL: "https://example.com/broken", Options: []configfile.Option{{Name: "template", Value: "broken.tmpl"}}},
+		{URL: "https://example.com/missing", Options: []configfile.Option{{Name: "template", Value: "missing.tmpl"}}},
+	}
+
+	// Missing templates are found before we start.
+	p := New()
+	p.prepareTemplates(entries)
+	if len(p.templateErrors) != 1 || !strings.Contains(p.templateErrors[0].Error(), "error loading template for https://example.com/missing") {
+		t.Fatalf("unexpected errors: %v", p.templateErrors)
+	}
+
+	// Templates which fail when executed are replaced by the built-in
+	// template, and reported once.
+	dest := filepath.Join(dir, "output")
+	p.SetExecCommand("cat > " + dest)
+
+	feed := &gofeed.Feed{Title: "Example"}
+	item := withstate.FeedItem{Item: &gofeed.Item{Title: "Hello", Link: "https://example.com/1"}}
+
+	for i := 0; i < 2; i++ {
+		err = p.execItem(p.newEmailer(entries[1], feed, item, nil), entries[1], feed, item, []string{"steve@example.com"}, "text", "<p>text</p>")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	data, err := os.ReadFile(dest)
+	if err != nil || !strings.Contains(string(data), "X-RSS-Link: https://example.com/1") {
+		t.Fatalf("built-in template wasn't used: %s %v", data, err)
+	}
+	if len(p.templateErrors) != 2 || !strings.Contains(p.templateErrors[1].Error(), "can't evaluate field Nope") {
+		t.Fatalf("unexpected errors: %v", p.templateErrors)
+	}
+}
+
+func TestScheduledFeeds(t *testing.T) {
+
+	entries := []configfile.Feed{
+		{URL: "https://example.com/always"},
+		{URL: "https://example.com/weekdays", Options: []configfile.Option{{Name: "cron", Value: "0 8 * * MON-FRI"}}},
+		{URL: "https://example.com/broken", Options: []configfile.Option{{Name: "cron", Value: "0 8 * *"}}},
+	}
+
+	// Without a previous run everything is processed.
+	p := New()
+	out, errs := p.scheduledFeeds(entries, time.Now())
+	if len(out) != 3 || len(errs) != 0 || p.unscheduled {
+		t.Fatalf("unexpected feeds selected: %v %v", out, errs)
+	}
+
+	// Monday morning.
+	now := time.Date(2024, 1, 1, 8, 5, 0, 0, time.UTC)
+
+	p = New()
+	p.SetLastRun(now.Add(-15 * time.Minute))
+	out, errs = p.scheduledFeeds(entries, now)
+	if len(out) != 2 || out[1].URL != "https://example.com/weekdays" {
+		t.Fatalf("unexpected feeds selected: %v", out)
+	}
+	if len(errs) != 1 {
+		t.Fatalf("expected an error with the broken schedule: %v", errs)
+	}
+
+	// Later the same morning.
+	p = New()
+	p.SetLastRun(now)
+	out, _ = p.scheduledFeeds(entries, now.Add(15*time.Minute))
+	if len(out) != 1 || out[0].URL != "https://example.com/always" {
+		t.Fatalf("unexpected feeds selected: %v", out)
+	}
+	if !p.unscheduled {
+		t.Fatalf("skipped feeds weren't noted")
+	}
+}
+
+func TestPaused(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+	p.SetSendEmail(true)
+	p.SetOutputs([]string{"jsonl"})
+
+	entry := configfile.Feed{URL: "https://example.com/rss", Options: []configfile.Option{{Name: "paused", Value: "true"}}}
+	feed := &gofeed.Feed{Items: []*gofeed.Item{{Title: "Hello", GUID: "rss2email-paused-test"}}}
+
+	// Record our state somewhere temporary.
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	item := withstate.FeedItem{Item: feed.Items[0]}
+
+	err := p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("items of a paused feed were sent: %s", buf.String())
+	}
+	if p.Summary().Skipped != 1 || item.IsNew() {
+		t.Fatalf("item of a paused feed was not recorded: %v", p.Summary())
+	}
+}
+
+func TestDeliveryWindow(t *testing.T) {
+
+	tests := []struct {
+		window string
+		time   string
+		open   bool
+	}{
+		{"08:00-22:00", "07:59", false},
+		{"08:00-22:00", "08:00", true},
+		{"08:00-22:00", "21:59", true},
+		{"08:00-22:00", "22:00", false},
+		{"22:00-07:00", "23:30", true},
+		{"22:00-07:00", "06:00", true},
+		{"22:00-07:00", "12:00", false},
+	}
+
+	for _, test := range tests {
+		w, err := ParseWindow(test.window)
+		if err != nil {
+			t.Fatalf("failed to parse %s: %s", test.window, err)
+		}
+		now, _ := time.Parse("15:04", test.time)
+		if w.Contains(now) != test.open {
+			t.Errorf("%s at %s: expected %v", test.window, test.time, test.open)
+		}
+	}
+
+	for _, bad := range []string{"", "08:00", "8-22", "25:00-07:00", "08:00-08:00"} {
+		_, err := ParseWindow(bad)
+		if err == nil {
+			t.Errorf("expected error parsing '%s'", bad)
+		}
+	}
+
+	// The per-feed option overrides the default.
+	p := New()
+	p.SetDeliveryWindow("08:00-22:00")
+	w, _ := p.deliveryWindow(configfile.Feed{Options: []configfile.Option{{Name: "deliver-hours", Value: "always"}}})
+	if w != nil {
+		t.Fatalf("per-feed window was ignored")
+	}
+	w, _ = p.deliveryWindow(configfile.Feed{})
+	if w == nil || w.String() != "08:00-22:00" {
+		t.Fatalf("default window was ignored: %v", w)
+	}
+}
+
+func TestQueueOutsideWindow(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+	p.SetSendEmail(true)
+	p.SetOutputs([]string{"jsonl"})
+
+	// Record our state somewhere temporary.
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	// A window which is closed now, and one which is open.
+	now := time.Now()
+	closed := fmt.Sprintf("%s-%s", now.Add(time.Hour).Format("15:04"), now.Add(2*time.Hour).Format("15:04"))
+	open := fmt.Sprintf("%s-%s", now.Add(-time.Hour).Format("15:04"), now.Add(time.Hour).Format("15:04"))
+
+	entry := configfile.Feed{URL: "https://example.com/rss", Options: []configfile.Option{{Name: "deliver-hours", Value: closed}}}
+	guid := fmt.Sprintf("rss2email-window-test-%d", now.UnixNano())
+	feed := &gofeed.Feed{Items: []*gofeed.Item{{Title: "Hello", GUID: guid}}}
+
+	err := p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if buf.Len() != 0 {
+		t.Fatalf("item was sent outside the window: %s", buf.String())
+	}
+	if p.Summary().Queued != 1 {
+		t.Fatalf("item was not queued: %v", p.Summary())
+	}
+
+	// Once the window opens the item is sent, even though it is no
+	// longer new.
+	entry.Options[0].Value = open
+	err = p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(buf.String(), guid) || p.Summary().Sent != 1 {
+		t.Fatalf("queued item was not sent: %s", buf.String())
+	}
+
+	queued, _ := withstate.Queued(entry.URL)
+	if len(queued) != 0 {
+		t.Fatalf("item remains queued")
+	}
+}
+
+func TestMinGap(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+	p.SetSendEmail(true)
+	p.SetOutputs([]string{"jsonl"})
+
+	// Record our state somewhere temporary.
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	entry := configfile.Feed{URL: "https://example.com/rss", Options: []configfile.Option{{Name: "min-gap", Value: "1h"}}}
+	feed := &gofeed.Feed{Title: "Example"}
+	for i := 0; i < 3; i++ {
+		guid := fmt.Sprintf("rss2email-gap-test-%d-%d", time.Now().UnixNano(), i)
+		feed.Items = append(feed.Items, &gofeed.Item{Title: fmt.Sprintf("Item %d", i), GUID: guid})
+	}
+
+	// Only the first item is sent.
+	err := p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Summary().Sent != 1 || p.Summary().Queued != 2 {
+		t.Fatalf("unexpected summary: %v", p.Summary())
+	}
+
+	// Once the gap has passed the others are sent together.
+	entry.Options[0].Value = "1ms"
+	time.Sleep(5 * time.Millisecond)
+
+	buf.Reset()
+	err = p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if p.Summary().Sent != 2 || !strings.Contains(buf.String(), "2 new items from Example") {
+		t.Fatalf("queued items were not sent as a digest: %v %s", p.Summary(), buf.String())
+	}
+	if strings.Count(buf.String(), "\n") != 1 {
+		t.Fatalf("expected a single item: %s", buf.String())
+	}
+
+	// Invalid gaps are reported.
+	entry.Options[0].Value = "soon"
+	err = p.processItems(context.Background(), entry, feed, nil)
+	if err == nil {
+		t.Fatalf("expected error with invalid gap")
+	}
+}
+
+func TestParseDigest(t *testing.T) {
+
+	// Monday 1st January 2024, at 09:30.
+	start := time.Date(2024, 1, 1, 9, 30, 0, 0, time.Local)
+
+	tests := []struct {
+		spec string
+		next string
+	}{
+		{"daily", "2024-01-02 00:00"},
+		{"daily 08:00", "2024-01-02 08:00"},
+		{"daily 18:30", "2024-01-01 18:30"},
+		{"weekly", "2024-01-07 00:00"},
+		{"weekly 18:00", "2024-01-07 18:00"},
+		{"weekly sunday 18:00", "2024-01-07 18:00"},
+		{"Weekly Wed 07:15", "2024-01-03 07:15"},
+		{"0 12 * * MON-FRI", "2024-01-01 12:00"},
+	}
+
+	for _, test := range tests {
+		s, err := ParseDigest(test.spec)
+		if err != nil {
+			t.Fatalf("failed to parse '%s': %s", test.spec, err)
+		}
+		next := s.Next(start).Format("2006-01-02 15:04")
+		if next != test.next {
+			t.Errorf("%s: expected %s, got %s", test.spec, test.next, next)
+		}
+	}
+
+	for _, bad := range []string{"", "weekly someday", "daily 25:00", "daily 08:00 extra", "monthly"} {
+		_, err := ParseDigest(bad)
+		if err == nil {
+			t.Errorf("expected error parsing '%s'", bad)
+		}
+	}
+}
+
+func TestDigest(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+
+	p := New()
+	p.out = buf
+	p.SetSendEmail(true)
+	p.SetOutputs([]string{"jsonl"})
+
+	// Record our state somewhere temporary.
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	digest := configfile.Option{Name: "digest", Value: "weekly sunday 18:00"}
+	group := configfile.Option{Name: "group", Value: "News"}
+	entries := []configfile.Feed{
+		{URL: "https://example.com/one", Options: []configfile.Option{digest, group}},
+		{URL: "https://example.com/two", Options: []configfile.Option{digest, group}},
+		{URL: "https://example.com/three", Options: []configfile.Option{digest}},
+	}
+
+	for i, entry := range entries {
+		guid := fmt.Sprintf("rss2email-digest-test-%d-%d", time.Now().UnixNano(), i)
+		feed := &gofeed.Feed{Title: fmt.Sprintf("Feed %d", i), Items: []*gofeed.Item{{Title: "Hello", GUID: guid}}}
+		err := p.processItems(context.Background(), entry, feed, nil)
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+	if buf.Len() != 0 || p.Summary().Queued != 3 {
+		t.Fatalf("items weren't added to the digest: %v %s", p.Summary(), buf.String())
+	}
+
+	// Nothing is sent until the digest is due.
+	errs := p.sendDigests(context.Background(), entries, nil, time.Now())
+	if len(errs) != 0 || buf.Len() != 0 {
+		t.Fatalf("digest sent early: %v %s", errs, buf.String())
+	}
+
+	// A week later we get one digest for the group, and one for the
+	// remaining feed.
+	errs = p.sendDigests(context.Background(), entries, nil, time.Now().AddDate(0, 0, 7))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	output := buf.String()
+	if strings.Count(output, "\n") != 2 {
+		t.Fatalf("expected two digests: %s", output)
+	}
+	if !strings.Contains(output, "2 new items from News") || !strings.Contains(output, "1 new item from Feed 2") {
+		t.Fatalf("unexpected digests: %s", output)
+	}
+	if !strings.Contains(output, "Feed 0") || !strings.Contains(output, "Feed 1") {
+		t.Fatalf("group digest doesn't name its feeds: %s", output)
+	}
+
+	// The digests are only sent once.
+	buf.Reset()
+	p.sendDigests(context.Background(), entries, nil, time.Now().AddDate(0, 0, 7))
+	if buf.Len() != 0 {
+		t.Fatalf("digest sent twice: %s", buf.String())
+	}
+}
+
+// TestDigestContents ensures long digests begin with a table of contents,
+// which is a numbered list within the text part.
+func TestDigestContents(t *testing.T) {
+
+	feed := &gofeed.Feed{Title: "Example"}
+	queued := []withstate.QueuedItem{
+		{Feed: "https://example.com/rss", Item: &gofeed.Item{Title: "First & foremost", Link: "https://example.com/1", Content: "<p>One</p>"}},
+		{Feed: "https://example.com/rss", Item: &gofeed.Item{Title: "Second", Link: "https://example.com/2", Content: "<p>Two</p>"}},
+	}
+
+	item := digestItem(feed, queued)
+	for _, expected := range []string{
+		`<p>1. <a href="#item-1">First &amp; foremost</a><br>2. <a href="#item-2">Second</a></p>`,
+		`<h2 id="item-1">1. <a href="https://example.com/1">First &amp; foremost</a></h2>`,
+		`<h2 id="item-2">2. <a href="https://example.com/2">Second</a></h2>`,
+	} {
+		if !strings.Contains(item.Content, expected) {
+			t.Fatalf("expected %q within digest: %s", expected, item.Content)
+		}
+	}
+
+	text := plainText(item.Content)
+	if !strings.Contains(text, "1. First & foremost\r\n2. Second") || strings.Contains(text, "#item-") {
+		t.Fatalf("unexpected text: %q", text)
+	}
+
+	// A single item needs no contents.
+	item = digestItem(feed, queued[:1])
+	if strings.Contains(item.Content, "Contents") || strings.Contains(item.Content, "#item-") {
+		t.Fatalf("unexpected contents: %s", item.Content)
+	}
+}
+
+// TestGroupDigest ensures the digest of a group may have its own template,
+// and recipients, which don't apply to the emails of its feeds.
+func TestGroupDigest(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "security.tmpl"), []byte("From: {{.From}}\nTo: {{.To}}\nSubject: Security roundup\n\n{{.RawText}}\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write template: %s", err)
+	}
+
+	recorder := emailer.NewRecorder("")
+	p := New()
+	p.SetSendEmail(true)
+	p.SetRecorder(recorder)
+
+	digest := configfile.Option{Name: "digest", Value: "daily"}
+	group := configfile.Option{Name: "group", Value: "Security"}
+	entries := []configfile.Feed{
+		{URL: "https://example.com/one", Options: []configfile.Option{digest, group, {Name: "to", Value: "one@example.com"}}},
+		{URL: "https://example.com/two", Options: []configfile.Option{digest, group, {Name: "digest-template", Value: "security.tmpl"}, {Name: "digest-to", Value: "security@example.com"}}},
+	}
+
+	for i, entry := range entries {
+		guid := fmt.Sprintf("rss2email-group-digest-test-%d-%d", time.Now().UnixNano(), i)
+		feed := &gofeed.Feed{Title: fmt.Sprintf("Feed %d", i), Items: []*gofeed.Item{{Title: "Hello", GUID: guid}}}
+		err := p.processItems(context.Background(), entry, feed, []string{"steve@example.com"})
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+	}
+
+	errs := p.sendDigests(context.Background(), entries, []string{"steve@example.com"}, time.Now().AddDate(0, 0, 2))
+	if len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+
+	messages := recorder.Messages()
+	if len(messages) != 1 {
+		t.Fatalf("expected a single digest, got %d", len(messages))
+	}
+	if strings.Join(messages[0].Recipients, ",") != "security@example.com" || !strings.Contains(string(messages[0].Content), "Subject: Security roundup") {
+		t.Fatalf("unexpected digest: %v %s", messages[0].Recipients, messages[0].Content)
+	}
+
+	// The options of the feeds themselves are unchanged.
+	if entry := digestEntry(entries[:1]); len(entry.Options) != 3 || entry.Options[2].Value != "one@example.com" {
+		t.Fatalf("unexpected options: %v", entry.Options)
+	}
+	if entries[0].Options[2].Value != "one@example.com" {
+		t.Fatalf("the options of the feed were changed: %v", entries[0].Options)
+	}
+}
+
+// fakeFetcher is a Fetcher which returns the given feed.
+type fakeFetcher struct {
+	feed *gofeed.Feed
+}
+
+func (f *fakeFetcher) FetchContext(ctx context.Context) (*gofeed.Feed, error) { return f.feed, nil }
+func (f *fakeFetcher) FinalURL() string                                       { return "" }
+func (f *fakeFetcher) SetLogger(l logger.Logger)                              {}
+func (f *fakeFetcher) SetValidators(v fetch.Validators)                       {}
+func (f *fakeFetcher) Validators() fetch.Validators                           { return fetch.Validators{} }
+func (f *fakeFetcher) NotModified() bool                                      { return false }
+func (f *fakeFetcher) BogusDates() int                                        { return 0 }
+
+func TestFakeFetcher(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("fake:one\nunknown:two\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	fetch.Register("fake", func(entry configfile.Feed) (fetch.Fetcher, error) {
+		return &fakeFetcher{feed: &gofeed.Feed{Title: "Fake", Items: []*gofeed.Item{
+			{Title: "First", Link: "https://example.com/fake/first"},
+			{Title: "Second", Link: "https://example.com/fake/second"},
+		}}}, nil
+	})
+
+	buf := &bytes.Buffer{}
+	p := New()
+	p.out = buf
+	p.SetOutputs([]string{"jsonl"})
+	p.ProcessFeeds(context.Background(), nil)
+
+	summary := p.Summary()
+	if summary.Sent != 2 || strings.Count(buf.String(), "\n") != 2 {
+		t.Fatalf("unexpected result: %v %s", summary, buf.String())
+	}
+	if len(summary.Errors) != 1 || !strings.Contains(summary.Errors[0].Error(), "cannot fetch 'unknown:two'") {
+		t.Fatalf("unexpected errors: %v", summary.Errors)
+	}
+}
+
+// TestConfigWarnings ensures the problems with our configuration which
+// don't prevent us from running are given to our logger.
+func TestConfigWarnings(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/rss\n - exlude: foo\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+	fixtures := t.TempDir()
+	err = os.WriteFile(filepath.Join(fixtures, "example.com_rss.xml"), []byte(`<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title></channel></rss>`), 0644)
+	if err != nil {
+		t.Fatalf("failed to write fixture: %s", err)
+	}
+
+	buf := &bytes.Buffer{}
+	p := New()
+	p.out = &bytes.Buffer{}
+	p.SetLogger(logger.New(buf))
+	p.SetOutputs([]string{"jsonl"})
+	p.SetFixtures(fixtures)
+	if errs := p.ProcessFeeds(context.Background(), nil); len(errs) != 0 {
+		t.Fatalf("unexpected errors: %v", errs)
+	}
+	if !strings.Contains(buf.String(), "feeds.txt:2: unknown option 'exlude'") {
+		t.Fatalf("warning wasn't logged: %q", buf.String())
+	}
+}
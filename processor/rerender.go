@@ -0,0 +1,40 @@
+package processor
+
+import (
+	"context"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/favicon"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Rerender sends the given cached item to our outputs again, rendered as
+// it would be now, with the current template, and the options of the given
+// feed.
+//
+// The item is changed as it would be if it were new, but its filters are
+// ignored, and its state isn't changed.
+func (p *Processor) Rerender(ctx context.Context, entry configfile.Feed, cached withstate.QueuedItem, recipients []string) error {
+
+	feed := cached.Source()
+	xp := cached.Item
+
+	err := p.prepareItem(entry, feed, xp)
+	if err != nil {
+		return err
+	}
+	item := withstate.FeedItem{Item: xp}
+
+	content, err := item.HTMLContent()
+	if err != nil {
+		content = item.RawContent()
+	}
+
+	// Failing to fetch the icon isn't fatal.
+	var icon *favicon.Icon
+	if p.wantFavicon(entry) {
+		icon, _ = favicon.New().Get(feed)
+	}
+
+	return p.sendItem(ctx, entry, feed, item, icon, recipients, content)
+}
@@ -0,0 +1,71 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// TestRerender ensures the items we send are cached as they were fetched,
+// and may be sent again with different options.
+func TestRerender(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	buf := &bytes.Buffer{}
+	p := New()
+	p.out = buf
+	p.SetOutputs([]string{"jsonl"})
+
+	entry := configfile.Feed{URL: "https://example.com/rss", Options: []configfile.Option{
+		{Name: "rewrite-title", Value: "s/^Draft/Post/"},
+		{Name: "exclude-title", Value: "Skipped"},
+	}}
+	feed := &gofeed.Feed{Title: "Example", Items: []*gofeed.Item{
+		{Title: "Draft one", GUID: "rss2email-rerender-1", Content: "<p>One</p>"},
+		{Title: "Skipped", GUID: "rss2email-rerender-2", Content: "<p>Two</p>"},
+	}}
+
+	// Nothing is cached by default.
+	err := p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	cached, _ := withstate.CachedItems(entry.URL, time.Time{})
+	if len(cached) != 0 {
+		t.Fatalf("unexpected cached items: %v", cached)
+	}
+
+	feed.Items = append(feed.Items, &gofeed.Item{Title: "Draft three", GUID: "rss2email-rerender-3", Content: "<p>Three</p>"})
+	p.SetCacheItems(time.Hour)
+	err = p.processItems(context.Background(), entry, feed, nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	cached, err = withstate.CachedItems(entry.URL, time.Time{})
+	if err != nil || len(cached) != 1 {
+		t.Fatalf("unexpected cached items: %v %v", cached, err)
+	}
+	if cached[0].Item.Title != "Draft three" || cached[0].FeedTitle != "Example" {
+		t.Fatalf("the item wasn't cached as it was fetched: %v", cached[0].Item)
+	}
+
+	// Render it again, with new options.
+	buf.Reset()
+	entry.Options = []configfile.Option{{Name: "rewrite-title", Value: "s/^Draft/Article/"}}
+	err = p.Rerender(context.Background(), entry, cached[0], nil)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if !strings.Contains(buf.String(), `"title":"Article three"`) {
+		t.Fatalf("unexpected output: %s", buf.String())
+	}
+}
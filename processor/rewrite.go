@@ -0,0 +1,203 @@
+package processor
+
+import (
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// rewriteOptions maps the per-feed options which rewrite items to the
+// field of the item each rewrites.
+var rewriteOptions = map[string]string{
+	"rewrite":       "content",
+	"rewrite-link":  "link",
+	"rewrite-title": "title",
+}
+
+// rewrite is a sed-style substitution, such as "s/^\[Sponsored\] //",
+// which is applied to a field of each item of a feed.
+type rewrite struct {
+
+	// field is the field of the item which is rewritten.
+	field string
+
+	// re matches the text to replace, and repl is its replacement,
+	// in the syntax of regexp.Expand.
+	re   *regexp.Regexp
+	repl string
+
+	// global is true if every match is replaced, not just the first.
+	global bool
+}
+
+// parseRewrite parses a substitution of the form "s/regexp/replacement/",
+// which may be followed by the flags "g", to replace every match, and "i",
+// to match without regard to case.
+//
+// As with sed any character may be used in place of the slashes, and the
+// replacement may refer to the groups of the match as "\1", or the whole
+// match as "&".
+func parseRewrite(spec string) (*rewrite, error) {
+
+	if len(spec) < 4 || spec[0] != 's' {
+		return nil, fmt.Errorf("invalid rewrite '%s', expected 's/regexp/replacement/'", spec)
+	}
+	delim := spec[1]
+	if delim == '\\' || !(unicode.IsPunct(rune(delim)) || unicode.IsSymbol(rune(delim))) {
+		return nil, fmt.Errorf("invalid rewrite '%s', the delimiter must be punctuation such as '/'", spec)
+	}
+
+	// Split upon the delimiter, unless it is escaped.
+	var parts []string
+	var cur strings.Builder
+	for i := 2; i < len(spec); i++ {
+		switch {
+		case spec[i] == '\\' && i+1 < len(spec) && spec[i+1] == delim:
+			cur.WriteByte(delim)
+			i++
+		case spec[i] == '\\' && i+1 < len(spec):
+			cur.WriteString(spec[i : i+2])
+			i++
+		case spec[i] == delim:
+			parts = append(parts, cur.String())
+			cur.Reset()
+		default:
+			cur.WriteByte(spec[i])
+		}
+	}
+	parts = append(parts, cur.String())
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("invalid rewrite '%s', expected 's/regexp/replacement/'", spec)
+	}
+
+	r := &rewrite{repl: replacement(parts[1])}
+
+	pattern := parts[0]
+	for _, flag := range parts[2] {
+		switch flag {
+		case 'g':
+			r.global = true
+		case 'i':
+			pattern = "(?i)" + pattern
+		default:
+			return nil, fmt.Errorf("invalid rewrite '%s', unknown flag '%c'", spec, flag)
+		}
+	}
+
+	var err error
+	r.re, err = regexp.Compile(pattern)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rewrite '%s': %s", spec, err)
+	}
+	return r, nil
+}
+
+// replacement converts the replacement of a sed-style substitution into
+// the syntax of regexp.Expand.
+func replacement(repl string) string {
+
+	var sb strings.Builder
+	for i := 0; i < len(repl); i++ {
+		c := repl[i]
+		switch {
+		case c == '\\' && i+1 < len(repl):
+			i++
+			switch n := repl[i]; {
+			case n >= '0' && n <= '9':
+				sb.WriteString("${" + string(n) + "}")
+			case n == 'n':
+				sb.WriteByte('\n')
+			case n == '$':
+				sb.WriteString("$$")
+			default:
+				sb.WriteByte(n)
+			}
+		case c == '&':
+			sb.WriteString("${0}")
+		case c == '$':
+			sb.WriteString("$$")
+		default:
+			sb.WriteByte(c)
+		}
+	}
+	return sb.String()
+}
+
+// replace returns the given text, with the substitution applied.
+func (r *rewrite) replace(s string) string {
+
+	if r.global {
+		return r.re.ReplaceAllString(s, r.repl)
+	}
+
+	m := r.re.FindStringSubmatchIndex(s)
+	if m == nil {
+		return s
+	}
+	return s[:m[0]] + string(r.re.ExpandString(nil, r.repl, s, m)) + s[m[1]:]
+}
+
+// CheckRewrite returns an error if the given per-feed option is one of
+// those which rewrite items, including "strip-selector", and its value is
+// invalid.
+func CheckRewrite(opt configfile.Option) error {
+
+	if opt.Name == "strip-selector" {
+		return checkSelector(opt.Value)
+	}
+	if _, ok := rewriteOptions[opt.Name]; !ok {
+		return nil
+	}
+	_, err := parseRewrite(opt.Value)
+	return err
+}
+
+// rewrites returns the substitutions given by the options of the feed,
+// via the "rewrite", "rewrite-title", and "rewrite-link" options, in the
+// order they're given.
+func rewrites(config configfile.Feed) ([]*rewrite, error) {
+
+	var res []*rewrite
+	for _, opt := range config.Options {
+
+		field, ok := rewriteOptions[opt.Name]
+		if !ok {
+			continue
+		}
+
+		r, err := parseRewrite(opt.Value)
+		if err != nil {
+			return nil, err
+		}
+		r.field = field
+		res = append(res, r)
+	}
+	return res, nil
+}
+
+// applyRewrites applies the given substitutions to the item.
+//
+// If the link of an item without a GUID is rewritten its original link
+// is used as its GUID, so that the item is still recognised as having
+// been seen.
+func applyRewrites(rules []*rewrite, xp *gofeed.Item) {
+
+	for _, r := range rules {
+		switch r.field {
+		case "title":
+			xp.Title = r.replace(xp.Title)
+		case "content":
+			xp.Content = r.replace(xp.Content)
+			xp.Description = r.replace(xp.Description)
+		case "link":
+			if xp.GUID == "" {
+				xp.GUID = xp.Link
+			}
+			xp.Link = r.replace(xp.Link)
+		}
+	}
+}
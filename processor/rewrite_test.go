@@ -0,0 +1,83 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestParseRewrite(t *testing.T) {
+
+	tests := []struct {
+		spec   string
+		input  string
+		output string
+	}{
+		{`s/^\[Sponsored\] //`, "[Sponsored] Buy this", "Buy this"},
+		{`s/a/b/`, "banana", "bbnana"},
+		{`s/a/b/g`, "banana", "bbnbnb"},
+		{`s/A/b/gi`, "banana", "bbnbnb"},
+		{`s#https?://#//#`, "http://example.com/", "//example.com/"},
+		{`s/\//|/g`, "a/b/c", "a|b|c"},
+		{`s/(\w+) (\w+)/\2 \1/`, "hello world", "world hello"},
+		{`s/[0-9]+/<&>/g`, "a1b22", "a<1>b<22>"},
+		{`s/x/$1 \&/`, "x", "$1 &"},
+		{`s|<p class="ad">.*?</p>||g`, `<p>one</p><p class="ad">buy</p><p>two</p>`, "<p>one</p><p>two</p>"},
+	}
+
+	for _, test := range tests {
+		r, err := parseRewrite(test.spec)
+		if err != nil {
+			t.Fatalf("unexpected error parsing %s: %s", test.spec, err)
+		}
+		if out := r.replace(test.input); out != test.output {
+			t.Fatalf("%s: %q != %q", test.spec, out, test.output)
+		}
+	}
+
+	for _, spec := range []string{"", "s", "y/a/b/", "s/a/b", "s/a/b/c/", "s/a/b/x", "s/(/b/", "sXaXbX", `s\a\b\`} {
+		_, err := parseRewrite(spec)
+		if err == nil {
+			t.Fatalf("expected error parsing %q", spec)
+		}
+	}
+}
+
+func TestRewrites(t *testing.T) {
+
+	entry := configfile.Feed{Options: []configfile.Option{
+		{Name: "rewrite-title", Value: `s/^\[Sponsored\] //`},
+		{Name: "rewrite", Value: `s/ad//g`},
+		{Name: "rewrite-link", Value: `s/\?.*$//`},
+		{Name: "rewrite-title", Value: `s/$/!/`},
+	}}
+
+	rules, err := rewrites(entry)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	xp := &gofeed.Item{Title: "[Sponsored] Title", Content: "bad", Description: "sad", Link: "https://example.com/?utm=x"}
+	applyRewrites(rules, xp)
+
+	if xp.Title != "Title!" || xp.Content != "b" || xp.Description != "s" || xp.Link != "https://example.com/" {
+		t.Fatalf("unexpected item %v", xp)
+	}
+
+	// The item is still known by its original link.
+	if xp.GUID != "https://example.com/?utm=x" {
+		t.Fatalf("unexpected GUID %s", xp.GUID)
+	}
+
+	_, err = rewrites(configfile.Feed{Options: []configfile.Option{{Name: "rewrite", Value: "bogus"}}})
+	if err == nil {
+		t.Fatalf("expected error with bogus rewrite")
+	}
+	if CheckRewrite(configfile.Option{Name: "rewrite-link", Value: "bogus"}) == nil {
+		t.Fatalf("expected error with bogus rewrite")
+	}
+	if CheckRewrite(configfile.Option{Name: "name", Value: "bogus"}) != nil {
+		t.Fatalf("unexpected error with other option")
+	}
+}
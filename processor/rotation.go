@@ -0,0 +1,187 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// rotationRuns is the number of consecutive runs in which a feed must
+// publish items we've seen, under new GUIDs, before its items are
+// identified by their links instead.
+const rotationRuns = 2
+
+// identityByLink returns true if the items of the given feed are identified
+// by their links, rather than their GUIDs, via its "identity" option.
+func identityByLink(config configfile.Feed) (bool, error) {
+
+	byLink := false
+	for _, opt := range config.Options {
+		if opt.Name == "identity" {
+			switch strings.TrimSpace(opt.Value) {
+			case "guid":
+				byLink = false
+			case "link":
+				byLink = true
+			default:
+				return false, fmt.Errorf("invalid identity '%s', expected \"guid\" or \"link\"", opt.Value)
+			}
+		}
+	}
+	return byLink, nil
+}
+
+// CheckIdentity returns an error if the given per-feed option is the
+// "identity" option, and its value is invalid.
+func CheckIdentity(opt configfile.Option) error {
+	if opt.Name != "identity" {
+		return nil
+	}
+	_, err := identityByLink(configfile.Feed{Options: []configfile.Option{opt}})
+	return err
+}
+
+// rotatingFeed records a feed which repeatedly published items we'd seen
+// under new GUIDs.
+type rotatingFeed struct {
+
+	// entry is the configuration of the feed, and items its items.
+	entry configfile.Feed
+	items []*gofeed.Item
+}
+
+// uniqueLinks returns true if each of the given items has a link, which
+// no other item shares, so that they may be identified by their links.
+func uniqueLinks(items []*gofeed.Item) bool {
+
+	seen := make(map[string]bool)
+	for _, xp := range items {
+		if xp.Link == "" || seen[xp.Link] {
+			return false
+		}
+		seen[xp.Link] = true
+	}
+	return len(items) > 0
+}
+
+// checkRotation records whether the given feed published items we'd seen
+// under new GUIDs during this run, and if it has done so for several runs
+// arranges for its items to be identified by their links instead.
+func (p *Processor) checkRotation(f *feedState) error {
+
+	if f.prints == nil {
+		return nil
+	}
+
+	if f.rotated == 0 {
+		f.prints.Runs = 0
+	} else {
+		f.prints.Runs++
+	}
+
+	if f.prints.Runs >= rotationRuns {
+		if uniqueLinks(f.feed.Items) {
+			p.rotating = append(p.rotating, rotatingFeed{entry: f.entry, items: f.feed.Items})
+			f.prints.Runs = 0
+		} else {
+			p.message("\tItems are repeatedly seen with new GUIDs, but their links aren't unique, so can't identify them\n")
+		}
+	}
+	return f.prints.Save()
+}
+
+// switchIdentities changes the feeds which repeatedly published items we'd
+// seen, under new GUIDs, to identify their items by their links, via their
+// "identity" option, and notifies the recipients.
+//
+// The current items of those feeds are recorded as seen by their links,
+// so that they aren't sent again.  The configuration file is left alone
+// when we're being tested.
+func (p *Processor) switchIdentities(conf *configfile.ConfigFile, recipients []string) error {
+
+	if len(p.rotating) == 0 {
+		return nil
+	}
+	testing := p.recorder != nil || p.fixtures != ""
+
+	var switched, manual []configfile.Feed
+	for _, r := range p.rotating {
+
+		for _, xp := range r.items {
+			if item := (withstate.FeedItem{Item: xp}); !item.IsNew() {
+				(&withstate.FeedItem{Item: xp, ByLink: true}).RecordSeen()
+			}
+		}
+
+		// Replace any existing identity, upon a copy of the options
+		// which don't share the storage of those we parsed.
+		entry := r.entry
+		entry.Options = nil
+		for _, opt := range r.entry.Options {
+			if opt.Name != "identity" {
+				entry.Options = append(entry.Options, opt)
+			}
+		}
+		entry.Options = append(entry.Options, configfile.Option{Name: "identity", Value: "link"})
+
+		// We change nothing while being tested, and the feeds of a
+		// feed-reader, or a remote list of feeds, can't be changed
+		// by us.
+		if testing {
+			p.message(fmt.Sprintf("\tNot adding \"identity: link\" to %s while testing\n", entry.Label()))
+			manual = append(manual, entry)
+		} else if configfile.Remote() == "" && conf.Update(entry) {
+			switched = append(switched, entry)
+		} else {
+			manual = append(manual, entry)
+		}
+	}
+
+	if len(switched) > 0 {
+		err := conf.Save()
+		if err != nil {
+			return fmt.Errorf("error changing the identity of feeds - %s", err)
+		}
+	}
+
+	var sb strings.Builder
+	fmt.Fprintf(&sb, "The following feeds have repeatedly published items which we'd already\n")
+	fmt.Fprintf(&sb, "seen, with the same link and title, under new GUIDs.  Those items were\n")
+	fmt.Fprintf(&sb, "skipped, rather than sent again.\n\n")
+	list := func(feeds []configfile.Feed) {
+		for _, entry := range feeds {
+			fmt.Fprintf(&sb, "  %s\n", entry.Label())
+			if entry.Label() != entry.URL {
+				fmt.Fprintf(&sb, "    %s\n", entry.URL)
+			}
+		}
+	}
+	if len(switched) > 0 {
+		fmt.Fprintf(&sb, "These feeds now identify their items by their links, via the\n")
+		fmt.Fprintf(&sb, "\"identity\" option which was added to %s:\n\n", conf.Path())
+		list(switched)
+		fmt.Fprintf(&sb, "\n")
+	}
+	if len(manual) > 0 {
+		fmt.Fprintf(&sb, "These feeds couldn't be changed, please add the option \"identity: link\"\n")
+		fmt.Fprintf(&sb, "to them, so that their items are identified by their links:\n\n")
+		list(manual)
+		fmt.Fprintf(&sb, "\n")
+	}
+
+	p.message(sb.String())
+
+	if !p.send || !p.wantOutput("email") || len(recipients) == 0 {
+		return nil
+	}
+
+	subject := fmt.Sprintf("rss2email: %s changing item GUIDs", plural(len(p.rotating), "feed"))
+	err := p.sendNotice(recipients, subject, sb.String())
+	if err != nil {
+		return fmt.Errorf("failed to email the list of feeds changing their GUIDs: %s", err)
+	}
+	return nil
+}
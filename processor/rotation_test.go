@@ -0,0 +1,101 @@
+package processor
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestIdentityOption(t *testing.T) {
+
+	for value, expected := range map[string]bool{"guid": false, "link": true, " link ": true} {
+		byLink, err := identityByLink(configfile.Feed{Options: []configfile.Option{{Name: "identity", Value: value}}})
+		if err != nil || byLink != expected {
+			t.Fatalf("unexpected result for %q: %v %v", value, byLink, err)
+		}
+	}
+	if CheckIdentity(configfile.Option{Name: "identity", Value: "title"}) == nil {
+		t.Fatalf("expected an error")
+	}
+	if CheckIdentity(configfile.Option{Name: "name", Value: "title"}) != nil {
+		t.Fatalf("unexpected error")
+	}
+}
+
+func TestRotation(t *testing.T) {
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	// The feed is served, rather than read from fixtures, as the
+	// configuration isn't changed while we're being tested.
+	var content string
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, content)
+	}))
+	defer srv.Close()
+
+	// Any identity the feed already has is replaced.
+	config := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(config, []byte(srv.URL+"/rss\n - identity: guid\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	// Each run the feed changes the GUIDs of its items.
+	run := func(n int, extra string) string {
+
+		items := extra
+		for _, name := range []string{"one", "two"} {
+			items += fmt.Sprintf(`<item><title>%s</title><link>https://example.com/%s</link><guid>%s-%d</guid></item>`, name, name, name, n)
+		}
+		content = `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>` + items + `</channel></rss>`
+
+		buf := &bytes.Buffer{}
+		p := New()
+		p.out = buf
+		p.SetOutputs([]string{"jsonl"})
+		if errs := p.ProcessFeeds(context.Background(), nil); len(errs) != 0 {
+			t.Fatalf("unexpected errors: %v", errs)
+		}
+		return buf.String()
+	}
+
+	if out := run(1, ""); strings.Count(out, "\n") != 2 {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	// The items aren't sent again, and after two runs the feed is
+	// changed to identify them by their links.
+	if out := run(2, ""); out != "" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	data, _ := os.ReadFile(config)
+	if strings.Contains(string(data), "identity:link") {
+		t.Fatalf("the identity was changed too soon: %s", data)
+	}
+	if out := run(3, ""); out != "" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+	data, _ = os.ReadFile(config)
+	if !strings.Contains(string(data), "identity:link") || strings.Contains(string(data), "identity:guid") {
+		t.Fatalf("the identity wasn't changed: %s", data)
+	}
+
+	// Once it is new items are still sent.
+	out := run(4, `<item><title>three</title><link>https://example.com/three</link><guid>three</guid></item>`)
+	if strings.Count(out, "\n") != 1 || !strings.Contains(out, `"title":"three"`) {
+		t.Fatalf("unexpected output: %s", out)
+	}
+}
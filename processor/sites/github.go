@@ -0,0 +1,107 @@
+package sites
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// githubLink matches the links of items in the release, tag, and commit
+// feeds of GitHub repositories.
+var githubLink = regexp.MustCompile(`^https?://github\.com/([^/]+)/([^/]+)/(releases/tag|commit)/([^/?#]+)`)
+
+// github handles the release and commit feeds of GitHub repositories.
+//
+// The content of these items is either HTML, or Markdown which is
+// not rendered.
+type github struct{}
+
+// Matches returns true for the releases and commits of a repository.
+func (g *github) Matches(feed *gofeed.Feed, item *gofeed.Item) bool {
+	return githubLink.MatchString(item.Link)
+}
+
+// Enhance sets the subject to include the repository name, renders the
+// content of the item, and adds links to the tag and comparison, or the
+// commit.
+func (g *github) Enhance(feed *gofeed.Feed, item *gofeed.Item, opts []configfile.Option) {
+
+	m := githubLink.FindStringSubmatch(item.Link)
+	repo := "https://github.com/" + m[1] + "/" + m[2]
+	ref, err := url.PathUnescape(m[4])
+	if err != nil {
+		ref = m[4]
+	}
+
+	// The content might be HTML, or Markdown.
+	content := strings.TrimSpace(item.Content)
+	if content == "" {
+		content = strings.TrimSpace(item.Description)
+	}
+	if !looksLikeHTML(content) {
+		content = markdown(content)
+	}
+
+	var out strings.Builder
+
+	if m[3] == "commit" {
+
+		// The title of a commit is the first line of the message.
+		title := strings.TrimSpace(strings.SplitN(item.Title, "\n", 2)[0])
+		item.Title = m[2] + ": " + title
+
+		fmt.Fprintf(&out, "<p>Commit: <a href=\"%s\">%s</a> | <a href=\"%s/tree/%s\">Browse files</a></p>\n",
+			html.EscapeString(item.Link), html.EscapeString(short(ref)),
+			html.EscapeString(repo), url.PathEscape(ref))
+	} else {
+
+		item.Title = m[2] + ": " + ref
+
+		fmt.Fprintf(&out, "<p>Tag: <a href=\"%s/tree/%s\">%s</a>",
+			html.EscapeString(repo), url.PathEscape(ref), html.EscapeString(ref))
+
+		// The previous release is the next item in the feed.
+		if prev := g.previous(feed, item); prev != "" {
+			fmt.Fprintf(&out, " | <a href=\"%s/compare/%s...%s\">Changes since %s</a>",
+				html.EscapeString(repo), url.PathEscape(prev), url.PathEscape(ref), html.EscapeString(prev))
+		}
+		out.WriteString("</p>\n")
+	}
+
+	out.WriteString(content)
+	item.Content = out.String()
+}
+
+// previous returns the tag of the release before the given one, if it
+// is present in the feed.
+func (g *github) previous(feed *gofeed.Feed, item *gofeed.Item) string {
+
+	for i, cur := range feed.Items {
+		if cur != item || i+1 >= len(feed.Items) {
+			continue
+		}
+		m := githubLink.FindStringSubmatch(feed.Items[i+1].Link)
+		if m == nil || m[3] != "releases/tag" {
+			return ""
+		}
+		prev, err := url.PathUnescape(m[4])
+		if err != nil {
+			return ""
+		}
+		return prev
+	}
+	return ""
+}
+
+// short returns the abbreviated form of a commit hash.
+func short(sha string) string {
+	if len(sha) > 7 {
+		return sha[:7]
+	}
+	return sha
+}
@@ -0,0 +1,114 @@
+package sites
+
+import (
+	"strings"
+	"testing"
+)
+
+var githubReleases = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>tag:github.com,2008:https://github.com/skx/rss2email/releases</id>
+  <link type="text/html" rel="alternate" href="https://github.com/skx/rss2email/releases"/>
+  <title>Release notes from rss2email</title>
+  <entry>
+    <id>tag:github.com,2008:Repository/1/v1.2.3</id>
+    <link rel="alternate" type="text/html" href="https://github.com/skx/rss2email/releases/tag/v1.2.3"/>
+    <title>v1.2.3</title>
+    <content type="html">## Changes
+
+* Fixed a **bug** in ` + "`foo`" + `
+* See [the docs](https://example.com/?a=b&amp;c=d)
+
+&lt;script&gt; is not HTML</content>
+  </entry>
+  <entry>
+    <id>tag:github.com,2008:Repository/1/v1.2.2</id>
+    <link rel="alternate" type="text/html" href="https://github.com/skx/rss2email/releases/tag/v1.2.2"/>
+    <title>Release 1.2.2</title>
+    <content type="html">&lt;p&gt;Already HTML&lt;/p&gt;</content>
+  </entry>
+</feed>`
+
+var githubCommits = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+  <id>tag:github.com,2008:/skx/rss2email/commits/master</id>
+  <title>Recent Commits to rss2email:master</title>
+  <entry>
+    <id>tag:github.com,2008:Grit::Commit/0123456789abcdef</id>
+    <link type="text/html" rel="alternate" href="https://github.com/skx/rss2email/commit/0123456789abcdef"/>
+    <title>Fixed the thing</title>
+    <content type="html">&lt;pre style='white-space:pre-wrap;width:81ex'&gt;Fixed the thing&lt;/pre&gt;</content>
+  </entry>
+</feed>`
+
+// TestGitHubRelease tests handling a release
+func TestGitHubRelease(t *testing.T) {
+
+	feed := parse(t, githubReleases)
+
+	item := feed.Items[0]
+	Enhance(feed, item, nil)
+
+	if item.Title != "rss2email: v1.2.3" {
+		t.Fatalf("unexpected title %s", item.Title)
+	}
+
+	expected := []string{
+		`<a href="https://github.com/skx/rss2email/tree/v1.2.3">v1.2.3</a>`,
+		`<a href="https://github.com/skx/rss2email/compare/v1.2.2...v1.2.3">`,
+		"<h2>Changes</h2>",
+		"<li>Fixed a <strong>bug</strong> in <code>foo</code></li>",
+		`<a href="https://example.com/?a=b&amp;c=d">the docs</a>`,
+		"&lt;script&gt; is not HTML",
+	}
+	for _, txt := range expected {
+		if !strings.Contains(item.Content, txt) {
+			t.Fatalf("missing %s in %s", txt, item.Content)
+		}
+	}
+
+	// The oldest release has no comparison, and is HTML already
+	item = feed.Items[1]
+	Enhance(feed, item, nil)
+
+	if strings.Contains(item.Content, "compare") {
+		t.Fatalf("unexpected comparison in %s", item.Content)
+	}
+	if !strings.Contains(item.Content, "<p>Already HTML</p>") {
+		t.Fatalf("HTML content was changed %s", item.Content)
+	}
+}
+
+// TestGitHubCommit tests handling a commit
+func TestGitHubCommit(t *testing.T) {
+
+	feed := parse(t, githubCommits)
+
+	item := feed.Items[0]
+	Enhance(feed, item, nil)
+
+	if item.Title != "rss2email: Fixed the thing" {
+		t.Fatalf("unexpected title %s", item.Title)
+	}
+	if !strings.Contains(item.Content, `>0123456</a>`) {
+		t.Fatalf("missing commit link in %s", item.Content)
+	}
+}
+
+// TestMarkdown tests some edge-cases of our markdown conversion
+func TestMarkdown(t *testing.T) {
+
+	out := markdown("```\n<b>code</b>\n```\ntext")
+	if !strings.Contains(out, "<pre>&lt;b&gt;code&lt;/b&gt;\n</pre>") {
+		t.Fatalf("unexpected code block %s", out)
+	}
+	if !strings.Contains(out, "<p>text</p>") {
+		t.Fatalf("unexpected paragraph %s", out)
+	}
+
+	// Unterminated blocks are closed
+	out = markdown("```\ncode")
+	if !strings.HasSuffix(out, "</pre>\n") {
+		t.Fatalf("unterminated code block %s", out)
+	}
+}
@@ -0,0 +1,125 @@
+package sites
+
+import (
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+)
+
+var (
+	// htmlTag matches the start of a HTML element.
+	htmlTag = regexp.MustCompile(`<(p|div|pre|ul|ol|h[1-6]|br|a|table|span|img)[\s/>]`)
+
+	// mdHeading matches a Markdown heading.
+	mdHeading = regexp.MustCompile(`^(#{1,6})\s+(.*)$`)
+
+	// mdBullet matches a Markdown list-item.
+	mdBullet = regexp.MustCompile(`^\s*[-*+]\s+(.*)$`)
+
+	// mdLink matches a Markdown link, after HTML-escaping.
+	mdLink = regexp.MustCompile(`\[([^\]]+)\]\((https?://[^)\s]+)\)`)
+
+	// mdCode matches inline code.
+	mdCode = regexp.MustCompile("`([^`]+)`")
+
+	// mdBold matches bold text.
+	mdBold = regexp.MustCompile(`\*\*([^*]+)\*\*`)
+)
+
+// looksLikeHTML returns true if the given content contains HTML.
+func looksLikeHTML(content string) bool {
+	return htmlTag.MatchString(strings.ToLower(content))
+}
+
+// markdown converts the given Markdown to HTML.
+//
+// This is deliberately minimal, supporting only the things which are
+// commonly found in release notes: headings, lists, code blocks, links,
+// inline code, and bold text.
+func markdown(content string) string {
+
+	var out strings.Builder
+
+	list := false
+	code := false
+	para := []string{}
+
+	// flush writes out any pending paragraph.
+	flush := func() {
+		if len(para) > 0 {
+			fmt.Fprintf(&out, "<p>%s</p>\n", strings.Join(para, "\n"))
+			para = para[:0]
+		}
+	}
+	// endList closes any open list.
+	endList := func() {
+		if list {
+			out.WriteString("</ul>\n")
+			list = false
+		}
+	}
+
+	for _, line := range strings.Split(strings.ReplaceAll(content, "\r\n", "\n"), "\n") {
+
+		// Code blocks are copied literally.
+		if strings.HasPrefix(strings.TrimSpace(line), "```") {
+			flush()
+			endList()
+			if code {
+				out.WriteString("</pre>\n")
+			} else {
+				out.WriteString("<pre>")
+			}
+			code = !code
+			continue
+		}
+		if code {
+			out.WriteString(html.EscapeString(line) + "\n")
+			continue
+		}
+
+		if strings.TrimSpace(line) == "" {
+			flush()
+			endList()
+			continue
+		}
+
+		if m := mdHeading.FindStringSubmatch(line); m != nil {
+			flush()
+			endList()
+			fmt.Fprintf(&out, "<h%d>%s</h%d>\n", len(m[1]), inline(m[2]), len(m[1]))
+			continue
+		}
+
+		if m := mdBullet.FindStringSubmatch(line); m != nil {
+			flush()
+			if !list {
+				out.WriteString("<ul>\n")
+				list = true
+			}
+			fmt.Fprintf(&out, "<li>%s</li>\n", inline(m[1]))
+			continue
+		}
+
+		endList()
+		para = append(para, inline(line))
+	}
+
+	flush()
+	endList()
+	if code {
+		out.WriteString("</pre>\n")
+	}
+
+	return out.String()
+}
+
+// inline escapes the given text, and converts any inline Markdown.
+func inline(text string) string {
+	text = html.EscapeString(strings.TrimSpace(text))
+	text = mdCode.ReplaceAllString(text, "<code>$1</code>")
+	text = mdBold.ReplaceAllString(text, "<strong>$1</strong>")
+	text = mdLink.ReplaceAllString(text, `<a href="$2">$1</a>`)
+	return text
+}
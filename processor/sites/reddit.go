@@ -0,0 +1,94 @@
+package sites
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// reddit handles the feeds of subreddits, users, and searches.
+//
+// The content of these items is a table containing a thumbnail, the
+// text of the post, and a "submitted by" line, along with "[link]" and
+// "[comments]" links.
+type reddit struct{}
+
+// Matches returns true for items which link to reddit, and contain the
+// usual reddit links.
+func (r *reddit) Matches(feed *gofeed.Feed, item *gofeed.Item) bool {
+
+	u, err := url.Parse(item.Link)
+	if err != nil {
+		return false
+	}
+
+	host := strings.ToLower(u.Hostname())
+	if host != "reddit.com" && !strings.HasSuffix(host, ".reddit.com") {
+		return false
+	}
+
+	return strings.Contains(item.Content, "[comments]")
+}
+
+// Enhance replaces the content of the item with links to the external
+// URL and the comments, followed by the self-text of the post.
+//
+// The "reddit-text" option may be used to disable the self-text.
+func (r *reddit) Enhance(feed *gofeed.Feed, item *gofeed.Item, opts []configfile.Option) {
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(item.Content))
+	if err != nil {
+		return
+	}
+
+	link := ""
+	comments := ""
+	doc.Find("a").Each(func(i int, a *goquery.Selection) {
+		href, _ := a.Attr("href")
+		switch strings.TrimSpace(a.Text()) {
+		case "[link]":
+			link = href
+		case "[comments]":
+			comments = href
+		}
+	})
+
+	if comments == "" {
+		comments = item.Link
+	}
+
+	var out strings.Builder
+
+	// Link posts point somewhere other than the comments.
+	if link != "" && link != comments {
+		fmt.Fprintf(&out, "<p>Link: <a href=\"%s\">%s</a></p>\n",
+			html.EscapeString(link), html.EscapeString(link))
+
+		// Show images inline.
+		switch strings.ToLower(path.Ext(link)) {
+		case ".jpg", ".jpeg", ".png", ".gif", ".webp":
+			fmt.Fprintf(&out, "<p><img src=\"%s\" alt=\"\"></p>\n", html.EscapeString(link))
+		}
+	}
+	fmt.Fprintf(&out, "<p>Comments: <a href=\"%s\">%s</a></p>\n",
+		html.EscapeString(comments), html.EscapeString(comments))
+
+	// The self-text is contained within a "md" div.
+	if enabled(opts, "reddit-text", true) {
+		md := doc.Find("div.md").First()
+		if md.Length() > 0 {
+			body, err := md.Html()
+			if err == nil {
+				fmt.Fprintf(&out, "<div>%s</div>\n", body)
+			}
+		}
+	}
+
+	item.Content = out.String()
+}
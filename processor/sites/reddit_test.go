@@ -0,0 +1,70 @@
+package sites
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+var redditFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns="http://www.w3.org/2005/Atom">
+<title>golang</title>
+<link rel="alternate" href="https://www.reddit.com/r/golang/"/>
+<entry>
+<id>t3_aaaaaa</id>
+<title>An interesting article</title>
+<link href="https://www.reddit.com/r/golang/comments/aaaaaa/an_interesting_article/"/>
+<content type="html">&lt;table&gt; &lt;tr&gt;&lt;td&gt; &amp;#32; submitted by &amp;#32; &lt;a href=&quot;https://www.reddit.com/user/steve&quot;&gt; /u/steve &lt;/a&gt; &lt;br/&gt; &lt;span&gt;&lt;a href=&quot;https://example.com/article&quot;&gt;[link]&lt;/a&gt;&lt;/span&gt; &amp;#32; &lt;span&gt;&lt;a href=&quot;https://www.reddit.com/r/golang/comments/aaaaaa/an_interesting_article/&quot;&gt;[comments]&lt;/a&gt;&lt;/span&gt; &lt;/td&gt;&lt;/tr&gt;&lt;/table&gt;</content>
+</entry>
+<entry>
+<id>t3_bbbbbb</id>
+<title>A question</title>
+<link href="https://www.reddit.com/r/golang/comments/bbbbbb/a_question/"/>
+<content type="html">&lt;!-- SC_OFF --&gt;&lt;div class=&quot;md&quot;&gt;&lt;p&gt;How do I do this?&lt;/p&gt;&lt;/div&gt;&lt;!-- SC_ON --&gt; &amp;#32; submitted by &amp;#32; &lt;a href=&quot;https://www.reddit.com/user/steve&quot;&gt; /u/steve &lt;/a&gt; &lt;br/&gt; &lt;span&gt;&lt;a href=&quot;https://www.reddit.com/r/golang/comments/bbbbbb/a_question/&quot;&gt;[link]&lt;/a&gt;&lt;/span&gt; &amp;#32; &lt;span&gt;&lt;a href=&quot;https://www.reddit.com/r/golang/comments/bbbbbb/a_question/&quot;&gt;[comments]&lt;/a&gt;&lt;/span&gt;</content>
+</entry>
+</feed>`
+
+// TestRedditLink tests a reddit link-post
+func TestRedditLink(t *testing.T) {
+
+	feed := parse(t, redditFeed)
+	item := feed.Items[0]
+
+	Enhance(feed, item, nil)
+
+	if !strings.Contains(item.Content, `Link: <a href="https://example.com/article">`) {
+		t.Fatalf("missing link in %s", item.Content)
+	}
+	if !strings.Contains(item.Content, `Comments: <a href="https://www.reddit.com/r/golang/comments/aaaaaa/an_interesting_article/">`) {
+		t.Fatalf("missing comments in %s", item.Content)
+	}
+	if strings.Contains(item.Content, "submitted by") {
+		t.Fatalf("boilerplate wasn't removed from %s", item.Content)
+	}
+}
+
+// TestRedditSelf tests a reddit self-post
+func TestRedditSelf(t *testing.T) {
+
+	feed := parse(t, redditFeed)
+	item := feed.Items[1]
+
+	Enhance(feed, item, nil)
+
+	if strings.Contains(item.Content, "Link:") {
+		t.Fatalf("self-post shouldn't have a link %s", item.Content)
+	}
+	if !strings.Contains(item.Content, "How do I do this?") {
+		t.Fatalf("missing self-text in %s", item.Content)
+	}
+
+	// Without the self-text
+	feed = parse(t, redditFeed)
+	item = feed.Items[1]
+
+	Enhance(feed, item, []configfile.Option{{Name: "reddit-text", Value: "false"}})
+	if strings.Contains(item.Content, "How do I do this?") {
+		t.Fatalf("unexpected self-text in %s", item.Content)
+	}
+}
@@ -0,0 +1,110 @@
+// Package sites contains special handling for feeds which come from
+// popular sites, where the generic handling of feed items produces
+// poor emails.
+//
+// Each site is handled by an enhancer, which recognizes the items it
+// understands, and rewrites their content appropriately.
+//
+// Site-specific handling may be disabled on a per-feed basis via the
+// "enhance" option.
+package sites
+
+import (
+	"strconv"
+
+	"github.com/mmcdole/gofeed"
+	ext "github.com/mmcdole/gofeed/extensions"
+	"github.com/skx/rss2email/configfile"
+)
+
+// enhancer is the interface which each site-specific handler implements.
+type enhancer interface {
+
+	// Matches returns true if the given item comes from this site.
+	Matches(feed *gofeed.Feed, item *gofeed.Item) bool
+
+	// Enhance updates the given item in-place.
+	Enhance(feed *gofeed.Feed, item *gofeed.Item, opts []configfile.Option)
+}
+
+// enhancers holds each of the sites we know about.
+var enhancers = []enhancer{
+	&youtube{},
+	&reddit{},
+	&github{},
+}
+
+// Enhance examines the given feed item, and if it comes from a site we
+// have special handling for then it is updated in-place.
+func Enhance(feed *gofeed.Feed, item *gofeed.Item, opts []configfile.Option) {
+
+	if !enabled(opts, "enhance", true) {
+		return
+	}
+
+	for _, e := range enhancers {
+		if e.Matches(feed, item) {
+			e.Enhance(feed, item, opts)
+			return
+		}
+	}
+}
+
+// enabled returns the boolean value of the named per-feed option, or the
+// given default if it was not set.
+func enabled(opts []configfile.Option, name string, def bool) bool {
+
+	for _, opt := range opts {
+		if opt.Name == name {
+			val, err := strconv.ParseBool(opt.Value)
+			if err == nil {
+				def = val
+			}
+		}
+	}
+	return def
+}
+
+// extElement wraps a feed extension element, to allow easy access to
+// nested children.
+type extElement struct {
+	ext.Extension
+}
+
+// child returns the first named child of the element, or nil.
+func (e *extElement) child(name string) *extElement {
+	if e == nil || len(e.Children[name]) == 0 {
+		return nil
+	}
+	return &extElement{e.Children[name][0]}
+}
+
+// value returns the text of the element, if present.
+func (e *extElement) value() string {
+	if e == nil {
+		return ""
+	}
+	return e.Value
+}
+
+// attr returns the named attribute of the element, if present.
+func (e *extElement) attr(name string) string {
+	if e == nil {
+		return ""
+	}
+	return e.Attrs[name]
+}
+
+// extension returns the named extension element of the given item, or
+// nil if it is not present.
+func extension(item *gofeed.Item, prefix string, name string) *extElement {
+
+	if item.Extensions == nil {
+		return nil
+	}
+	vals := item.Extensions[prefix][name]
+	if len(vals) == 0 {
+		return nil
+	}
+	return &extElement{vals[0]}
+}
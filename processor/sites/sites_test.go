@@ -0,0 +1,60 @@
+package sites
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// parse is a helper to parse the given feed.
+func parse(t *testing.T, content string) *gofeed.Feed {
+	feed, err := gofeed.NewParser().ParseString(content)
+	if err != nil {
+		t.Fatalf("failed to parse feed: %s", err)
+	}
+	return feed
+}
+
+// TestEnabled tests our boolean-option helper
+func TestEnabled(t *testing.T) {
+
+	opts := []configfile.Option{
+		{Name: "foo", Value: "false"},
+		{Name: "bar", Value: "steve"},
+	}
+
+	if enabled(opts, "foo", true) {
+		t.Fatalf("option should be disabled")
+	}
+	if !enabled(opts, "bar", true) {
+		t.Fatalf("bogus values should be ignored")
+	}
+	if enabled(opts, "baz", false) {
+		t.Fatalf("missing options should use the default")
+	}
+}
+
+// TestGenericFeed ensures that we don't change other feeds.
+func TestGenericFeed(t *testing.T) {
+
+	feed := parse(t, `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Example</title>
+<link>https://example.com/</link>
+<item>
+<title>Item</title>
+<link>https://example.com/item</link>
+<description>Hello, world</description>
+</item>
+</channel>
+</rss>`)
+
+	item := feed.Items[0]
+	Enhance(feed, item, nil)
+
+	if item.Content != "" || item.Description != "Hello, world" {
+		t.Fatalf("generic item was changed")
+	}
+}
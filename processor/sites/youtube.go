@@ -0,0 +1,80 @@
+package sites
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"strconv"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// youtube handles the feeds of YouTube channels and playlists.
+//
+// The items in these feeds have no content, instead the description
+// of the video is contained in a `media:group` element, along with
+// a thumbnail.
+type youtube struct{}
+
+// Matches returns true for items with a YouTube video ID.
+func (y *youtube) Matches(feed *gofeed.Feed, item *gofeed.Item) bool {
+	return extension(item, "yt", "videoId") != nil
+}
+
+// Enhance replaces the content of the item with the video thumbnail,
+// duration, and description.
+//
+// The "youtube-embed" option adds a link to the embeddable player.
+func (y *youtube) Enhance(feed *gofeed.Feed, item *gofeed.Item, opts []configfile.Option) {
+
+	id := extension(item, "yt", "videoId").value()
+	group := extension(item, "media", "group")
+
+	link := item.Link
+	if link == "" {
+		link = "https://www.youtube.com/watch?v=" + url.QueryEscape(id)
+	}
+
+	var out strings.Builder
+
+	// The thumbnail, linking to the video.
+	if thumb := group.child("thumbnail").attr("url"); thumb != "" {
+		fmt.Fprintf(&out, "<p><a href=\"%s\"><img src=\"%s\" alt=\"%s\"></a></p>\n",
+			html.EscapeString(link), html.EscapeString(thumb), html.EscapeString(item.Title))
+	}
+
+	// The duration, if known.
+	if secs, err := strconv.Atoi(group.child("content").attr("duration")); err == nil && secs > 0 {
+		fmt.Fprintf(&out, "<p>Duration: %s</p>\n", duration(secs))
+	}
+
+	// Links to the video.
+	fmt.Fprintf(&out, "<p><a href=\"%s\">Watch on YouTube</a>", html.EscapeString(link))
+	if enabled(opts, "youtube-embed", false) && id != "" {
+		fmt.Fprintf(&out, " | <a href=\"https://www.youtube.com/embed/%s\">Embedded player</a>", url.PathEscape(id))
+	}
+	out.WriteString("</p>\n")
+
+	// The description is plain text, which we need to convert.
+	if desc := group.child("description").value(); desc != "" {
+		desc = html.EscapeString(desc)
+		desc = strings.ReplaceAll(desc, "\n", "<br>\n")
+		fmt.Fprintf(&out, "<p>%s</p>\n", desc)
+	}
+
+	item.Content = out.String()
+}
+
+// duration formats a number of seconds as "h:mm:ss", or "m:ss".
+func duration(secs int) string {
+	h := secs / 3600
+	m := (secs % 3600) / 60
+	s := secs % 60
+
+	if h > 0 {
+		return fmt.Sprintf("%d:%02d:%02d", h, m, s)
+	}
+	return fmt.Sprintf("%d:%02d", m, s)
+}
@@ -0,0 +1,81 @@
+package sites
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+var youtubeFeed = `<?xml version="1.0" encoding="UTF-8"?>
+<feed xmlns:yt="http://www.youtube.com/xml/schemas/2015" xmlns:media="http://search.yahoo.com/mrss/" xmlns="http://www.w3.org/2005/Atom">
+ <id>yt:channel:UC1</id>
+ <title>Channel</title>
+ <link rel="alternate" href="https://www.youtube.com/channel/UC1"/>
+ <entry>
+  <id>yt:video:abc123</id>
+  <yt:videoId>abc123</yt:videoId>
+  <title>Video &amp; title</title>
+  <link rel="alternate" href="https://www.youtube.com/watch?v=abc123"/>
+  <published>2021-09-01T10:00:00+00:00</published>
+  <media:group>
+   <media:title>Video title</media:title>
+   <media:content url="https://www.youtube.com/v/abc123?version=3" duration="3725"/>
+   <media:thumbnail url="https://i1.ytimg.com/vi/abc123/hqdefault.jpg" width="480" height="360"/>
+   <media:description>Line one
+Line &lt;two&gt;</media:description>
+  </media:group>
+ </entry>
+</feed>`
+
+// TestYouTube ensures YouTube items get useful content.
+func TestYouTube(t *testing.T) {
+
+	feed := parse(t, youtubeFeed)
+	item := feed.Items[0]
+
+	Enhance(feed, item, nil)
+
+	expected := []string{
+		`<img src="https://i1.ytimg.com/vi/abc123/hqdefault.jpg"`,
+		"Duration: 1:02:05",
+		"Line one<br>",
+		"Line &lt;two&gt;",
+		`<a href="https://www.youtube.com/watch?v=abc123">`,
+	}
+	for _, txt := range expected {
+		if !strings.Contains(item.Content, txt) {
+			t.Fatalf("missing %s in content %s", txt, item.Content)
+		}
+	}
+	if strings.Contains(item.Content, "embed") {
+		t.Fatalf("embedded player should not be present by default")
+	}
+
+	// Now with the embedded player
+	feed = parse(t, youtubeFeed)
+	item = feed.Items[0]
+	Enhance(feed, item, []configfile.Option{{Name: "youtube-embed", Value: "true"}})
+	if !strings.Contains(item.Content, "https://www.youtube.com/embed/abc123") {
+		t.Fatalf("embedded player missing from content %s", item.Content)
+	}
+
+	// Disabled entirely
+	feed = parse(t, youtubeFeed)
+	item = feed.Items[0]
+	Enhance(feed, item, []configfile.Option{{Name: "enhance", Value: "false"}})
+	if item.Content != "" {
+		t.Fatalf("content was changed, despite being disabled")
+	}
+}
+
+// TestDuration tests our duration formatting
+func TestDuration(t *testing.T) {
+
+	if duration(59) != "0:59" {
+		t.Fatalf("unexpected duration %s", duration(59))
+	}
+	if duration(3600) != "1:00:00" {
+		t.Fatalf("unexpected duration %s", duration(3600))
+	}
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/PuerkitoBio/goquery"
+	"github.com/andybalholm/cascadia"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// itemRules holds the changes made to each item of a feed, before it is
+// filtered, and delivered.
+type itemRules struct {
+
+	// strip holds the CSS selectors of the elements removed from the
+	// content of each item.
+	strip []string
+
+	// rewrites holds the substitutions applied to each item.
+	rewrites []*rewrite
+}
+
+// parseItemRules returns the changes made to the items of the given feed,
+// via the "strip-selector", and rewrite, options.
+func parseItemRules(config configfile.Feed) (*itemRules, error) {
+
+	rules := &itemRules{}
+
+	for _, opt := range config.Options {
+		if opt.Name == "strip-selector" {
+			err := checkSelector(opt.Value)
+			if err != nil {
+				return nil, err
+			}
+			rules.strip = append(rules.strip, opt.Value)
+		}
+	}
+
+	var err error
+	rules.rewrites, err = rewrites(config)
+	if err != nil {
+		return nil, err
+	}
+	return rules, nil
+}
+
+// apply makes our changes to the given item.
+//
+// The text of the item is normalised first, then elements are removed
+// before the substitutions are applied.
+func (r *itemRules) apply(xp *gofeed.Item) {
+
+	normaliseItem(xp)
+
+	if len(r.strip) > 0 {
+		xp.Content = stripSelectors(xp.Content, r.strip)
+		xp.Description = stripSelectors(xp.Description, r.strip)
+	}
+	applyRewrites(r.rewrites, xp)
+}
+
+// checkSelector returns an error if the given value of a "strip-selector"
+// option isn't a valid, comma-separated, list of CSS selectors.
+func checkSelector(selector string) error {
+
+	_, err := cascadia.ParseGroup(selector)
+	if err != nil {
+		return fmt.Errorf("invalid strip-selector '%s': %s", selector, err)
+	}
+	return nil
+}
+
+// stripSelectors removes the elements which match any of the given CSS
+// selectors from the given HTML.
+//
+// The HTML is returned unchanged if nothing matches.
+func stripSelectors(content string, selectors []string) string {
+
+	if content == "" {
+		return content
+	}
+
+	doc, err := goquery.NewDocumentFromReader(strings.NewReader(content))
+	if err != nil {
+		return content
+	}
+
+	found := false
+	for _, selector := range selectors {
+		sel := doc.Find(selector)
+		if sel.Length() > 0 {
+			found = true
+			sel.Remove()
+		}
+	}
+	if !found {
+		return content
+	}
+
+	// The content was parsed as the body of a document.
+	res, err := doc.Find("body").Html()
+	if err != nil {
+		return content
+	}
+	return res
+}
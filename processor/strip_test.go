@@ -0,0 +1,45 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestStripSelectors(t *testing.T) {
+
+	entry := configfile.Feed{Options: []configfile.Option{
+		{Name: "strip-selector", Value: ".newsletter-promo, figure.ad"},
+		{Name: "strip-selector", Value: "aside"},
+		{Name: "rewrite", Value: "s/Hello/Goodbye/"},
+	}}
+
+	rules, err := parseItemRules(entry)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	xp := &gofeed.Item{
+		Content:     `<p>Hello</p><div class="newsletter-promo"><p>Subscribe!</p></div><figure class="ad"><img src="x"></figure><figure><img src="y"/></figure><aside>Aside</aside>`,
+		Description: "Nothing to see here & there",
+	}
+	rules.apply(xp)
+
+	if xp.Content != `<p>Goodbye</p><figure><img src="y"/></figure>` {
+		t.Fatalf("unexpected content: %s", xp.Content)
+	}
+
+	// Content without matches is unchanged.
+	if xp.Description != "Nothing to see here & there" {
+		t.Fatalf("unexpected description: %s", xp.Description)
+	}
+
+	_, err = parseItemRules(configfile.Feed{Options: []configfile.Option{{Name: "strip-selector", Value: "div..ad"}}})
+	if err == nil {
+		t.Fatalf("expected error with bogus selector")
+	}
+	if CheckRewrite(configfile.Option{Name: "strip-selector", Value: "div["}) == nil {
+		t.Fatalf("expected error with bogus selector")
+	}
+}
@@ -0,0 +1,108 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+
+	"github.com/k3a/html2text"
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/summarise"
+)
+
+// summariseWords returns the number of words above which the items of the
+// given feed are summarised, from its "summarise" option, or -1 if they're
+// not summarised.
+//
+// The option may be "true", to summarise every item, or a number of words,
+// so that only long items are summarised.
+func summariseWords(config configfile.Feed) (int, error) {
+
+	words := -1
+	for _, opt := range config.Options {
+		if opt.Name != "summarise" {
+			continue
+		}
+
+		on, err := strconv.ParseBool(opt.Value)
+		if err == nil {
+			words = -1
+			if on {
+				words = 0
+			}
+			continue
+		}
+
+		words, err = strconv.Atoi(strings.TrimSpace(opt.Value))
+		if err != nil || words < 0 {
+			return -1, fmt.Errorf("invalid summarise '%s', expected true, false, or a number of words", opt.Value)
+		}
+	}
+	return words, nil
+}
+
+// CheckSummarise returns an error if the given per-feed option is the
+// "summarise" option, and its value is invalid.
+func CheckSummarise(opt configfile.Option) error {
+	if opt.Name != "summarise" {
+		return nil
+	}
+	_, err := summariseWords(configfile.Feed{Options: []configfile.Option{opt}})
+	return err
+}
+
+// summariseItem places a summary of the given item at the top of its
+// content, if it has at least the given number of words.
+//
+// Failure isn't fatal, the item is sent without a summary.  Returns true
+// if the item was changed.
+func (p *Processor) summariseItem(xp *gofeed.Item, words int) bool {
+
+	content := &xp.Content
+	if *content == "" {
+		content = &xp.Description
+	}
+
+	text := html2text.HTML2Text(*content)
+	if text == "" || len(strings.Fields(text)) < words {
+		return false
+	}
+
+	s, err := summarise.New()
+	if err != nil {
+		p.message(fmt.Sprintf("\t\tFailed to summarise: %s\n", err))
+		return false
+	}
+
+	summary, err := s.Summarise(summarise.Item{Title: xp.Title, Link: xp.Link, Text: text})
+	if err != nil {
+		p.message(fmt.Sprintf("\t\tFailed to summarise: %s\n", err))
+		return false
+	}
+	if summary == "" {
+		return false
+	}
+
+	*content = summaryHTML(summary) + *content
+	return true
+}
+
+// summaryHTML returns the HTML which shows the given summary, which is
+// text, at the top of an item.
+func summaryHTML(summary string) string {
+
+	var sb strings.Builder
+	sb.WriteString("<div class=\"summary\">\n<h3>Summary</h3>\n")
+	for _, para := range strings.Split(strings.ReplaceAll(summary, "\r\n", "\n"), "\n\n") {
+		para = strings.TrimSpace(para)
+		if para == "" {
+			continue
+		}
+		para = strings.ReplaceAll(html.EscapeString(para), "\n", "<br>\n")
+		fmt.Fprintf(&sb, "<p>%s</p>\n", para)
+	}
+	sb.WriteString("</div>\n<hr>\n")
+	return sb.String()
+}
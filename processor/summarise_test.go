@@ -0,0 +1,68 @@
+package processor
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestSummariseWords ensures the option is parsed.
+func TestSummariseWords(t *testing.T) {
+
+	tests := map[string]int{
+		"true":  0,
+		"false": -1,
+		"1500":  1500,
+	}
+	for val, words := range tests {
+		got, err := summariseWords(configfile.Feed{Options: []configfile.Option{{Name: "summarise", Value: val}}})
+		if err != nil || got != words {
+			t.Fatalf("unexpected result for '%s': %d %v", val, got, err)
+		}
+	}
+
+	if words, _ := summariseWords(configfile.Feed{}); words != -1 {
+		t.Fatalf("items shouldn't be summarised by default")
+	}
+	for _, bogus := range []string{"", "-1", "long"} {
+		if CheckSummarise(configfile.Option{Name: "summarise", Value: bogus}) == nil {
+			t.Fatalf("expected an error for '%s'", bogus)
+		}
+	}
+}
+
+// TestSummariseItem summarises an item via a command.
+func TestSummariseItem(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+
+	os.Setenv("SUMMARISE_COMMAND", `printf "It has <%s> words.\n\nThe end." $(wc -w)`)
+	defer os.Unsetenv("SUMMARISE_COMMAND")
+
+	xp := &gofeed.Item{Title: "Essay", Description: "<p>One two three four.</p>"}
+	if !New().summariseItem(xp, 0) {
+		t.Fatalf("expected the item to be summarised")
+	}
+	expected := "<div class=\"summary\">\n<h3>Summary</h3>\n<p>It has &lt;4&gt; words.</p>\n<p>The end.</p>\n</div>\n<hr>\n<p>One two three four.</p>"
+	if xp.Description != expected {
+		t.Fatalf("unexpected content: %q", xp.Description)
+	}
+
+	// Short items aren't summarised.
+	xp = &gofeed.Item{Title: "Note", Content: "<p>Short.</p>"}
+	if New().summariseItem(xp, 100) || xp.Content != "<p>Short.</p>" {
+		t.Fatalf("short items shouldn't be summarised")
+	}
+
+	// Failures leave the item unchanged.
+	os.Setenv("SUMMARISE_COMMAND", "exit 1")
+	if New().summariseItem(xp, 0) || !strings.HasPrefix(xp.Content, "<p>Short") {
+		t.Fatalf("unexpected summary: %s", xp.Content)
+	}
+}
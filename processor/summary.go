@@ -0,0 +1,105 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// Summary contains the details of a single run.
+type Summary struct {
+
+	// Started holds the time the run started, and Duration how long
+	// it took.
+	Started  time.Time
+	Duration time.Duration
+
+	// Feeds holds the number of feeds which were processed, out of
+	// the Total number configured.
+	Feeds int
+	Total int
+
+	// Items holds the number of new items which were discovered.
+	Items int
+
+	// Sent holds the number of new items which were delivered, and
+	// Skipped those which were excluded by the per-feed options.
+	Sent    int
+	Skipped int
+
+	// Queued holds the number of new items which were held back, to
+	// be sent later.
+	Queued int
+
+	// Rejected holds the number of new items which the MTA refused
+	// permanently, which won't be retried.
+	Rejected int
+
+	// Held holds the number of new items which weren't sent, as the
+	// maximum number of items per run was reached, which were left
+	// unseen.
+	Held int
+
+	// Errors holds the errors which were encountered, which are
+	// generally associated with a particular feed.
+	Errors []error
+
+	// Interrupted is true if the run was stopped early, and TimedOut
+	// is also true if that was because it exceeded its timeout.
+	Interrupted bool
+	TimedOut    bool
+}
+
+// plural returns the given count and noun, pluralized if necessary.
+func plural(n int, noun string) string {
+	if n == 1 {
+		return fmt.Sprintf("%d %s", n, noun)
+	}
+	return fmt.Sprintf("%d %ss", n, noun)
+}
+
+// Line returns a single line describing the run.
+func (s Summary) Line() string {
+
+	// Only mention queued, rejected, or held, items if there are some.
+	skipped := fmt.Sprintf("%d skipped", s.Skipped)
+	if s.Queued > 0 {
+		skipped += fmt.Sprintf(", %d queued", s.Queued)
+	}
+	if s.Rejected > 0 {
+		skipped += fmt.Sprintf(", %d rejected", s.Rejected)
+	}
+	if s.Held > 0 {
+		skipped += fmt.Sprintf(", %d held", s.Held)
+	}
+
+	line := fmt.Sprintf("%s processed, %s, %d emailed, %s, %s, in %s",
+		plural(s.Feeds, "feed"),
+		plural(s.Items, "new item"),
+		s.Sent,
+		skipped,
+		plural(len(s.Errors), "error"),
+		s.Duration.Round(time.Millisecond))
+
+	if s.TimedOut {
+		line += fmt.Sprintf(" (timed out after %d of %d feeds)", s.Feeds, s.Total)
+	} else if s.Interrupted {
+		line += fmt.Sprintf(" (interrupted after %d of %d feeds)", s.Feeds, s.Total)
+	}
+	return line
+}
+
+// String returns the summary, as a single line followed by any errors.
+func (s Summary) String() string {
+
+	var sb strings.Builder
+	sb.WriteString(s.Line() + "\n")
+
+	if len(s.Errors) > 0 {
+		sb.WriteString("\nErrors:\n")
+		for _, err := range s.Errors {
+			sb.WriteString("  " + err.Error() + "\n")
+		}
+	}
+	return sb.String()
+}
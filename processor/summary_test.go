@@ -0,0 +1,49 @@
+package processor
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSummary(t *testing.T) {
+
+	s := Summary{
+		Duration: 4200 * time.Millisecond,
+		Feeds:    1,
+		Total:    3,
+		Items:    3,
+		Sent:     2,
+		Skipped:  1,
+	}
+
+	expected := "1 feed processed, 3 new items, 2 emailed, 1 skipped, 0 errors, in 4.2s"
+	if s.Line() != expected {
+		t.Fatalf("unexpected line: %q", s.Line())
+	}
+	if s.String() != expected+"\n" {
+		t.Fatalf("unexpected summary: %q", s.String())
+	}
+
+	s.Queued = 2
+	s.Rejected = 1
+	if !strings.Contains(s.Line(), "1 skipped, 2 queued, 1 rejected, 0 errors") {
+		t.Fatalf("unexpected line: %q", s.Line())
+	}
+
+	s.Errors = []error{errors.New("error processing https://example.com/ - broken")}
+	s.Interrupted = true
+
+	if !strings.Contains(s.Line(), "1 error,") || !strings.HasSuffix(s.Line(), "(interrupted after 1 of 3 feeds)") {
+		t.Fatalf("unexpected line: %q", s.Line())
+	}
+	if !strings.Contains(s.String(), "\nErrors:\n  error processing https://example.com/ - broken\n") {
+		t.Fatalf("errors missing from summary: %q", s.String())
+	}
+
+	s.TimedOut = true
+	if !strings.HasSuffix(s.Line(), "(timed out after 1 of 3 feeds)") {
+		t.Fatalf("unexpected line: %q", s.Line())
+	}
+}
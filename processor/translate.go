@@ -0,0 +1,72 @@
+package processor
+
+import (
+	"fmt"
+	"html"
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/translate"
+)
+
+// translateTarget returns the language the items of the given feed are
+// translated to, from its "translate" option, or the empty string if
+// they're not translated.
+func translateTarget(config configfile.Feed) string {
+
+	target := ""
+	for _, opt := range config.Options {
+		if opt.Name == "translate" {
+			target = strings.TrimSpace(opt.Value)
+		}
+	}
+	return target
+}
+
+// translateItem translates the title, and content, of the given item to
+// the given language.  The original title, and content, are kept below
+// the translation.
+//
+// Failure isn't fatal, the item is sent untranslated.  Returns true if
+// the item was changed.
+func (p *Processor) translateItem(xp *gofeed.Item, target string) bool {
+
+	tr, err := translate.New()
+	if err != nil {
+		p.message(fmt.Sprintf("\t\tFailed to translate: %s\n", err))
+		return false
+	}
+
+	content := &xp.Content
+	if *content == "" {
+		content = &xp.Description
+	}
+
+	title := xp.Title
+	if title != "" {
+		title, err = tr.Translate(xp.Title, target)
+		if err != nil {
+			p.message(fmt.Sprintf("\t\tFailed to translate: %s\n", err))
+			return false
+		}
+	}
+
+	body := *content
+	if body != "" {
+		body, err = tr.Translate(*content, target)
+		if err != nil {
+			p.message(fmt.Sprintf("\t\tFailed to translate: %s\n", err))
+			return false
+		}
+	}
+
+	original := "\n<hr>\n<h3>Original</h3>\n"
+	if xp.Title != "" {
+		original += "<p><strong>" + html.EscapeString(xp.Title) + "</strong></p>\n"
+	}
+
+	*content = body + original + *content
+	xp.Title = html.UnescapeString(title)
+	return true
+}
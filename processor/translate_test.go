@@ -0,0 +1,56 @@
+package processor
+
+import (
+	"os"
+	"runtime"
+	"strings"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// TestTranslateTarget ensures the option is parsed.
+func TestTranslateTarget(t *testing.T) {
+
+	if translateTarget(configfile.Feed{}) != "" {
+		t.Fatalf("items shouldn't be translated by default")
+	}
+	if translateTarget(configfile.Feed{Options: []configfile.Option{{Name: "translate", Value: " en "}}}) != "en" {
+		t.Fatalf("items should be translated to English")
+	}
+}
+
+// TestTranslateItem translates an item via a command, and ensures the
+// original is kept.
+func TestTranslateItem(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+
+	os.Setenv("TRANSLATE_COMMAND", `sed -e "s/Hola/Hello/" -e "s/mundo/world/" -e "s/&/\&amp;/"`)
+	defer os.Unsetenv("TRANSLATE_COMMAND")
+
+	xp := &gofeed.Item{Title: "Hola & adiós", Description: "<p>Hola mundo</p>"}
+	if !New().translateItem(xp, "en") {
+		t.Fatalf("expected the item to be translated")
+	}
+
+	if xp.Title != "Hello & adiós" {
+		t.Fatalf("unexpected title: %s", xp.Title)
+	}
+	if !strings.HasPrefix(xp.Description, "<p>Hello world</p>") {
+		t.Fatalf("unexpected translation: %s", xp.Description)
+	}
+	if !strings.HasSuffix(xp.Description, "<h3>Original</h3>\n<p><strong>Hola &amp; adiós</strong></p>\n<p>Hola mundo</p>") {
+		t.Fatalf("the original wasn't kept: %s", xp.Description)
+	}
+
+	// Failures leave the item unchanged.
+	os.Setenv("TRANSLATE_COMMAND", "exit 1")
+	xp = &gofeed.Item{Title: "Hola", Content: "<p>Hola mundo</p>"}
+	if New().translateItem(xp, "en") || xp.Title != "Hola" || xp.Content != "<p>Hola mundo</p>" {
+		t.Fatalf("unexpected translation: %v", xp)
+	}
+}
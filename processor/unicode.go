@@ -0,0 +1,47 @@
+package processor
+
+import (
+	"strings"
+
+	"github.com/mmcdole/gofeed"
+	"golang.org/x/text/unicode/norm"
+)
+
+// invisible holds the zero-width, and bidirectional control, characters we
+// remove from items.  They're invisible, but confuse terminal mail clients,
+// and may be abused to disguise text.
+//
+// The zero-width joiner, and non-joiner, are kept as they are needed by
+// some scripts, and by emoji sequences.
+var invisible = strings.NewReplacer(
+	"\u200b", "", // zero-width space
+	"\u2060", "", // word joiner
+	"\ufeff", "", // zero-width no-break space, or byte-order mark
+	"\u061c", "", // arabic letter mark
+	"\u200e", "", // left-to-right mark
+	"\u200f", "", // right-to-left mark
+	"\u202a", "", // left-to-right embedding
+	"\u202b", "", // right-to-left embedding
+	"\u202c", "", // pop directional formatting
+	"\u202d", "", // left-to-right override
+	"\u202e", "", // right-to-left override
+	"\u2066", "", // left-to-right isolate
+	"\u2067", "", // right-to-left isolate
+	"\u2068", "", // first strong isolate
+	"\u2069", "", // pop directional isolate
+)
+
+// normaliseText returns the given text in Unicode Normalization Form C,
+// without any invisible control characters.
+func normaliseText(text string) string {
+	return norm.NFC.String(invisible.Replace(text))
+}
+
+// normaliseItem normalises the title, and content, of the given item, so
+// that the same text is always written the same way, which filters rely
+// upon.
+func normaliseItem(xp *gofeed.Item) {
+	xp.Title = normaliseText(xp.Title)
+	xp.Description = normaliseText(xp.Description)
+	xp.Content = normaliseText(xp.Content)
+}
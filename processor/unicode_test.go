@@ -0,0 +1,29 @@
+package processor
+
+import (
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// TestNormaliseItem ensures text is normalised, and invisible characters
+// removed.
+func TestNormaliseItem(t *testing.T) {
+
+	xp := &gofeed.Item{
+		Title:       "Cafe\u0301 opens",
+		Description: "<p>Pay\u200b\u200bPal \u202egnp.exe</p>",
+		Content:     "\ufeffFamily: \U0001f468\u200d\U0001f469\u200d\U0001f467",
+	}
+	normaliseItem(xp)
+
+	if xp.Title != "Caf\u00e9 opens" {
+		t.Fatalf("title wasn't normalised: %q", xp.Title)
+	}
+	if xp.Description != "<p>PayPal gnp.exe</p>" {
+		t.Fatalf("invisible characters weren't removed: %q", xp.Description)
+	}
+	if xp.Content != "Family: \U0001f468\u200d\U0001f469\u200d\U0001f467" {
+		t.Fatalf("unexpected content: %q", xp.Content)
+	}
+}
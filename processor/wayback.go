@@ -0,0 +1,38 @@
+package processor
+
+import (
+	"fmt"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/wayback"
+)
+
+// wantWayback returns true if the given feed has the "wayback" option set,
+// so that the link of each item is archived by the Wayback Machine.
+func wantWayback(config configfile.Feed) bool {
+	return boolOption(config, "wayback", false)
+}
+
+// archiveLink requests a snapshot of the link of the given item, and
+// records its address within the item, so that it is linked from the
+// email, even if the item is queued first.
+//
+// Failure isn't fatal, the email is sent without the snapshot.
+func (p *Processor) archiveLink(xp *gofeed.Item) {
+
+	if xp.Link == "" {
+		return
+	}
+
+	snapshot, err := wayback.New().Snapshot(xp.Link)
+	if err != nil {
+		p.message(fmt.Sprintf("\t\tFailed to archive %s: %s\n", xp.Link, err))
+		return
+	}
+
+	if xp.Custom == nil {
+		xp.Custom = make(map[string]string)
+	}
+	xp.Custom[wayback.Key] = snapshot
+}
@@ -0,0 +1,142 @@
+package processor
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Window is a daily period, in local time, within which emails may be
+// delivered.  Items discovered outside the window are queued, and sent
+// once it opens.
+type Window struct {
+
+	// Start and End hold the minutes after midnight at which the
+	// window opens and closes.  If End is before Start the window
+	// spans midnight.
+	Start int
+	End   int
+}
+
+// parseClock parses a time of day, such as "08:00", returning the
+// number of minutes after midnight.
+func parseClock(s string) (int, error) {
+
+	t, err := time.Parse("15:04", strings.TrimSpace(s))
+	if err != nil {
+		return 0, fmt.Errorf("invalid time '%s', expected HH:MM", s)
+	}
+	return t.Hour()*60 + t.Minute(), nil
+}
+
+// ParseWindow parses a delivery window, such as "08:00-22:00".
+func ParseWindow(s string) (*Window, error) {
+
+	parts := strings.Split(s, "-")
+	if len(parts) != 2 {
+		return nil, fmt.Errorf("invalid delivery window '%s', expected HH:MM-HH:MM", s)
+	}
+
+	start, err := parseClock(parts[0])
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery window '%s': %s", s, err)
+	}
+	end, err := parseClock(parts[1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid delivery window '%s': %s", s, err)
+	}
+	if start == end {
+		return nil, fmt.Errorf("invalid delivery window '%s': it is empty", s)
+	}
+	return &Window{Start: start, End: end}, nil
+}
+
+// Contains returns true if the given time falls within the window.
+func (w *Window) Contains(t time.Time) bool {
+
+	m := t.Hour()*60 + t.Minute()
+	if w.Start < w.End {
+		return m >= w.Start && m < w.End
+	}
+	return m >= w.Start || m < w.End
+}
+
+// Opens returns the time at which the window next opens, after the
+// given time.
+func (w *Window) Opens(t time.Time) time.Time {
+
+	open := time.Date(t.Year(), t.Month(), t.Day(), w.Start/60, w.Start%60, 0, 0, t.Location())
+	if !open.After(t) {
+		open = open.AddDate(0, 0, 1)
+	}
+	return open
+}
+
+// String returns the window in the form it was given.
+func (w *Window) String() string {
+	return fmt.Sprintf("%02d:%02d-%02d:%02d", w.Start/60, w.Start%60, w.End/60, w.End%60)
+}
+
+// deliveryWindow returns the delivery window which applies to the given
+// feed, if any.  The per-feed "deliver-hours" option overrides the
+// global setting.
+func (p *Processor) deliveryWindow(config configfile.Feed) (*Window, error) {
+
+	window := p.window
+	for _, opt := range config.Options {
+		if opt.Name == "deliver-hours" {
+			window = opt.Value
+		}
+	}
+
+	if window == "" || window == "always" {
+		return nil, nil
+	}
+	return ParseWindow(window)
+}
+
+// minGap returns the minimum time between the emails sent for the given
+// feed, from its "min-gap" option, or zero if there is none.
+func minGap(config configfile.Feed) (time.Duration, error) {
+
+	for _, opt := range config.Options {
+		if opt.Name == "min-gap" {
+			gap, err := time.ParseDuration(opt.Value)
+			if err != nil || gap < 0 {
+				return 0, fmt.Errorf("invalid min-gap '%s'", opt.Value)
+			}
+			return gap, nil
+		}
+	}
+	return 0, nil
+}
+
+// holdUntil returns the time until which emails for the given feed must
+// be held back, due to its delivery window or minimum gap, or the zero
+// time if they may be sent now.
+func holdUntil(feedURL string, window *Window, gap time.Duration) time.Time {
+
+	now := time.Now()
+	until := time.Time{}
+
+	if gap > 0 {
+		next := withstate.LastSent(feedURL).Add(gap)
+		if next.After(now) {
+			until = next
+		}
+	}
+
+	if window != nil {
+		t := now
+		if !until.IsZero() {
+			t = until
+		}
+		if !window.Contains(t) {
+			until = window.Opens(t)
+		}
+	}
+	return until
+}
@@ -0,0 +1,197 @@
+//
+// Progress of interactive runs.
+//
+
+package main
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/skx/rss2email/processor"
+)
+
+// progressWidth is the number of characters within our progress bar, and
+// labelWidth the number of characters of the labels of feeds we show.
+const (
+	progressWidth = 20
+	labelWidth    = 30
+)
+
+// isTerminal returns true if the given file is a terminal, rather than a
+// file or a pipe.
+func isTerminal(f *os.File) bool {
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// progress shows the progress of a run upon a single line of a terminal,
+// which is redrawn as each event of the processor is received.  As each
+// feed is finished an aligned line describing it is written above that.
+//
+// Messages, such as errors, are written via progress too, so that the line
+// is cleared before they're shown, and redrawn beneath them.
+type progress struct {
+
+	// out is the terminal, and mutex serialises writes to it.  colour
+	// is true if we colour what we write.
+	out    io.Writer
+	mutex  sync.Mutex
+	colour bool
+
+	// now returns the current time.
+	now func() time.Time
+
+	// total is the number of feeds to process, and done the number
+	// which have been processed.
+	total int
+	done  int
+
+	// sent, and errors, count the items sent and the errors seen.
+	sent   int
+	errors int
+
+	// current is the label of the feed being processed, started the
+	// time we started to process it, and items the number of new items
+	// it had.
+	current string
+	started time.Time
+	items   int
+
+	// running is true once the run has started, and until it finishes.
+	running bool
+}
+
+// newProgress returns a progress which draws upon the given terminal, in
+// colour if colour is set.
+func newProgress(out io.Writer, colour bool) *progress {
+	return &progress{out: out, colour: colour, now: time.Now}
+}
+
+// Event is part of the processor.Observer interface.
+func (b *progress) Event(e processor.Event) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	switch e.Type {
+	case processor.RunStarted:
+		b.total = e.Feeds
+		b.running = true
+	case processor.FeedStarted:
+		b.current = e.Feed.Label()
+		b.started = b.now()
+		b.items = 0
+	case processor.FeedFinished:
+		b.done++
+		fmt.Fprintf(b.out, "\r\033[K%s\n", b.status(e.Err))
+		b.current = ""
+	case processor.ItemDiscovered:
+		b.items++
+	case processor.ItemDelivered:
+		b.sent++
+	case processor.Error:
+		b.errors++
+	case processor.RunFinished:
+		b.running = false
+		b.current = ""
+		fmt.Fprintf(b.out, "\r\033[K%s\n", b.line())
+		return
+	default:
+		return
+	}
+	b.draw()
+}
+
+// Write clears our line, writes the given message, and then redraws the
+// line beneath it.
+func (b *progress) Write(msg []byte) (int, error) {
+
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+
+	if b.running {
+		fmt.Fprint(b.out, "\r\033[K")
+	}
+	n, err := b.out.Write(msg)
+	b.draw()
+	return n, err
+}
+
+// draw replaces our line with the current progress, if we're running.
+func (b *progress) draw() {
+	if b.running {
+		fmt.Fprintf(b.out, "\r\033[K%s", b.line())
+	}
+}
+
+// line returns the current progress, such as:
+//
+//	[##########----------] 20/40 feeds, 3 sent, 1 error - blog.steve.fi
+func (b *progress) line() string {
+
+	filled := progressWidth
+	if b.total > 0 && b.done < b.total {
+		filled = b.done * progressWidth / b.total
+	}
+
+	errors := fmt.Sprintf("%d error", b.errors)
+	if b.errors != 1 {
+		errors += "s"
+	}
+	if b.errors > 0 {
+		errors = paint(b.colour, colourRed, errors)
+	}
+
+	line := fmt.Sprintf("[%s%s] %d/%d feeds, %d sent, %s",
+		paint(b.colour, colourGreen, strings.Repeat("#", filled)), strings.Repeat("-", progressWidth-filled),
+		b.done, b.total, b.sent, errors)
+	if b.current != "" {
+		line += " - " + truncate(b.current, labelWidth)
+	}
+	return line
+}
+
+// status returns the line describing the feed we've just processed, with
+// the error which stopped us, if any, such as:
+//
+//	blog.steve.fi                    3 new    1.25s  ok
+func (b *progress) status(err error) string {
+
+	result := paint(b.colour, colourGreen, "ok")
+	if err != nil {
+		result = paint(b.colour, colourRed, "failed")
+	}
+
+	items := fmt.Sprintf("%3d new", b.items)
+	if b.items > 0 {
+		items = paint(b.colour, colourYellow, items)
+	}
+
+	took := b.now().Sub(b.started).Round(10 * time.Millisecond)
+	return fmt.Sprintf("%-*s %s %8s  %s", labelWidth, truncate(b.current, labelWidth), items, took, result)
+}
+
+// terminalProgress returns the progress to show for a run whose items are
+// sent to the given outputs, or nil if our messages aren't written to a
+// terminal, or are written as JSON in container mode.
+func terminalProgress(outputs []string) *progress {
+
+	if containerMode {
+		return nil
+	}
+
+	// Messages are written to STDERR if items are written to STDOUT.
+	f := os.Stdout
+	if hasOutput(outputs, "jsonl") {
+		f = os.Stderr
+	}
+	if !isTerminal(f) {
+		return nil
+	}
+	return newProgress(f, useColour(f))
+}
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"bytes"
+	"errors"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+)
+
+func TestProgress(t *testing.T) {
+
+	buf := &bytes.Buffer{}
+	bar := newProgress(buf, false)
+
+	// Each feed takes 1.5s.
+	now := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC)
+	bar.now = func() time.Time {
+		now = now.Add(1500 * time.Millisecond)
+		return now
+	}
+
+	// Nothing is drawn until the run starts.
+	bar.Write([]byte("before\n"))
+	if buf.String() != "before\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+	buf.Reset()
+
+	feed := configfile.Feed{URL: "https://example.com/index.rss"}
+	bar.Event(processor.Event{Type: processor.RunStarted, Feeds: 4})
+	bar.Event(processor.Event{Type: processor.FeedStarted, Feed: feed})
+	bar.Event(processor.Event{Type: processor.ItemDiscovered, Feed: feed})
+	bar.Event(processor.Event{Type: processor.ItemDelivered, Feed: feed})
+	bar.Event(processor.Event{Type: processor.FeedFinished, Feed: feed})
+
+	// Each feed is described, once processed.
+	status := "https://example.com/index.rss    1 new     1.5s  ok\n"
+	if !strings.Contains(buf.String(), "\r\033[K"+status) {
+		t.Fatalf("feed wasn't described: %q", buf.String())
+	}
+	bar.Event(processor.Event{Type: processor.FeedStarted, Feed: feed})
+
+	expected := "[#####---------------] 1/4 feeds, 1 sent, 0 errors - https://example.com/index.rss"
+	if bar.line() != expected {
+		t.Fatalf("unexpected line:\n%s\n%s", bar.line(), expected)
+	}
+	if !strings.HasSuffix(buf.String(), "\r\033[K"+expected) {
+		t.Fatalf("line wasn't drawn: %q", buf.String())
+	}
+
+	// Messages are written beneath the line, which is redrawn.
+	buf.Reset()
+	bar.Write([]byte("error fetching\n"))
+	if buf.String() != "\r\033[Kerror fetching\n\r\033[K"+expected {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+
+	// Once finished the line is left behind.
+	buf.Reset()
+	bar.Event(processor.Event{Type: processor.Error, Feed: feed, Err: errors.New("failed")})
+	bar.Event(processor.Event{Type: processor.FeedFinished, Feed: feed, Err: errors.New("failed")})
+	bar.Event(processor.Event{Type: processor.RunFinished})
+	if !strings.Contains(buf.String(), "\r\033[Khttps://example.com/index.rss    0 new     1.5s  failed\n") || !strings.HasSuffix(buf.String(), "\r\033[K[##########----------] 2/4 feeds, 1 sent, 1 error\n") {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+	buf.Reset()
+	bar.Write([]byte("after\n"))
+	if buf.String() != "after\n" {
+		t.Fatalf("unexpected output: %q", buf.String())
+	}
+}
+
+func TestIsTerminal(t *testing.T) {
+
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatalf("failed to create file: %s", err)
+	}
+	defer f.Close()
+
+	if isTerminal(f) {
+		t.Fatalf("a file isn't a terminal")
+	}
+}
+
+func TestColour(t *testing.T) {
+
+	defer func() { noColour = false }()
+
+	if paint(false, colourRed, "text") != "text" || paint(true, colourRed, "text") != "\033[31mtext\033[0m" {
+		t.Fatalf("unexpected painting")
+	}
+
+	// Files aren't terminals, so aren't coloured.
+	f, err := os.Create(filepath.Join(t.TempDir(), "file"))
+	if err != nil {
+		t.Fatalf("failed to create file: %s", err)
+	}
+	defer f.Close()
+	if useColour(f) {
+		t.Fatalf("a file shouldn't be coloured")
+	}
+
+	// The flag is global.
+	_, err = globalFlags([]string{"-no-color", "list"})
+	if err != nil || !noColour {
+		t.Fatalf("colour wasn't disabled: %v", err)
+	}
+
+	// Coloured progress colours errors, and feeds with new items.
+	buf := &bytes.Buffer{}
+	bar := newProgress(buf, true)
+	bar.Event(processor.Event{Type: processor.RunStarted, Feeds: 1})
+	bar.Event(processor.Event{Type: processor.FeedStarted})
+	bar.Event(processor.Event{Type: processor.ItemDiscovered})
+	bar.Event(processor.Event{Type: processor.Error, Err: errors.New("failed")})
+	bar.Event(processor.Event{Type: processor.FeedFinished, Err: errors.New("failed")})
+	for _, expected := range []string{colourYellow + "  1 new" + colourReset, colourRed + "failed" + colourReset, colourRed + "1 error" + colourReset} {
+		if !strings.Contains(buf.String(), expected) {
+			t.Fatalf("expected %q in %q", expected, buf.String())
+		}
+	}
+}
@@ -0,0 +1,250 @@
+package reader
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// readingList is the stream containing all items.
+const readingList = "user/-/state/com.google/reading-list"
+
+// readState is the tag applied to items which have been read.
+const readState = "user/-/state/com.google/read"
+
+// freshrss is a Source which uses the Google Reader API, as implemented
+// by FreshRSS.
+type freshrss struct {
+
+	// url is the base URL of the API.
+	url string
+
+	// user and pass are the credentials used to login.
+	user string
+	pass string
+
+	// auth is the token returned by a successful login.
+	auth string
+}
+
+// Name is part of the Source interface.
+func (f *freshrss) Name() string {
+	return "FreshRSS"
+}
+
+// login retrieves an authentication token, if we've not already done so.
+func (f *freshrss) login() error {
+
+	if f.auth != "" {
+		return nil
+	}
+
+	resp, err := client.PostForm(f.url+"/accounts/ClientLogin", url.Values{
+		"Email":  {f.user},
+		"Passwd": {f.pass},
+	})
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = check(resp); err != nil {
+		return err
+	}
+
+	scanner := bufio.NewScanner(resp.Body)
+	for scanner.Scan() {
+		line := scanner.Text()
+		if strings.HasPrefix(line, "Auth=") {
+			f.auth = strings.TrimPrefix(line, "Auth=")
+		}
+	}
+	if f.auth == "" {
+		return fmt.Errorf("login to %s returned no token", f.url)
+	}
+	return nil
+}
+
+// request makes an authenticated request against the API, returning the
+// body of the response.
+func (f *freshrss) request(method string, path string, form url.Values) ([]byte, error) {
+
+	if err := f.login(); err != nil {
+		return nil, err
+	}
+
+	var body io.Reader
+	if form != nil {
+		body = strings.NewReader(form.Encode())
+	}
+
+	req, err := http.NewRequest(method, f.url+path, body)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Authorization", "GoogleLogin auth="+f.auth)
+	if form != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if err = check(resp); err != nil {
+		return nil, err
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// Subscriptions is part of the Source interface.
+func (f *freshrss) Subscriptions() ([]string, error) {
+
+	data, err := f.request("GET", "/reader/api/0/subscription/list?output=json", nil)
+	if err != nil {
+		return nil, err
+	}
+
+	var res struct {
+		Subscriptions []struct {
+			ID  string `json:"id"`
+			URL string `json:"url"`
+		} `json:"subscriptions"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, sub := range res.Subscriptions {
+		u := sub.URL
+		if u == "" {
+			u = strings.TrimPrefix(sub.ID, "feed/")
+		}
+		urls = append(urls, u)
+	}
+	return urls, nil
+}
+
+// Unread is part of the Source interface.
+func (f *freshrss) Unread() ([]Stream, error) {
+
+	params := url.Values{
+		"xt":     {readState},
+		"n":      {"1000"},
+		"r":      {"o"},
+		"output": {"json"},
+	}
+	data, err := f.request("GET", "/reader/api/0/stream/contents/"+readingList+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	type link struct {
+		Href string `json:"href"`
+	}
+	type text struct {
+		Content string `json:"content"`
+	}
+	var res struct {
+		Items []struct {
+			ID        string `json:"id"`
+			Title     string `json:"title"`
+			Author    string `json:"author"`
+			Published int64  `json:"published"`
+			Canonical []link `json:"canonical"`
+			Alternate []link `json:"alternate"`
+			Summary   text   `json:"summary"`
+			Content   text   `json:"content"`
+			Origin    struct {
+				StreamID string `json:"streamId"`
+				Title    string `json:"title"`
+				HTMLURL  string `json:"htmlUrl"`
+			} `json:"origin"`
+		} `json:"items"`
+	}
+	if err = json.Unmarshal(data, &res); err != nil {
+		return nil, err
+	}
+
+	var streams []Stream
+	index := make(map[string]int)
+
+	for _, ent := range res.Items {
+
+		feedURL := strings.TrimPrefix(ent.Origin.StreamID, "feed/")
+		i, ok := index[feedURL]
+		if !ok {
+			i = len(streams)
+			index[feedURL] = i
+			streams = append(streams, Stream{
+				URL:  feedURL,
+				Feed: &gofeed.Feed{Title: ent.Origin.Title, Link: ent.Origin.HTMLURL, FeedLink: feedURL},
+			})
+		}
+
+		link := ""
+		if len(ent.Canonical) > 0 {
+			link = ent.Canonical[0].Href
+		} else if len(ent.Alternate) > 0 {
+			link = ent.Alternate[0].Href
+		}
+
+		content := ent.Content.Content
+		if content == "" {
+			content = ent.Summary.Content
+		}
+
+		published := time.Unix(ent.Published, 0).UTC()
+		item := &gofeed.Item{
+			Title:           ent.Title,
+			Link:            link,
+			GUID:            link,
+			Content:         content,
+			Published:       published.Format(time.RFC3339),
+			PublishedParsed: &published,
+		}
+		if link == "" {
+			item.GUID = ent.ID
+		}
+		if ent.Author != "" {
+			item.Author = &gofeed.Person{Name: ent.Author}
+		}
+
+		streams[i].Feed.Items = append(streams[i].Feed.Items, item)
+		streams[i].IDs = append(streams[i].IDs, ent.ID)
+	}
+
+	return streams, nil
+}
+
+// MarkRead is part of the Source interface.
+func (f *freshrss) MarkRead(ids []string) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	// Modifications require a short-lived token.
+	token, err := f.request("GET", "/reader/api/0/token", nil)
+	if err != nil {
+		return err
+	}
+
+	form := url.Values{
+		"a": {readState},
+		"i": ids,
+		"T": {strings.TrimSpace(string(token))},
+	}
+	_, err = f.request("POST", "/reader/api/0/edit-tag", form)
+	return err
+}
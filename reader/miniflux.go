@@ -0,0 +1,166 @@
+package reader
+
+import (
+	"bytes"
+	"encoding/json"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// miniflux is a Source which uses the API of a Miniflux server.
+type miniflux struct {
+
+	// url is the base URL of the server.
+	url string
+
+	// token is the API key used to authenticate.
+	token string
+}
+
+// minifluxFeed is a feed, as returned by the Miniflux API.
+type minifluxFeed struct {
+	ID      int64  `json:"id"`
+	FeedURL string `json:"feed_url"`
+	SiteURL string `json:"site_url"`
+	Title   string `json:"title"`
+}
+
+// minifluxEntries is the list of entries returned by the Miniflux API.
+type minifluxEntries struct {
+	Total   int `json:"total"`
+	Entries []struct {
+		ID          int64        `json:"id"`
+		Title       string       `json:"title"`
+		URL         string       `json:"url"`
+		Author      string       `json:"author"`
+		Content     string       `json:"content"`
+		PublishedAt time.Time    `json:"published_at"`
+		Feed        minifluxFeed `json:"feed"`
+	} `json:"entries"`
+}
+
+// Name is part of the Source interface.
+func (m *miniflux) Name() string {
+	return "Miniflux"
+}
+
+// request makes an authenticated request against the API, decoding the
+// response into the given value, if non-nil.
+func (m *miniflux) request(method string, path string, body interface{}, out interface{}) error {
+
+	var data []byte
+	if body != nil {
+		var err error
+		data, err = json.Marshal(body)
+		if err != nil {
+			return err
+		}
+	}
+
+	req, err := http.NewRequest(method, m.url+path, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("X-Auth-Token", m.token)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if err = check(resp); err != nil {
+		return err
+	}
+	if out == nil {
+		return nil
+	}
+	return json.NewDecoder(resp.Body).Decode(out)
+}
+
+// Subscriptions is part of the Source interface.
+func (m *miniflux) Subscriptions() ([]string, error) {
+
+	var feeds []minifluxFeed
+	err := m.request("GET", "/v1/feeds", nil, &feeds)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, feed := range feeds {
+		urls = append(urls, feed.FeedURL)
+	}
+	return urls, nil
+}
+
+// Unread is part of the Source interface.
+func (m *miniflux) Unread() ([]Stream, error) {
+
+	var res minifluxEntries
+	err := m.request("GET", "/v1/entries?status=unread&order=published_at&direction=asc&limit=1000", nil, &res)
+	if err != nil {
+		return nil, err
+	}
+
+	var streams []Stream
+	index := make(map[string]int)
+
+	for _, ent := range res.Entries {
+
+		i, ok := index[ent.Feed.FeedURL]
+		if !ok {
+			i = len(streams)
+			index[ent.Feed.FeedURL] = i
+			streams = append(streams, Stream{
+				URL:  ent.Feed.FeedURL,
+				Feed: &gofeed.Feed{Title: ent.Feed.Title, Link: ent.Feed.SiteURL, FeedLink: ent.Feed.FeedURL},
+			})
+		}
+
+		published := ent.PublishedAt
+		item := &gofeed.Item{
+			Title:           ent.Title,
+			Link:            ent.URL,
+			GUID:            ent.URL,
+			Content:         ent.Content,
+			Published:       published.Format(time.RFC3339),
+			PublishedParsed: &published,
+		}
+		if ent.Author != "" {
+			item.Author = &gofeed.Person{Name: ent.Author}
+		}
+
+		streams[i].Feed.Items = append(streams[i].Feed.Items, item)
+		streams[i].IDs = append(streams[i].IDs, strconv.FormatInt(ent.ID, 10))
+	}
+
+	return streams, nil
+}
+
+// MarkRead is part of the Source interface.
+func (m *miniflux) MarkRead(ids []string) error {
+
+	if len(ids) == 0 {
+		return nil
+	}
+
+	body := struct {
+		IDs    []int64 `json:"entry_ids"`
+		Status string  `json:"status"`
+	}{Status: "read"}
+
+	for _, id := range ids {
+		n, err := strconv.ParseInt(id, 10, 64)
+		if err != nil {
+			return err
+		}
+		body.IDs = append(body.IDs, n)
+	}
+
+	return m.request("PUT", "/v1/entries", body, nil)
+}
@@ -0,0 +1,106 @@
+// Package reader allows a self-hosted feed-reader, such as Miniflux or
+// FreshRSS, to be used as a source of feeds.
+//
+// The reader is configured via environmental variables, for Miniflux:
+//
+//	MINIFLUX_URL    (e.g. "https://miniflux.example.com")
+//	MINIFLUX_TOKEN  (an API key created within Miniflux)
+//
+// Or for FreshRSS, which uses the Google Reader API:
+//
+//	FRESHRSS_URL       (e.g. "https://freshrss.example.com/api/greader.php")
+//	FRESHRSS_USER      (the name of the user)
+//	FRESHRSS_PASSWORD  (the API password of that user)
+//
+// The token, and password, may be references to secrets, such as
+// "file:/run/secrets/miniflux".
+//
+// The reader may be used to supply the list of subscriptions, which are
+// then fetched as if they were present in our configuration file, or to
+// supply the unread items themselves, which are marked as read once they
+// have been processed.
+package reader
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/secret"
+)
+
+// Stream holds the unread items of a single feed.
+type Stream struct {
+
+	// URL is the URL of the feed.
+	URL string
+
+	// Feed contains the details of the feed, and the unread items.
+	Feed *gofeed.Feed
+
+	// IDs contains the ID of each item, as known by the reader, in the
+	// same order as the items of the feed.
+	IDs []string
+}
+
+// Source is the interface which a feed-reader must implement.
+type Source interface {
+
+	// Name returns the name of the reader, for diagnostics.
+	Name() string
+
+	// Subscriptions returns the URLs of the subscribed feeds.
+	Subscriptions() ([]string, error)
+
+	// Unread returns the unread items, grouped by feed.
+	Unread() ([]Stream, error)
+
+	// MarkRead marks the items with the given IDs as having been read.
+	MarkRead(ids []string) error
+}
+
+// client is the HTTP-client used to make requests against the reader.
+var client = &http.Client{Timeout: 60 * time.Second}
+
+// New returns the reader configured via the environment, or nil if there
+// is none.
+//
+// An error is returned if a reader is only partially configured.
+func New() (Source, error) {
+
+	if url := os.Getenv("MINIFLUX_URL"); url != "" {
+		token, err := secret.Getenv("MINIFLUX_TOKEN")
+		if err != nil {
+			return nil, err
+		}
+		if token == "" {
+			return nil, fmt.Errorf("MINIFLUX_URL is set, but MINIFLUX_TOKEN is not")
+		}
+		return &miniflux{url: strings.TrimSuffix(url, "/"), token: token}, nil
+	}
+
+	if url := os.Getenv("FRESHRSS_URL"); url != "" {
+		user := os.Getenv("FRESHRSS_USER")
+		pass, err := secret.Getenv("FRESHRSS_PASSWORD")
+		if err != nil {
+			return nil, err
+		}
+		if user == "" || pass == "" {
+			return nil, fmt.Errorf("FRESHRSS_URL is set, but FRESHRSS_USER or FRESHRSS_PASSWORD is not")
+		}
+		return &freshrss{url: strings.TrimSuffix(url, "/"), user: user, pass: pass}, nil
+	}
+
+	return nil, nil
+}
+
+// check returns an error if the given response was not successful.
+func check(resp *http.Response) error {
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return fmt.Errorf("request to %s failed: %s", resp.Request.URL, resp.Status)
+	}
+	return nil
+}
@@ -0,0 +1,188 @@
+package reader
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"testing"
+)
+
+func TestNew(t *testing.T) {
+
+	for _, env := range []string{"MINIFLUX_URL", "MINIFLUX_TOKEN", "FRESHRSS_URL", "FRESHRSS_USER", "FRESHRSS_PASSWORD"} {
+		os.Unsetenv(env)
+	}
+
+	src, err := New()
+	if src != nil || err != nil {
+		t.Fatalf("expected no reader by default")
+	}
+
+	os.Setenv("MINIFLUX_URL", "https://example.com/")
+	defer os.Unsetenv("MINIFLUX_URL")
+
+	_, err = New()
+	if err == nil {
+		t.Fatalf("expected error with missing token")
+	}
+
+	os.Setenv("MINIFLUX_TOKEN", "secret")
+	defer os.Unsetenv("MINIFLUX_TOKEN")
+
+	src, err = New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if src.Name() != "Miniflux" {
+		t.Fatalf("unexpected reader %s", src.Name())
+	}
+}
+
+func TestMiniflux(t *testing.T) {
+
+	var read []int64
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("X-Auth-Token") != "secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.Method + " " + r.URL.Path {
+		case "GET /v1/feeds":
+			w.Write([]byte(`[{"id":1,"feed_url":"https://example.com/rss","title":"Example"}]`))
+		case "GET /v1/entries":
+			w.Write([]byte(`{"total":2,"entries":[
+ {"id":10,"title":"One","url":"https://example.com/1","content":"<p>one</p>","published_at":"2021-01-02T03:04:05Z",
+  "feed":{"feed_url":"https://example.com/rss","title":"Example","site_url":"https://example.com/"}},
+ {"id":11,"title":"Two","url":"https://example.com/2","content":"<p>two</p>","published_at":"2021-01-03T03:04:05Z",
+  "feed":{"feed_url":"https://example.com/rss","title":"Example","site_url":"https://example.com/"}}]}`))
+		case "PUT /v1/entries":
+			var body struct {
+				IDs    []int64 `json:"entry_ids"`
+				Status string  `json:"status"`
+			}
+			json.NewDecoder(r.Body).Decode(&body)
+			if body.Status == "read" {
+				read = append(read, body.IDs...)
+			}
+			w.WriteHeader(http.StatusNoContent)
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	m := &miniflux{url: server.URL, token: "secret"}
+
+	subs, err := m.Subscriptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(subs) != 1 || subs[0] != "https://example.com/rss" {
+		t.Fatalf("unexpected subscriptions: %v", subs)
+	}
+
+	streams, err := m.Unread()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(streams) != 1 || len(streams[0].Feed.Items) != 2 {
+		t.Fatalf("unexpected streams: %v", streams)
+	}
+	if streams[0].Feed.Title != "Example" || streams[0].Feed.Items[1].Link != "https://example.com/2" {
+		t.Fatalf("unexpected feed contents")
+	}
+
+	err = m.MarkRead(streams[0].IDs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(read) != 2 || read[0] != 10 || read[1] != 11 {
+		t.Fatalf("unexpected items marked read: %v", read)
+	}
+
+	// Bad credentials are an error
+	m = &miniflux{url: server.URL, token: "wrong"}
+	_, err = m.Subscriptions()
+	if err == nil {
+		t.Fatalf("expected error with bad token")
+	}
+}
+
+func TestFreshRSS(t *testing.T) {
+
+	var read url.Values
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/accounts/ClientLogin" {
+			r.ParseForm()
+			if r.Form.Get("Passwd") != "secret" {
+				w.WriteHeader(http.StatusUnauthorized)
+				return
+			}
+			w.Write([]byte("SID=x\nLSID=y\nAuth=token\n"))
+			return
+		}
+		if r.Header.Get("Authorization") != "GoogleLogin auth=token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/reader/api/0/subscription/list":
+			w.Write([]byte(`{"subscriptions":[{"id":"feed/https://example.com/rss"},{"id":"feed/2","url":"https://example.net/atom"}]}`))
+		case "/reader/api/0/stream/contents/" + readingList:
+			w.Write([]byte(`{"items":[
+ {"id":"tag:google.com,2005:reader/item/1","title":"One","published":1609556645,
+  "canonical":[{"href":"https://example.com/1"}],"summary":{"content":"<p>one</p>"},
+  "origin":{"streamId":"feed/https://example.com/rss","title":"Example"}}]}`))
+		case "/reader/api/0/token":
+			w.Write([]byte("edit-token\n"))
+		case "/reader/api/0/edit-tag":
+			body, _ := io.ReadAll(r.Body)
+			read, _ = url.ParseQuery(string(body))
+		default:
+			w.WriteHeader(http.StatusNotFound)
+		}
+	}))
+	defer server.Close()
+
+	f := &freshrss{url: server.URL, user: "steve", pass: "secret"}
+
+	subs, err := f.Subscriptions()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(subs) != 2 || subs[0] != "https://example.com/rss" || subs[1] != "https://example.net/atom" {
+		t.Fatalf("unexpected subscriptions: %v", subs)
+	}
+
+	streams, err := f.Unread()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if len(streams) != 1 || streams[0].URL != "https://example.com/rss" {
+		t.Fatalf("unexpected streams: %v", streams)
+	}
+	item := streams[0].Feed.Items[0]
+	if item.Link != "https://example.com/1" || item.Content != "<p>one</p>" {
+		t.Fatalf("unexpected item: %v", item)
+	}
+
+	err = f.MarkRead(streams[0].IDs)
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if read.Get("T") != "edit-token" || read.Get("i") != "tag:google.com,2005:reader/item/1" || read.Get("a") != readState {
+		t.Fatalf("unexpected edit: %v", read)
+	}
+
+	// Bad credentials are an error
+	f = &freshrss{url: server.URL, user: "steve", pass: "wrong"}
+	_, err = f.Subscriptions()
+	if err == nil {
+		t.Fatalf("expected error with bad password")
+	}
+}
@@ -0,0 +1,134 @@
+//
+// Render the email for an item of a feed, without sending it.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/processor/emailer"
+)
+
+// Structure for our options and state.
+type renderCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// The number of the item to render, starting from one.
+	item int
+
+	// The file to write the email to, rather than STDOUT.
+	output string
+
+	// The address to send the email to.
+	to string
+
+	// The address to use in the From: header.
+	from string
+
+	// The name of the embedded template to use.
+	style string
+
+	// The time zone, and layout, of the dates shown within the email.
+	timezone   string
+	dateFormat string
+
+	// Should we embed the icon of the feed?
+	favicon bool
+}
+
+// Arguments handles our flag-setup.
+func (r *renderCmd) Arguments(f *flag.FlagSet) {
+	r.config = configfile.New()
+
+	f.IntVar(&r.item, "item", 1, "The number of the item to render, the first being 1.")
+	f.StringVar(&r.output, "o", "", "The file to write the email to, rather than STDOUT.")
+	f.StringVar(&r.to, "to", "user@example.com", "The recipient of the email.")
+	f.StringVar(&r.from, "from", "", "The address to use in the From: header, rather than the recipient.")
+	f.StringVar(&r.style, "style", "", "The embedded template to use, 'plain' or 'styled'.")
+	f.StringVar(&r.timezone, "timezone", "", "The time zone in which dates are shown, rather than the local one.")
+	f.StringVar(&r.dateFormat, "date-format", emailer.DefaultDateFormat, "The layout of the dates shown, as used by Go's time package.")
+	f.BoolVar(&r.favicon, "favicon", false, "Embed the icon of the feed within the email?")
+}
+
+// Info is part of the subcommand-API.
+func (r *renderCmd) Info() (string, string) {
+	return "render", `Render the email for an item of a feed, without sending it.
+
+This sub-command fetches the given feed, and writes the complete email
+which would be sent for one of its items, by default the first, to the
+given file, or STDOUT.  You may open the file, which is in the '.eml'
+format, in your mail client to see how the email will appear.
+
+This allows you to iterate upon your templates, and the options of your
+feeds, without waiting for new items to appear.  If the feed is present
+within the configuration file, which may be given by its URL or name,
+its options are used.  Nothing is sent, and the state of the item is not
+changed.
+
+Example:
+
+    $ rss2email render -o sample.eml https://blog.steve.fi/index.rss
+    $ rss2email render -item 3 -style styled -o sample.eml blog
+`
+}
+
+// Execute is invoked if the user specifies `render` as the subcommand.
+func (r *renderCmd) Execute(args []string) int {
+
+	if len(args) != 1 {
+		fmt.Printf("Usage: rss2email render [flags] feed-url\n")
+		return 1
+	}
+
+	// Use the options of the feed, if it is configured.
+	entry := configfile.Feed{URL: args[0]}
+	if r.config.Exists() {
+		entries, err := r.config.Parse()
+		if err != nil {
+			fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+			return 1
+		}
+
+		found := configfile.Find(entries, args[0])
+		if len(found) > 1 {
+			fmt.Printf("'%s' matches %d feeds, please be more specific\n", args[0], len(found))
+			return 1
+		}
+		if len(found) == 1 {
+			entry = found[0]
+		}
+	}
+
+	p := processor.New()
+	p.SetFrom(r.from)
+	p.SetStyle(r.style)
+	p.SetTimezone(r.timezone)
+	p.SetDateFormat(r.dateFormat)
+	p.SetFavicon(r.favicon)
+
+	content, err := p.Render(context.Background(), entry, r.item-1, emailer.SplitAddresses(r.to))
+	if err != nil {
+		fmt.Printf("failed to render %s: %s\n", entry.Label(), err.Error())
+		return 1
+	}
+
+	if r.output == "" {
+		fmt.Fprintf(out, "%s", content)
+		return 0
+	}
+
+	err = os.WriteFile(r.output, content, 0644)
+	if err != nil {
+		fmt.Printf("failed to write %s: %s\n", r.output, err.Error())
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,70 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestRender(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, `<?xml version="1.0"?><rss version="2.0"><channel><title>Example</title>
+<item><title>First</title><link>https://example.com/1</link><description>One</description></item>
+<item><title>Second</title><link>https://example.com/2</link><description>Two</description></item>
+</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(cfg, []byte(ts.URL+"/feed\n - name: Sample\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	r := renderCmd{config: configfile.NewWithPath(cfg), item: 1, to: "steve@example.com"}
+
+	if r.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with no arguments")
+	}
+
+	// The first item, by name, to STDOUT.
+	out = new(bytes.Buffer)
+	if r.Execute([]string{"sample"}) != 0 {
+		t.Fatalf("unexpected error rendering")
+	}
+	output := out.(*bytes.Buffer).String()
+	for _, expected := range []string{"To: steve@example.com", "Subject: [Sample] First", "X-RSS-Link: https://example.com/1"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output didn't contain %q: %s", expected, output)
+		}
+	}
+
+	// The second item, to a file.
+	r.item = 2
+	r.output = filepath.Join(dir, "out.eml")
+	if r.Execute([]string{ts.URL + "/feed"}) != 0 {
+		t.Fatalf("unexpected error rendering")
+	}
+	data, err := os.ReadFile(r.output)
+	if err != nil || !strings.Contains(string(data), "Subject: [Sample] Second") {
+		t.Fatalf("unexpected email: %s %v", data, err)
+	}
+
+	// There is no third item.
+	r.item = 3
+	if r.Execute([]string{ts.URL + "/feed"}) != 1 {
+		t.Fatalf("expected error with missing item")
+	}
+}
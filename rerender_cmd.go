@@ -0,0 +1,154 @@
+//
+// Send items again, from the cache of their content.
+//
+
+package main
+
+import (
+	"context"
+	"flag"
+	"fmt"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Structure for our options and state.
+type rerenderCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Send the items of this feed, by URL or name, rather than all.
+	feed string
+
+	// Send the items cached within this period.
+	since time.Duration
+
+	// The address to use in the From: header.
+	from string
+
+	// The name of the embedded template to use.
+	style string
+
+	// The time zone, and layout, of the dates shown within emails.
+	timezone   string
+	dateFormat string
+
+	// Should we embed the icon of the feed?
+	favicon bool
+
+	// Show the items which would be sent, without sending them?
+	dryRun bool
+}
+
+// Arguments handles our flag-setup.
+func (r *rerenderCmd) Arguments(f *flag.FlagSet) {
+	r.config = configfile.New()
+
+	f.StringVar(&r.feed, "feed", "", "Send the items of this feed, by URL or name, rather than those of every feed.")
+	f.DurationVar(&r.since, "since", 0, "Send the items cached within this period, e.g. '48h'.")
+	f.StringVar(&r.from, "from", "", "The address to use in the From: header, rather than the recipient.")
+	f.StringVar(&r.style, "style", "", "The embedded template to use, 'plain' or 'styled'.")
+	f.StringVar(&r.timezone, "timezone", "", "The time zone in which dates are shown, rather than the local one.")
+	f.StringVar(&r.dateFormat, "date-format", emailer.DefaultDateFormat, "The layout of the dates shown, as used by Go's time package.")
+	f.BoolVar(&r.favicon, "favicon", false, "Embed the icon of each feed within the emails?")
+	f.BoolVar(&r.dryRun, "dry-run", false, "Show the items which would be sent, without sending them?")
+}
+
+// Info is part of the subcommand-API.
+func (r *rerenderCmd) Info() (string, string) {
+	return "rerender", `Send items again, rendered with the current template.
+
+If the 'cron', or 'daemon', sub-commands are given the '-cache-items' flag
+then the content of each item which is sent is kept, as it was fetched,
+for the given number of days.  This sub-command sends those items again,
+rendered as they would be now, with your current template, the current
+options of their feeds, and your current delivery settings.
+
+This is useful once you've changed your template, or the way in which
+emails are delivered, as the items may no longer be present within their
+feeds.  The items are chosen by the time they were cached, via the
+'-since' flag, and optionally by their feed.
+
+Example:
+
+    $ rss2email rerender -since 48h steve@example.com
+    $ rss2email rerender -since 168h -feed blog -style styled steve@example.com
+`
+}
+
+// Execute is invoked if the user specifies `rerender` as the subcommand.
+func (r *rerenderCmd) Execute(args []string) int {
+
+	if r.since <= 0 {
+		fmt.Printf("Usage: rss2email rerender -since 48h [flags] email1 .. emailN\n")
+		return 1
+	}
+
+	recipients := emailer.SplitAddresses(envRecipients(args)...)
+	if len(recipients) == 0 && !r.dryRun {
+		fmt.Printf("Usage: rss2email rerender -since 48h [flags] email1 .. emailN\n")
+		return 1
+	}
+
+	entries, err := r.config.Parse()
+	if err != nil {
+		fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+		return 1
+	}
+	if r.feed != "" {
+		entries = configfile.Find(entries, r.feed)
+		if len(entries) == 0 {
+			fmt.Printf("'%s' doesn't match any feed\n", r.feed)
+			return 1
+		}
+	}
+
+	p := processor.New()
+	p.SetFrom(r.from)
+	p.SetStyle(r.style)
+	p.SetTimezone(r.timezone)
+	p.SetDateFormat(r.dateFormat)
+	p.SetFavicon(r.favicon)
+
+	since := time.Now().Add(-r.since)
+	count := 0
+	failed := 0
+	for _, entry := range entries {
+
+		cached, err := withstate.CachedItems(entry.URL, since)
+		if err != nil {
+			fmt.Printf("failed to read the cached items of %s: %s\n", entry.Label(), err.Error())
+			return 1
+		}
+
+		for _, item := range cached {
+			count++
+
+			if r.dryRun {
+				fmt.Fprintf(out, "%s: %s: would send\n", entry.Label(), item.Item.Title)
+				continue
+			}
+
+			err = p.Rerender(context.Background(), entry, item, recipients)
+			if err != nil {
+				fmt.Fprintf(out, "%s: %s: failed to send: %s\n", entry.Label(), item.Item.Title, err.Error())
+				failed++
+				continue
+			}
+			fmt.Fprintf(out, "%s: %s: sent\n", entry.Label(), item.Item.Title)
+		}
+	}
+
+	if count == 0 {
+		fmt.Fprintf(out, "No items were cached within %s, is the '-cache-items' flag set?\n", r.since)
+	}
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,77 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestRerender(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	dir := t.TempDir()
+	cfg := filepath.Join(dir, "feeds.txt")
+	err := os.WriteFile(cfg, []byte("https://example.com/feed\n - name: Sample\nhttps://example.net/feed\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	feed := &gofeed.Feed{Title: "Example"}
+	for _, title := range []string{"First", "Second"} {
+		err = withstate.CacheItem("https://example.com/feed", feed, &gofeed.Item{Title: title, Link: "https://example.com/" + title})
+		if err != nil {
+			t.Fatalf("failed to cache item: %s", err)
+		}
+	}
+
+	r := rerenderCmd{config: configfile.NewWithPath(cfg)}
+
+	// The period is required, as are recipients.
+	if r.Execute([]string{"steve@example.com"}) != 1 {
+		t.Fatalf("expected error without -since")
+	}
+	r.since = time.Hour
+	if r.Execute([]string{}) != 1 {
+		t.Fatalf("expected error without recipients")
+	}
+
+	// Unknown feeds are reported.
+	r.dryRun = true
+	r.feed = "missing"
+	if r.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with an unknown feed")
+	}
+
+	// The cached items are listed, oldest first.
+	out = new(bytes.Buffer)
+	r.feed = "sample"
+	if r.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error")
+	}
+	expected := "Sample: First: would send\nSample: Second: would send\n"
+	if out.(*bytes.Buffer).String() != expected {
+		t.Fatalf("unexpected output: %s", out.(*bytes.Buffer).String())
+	}
+
+	// Nothing is cached for the other feed.
+	out = new(bytes.Buffer)
+	r.feed = "https://example.net/feed"
+	if r.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error")
+	}
+	if !strings.Contains(out.(*bytes.Buffer).String(), "No items were cached") {
+		t.Fatalf("unexpected output: %s", out.(*bytes.Buffer).String())
+	}
+}
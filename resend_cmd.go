@@ -0,0 +1,269 @@
+//
+// Resend items from the archive.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/archive"
+	"github.com/skx/rss2email/audit"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Structure for our options and state.
+type resendCmd struct {
+
+	// The path to the archive, used for testing
+	path string
+
+	// The path to the delivery log, used for testing
+	log string
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Resend the items of the feed with this URL.
+	feed string
+
+	// Resend the items archived within this period.
+	since time.Duration
+
+	// Comma-separated addresses to send to, rather than the original
+	// recipients.
+	to string
+
+	// The address to use in the From: header.
+	from string
+
+	// The envelope sender to pass to the MTA.
+	envelopeFrom string
+
+	// Show the items which would be sent, without sending them?
+	dryRun bool
+}
+
+// Arguments handles our flag-setup.
+func (r *resendCmd) Arguments(f *flag.FlagSet) {
+	r.path = archive.Path()
+	r.log = audit.Path()
+	r.config = configfile.New()
+
+	f.StringVar(&r.feed, "feed", "", "Resend the items of the feed with this URL.")
+	f.DurationVar(&r.since, "since", 0, "Resend the items archived within this period, e.g. '24h'.")
+	f.StringVar(&r.to, "to", "", "Comma-separated addresses to send to, rather than the original recipients.")
+	f.StringVar(&r.from, "from", "", "The address to use in the From: header, rather than the recipient.")
+	f.StringVar(&r.envelopeFrom, "envelope-from", "", "The envelope sender to pass to the MTA, which receives bounces.")
+	f.BoolVar(&r.dryRun, "dry-run", false, "Show the items which would be sent, without sending them?")
+}
+
+// Info is part of the subcommand-API.
+func (r *resendCmd) Info() (string, string) {
+	return "resend", `Resend items from the archive.
+
+If the 'cron', or 'daemon', sub-commands are given the '-archive' flag
+then each item which is emailed is stored within a SQLite database at
+'~/.rss2email/archive.db'.  This sub-command sends those items again,
+which is useful if emails were lost or deleted by accident.
+
+Items may be chosen by the IDs shown by the 'search' sub-command, or by
+the feed they came from and the time they were archived, via the '-feed'
+and '-since' flags.
+
+Each email is generated again, from the archived content, using the
+current template and the options of the feed.  Emails are sent to the
+original recipients, as recorded in the delivery log, unless the '-to'
+flag is given.
+
+Example:
+
+    $ rss2email resend 17 18
+    $ rss2email resend -feed https://blog.steve.fi/index.rss -since 48h
+    $ rss2email resend -since 24h -to steve@example.com
+`
+}
+
+// entries returns the archived entries chosen by our arguments.
+func (r *resendCmd) entries(db *archive.Archive, args []string) ([]archive.Entry, error) {
+
+	if len(args) == 0 {
+		return db.List(r.feed, time.Now().Add(-r.since))
+	}
+
+	var entries []archive.Entry
+	for _, arg := range args {
+
+		id, err := strconv.ParseInt(arg, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("invalid item ID '%s'", arg)
+		}
+
+		e, err := db.Get(id)
+		if err != nil {
+			return nil, err
+		}
+		if e == nil {
+			return nil, fmt.Errorf("there is no archived item with ID %d", id)
+		}
+		entries = append(entries, *e)
+	}
+	return entries, nil
+}
+
+// recipients returns the addresses the given entry was originally sent
+// to, as recorded within the delivery log, unless we've been given
+// addresses to use instead.
+func (r *resendCmd) recipients(entry archive.Entry, records []audit.Record) []string {
+
+	if r.to != "" {
+		return emailer.SplitAddresses(r.to)
+	}
+
+	var found []string
+	for _, rec := range records {
+		if rec.Feed == entry.Feed && rec.GUID == entry.GUID && rec.Link == entry.Link && len(rec.Recipients) > 0 {
+			found = rec.Recipients
+		}
+	}
+	return found
+}
+
+// send generates the email for the given entry, and sends it.
+func (r *resendCmd) send(entry archive.Entry, options []configfile.Option, recipients []string) error {
+
+	feed := &gofeed.Feed{Title: entry.FeedTitle, FeedLink: entry.Feed}
+	item := withstate.FeedItem{Item: &gofeed.Item{
+		GUID:  entry.GUID,
+		Title: entry.Title,
+		Link:  entry.Link,
+	}}
+	if !entry.Published.IsZero() {
+		item.PublishedParsed = &entry.Published
+		item.Published = entry.Published.Format(time.RFC1123Z)
+	}
+
+	helper := emailer.New(feed, item, options)
+	helper.SetFrom(r.from)
+	helper.SetEnvelopeFrom(r.envelopeFrom)
+
+	msg, err := helper.Render(recipients, entry.Text, entry.HTML)
+	if err != nil {
+		return err
+	}
+	if msg.TemplateError != nil {
+		fmt.Fprintf(out, "%d %s: template failed, using the built-in template: %s\n", entry.ID, entry.Title, msg.TemplateError.Error())
+	}
+
+	err = helper.Send(msg)
+
+	record := audit.Record{
+		Feed:       entry.Feed,
+		FeedTitle:  entry.FeedTitle,
+		GUID:       entry.GUID,
+		Title:      entry.Title,
+		Link:       entry.Link,
+		Recipients: recipients,
+		Backend:    msg.Backend,
+		Result:     audit.ResultSent,
+		Response:   msg.Response,
+	}
+	if err != nil {
+		record.Result = audit.ResultFailed
+		if emailer.IsPermanent(err) {
+			record.Result = audit.ResultRejected
+		}
+		record.Error = err.Error()
+	}
+	if aerr := audit.Append(r.log, record); aerr != nil {
+		fmt.Printf("failed to record delivery: %s\n", aerr.Error())
+	}
+
+	return err
+}
+
+// Execute is invoked if the user specifies `resend` as the subcommand.
+func (r *resendCmd) Execute(args []string) int {
+
+	if len(args) == 0 && r.feed == "" && r.since == 0 {
+		fmt.Printf("Usage: rss2email resend [flags] id1 id2 .. idN\n")
+		fmt.Printf("       rss2email resend [flags] -feed URL -since 24h\n")
+		return 1
+	}
+
+	// Don't create an empty archive.
+	if _, err := os.Stat(r.path); err != nil {
+		fmt.Printf("failed to open archive %s: %s\n", r.path, err.Error())
+		return 1
+	}
+
+	db, err := archive.Open(r.path)
+	if err != nil {
+		fmt.Printf("failed to open archive %s: %s\n", r.path, err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	entries, err := r.entries(db, args)
+	if err != nil {
+		fmt.Printf("failed to find items: %s\n", err.Error())
+		return 1
+	}
+
+	records, err := audit.Read(r.log)
+	if err != nil {
+		fmt.Printf("failed to read delivery log %s: %s\n", r.log, err.Error())
+		return 1
+	}
+
+	// The options of each feed, so that emails are generated as they
+	// were originally.  A missing configuration file is fine.
+	options := make(map[string][]configfile.Option)
+	if r.config.Exists() {
+		feeds, err := r.config.Parse()
+		if err != nil {
+			fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+			return 1
+		}
+		for _, feed := range feeds {
+			options[feed.URL] = feed.Options
+		}
+	}
+
+	failed := 0
+	for _, entry := range entries {
+
+		recipients := r.recipients(entry, records)
+		if len(recipients) == 0 {
+			fmt.Fprintf(out, "%d %s: no recipients are known, use -to\n", entry.ID, entry.Title)
+			failed++
+			continue
+		}
+
+		if r.dryRun {
+			fmt.Fprintf(out, "%d %s: would send to %s\n", entry.ID, entry.Title, strings.Join(recipients, ", "))
+			continue
+		}
+
+		err = r.send(entry, options[entry.Feed], recipients)
+		if err != nil {
+			fmt.Fprintf(out, "%d %s: failed to send: %s\n", entry.ID, entry.Title, err.Error())
+			failed++
+			continue
+		}
+		fmt.Fprintf(out, "%d %s: sent to %s\n", entry.ID, entry.Title, strings.Join(recipients, ", "))
+	}
+
+	if failed > 0 {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,94 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/archive"
+	"github.com/skx/rss2email/audit"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestResend(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	dir := t.TempDir()
+	path := filepath.Join(dir, "archive.db")
+	log := filepath.Join(dir, "delivery.log")
+
+	r := resendCmd{path: path, log: log, config: configfile.NewWithPath(filepath.Join(dir, "feeds.txt")), dryRun: true}
+
+	// No items chosen is an error
+	out = new(bytes.Buffer)
+	if r.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with no arguments")
+	}
+
+	// A missing archive is an error
+	if r.Execute([]string{"1"}) != 1 {
+		t.Fatalf("expected error with missing archive")
+	}
+
+	db, err := archive.Open(path)
+	if err != nil {
+		t.Fatalf("failed to create archive: %s", err)
+	}
+	entries := []archive.Entry{
+		{Feed: "https://example.com/rss", GUID: "1", Title: "Hello World", Link: "https://example.com/1", Text: "Some text", Status: archive.StatusSent},
+		{Feed: "https://example.org/atom", GUID: "2", Title: "Another", Link: "https://example.org/2", Text: "More text", Status: archive.StatusSent},
+	}
+	for _, e := range entries {
+		if err = db.Add(e); err != nil {
+			t.Fatalf("failed to add entry: %s", err)
+		}
+	}
+	db.Close()
+
+	err = audit.Append(log, audit.Record{Feed: "https://example.com/rss", GUID: "1", Link: "https://example.com/1", Recipients: []string{"steve@example.com"}, Result: audit.ResultSent})
+	if err != nil {
+		t.Fatalf("failed to append: %s", err)
+	}
+
+	// Bogus, and missing, IDs are errors
+	for _, arg := range []string{"one", "17"} {
+		if r.Execute([]string{arg}) != 1 {
+			t.Fatalf("expected error with ID %s", arg)
+		}
+	}
+
+	// The original recipients are found in the delivery log.
+	out = new(bytes.Buffer)
+	if r.Execute([]string{"1"}) != 0 {
+		t.Fatalf("unexpected error resending")
+	}
+	if !strings.Contains(out.(*bytes.Buffer).String(), "1 Hello World: would send to steve@example.com") {
+		t.Fatalf("unexpected output: %s", out.(*bytes.Buffer).String())
+	}
+
+	// The second item has no known recipients.
+	out = new(bytes.Buffer)
+	if r.Execute([]string{"2"}) != 1 {
+		t.Fatalf("expected error with no recipients")
+	}
+	if !strings.Contains(out.(*bytes.Buffer).String(), "no recipients are known") {
+		t.Fatalf("unexpected output: %s", out.(*bytes.Buffer).String())
+	}
+
+	// Choose by feed, and time, with explicit recipients.
+	r.feed = "https://example.org/atom"
+	r.since = time.Hour
+	r.to = "bob@example.org, jim@example.org"
+	out = new(bytes.Buffer)
+	if r.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error resending")
+	}
+	output := out.(*bytes.Buffer).String()
+	if !strings.Contains(output, "2 Another: would send to bob@example.org, jim@example.org") || strings.Contains(output, "Hello") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+}
@@ -0,0 +1,128 @@
+//
+// Restore archived feeds.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+	"github.com/skx/subcommands"
+)
+
+// Structure for our options and state.
+type restoreCmd struct {
+
+	// We embed the NoFlags option, because we accept no command-line flags.
+	subcommands.NoFlags
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (r *restoreCmd) Arguments(flags *flag.FlagSet) {
+	r.config = configfile.New()
+}
+
+// Info is part of the subcommand-API
+func (r *restoreCmd) Info() (string, string) {
+	return "restore", `Restore archived feeds.
+
+Feeds which are probably dead may be archived, via the '-archive-dead' flag
+of the 'cron' and 'daemon' sub-commands, after which they are no longer
+fetched.  This command restores the specified URLs, or named feeds, so that
+they are fetched once again.
+
+The items the feed contains when it is next fetched are recorded as seen,
+rather than being sent, so there's no flood of old items.
+
+If no feeds are specified the archived feeds are listed.
+
+Example:
+
+    $ rss2email restore
+    $ rss2email restore https://blog.steve.fi/index.rss
+`
+}
+
+// Execute is invoked if the user specifies `restore` as the subcommand.
+func (r *restoreCmd) Execute(args []string) int {
+
+	// Upgrade our configuration-file if necessary
+	r.config.Upgrade()
+
+	entries, err := r.config.Parse()
+	if err != nil {
+		fmt.Printf("Error with config-file: %s\n", err.Error())
+		return 1
+	}
+
+	var archived []configfile.Feed
+	for _, entry := range entries {
+		if entry.Archived() {
+			archived = append(archived, entry)
+		}
+	}
+
+	// No arguments?  Then show the archived feeds.
+	if len(args) == 0 {
+		for _, entry := range archived {
+			for _, opt := range entry.Options {
+				if opt.Name == "archived" {
+					fmt.Fprintf(out, "%s\n\tArchived %s\n", entry.Label(), opt.Value)
+				}
+			}
+		}
+		return 0
+	}
+
+	// Feeds may be given by name, as well as by URL.
+	for _, arg := range args {
+		found := configfile.Find(archived, arg)
+		if len(found) == 0 {
+			fmt.Printf("'%s' doesn't match any archived feed\n", arg)
+			return 1
+		}
+		if len(found) > 1 {
+			fmt.Printf("'%s' matches more than one feed, please be more specific\n", arg)
+			return 1
+		}
+
+		entry := found[0]
+		var opts []configfile.Option
+		for _, opt := range entry.Options {
+			if opt.Name != "archived" {
+				opts = append(opts, opt)
+			}
+		}
+		entry.Options = opts
+		r.config.Update(entry)
+
+		// Its items are recorded as seen, which requires that the
+		// feed is fetched in full.
+		err = withstate.SetCatchUp(entry.URL, true)
+		if err == nil {
+			err = withstate.SetCheckpoint(entry.URL, withstate.Checkpoint{})
+		}
+		if err != nil {
+			fmt.Printf("failed to restore %s: %s\n", entry.Label(), err.Error())
+			return 1
+		}
+	}
+
+	// Save the list.
+	err = r.config.Save()
+	if err != nil {
+		fmt.Printf("failed to save the updated feed list: %s\n", err.Error())
+		return 1
+	}
+
+	return 0
+}
@@ -0,0 +1,65 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestRestore(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	path := filepath.Join(t.TempDir(), "feeds.txt")
+	os.WriteFile(path, []byte(`https://example.com/alive
+
+`+configfile.ArchivedHeader+`
+https://example.com/dead
+ - name: Dead
+ - archived: 2020-01-01, no new items since 2019-06-01
+`), 0644)
+
+	r := restoreCmd{config: configfile.NewWithPath(path)}
+
+	// With no arguments the archived feeds are listed.
+	if r.Execute([]string{}) != 0 {
+		t.Fatalf("failed to list archived feeds")
+	}
+	if out.(*bytes.Buffer).String() != "Dead\n\tArchived 2020-01-01, no new items since 2019-06-01\n" {
+		t.Fatalf("unexpected output: %s", out)
+	}
+
+	// Only archived feeds may be restored.
+	if r.Execute([]string{"https://example.com/alive"}) != 1 {
+		t.Fatalf("expected failure restoring a feed which isn't archived")
+	}
+
+	if r.Execute([]string{"dead"}) != 0 {
+		t.Fatalf("failed to restore by name")
+	}
+
+	data, _ := os.ReadFile(path)
+	if strings.Contains(string(data), "archived") {
+		t.Fatalf("feed wasn't restored:\n%s", data)
+	}
+	entries, _ := configfile.NewWithPath(path).Parse()
+	if len(entries) != 2 || entries[1].URL != "https://example.com/dead" || entries[1].Archived() {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+
+	// Its items will be caught up with.
+	catchUp, _ := withstate.CatchUp("https://example.com/dead")
+	if !catchUp {
+		t.Fatalf("restored feed won't catch up")
+	}
+}
@@ -0,0 +1,286 @@
+// Package river writes the items we've processed into a static HTML site,
+// providing a browsable web archive of everything received.
+//
+// The site contains a page for each day, listing the items received upon
+// that day, and a page for each feed.  An index page links to them all:
+//
+//	index.html
+//	days/2021-01-02.html
+//	feeds/<hash>.html
+//
+// The items themselves are stored as JSON files beneath "items/", from
+// which the pages are regenerated.
+package river
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"encoding/json"
+	"html/template"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+// dayFormat is the format used for the names of the daily pages.
+const dayFormat = "2006-01-02"
+
+// Item is a single item which has been received.
+//
+// Items are also written to the aggregated feed, which is why they hold
+// their GUID and author, which our pages don't show.
+type Item struct {
+
+	// Feed is the URL of the feed, and FeedTitle its title.
+	Feed      string `json:"feed"`
+	FeedTitle string `json:"feed_title"`
+
+	// Title and Link identify the item.
+	Title string `json:"title"`
+	Link  string `json:"link"`
+
+	// GUID identifies the item within its feed.
+	GUID string `json:"guid,omitempty"`
+
+	// Author is the name of the author of the item, if known.
+	Author string `json:"author,omitempty"`
+
+	// Published is the date the item was published, if known.
+	Published time.Time `json:"published"`
+
+	// Received is the time at which we received the item.
+	Received time.Time `json:"received"`
+
+	// HTML is the content of the item.
+	HTML string `json:"html"`
+}
+
+// Content returns the HTML of the item, for use in our templates.
+//
+// Note that the content is not sanitized, as is the case with the
+// emails we send.
+func (i Item) Content() template.HTML {
+	return template.HTML(i.HTML)
+}
+
+// River holds our state.
+type River struct {
+
+	// dir is the directory the site is written to.
+	dir string
+
+	// days and feeds contain the pages which need regenerating.
+	days  map[string]bool
+	feeds map[string]bool
+}
+
+// New creates a new River, which writes to the given directory.
+func New(dir string) *River {
+	return &River{dir: dir, days: make(map[string]bool), feeds: make(map[string]bool)}
+}
+
+// hash returns a stable name for the given value.
+func hash(value string) string {
+	sum := sha1.Sum([]byte(value))
+	return hex.EncodeToString(sum[:])
+}
+
+// FeedPage returns the path of the page for the given feed, relative to
+// the top of the site.
+func FeedPage(feed string) string {
+	return "feeds/" + hash(feed) + ".html"
+}
+
+// DayPage returns the path of the page for the given day, relative to
+// the top of the site.
+func DayPage(day string) string {
+	return "days/" + day + ".html"
+}
+
+// Add stores the given item.
+//
+// The pages of the site are not updated until Write is called.
+func (r *River) Add(item Item) error {
+
+	if item.Received.IsZero() {
+		item.Received = time.Now()
+	}
+	day := item.Received.Local().Format(dayFormat)
+
+	dir := filepath.Join(r.dir, "items", day)
+	err := os.MkdirAll(dir, 0755)
+	if err != nil {
+		return err
+	}
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return err
+	}
+
+	name := hash(item.Feed+"\n"+item.Link+"\n"+item.Title) + ".json"
+	err = os.WriteFile(filepath.Join(dir, name), data, 0644)
+	if err != nil {
+		return err
+	}
+
+	r.days[day] = true
+	r.feeds[item.Feed] = true
+	return nil
+}
+
+// load returns all the items we've stored, newest first.
+func (r *River) load() ([]Item, error) {
+
+	var items []Item
+
+	files, err := filepath.Glob(filepath.Join(r.dir, "items", "*", "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, err
+		}
+
+		var item Item
+		err = json.Unmarshal(data, &item)
+		if err != nil {
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Received.After(items[j].Received)
+	})
+	return items, nil
+}
+
+// Write regenerates the pages of the site which contain new items, along
+// with the index.
+func (r *River) Write() error {
+
+	if len(r.days) == 0 && len(r.feeds) == 0 {
+		return nil
+	}
+
+	items, err := r.load()
+	if err != nil {
+		return err
+	}
+
+	byDay := make(map[string][]Item)
+	byFeed := make(map[string][]Item)
+	titles := make(map[string]string)
+
+	for _, item := range items {
+		day := item.Received.Local().Format(dayFormat)
+		byDay[day] = append(byDay[day], item)
+		byFeed[item.Feed] = append(byFeed[item.Feed], item)
+		if titles[item.Feed] == "" {
+			titles[item.Feed] = item.FeedTitle
+		}
+	}
+
+	for day := range r.days {
+		err = r.page(DayPage(day), "Items received on "+day, byDay[day])
+		if err != nil {
+			return err
+		}
+	}
+
+	for feed := range r.feeds {
+		title := titles[feed]
+		if title == "" {
+			title = feed
+		}
+		err = r.page(FeedPage(feed), title, byFeed[feed])
+		if err != nil {
+			return err
+		}
+	}
+
+	r.days = make(map[string]bool)
+	r.feeds = make(map[string]bool)
+
+	return r.index(byDay, byFeed, titles)
+}
+
+// page writes a single page of items.
+func (r *River) page(path string, title string, items []Item) error {
+	return r.render(path, pageTemplate, struct {
+		Title string
+		Items []Item
+	}{Title: title, Items: items})
+}
+
+// index writes the index of the site.
+func (r *River) index(byDay map[string][]Item, byFeed map[string][]Item, titles map[string]string) error {
+
+	type link struct {
+		Name  string
+		Path  string
+		Count int
+	}
+
+	var days []link
+	for day, items := range byDay {
+		days = append(days, link{Name: day, Path: DayPage(day), Count: len(items)})
+	}
+	sort.Slice(days, func(i, j int) bool { return days[i].Name > days[j].Name })
+
+	var feeds []link
+	for feed, items := range byFeed {
+		name := titles[feed]
+		if name == "" {
+			name = feed
+		}
+		feeds = append(feeds, link{Name: name, Path: FeedPage(feed), Count: len(items)})
+	}
+	sort.Slice(feeds, func(i, j int) bool {
+		return strings.ToLower(feeds[i].Name) < strings.ToLower(feeds[j].Name)
+	})
+
+	return r.render("index.html", indexTemplate, struct {
+		Days  []link
+		Feeds []link
+	}{Days: days, Feeds: feeds})
+}
+
+// render writes the given template to the given path, beneath our
+// directory, replacing the previous contents atomically.
+func (r *River) render(path string, tmpl *template.Template, data interface{}) error {
+
+	dest := filepath.Join(r.dir, filepath.FromSlash(path))
+	err := os.MkdirAll(filepath.Dir(dest), 0755)
+	if err != nil {
+		return err
+	}
+
+	tmp, err := os.CreateTemp(filepath.Dir(dest), ".river")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	err = tmpl.Execute(tmp, data)
+	if err != nil {
+		tmp.Close()
+		return err
+	}
+	err = tmp.Close()
+	if err != nil {
+		return err
+	}
+	err = os.Chmod(tmp.Name(), 0644)
+	if err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), dest)
+}
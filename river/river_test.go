@@ -0,0 +1,95 @@
+package river
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRiver(t *testing.T) {
+
+	dir := t.TempDir()
+
+	// Nothing to do
+	r := New(dir)
+	err := r.Write()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	day1 := time.Date(2021, 1, 2, 12, 0, 0, 0, time.Local)
+	day2 := time.Date(2021, 1, 3, 12, 0, 0, 0, time.Local)
+
+	items := []Item{
+		{Feed: "https://example.com/rss", FeedTitle: "Example", Title: "First", Link: "https://example.com/1", HTML: "<p>one</p>", Received: day1},
+		{Feed: "https://example.com/rss", FeedTitle: "Example", Title: "Second", Link: "https://example.com/2", HTML: "<p>two</p>", Received: day2},
+		{Feed: "https://example.net/atom", Title: "Other <b>", Link: "https://example.net/1", Received: day2},
+	}
+	for _, item := range items {
+		err = r.Add(item)
+		if err != nil {
+			t.Fatalf("failed to add item: %s", err)
+		}
+	}
+
+	err = r.Write()
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+
+	read := func(path string) string {
+		data, err := os.ReadFile(filepath.Join(dir, filepath.FromSlash(path)))
+		if err != nil {
+			t.Fatalf("failed to read %s: %s", path, err)
+		}
+		return string(data)
+	}
+
+	tests := []struct {
+		path     string
+		contains []string
+		missing  []string
+	}{
+		{"index.html", []string{DayPage("2021-01-02"), DayPage("2021-01-03"), FeedPage("https://example.com/rss"), "Example</a> (2)", "https://example.net/atom</a> (1)"}, nil},
+		{DayPage("2021-01-02"), []string{"First", "<p>one</p>"}, []string{"Second"}},
+		{DayPage("2021-01-03"), []string{"Second", "Other &lt;b&gt;"}, []string{"First"}},
+		{FeedPage("https://example.com/rss"), []string{"First", "Second"}, []string{"Other"}},
+	}
+
+	for _, test := range tests {
+		content := read(test.path)
+		for _, str := range test.contains {
+			if !strings.Contains(content, str) {
+				t.Errorf("%s didn't contain %q", test.path, str)
+			}
+		}
+		for _, str := range test.missing {
+			if strings.Contains(content, str) {
+				t.Errorf("%s unexpectedly contained %q", test.path, str)
+			}
+		}
+	}
+
+	// The newest item comes first.
+	feed := read(FeedPage("https://example.com/rss"))
+	if strings.Index(feed, "Second") > strings.Index(feed, "First") {
+		t.Errorf("items are not ordered newest first")
+	}
+
+	// A later run preserves the existing items.
+	r = New(dir)
+	err = r.Add(Item{Feed: "https://example.com/rss", Title: "Third", Link: "https://example.com/3", Received: day2})
+	if err != nil {
+		t.Fatalf("failed to add item: %s", err)
+	}
+	err = r.Write()
+	if err != nil {
+		t.Fatalf("failed to write: %s", err)
+	}
+	feed = read(FeedPage("https://example.com/rss"))
+	if !strings.Contains(feed, "First") || !strings.Contains(feed, "Third") {
+		t.Errorf("existing items were lost")
+	}
+}
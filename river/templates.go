@@ -0,0 +1,61 @@
+package river
+
+import "html/template"
+
+// style is the CSS shared by our pages.
+const style = `
+<meta name="viewport" content="width=device-width, initial-scale=1">
+<style>
+body { font-family: sans-serif; line-height: 1.5; max-width: 50em; margin: 0 auto; padding: 1em; color: #222; background: #fff; }
+a { color: #0645ad; }
+article { border-bottom: 1px solid #ddd; padding-bottom: 1em; margin-bottom: 1em; overflow-wrap: break-word; }
+article img { max-width: 100%; height: auto; }
+.meta { color: #666; font-size: 0.9em; }
+@media (prefers-color-scheme: dark) {
+  body { color: #ddd; background: #1e1e1e; }
+  a { color: #8ab4f8; }
+  article { border-color: #444; }
+  .meta { color: #aaa; }
+}
+</style>
+`
+
+// pageTemplate is used for the pages listing items.
+var pageTemplate = template.Must(template.New("page").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>{{.Title}}</title>` + style + `</head>
+<body>
+<p><a href="../index.html">Index</a></p>
+<h1>{{.Title}}</h1>
+{{range .Items}}
+<article>
+<h2><a href="{{.Link}}">{{.Title}}</a></h2>
+<p class="meta">{{if .FeedTitle}}{{.FeedTitle}}{{else}}{{.Feed}}{{end}}{{if not .Published.IsZero}} &middot; {{.Published.Format "2006-01-02 15:04"}}{{end}}</p>
+{{.Content}}
+</article>
+{{end}}
+</body>
+</html>
+`))
+
+// indexTemplate is used for the index of the site.
+var indexTemplate = template.Must(template.New("index").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>rss2email archive</title>` + style + `</head>
+<body>
+<h1>rss2email archive</h1>
+<h2>Days</h2>
+<ul>
+{{range .Days}}<li><a href="{{.Path}}">{{.Name}}</a> ({{.Count}})</li>
+{{end}}</ul>
+<h2>Feeds</h2>
+<ul>
+{{range .Feeds}}<li><a href="{{.Path}}">{{.Name}}</a> ({{.Count}})</li>
+{{end}}</ul>
+</body>
+</html>
+`))
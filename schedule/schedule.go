@@ -0,0 +1,227 @@
+// Package schedule parses cron expressions, which may be used to control
+// when individual feeds are checked.
+//
+// Expressions have the usual five fields, separated by whitespace:
+//
+//	minute hour day-of-month month day-of-week
+//
+// Each field may be "*", a number, a range ("1-5"), a list ("1,3,5"),
+// or any of those with a step ("*/15", "8-18/2").  Months and days of
+// the week may be given by name ("JAN", "MON-FRI"), and Sunday is either
+// 0 or 7.  As with cron if both the day-of-month and day-of-week fields
+// are restricted then a time matches if either matches.
+//
+// The macros "@hourly", "@daily", "@weekly", "@monthly", and "@yearly"
+// are also supported.
+package schedule
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// macros holds the expressions which our macros expand to.
+var macros = map[string]string{
+	"@hourly":   "0 * * * *",
+	"@daily":    "0 0 * * *",
+	"@midnight": "0 0 * * *",
+	"@weekly":   "0 0 * * 0",
+	"@monthly":  "0 0 1 * *",
+	"@yearly":   "0 0 1 1 *",
+	"@annually": "0 0 1 1 *",
+}
+
+// field describes one of the fields of an expression.
+type field struct {
+	name  string
+	min   int
+	max   int
+	names []string
+}
+
+// fields holds the details of each field, in order.
+var fields = []field{
+	{name: "minute", min: 0, max: 59},
+	{name: "hour", min: 0, max: 23},
+	{name: "day-of-month", min: 1, max: 31},
+	{name: "month", min: 1, max: 12, names: []string{"", "JAN", "FEB", "MAR", "APR", "MAY", "JUN", "JUL", "AUG", "SEP", "OCT", "NOV", "DEC"}},
+	{name: "day-of-week", min: 0, max: 7, names: []string{"SUN", "MON", "TUE", "WED", "THU", "FRI", "SAT"}},
+}
+
+// Schedule is a parsed cron expression.
+type Schedule struct {
+
+	// The values which match, for each field.
+	minute, hour, dom, month, dow map[int]bool
+
+	// Were the day fields restricted?
+	domStar, dowStar bool
+}
+
+// Parse parses the given cron expression.
+func Parse(expr string) (*Schedule, error) {
+
+	spec := strings.TrimSpace(expr)
+	if m, ok := macros[strings.ToLower(spec)]; ok {
+		spec = m
+	}
+
+	parts := strings.Fields(spec)
+	if len(parts) != len(fields) {
+		return nil, fmt.Errorf("invalid cron expression '%s', expected %d fields", expr, len(fields))
+	}
+
+	sets := make([]map[int]bool, len(fields))
+	for i, part := range parts {
+		set, err := parseField(part, fields[i])
+		if err != nil {
+			return nil, fmt.Errorf("invalid cron expression '%s': %s", expr, err)
+		}
+		sets[i] = set
+	}
+
+	// Sunday may be given as 7.
+	if sets[4][7] {
+		sets[4][0] = true
+	}
+
+	return &Schedule{
+		minute:  sets[0],
+		hour:    sets[1],
+		dom:     sets[2],
+		month:   sets[3],
+		dow:     sets[4],
+		domStar: parts[2] == "*",
+		dowStar: parts[4] == "*",
+	}, nil
+}
+
+// parseValue parses a single value of the given field, which may be a name.
+func parseValue(s string, f field) (int, error) {
+
+	for i, name := range f.names {
+		if name != "" && strings.EqualFold(s, name) {
+			return i, nil
+		}
+	}
+
+	n, err := strconv.Atoi(s)
+	if err != nil || n < f.min || n > f.max {
+		return 0, fmt.Errorf("invalid %s '%s'", f.name, s)
+	}
+	return n, nil
+}
+
+// parseField parses a single field, returning the values which match.
+func parseField(s string, f field) (map[int]bool, error) {
+
+	set := make(map[int]bool)
+
+	for _, item := range strings.Split(s, ",") {
+
+		// Look for a step.
+		step := 1
+		if i := strings.Index(item, "/"); i >= 0 {
+			n, err := strconv.Atoi(item[i+1:])
+			if err != nil || n < 1 {
+				return nil, fmt.Errorf("invalid step in %s '%s'", f.name, item)
+			}
+			step = n
+			item = item[:i]
+		}
+
+		// Find the range.
+		lo, hi := f.min, f.max
+		switch {
+		case item == "*":
+		case strings.Contains(item, "-"):
+			bounds := strings.SplitN(item, "-", 2)
+			var err error
+			lo, err = parseValue(bounds[0], f)
+			if err != nil {
+				return nil, err
+			}
+			hi, err = parseValue(bounds[1], f)
+			if err != nil {
+				return nil, err
+			}
+			if lo > hi {
+				return nil, fmt.Errorf("invalid range in %s '%s'", f.name, item)
+			}
+		default:
+			n, err := parseValue(item, f)
+			if err != nil {
+				return nil, err
+			}
+			lo = n
+
+			// "5/10" means from five onwards.
+			if step == 1 {
+				hi = n
+			}
+		}
+
+		for v := lo; v <= hi; v += step {
+			set[v] = true
+		}
+	}
+	return set, nil
+}
+
+// dayMatches returns true if the given day matches our day fields.
+func (s *Schedule) dayMatches(t time.Time) bool {
+
+	dom := s.dom[t.Day()]
+	dow := s.dow[int(t.Weekday())]
+
+	switch {
+	case s.domStar && s.dowStar:
+		return true
+	case s.domStar:
+		return dow
+	case s.dowStar:
+		return dom
+	default:
+		return dom || dow
+	}
+}
+
+// Next returns the first time which matches the schedule after the given
+// time, or the zero time if there is none within the next five years.
+func (s *Schedule) Next(t time.Time) time.Time {
+
+	t = t.Truncate(time.Minute).Add(time.Minute)
+	end := t.AddDate(5, 0, 0)
+
+	for t.Before(end) {
+
+		if !s.month[int(t.Month())] {
+			t = time.Date(t.Year(), t.Month()+1, 1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.dayMatches(t) {
+			t = time.Date(t.Year(), t.Month(), t.Day()+1, 0, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.hour[t.Hour()] {
+			t = time.Date(t.Year(), t.Month(), t.Day(), t.Hour()+1, 0, 0, 0, t.Location())
+			continue
+		}
+		if !s.minute[t.Minute()] {
+			t = t.Add(time.Minute)
+			continue
+		}
+		return t
+	}
+	return time.Time{}
+}
+
+// Due returns true if the schedule matched at any time after since, up
+// to and including now.
+func (s *Schedule) Due(since time.Time, now time.Time) bool {
+
+	next := s.Next(since)
+	return !next.IsZero() && !next.After(now)
+}
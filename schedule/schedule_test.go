@@ -0,0 +1,94 @@
+package schedule
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseErrors(t *testing.T) {
+
+	for _, expr := range []string{
+		"",
+		"* * * *",
+		"60 * * * *",
+		"* 24 * * *",
+		"* * 0 * *",
+		"* * * 13 *",
+		"* * * * 8",
+		"*/0 * * * *",
+		"5-1 * * * *",
+		"* * * FOO *",
+		"@never",
+	} {
+		_, err := Parse(expr)
+		if err == nil {
+			t.Errorf("expected error parsing '%s'", expr)
+		}
+	}
+}
+
+func TestNext(t *testing.T) {
+
+	// Monday 1st January 2024, at 09:30.
+	start := time.Date(2024, 1, 1, 9, 30, 0, 0, time.UTC)
+
+	tests := []struct {
+		expr string
+		next string
+	}{
+		{"* * * * *", "2024-01-01 09:31"},
+		{"*/15 * * * *", "2024-01-01 09:45"},
+		{"0 8 * * MON-FRI", "2024-01-02 08:00"},
+		{"0 8 * * sat,sun", "2024-01-06 08:00"},
+		{"0 18 * * 7", "2024-01-07 18:00"},
+		{"30 9 * * 1", "2024-01-08 09:30"},
+		{"0 0 29 2 *", "2024-02-29 00:00"},
+		{"0 12 15 * MON", "2024-01-01 12:00"},
+		{"5/20 10 * * *", "2024-01-01 10:05"},
+		{"0 8-18/4 * * *", "2024-01-01 12:00"},
+		{"@daily", "2024-01-02 00:00"},
+		{"@monthly", "2024-02-01 00:00"},
+		{"0 0 1 JAN *", "2025-01-01 00:00"},
+	}
+
+	for _, test := range tests {
+		s, err := Parse(test.expr)
+		if err != nil {
+			t.Fatalf("failed to parse '%s': %s", test.expr, err)
+		}
+		next := s.Next(start).Format("2006-01-02 15:04")
+		if next != test.next {
+			t.Errorf("%s: expected %s, got %s", test.expr, test.next, next)
+		}
+	}
+
+	// Impossible dates never happen.
+	s, _ := Parse("0 0 31 2 *")
+	if !s.Next(start).IsZero() {
+		t.Errorf("found a 31st of February")
+	}
+}
+
+func TestDue(t *testing.T) {
+
+	s, err := Parse("0 8 * * MON-FRI")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	monday := time.Date(2024, 1, 1, 7, 50, 0, 0, time.UTC)
+	if s.Due(monday, monday.Add(5*time.Minute)) {
+		t.Errorf("due before 8am")
+	}
+	if !s.Due(monday, monday.Add(15*time.Minute)) {
+		t.Errorf("not due at 8am")
+	}
+	if s.Due(monday.Add(15*time.Minute), monday.Add(30*time.Minute)) {
+		t.Errorf("due twice")
+	}
+
+	saturday := time.Date(2024, 1, 6, 7, 50, 0, 0, time.UTC)
+	if s.Due(saturday, saturday.Add(time.Hour)) {
+		t.Errorf("due at the weekend")
+	}
+}
@@ -0,0 +1,106 @@
+//
+// Search the archive of delivered items.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/skx/rss2email/archive"
+)
+
+// Structure for our options and state.
+type searchCmd struct {
+
+	// The path to the archive, used for testing
+	path string
+
+	// The maximum number of results to show.
+	limit int
+
+	// Should we show the text of each result?
+	verbose bool
+}
+
+// Arguments handles our flag-setup.
+func (s *searchCmd) Arguments(f *flag.FlagSet) {
+	s.path = archive.Path()
+
+	f.IntVar(&s.limit, "limit", 20, "The maximum number of results to show, zero for no limit.")
+	f.BoolVar(&s.verbose, "verbose", false, "Show the text of each result?")
+}
+
+// Info is part of the subcommand-API.
+func (s *searchCmd) Info() (string, string) {
+	return "search", `Search the archive of processed items.
+
+If the 'cron', or 'daemon', sub-commands are given the '-archive' flag
+then each item which is emailed is stored within a SQLite database at
+'~/.rss2email/archive.db', along with the email which was generated and
+the status of its delivery.
+
+This sub-command searches that archive, showing those items whose title,
+link, or text contains the given term.  The newest items are shown first,
+each with the ID which may be given to the 'resend' sub-command.
+
+Example:
+
+    $ rss2email search golang
+    $ rss2email search -verbose -limit 5 "release notes"
+`
+}
+
+// Execute is invoked if the user specifies `search` as the subcommand.
+func (s *searchCmd) Execute(args []string) int {
+
+	if len(args) == 0 {
+		fmt.Printf("Usage: rss2email search [flags] term\n")
+		return 1
+	}
+
+	// Don't create an empty archive by searching.
+	if _, err := os.Stat(s.path); err != nil {
+		fmt.Printf("failed to open archive %s: %s\n", s.path, err.Error())
+		return 1
+	}
+
+	db, err := archive.Open(s.path)
+	if err != nil {
+		fmt.Printf("failed to open archive %s: %s\n", s.path, err.Error())
+		return 1
+	}
+	defer db.Close()
+
+	entries, err := db.Search(strings.Join(args, " "), s.limit)
+	if err != nil {
+		fmt.Printf("failed to search archive: %s\n", err.Error())
+		return 1
+	}
+
+	for _, entry := range entries {
+
+		feed := entry.FeedTitle
+		if feed == "" {
+			feed = entry.Feed
+		}
+
+		fmt.Fprintf(out, "%d %s %s: %s\n", entry.ID, entry.Archived.Local().Format("2006-01-02"), feed, entry.Title)
+		fmt.Fprintf(out, "\t%s [%s]\n", entry.Link, entry.Status)
+		if entry.Error != "" {
+			fmt.Fprintf(out, "\t%s\n", entry.Error)
+		}
+
+		if s.verbose {
+			for _, line := range strings.Split(strings.TrimSpace(entry.Text), "\n") {
+				fmt.Fprintf(out, "\t\t%s\n", line)
+			}
+			fmt.Fprintf(out, "\n")
+		}
+	}
+
+	return 0
+}
@@ -0,0 +1,52 @@
+package main
+
+import (
+	"bytes"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/archive"
+)
+
+func TestSearch(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	path := filepath.Join(t.TempDir(), "archive.db")
+
+	// A missing archive is an error
+	s := searchCmd{path: path}
+	if s.Execute([]string{"foo"}) != 1 {
+		t.Fatalf("expected error with missing archive")
+	}
+
+	db, err := archive.Open(path)
+	if err != nil {
+		t.Fatalf("failed to create archive: %s", err)
+	}
+	err = db.Add(archive.Entry{Feed: "https://example.com/rss", Title: "Hello World", Link: "https://example.com/1", Text: "Some text", Status: archive.StatusSent})
+	if err != nil {
+		t.Fatalf("failed to add entry: %s", err)
+	}
+	db.Close()
+
+	// No term is an error
+	if s.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with no search term")
+	}
+
+	s.verbose = true
+	if s.Execute([]string{"hello"}) != 0 {
+		t.Fatalf("unexpected error searching")
+	}
+
+	output := out.(*bytes.Buffer).String()
+	for _, expected := range []string{"https://example.com/rss: Hello World", "https://example.com/1 [sent]", "Some text"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output didn't contain %q: %s", expected, output)
+		}
+	}
+}
@@ -0,0 +1,124 @@
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strings"
+)
+
+// These are the commands we use to decrypt, and encrypt, files, which
+// may be replaced for testing.
+var (
+	ageCommand = "age"
+	gpgCommand = "gpg"
+)
+
+// Encrypted returns true if the given file is encrypted, according to
+// its suffix, which is ".age" for files encrypted with age, and ".gpg" or
+// ".asc" for those encrypted with gpg.
+func Encrypted(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".age", ".gpg", ".asc":
+		return true
+	}
+	return false
+}
+
+// AgeIdentity returns the path of the identity file used to decrypt
+// files encrypted with age.
+//
+// This is taken from $RSS2EMAIL_AGE_IDENTITY, defaulting to "age/keys.txt"
+// within the user's configuration directory, so it is kept apart from
+// the configuration it decrypts.
+func AgeIdentity() string {
+
+	if path := os.Getenv("RSS2EMAIL_AGE_IDENTITY"); path != "" {
+		return path
+	}
+	dir, err := os.UserConfigDir()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(dir, "age", "keys.txt")
+}
+
+// Decrypt returns the contents of the given encrypted file.
+//
+// Files encrypted with age are decrypted using our identity file, and
+// those encrypted with gpg via its agent, which may prompt for the
+// passphrase of the key.
+func Decrypt(path string) ([]byte, error) {
+	return decrypt(strings.EqualFold(filepath.Ext(path), ".age"), path)
+}
+
+// decrypt returns the contents of the given file, which is encrypted
+// with age, or gpg.
+func decrypt(age bool, path string) ([]byte, error) {
+
+	var cmd *exec.Cmd
+	if age {
+		cmd = exec.Command(ageCommand, "--decrypt", "-i", AgeIdentity(), path)
+	} else {
+		cmd = exec.Command(gpgCommand, "--decrypt", "--batch", "--quiet", path)
+	}
+
+	out, err := crypt(cmd, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt %s: %s", path, err)
+	}
+	return out, nil
+}
+
+// Encrypt returns the given data encrypted for storage in the given file,
+// by the same means as Decrypt would use to read it.
+//
+// With age the data is encrypted to the recipients of our identity file,
+// and with gpg to the default key.
+func Encrypt(path string, data []byte) ([]byte, error) {
+
+	var cmd *exec.Cmd
+	if strings.EqualFold(filepath.Ext(path), ".age") {
+		cmd = exec.Command(ageCommand, "--encrypt", "-i", AgeIdentity())
+	} else {
+		cmd = exec.Command(gpgCommand, "--encrypt", "--batch", "--quiet", "--default-recipient-self")
+		if strings.EqualFold(filepath.Ext(path), ".asc") {
+			cmd.Args = append(cmd.Args, "--armor")
+		}
+	}
+
+	out, err := crypt(cmd, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to encrypt %s: %s", path, err)
+	}
+	return out, nil
+}
+
+// crypt runs the given command, with the given input, returning its
+// output.
+func crypt(cmd *exec.Cmd, input []byte) ([]byte, error) {
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// Without input the command shares our terminal, if we have one,
+	// so pinentry may prompt for a passphrase.
+	if input != nil {
+		cmd.Stdin = bytes.NewReader(input)
+	} else {
+		cmd.Stdin = os.Stdin
+	}
+
+	err := cmd.Run()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("%s: %s", err, msg)
+		}
+		return nil, err
+	}
+	return stdout.Bytes(), nil
+}
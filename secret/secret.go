@@ -0,0 +1,138 @@
+// Package secret resolves references to secrets, so that credentials
+// needn't be written in the configuration file, or the environment, and
+// so the configuration may be committed safely.
+//
+// A reference is a value with one of these prefixes:
+//
+//	env:SMTP_PASS                  the named environmental variable
+//	file:/run/secrets/smtp         the contents of the file
+//	cmd:pass show rss2email/smtp   the output of the command
+//	age:/path/to/smtp.age          the file, decrypted with age
+//	gpg:/path/to/smtp.gpg          the file, decrypted with gpg
+//
+// Other values are used as they are.
+package secret
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"sync"
+)
+
+// commands holds the output of the commands we've run, and the files
+// we've decrypted, as they may be slow, or prompt for a passphrase.
+var commands = struct {
+	mutex  sync.Mutex
+	output map[string]string
+}{output: make(map[string]string)}
+
+// Resolve returns the secret the given value refers to, or the value
+// itself if it isn't a reference.
+//
+// Trailing newlines are removed from the contents of files, and the
+// output of commands.  Each command is run once, and its output reused,
+// as is the contents of each encrypted file.
+func Resolve(value string) (string, error) {
+
+	switch {
+	case strings.HasPrefix(value, "env:"):
+		name := strings.TrimPrefix(value, "env:")
+		val, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret %s: the environmental variable %s is not set", value, name)
+		}
+		return val, nil
+
+	case strings.HasPrefix(value, "file:"):
+		data, err := os.ReadFile(strings.TrimPrefix(value, "file:"))
+		if err != nil {
+			return "", fmt.Errorf("secret %s: %s", value, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), nil
+
+	case strings.HasPrefix(value, "cmd:"):
+		return run(strings.TrimSpace(strings.TrimPrefix(value, "cmd:")))
+
+	case strings.HasPrefix(value, "age:"), strings.HasPrefix(value, "gpg:"):
+		return decryptFile(value)
+	}
+
+	return value, nil
+}
+
+// Getenv returns the value of the named environmental variable, resolving
+// it if it is a reference to a secret.
+func Getenv(name string) (string, error) {
+
+	val, err := Resolve(os.Getenv(name))
+	if err != nil {
+		return "", fmt.Errorf("failed to read %s: %s", name, err)
+	}
+	return val, nil
+}
+
+// run returns the output of the given command, which is run via the
+// shell.
+func run(command string) (string, error) {
+
+	commands.mutex.Lock()
+	defer commands.mutex.Unlock()
+
+	if out, ok := commands.output[command]; ok {
+		return out, nil
+	}
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", command)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+
+	// The command may prompt for a passphrase, so it shares our
+	// terminal, if we have one.
+	cmd.Stdin = os.Stdin
+
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return "", fmt.Errorf("secret command '%s' failed: %s: %s", command, err, msg)
+		}
+		return "", fmt.Errorf("secret command '%s' failed: %s", command, err)
+	}
+
+	out := strings.TrimRight(stdout.String(), "\r\n")
+	commands.output[command] = out
+	return out, nil
+}
+
+// decryptFile returns the contents of the encrypted file the given
+// reference names.
+func decryptFile(value string) (string, error) {
+
+	commands.mutex.Lock()
+	defer commands.mutex.Unlock()
+
+	if out, ok := commands.output[value]; ok {
+		return out, nil
+	}
+
+	data, err := decrypt(strings.HasPrefix(value, "age:"), value[4:])
+	if err != nil {
+		return "", fmt.Errorf("secret %s: %s", value, err)
+	}
+
+	out := strings.TrimRight(string(data), "\r\n")
+	commands.output[value] = out
+	return out, nil
+}
@@ -0,0 +1,108 @@
+package secret
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+)
+
+func TestResolve(t *testing.T) {
+
+	// Plain values are unchanged.
+	val, err := Resolve("secret!value")
+	if err != nil || val != "secret!value" {
+		t.Fatalf("unexpected value %q: %v", val, err)
+	}
+
+	os.Setenv("RSS2EMAIL_SECRET_TEST", "from-env")
+	defer os.Unsetenv("RSS2EMAIL_SECRET_TEST")
+
+	val, err = Resolve("env:RSS2EMAIL_SECRET_TEST")
+	if err != nil || val != "from-env" {
+		t.Fatalf("unexpected value %q: %v", val, err)
+	}
+	_, err = Resolve("env:RSS2EMAIL_SECRET_MISSING")
+	if err == nil || !strings.Contains(err.Error(), "is not set") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	path := filepath.Join(t.TempDir(), "secret")
+	os.WriteFile(path, []byte("from-file\n"), 0600)
+
+	val, err = Resolve("file:" + path)
+	if err != nil || val != "from-file" {
+		t.Fatalf("unexpected value %q: %v", val, err)
+	}
+	_, err = Resolve("file:" + path + ".missing")
+	if err == nil {
+		t.Fatalf("expected error with missing file")
+	}
+
+	// Getenv resolves the value of the variable.
+	os.Setenv("RSS2EMAIL_SECRET_TEST", "file:"+path)
+	val, err = Getenv("RSS2EMAIL_SECRET_TEST")
+	if err != nil || val != "from-file" {
+		t.Fatalf("unexpected value %q: %v", val, err)
+	}
+}
+
+func TestCommand(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the test commands require a POSIX shell")
+	}
+
+	// Each command is only run once.
+	count := filepath.Join(t.TempDir(), "count")
+	command := "cmd: echo run >> " + count + "; echo from-cmd"
+
+	for i := 0; i < 2; i++ {
+		val, err := Resolve(command)
+		if err != nil || val != "from-cmd" {
+			t.Fatalf("unexpected value %q: %v", val, err)
+		}
+	}
+	data, _ := os.ReadFile(count)
+	if string(data) != "run\n" {
+		t.Fatalf("command was run more than once: %q", data)
+	}
+
+	_, err := Resolve("cmd: echo oops >&2; exit 3")
+	if err == nil || !strings.Contains(err.Error(), "exit status 3: oops") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
+
+func TestEncrypted(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("the fake gpg command requires a POSIX shell")
+	}
+
+	// Our fake gpg "decrypts" by removing a header.
+	dir := t.TempDir()
+	gpgCommand = filepath.Join(dir, "gpg")
+	defer func() { gpgCommand = "gpg" }()
+
+	script := "#!/bin/sh\ntail -n +2 \"$4\"\n"
+	os.WriteFile(gpgCommand, []byte(script), 0755)
+
+	path := filepath.Join(dir, "smtp.gpg")
+	os.WriteFile(path, []byte("-- fake gpg\nfrom-gpg\n"), 0600)
+
+	if !Encrypted(path) || Encrypted(filepath.Join(dir, "smtp.txt")) {
+		t.Fatalf("encrypted files misidentified")
+	}
+
+	val, err := Resolve("gpg:" + path)
+	if err != nil || val != "from-gpg" {
+		t.Fatalf("unexpected value %q: %v", val, err)
+	}
+
+	_, err = Resolve("gpg:" + path + ".missing")
+	if err == nil || !strings.Contains(err.Error(), "failed to decrypt") {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
@@ -0,0 +1,84 @@
+//
+// Handle signals, to allow a graceful shutdown.
+//
+
+package main
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"syscall"
+	"time"
+
+	"github.com/skx/rss2email/tracing"
+)
+
+// shutdownGrace is how long the work which is in-flight, when we're
+// asked to stop, may take to complete.
+var shutdownGrace = 30 * time.Second
+
+// signalContext returns a context which is cancelled when we receive
+// SIGINT or SIGTERM, along with a function to call once we're finished.
+//
+// Once a signal has been received the work in-flight has shutdownGrace
+// to complete, after which we exit.  A second signal causes us to exit
+// immediately.
+func signalContext() (context.Context, func()) {
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	signals := make(chan os.Signal, 1)
+	signal.Notify(signals, os.Interrupt, syscall.SIGTERM)
+
+	done := make(chan struct{})
+
+	go func() {
+		select {
+		case <-done:
+			return
+		case sig := <-signals:
+
+			// Restore the default handling, so that a
+			// second signal terminates us.
+			signal.Stop(signals)
+			cancel()
+
+			fmt.Fprintf(os.Stderr, "Received %s, finishing the work in progress (interrupt again to exit immediately)\n", sig)
+
+			select {
+			case <-done:
+			case <-time.After(shutdownGrace):
+				fmt.Fprintf(os.Stderr, "Work in progress didn't complete within %s, exiting\n", shutdownGrace)
+				os.Exit(1)
+			}
+		}
+	}()
+
+	return ctx, func() {
+		signal.Stop(signals)
+		close(done)
+		cancel()
+	}
+}
+
+// setupTracing configures the exporting of traces, if enabled, returning
+// the function which must be called to flush them before we exit.
+func setupTracing() (func(), error) {
+
+	shutdown, err := tracing.Setup(context.Background(), version)
+	if err != nil {
+		return nil, fmt.Errorf("failed to setup tracing: %s", err)
+	}
+
+	return func() {
+		ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+		defer cancel()
+
+		err := shutdown(ctx)
+		if err != nil {
+			fmt.Fprintf(os.Stderr, "failed to export traces: %s\n", err)
+		}
+	}, nil
+}
@@ -0,0 +1,37 @@
+package main
+
+import (
+	"os"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestSignalContext(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("signals cannot be sent to ourselves upon Windows")
+	}
+
+	ctx, done := signalContext()
+	defer done()
+
+	if ctx.Err() != nil {
+		t.Fatalf("context cancelled before a signal was received")
+	}
+
+	p, err := os.FindProcess(os.Getpid())
+	if err != nil {
+		t.Fatalf("failed to find our process: %s", err)
+	}
+	err = p.Signal(os.Interrupt)
+	if err != nil {
+		t.Fatalf("failed to send signal: %s", err)
+	}
+
+	select {
+	case <-ctx.Done():
+	case <-time.After(5 * time.Second):
+		t.Fatalf("context wasn't cancelled by the signal")
+	}
+}
@@ -0,0 +1,158 @@
+// Package summarise generates summaries of feed items, which are placed at
+// the top of their emails, so that long items may be skimmed.
+//
+// Summaries are generated by one of two backends, which is chosen via the
+// environment:
+//
+//	SUMMARISE_COMMAND   a command which summarises its STDIN to STDOUT
+//	SUMMARISE_URL       an API endpoint, to which items are POSTed
+//
+// The first which is set is used.
+package summarise
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/secret"
+)
+
+// Item is the item to be summarised.
+type Item struct {
+	Title string `json:"title"`
+	Link  string `json:"link"`
+
+	// Text holds the content of the item, as text.
+	Text string `json:"text"`
+}
+
+// Summariser summarises items.
+type Summariser interface {
+
+	// Summarise returns a summary of the given item, as text.
+	Summarise(item Item) (string, error)
+}
+
+// New returns the summariser configured via the environment, or an error
+// if there is none.
+func New() (Summariser, error) {
+
+	if command := os.Getenv("SUMMARISE_COMMAND"); command != "" {
+		return &Command{command: command}, nil
+	}
+
+	if url := os.Getenv("SUMMARISE_URL"); url != "" {
+		key, err := secret.Getenv("SUMMARISE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return &API{url: url, key: key, client: &http.Client{Timeout: 120 * time.Second}}, nil
+	}
+
+	return nil, fmt.Errorf("no summariser is configured, set $SUMMARISE_COMMAND, or $SUMMARISE_URL")
+}
+
+// API summarises items via an HTTP endpoint.
+//
+// The item is POSTed as JSON, with "title", "link", and "text" fields.
+// The response may be JSON, with a "summary" field, or the summary as
+// text.
+type API struct {
+
+	// url is the address of the endpoint, and key the bearer token
+	// to send to it, if any.
+	url string
+	key string
+
+	// client is the HTTP client we use.
+	client *http.Client
+}
+
+// Summarise is part of the Summariser interface.
+func (a *API) Summarise(item Item) (string, error) {
+
+	data, err := json.Marshal(item)
+	if err != nil {
+		return "", err
+	}
+
+	req, err := http.NewRequest("POST", a.url, bytes.NewReader(data))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rss2email (https://github.com/skx/rss2email)")
+	if a.key != "" {
+		req.Header.Set("Authorization", "Bearer "+a.key)
+	}
+
+	resp, err := a.client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return "", err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("summarising returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var res struct {
+			Summary string `json:"summary"`
+		}
+		err = json.Unmarshal(data, &res)
+		if err != nil {
+			return "", err
+		}
+		data = []byte(res.Summary)
+	}
+	return strings.TrimSpace(string(data)), nil
+}
+
+// Command summarises items via an external command, which is run via the
+// shell, with the text of the item upon STDIN, and its title, and link,
+// in $RSS2EMAIL_TITLE and $RSS2EMAIL_LINK.  The summary is read from
+// STDOUT.
+type Command struct {
+	command string
+}
+
+// Summarise is part of the Summariser interface.
+func (c *Command) Summarise(item Item) (string, error) {
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", c.command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", c.command)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdin = strings.NewReader(item.Text)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), "RSS2EMAIL_TITLE="+item.Title, "RSS2EMAIL_LINK="+item.Link)
+
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return "", fmt.Errorf("command '%s' failed: %s: %s", c.command, err, msg)
+		}
+		return "", fmt.Errorf("command '%s' failed: %s", c.command, err)
+	}
+	return strings.TrimSpace(stdout.String()), nil
+}
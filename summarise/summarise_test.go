@@ -0,0 +1,92 @@
+package summarise
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// TestNew ensures the summariser is chosen via the environment.
+func TestNew(t *testing.T) {
+
+	for _, name := range []string{"SUMMARISE_COMMAND", "SUMMARISE_URL"} {
+		os.Unsetenv(name)
+		defer os.Unsetenv(name)
+	}
+
+	if _, err := New(); err == nil {
+		t.Fatalf("expected an error without a summariser")
+	}
+
+	os.Setenv("SUMMARISE_URL", "https://example.com/summarise")
+	if s, err := New(); err != nil || s.(*API).url != "https://example.com/summarise" {
+		t.Fatalf("expected an API: %v %v", s, err)
+	}
+
+	os.Setenv("SUMMARISE_COMMAND", "cat")
+	if s, err := New(); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	} else if _, ok := s.(*Command); !ok {
+		t.Fatalf("expected a command")
+	}
+}
+
+// TestAPI summarises via a fake endpoint.
+func TestAPI(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var item Item
+		json.NewDecoder(r.Body).Decode(&item)
+		switch {
+		case r.Header.Get("Authorization") != "Bearer key":
+			w.WriteHeader(http.StatusUnauthorized)
+		case item.Title == "JSON":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"summary":"In short: ` + item.Text + `"}`))
+		default:
+			w.Header().Set("Content-Type", "text/plain")
+			w.Write([]byte("\nBriefly: " + item.Text + "\n"))
+		}
+	}))
+	defer ts.Close()
+
+	a := &API{url: ts.URL, key: "key", client: http.DefaultClient}
+
+	out, err := a.Summarise(Item{Title: "JSON", Text: "words"})
+	if err != nil || out != "In short: words" {
+		t.Fatalf("unexpected summary: %s %v", out, err)
+	}
+	out, err = a.Summarise(Item{Title: "Text", Text: "words"})
+	if err != nil || out != "Briefly: words" {
+		t.Fatalf("unexpected summary: %s %v", out, err)
+	}
+
+	a.key = "wrong"
+	_, err = a.Summarise(Item{Title: "Text", Text: "words"})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+// TestCommand summarises via an external command.
+func TestCommand(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+
+	c := &Command{command: `echo "$RSS2EMAIL_TITLE: $(wc -w)"`}
+	out, err := c.Summarise(Item{Title: "Essay", Text: "one two three"})
+	if err != nil || out != "Essay: 3" {
+		t.Fatalf("unexpected summary: %s %v", out, err)
+	}
+
+	c = &Command{command: "exit 3"}
+	_, err = c.Summarise(Item{Title: "Essay"})
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
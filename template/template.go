@@ -12,7 +12,16 @@ import (
 //go:embed template.txt
 var message string
 
+//go:embed styled.txt
+var styled string
+
 // EmailTemplate returns the embedded email template.
 func EmailTemplate() []byte {
 	return []byte(message)
 }
+
+// StyledTemplate returns the embedded styled email template, which wraps
+// the HTML part of the email in a layout with embedded CSS.
+func StyledTemplate() []byte {
+	return []byte(styled)
+}
@@ -1,10 +1,20 @@
 package template
 
-import "testing"
+import (
+	"strings"
+	"testing"
+)
 
 func TestTemplate(t *testing.T) {
 	content := EmailTemplate()
-	if len(content) != 2241 {
-		t.Fatalf("unexpected template size 2241 != %d", len(content))
+	if len(content) != 4558 {
+		t.Fatalf("unexpected template size 4558 != %d", len(content))
+	}
+}
+
+func TestStyledTemplate(t *testing.T) {
+	content := string(StyledTemplate())
+	if !strings.Contains(content, "prefers-color-scheme: dark") {
+		t.Fatalf("styled template is missing dark-mode support")
 	}
 }
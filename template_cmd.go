@@ -0,0 +1,156 @@
+//
+// Check email templates for problems.
+//
+
+package main
+
+import (
+	"flag"
+	"fmt"
+	"os"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/template"
+)
+
+// Structure for our options and state.
+type templateCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (t *templateCmd) Arguments(flags *flag.FlagSet) {
+	t.config = configfile.New()
+}
+
+// Info is part of the subcommand-API.
+func (t *templateCmd) Info() (string, string) {
+	return "template", `Check email templates for problems.
+
+A mistake within a custom template will break the delivery of every item
+which uses it, perhaps without being noticed.  This sub-command renders
+the given templates against a sample item, and verifies the email which
+is generated, reporting:
+
+  * Templates which fail to parse, or to execute.
+  * References to fields which don't exist.
+  * Missing, or invalid, From:, To:, and Subject: headers.
+  * Broken MIME structure, such as missing, or unterminated, boundaries.
+  * Parts which aren't correctly encoded.
+  * Emails which don't include the link to the item, or its content.
+
+If no template is given then '~/.rss2email/email.tmpl' is checked, along
+with any templates given to feeds via the 'template' option.  If there
+are no custom templates the embedded one is checked.
+
+Example:
+
+    $ rss2email template check
+    $ rss2email template check ~/my-template.tmpl
+`
+}
+
+// check verifies the given template, using the given per-feed options,
+// and reports any problems, returning true if there were none.
+func (t *templateCmd) check(name string, content []byte, opts []configfile.Option) bool {
+
+	errs := emailer.CheckTemplate(content, opts)
+	if len(errs) == 0 {
+		fmt.Fprintf(out, "%s: OK\n", name)
+		return true
+	}
+
+	for _, err := range errs {
+		fmt.Fprintf(out, "%s: %s\n", name, err.Error())
+	}
+	return false
+}
+
+// checkFile verifies the template in the given file.
+func (t *templateCmd) checkFile(path string, opts []configfile.Option) bool {
+
+	content, err := os.ReadFile(path)
+	if err != nil {
+		fmt.Fprintf(out, "%s: failed to read template: %s\n", path, err.Error())
+		return false
+	}
+	return t.check(path, content, opts)
+}
+
+// Execute is invoked if the user specifies `template` as the subcommand.
+func (t *templateCmd) Execute(args []string) int {
+
+	if len(args) < 1 || args[0] != "check" {
+		fmt.Printf("Usage: rss2email template check [file1 file2 .. fileN]\n")
+		return 1
+	}
+	args = args[1:]
+
+	ok := true
+
+	// Check the files we were given.
+	if len(args) > 0 {
+		for _, path := range args {
+			if !t.checkFile(path, nil) {
+				ok = false
+			}
+		}
+		if !ok {
+			return 1
+		}
+		return 0
+	}
+
+	// Otherwise check the global template, and those of each feed.
+	checked := 0
+	if path := emailer.TemplatePath(""); exists(path) {
+		if !t.checkFile(path, nil) {
+			ok = false
+		}
+		checked++
+	}
+
+	if t.config.Exists() {
+		entries, err := t.config.Parse()
+		if err != nil {
+			fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+			return 1
+		}
+
+		seen := make(map[string]bool)
+		for _, entry := range entries {
+			for _, opt := range entry.Options {
+				if opt.Name != "template" || seen[opt.Value] {
+					continue
+				}
+				seen[opt.Value] = true
+				checked++
+
+				if !t.checkFile(emailer.TemplatePath(opt.Value), entry.Options) {
+					ok = false
+				}
+			}
+		}
+	}
+
+	if checked == 0 && !t.check("embedded template", template.EmailTemplate(), nil) {
+		ok = false
+	}
+
+	if !ok {
+		return 1
+	}
+	return 0
+}
+
+// exists returns true if the given file exists.
+func exists(path string) bool {
+	_, err := os.Stat(path)
+	return err == nil
+}
@@ -0,0 +1,75 @@
+package main
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestTemplateCheck(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+
+	good := "From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\n\n{{.Link}}\n{{.RawText}}\n"
+	bad := "From: {{.From}}\nTo: {{.To}}\nSubject: {{.Subject}}\n\n{{.RawText}}\n"
+
+	for name, content := range map[string]string{"good.tmpl": good, "bad.tmpl": bad} {
+		err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+		if err != nil {
+			t.Fatalf("failed to write template: %s", err)
+		}
+	}
+
+	cfg := filepath.Join(dir, "feeds.txt")
+	tc := templateCmd{config: configfile.NewWithPath(cfg)}
+
+	// We need to be told what to do.
+	out = new(bytes.Buffer)
+	if tc.Execute([]string{}) != 1 || tc.Execute([]string{"test"}) != 1 {
+		t.Fatalf("expected error without 'check'")
+	}
+
+	// Without any custom templates the embedded one is checked.
+	if tc.Execute([]string{"check"}) != 0 || !strings.Contains(out.(*bytes.Buffer).String(), "embedded template: OK") {
+		t.Fatalf("unexpected result: %s", out.(*bytes.Buffer).String())
+	}
+
+	// Explicit files.
+	out = new(bytes.Buffer)
+	if tc.Execute([]string{"check", filepath.Join(dir, "good.tmpl")}) != 0 {
+		t.Fatalf("unexpected error: %s", out.(*bytes.Buffer).String())
+	}
+	out = new(bytes.Buffer)
+	if tc.Execute([]string{"check", filepath.Join(dir, "good.tmpl"), filepath.Join(dir, "bad.tmpl"), filepath.Join(dir, "missing.tmpl")}) != 1 {
+		t.Fatalf("expected error: %s", out.(*bytes.Buffer).String())
+	}
+	output := out.(*bytes.Buffer).String()
+	for _, expected := range []string{"good.tmpl: OK", "bad.tmpl: the link to the item doesn't appear", "missing.tmpl: failed to read template"} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output didn't contain %q: %s", expected, output)
+		}
+	}
+
+	// The templates of feeds are checked.
+	err := os.WriteFile(cfg, []byte("https://example.com/\n - template:good.tmpl\nhttps://example.org/\n - template:bad.tmpl\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+	out = new(bytes.Buffer)
+	if tc.Execute([]string{"check"}) != 1 {
+		t.Fatalf("expected error: %s", out.(*bytes.Buffer).String())
+	}
+	output = out.(*bytes.Buffer).String()
+	if !strings.Contains(output, "good.tmpl: OK") || !strings.Contains(output, "bad.tmpl: the link") || strings.Contains(output, "embedded") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+}
@@ -0,0 +1,167 @@
+//
+// Process feeds against fixtures, recording the emails we'd send.
+//
+
+package main
+
+import (
+	"bytes"
+	"context"
+	"flag"
+	"fmt"
+	"mime"
+	"net/mail"
+	"os"
+	"strings"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/processor/emailer"
+	"github.com/skx/rss2email/withstate"
+)
+
+// Structure for our options and state.
+type testCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// The directory holding the content of each feed.
+	fixtures string
+
+	// The directory to write the emails we'd send to, if any.
+	output string
+
+	// The directory holding our state, which is kept between runs.
+	state string
+
+	// The address to use in the From: header.
+	from string
+
+	// The name of the embedded template to use.
+	style string
+
+	// Should we be verbose in operation?
+	verbose bool
+}
+
+// Arguments handles our flag-setup.
+func (t *testCmd) Arguments(f *flag.FlagSet) {
+	t.config = configfile.New()
+
+	f.StringVar(&t.fixtures, "fixtures", "", "The directory holding the content of each feed.")
+	f.StringVar(&t.output, "o", "", "The directory to write the emails which would be sent to.")
+	f.StringVar(&t.state, "state", "", "The directory to hold state, kept between runs, rather than a temporary one.")
+	f.StringVar(&t.from, "from", "", "The address to use in the From: header, rather than the recipient.")
+	f.StringVar(&t.style, "style", "", "The embedded template to use, 'plain' or 'styled'.")
+	f.BoolVar(&t.verbose, "verbose", false, "Should we be extra verbose?")
+}
+
+// Info is part of the subcommand-API.
+func (t *testCmd) Info() (string, string) {
+	return "test", `Process feeds against fixtures, without sending anything.
+
+This sub-command processes your feeds just as the 'cron' sub-command
+does, with their options, filters, and your templates, except that the
+content of each feed is read from a file within the directory given by
+the '-fixtures' flag, rather than being fetched, and the emails which
+would be sent are recorded rather than sent.
+
+The fixture of a feed is named after the feed, if it has a name, or
+otherwise after its URL without the scheme, with characters other than
+letters, digits, dots, and dashes replaced by underscores, followed by
+'.xml', '.json', or nothing at all.  So the fixture of the feed
+'https://blog.steve.fi/index.rss' is 'blog.steve.fi_index.rss.xml'.
+
+The subject of each email is shown, and the emails are written to the
+directory given by the '-o' flag, if any, as '0001.eml' and so on.
+
+State is kept within a temporary directory, so every item is new, unless
+the '-state' flag gives a directory to keep it within between runs.
+Your real state is never changed.
+
+This lets you verify changes to your configuration, or templates,
+safely, and lets the whole pipeline be exercised in CI.  Only the feeds
+are read from fixtures, options which fetch other content, such as
+'wayback', still do so.
+
+Example:
+
+    $ rss2email test -fixtures ./fixtures -o ./out steve@example.com
+`
+}
+
+// Execute is invoked if the user specifies `test` as the subcommand.
+func (t *testCmd) Execute(args []string) int {
+
+	if t.fixtures == "" {
+		fmt.Printf("Usage: rss2email test -fixtures DIR [flags] [email1 .. emailN]\n")
+		return 1
+	}
+	if fi, err := os.Stat(t.fixtures); err != nil || !fi.IsDir() {
+		fmt.Printf("'%s' is not a directory\n", t.fixtures)
+		return 1
+	}
+
+	// Recipients are optional, as nothing is sent.
+	recipients := emailer.SplitAddresses(envRecipients(args)...)
+	if len(recipients) == 0 {
+		recipients = []string{"user@example.com"}
+	}
+
+	// Ensure the configuration is valid before we begin.
+	_, err := t.config.Parse()
+	if err != nil {
+		fmt.Printf("failed to parse configuration file: %s\n", err.Error())
+		return 1
+	}
+
+	// Keep our state away from the real state.
+	dir := t.state
+	if dir == "" {
+		dir, err = os.MkdirTemp("", "rss2email-test")
+		if err != nil {
+			fmt.Printf("failed to create a state directory: %s\n", err.Error())
+			return 1
+		}
+		defer os.RemoveAll(dir)
+	}
+	configfile.SetStateDirectory(dir)
+
+	err = withstate.SetStore("")
+	if err != nil {
+		fmt.Printf("%s\n", err.Error())
+		return 1
+	}
+
+	recorder := emailer.NewRecorder(t.output)
+
+	p := processor.New()
+	p.SetLogger(newLogger(nil, t.verbose, ""))
+	p.SetFixtures(t.fixtures)
+	p.SetRecorder(recorder)
+	p.SetFrom(t.from)
+	p.SetStyle(t.style)
+
+	errors := p.ProcessFeeds(context.Background(), recipients)
+
+	messages := recorder.Messages()
+	for i, msg := range messages {
+		subject := "(unknown)"
+		if m, err := mail.ReadMessage(bytes.NewReader(msg.Content)); err == nil {
+			if s, err := new(mime.WordDecoder).DecodeHeader(m.Header.Get("Subject")); err == nil {
+				subject = s
+			}
+		}
+		fmt.Fprintf(out, "%04d.eml: %s: %s\n", i+1, strings.Join(msg.Recipients, ","), subject)
+	}
+	fmt.Fprintf(out, "\n%d emails would be sent\n", len(messages))
+
+	if len(errors) > 0 {
+		for _, err := range errors {
+			fmt.Printf("%s\n", err.Error())
+		}
+		return 1
+	}
+	return 0
+}
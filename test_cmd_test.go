@@ -0,0 +1,158 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/withstate"
+)
+
+func TestTestCmd(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+	defer configfile.SetStateDirectory("")
+	defer withstate.SetStore("")
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/index.rss\nhttps://example.net/feed\n - name: sample\n - exclude-title: Second\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	feed := `<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Example</title>
+<link>https://%[1]s/</link>
+<item><title>First</title><link>https://%[1]s/first</link></item>
+<item><title>Second</title><link>https://%[1]s/second</link></item>
+</channel>
+</rss>`
+
+	fixtures := t.TempDir()
+	for name, host := range map[string]string{"example.com_index.rss.xml": "example.com", "sample.xml": "example.net"} {
+		err = os.WriteFile(filepath.Join(fixtures, name), []byte(fmt.Sprintf(feed, host)), 0644)
+		if err != nil {
+			t.Fatalf("failed to write fixture: %s", err)
+		}
+	}
+
+	// The fixtures are required.
+	tc := testCmd{config: configfile.New()}
+	if tc.Execute([]string{}) != 1 {
+		t.Fatalf("expected error without fixtures")
+	}
+	tc.fixtures = filepath.Join(fixtures, "missing")
+	if tc.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with missing fixtures")
+	}
+
+	// Each item is recorded, except the one filtered out.
+	out = new(bytes.Buffer)
+	emails := t.TempDir()
+	state := t.TempDir()
+	tc = testCmd{config: configfile.New(), fixtures: fixtures, output: emails, state: state}
+	if tc.Execute([]string{"steve@example.com"}) != 0 {
+		t.Fatalf("unexpected error: %s", out.(*bytes.Buffer).String())
+	}
+	output := out.(*bytes.Buffer).String()
+	if !strings.Contains(output, "3 emails would be sent") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+	if !strings.Contains(output, "0001.eml: steve@example.com: ") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+
+	files, err := filepath.Glob(filepath.Join(emails, "*.eml"))
+	if err != nil || len(files) != 3 {
+		t.Fatalf("expected three emails, got %v: %v", files, err)
+	}
+
+	// The state is kept, so nothing is new the second time.
+	out = new(bytes.Buffer)
+	if tc.Execute([]string{"steve@example.com"}) != 0 {
+		t.Fatalf("unexpected error: %s", out.(*bytes.Buffer).String())
+	}
+	if !strings.Contains(out.(*bytes.Buffer).String(), "0 emails would be sent") {
+		t.Fatalf("unexpected output: %s", out.(*bytes.Buffer).String())
+	}
+
+	// A missing fixture is an error.
+	os.Remove(filepath.Join(fixtures, "sample.xml"))
+	out = new(bytes.Buffer)
+	tc = testCmd{config: configfile.New(), fixtures: fixtures}
+	if tc.Execute([]string{}) != 1 {
+		t.Fatalf("expected error with a missing fixture")
+	}
+}
+
+func TestTestCmdNotice(t *testing.T) {
+
+	bak := out
+	defer func() { out = bak }()
+	defer configfile.SetStateDirectory("")
+	defer withstate.SetStore("")
+
+	dir := t.TempDir()
+	configfile.SetDirectory(dir)
+	defer configfile.SetDirectory("")
+
+	err := os.WriteFile(filepath.Join(dir, "feeds.txt"), []byte("https://example.com/index.rss\n"), 0644)
+	if err != nil {
+		t.Fatalf("failed to write config: %s", err)
+	}
+
+	// The feed publishes its items under new GUIDs each run, so that
+	// we tell the recipient we've noticed that.
+	fixtures := t.TempDir()
+	emails := t.TempDir()
+	tc := testCmd{config: configfile.New(), fixtures: fixtures, output: emails, state: t.TempDir()}
+	for run := 1; run <= 3; run++ {
+		feed := fmt.Sprintf(`<?xml version="1.0"?>
+<rss version="2.0">
+<channel>
+<title>Example</title>
+<link>https://example.com/</link>
+<item><title>First</title><link>https://example.com/first</link><guid>first-%[1]d</guid></item>
+<item><title>Second</title><link>https://example.com/second</link><guid>second-%[1]d</guid></item>
+</channel>
+</rss>`, run)
+		err = os.WriteFile(filepath.Join(fixtures, "example.com_index.rss.xml"), []byte(feed), 0644)
+		if err != nil {
+			t.Fatalf("failed to write fixture: %s", err)
+		}
+
+		out = new(bytes.Buffer)
+		if tc.Execute([]string{"steve@example.com"}) != 0 {
+			t.Fatalf("unexpected error: %s", out.(*bytes.Buffer).String())
+		}
+	}
+
+	// The notice is recorded, along with the emails, rather than sent.
+	output := out.(*bytes.Buffer).String()
+	if !strings.Contains(output, "0001.eml: steve@example.com: rss2email: 1 feed changing item GUIDs") {
+		t.Fatalf("notice wasn't recorded: %s", output)
+	}
+	if !strings.Contains(output, "1 emails would be sent") {
+		t.Fatalf("unexpected output: %s", output)
+	}
+	data, err := os.ReadFile(filepath.Join(emails, "0001.eml"))
+	if err != nil || !strings.Contains(string(data), "https://example.com/index.rss") {
+		t.Fatalf("notice wasn't written: %s %v", data, err)
+	}
+
+	// The configuration is left alone.
+	data, err = os.ReadFile(filepath.Join(dir, "feeds.txt"))
+	if err != nil || string(data) != "https://example.com/index.rss\n" {
+		t.Fatalf("configuration was changed: %s %v", data, err)
+	}
+}
@@ -0,0 +1,81 @@
+// Package tracing configures OpenTelemetry tracing, allowing operators
+// to see where time is spent, and which feeds are slow.
+//
+// Tracing is disabled unless an OTLP endpoint is configured, via the
+// standard environmental variables:
+//
+//	OTEL_EXPORTER_OTLP_ENDPOINT         (e.g. "http://localhost:4318")
+//	OTEL_EXPORTER_OTLP_TRACES_ENDPOINT  (overrides the above, for traces)
+//
+// The other OTEL_EXPORTER_OTLP_* variables, such as those for headers
+// and TLS, are also honoured.  Spans are exported via OTLP over HTTP.
+package tracing
+
+import (
+	"context"
+	"os"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/codes"
+	"go.opentelemetry.io/otel/exporters/otlp/otlptrace/otlptracehttp"
+	"go.opentelemetry.io/otel/sdk/resource"
+	sdktrace "go.opentelemetry.io/otel/sdk/trace"
+	semconv "go.opentelemetry.io/otel/semconv/v1.4.0"
+	"go.opentelemetry.io/otel/trace"
+)
+
+// name is the name of our tracer, and service.
+const name = "rss2email"
+
+// Enabled returns true if an OTLP endpoint has been configured.
+func Enabled() bool {
+	return os.Getenv("OTEL_EXPORTER_OTLP_ENDPOINT") != "" ||
+		os.Getenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT") != ""
+}
+
+// Setup configures the exporting of spans, if an OTLP endpoint has been
+// configured.
+//
+// The returned function must be called before exiting, to ensure that
+// all spans are exported.
+func Setup(ctx context.Context, version string) (func(context.Context) error, error) {
+
+	if !Enabled() {
+		return func(context.Context) error { return nil }, nil
+	}
+
+	exporter, err := otlptracehttp.New(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	res := resource.NewWithAttributes(semconv.SchemaURL,
+		semconv.ServiceNameKey.String(name),
+		semconv.ServiceVersionKey.String(version),
+	)
+
+	provider := sdktrace.NewTracerProvider(
+		sdktrace.WithBatcher(exporter),
+		sdktrace.WithResource(res),
+	)
+	otel.SetTracerProvider(provider)
+
+	return provider.Shutdown, nil
+}
+
+// Start starts a new span with the given name, and attributes.
+//
+// If tracing hasn't been setup then this is a no-op.
+func Start(ctx context.Context, span string, attrs ...attribute.KeyValue) (context.Context, trace.Span) {
+	return otel.Tracer(name).Start(ctx, span, trace.WithAttributes(attrs...))
+}
+
+// End ends the given span, recording the error, if any.
+func End(span trace.Span, err error) {
+	if err != nil {
+		span.RecordError(err)
+		span.SetStatus(codes.Error, err.Error())
+	}
+	span.End()
+}
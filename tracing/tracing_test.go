@@ -0,0 +1,79 @@
+package tracing
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"sync/atomic"
+	"testing"
+
+	"go.opentelemetry.io/otel"
+	"go.opentelemetry.io/otel/trace"
+)
+
+func TestDisabled(t *testing.T) {
+
+	os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+	os.Unsetenv("OTEL_EXPORTER_OTLP_TRACES_ENDPOINT")
+
+	if Enabled() {
+		t.Fatalf("tracing enabled by default")
+	}
+
+	shutdown, err := Setup(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+
+	// Spans are no-ops
+	_, span := Start(context.Background(), "test")
+	if span.IsRecording() {
+		t.Fatalf("span is recording without an exporter")
+	}
+	End(span, errors.New("ignored"))
+
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+}
+
+func TestExport(t *testing.T) {
+
+	var requests int32
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/v1/traces" {
+			atomic.AddInt32(&requests, 1)
+		}
+	}))
+	defer server.Close()
+
+	os.Setenv("OTEL_EXPORTER_OTLP_ENDPOINT", server.URL)
+	defer os.Unsetenv("OTEL_EXPORTER_OTLP_ENDPOINT")
+
+	if !Enabled() {
+		t.Fatalf("tracing not enabled")
+	}
+
+	shutdown, err := Setup(context.Background(), "test")
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	defer otel.SetTracerProvider(trace.NewNoopTracerProvider())
+
+	_, span := Start(context.Background(), "test")
+	if !span.IsRecording() {
+		t.Fatalf("span isn't recording")
+	}
+	End(span, errors.New("failed"))
+
+	// Shutting down flushes the spans.
+	if err = shutdown(context.Background()); err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	if atomic.LoadInt32(&requests) == 0 {
+		t.Fatalf("no spans were exported")
+	}
+}
@@ -0,0 +1,214 @@
+// Package translate translates the text of feed items, so that feeds
+// written in a foreign language may be read in our own.
+//
+// Translation is carried out by one of several backends, which is chosen
+// via the environment:
+//
+//	TRANSLATE_COMMAND    a command which translates its STDIN to STDOUT
+//	DEEPL_API_KEY        the DeepL API
+//	LIBRETRANSLATE_URL   a LibreTranslate server
+//
+// The first which is set is used.  The API keys may refer to secrets, as
+// understood by the secret package.
+package translate
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/exec"
+	"runtime"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/secret"
+)
+
+// Translator translates text.
+type Translator interface {
+
+	// Translate returns the given text, translated to the language
+	// with the given code, such as "en".  The text may be HTML.
+	Translate(text string, target string) (string, error)
+}
+
+// New returns the translator configured via the environment, or an error
+// if there is none.
+func New() (Translator, error) {
+
+	if command := os.Getenv("TRANSLATE_COMMAND"); command != "" {
+		return &Command{command: command}, nil
+	}
+
+	if os.Getenv("DEEPL_API_KEY") != "" {
+		key, err := secret.Getenv("DEEPL_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return NewDeepL(key), nil
+	}
+
+	if url := os.Getenv("LIBRETRANSLATE_URL"); url != "" {
+		key, err := secret.Getenv("LIBRETRANSLATE_API_KEY")
+		if err != nil {
+			return nil, err
+		}
+		return NewLibreTranslate(url, key), nil
+	}
+
+	return nil, fmt.Errorf("no translator is configured, set $TRANSLATE_COMMAND, $DEEPL_API_KEY, or $LIBRETRANSLATE_URL")
+}
+
+// client is the HTTP client used to make requests of translation APIs.
+var client = &http.Client{Timeout: 60 * time.Second}
+
+// post sends the given JSON to the given URL, with the given headers, and
+// decodes the JSON response into res.
+func post(url string, headers map[string]string, body interface{}, res interface{}) error {
+
+	data, err := json.Marshal(body)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequest("POST", url, bytes.NewReader(data))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "rss2email (https://github.com/skx/rss2email)")
+	for k, v := range headers {
+		req.Header.Set(k, v)
+	}
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	data, err = io.ReadAll(resp.Body)
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("translating returned status %d: %s", resp.StatusCode, strings.TrimSpace(string(data)))
+	}
+	return json.Unmarshal(data, res)
+}
+
+// LibreTranslate translates text via a LibreTranslate server.
+type LibreTranslate struct {
+
+	// url is the address of the server, and key the API key to send,
+	// if any.
+	url string
+	key string
+}
+
+// NewLibreTranslate returns a translator which uses the LibreTranslate
+// server at the given URL.
+func NewLibreTranslate(url string, key string) *LibreTranslate {
+	return &LibreTranslate{url: strings.TrimSuffix(url, "/"), key: key}
+}
+
+// Translate is part of the Translator interface.
+func (l *LibreTranslate) Translate(text string, target string) (string, error) {
+
+	body := map[string]string{
+		"q":      text,
+		"source": "auto",
+		"target": target,
+		"format": "html",
+	}
+	if l.key != "" {
+		body["api_key"] = l.key
+	}
+
+	var res struct {
+		Text string `json:"translatedText"`
+	}
+	err := post(l.url+"/translate", nil, body, &res)
+	return res.Text, err
+}
+
+// DeepL translates text via the DeepL API.
+type DeepL struct {
+
+	// url is the address of the API, and key our API key.
+	url string
+	key string
+}
+
+// NewDeepL returns a translator which uses the DeepL API, with the given
+// API key.  Keys of free accounts use the free API.
+func NewDeepL(key string) *DeepL {
+
+	url := "https://api.deepl.com"
+	if strings.HasSuffix(key, ":fx") {
+		url = "https://api-free.deepl.com"
+	}
+	return &DeepL{url: url, key: key}
+}
+
+// Translate is part of the Translator interface.
+func (d *DeepL) Translate(text string, target string) (string, error) {
+
+	body := map[string]interface{}{
+		"text":         []string{text},
+		"target_lang":  strings.ToUpper(target),
+		"tag_handling": "html",
+	}
+
+	var res struct {
+		Translations []struct {
+			Text string `json:"text"`
+		} `json:"translations"`
+	}
+	err := post(d.url+"/v2/translate", map[string]string{"Authorization": "DeepL-Auth-Key " + d.key}, body, &res)
+	if err != nil {
+		return "", err
+	}
+	if len(res.Translations) == 0 {
+		return "", fmt.Errorf("DeepL returned no translation")
+	}
+	return res.Translations[0].Text, nil
+}
+
+// Command translates text via an external command, which is run via the
+// shell, with the text upon STDIN, and the target language in
+// $RSS2EMAIL_LANGUAGE.  The translation is read from STDOUT.
+type Command struct {
+	command string
+}
+
+// Translate is part of the Translator interface.
+func (c *Command) Translate(text string, target string) (string, error) {
+
+	var cmd *exec.Cmd
+	if runtime.GOOS == "windows" {
+		cmd = exec.Command("cmd", "/C", c.command)
+	} else {
+		cmd = exec.Command("/bin/sh", "-c", c.command)
+	}
+
+	stdout := &bytes.Buffer{}
+	stderr := &bytes.Buffer{}
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = stdout
+	cmd.Stderr = stderr
+	cmd.Env = append(os.Environ(), "RSS2EMAIL_LANGUAGE="+target)
+
+	err := cmd.Run()
+	if err != nil {
+		msg := strings.TrimSpace(stderr.String())
+		if msg != "" {
+			return "", fmt.Errorf("command '%s' failed: %s: %s", c.command, err, msg)
+		}
+		return "", fmt.Errorf("command '%s' failed: %s", c.command, err)
+	}
+	return strings.TrimRight(stdout.String(), "\r\n"), nil
+}
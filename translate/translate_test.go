@@ -0,0 +1,117 @@
+package translate
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"runtime"
+	"testing"
+)
+
+// TestNew ensures the translator is chosen via the environment.
+func TestNew(t *testing.T) {
+
+	for _, name := range []string{"TRANSLATE_COMMAND", "DEEPL_API_KEY", "LIBRETRANSLATE_URL"} {
+		os.Unsetenv(name)
+		defer os.Unsetenv(name)
+	}
+
+	if _, err := New(); err == nil {
+		t.Fatalf("expected an error without a translator")
+	}
+
+	os.Setenv("LIBRETRANSLATE_URL", "https://translate.example.com/")
+	if tr, err := New(); err != nil || tr.(*LibreTranslate).url != "https://translate.example.com" {
+		t.Fatalf("expected LibreTranslate: %v %v", tr, err)
+	}
+
+	os.Setenv("DEEPL_API_KEY", "secret:fx")
+	if tr, err := New(); err != nil || tr.(*DeepL).url != "https://api-free.deepl.com" {
+		t.Fatalf("expected the free DeepL API: %v %v", tr, err)
+	}
+
+	os.Setenv("TRANSLATE_COMMAND", "cat")
+	if _, ok := mustNew(t).(*Command); !ok {
+		t.Fatalf("expected a command")
+	}
+}
+
+// mustNew returns the configured translator.
+func mustNew(t *testing.T) Translator {
+	tr, err := New()
+	if err != nil {
+		t.Fatalf("unexpected error: %s", err)
+	}
+	return tr
+}
+
+// TestLibreTranslate translates via a fake LibreTranslate server.
+func TestLibreTranslate(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req map[string]string
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.URL.Path != "/translate" || req["target"] != "en" || req["api_key"] != "key" || req["format"] != "html" {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		json.NewEncoder(w).Encode(map[string]string{"translatedText": "<p>Hello</p>"})
+	}))
+	defer ts.Close()
+
+	out, err := NewLibreTranslate(ts.URL, "key").Translate("<p>Hola</p>", "en")
+	if err != nil || out != "<p>Hello</p>" {
+		t.Fatalf("unexpected translation: %s %v", out, err)
+	}
+
+	_, err = NewLibreTranslate(ts.URL, "wrong").Translate("<p>Hola</p>", "en")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
+
+// TestDeepL translates via a fake DeepL API.
+func TestDeepL(t *testing.T) {
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Text   []string `json:"text"`
+			Target string   `json:"target_lang"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if r.Header.Get("Authorization") != "DeepL-Auth-Key key" || req.Target != "EN" || len(req.Text) != 1 {
+			w.WriteHeader(http.StatusForbidden)
+			return
+		}
+		w.Write([]byte(`{"translations":[{"detected_source_language":"ES","text":"Hello"}]}`))
+	}))
+	defer ts.Close()
+
+	d := NewDeepL("key")
+	d.url = ts.URL
+	out, err := d.Translate("Hola", "en")
+	if err != nil || out != "Hello" {
+		t.Fatalf("unexpected translation: %s %v", out, err)
+	}
+}
+
+// TestCommand translates via an external command.
+func TestCommand(t *testing.T) {
+
+	if runtime.GOOS == "windows" {
+		t.Skip("requires a unix shell")
+	}
+
+	c := &Command{command: `sed "s/Hola/Hello ($RSS2EMAIL_LANGUAGE)/"`}
+	out, err := c.Translate("Hola\n", "en")
+	if err != nil || out != "Hello (en)" {
+		t.Fatalf("unexpected translation: %s %v", out, err)
+	}
+
+	c = &Command{command: "echo oops >&2; exit 1"}
+	_, err = c.Translate("Hola", "en")
+	if err == nil || err.Error() != "command 'echo oops >&2; exit 1' failed: exit status 1: oops" {
+		t.Fatalf("unexpected error: %v", err)
+	}
+}
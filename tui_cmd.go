@@ -0,0 +1,370 @@
+//
+// Manage our feeds interactively.
+//
+
+package main
+
+import (
+	"bufio"
+	"context"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+	"github.com/skx/rss2email/fetch"
+	"github.com/skx/rss2email/processor"
+)
+
+// feedStatus holds what we know about a feed, from fetching it.
+type feedStatus struct {
+
+	// The items of the feed, newest first.
+	items []*gofeed.Item
+
+	// The error from the most recent fetch, if any.
+	err error
+
+	// The number of failed fetches in this session.
+	errors int
+}
+
+// Structure for our options and state.
+type tuiCmd struct {
+
+	// Configuration file, used for testing
+	config *configfile.ConfigFile
+
+	// Where we read commands from, used for testing
+	in io.Reader
+
+	// Should we clear the screen before drawing?
+	clear bool
+
+	// The feeds we're managing.
+	entries []configfile.Feed
+
+	// The status of each feed, by URL.
+	status map[string]*feedStatus
+}
+
+// Arguments handles argument-flags we might have.
+//
+// In our case we use this as a hook to setup our configuration-file,
+// which allows testing.
+func (t *tuiCmd) Arguments(flags *flag.FlagSet) {
+	t.config = configfile.New()
+}
+
+// Info is part of the subcommand-API
+func (t *tuiCmd) Info() (string, string) {
+	return "tui", `Manage the feed list interactively.
+
+This command shows a table of the configured feeds, along with their
+status, their most recent item, and the number of times fetching them
+has failed.  Commands are entered beneath the table:
+
+    a URL    Add a new feed.
+    d N      Delete feed number N.
+    p N      Pause, or resume, feed number N.
+    v N      View the recent items of feed number N.
+    r        Refresh, by fetching each feed again.
+    q        Quit.
+
+The items of paused feeds are recorded as having been seen, but are not
+emailed, so that no flood of emails is sent when they are resumed.  This
+is controlled by the per-feed 'paused' option.
+
+Changes are saved to the configuration file as they are made.
+
+Example:
+
+    $ rss2email tui
+`
+}
+
+// fetch fetches each of the given feeds, in parallel, updating their status.
+func (t *tuiCmd) fetch(entries []configfile.Feed) {
+
+	var mutex sync.Mutex
+	var wg sync.WaitGroup
+
+	// Limit the number of concurrent fetches.
+	sem := make(chan bool, 8)
+
+	for _, entry := range entries {
+		wg.Add(1)
+		go func(entry configfile.Feed) {
+			defer wg.Done()
+			sem <- true
+			defer func() { <-sem }()
+
+			var feed *gofeed.Feed
+			helper, err := fetch.New(entry)
+			if err == nil {
+				feed, err = helper.FetchContext(context.Background())
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			st, ok := t.status[entry.URL]
+			if !ok {
+				st = &feedStatus{}
+				t.status[entry.URL] = st
+			}
+			st.err = err
+			if err != nil {
+				st.errors++
+				return
+			}
+
+			st.items = feed.Items
+			sort.SliceStable(st.items, func(i, j int) bool {
+				a, b := st.items[i].PublishedParsed, st.items[j].PublishedParsed
+				return a != nil && (b == nil || a.After(*b))
+			})
+		}(entry)
+	}
+	wg.Wait()
+}
+
+// reload re-reads the configuration file.
+func (t *tuiCmd) reload() error {
+	entries, err := t.config.Parse()
+	if err != nil {
+		return err
+	}
+	t.entries = entries
+	return nil
+}
+
+// truncate shortens the given string to the given number of characters.
+func truncate(s string, n int) string {
+	r := []rune(strings.TrimSpace(s))
+	if len(r) > n {
+		return string(r[:n-1]) + "…"
+	}
+	return string(r)
+}
+
+// draw shows the table of feeds.
+func (t *tuiCmd) draw() {
+
+	if t.clear {
+		fmt.Fprint(out, "\033[H\033[2J")
+	}
+
+	fmt.Fprintf(out, "%3s  %-7s %5s %6s  %-10s %-32s  %s\n", "#", "Status", "Items", "Errors", "Latest", "Last item", "Feed")
+
+	for i, entry := range t.entries {
+
+		status := "-"
+		items := ""
+		errors := ""
+		latest := ""
+		last := ""
+
+		if st, ok := t.status[entry.URL]; ok {
+			status = "ok"
+			if st.err != nil {
+				status = "error"
+			}
+			items = strconv.Itoa(len(st.items))
+			errors = strconv.Itoa(st.errors)
+			if len(st.items) > 0 {
+				last = truncate(st.items[0].Title, 32)
+				if st.items[0].PublishedParsed != nil {
+					latest = st.items[0].PublishedParsed.Format("2006-01-02")
+				}
+			}
+		}
+		if processor.IsPaused(entry) {
+			status = "paused"
+		}
+
+		fmt.Fprintf(out, "%3d  %-7s %5s %6s  %-10s %-32s  %s\n", i+1, status, items, errors, latest, last, entry.Label())
+	}
+
+	fmt.Fprintf(out, "\n[a]dd URL  [d]elete N  [p]ause N  [v]iew N  [r]efresh  [q]uit\n> ")
+}
+
+// feed returns the feed with the given number, as shown in our table.
+func (t *tuiCmd) feed(arg string) (configfile.Feed, error) {
+	n, err := strconv.Atoi(arg)
+	if err != nil || n < 1 || n > len(t.entries) {
+		return configfile.Feed{}, fmt.Errorf("there is no feed '%s'", arg)
+	}
+	return t.entries[n-1], nil
+}
+
+// view shows the recent items of the given feed.
+func (t *tuiCmd) view(entry configfile.Feed) {
+
+	st, ok := t.status[entry.URL]
+	if !ok {
+		t.fetch([]configfile.Feed{entry})
+		st = t.status[entry.URL]
+	}
+
+	fmt.Fprintf(out, "\n%s\n", entry.Label())
+	if st.err != nil {
+		fmt.Fprintf(out, "  Error: %s\n", st.err)
+		return
+	}
+
+	for i, item := range st.items {
+		if i == 10 {
+			break
+		}
+		date := "          "
+		if item.PublishedParsed != nil {
+			date = item.PublishedParsed.Format("2006-01-02")
+		}
+		fmt.Fprintf(out, "  %s  %s\n              %s\n", date, truncate(item.Title, 64), item.Link)
+	}
+}
+
+// run handles a single command, returning false if we should quit.
+func (t *tuiCmd) run(line string) (bool, error) {
+
+	fields := strings.Fields(line)
+	if len(fields) == 0 {
+		return true, nil
+	}
+	arg := ""
+	if len(fields) > 1 {
+		arg = fields[1]
+	}
+
+	switch fields[0] {
+	case "q", "quit":
+		return false, nil
+
+	case "r", "refresh":
+		t.fetch(t.entries)
+
+	case "a", "add":
+		if arg == "" {
+			return true, fmt.Errorf("usage: a URL")
+		}
+		if dup := (&addCmd{}).duplicate(t.entries, arg); dup != "" {
+			return true, fmt.Errorf("%s duplicates the existing feed %s", arg, dup)
+		}
+		t.config.Add(arg)
+		if err := t.config.Save(); err != nil {
+			return true, err
+		}
+		if err := t.reload(); err != nil {
+			return true, err
+		}
+		t.fetch([]configfile.Feed{{URL: arg}})
+
+	case "d", "delete":
+		entry, err := t.feed(arg)
+		if err != nil {
+			return true, err
+		}
+		t.config.Delete(entry.URL)
+		if err := t.config.Save(); err != nil {
+			return true, err
+		}
+		delete(t.status, entry.URL)
+		return true, t.reload()
+
+	case "p", "pause":
+		entry, err := t.feed(arg)
+		if err != nil {
+			return true, err
+		}
+
+		// Replace any existing setting.
+		var opts []configfile.Option
+		for _, opt := range entry.Options {
+			if opt.Name != "paused" {
+				opts = append(opts, opt)
+			}
+		}
+		if !processor.IsPaused(entry) {
+			opts = append(opts, configfile.Option{Name: "paused", Value: "true"})
+		}
+		entry.Options = opts
+
+		t.config.Update(entry)
+		if err := t.config.Save(); err != nil {
+			return true, err
+		}
+		return true, t.reload()
+
+	case "v", "view":
+		entry, err := t.feed(arg)
+		if err != nil {
+			return true, err
+		}
+		t.view(entry)
+		return true, errPause
+
+	default:
+		return true, fmt.Errorf("unknown command '%s'", fields[0])
+	}
+
+	return true, nil
+}
+
+// errPause is returned by commands whose output should be shown until
+// the user presses enter, rather than being replaced by our table.
+var errPause = fmt.Errorf("press enter to continue")
+
+// Execute is invoked if the user specifies `tui` as the subcommand.
+func (t *tuiCmd) Execute(args []string) int {
+
+	// Upgrade our configuration-file if necessary
+	t.config.Upgrade()
+
+	err := t.reload()
+	if err != nil {
+		fmt.Printf("Error with config-file: %s\n", err.Error())
+		return 1
+	}
+
+	// Default to the terminal.
+	if t.in == nil {
+		t.in = os.Stdin
+		t.clear = isTerminal(os.Stdout)
+	}
+
+	t.status = make(map[string]*feedStatus)
+	fmt.Fprintf(out, "Fetching %d feeds ..\n", len(t.entries))
+	t.fetch(t.entries)
+
+	scanner := bufio.NewScanner(t.in)
+	for {
+		t.draw()
+
+		if !scanner.Scan() {
+			fmt.Fprintln(out)
+			return 0
+		}
+
+		more, err := t.run(scanner.Text())
+		if !more {
+			return 0
+		}
+		if err != nil {
+			if err != errPause {
+				fmt.Fprintf(out, "\nError: %s\n", err)
+			}
+			fmt.Fprintf(out, "\n%s ", errPause)
+			if !scanner.Scan() {
+				fmt.Fprintln(out)
+				return 0
+			}
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestTUI(t *testing.T) {
+
+	bak := out
+	out = new(bytes.Buffer)
+	defer func() { out = bak }()
+
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/broken" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		fmt.Fprintf(w, `<?xml version="1.0"?>
+<rss version="2.0"><channel><title>Example</title>
+<item><title>Older item</title><link>https://example.com/1</link><pubDate>Mon, 01 Jan 2024 00:00:00 +0000</pubDate></item>
+<item><title>Newest item</title><link>https://example.com/2</link><pubDate>Tue, 02 Jan 2024 00:00:00 +0000</pubDate></item>
+</channel></rss>`)
+	}))
+	defer ts.Close()
+
+	tmpfile, err := os.CreateTemp("", "example")
+	if err != nil {
+		t.Fatalf("Error creating temporary file")
+	}
+	defer os.Remove(tmpfile.Name())
+	tmpfile.Write([]byte(ts.URL + "/feed\n - name: Example\n" + ts.URL + "/broken\n"))
+	tmpfile.Close()
+
+	script := strings.Join([]string{
+		"v 1", "",
+		"p 1",
+		"a " + ts.URL + "/other",
+		"a " + ts.URL + "/feed/", "",
+		"d 2",
+		"x", "",
+		"q",
+	}, "\n")
+
+	tui := tuiCmd{config: configfile.NewWithPath(tmpfile.Name()), in: strings.NewReader(script)}
+	if tui.Execute([]string{}) != 0 {
+		t.Fatalf("unexpected error running tui")
+	}
+
+	output := out.(*bytes.Buffer).String()
+	for _, expected := range []string{
+		"ok          2      0  2024-01-02 Newest item",
+		"error       0      1",
+		"  2024-01-01  Older item\n              https://example.com/1",
+		"paused      2      0",
+		"duplicates the existing feed " + ts.URL + "/feed",
+		"unknown command 'x'",
+	} {
+		if !strings.Contains(output, expected) {
+			t.Errorf("output is missing %q:\n%s", expected, output)
+		}
+	}
+
+	// The changes should have been saved.
+	entries, err := configfile.NewWithPath(tmpfile.Name()).Parse()
+	if err != nil {
+		t.Fatalf("Error parsing written file")
+	}
+	if len(entries) != 2 || entries[0].URL != ts.URL+"/feed" || entries[1].URL != ts.URL+"/other" {
+		t.Fatalf("unexpected entries: %v", entries)
+	}
+	if entries[0].Label() != "Example" || entries[0].Options[1].Name != "paused" {
+		t.Fatalf("feed was not paused: %v", entries[0])
+	}
+}
@@ -0,0 +1,190 @@
+// Package unshorten expands the links of URL-shorteners, such as t.co and
+// bit.ly, to the addresses they redirect to.
+//
+// This means emails show the real destination of their links, and that
+// archived emails remain useful after the shorteners are gone.
+//
+// Redirects are only followed while they lead to a shortener we know of,
+// so the redirects of the destination site, for example to a login page,
+// are not followed.  Expansions are cached upon the local filesystem, as
+// the destination of a shortened link doesn't change.
+package unshorten
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// Hosts holds the shorteners we know of.
+var Hosts = []string{
+	"bit.ly",
+	"buff.ly",
+	"dlvr.it",
+	"feedproxy.google.com",
+	"feeds.feedburner.com",
+	"goo.gl",
+	"is.gd",
+	"lnkd.in",
+	"ow.ly",
+	"rebrand.ly",
+	"t.co",
+	"tinyurl.com",
+	"trib.al",
+}
+
+// Expander is our state-storing structure
+type Expander struct {
+
+	// dir is the directory in which we cache expansions.
+	dir string
+
+	// ttl is the length of time for which cached expansions are used.
+	ttl time.Duration
+
+	// hops is the largest number of redirects we'll follow.
+	hops int
+
+	// hosts holds the hosts of the shorteners we expand.
+	hosts map[string]bool
+
+	// userAgent is the User-Agent header to send.
+	userAgent string
+}
+
+// New creates a new object, which caches expansions beneath our state
+// directory.
+func New() *Expander {
+
+	e := &Expander{
+		dir:       filepath.Join(configfile.New().StateDirectory(), "links"),
+		ttl:       90 * 24 * time.Hour,
+		hops:      10,
+		hosts:     make(map[string]bool),
+		userAgent: "rss2email (https://github.com/skx/rss2email)",
+	}
+	for _, host := range Hosts {
+		e.hosts[host] = true
+	}
+	return e
+}
+
+// SetUserAgent updates the User-Agent header we send.
+func (e *Expander) SetUserAgent(agent string) {
+	e.userAgent = agent
+}
+
+// Shortened returns true if the given link is that of a shortener we know
+// of, and so may be expanded.
+func (e *Expander) Shortened(link string) bool {
+
+	u, err := url.Parse(link)
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return false
+	}
+	return e.hosts[strings.TrimPrefix(strings.ToLower(u.Hostname()), "www.")]
+}
+
+// Expand returns the address the given link redirects to.
+//
+// Links which aren't shortened are returned unchanged, as are those whose
+// redirects lead nowhere.  An error is returned if the first redirect
+// couldn't be fetched, otherwise we return the last address we reached.
+func (e *Expander) Expand(link string) (string, error) {
+
+	if !e.Shortened(link) {
+		return link, nil
+	}
+
+	// Look for a cached copy
+	path := filepath.Join(e.dir, fmt.Sprintf("%x", sha1.Sum([]byte(link))))
+
+	fi, err := os.Stat(path)
+	if err == nil && time.Since(fi.ModTime()) < e.ttl {
+		data, err := os.ReadFile(path)
+		if err == nil && len(data) > 0 {
+			return string(data), nil
+		}
+	}
+
+	cur := link
+	for i := 0; i < e.hops && e.Shortened(cur); i++ {
+
+		next, err := e.next(cur)
+		if err != nil {
+			if cur == link {
+				return link, err
+			}
+			break
+		}
+		if next == "" {
+			break
+		}
+		cur = next
+	}
+
+	// Failing to cache isn't fatal.
+	os.MkdirAll(e.dir, os.ModePerm)
+	_ = os.WriteFile(path, []byte(cur), 0644)
+
+	return cur, nil
+}
+
+// next returns the address the given link redirects to, or the empty
+// string if it doesn't redirect.
+func (e *Expander) next(link string) (string, error) {
+
+	client := &http.Client{
+		Timeout: 10 * time.Second,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	var resp *http.Response
+	for _, method := range []string{"HEAD", "GET"} {
+
+		req, err := http.NewRequest(method, link, nil)
+		if err != nil {
+			return "", err
+		}
+		req.Header.Set("User-Agent", e.userAgent)
+
+		resp, err = client.Do(req)
+		if err != nil {
+			return "", err
+		}
+		resp.Body.Close()
+
+		// Some shorteners don't support HEAD requests.
+		if resp.StatusCode != http.StatusMethodNotAllowed && resp.StatusCode != http.StatusNotImplemented {
+			break
+		}
+	}
+
+	switch resp.StatusCode {
+	case http.StatusMovedPermanently, http.StatusFound, http.StatusSeeOther,
+		http.StatusTemporaryRedirect, http.StatusPermanentRedirect:
+	default:
+		if resp.StatusCode >= 400 {
+			return "", fmt.Errorf("fetching %s returned status %d", link, resp.StatusCode)
+		}
+		return "", nil
+	}
+
+	loc, err := resp.Location()
+	if err != nil {
+		return "", fmt.Errorf("%s redirected without a location", link)
+	}
+	if loc.Scheme != "http" && loc.Scheme != "https" {
+		return "", fmt.Errorf("%s redirected to %s", link, loc)
+	}
+	return loc.String(), nil
+}
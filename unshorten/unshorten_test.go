@@ -0,0 +1,106 @@
+package unshorten
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestShortened ensures we recognise shortened links.
+func TestShortened(t *testing.T) {
+
+	e := New()
+
+	for link, expected := range map[string]bool{
+		"https://t.co/abc123":                         true,
+		"http://bit.ly/xyz":                           true,
+		"https://WWW.Bit.ly/xyz":                      true,
+		"https://feedproxy.google.com/~r/blog/~3/abc": true,
+		"https://example.com/t.co":                    false,
+		"mailto:someone@t.co":                         false,
+		"not a link":                                  false,
+	} {
+		if e.Shortened(link) != expected {
+			t.Errorf("unexpected result for %s", link)
+		}
+	}
+}
+
+// TestExpand follows a chain of redirects, and ensures the result is
+// cached.
+func TestExpand(t *testing.T) {
+
+	count := 0
+	var dest string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch r.URL.Path {
+		case "/a":
+			http.Redirect(w, r, "/b", http.StatusMovedPermanently)
+		case "/b":
+			// Doesn't support HEAD.
+			if r.Method == "HEAD" {
+				w.WriteHeader(http.StatusMethodNotAllowed)
+				return
+			}
+			http.Redirect(w, r, dest+"/article", http.StatusFound)
+		case "/loop":
+			http.Redirect(w, r, "/loop", http.StatusFound)
+		case "/missing":
+			http.NotFound(w, r)
+		case "/article":
+			// The redirects of the destination aren't followed.
+			http.Redirect(w, r, "/login", http.StatusFound)
+		}
+	}))
+	defer ts.Close()
+
+	// The destination must not be a shortener.
+	dest = strings.Replace(ts.URL, "127.0.0.1", "localhost", 1)
+
+	e := New()
+	e.dir = t.TempDir()
+	e.hosts = map[string]bool{"127.0.0.1": true}
+
+	for i := 0; i < 2; i++ {
+		link, err := e.Expand(ts.URL + "/a")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if link != dest+"/article" {
+			t.Fatalf("unexpected expansion: %s", link)
+		}
+	}
+
+	// One request for /a, and two for /b.
+	if count != 3 {
+		t.Fatalf("expected three requests, got %d", count)
+	}
+
+	// A loop stops at our limit.
+	count = 0
+	link, err := e.Expand(ts.URL + "/loop")
+	if err != nil || link != ts.URL+"/loop" {
+		t.Fatalf("unexpected result: %s %v", link, err)
+	}
+	if count != e.hops {
+		t.Fatalf("expected %d requests, got %d", e.hops, count)
+	}
+
+	// A failure is reported, and the link unchanged.
+	link, err = e.Expand(ts.URL + "/missing")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+	if link != ts.URL+"/missing" {
+		t.Fatalf("unexpected expansion: %s", link)
+	}
+
+	// Links which aren't shortened aren't fetched.
+	count = 0
+	link, err = e.Expand(dest + "/article")
+	if err != nil || link != dest+"/article" || count != 0 {
+		t.Fatalf("unexpected result: %s %v %d", link, err, count)
+	}
+}
@@ -3,9 +3,9 @@ package main
 import (
 	"flag"
 	"github.com/skx/rss2email/configfile"
-	"io/ioutil"
 	"os"
 	"testing"
+	"time"
 )
 
 // TestUsage just calls the usage-function for each of our handlers,
@@ -16,6 +16,14 @@ func TestUsage(t *testing.T) {
 	add.Info()
 	add.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
 
+	bench := benchCmd{}
+	bench.Info()
+	bench.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	completion := completionCmd{}
+	completion.Info()
+	completion.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
 	cron := cronCmd{}
 	cron.Info()
 	cron.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
@@ -36,18 +44,86 @@ func TestUsage(t *testing.T) {
 	export.Info()
 	export.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
 
+	filter := filterTestCmd{}
+	filter.Info()
+	filter.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	grep := grepCmd{}
+	grep.Info()
+	grep.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	health := healthCmd{}
+	health.Info()
+	health.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
 	imprt := importCmd{}
 	imprt.Info()
 	imprt.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
 
+	legacy := importLegacyCmd{}
+	legacy.Info()
+	legacy.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	initialise := initCmd{}
+	initialise.Info()
+	initialise.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	lint := lintCmd{}
+	lint.Info()
+	lint.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
 	list := listCmd{}
 	list.Info()
 	list.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
 
+	manifest := manifestCmd{}
+	manifest.Info()
+	manifest.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
 	ldt := listDefaultTemplateCmd{}
 	ldt.Info()
 	ldt.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
 
+	log := logCmd{}
+	log.Info()
+	log.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	preview := previewCmd{}
+	preview.Info()
+	preview.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	render := renderCmd{}
+	render.Info()
+	render.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	rerender := rerenderCmd{}
+	rerender.Info()
+	rerender.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	resend := resendCmd{}
+	resend.Info()
+	resend.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	restore := restoreCmd{}
+	restore.Info()
+	restore.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	search := searchCmd{}
+	search.Info()
+	search.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	tmpl := templateCmd{}
+	tmpl.Info()
+	tmpl.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	test := testCmd{}
+	test.Info()
+	test.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
+	tui := tuiCmd{}
+	tui.Info()
+	tui.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
+
 	vers := versionCmd{}
 	vers.Info()
 	vers.Arguments(flag.NewFlagSet("test", flag.ContinueOnError))
@@ -59,7 +135,7 @@ func TestBrokenConfig(t *testing.T) {
 
 	data := []byte(`# This is bogus, options must follow URLs
  - foo:bar`)
-	tmpfile, err := ioutil.TempFile("", "example")
+	tmpfile, err := os.CreateTemp("", "example")
 	if err != nil {
 		t.Fatalf("Error creating temporary file")
 	}
@@ -93,6 +169,20 @@ func TestBrokenConfig(t *testing.T) {
 		t.Fatalf("expected error with config file")
 	}
 
+	ft := filterTestCmd{feed: "https://example.com/"}
+	ft.config = configfile.NewWithPath(tmpfile.Name())
+	res = ft.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	h := healthCmd{}
+	h.config = configfile.NewWithPath(tmpfile.Name())
+	res = h.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
 	i := importCmd{}
 	i.config = configfile.NewWithPath(tmpfile.Name())
 	res = i.Execute([]string{})
@@ -100,6 +190,20 @@ func TestBrokenConfig(t *testing.T) {
 		t.Fatalf("expected error with config file")
 	}
 
+	il := importLegacyCmd{}
+	il.config = configfile.NewWithPath(tmpfile.Name())
+	res = il.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	in := initCmd{}
+	in.config = configfile.NewWithPath(tmpfile.Name())
+	res = in.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
 	l := listCmd{}
 	l.config = configfile.NewWithPath(tmpfile.Name())
 	res = l.Execute([]string{})
@@ -107,6 +211,76 @@ func TestBrokenConfig(t *testing.T) {
 		t.Fatalf("expected error with config file")
 	}
 
+	lt := lintCmd{}
+	lt.config = configfile.NewWithPath(tmpfile.Name())
+	res = lt.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	mc := manifestCmd{}
+	mc.config = configfile.NewWithPath(tmpfile.Name())
+	res = mc.Execute([]string{"k8s"})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	tc := templateCmd{}
+	tc.config = configfile.NewWithPath(tmpfile.Name())
+	res = tc.Execute([]string{"check"})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	gc := grepCmd{}
+	gc.config = configfile.NewWithPath(tmpfile.Name())
+	res = gc.Execute([]string{"golang"})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	pc := previewCmd{}
+	pc.config = configfile.NewWithPath(tmpfile.Name())
+	res = pc.Execute([]string{"https://example.com/"})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	rc := renderCmd{}
+	rc.config = configfile.NewWithPath(tmpfile.Name())
+	res = rc.Execute([]string{"https://example.com/"})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	bc := benchCmd{feed: "example"}
+	bc.config = configfile.NewWithPath(tmpfile.Name())
+	res = bc.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	rr := rerenderCmd{since: time.Hour, dryRun: true}
+	rr.config = configfile.NewWithPath(tmpfile.Name())
+	res = rr.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	rs := restoreCmd{}
+	rs.config = configfile.NewWithPath(tmpfile.Name())
+	res = rs.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
+	ts := testCmd{fixtures: os.TempDir()}
+	ts.config = configfile.NewWithPath(tmpfile.Name())
+	res = ts.Execute([]string{})
+	if res != 1 {
+		t.Fatalf("expected error with config file")
+	}
+
 	// TODO : error-match
 
 	os.Remove(tmpfile.Name())
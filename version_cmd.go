@@ -10,6 +10,12 @@ import (
 	"io"
 	"os"
 	"runtime"
+	"runtime/debug"
+	"strings"
+
+	"github.com/skx/rss2email/fetch"
+	"github.com/skx/rss2email/processor"
+	"github.com/skx/rss2email/withstate"
 )
 
 //
@@ -17,25 +23,38 @@ import (
 //
 var out io.Writer = os.Stdout
 
+//
+// set at build-time, via "-ldflags -X main.version=..", otherwise the
+// commit and date are found from the build information Go records.
+//
 var (
 	version = "unreleased"
+	commit  = ""
+	date    = ""
 )
 
 // Structure for our options and state.
 type versionCmd struct {
 	// verbose controls whether our version information includes
-	// the go-version.
+	// the go-version, and the rest of our build metadata.
 	verbose bool
 }
 
 // Info is part of the subcommand-API.
 func (v *versionCmd) Info() (string, string) {
-	return "version", `Report upon our version, and exit.`
+	return "version", `Report upon our version, and exit.
+
+The '-verbose' flag also shows the details of the build, which are useful
+within bug reports, and when packaging: the git commit, and the date, from
+which we were built, the version of Go, the platform, and the backends we
+support, such as the state stores, the schemes of feed URLs, our outputs,
+and whether SQLite is available, as it requires cgo.
+`
 }
 
 // Arguments handles our flag-setup.
 func (v *versionCmd) Arguments(f *flag.FlagSet) {
-	f.BoolVar(&v.verbose, "verbose", false, "Show go version the binary was generated with.")
+	f.BoolVar(&v.verbose, "verbose", false, "Show go version the binary was generated with, along with the rest of our build metadata.")
 }
 
 //
@@ -43,9 +62,59 @@ func (v *versionCmd) Arguments(f *flag.FlagSet) {
 //
 func showVersion(verbose bool) {
 	fmt.Fprintf(out, "%s\n", version)
-	if verbose {
-		fmt.Fprintf(out, "Built with %s\n", runtime.Version())
+	if !verbose {
+		return
+	}
+
+	rev, when := buildCommit()
+	sqlite := "enabled"
+	if !cgoEnabled {
+		sqlite = "disabled, built without cgo"
+	}
+
+	fmt.Fprintf(out, "Built with %s\n", runtime.Version())
+	fmt.Fprintf(out, "Commit:     %s\n", rev)
+	fmt.Fprintf(out, "Date:       %s\n", when)
+	fmt.Fprintf(out, "Platform:   %s/%s\n", runtime.GOOS, runtime.GOARCH)
+	fmt.Fprintf(out, "State:      %s\n", strings.Join(withstate.Drivers(), ", "))
+	fmt.Fprintf(out, "Feeds:      %s\n", strings.Join(fetch.Schemes(), ", "))
+	fmt.Fprintf(out, "Outputs:    %s\n", strings.Join(processor.Outputs, ", "))
+	fmt.Fprintf(out, "Delivery:   sendmail, smtp\n")
+	fmt.Fprintf(out, "SQLite:     %s\n", sqlite)
+}
+
+//
+// Find the commit, and date, we were built from, or "unknown".
+//
+func buildCommit() (string, string) {
+
+	rev, when, modified := commit, date, false
+	if info, ok := debug.ReadBuildInfo(); ok {
+		for _, setting := range info.Settings {
+			switch setting.Key {
+			case "vcs.revision":
+				if rev == "" {
+					rev = setting.Value
+				}
+			case "vcs.time":
+				if when == "" {
+					when = setting.Value
+				}
+			case "vcs.modified":
+				modified = setting.Value == "true"
+			}
+		}
+	}
+
+	if rev == "" {
+		rev = "unknown"
+	} else if modified {
+		rev += " (modified)"
+	}
+	if when == "" {
+		when = "unknown"
 	}
+	return rev, when
 }
 
 //
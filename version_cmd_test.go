@@ -4,6 +4,7 @@ import (
 	"bytes"
 	"flag"
 	"runtime"
+	"strings"
 	"testing"
 )
 
@@ -52,7 +53,29 @@ func TestVersionVerbose(t *testing.T) {
 	//
 	s.Execute([]string{})
 
-	if out.(*bytes.Buffer).String() != expected {
+	if !strings.HasPrefix(out.(*bytes.Buffer).String(), expected) {
 		t.Errorf("Expected '%s' received '%s'", expected, out)
 	}
+
+	//
+	// The rest of our build metadata follows.
+	//
+	for _, line := range []string{"Commit:     ", "Platform:   " + runtime.GOOS + "/" + runtime.GOARCH + "\n", "Feeds:      bridge, exec, file, gemini, http, https, plugin\n", "Outputs:    email, jsonl, exec, feed\n"} {
+		if !strings.Contains(out.(*bytes.Buffer).String(), line) {
+			t.Errorf("Expected '%s' within '%s'", line, out)
+		}
+	}
+}
+
+func TestBuildCommit(t *testing.T) {
+	defer func() { commit, date = "", "" }()
+
+	//
+	// Values given at build-time are used.
+	//
+	commit, date = "abc123", "2024-01-02T03:04:05Z"
+	rev, when := buildCommit()
+	if !strings.HasPrefix(rev, "abc123") || when != "2024-01-02T03:04:05Z" {
+		t.Errorf("unexpected build commit: %s %s", rev, when)
+	}
 }
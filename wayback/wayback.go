@@ -0,0 +1,129 @@
+// Package wayback requests that the Wayback Machine, of the Internet
+// Archive, takes a snapshot of the links of feed items.
+//
+// The address of the snapshot is included within the emails we send, so
+// that they remain useful even once the items they link to are gone.
+//
+// Snapshots are requested via the "Save Page Now" API, and the addresses
+// of those we've taken are cached upon the local filesystem, so a link is
+// only archived once.
+package wayback
+
+import (
+	"crypto/sha1"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+// Key is the key, within the custom fields of an item, which holds the
+// address of its snapshot.
+const Key = "rss2email-wayback"
+
+// Wayback is our state-storing structure
+type Wayback struct {
+
+	// base is the address of the Wayback Machine.
+	base string
+
+	// dir is the directory in which we cache the addresses of the
+	// snapshots we've taken.
+	dir string
+
+	// timeout is the time we'll wait for a snapshot to be taken.
+	timeout time.Duration
+
+	// userAgent is the User-Agent header to send.
+	userAgent string
+}
+
+// New creates a new object, which caches snapshots beneath our state
+// directory.
+func New() *Wayback {
+	return &Wayback{
+		base:      "https://web.archive.org",
+		dir:       filepath.Join(configfile.New().StateDirectory(), "wayback"),
+		timeout:   60 * time.Second,
+		userAgent: "rss2email (https://github.com/skx/rss2email)",
+	}
+}
+
+// SetUserAgent updates the User-Agent header we send.
+func (w *Wayback) SetUserAgent(agent string) {
+	w.userAgent = agent
+}
+
+// Snapshot requests a snapshot of the given link, and returns its address.
+func (w *Wayback) Snapshot(link string) (string, error) {
+
+	if !strings.HasPrefix(link, "http://") && !strings.HasPrefix(link, "https://") {
+		return "", fmt.Errorf("'%s' cannot be archived", link)
+	}
+
+	// Look for a cached copy
+	path := filepath.Join(w.dir, fmt.Sprintf("%x", sha1.Sum([]byte(link))))
+
+	data, err := os.ReadFile(path)
+	if err == nil && len(data) > 0 {
+		return string(data), nil
+	}
+
+	snapshot, err := w.save(link)
+	if err != nil {
+		return "", err
+	}
+
+	// Failing to cache isn't fatal.
+	os.MkdirAll(w.dir, os.ModePerm)
+	_ = os.WriteFile(path, []byte(snapshot), 0644)
+
+	return snapshot, nil
+}
+
+// save requests a snapshot of the given link, and returns its address.
+//
+// The snapshot is usually taken before the request completes, and we're
+// told its address.  If we're not, the address of the snapshot closest
+// to the present is returned, which the Wayback Machine resolves.
+func (w *Wayback) save(link string) (string, error) {
+
+	client := &http.Client{
+		Timeout: w.timeout,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	req, err := http.NewRequest("GET", w.base+"/save/"+link, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", w.userAgent)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return "", fmt.Errorf("archiving %s returned status %d", link, resp.StatusCode)
+	}
+
+	for _, header := range []string{"Content-Location", "Location"} {
+		loc := resp.Header.Get(header)
+		if strings.HasPrefix(loc, "/web/") {
+			return w.base + loc, nil
+		}
+		if strings.HasPrefix(loc, w.base+"/web/") {
+			return loc, nil
+		}
+	}
+
+	return w.base + "/web/" + time.Now().UTC().Format("20060102150405") + "/" + link, nil
+}
@@ -0,0 +1,75 @@
+package wayback
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// TestSnapshot requests a snapshot, and ensures its address is cached.
+func TestSnapshot(t *testing.T) {
+
+	count := 0
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		count++
+		switch r.URL.Path {
+		case "/save/https://example.com/post":
+			w.Header().Set("Content-Location", "/web/20240101120000/https://example.com/post")
+		case "/save/https://example.com/redirect":
+			w.Header().Set("Location", "/web/20240101120000/https://example.com/redirect")
+			w.WriteHeader(http.StatusFound)
+		case "/save/https://example.com/pending":
+			w.WriteHeader(http.StatusOK)
+		default:
+			w.WriteHeader(http.StatusTooManyRequests)
+		}
+	}))
+	defer ts.Close()
+
+	w := New()
+	w.base = ts.URL
+	w.dir = t.TempDir()
+
+	// Fetch twice, the second should be cached.
+	for i := 0; i < 2; i++ {
+		snapshot, err := w.Snapshot("https://example.com/post")
+		if err != nil {
+			t.Fatalf("unexpected error: %s", err)
+		}
+		if snapshot != ts.URL+"/web/20240101120000/https://example.com/post" {
+			t.Fatalf("unexpected snapshot: %s", snapshot)
+		}
+	}
+	if count != 1 {
+		t.Fatalf("expected one request, got %d", count)
+	}
+
+	snapshot, err := w.Snapshot("https://example.com/redirect")
+	if err != nil || snapshot != ts.URL+"/web/20240101120000/https://example.com/redirect" {
+		t.Fatalf("unexpected result: %s %v", snapshot, err)
+	}
+
+	// Without an address we link to the closest snapshot.
+	snapshot, err = w.Snapshot("https://example.com/pending")
+	if err != nil || !strings.HasPrefix(snapshot, ts.URL+"/web/") || !strings.HasSuffix(snapshot, "/https://example.com/pending") {
+		t.Fatalf("unexpected result: %s %v", snapshot, err)
+	}
+
+	// Failures are reported, and not cached.
+	for i := 0; i < 2; i++ {
+		_, err = w.Snapshot("https://example.com/busy")
+		if err == nil {
+			t.Fatalf("expected an error")
+		}
+	}
+	if count != 5 {
+		t.Fatalf("expected five requests, got %d", count)
+	}
+
+	// Only web links may be archived.
+	_, err = w.Snapshot("mailto:someone@example.com")
+	if err == nil {
+		t.Fatalf("expected an error")
+	}
+}
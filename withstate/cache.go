@@ -0,0 +1,64 @@
+package withstate
+
+import (
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// cacheQueue returns the name of the queue holding the cached items of the
+// feed with the given URL.
+func cacheQueue(feedURL string) string {
+	return "cache:" + feedURL
+}
+
+// CacheItem stores the raw content of the given item, from the given feed,
+// as it was fetched, so that it may be rendered again later, even once the
+// feed no longer contains it.
+//
+// Cached items are stored much like queued items, and the time at which
+// they were cached is recorded as the time they were queued.
+func CacheItem(feedURL string, feed *gofeed.Feed, item *gofeed.Item) error {
+	return Enqueue(cacheQueue(feedURL), feedURL, feed, item)
+}
+
+// CachedItems returns the items of the feed with the given URL which were
+// cached since the given time, oldest first.
+func CachedItems(feedURL string, since time.Time) ([]QueuedItem, error) {
+
+	items, err := Queued(cacheQueue(feedURL))
+	if err != nil {
+		return nil, err
+	}
+
+	var res []QueuedItem
+	for _, item := range items {
+		if !item.Queued.Before(since) {
+			res = append(res, item)
+		}
+	}
+	return res, nil
+}
+
+// PruneCache removes the cached items of the feed with the given URL which
+// are older than the given age, returning the number removed.
+func PruneCache(feedURL string, age time.Duration) (int, error) {
+
+	items, err := Queued(cacheQueue(feedURL))
+	if err != nil {
+		return 0, err
+	}
+
+	count := 0
+	for _, item := range items {
+		if time.Since(item.Queued) <= age {
+			continue
+		}
+		err = item.Remove()
+		if err != nil {
+			return count, err
+		}
+		count++
+	}
+	return count, nil
+}
@@ -0,0 +1,61 @@
+package withstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestCache(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	url := "https://example.com/rss"
+	feed := &gofeed.Feed{Title: "Example", Link: "https://example.com/"}
+
+	start := time.Now()
+	for _, title := range []string{"One", "Two"} {
+		err := CacheItem(url, feed, &gofeed.Item{GUID: title, Title: title, Content: "<p>" + title + "</p>"})
+		if err != nil {
+			t.Fatalf("failed to cache item: %s", err)
+		}
+		time.Sleep(5 * time.Millisecond)
+	}
+
+	items, err := CachedItems(url, start)
+	if err != nil || len(items) != 2 || items[0].Item.Title != "One" || items[1].Item.Content != "<p>Two</p>" {
+		t.Fatalf("unexpected items: %v %v", items, err)
+	}
+	if items[0].Source().Title != "Example" || items[0].Feed != url {
+		t.Fatalf("unexpected source: %v", items[0].Source())
+	}
+
+	// Only items cached since the given time are returned.
+	items, _ = CachedItems(url, time.Now())
+	if len(items) != 0 {
+		t.Fatalf("unexpected items: %v", items)
+	}
+
+	// The cache is distinct from the queue of the feed.
+	queued, _ := Queued(url)
+	if len(queued) != 0 {
+		t.Fatalf("unexpected queued items: %v", queued)
+	}
+
+	// Pruning removes older items.
+	n, err := PruneCache(url, time.Hour)
+	if err != nil || n != 0 {
+		t.Fatalf("unexpected pruning: %d %v", n, err)
+	}
+	n, err = PruneCache(url, 0)
+	if err != nil || n != 2 {
+		t.Fatalf("unexpected pruning: %d %v", n, err)
+	}
+	items, _ = CachedItems(url, time.Time{})
+	if len(items) != 0 {
+		t.Fatalf("unexpected items: %v", items)
+	}
+}
@@ -0,0 +1,104 @@
+package withstate
+
+import (
+	"encoding/json"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// Checkpoint records how far we got with a feed, so that it may be fetched
+// only if it has changed, and so that the items we processed long ago
+// needn't be considered again.
+type Checkpoint struct {
+
+	// ETag and LastModified hold the validators of the response to
+	// the last successful fetch of the feed.
+	ETag         string `json:"etag,omitempty"`
+	LastModified string `json:"last_modified,omitempty"`
+
+	// Title and Link are those of the feed, which are used if it
+	// hasn't changed.
+	Title string `json:"title,omitempty"`
+	Link  string `json:"link,omitempty"`
+
+	// Newest holds the date of the newest item of the feed, once all
+	// of its items were processed.
+	Newest time.Time `json:"newest"`
+}
+
+// FeedCheckpoint returns the checkpoint of the feed with the given URL,
+// which is empty if we've not recorded one.
+func FeedCheckpoint(url string) (Checkpoint, error) {
+
+	var c Checkpoint
+
+	val, err := store().Meta("checkpoint:" + url)
+	if err != nil || val == "" {
+		return c, err
+	}
+	err = json.Unmarshal([]byte(val), &c)
+	return c, err
+}
+
+// SetCheckpoint records the checkpoint of the feed with the given URL.
+func SetCheckpoint(url string, c Checkpoint) error {
+
+	data, err := json.Marshal(c)
+	if err != nil {
+		return err
+	}
+	return store().SetMeta("checkpoint:"+url, string(data))
+}
+
+// RecordFeedItems records the given items as those of the feed with the
+// given URL, so that their seen records may be refreshed by
+// RefreshFeedItems whilst the feed is unchanged, and we receive none of
+// its items.
+func RecordFeedItems(url string, items []*gofeed.Item, byLink bool) error {
+
+	seen := make(map[string]string)
+	for _, xp := range items {
+		item := FeedItem{Item: xp, ByLink: byLink}
+		seen[item.id()] = xp.Link
+	}
+
+	data, err := json.Marshal(seen)
+	if err != nil {
+		return err
+	}
+	return store().SetMeta("items:"+url, string(data))
+}
+
+// RefreshFeedItems refreshes the seen records of the items recorded for
+// the feed with the given URL by RecordFeedItems, so that they aren't
+// pruned whilst the feed is unchanged.
+//
+// Only the records of items we've seen are refreshed.
+func RefreshFeedItems(url string) error {
+
+	val, err := store().Meta("items:" + url)
+	if err != nil || val == "" {
+		return err
+	}
+
+	var items map[string]string
+	err = json.Unmarshal([]byte(val), &items)
+	if err != nil {
+		return err
+	}
+
+	for id, link := range items {
+		seen, err := store().Seen(id)
+		if err != nil {
+			return err
+		}
+		if seen {
+			err = store().MarkSeen(id, link)
+			if err != nil {
+				return err
+			}
+		}
+	}
+	return nil
+}
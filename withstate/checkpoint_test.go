@@ -0,0 +1,31 @@
+package withstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestCheckpoint(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	url := "https://example.com/rss"
+
+	c, err := FeedCheckpoint(url)
+	if err != nil || c != (Checkpoint{}) {
+		t.Fatalf("unexpected checkpoint of an unknown feed: %v %v", c, err)
+	}
+
+	saved := Checkpoint{ETag: `"abc"`, Title: "Example", Newest: time.Date(2024, 1, 1, 12, 0, 0, 0, time.UTC)}
+	err = SetCheckpoint(url, saved)
+	if err != nil {
+		t.Fatalf("failed to set checkpoint: %s", err)
+	}
+	c, err = FeedCheckpoint(url)
+	if err != nil || c.ETag != saved.ETag || c.Title != saved.Title || !c.Newest.Equal(saved.Newest) {
+		t.Fatalf("unexpected checkpoint: %v %v", c, err)
+	}
+}
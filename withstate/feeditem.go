@@ -6,18 +6,16 @@
 package withstate
 
 import (
-	"crypto/sha1"
 	"fmt"
-	"io/ioutil"
 	"net/url"
 	"os"
-	"os/user"
 	"path/filepath"
 	"strings"
 	"time"
 
 	"github.com/PuerkitoBio/goquery"
 	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
 )
 
 // statePrefix holds the prefix directory, and is used to
@@ -30,38 +28,25 @@ type FeedItem struct {
 
 	// Wrapped structure
 	*gofeed.Item
+
+	// ByLink is true if the item is identified by its link, rather
+	// than its GUID, as some feeds change the GUIDs of their items.
+	ByLink bool
 }
 
 // IsNew reports whether this particular feed-item is new.
+//
+// If the state can't be read the item is treated as having been seen,
+// it will be considered again upon the next run.
 func (item *FeedItem) IsNew() bool {
 
-	file := item.path()
-	if _, err := os.Stat(file); os.IsNotExist(err) {
-		return true
-	}
-	return false
+	seen, err := store().Seen(item.id())
+	return err == nil && !seen
 }
 
 // RecordSeen updates this item, to record the fact that it has been seen.
 func (item *FeedItem) RecordSeen() {
-
-	// Get the file-path
-	file := item.path()
-
-	if _, err := os.Stat(file); !os.IsNotExist(err) {
-		t := time.Now()
-		_ = os.Chtimes(file, t, t)
-		return
-	}
-
-	// Ensure the parent directory exists
-	os.MkdirAll(filepath.Dir(file), os.ModePerm)
-
-	// We'll write out the link to the item in the file
-	d1 := []byte(item.Link)
-
-	// Write it out
-	_ = ioutil.WriteFile(file, d1, 0644)
+	_ = store().MarkSeen(item.id(), item.Link)
 }
 
 // RawContent provides content or fallback to description
@@ -158,38 +143,34 @@ func stateDirectory() string {
 		return statePrefix
 	}
 
-	// Default to using $HOME
-	home := os.Getenv("HOME")
-
-	if home == "" {
-		// Get the current user, and use their home if possible.
-		usr, err := user.Current()
-		if err == nil {
-			home = usr.HomeDir
-		}
-	}
-
 	// Store the path for the future, and return it.
-	statePrefix = filepath.Join(home, ".rss2email", "seen")
+	statePrefix = filepath.Join(configfile.New().StateDirectory(), "seen")
 	return statePrefix
 }
 
-// path returns an appropriate marker-file, which is used to record
-// the seen vs. unseen state of a particular entry.
-func (item *FeedItem) path() string {
+// Identity returns the value which identifies this item, which is its
+// GUID, or its link if it has none, or if it is identified by its link.
+func (item *FeedItem) Identity() string {
 
-	guid := item.GUID
-	if guid == "" {
-		guid = item.Link
+	if item.GUID == "" || (item.ByLink && item.Link != "") {
+		return item.Link
 	}
+	return item.GUID
+}
 
-	// Hash the item GUID and convert to hexadecimal
-	hexSha1 := fmt.Sprintf("%x", sha1.Sum([]byte(guid)))
+// id returns the identity of this item, hashed, which is the key we use
+// to record whether it has been seen.
+func (item *FeedItem) id() string {
 
-	// Finally join the path
-	out := filepath.Join(stateDirectory(), hexSha1)
-	return out
+	// Hash the item GUID and convert to hexadecimal
+	return hash(item.Identity())
+}
 
+// path returns an appropriate marker-file, which is used to record
+// the seen vs. unseen state of a particular entry when our state is
+// stored upon the filesystem.
+func (item *FeedItem) path() string {
+	return filepath.Join(stateDirectory(), item.id())
 }
 
 // isSha1File returns true if a regular file has a name that looks
@@ -218,46 +199,8 @@ func isSha1File(fi os.FileInfo) bool {
 
 // PruneStateFiles removes no-longer-needed state files
 // It returns the number of files pruned and a slice of errors encountered.
+//
+// Items are pruned once they haven't been seen for four days.
 func PruneStateFiles() (int, []error) {
-
-	stateDirPath := stateDirectory()
-
-	err := os.MkdirAll(stateDirPath, os.ModePerm)
-	if err != nil {
-		return 0, []error{err}
-	}
-
-	stateDir, err := os.Open(stateDirPath)
-	if err != nil {
-		err = fmt.Errorf("failed to open state-file directory: %s", err.Error())
-		return 0, []error{err}
-	}
-
-	fileInfos, err := stateDir.Readdir(0)
-	if err != nil {
-		err = fmt.Errorf("failed to list state files: %s", err.Error())
-		return 0, []error{err}
-	}
-
-	errors := make([]error, 0)
-	prunedCount := 0
-
-	// Prune state files older than 4 days.
-	for _, fi := range fileInfos {
-		if time.Since(fi.ModTime()) > (4*24)*time.Hour {
-			if !isSha1File(fi) {
-				continue
-			}
-
-			err := os.Remove(filepath.Join(stateDirPath, fi.Name()))
-			if err == nil {
-				prunedCount++
-			} else {
-				err = fmt.Errorf("failed to remove state file: %s", err.Error())
-				errors = append(errors, err)
-			}
-		}
-	}
-
-	return prunedCount, errors
+	return store().Prune((4 * 24) * time.Hour)
 }
@@ -1,7 +1,6 @@
 package withstate
 
 import (
-	"io/ioutil"
 	"os"
 	"path/filepath"
 	"testing"
@@ -14,7 +13,7 @@ import (
 func TestBasics(t *testing.T) {
 
 	// Create an item
-	x := &FeedItem{&gofeed.Item{}}
+	x := &FeedItem{Item: &gofeed.Item{}}
 
 	// Give it an identity
 	x.GUID = "steve-test"
@@ -46,8 +45,8 @@ func TestCollision(t *testing.T) {
 	// So we want to have two feed items with the same
 	// GUID.  They should map to the same file, so we
 	// can confirm they would be treated as identical
-	a := &FeedItem{&gofeed.Item{}}
-	b := &FeedItem{&gofeed.Item{}}
+	a := &FeedItem{Item: &gofeed.Item{}}
+	b := &FeedItem{Item: &gofeed.Item{}}
 
 	a.GUID = "steve"
 	b.GUID = "steve"
@@ -76,8 +75,8 @@ func TestCollisionMissingHome(t *testing.T) {
 	// So we want to have two feed items with the same
 	// GUID.  They should map to the same file, so we
 	// can confirm they would be treated as identical
-	a := &FeedItem{&gofeed.Item{}}
-	b := &FeedItem{&gofeed.Item{}}
+	a := &FeedItem{Item: &gofeed.Item{}}
+	b := &FeedItem{Item: &gofeed.Item{}}
 
 	a.GUID = "steve"
 	b.GUID = "steve"
@@ -139,7 +138,7 @@ func TestPrune(t *testing.T) {
 	}
 
 	// Create a temporary directory
-	dir, err := ioutil.TempDir("", "prune")
+	dir, err := os.MkdirTemp("", "prune")
 	if err != nil {
 		t.Fatalf("failed to create temporary directory:%s", err)
 	}
@@ -151,7 +150,7 @@ func TestPrune(t *testing.T) {
 		out := filepath.Join(dir, tst.name)
 
 		// Write bogus content
-		err = ioutil.WriteFile(out, []byte(tst.name), 0666)
+		err = os.WriteFile(out, []byte(tst.name), 0666)
 		if err != nil {
 			t.Fatalf("failed to write temporary file : %s", err)
 		}
@@ -0,0 +1,117 @@
+package withstate
+
+import (
+	"encoding/json"
+	"strings"
+)
+
+// maxFingerprints is the largest number of fingerprints we remember for a
+// feed.
+const maxFingerprints = 1000
+
+// fingerprint associates the link, and title, of an item with its identity.
+type fingerprint struct {
+
+	// Print holds the hash of the link and title of the item.
+	Print string `json:"print"`
+
+	// ID holds the identity of the item.
+	ID string `json:"id"`
+}
+
+// Fingerprints holds the links, and titles, of the items of a feed which
+// we've seen, along with their identities, so that items whose GUIDs have
+// changed may be recognised.
+type Fingerprints struct {
+
+	// Runs holds the number of consecutive runs in which items of
+	// the feed were seen again with new GUIDs.
+	Runs int `json:"runs"`
+
+	// Items holds the fingerprints, oldest first.
+	Items []fingerprint `json:"items"`
+
+	// url is the URL of the feed, and index maps each fingerprint to
+	// its identity.
+	url   string
+	index map[string]string
+}
+
+// FeedFingerprints returns the fingerprints of the items of the feed with
+// the given URL, as recorded by Save.
+func FeedFingerprints(url string) (*Fingerprints, error) {
+
+	f := &Fingerprints{url: url, index: make(map[string]string)}
+
+	val, err := store().Meta("fingerprints:" + url)
+	if err != nil || val == "" {
+		return f, err
+	}
+	err = json.Unmarshal([]byte(val), f)
+	for _, fp := range f.Items {
+		f.index[fp.Print] = fp.ID
+	}
+	return f, err
+}
+
+// print returns the fingerprint of the given item, the hash of its link
+// and title, or the empty string if it has no link, as its title alone
+// may well be shared by distinct items.
+func (item *FeedItem) print() string {
+
+	link := strings.TrimSpace(item.Link)
+	if link == "" {
+		return ""
+	}
+	return hash(link + "\n" + strings.TrimSpace(item.Title))
+}
+
+// Rotated returns true if an item with the same link, and title, as the
+// given item was seen with a different identity, which is the case if the
+// feed changed its GUID.
+func (f *Fingerprints) Rotated(item *FeedItem) bool {
+
+	id, ok := f.index[item.print()]
+	return ok && id != item.id()
+}
+
+// Add records the fingerprint of the given item.
+func (f *Fingerprints) Add(item *FeedItem) {
+
+	print := item.print()
+	if print == "" || f.index[print] == item.id() {
+		return
+	}
+
+	f.index[print] = item.id()
+	f.Items = append(f.Items, fingerprint{Print: print, ID: item.id()})
+}
+
+// Save records the fingerprints, forgetting the oldest if there are too
+// many.
+func (f *Fingerprints) Save() error {
+
+	// Keep only the most recent identity of each item.
+	var items []fingerprint
+	kept := make(map[string]bool)
+	for i := len(f.Items) - 1; i >= 0; i-- {
+		fp := f.Items[i]
+		if !kept[fp.Print] {
+			kept[fp.Print] = true
+			items = append(items, fp)
+		}
+	}
+	for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+		items[i], items[j] = items[j], items[i]
+	}
+	if len(items) > maxFingerprints {
+		items = items[len(items)-maxFingerprints:]
+	}
+	f.Items = items
+
+	data, err := json.Marshal(f)
+	if err != nil {
+		return err
+	}
+	return store().SetMeta("fingerprints:"+f.url, string(data))
+}
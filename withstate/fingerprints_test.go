@@ -0,0 +1,72 @@
+package withstate
+
+import (
+	"fmt"
+	"testing"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestFingerprints(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	url := "https://example.com/rss"
+
+	f, err := FeedFingerprints(url)
+	if err != nil || f.Runs != 0 || len(f.Items) != 0 {
+		t.Fatalf("unexpected fingerprints of an unknown feed: %v %v", f, err)
+	}
+
+	item := &FeedItem{Item: &gofeed.Item{GUID: "1", Link: "https://example.com/1", Title: "One"}}
+	f.Add(item)
+	f.Add(&FeedItem{Item: &gofeed.Item{GUID: "2", Title: "No link"}})
+	f.Runs = 1
+	if err = f.Save(); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+
+	f, err = FeedFingerprints(url)
+	if err != nil || f.Runs != 1 || len(f.Items) != 1 {
+		t.Fatalf("unexpected fingerprints: %v %v", f, err)
+	}
+
+	// The same item isn't rotated, the same link and title with another
+	// GUID is, and items without links never are.
+	if f.Rotated(item) {
+		t.Fatalf("the same item was rotated")
+	}
+	rotated := &FeedItem{Item: &gofeed.Item{GUID: "1b", Link: "https://example.com/1", Title: "One"}}
+	if !f.Rotated(rotated) {
+		t.Fatalf("the item with a new GUID wasn't rotated")
+	}
+	if f.Rotated(&FeedItem{Item: &gofeed.Item{GUID: "1c", Link: "https://example.com/1", Title: "Another"}}) {
+		t.Fatalf("an item with a new title was rotated")
+	}
+	if f.Rotated(&FeedItem{Item: &gofeed.Item{GUID: "3", Title: "No link"}}) {
+		t.Fatalf("an item without a link was rotated")
+	}
+
+	// Only the latest identity is kept.
+	f.Add(rotated)
+	if f.Rotated(rotated) || !f.Rotated(item) {
+		t.Fatalf("the new identity wasn't recorded")
+	}
+	for i := 0; i < maxFingerprints+10; i++ {
+		f.Add(&FeedItem{Item: &gofeed.Item{GUID: fmt.Sprintf("x%d", i), Link: fmt.Sprintf("https://example.com/x%d", i)}})
+	}
+	if err = f.Save(); err != nil {
+		t.Fatalf("failed to save: %s", err)
+	}
+	if len(f.Items) != maxFingerprints {
+		t.Fatalf("unexpected number of fingerprints: %d", len(f.Items))
+	}
+
+	// Items identified by their links are.
+	byLink := &FeedItem{Item: &gofeed.Item{GUID: "new", Link: "https://example.com/1"}, ByLink: true}
+	if byLink.id() != (&FeedItem{Item: &gofeed.Item{GUID: "other", Link: "https://example.com/1"}, ByLink: true}).id() {
+		t.Fatalf("items weren't identified by their links")
+	}
+}
@@ -0,0 +1,231 @@
+package withstate
+
+import (
+	"encoding/json"
+	"strconv"
+	"time"
+)
+
+// weight is the weight given to the most recent fetch when updating the
+// moving averages of our failure rate, and latency.
+const weight = 0.2
+
+// Fetch describes a single attempt to fetch a feed.
+type Fetch struct {
+
+	// Latency is the time the fetch took.
+	Latency time.Duration
+
+	// Err holds the error which caused the fetch to fail, if any.
+	Err error
+
+	// NewItems holds the number of items which were new.
+	NewItems int
+
+	// Newest holds the publication date of the newest item in the
+	// feed, if known.
+	Newest time.Time
+}
+
+// Health holds the record of the fetches of a feed, which is used to
+// decide whether the feed is still alive.
+type Health struct {
+
+	// Since holds the time of the first fetch we recorded.
+	Since time.Time `json:"since"`
+
+	// Fetches and Failures hold the number of fetches, and of those
+	// which failed, while Consecutive holds the number of failures
+	// since the last success.
+	Fetches     int `json:"fetches"`
+	Failures    int `json:"failures"`
+	Consecutive int `json:"consecutive"`
+
+	// FailureRate is a moving average of the proportion of fetches
+	// which failed, so recent fetches count most.
+	FailureRate float64 `json:"failure_rate"`
+
+	// Latency is a moving average of the time successful fetches took.
+	Latency time.Duration `json:"latency"`
+
+	// LastFetch and LastSuccess hold the times of the most recent
+	// fetch, and successful fetch, and LastError the error of the
+	// most recent failure.
+	LastFetch   time.Time `json:"last_fetch"`
+	LastSuccess time.Time `json:"last_success"`
+	LastError   string    `json:"last_error,omitempty"`
+
+	// NewItems holds the number of new items we've discovered.
+	NewItems int `json:"new_items"`
+
+	// LastItem holds the publication date of the newest item we've
+	// seen, or the time we discovered a new item if the feed doesn't
+	// date them.
+	LastItem time.Time `json:"last_item"`
+}
+
+// FeedHealth returns the health of the feed with the given URL, which has
+// a zero Since if we've not fetched it.
+func FeedHealth(url string) (Health, error) {
+
+	var h Health
+
+	val, err := store().Meta("health:" + url)
+	if err != nil || val == "" {
+		return h, err
+	}
+	err = json.Unmarshal([]byte(val), &h)
+	return h, err
+}
+
+// RecordFetch updates the health of the feed with the given URL, with the
+// result of a fetch which has just completed.
+func RecordFetch(url string, f Fetch) error {
+
+	h, err := FeedHealth(url)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	if h.Since.IsZero() {
+		h.Since = now
+	}
+	h.Fetches++
+	h.LastFetch = now
+
+	failed := 0.0
+	if f.Err != nil {
+		failed = 1.0
+		h.Failures++
+		h.Consecutive++
+		h.LastError = f.Err.Error()
+	} else {
+		h.Consecutive = 0
+		h.LastSuccess = now
+		if h.Latency == 0 {
+			h.Latency = f.Latency
+		} else {
+			h.Latency = time.Duration((1-weight)*float64(h.Latency) + weight*float64(f.Latency))
+		}
+	}
+	if h.Fetches == 1 {
+		h.FailureRate = failed
+	} else {
+		h.FailureRate = (1-weight)*h.FailureRate + weight*failed
+	}
+
+	h.NewItems += f.NewItems
+	if f.Newest.After(h.LastItem) && !f.Newest.After(now) {
+		h.LastItem = f.Newest
+	} else if f.NewItems > 0 && f.Newest.IsZero() {
+		h.LastItem = now
+	}
+
+	data, err := json.Marshal(h)
+	if err != nil {
+		return err
+	}
+	return store().SetMeta("health:"+url, string(data))
+}
+
+// Known returns true if we've recorded a fetch of the feed.
+func (h Health) Known() bool {
+	return !h.Since.IsZero()
+}
+
+// Silence returns the time since the feed last had a new item, or since
+// we first fetched it if it has never had one.
+func (h Health) Silence(now time.Time) time.Duration {
+	if h.LastItem.IsZero() {
+		return now.Sub(h.Since)
+	}
+	return now.Sub(h.LastItem)
+}
+
+// Dead returns true if the feed is probably dead, because it has had no new
+// items, or has failed every fetch, for longer than the given time.
+func (h Health) Dead(now time.Time, silence time.Duration) bool {
+
+	if !h.Known() {
+		return false
+	}
+	if h.Silence(now) > silence {
+		return true
+	}
+	if h.Consecutive > 0 {
+		last := h.LastSuccess
+		if last.IsZero() {
+			last = h.Since
+		}
+		return now.Sub(last) > silence
+	}
+	return false
+}
+
+// PerMonth returns the average number of new items each month, since we
+// first fetched the feed.
+func (h Health) PerMonth(now time.Time) float64 {
+
+	months := now.Sub(h.Since).Hours() / (24 * 30)
+	if months < 1 {
+		months = 1
+	}
+	return float64(h.NewItems) / months
+}
+
+// Score returns a score, from 0 to 100, describing the health of the feed,
+// which is reduced by failures, slow fetches, and silence.
+func (h Health) Score(now time.Time) int {
+
+	if !h.Known() {
+		return 0
+	}
+
+	score := 100.0
+
+	// Failures cost up to half of our score.
+	score -= 50 * h.FailureRate
+	if h.Consecutive >= 5 {
+		score -= 10
+	}
+
+	// As does a slow server.
+	switch {
+	case h.Latency > 15*time.Second:
+		score -= 20
+	case h.Latency > 5*time.Second:
+		score -= 10
+	}
+
+	// And a lack of new items.
+	days := h.Silence(now).Hours() / 24
+	switch {
+	case days > 180:
+		score -= 30
+	case days > 90:
+		score -= 20
+	case days > 30:
+		score -= 10
+	}
+
+	if score < 0 {
+		return 0
+	}
+	return int(score + 0.5)
+}
+
+// CatchUp returns true if the items of the feed with the given URL should
+// be recorded as seen, without being sent, when it is next fetched.
+//
+// This is used when an archived feed is restored, as the state of its
+// items may have been pruned while it wasn't being fetched.
+func CatchUp(url string) (bool, error) {
+	val, err := store().Meta("catch-up:" + url)
+	return val == "true", err
+}
+
+// SetCatchUp sets, or clears, the flag returned by CatchUp.
+func SetCatchUp(url string, on bool) error {
+	return store().SetMeta("catch-up:"+url, strconv.FormatBool(on))
+}
@@ -0,0 +1,66 @@
+package withstate
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestHealth(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	url := "https://example.com/rss"
+	now := time.Now()
+
+	h, err := FeedHealth(url)
+	if err != nil || h.Known() || h.Score(now) != 0 || h.Dead(now, time.Hour) {
+		t.Fatalf("unexpected health of an unknown feed: %v %v", h, err)
+	}
+
+	// A healthy feed.
+	newest := now.Add(-24 * time.Hour)
+	err = RecordFetch(url, Fetch{Latency: time.Second, NewItems: 3, Newest: newest})
+	if err != nil {
+		t.Fatalf("failed to record fetch: %s", err)
+	}
+	h, _ = FeedHealth(url)
+	if !h.Known() || h.Fetches != 1 || h.NewItems != 3 || !h.LastItem.Equal(newest) || h.Latency != time.Second {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+	if h.Score(now) != 100 || h.Dead(now, 30*24*time.Hour) {
+		t.Fatalf("unexpected score %d", h.Score(now))
+	}
+
+	// Which begins to fail.
+	for i := 0; i < 5; i++ {
+		RecordFetch(url, Fetch{Latency: time.Minute, Err: errors.New("404 Not Found")})
+	}
+	h, _ = FeedHealth(url)
+	if h.Consecutive != 5 || h.Failures != 5 || h.LastError != "404 Not Found" || h.Latency != time.Second {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+	if score := h.Score(now); score >= 70 || score <= 0 {
+		t.Fatalf("unexpected score of failing feed: %d", score)
+	}
+
+	// Feeds are dead if they've had no new items, or failed, for too
+	// long.
+	later := now.Add(100 * 24 * time.Hour)
+	if !h.Dead(later, 90*24*time.Hour) || h.Dead(later, 120*24*time.Hour) {
+		t.Fatalf("unexpected dead status")
+	}
+	if h.Score(later) >= h.Score(now) {
+		t.Fatalf("silence didn't reduce the score")
+	}
+
+	// Dates in the future are ignored.
+	RecordFetch(url, Fetch{NewItems: 1, Newest: now.Add(time.Hour)})
+	h, _ = FeedHealth(url)
+	if !h.LastItem.Equal(newest) || h.Consecutive != 0 {
+		t.Fatalf("unexpected health: %+v", h)
+	}
+}
@@ -0,0 +1,176 @@
+package withstate
+
+import (
+	"sync"
+	"time"
+)
+
+// memoryItem records a seen item, within a memoryStore.
+type memoryItem struct {
+	link string
+	seen time.Time
+}
+
+// memoryLock records a lock, within a memoryStore.
+type memoryLock struct {
+	owner   string
+	expires time.Time
+}
+
+// memoryStore keeps our state in memory, so it is lost when we exit.
+//
+// It is intended for testing, and for one-off runs whose state doesn't
+// matter.
+type memoryStore struct {
+
+	// mutex protects our state.
+	mutex sync.Mutex
+
+	items  map[string]memoryItem
+	meta   map[string]string
+	queues map[string]map[string][]byte
+	locks  map[string]memoryLock
+}
+
+// NewMemoryStore creates a store which keeps our state in memory, for
+// use via Use.
+func NewMemoryStore() Store {
+	return &memoryStore{
+		items:  make(map[string]memoryItem),
+		meta:   make(map[string]string),
+		queues: make(map[string]map[string][]byte),
+		locks:  make(map[string]memoryLock),
+	}
+}
+
+// Seen is part of the Store interface.
+func (m *memoryStore) Seen(id string) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, ok := m.items[id]
+	return ok, nil
+}
+
+// MarkSeen is part of the Store interface.
+func (m *memoryStore) MarkSeen(id string, link string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	// Like the file store, we keep the link first recorded.
+	item, ok := m.items[id]
+	if !ok {
+		item.link = link
+	}
+	item.seen = time.Now()
+	m.items[id] = item
+	return nil
+}
+
+// Prune is part of the Store interface.
+func (m *memoryStore) Prune(age time.Duration) (int, []error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	n := 0
+	for id, item := range m.items {
+		if time.Since(item.seen) > age {
+			delete(m.items, id)
+			n++
+		}
+	}
+	return n, nil
+}
+
+// Iterate is part of the Store interface.
+//
+// The items are copied first, so the function may use the store.
+func (m *memoryStore) Iterate(fn func(id string, link string, seen time.Time) error) error {
+
+	m.mutex.Lock()
+	items := make(map[string]memoryItem, len(m.items))
+	for id, item := range m.items {
+		items[id] = item
+	}
+	m.mutex.Unlock()
+
+	for id, item := range items {
+		if err := fn(id, item.link, item.seen); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Meta is part of the Store interface.
+func (m *memoryStore) Meta(key string) (string, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	return m.meta[key], nil
+}
+
+// SetMeta is part of the Store interface.
+func (m *memoryStore) SetMeta(key string, value string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.meta[key] = value
+	return nil
+}
+
+// Push is part of the Store interface.
+func (m *memoryStore) Push(queue string, id string, data []byte) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if m.queues[queue] == nil {
+		m.queues[queue] = make(map[string][]byte)
+	}
+	m.queues[queue][id] = append([]byte(nil), data...)
+	return nil
+}
+
+// Entries is part of the Store interface.
+func (m *memoryStore) Entries(queue string) (map[string][]byte, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	entries := make(map[string][]byte)
+	for id, data := range m.queues[queue] {
+		entries[id] = append([]byte(nil), data...)
+	}
+	return entries, nil
+}
+
+// Pop is part of the Store interface.
+func (m *memoryStore) Pop(queue string, id string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.queues[queue], id)
+	return nil
+}
+
+// Lock is part of the Store interface.
+func (m *memoryStore) Lock(name string, owner string, ttl time.Duration) (bool, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if l, ok := m.locks[name]; ok && l.owner != owner && time.Now().Before(l.expires) {
+		return false, nil
+	}
+	m.locks[name] = memoryLock{owner: owner, expires: time.Now().Add(ttl)}
+	return true, nil
+}
+
+// Unlock is part of the Store interface.
+func (m *memoryStore) Unlock(name string, owner string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if l, ok := m.locks[name]; ok && l.owner == owner {
+		delete(m.locks, name)
+	}
+	return nil
+}
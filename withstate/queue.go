@@ -0,0 +1,113 @@
+package withstate
+
+import (
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+)
+
+// QueuedItem is an item which has been held back, rather than sent when
+// it was discovered, along with the details of the feed it came from.
+type QueuedItem struct {
+
+	// Feed holds the URL of the feed the item came from.
+	Feed string `json:"feed"`
+
+	// FeedTitle and FeedLink describe the feed, at the time the
+	// item was queued.
+	FeedTitle string `json:"feed_title"`
+	FeedLink  string `json:"feed_link"`
+
+	// Item is the item itself.
+	Item *gofeed.Item `json:"item"`
+
+	// Queued holds the time at which the item was queued.
+	Queued time.Time `json:"queued"`
+
+	// queue and id identify our entry within the store.
+	queue string
+	id    string
+}
+
+// Enqueue stores the given item, from the given feed, upon the named
+// queue so that it may be sent later.  Queues are usually named for the
+// URL of the feed whose items they hold.
+func Enqueue(queue string, feedURL string, feed *gofeed.Feed, item *gofeed.Item) error {
+
+	entry := QueuedItem{
+		Feed:      feedURL,
+		FeedTitle: feed.Title,
+		FeedLink:  feed.Link,
+		Item:      item,
+		Queued:    time.Now(),
+	}
+
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return err
+	}
+
+	return store().Push(queue, (&FeedItem{Item: item}).id(), data)
+}
+
+// Queued returns the items of the named queue, oldest first.
+func Queued(queue string) ([]QueuedItem, error) {
+
+	entries, err := store().Entries(queue)
+	if err != nil {
+		return nil, err
+	}
+
+	var items []QueuedItem
+	for id, data := range entries {
+
+		var entry QueuedItem
+		err = json.Unmarshal(data, &entry)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read queued item %s: %s", id, err)
+		}
+		entry.queue = queue
+		entry.id = id
+		items = append(items, entry)
+	}
+
+	sort.SliceStable(items, func(i, j int) bool {
+		return items[i].Queued.Before(items[j].Queued)
+	})
+	return items, nil
+}
+
+// Remove removes the given item from the queue, once it has been sent.
+func (q QueuedItem) Remove() error {
+	return store().Pop(q.queue, q.id)
+}
+
+// Source returns a feed containing the details of the feed the item came
+// from, which is sufficient to render it.
+func (q QueuedItem) Source() *gofeed.Feed {
+	return &gofeed.Feed{Title: q.FeedTitle, Link: q.FeedLink, FeedLink: q.Feed}
+}
+
+// LastSent returns the time at which an email was last sent for the named
+// queue, as recorded by RecordSent, or the zero time if none has been.
+func LastSent(queue string) time.Time {
+
+	val, err := store().Meta("sent:" + queue)
+	if err != nil || val == "" {
+		return time.Time{}
+	}
+
+	t, err := time.Parse(time.RFC3339Nano, val)
+	if err != nil {
+		return time.Time{}
+	}
+	return t
+}
+
+// RecordSent records that an email has just been sent for the named queue.
+func RecordSent(queue string) error {
+	return store().SetMeta("sent:"+queue, time.Now().Format(time.RFC3339Nano))
+}
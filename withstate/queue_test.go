@@ -0,0 +1,90 @@
+package withstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestQueue(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	// Nothing is queued initially.
+	items, err := Queued("https://example.com/rss")
+	if err != nil || len(items) != 0 {
+		t.Fatalf("unexpected queue: %v %s", items, err)
+	}
+
+	feed := &gofeed.Feed{Title: "Example", Link: "https://example.com/"}
+	for _, title := range []string{"One", "Two"} {
+		err = Enqueue("https://example.com/rss", "https://example.com/rss", feed, &gofeed.Item{Title: title, GUID: title})
+		if err != nil {
+			t.Fatalf("failed to queue item: %s", err)
+		}
+	}
+
+	// Queueing an item twice replaces it.
+	err = Enqueue("https://example.com/rss", "https://example.com/rss", feed, &gofeed.Item{Title: "Two", GUID: "Two"})
+	if err != nil {
+		t.Fatalf("failed to queue item: %s", err)
+	}
+
+	// Other feeds have their own queues.
+	items, _ = Queued("https://example.com/other")
+	if len(items) != 0 {
+		t.Fatalf("unexpected queue: %v", items)
+	}
+
+	items, err = Queued("https://example.com/rss")
+	if err != nil {
+		t.Fatalf("failed to read queue: %s", err)
+	}
+	if len(items) != 2 || items[0].Item.Title != "One" || items[1].Item.Title != "Two" {
+		t.Fatalf("unexpected queue: %v", items)
+	}
+	if items[0].Source().Title != "Example" {
+		t.Fatalf("unexpected feed: %v", items[0].Source())
+	}
+
+	// Remove the first item.
+	err = items[0].Remove()
+	if err != nil {
+		t.Fatalf("failed to remove item: %s", err)
+	}
+	items, _ = Queued("https://example.com/rss")
+	if len(items) != 1 || items[0].Item.Title != "Two" {
+		t.Fatalf("unexpected queue: %v", items)
+	}
+}
+
+func TestLastSent(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	if !LastSent("https://example.com/rss").IsZero() {
+		t.Fatalf("unexpected time of last email")
+	}
+
+	before := time.Now().Add(-time.Second)
+	err := RecordSent("https://example.com/rss")
+	if err != nil {
+		t.Fatalf("failed to record email: %s", err)
+	}
+	if LastSent("https://example.com/rss").Before(before) {
+		t.Fatalf("time of last email was not recorded")
+	}
+	if !LastSent("https://example.com/other").IsZero() {
+		t.Fatalf("unexpected time of last email for another feed")
+	}
+
+	// The marker isn't mistaken for a queued item.
+	items, err := Queued("https://example.com/rss")
+	if err != nil || len(items) != 0 {
+		t.Fatalf("unexpected queue: %v %s", items, err)
+	}
+}
@@ -0,0 +1,234 @@
+package withstate
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"github.com/skx/rss2email/configfile"
+)
+
+// unlockScript deletes a lock only if it is held by the given owner, as a
+// single atomic step.
+var unlockScript = redis.NewScript(`if redis.call('get', KEYS[1]) == ARGV[1] then return redis.call('del', KEYS[1]) end return 0`)
+
+// seenTTL is the time for which we remember seen items in Redis, which
+// matches the time after which we prune them from the filesystem.
+const seenTTL = (4 * 24) * time.Hour
+
+// redisStore stores our state in Redis, so that it may be shared by
+// several instances.
+//
+// Seen items are stored as keys which expire, so no pruning is needed.
+// Queues are hashes, and other values are plain keys.
+type redisStore struct {
+
+	// client is our connection to the server, which reconnects as
+	// necessary.
+	client *redis.Client
+
+	// addr is the address of the server.
+	addr string
+
+	// prefix is prepended to each of our keys.
+	prefix string
+}
+
+func init() {
+	for _, scheme := range []string{"redis", "rediss"} {
+		Register(scheme, func(location string) (Store, error) {
+			u, err := url.Parse(location)
+			if err != nil {
+				return nil, fmt.Errorf("invalid state store '%s': %s", location, err)
+			}
+			return newRedisStore(u)
+		})
+	}
+}
+
+// newRedisStore creates a store using the server at the given URL, which
+// has the form "redis://[[user]:password@]host[:port][/db][?prefix=..]".
+//
+// Our keys are prefixed with "rss2email:", and the name of the profile
+// if one is in use, unless another prefix is given.
+func newRedisStore(u *url.URL) (*redisStore, error) {
+
+	opts := &redis.Options{
+		Addr:        u.Host,
+		DialTimeout: 10 * time.Second,
+		ReadTimeout: 30 * time.Second,
+
+		// The second version of the protocol is understood by
+		// every server.
+		Protocol:        2,
+		DisableIdentity: true,
+	}
+	if u.Port() == "" {
+		opts.Addr = net.JoinHostPort(u.Hostname(), "6379")
+	}
+	if u.Scheme == "rediss" {
+		opts.TLSConfig = &tls.Config{ServerName: u.Hostname()}
+	}
+	if u.User != nil {
+		opts.Username = u.User.Username()
+		opts.Password, _ = u.User.Password()
+	}
+	if db := strings.Trim(u.Path, "/"); db != "" {
+		n, err := strconv.Atoi(db)
+		if err != nil {
+			return nil, fmt.Errorf("invalid redis database '%s'", db)
+		}
+		opts.DB = n
+	}
+
+	r := &redisStore{addr: opts.Addr, prefix: "rss2email:"}
+
+	// Each profile has its own state.
+	if profile := configfile.Profile(); profile != "" {
+		r.prefix += profile + ":"
+	}
+	if prefix := u.Query().Get("prefix"); prefix != "" {
+		r.prefix = prefix
+	}
+
+	r.client = redis.NewClient(opts)
+	return r, nil
+}
+
+// wrap adds the address of our server to errors which aren't replies
+// from it, such as those of connecting.
+func (r *redisStore) wrap(err error) error {
+
+	var reply redis.Error
+	if err == nil || errors.As(err, &reply) {
+		return err
+	}
+	return fmt.Errorf("failed to connect to redis at %s: %s", r.addr, err)
+}
+
+// Seen is part of the Store interface.
+func (r *redisStore) Seen(id string) (bool, error) {
+
+	n, err := r.client.Exists(context.Background(), r.prefix+"seen:"+id).Result()
+	if err != nil {
+		return false, r.wrap(err)
+	}
+	return n > 0, nil
+}
+
+// MarkSeen is part of the Store interface.
+func (r *redisStore) MarkSeen(id string, link string) error {
+	return r.wrap(r.client.Set(context.Background(), r.prefix+"seen:"+id, link, seenTTL).Err())
+}
+
+// Prune is part of the Store interface.
+//
+// Our seen items expire by themselves, so there's nothing to do.
+func (r *redisStore) Prune(age time.Duration) (int, []error) {
+	return 0, nil
+}
+
+// Iterate is part of the Store interface.
+//
+// The time an item was last seen is derived from the time remaining
+// before its key expires.
+func (r *redisStore) Iterate(fn func(id string, link string, seen time.Time) error) error {
+
+	ctx := context.Background()
+	pattern := r.prefix + "seen:"
+
+	iter := r.client.Scan(ctx, 0, pattern+"*", 100).Iterator()
+	for iter.Next(ctx) {
+		key := iter.Val()
+
+		// The key may have expired since we found it.
+		link, err := r.client.Get(ctx, key).Result()
+		if err == redis.Nil {
+			continue
+		}
+		if err != nil {
+			return r.wrap(err)
+		}
+		left, err := r.client.TTL(ctx, key).Result()
+		if err != nil {
+			return r.wrap(err)
+		}
+		seen := time.Now()
+		if left > 0 {
+			seen = seen.Add(left - seenTTL)
+		}
+
+		err = fn(strings.TrimPrefix(key, pattern), link, seen)
+		if err != nil {
+			return err
+		}
+	}
+	return r.wrap(iter.Err())
+}
+
+// Meta is part of the Store interface.
+func (r *redisStore) Meta(key string) (string, error) {
+
+	val, err := r.client.Get(context.Background(), r.prefix+"meta:"+key).Result()
+	if err == redis.Nil {
+		return "", nil
+	}
+	return val, r.wrap(err)
+}
+
+// SetMeta is part of the Store interface.
+func (r *redisStore) SetMeta(key string, value string) error {
+	return r.wrap(r.client.Set(context.Background(), r.prefix+"meta:"+key, value, 0).Err())
+}
+
+// Push is part of the Store interface.
+func (r *redisStore) Push(queue string, id string, data []byte) error {
+	return r.wrap(r.client.HSet(context.Background(), r.prefix+"queue:"+queue, id, string(data)).Err())
+}
+
+// Entries is part of the Store interface.
+func (r *redisStore) Entries(queue string) (map[string][]byte, error) {
+
+	fields, err := r.client.HGetAll(context.Background(), r.prefix+"queue:"+queue).Result()
+	if err != nil {
+		return nil, r.wrap(err)
+	}
+
+	entries := make(map[string][]byte)
+	for id, data := range fields {
+		entries[id] = []byte(data)
+	}
+	return entries, nil
+}
+
+// Pop is part of the Store interface.
+func (r *redisStore) Pop(queue string, id string) error {
+	return r.wrap(r.client.HDel(context.Background(), r.prefix+"queue:"+queue, id).Err())
+}
+
+// Lock is part of the Store interface.
+func (r *redisStore) Lock(name string, owner string, ttl time.Duration) (bool, error) {
+
+	ok, err := r.client.SetNX(context.Background(), r.prefix+"lock:"+name, owner, ttl).Result()
+	if err != nil {
+		return false, r.wrap(err)
+	}
+	return ok, nil
+}
+
+// Unlock is part of the Store interface.
+//
+// The lock is only removed if we still hold it, it might have expired
+// and been taken by another instance.  That is checked by a script on
+// the server, so that another instance can't take the lock between our
+// check and its removal.
+func (r *redisStore) Unlock(name string, owner string) error {
+	return r.wrap(unlockScript.Run(context.Background(), r.client, []string{r.prefix + "lock:" + name}, owner).Err())
+}
@@ -0,0 +1,518 @@
+package withstate
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/mmcdole/gofeed"
+	"github.com/skx/rss2email/configfile"
+)
+
+// fakeRedis is a minimal Redis server, which supports the commands we use.
+type fakeRedis struct {
+	mutex    sync.Mutex
+	password string
+	keys     map[string]string
+	ttls     map[string]string
+	hashes   map[string]map[string]string
+	commands []string
+	conns    []net.Conn
+}
+
+// readCommand reads a single command, an array of bulk strings, from a
+// client.
+func readCommand(rd *bufio.Reader) ([]string, error) {
+
+	var args []string
+	line, err := rd.ReadString('\n')
+	if err != nil || !strings.HasPrefix(line, "*") {
+		return nil, fmt.Errorf("unexpected command %q: %v", line, err)
+	}
+	n, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+	for i := 0; i < n; i++ {
+		line, err = rd.ReadString('\n')
+		if err != nil || !strings.HasPrefix(line, "$") {
+			return nil, fmt.Errorf("unexpected argument %q: %v", line, err)
+		}
+		size, _ := strconv.Atoi(strings.TrimSpace(line[1:]))
+		buf := make([]byte, size+2)
+		if _, err = io.ReadFull(rd, buf); err != nil {
+			return nil, err
+		}
+		args = append(args, string(buf[:size]))
+	}
+	return args, nil
+}
+
+// drop closes each connection, as though the server had restarted.
+func (f *fakeRedis) drop() {
+
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	for _, conn := range f.conns {
+		conn.Close()
+	}
+	f.conns = nil
+}
+
+// serve handles the commands of a single connection.
+func (f *fakeRedis) serve(conn net.Conn) {
+
+	defer conn.Close()
+	rd := bufio.NewReader(conn)
+	authed := f.password == ""
+
+	f.mutex.Lock()
+	f.conns = append(f.conns, conn)
+	f.mutex.Unlock()
+
+	for {
+		args, err := readCommand(rd)
+		if err != nil || len(args) == 0 {
+			return
+		}
+		args[0] = strings.ToUpper(args[0])
+
+		f.mutex.Lock()
+		f.commands = append(f.commands, args[0])
+
+		res := "+OK\r\n"
+		switch {
+		case args[0] == "AUTH":
+			authed = args[len(args)-1] == f.password
+			if !authed {
+				res = "-WRONGPASS invalid password\r\n"
+			}
+		case !authed:
+			res = "-NOAUTH Authentication required.\r\n"
+		case args[0] == "SELECT":
+		case args[0] == "EXISTS":
+			_, ok := f.keys[args[1]]
+			res = ":0\r\n"
+			if ok {
+				res = ":1\r\n"
+			}
+		case args[0] == "SET":
+			_, exists := f.keys[args[1]]
+			nx, ttl := false, ""
+			for i := 3; i < len(args); i++ {
+				switch strings.ToUpper(args[i]) {
+				case "NX":
+					nx = true
+				case "EX":
+					ttl = args[i+1]
+				}
+			}
+			if nx && exists {
+				res = "$-1\r\n"
+				break
+			}
+			f.keys[args[1]] = args[2]
+			if ttl != "" {
+				f.ttls[args[1]] = ttl
+			}
+		case args[0] == "DEL":
+			delete(f.keys, args[1])
+			res = ":1\r\n"
+		case args[0] == "GET":
+			val, ok := f.keys[args[1]]
+			res = "$-1\r\n"
+			if ok {
+				res = fmt.Sprintf("$%d\r\n%s\r\n", len(val), val)
+			}
+		case args[0] == "HSET":
+			if f.hashes[args[1]] == nil {
+				f.hashes[args[1]] = make(map[string]string)
+			}
+			f.hashes[args[1]][args[2]] = args[3]
+			res = ":1\r\n"
+		case args[0] == "HGETALL":
+			h := f.hashes[args[1]]
+			res = fmt.Sprintf("*%d\r\n", len(h)*2)
+			for k, v := range h {
+				res += fmt.Sprintf("$%d\r\n%s\r\n$%d\r\n%s\r\n", len(k), k, len(v), v)
+			}
+		case args[0] == "SCAN":
+			var keys []string
+			for k := range f.keys {
+				if ok, _ := filepath.Match(args[3], k); ok {
+					keys = append(keys, k)
+				}
+			}
+			res = fmt.Sprintf("*2\r\n$1\r\n0\r\n*%d\r\n", len(keys))
+			for _, k := range keys {
+				res += fmt.Sprintf("$%d\r\n%s\r\n", len(k), k)
+			}
+		case args[0] == "TTL":
+			res = ":-1\r\n"
+			if ttl, ok := f.ttls[args[1]]; ok {
+				res = ":" + ttl + "\r\n"
+			}
+		case args[0] == "EVALSHA":
+			res = "-NOSCRIPT No matching script.\r\n"
+		case args[0] == "EVAL" && strings.Contains(args[1], "redis.call('del'"):
+			res = ":0\r\n"
+			if val, ok := f.keys[args[3]]; ok && val == args[4] {
+				delete(f.keys, args[3])
+				res = ":1\r\n"
+			}
+		case args[0] == "HDEL":
+			delete(f.hashes[args[1]], args[2])
+			res = ":1\r\n"
+		default:
+			res = "-ERR unknown command\r\n"
+		}
+		f.mutex.Unlock()
+
+		conn.Write([]byte(res))
+	}
+}
+
+// startRedis starts a fake Redis server, returning it and its address.
+func startRedis(t *testing.T, password string) (*fakeRedis, string) {
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatalf("failed to listen: %s", err)
+	}
+	t.Cleanup(func() { l.Close() })
+
+	f := &fakeRedis{
+		password: password,
+		keys:     make(map[string]string),
+		ttls:     make(map[string]string),
+		hashes:   make(map[string]map[string]string),
+	}
+	go func() {
+		for {
+			conn, err := l.Accept()
+			if err != nil {
+				return
+			}
+			go f.serve(conn)
+		}
+	}()
+	return f, l.Addr().String()
+}
+
+// testStore tests the behaviour of the given store.
+func testStore(t *testing.T, s Store) {
+
+	seen, err := s.Seen("abc")
+	if err != nil || seen {
+		t.Fatalf("unexpected seen item: %v %s", seen, err)
+	}
+	err = s.MarkSeen("abc", "https://example.com/")
+	if err != nil {
+		t.Fatalf("failed to mark item as seen: %s", err)
+	}
+	seen, err = s.Seen("abc")
+	if err != nil || !seen {
+		t.Fatalf("item wasn't seen: %v %s", seen, err)
+	}
+
+	// Seen items may be listed, their IDs being hashes.
+	id := hash("listed")
+	s.MarkSeen(id, "https://example.com/listed")
+	found := make(map[string]string)
+	err = s.Iterate(func(id string, link string, at time.Time) error {
+		found[id] = link
+		if time.Since(at) > time.Minute {
+			t.Fatalf("unexpected time last seen: %s", at)
+		}
+		return nil
+	})
+	if err != nil || found[id] != "https://example.com/listed" {
+		t.Fatalf("unexpected seen items: %v %s", found, err)
+	}
+	stop := fmt.Errorf("stop")
+	if err = s.Iterate(func(string, string, time.Time) error { return stop }); err != stop {
+		t.Fatalf("expected the error to be returned, got %v", err)
+	}
+
+	val, err := s.Meta("key")
+	if err != nil || val != "" {
+		t.Fatalf("unexpected value: %s %s", val, err)
+	}
+	err = s.SetMeta("key", "value")
+	if err != nil {
+		t.Fatalf("failed to set value: %s", err)
+	}
+	val, err = s.Meta("key")
+	if err != nil || val != "value" {
+		t.Fatalf("unexpected value: %s %s", val, err)
+	}
+
+	for _, id := range []string{"one", "two", "one"} {
+		err = s.Push("queue", id, []byte("data "+id))
+		if err != nil {
+			t.Fatalf("failed to push: %s", err)
+		}
+	}
+	err = s.Pop("queue", "one")
+	if err != nil {
+		t.Fatalf("failed to pop: %s", err)
+	}
+	entries, err := s.Entries("queue")
+	if err != nil || len(entries) != 1 || string(entries["two"]) != "data two" {
+		t.Fatalf("unexpected entries: %v %s", entries, err)
+	}
+	entries, err = s.Entries("other")
+	if err != nil || len(entries) != 0 {
+		t.Fatalf("unexpected entries: %v %s", entries, err)
+	}
+}
+
+// testLock tests the locking of the given store, which is shared.
+func testLock(t *testing.T, s Store) {
+
+	ok, err := s.Lock("feed", "one", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("failed to take lock: %v %s", ok, err)
+	}
+
+	// Another owner can't take the lock, or release it.
+	ok, err = s.Lock("feed", "two", time.Minute)
+	if err != nil || ok {
+		t.Fatalf("lock was taken twice: %v %s", ok, err)
+	}
+	s.Unlock("feed", "two")
+	ok, _ = s.Lock("feed", "two", time.Minute)
+	if ok {
+		t.Fatalf("lock was released by another owner")
+	}
+
+	// Other locks are independent.
+	ok, _ = s.Lock("other", "two", time.Minute)
+	if !ok {
+		t.Fatalf("failed to take another lock")
+	}
+
+	// Once released the lock may be taken.
+	err = s.Unlock("feed", "one")
+	if err != nil {
+		t.Fatalf("failed to release lock: %s", err)
+	}
+	ok, err = s.Lock("feed", "two", time.Minute)
+	if err != nil || !ok {
+		t.Fatalf("failed to take released lock: %v %s", ok, err)
+	}
+}
+
+func TestFileStore(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	testStore(t, &fileStore{})
+}
+
+func TestMemoryStore(t *testing.T) {
+
+	s := NewMemoryStore()
+	testStore(t, s)
+	testLock(t, s)
+
+	// Old items are pruned.
+	s.(*memoryStore).items["old"] = memoryItem{seen: time.Now().Add(-5 * 24 * time.Hour)}
+	n, errs := s.Prune((4 * 24) * time.Hour)
+	if n != 1 || len(errs) != 0 {
+		t.Fatalf("unexpected prune: %d %v", n, errs)
+	}
+	if seen, _ := s.Seen("abc"); !seen {
+		t.Fatalf("recent item was pruned")
+	}
+}
+
+func TestRedisStore(t *testing.T) {
+
+	f, addr := startRedis(t, "secret")
+
+	u, _ := url.Parse("redis://:secret@" + addr + "/2?prefix=test:")
+	s, err := newRedisStore(u)
+	if err != nil {
+		t.Fatalf("failed to create store: %s", err)
+	}
+	testStore(t, s)
+	testLock(t, s)
+
+	// Our keys are prefixed, and seen items expire.
+	if f.keys["test:seen:abc"] != "https://example.com/" {
+		t.Fatalf("seen item wasn't stored: %v", f.keys)
+	}
+	if f.ttls["test:seen:abc"] != fmt.Sprintf("%d", int(seenTTL.Seconds())) {
+		t.Fatalf("seen item doesn't expire: %v", f.ttls)
+	}
+	if !strings.Contains(strings.Join(f.commands, " "), "AUTH SELECT") {
+		t.Fatalf("unexpected commands: %v", f.commands)
+	}
+
+	// Locks are released by a script, rather than GET and DEL.
+	for _, cmd := range f.commands {
+		if cmd == "DEL" {
+			t.Fatalf("lock released non-atomically: %v", f.commands)
+		}
+	}
+
+	// We reconnect if our connection is lost.
+	f.drop()
+	if _, err = s.Seen("abc"); err != nil {
+		t.Fatalf("failed to reconnect: %s", err)
+	}
+
+	// Bad passwords are reported.
+	u, _ = url.Parse("redis://:wrong@" + addr)
+	s, _ = newRedisStore(u)
+	if _, err = s.Seen("abc"); err == nil || !strings.Contains(err.Error(), "WRONGPASS") {
+		t.Fatalf("expected error with the wrong password, got %v", err)
+	}
+}
+
+func TestSQLStore(t *testing.T) {
+
+	path := filepath.Join(t.TempDir(), "state.db")
+
+	s, err := newSQLStore("sqlite://" + path)
+	if err != nil {
+		t.Fatalf("failed to open store: %s", err)
+	}
+	testStore(t, s)
+	testLock(t, s)
+
+	// Expired locks may be taken.
+	ok, _ := s.Lock("expired", "one", -time.Second)
+	if !ok {
+		t.Fatalf("failed to take lock")
+	}
+	ok, _ = s.Lock("expired", "two", time.Minute)
+	if !ok {
+		t.Fatalf("failed to take expired lock")
+	}
+
+	// Old items, the two marked by testStore, are pruned.
+	_, err = s.exec(`UPDATE rss2email_seen SET seen_at = ?`, time.Now().Add(-5*24*time.Hour).Unix())
+	if err != nil {
+		t.Fatalf("failed to age items: %s", err)
+	}
+	s.MarkSeen("def", "https://example.com/def")
+	n, errs := s.Prune((4 * 24) * time.Hour)
+	if n != 2 || len(errs) != 0 {
+		t.Fatalf("unexpected prune: %d %v", n, errs)
+	}
+	if seen, _ := s.Seen("def"); !seen {
+		t.Fatalf("recent item was pruned")
+	}
+	s.db.Close()
+
+	// Reopening the database doesn't repeat our migrations.
+	s, err = newSQLStore("sqlite3://" + path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %s", err)
+	}
+	var version int
+	s.db.QueryRow(`SELECT COUNT(*) FROM rss2email_migrations`).Scan(&version)
+	if version != len(migrations) {
+		t.Fatalf("unexpected schema version %d", version)
+	}
+	if seen, _ := s.Seen("def"); !seen {
+		t.Fatalf("state was lost")
+	}
+	s.db.Close()
+
+	// Instances starting at once migrate a new database only once.
+	path = filepath.Join(t.TempDir(), "shared.db")
+	var wg sync.WaitGroup
+	results := make(chan error, 4)
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s, err := newSQLStore("sqlite://" + path)
+			if err == nil {
+				s.db.Close()
+			}
+			results <- err
+		}()
+	}
+	wg.Wait()
+	close(results)
+	for err := range results {
+		if err != nil {
+			t.Fatalf("failed to open shared store: %s", err)
+		}
+	}
+	s, err = newSQLStore("sqlite://" + path)
+	if err != nil {
+		t.Fatalf("failed to reopen store: %s", err)
+	}
+	s.db.QueryRow(`SELECT COUNT(*) FROM rss2email_migrations`).Scan(&version)
+	if version != len(migrations) {
+		t.Fatalf("unexpected number of migrations %d", version)
+	}
+	s.db.Close()
+
+	// Placeholders are rewritten for PostgreSQL.
+	pg := &sqlStore{driver: "postgres"}
+	if q := pg.rebind("SELECT a FROM b WHERE c = ? AND d = ?"); q != "SELECT a FROM b WHERE c = $1 AND d = $2" {
+		t.Fatalf("unexpected query: %s", q)
+	}
+}
+
+func TestSetStore(t *testing.T) {
+
+	defer SetStore("")
+
+	f, addr := startRedis(t, "")
+	for _, location := range []string{"", "file", "sqlite://" + filepath.Join(t.TempDir(), "state.db"), "redis://" + addr} {
+		if err := SetStore(location); err != nil {
+			t.Fatalf("failed to use store %s: %s", location, err)
+		}
+	}
+	if _, ok := store().(*redisStore); !ok {
+		t.Fatalf("redis store wasn't used")
+	}
+
+	// Items are recorded in the store in use.
+	x := &FeedItem{Item: &gofeed.Item{GUID: "redis-test", Link: "https://example.com/"}}
+	if !x.IsNew() {
+		t.Fatalf("unexpected seen item")
+	}
+	x.RecordSeen()
+	if x.IsNew() || f.keys["rss2email:seen:"+x.id()] == "" {
+		t.Fatalf("item wasn't recorded in redis: %v", f.keys)
+	}
+
+	for _, location := range []string{"memcache://localhost/", "redis://localhost/db", "::"} {
+		if err := SetStore(location); err == nil {
+			t.Fatalf("expected error with store %s", location)
+		}
+	}
+
+	// Other stores may be registered, or used directly.
+	mem := NewMemoryStore()
+	Register("test", func(location string) (Store, error) { return mem, nil })
+	defer delete(drivers, "test")
+	if strings.Join(Drivers(), ",") != "file,memory,mysql,postgres,postgresql,redis,rediss,sqlite,sqlite3,test" {
+		t.Fatalf("unexpected drivers: %v", Drivers())
+	}
+	if err := SetStore("test://anything"); err != nil || store() != mem {
+		t.Fatalf("registered store wasn't used: %s", err)
+	}
+	if err := SetStore("memory"); err != nil || store() == mem {
+		t.Fatalf("memory store wasn't used: %s", err)
+	}
+	Use(mem)
+	if store() != mem {
+		t.Fatalf("store wasn't used")
+	}
+}
@@ -0,0 +1,63 @@
+package withstate
+
+import (
+	"encoding/json"
+	"time"
+)
+
+// maxTitles is the largest number of titles we remember for a feed.
+const maxTitles = 1000
+
+// SentTitle is the title of an item which was delivered.
+type SentTitle struct {
+
+	// Title holds the title, which is normalised by the caller.
+	Title string `json:"title"`
+
+	// Sent holds the time the item was delivered.
+	Sent time.Time `json:"sent"`
+}
+
+// SentTitles returns the titles of the items of the feed with the given
+// URL which were delivered, as recorded by RecordTitle.
+func SentTitles(url string) ([]SentTitle, error) {
+
+	var titles []SentTitle
+
+	val, err := store().Meta("titles:" + url)
+	if err != nil || val == "" {
+		return titles, err
+	}
+	err = json.Unmarshal([]byte(val), &titles)
+	return titles, err
+}
+
+// RecordTitle records that an item of the feed with the given URL, with
+// the given title, has just been delivered.  Titles older than the given
+// age are forgotten.
+func RecordTitle(url string, title string, keep time.Duration) error {
+
+	titles, err := SentTitles(url)
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	titles = append(titles, SentTitle{Title: title, Sent: now})
+
+	var recent []SentTitle
+	for _, t := range titles {
+		if now.Sub(t.Sent) <= keep {
+			recent = append(recent, t)
+		}
+	}
+	if len(recent) > maxTitles {
+		recent = recent[len(recent)-maxTitles:]
+	}
+
+	data, err := json.Marshal(recent)
+	if err != nil {
+		return err
+	}
+	return store().SetMeta("titles:"+url, string(data))
+}
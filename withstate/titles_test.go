@@ -0,0 +1,46 @@
+package withstate
+
+import (
+	"testing"
+	"time"
+
+	"github.com/skx/rss2email/configfile"
+)
+
+func TestSentTitles(t *testing.T) {
+
+	configfile.SetStateDirectory(t.TempDir())
+	defer configfile.SetStateDirectory("")
+
+	url := "https://example.com/rss"
+
+	titles, err := SentTitles(url)
+	if err != nil || len(titles) != 0 {
+		t.Fatalf("unexpected titles of an unknown feed: %v %v", titles, err)
+	}
+
+	for _, title := range []string{"one", "two"} {
+		err = RecordTitle(url, title, time.Hour)
+		if err != nil {
+			t.Fatalf("failed to record title: %s", err)
+		}
+	}
+	titles, _ = SentTitles(url)
+	if len(titles) != 2 || titles[0].Title != "one" || titles[1].Title != "two" {
+		t.Fatalf("unexpected titles: %v", titles)
+	}
+
+	// Older titles are forgotten.
+	time.Sleep(10 * time.Millisecond)
+	RecordTitle(url, "three", 5*time.Millisecond)
+	titles, _ = SentTitles(url)
+	if len(titles) != 1 || titles[0].Title != "three" {
+		t.Fatalf("unexpected titles: %v", titles)
+	}
+
+	// Feeds are independent.
+	titles, _ = SentTitles("https://example.org/rss")
+	if len(titles) != 0 {
+		t.Fatalf("unexpected titles: %v", titles)
+	}
+}